@@ -0,0 +1,173 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"sync"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// AdaptiveConfig configures an AdaptiveMailer.
+type AdaptiveConfig struct {
+	// Min and Max bound the number of concurrent sends; they default
+	// to 1 and 16.
+	Min, Max int
+	// Initial is the starting limit; defaults to Min.
+	Initial int
+	// Decrease multiplies the limit on pressure; defaults to 0.5.
+	Decrease float64
+	// LatencyTarget, if set, counts sends slower than this as pressure
+	// even when they succeed.
+	LatencyTarget time.Duration
+	// Pressure reports whether err shows that the relay is overloaded;
+	// defaults to IsPressure.
+	Pressure func(err error) bool
+}
+
+// AdaptiveMailer is a Mailer that limits the concurrent sends to
+// another Mailer and adapts the limit to the relay (AIMD): every
+// successful send raises it by 1/limit, so about one per round of
+// sends, and a 421 or 451 reply, or a slow send, cuts it by Decrease.
+// Sends already in flight when the limit is cut do not cut it again.
+// Unlike a fixed pool size, this tracks a provider whose capacity
+// changes over the day. Send blocks while the limit is reached.
+type AdaptiveMailer struct {
+	next Mailer
+	cfg  AdaptiveConfig
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	epoch    uint64        // incremented on every cut
+	changed  chan struct{} // closed when a slot may have freed
+}
+
+// NewAdaptiveMailer creates an adaptive mailer sending through next.
+//
+// Parameters:
+//   - next: The mailer that sends the messages.
+//   - cfg: The adaptive config.
+//
+// Returns:
+//   - *AdaptiveMailer: The adaptive mailer.
+func NewAdaptiveMailer(next Mailer, cfg AdaptiveConfig) *AdaptiveMailer {
+	if cfg.Min <= 0 {
+		cfg.Min = 1
+	}
+	if cfg.Max <= 0 {
+		cfg.Max = 16
+	}
+	cfg.Max = max(cfg.Max, cfg.Min)
+	if cfg.Initial <= 0 {
+		cfg.Initial = cfg.Min
+	}
+	if cfg.Decrease <= 0 || cfg.Decrease >= 1 {
+		cfg.Decrease = 0.5
+	}
+	if cfg.Pressure == nil {
+		cfg.Pressure = IsPressure
+	}
+	return &AdaptiveMailer{
+		next:    next,
+		cfg:     cfg,
+		limit:   float64(min(max(cfg.Initial, cfg.Min), cfg.Max)),
+		changed: make(chan struct{}),
+	}
+}
+
+// Send waits for a free slot, sends msg through the wrapped mailer and
+// adjusts the limit from the outcome.
+//
+// Parameters:
+//   - ctx: The context; ending it while waiting abandons the send.
+//   - msg: The message.
+//   - opts: The options, passed on to the wrapped mailer.
+//
+// Returns:
+//   - error: The error of the wrapped mailer, or ctx.Err().
+func (a *AdaptiveMailer) Send(ctx context.Context, msg types.Message, opts ...Option) error {
+	epoch, err := a.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = a.next.Send(ctx, msg, opts...)
+	slow := a.cfg.LatencyTarget > 0 && time.Since(start) > a.cfg.LatencyTarget
+	a.release(epoch, err, slow)
+	return err
+}
+
+// Limit returns the current concurrency limit.
+//
+// Returns:
+//   - int: The number of sends allowed in flight.
+func (a *AdaptiveMailer) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return int(a.limit)
+}
+
+// acquire takes a slot and returns the epoch it was taken in.
+func (a *AdaptiveMailer) acquire(ctx context.Context) (uint64, error) {
+	for {
+		a.mu.Lock()
+		if a.inflight < int(a.limit) {
+			a.inflight++
+			epoch := a.epoch
+			a.mu.Unlock()
+			return epoch, nil
+		}
+		changed := a.changed
+		a.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// release frees a slot taken in epoch and adjusts the limit: down on
+// pressure unless already cut since the send started, up on success.
+// Other errors, such as a refused recipient, leave it unchanged.
+func (a *AdaptiveMailer) release(epoch uint64, err error, slow bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.inflight--
+	switch {
+	case slow || err != nil && a.cfg.Pressure(err):
+		if epoch == a.epoch {
+			a.limit = max(float64(a.cfg.Min), a.limit*a.cfg.Decrease)
+			a.epoch++
+		}
+	case err == nil:
+		a.limit = min(float64(a.cfg.Max), a.limit+1/a.limit)
+	}
+	close(a.changed)
+	a.changed = make(chan struct{})
+}
+
+// IsPressure reports whether err shows that the relay is asking senders
+// to slow down: an SMTP 421 or 451 reply, or an HTTP 429 from an API
+// adapter.
+//
+// Parameters:
+//   - err: The send error.
+//
+// Returns:
+//   - bool: True if err signals pressure.
+func IsPressure(err error) bool {
+	var se *types.SMTPError
+	if errors.As(err, &se) && se.Code != 0 {
+		return se.Code == 421 || se.Code == 451
+	}
+	var te *textproto.Error
+	if errors.As(err, &te) {
+		return te.Code == 421 || te.Code == 451
+	}
+	var ae *types.APIError
+	return errors.As(err, &ae) && ae.StatusCode == 429
+}
@@ -0,0 +1,148 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// errMailer returns the errors of fail in turn, then nil, and records
+// the peak number of concurrent sends.
+type errMailer struct {
+	mu       sync.Mutex
+	fail     []error
+	inflight atomic.Int32
+	peak     atomic.Int32
+	delay    time.Duration
+}
+
+func (m *errMailer) Send(ctx context.Context, msg types.Message, opts ...Option) error {
+	n := m.inflight.Add(1)
+	defer m.inflight.Add(-1)
+	for p := m.peak.Load(); n > p && !m.peak.CompareAndSwap(p, n); p = m.peak.Load() {
+	}
+	time.Sleep(m.delay)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.fail) == 0 {
+		return nil
+	}
+	err := m.fail[0]
+	m.fail = m.fail[1:]
+	return err
+}
+
+func TestAdaptiveMailerAIMD(t *testing.T) {
+	pressure := &types.SMTPError{Command: "MAIL FROM", Code: 421, Err: errors.New("421 busy")}
+	next := &errMailer{}
+	a := NewAdaptiveMailer(next, AdaptiveConfig{Min: 1, Max: 4, Initial: 4})
+	ctx := context.Background()
+
+	next.fail = []error{pressure}
+	if err := a.Send(ctx, types.Message{}); !errors.Is(err, pressure) {
+		t.Fatalf("send: %v", err)
+	}
+	if a.Limit() != 2 {
+		t.Fatalf("limit after 421 = %d, want 2", a.Limit())
+	}
+	next.fail = []error{&types.SMTPError{Command: "RCPT TO", Code: 550, Err: errors.New("550")}}
+	a.Send(ctx, types.Message{})
+	if a.Limit() != 2 {
+		t.Fatalf("limit after 550 = %d, want 2", a.Limit())
+	}
+	// Each success adds 1/limit: 2, 2.5, 2.9, 3.24.
+	for range 3 {
+		if err := a.Send(ctx, types.Message{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a.Limit() != 3 {
+		t.Fatalf("limit after successes = %d, want 3", a.Limit())
+	}
+	for range 20 {
+		a.Send(ctx, types.Message{})
+	}
+	if a.Limit() != 4 {
+		t.Fatalf("limit = %d, want capped at 4", a.Limit())
+	}
+}
+
+func TestAdaptiveMailerConcurrency(t *testing.T) {
+	next := &errMailer{delay: 5 * time.Millisecond}
+	a := NewAdaptiveMailer(next, AdaptiveConfig{Min: 2, Max: 2})
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.Send(context.Background(), types.Message{})
+		}()
+	}
+	wg.Wait()
+	if p := next.peak.Load(); p != 2 {
+		t.Fatalf("peak concurrency = %d, want 2", p)
+	}
+
+	// Sends in flight when the limit is cut do not cut it again.
+	busy := fmt.Errorf("smtp: %w", &textproto.Error{Code: 451, Msg: "4.3.2 try later"})
+	next = &errMailer{delay: 5 * time.Millisecond, fail: []error{busy, busy, busy, busy}}
+	a = NewAdaptiveMailer(next, AdaptiveConfig{Max: 8, Initial: 8})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.Send(context.Background(), types.Message{})
+		}()
+	}
+	wg.Wait()
+	if a.Limit() != 4 {
+		t.Fatalf("limit = %d, want one cut to 4", a.Limit())
+	}
+}
+
+func TestAdaptiveMailerLatencyAndCancel(t *testing.T) {
+	next := &errMailer{delay: 20 * time.Millisecond}
+	a := NewAdaptiveMailer(next, AdaptiveConfig{Max: 4, Initial: 4, LatencyTarget: time.Millisecond})
+	if err := a.Send(context.Background(), types.Message{}); err != nil {
+		t.Fatal(err)
+	}
+	if a.Limit() != 2 {
+		t.Fatalf("limit after slow send = %d, want 2", a.Limit())
+	}
+
+	a = NewAdaptiveMailer(&errMailer{delay: 50 * time.Millisecond}, AdaptiveConfig{Max: 1})
+	go a.Send(context.Background(), types.Message{})
+	time.Sleep(10 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := a.Send(ctx, types.Message{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline, got %v", err)
+	}
+}
+
+func TestIsPressure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&types.SMTPError{Code: 421}, true},
+		{&types.SMTPError{Code: 451}, true},
+		{&types.SMTPError{Code: 452}, false},
+		{&types.SMTPError{Err: &textproto.Error{Code: 421}}, true},
+		{&types.APIError{StatusCode: 429}, true},
+		{&types.APIError{StatusCode: 500}, false},
+		{errors.New("421"), false},
+	}
+	for _, tt := range tests {
+		if got := IsPressure(tt.err); got != tt.want {
+			t.Fatalf("IsPressure(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
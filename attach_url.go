@@ -0,0 +1,179 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// AttachURLOptions controls AttachURL.
+type AttachURLOptions struct {
+	// Filename names the attachment; defaults to the filename of the
+	// response's Content-Disposition, then the last URL path segment.
+	Filename string
+	// ContentType overrides the type the server reports.
+	ContentType string
+	// AllowedTypes lists the accepted media types, exact
+	// ("application/pdf") or by prefix ("image/*"). A missing or
+	// generic server type is checked against the sniffed content.
+	// Empty accepts any type.
+	AllowedTypes []string
+	// MaxBytes caps the download (default 10 MiB). A larger document
+	// fails the build.
+	MaxBytes int64
+	// Timeout bounds the whole download, including the streaming at
+	// build time (default 30 seconds).
+	Timeout time.Duration
+	// Client fetches the document; defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// AttachURL starts downloading rawURL and returns an attachment that
+// streams the response into the message when it is built, so the
+// document is never held in memory as a whole. The status, declared
+// length and content type are checked before it returns. The reader
+// can be read once: build or send the message soon, and close it
+// (it implements io.Closer) if the message is dropped.
+//
+// Parameters:
+//   - ctx: The context for the download.
+//   - rawURL: The http(s) URL of the document.
+//   - opts: The download settings.
+//
+// Returns:
+//   - types.Attachment: The attachment.
+//   - error: The error if the request fails or the response is refused.
+func AttachURL(
+	ctx context.Context,
+	rawURL string,
+	opts AttachURLOptions,
+) (types.Attachment, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return types.Attachment{}, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return types.Attachment{}, fmt.Errorf("attach %s: not an http(s) URL", rawURL)
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 10 << 20
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	client := http.DefaultClient
+	if opts.Client != nil {
+		client = opts.Client
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		cancel()
+		return types.Attachment{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		cancel()
+		return types.Attachment{}, fmt.Errorf("attach %s: %w", rawURL, err)
+	}
+	r := &urlReader{url: rawURL, body: resp.Body, max: opts.MaxBytes, cancel: cancel}
+	fail := func(format string, args ...any) (types.Attachment, error) {
+		_ = r.Close()
+		return types.Attachment{}, fmt.Errorf("attach %s: "+format,
+			append([]any{rawURL}, args...)...)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fail("%s", resp.Status)
+	}
+	if resp.ContentLength > opts.MaxBytes {
+		return fail("%d bytes exceeds limit of %d", resp.ContentLength, opts.MaxBytes)
+	}
+	r.br = bufio.NewReaderSize(resp.Body, 512)
+	ctype, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if ctype == "" || ctype == "application/octet-stream" {
+		// Peek does not consume, so the full document is still streamed.
+		head, _ := r.br.Peek(512)
+		ctype, _, _ = mime.ParseMediaType(http.DetectContentType(head))
+	}
+	if !typeAllowed(ctype, opts.AllowedTypes) {
+		return fail("content type %q not allowed", ctype)
+	}
+	if opts.ContentType != "" {
+		ctype = opts.ContentType
+	}
+	name := opts.Filename
+	if name == "" {
+		_, params, _ := mime.ParseMediaType(resp.Header.Get("Content-Disposition"))
+		name = path.Base(params["filename"])
+	}
+	if name == "" || name == "." || name == "/" {
+		name = path.Base(u.Path)
+	}
+	if name == "" || name == "." || name == "/" {
+		name = "download"
+	}
+	return types.Attachment{Filename: name, ContentType: ctype, Reader: r}, nil
+}
+
+// typeAllowed reports whether ctype matches one of allowed.
+func typeAllowed(ctype string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == ctype || strings.HasSuffix(a, "/*") &&
+			strings.HasPrefix(ctype, strings.TrimSuffix(a, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// urlReader streams a response body, failing once more than max bytes
+// have been read. It closes the body at EOF or on error.
+type urlReader struct {
+	url    string
+	body   io.ReadCloser
+	br     *bufio.Reader
+	max    int64
+	n      int64
+	cancel context.CancelFunc
+	err    error
+}
+
+// Read implements io.Reader.
+func (r *urlReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.br.Read(p)
+	r.n += int64(n)
+	if r.n > r.max {
+		err = fmt.Errorf("attach %s: larger than %d bytes", r.url, r.max)
+	}
+	if err != nil {
+		r.err = err
+		_ = r.Close()
+	}
+	return n, err
+}
+
+// Close releases the download. It is safe to call more than once.
+func (r *urlReader) Close() error {
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+		return r.body.Close()
+	}
+	return nil
+}
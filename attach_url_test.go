@@ -0,0 +1,79 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAttachURL(t *testing.T) {
+	pdf := append([]byte("%PDF-1.7\n"), bytes.Repeat([]byte("x"), 4096)...)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/docs/invoice.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Length", strconv.Itoa(len(pdf)))
+		w.Write(pdf)
+	})
+	mux.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+		// No length and no type: the content is sniffed.
+		w.Header().Set("Content-Disposition", `attachment; filename="report.pdf"`)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.(http.Flusher).Flush()
+		w.Write(pdf)
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "<html>login</html>")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	ctx := context.Background()
+	pdfOnly := AttachURLOptions{AllowedTypes: []string{"application/pdf", "image/*"}}
+
+	a, err := AttachURL(ctx, srv.URL+"/docs/invoice.pdf", pdfOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(a.Reader)
+	if err != nil || !bytes.Equal(got, pdf) || a.Filename != "invoice.pdf" ||
+		a.ContentType != "application/pdf" {
+		t.Fatalf("attachment %+v: %d bytes, %v", a, len(got), err)
+	}
+
+	a, err = AttachURL(ctx, srv.URL+"/download", pdfOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(a.Reader); !bytes.Equal(got, pdf) || a.Filename != "report.pdf" {
+		t.Fatalf("sniffed attachment %+v: %d bytes", a, len(got))
+	}
+
+	if _, err := AttachURL(ctx, srv.URL+"/page", pdfOnly); err == nil ||
+		!strings.Contains(err.Error(), "text/html") {
+		t.Fatalf("expected content type error, got %v", err)
+	}
+	if _, err := AttachURL(ctx, srv.URL+"/missing", pdfOnly); err == nil {
+		t.Fatal("expected status error")
+	}
+	if _, err := AttachURL(ctx, srv.URL+"/docs/invoice.pdf",
+		AttachURLOptions{MaxBytes: 100}); err == nil {
+		t.Fatal("expected Content-Length over limit to fail")
+	}
+	// Without Content-Length the limit applies while streaming.
+	a, err = AttachURL(ctx, srv.URL+"/download", AttachURLOptions{MaxBytes: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(a.Reader); err == nil ||
+		!strings.Contains(err.Error(), "larger than 1000 bytes") {
+		t.Fatalf("expected streaming limit error, got %v", err)
+	}
+	if _, err := AttachURL(ctx, "file:///etc/passwd", AttachURLOptions{}); err == nil {
+		t.Fatal("expected scheme error")
+	}
+}
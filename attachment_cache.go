@@ -0,0 +1,105 @@
+package email
+
+import (
+	"container/list"
+	"sync"
+)
+
+// AttachmentCache is a thread-safe, size-bounded LRU cache of encoded
+// attachment bodies. Share one across sends with WithAttachmentCache so
+// an attachment sent to many recipients is base64-encoded only once.
+type AttachmentCache struct {
+	maxBytes int64
+	mu       sync.Mutex
+	size     int64
+	order    *list.List // front is most recently used
+	items    map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	encoded []byte
+}
+
+// NewAttachmentCache returns a cache holding up to maxBytes of encoded
+// data. Least recently used entries are evicted first; entries larger
+// than maxBytes are not stored.
+//
+// Parameters:
+//   - maxBytes: The capacity in encoded bytes.
+//
+// Returns:
+//   - *AttachmentCache: The cache.
+func NewAttachmentCache(maxBytes int64) *AttachmentCache {
+	return &AttachmentCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+// Get returns the encoded body stored under key.
+//
+// Parameters:
+//   - key: The content hash.
+//
+// Returns:
+//   - []byte: The encoded body; must not be modified.
+//   - bool: Whether key was found.
+func (c *AttachmentCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*cacheEntry).encoded, true
+}
+
+// Put stores encoded under key, evicting old entries as needed.
+//
+// Parameters:
+//   - key: The content hash.
+//   - encoded: The encoded body.
+func (c *AttachmentCache) Put(key string, encoded []byte) {
+	n := int64(len(encoded))
+	if n > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+	for c.size+n > c.maxBytes {
+		el := c.order.Back()
+		e := el.Value.(*cacheEntry)
+		c.order.Remove(el)
+		delete(c.items, e.key)
+		c.size -= int64(len(e.encoded))
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, encoded: encoded})
+	c.size += n
+}
+
+// Len returns the number of cached attachments.
+//
+// Returns:
+//   - int: The entry count.
+func (c *AttachmentCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Size returns the total encoded bytes held.
+//
+// Returns:
+//   - int64: The cached bytes.
+func (c *AttachmentCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
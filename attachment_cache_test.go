@@ -0,0 +1,35 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAttachmentCacheEviction(t *testing.T) {
+	c := NewAttachmentCache(10)
+	c.Put("a", []byte("aaaa"))
+	c.Put("b", []byte("bbbb"))
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("expected a to be cached")
+	}
+	// a is now most recently used; adding c evicts b.
+	c.Put("c", []byte("cccc"))
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("expected b to be evicted")
+	}
+	if got, ok := c.Get("a"); !ok || string(got) != "aaaa" {
+		t.Fatalf("expected a to survive, got %q %v", got, ok)
+	}
+	if c.Len() != 2 || c.Size() != 8 {
+		t.Fatalf("unexpected len/size: %d %d", c.Len(), c.Size())
+	}
+
+	c.Put("big", []byte(strings.Repeat("x", 11)))
+	if _, ok := c.Get("big"); ok || c.Len() != 2 {
+		t.Fatalf("oversized entry should not be stored")
+	}
+	c.Put("a", []byte("aaaa"))
+	if c.Size() != 8 {
+		t.Fatalf("re-put should not double count: %d", c.Size())
+	}
+}
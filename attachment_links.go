@@ -0,0 +1,190 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// Uploader stores an attachment and returns a link to download it. The
+// upload package provides S3 and Google Cloud Storage implementations.
+type Uploader interface {
+	// Upload stores a. expires, if not zero, is when the link stops
+	// working and is shown to the recipient.
+	Upload(ctx context.Context, a types.Attachment) (link string, expires time.Time, err error)
+}
+
+// Upload calls f.
+//
+// Parameters:
+//   - ctx: The context.
+//   - a: The attachment.
+//
+// Returns:
+//   - string: The download link.
+//   - time.Time: When the link expires, or zero.
+//   - error: The upload error, if any.
+func (f UploadFunc) Upload(ctx context.Context, a types.Attachment) (string, time.Time, error) {
+	return f(ctx, a)
+}
+
+// AttachmentLinkPolicy replaces attachments of at least MinSize bytes
+// with download links; see WithAttachmentLinks. Inline attachments are
+// always kept.
+type AttachmentLinkPolicy struct {
+	// Uploader stores the replaced attachments. Required.
+	Uploader Uploader
+	// MinSize is the size in bytes from which attachments are linked
+	// (0 = all).
+	MinSize int64
+	// Intro is passed to the templates; defaults to "Download
+	// attachments:".
+	Intro string
+	// Text and HTML render the block added to the text and HTML bodies
+	// from AttachmentLinkData; nil uses DefaultLinkText and
+	// DefaultLinkHTML.
+	Text *texttemplate.Template
+	HTML *htmltemplate.Template
+}
+
+// AttachmentLinkData is passed to the templates of an
+// AttachmentLinkPolicy.
+type AttachmentLinkData struct {
+	Intro string
+	Links []LinkedAttachment
+}
+
+// DefaultLinkText renders the text block of an AttachmentLinkPolicy.
+var DefaultLinkText = texttemplate.Must(texttemplate.New("links").Parse(
+	`{{.Intro}}
+{{range .Links}}
+- {{.Filename}} ({{.HumanSize}}): {{.Link}}{{if not .Expires.IsZero}}
+  available until {{.Expires.UTC.Format "2006-01-02 15:04 MST"}}{{end}}{{end}}
+`))
+
+// DefaultLinkHTML renders the HTML block of an AttachmentLinkPolicy.
+var DefaultLinkHTML = htmltemplate.Must(htmltemplate.New("links").Parse(
+	`<p>{{.Intro}}</p><ul>{{range .Links}}<li><a href="{{.Link}}">{{.Filename}}</a>` +
+		` ({{.HumanSize}}){{if not .Expires.IsZero}}, available until ` +
+		`{{.Expires.UTC.Format "2006-01-02 15:04 MST"}}{{end}}</li>{{end}}</ul>`))
+
+// HumanSize formats Size for people, e.g. "12.5 MB".
+//
+// Returns:
+//   - string: The size.
+func (l LinkedAttachment) HumanSize() string {
+	switch n := l.Size; {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
+
+// Apply uploads the qualifying attachments of msg and replaces them
+// with a block of download links, appended to the text body and
+// inserted before </body> in the HTML body. An empty text body only
+// gets the block if there is no HTML body. Attachment readers are
+// consumed and kept attachments get new ones; msg is not otherwise
+// modified.
+//
+// Parameters:
+//   - ctx: The context for the uploads.
+//   - msg: The message.
+//
+// Returns:
+//   - types.Message: The message with attachments linked.
+//   - []LinkedAttachment: The replaced attachments, in Attach order.
+//   - error: The error if an attachment fails to read or upload, or a
+//     template fails.
+func (p AttachmentLinkPolicy) Apply(
+	ctx context.Context,
+	msg types.Message,
+) (types.Message, []LinkedAttachment, error) {
+	if p.Uploader == nil {
+		return msg, nil, errors.New("attachment links: no uploader")
+	}
+	var keep []types.Attachment
+	var linked []LinkedAttachment
+	for _, a := range msg.Attach {
+		if a.ContentID != "" || a.Reader == nil {
+			keep = append(keep, a)
+			continue
+		}
+		data, err := io.ReadAll(a.Reader)
+		if err != nil {
+			return msg, nil, fmt.Errorf("read attachment %q: %w", a.Filename, err)
+		}
+		a.Reader = bytes.NewReader(data)
+		if int64(len(data)) < p.MinSize {
+			keep = append(keep, a)
+			continue
+		}
+		link, expires, err := p.Uploader.Upload(ctx, a)
+		if err != nil {
+			return msg, nil, fmt.Errorf("upload attachment %q: %w", a.Filename, err)
+		}
+		linked = append(linked, LinkedAttachment{
+			Filename: a.Filename, Size: int64(len(data)), Link: link, Expires: expires,
+		})
+	}
+	msg.Attach = keep
+	if len(linked) == 0 {
+		return msg, nil, nil
+	}
+	data := AttachmentLinkData{Intro: p.Intro, Links: linked}
+	if data.Intro == "" {
+		data.Intro = "Download attachments:"
+	}
+	text, html := p.Text, p.HTML
+	if text == nil {
+		text = DefaultLinkText
+	}
+	if html == nil {
+		html = DefaultLinkHTML
+	}
+	if len(msg.Plain) > 0 || len(msg.HTML) == 0 {
+		var b bytes.Buffer
+		if err := text.Execute(&b, data); err != nil {
+			return msg, nil, fmt.Errorf("attachment links: %w", err)
+		}
+		msg.Plain = appendBlock(msg.Plain, b.Bytes())
+	}
+	if len(msg.HTML) > 0 {
+		var b bytes.Buffer
+		if err := html.Execute(&b, data); err != nil {
+			return msg, nil, fmt.Errorf("attachment links: %w", err)
+		}
+		msg.HTML = insertBeforeBody(msg.HTML, b.Bytes())
+	}
+	return msg, linked, nil
+}
+
+// appendBlock appends block to a text body, separated by a blank line.
+func appendBlock(body, block []byte) []byte {
+	out := bytes.Clone(body)
+	if len(out) > 0 {
+		out = append(out, "\n\n"...)
+	}
+	return append(out, block...)
+}
+
+// insertBeforeBody inserts block before the last </body>, or at the
+// end.
+func insertBeforeBody(body, block []byte) []byte {
+	s := string(body)
+	if i := strings.LastIndex(strings.ToLower(s), "</body>"); i >= 0 {
+		return []byte(s[:i] + string(block) + s[i:])
+	}
+	return []byte(s + string(block))
+}
@@ -0,0 +1,75 @@
+package email
+
+import (
+	"context"
+	"errors"
+	htmltemplate "html/template"
+	"strings"
+	"testing"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestAttachmentLinkPolicy(t *testing.T) {
+	var up Uploader = UploadFunc(func(ctx context.Context, a types.Attachment) (string, time.Time, error) {
+		return "https://files.example.com/" + a.Filename, time.Time{}, nil
+	})
+	p := AttachmentLinkPolicy{
+		Uploader: up,
+		MinSize:  2 << 20,
+		Text: texttemplate.Must(texttemplate.New("t").Parse(
+			"{{range .Links}}Download attachment {{.Filename}}: {{.Link}}\n{{end}}")),
+		HTML: htmltemplate.Must(htmltemplate.New("h").Parse(
+			`{{range .Links}}<a href="{{.Link}}">Download attachment</a>{{end}}`)),
+	}
+	msg := types.Message{
+		Plain: []byte("Hi"),
+		HTML:  []byte("<p>Hi</p>"),
+		Attach: []types.Attachment{
+			{Filename: "video.mp4", Reader: strings.NewReader(strings.Repeat("x", 3<<20))},
+			{Filename: "notes.txt", Reader: strings.NewReader("small")},
+		},
+	}
+	out, linked, err := p.Apply(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(linked) != 1 || linked[0].HumanSize() != "3.0 MB" || len(out.Attach) != 1 {
+		t.Fatalf("linked = %+v, kept %d", linked, len(out.Attach))
+	}
+	if string(out.Plain) != "Hi\n\nDownload attachment video.mp4: https://files.example.com/video.mp4\n" ||
+		string(out.HTML) != `<p>Hi</p><a href="https://files.example.com/video.mp4">Download attachment</a>` ||
+		string(msg.Plain) != "Hi" {
+		t.Fatalf("plain = %q, html = %q", out.Plain, out.HTML)
+	}
+
+	// Only the HTML body gets the block when there is no text body.
+	p.Text, p.HTML, p.MinSize = nil, nil, 0
+	out, _, err = p.Apply(context.Background(), types.Message{
+		HTML:   []byte("<body>x</body>"),
+		Attach: []types.Attachment{{Filename: "a.txt", Reader: strings.NewReader("a")}},
+	})
+	if err != nil || len(out.Plain) != 0 || !strings.HasPrefix(string(out.HTML),
+		"<body>x<p>Download attachments:</p><ul><li>") {
+		t.Fatalf("plain = %q, html = %q, %v", out.Plain, out.HTML, err)
+	}
+
+	p.Uploader = UploadFunc(func(context.Context, types.Attachment) (string, time.Time, error) {
+		return "", time.Time{}, errors.New("quota")
+	})
+	if _, _, err := p.Apply(context.Background(), types.Message{
+		Attach: []types.Attachment{{Filename: "a.txt", Reader: strings.NewReader("a")}},
+	}); err == nil || !strings.Contains(err.Error(), `"a.txt": quota`) {
+		t.Fatalf("expected upload error, got %v", err)
+	}
+}
+
+func TestLinkedAttachmentHumanSize(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 bytes", 1023: "1023 bytes", 1536: "1.5 KB", 5 << 20: "5.0 MB"} {
+		if got := (LinkedAttachment{Size: n}).HumanSize(); got != want {
+			t.Fatalf("HumanSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
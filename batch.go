@@ -0,0 +1,181 @@
+package email
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// BatchResult is the outcome of one message of a SendBatch call.
+type BatchResult struct {
+	Index  int        // position of the message in the batch
+	Result SendResult // filled in as by WithResult
+	Err    error      // nil on success
+}
+
+// BatchSender is implemented by mailers that send a batch more cheaply
+// than one Send per message, like the SMTP adapter, which sends the
+// whole batch over one connection. SendBatch uses it when available.
+type BatchSender interface {
+	// SendBatch sends msgs with opts applied to each of them. It
+	// returns one result per message, in order, and a *BatchError if
+	// any message failed.
+	SendBatch(ctx context.Context, msgs []types.Message, opts ...Option) ([]BatchResult, error)
+}
+
+// BatchError reports the messages of a batch that were not sent. It
+// unwraps to their errors, so errors.Is and errors.As match any of
+// them.
+type BatchError struct {
+	Failed []BatchResult // the failed messages, in batch order
+	Total  int           // the number of messages in the batch
+}
+
+// Error implements error.
+//
+// Returns:
+//   - string: The error message.
+func (e *BatchError) Error() string {
+	first := e.Failed[0]
+	if len(e.Failed) == 1 {
+		return fmt.Sprintf("email: 1 of %d messages failed: message %d: %v",
+			e.Total, first.Index, first.Err)
+	}
+	return fmt.Sprintf("email: %d of %d messages failed, first: message %d: %v",
+		len(e.Failed), e.Total, first.Index, first.Err)
+}
+
+// Unwrap returns the errors of the failed messages.
+//
+// Returns:
+//   - []error: The errors.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, r := range e.Failed {
+		errs[i] = r.Err
+	}
+	return errs
+}
+
+// NewBatchError returns a *BatchError for the failed results, or nil if
+// every message was sent. It is meant for BatchSender implementations.
+//
+// Parameters:
+//   - results: The results of a batch.
+//
+// Returns:
+//   - error: The *BatchError, or nil.
+func NewBatchError(results []BatchResult) error {
+	var failed []BatchResult
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &BatchError{Failed: failed, Total: len(results)}
+}
+
+// SendBatch sends msgs through m with opts applied to each of them and
+// reports every message on its own. A mailer implementing BatchSender
+// sends the batch its own way; any other mailer gets one Send per
+// message. Either way the rate limiter (WithRateLimit) is acquired once
+// per message, the hooks' OnBatchStart context is shared by all of
+// them, and a failed message does not stop the batch. Messages not yet
+// sent when ctx is done fail with the context error.
+//
+// A WithResult or WithMessageIDOut option in opts is superseded by the
+// per-message results.
+//
+// Parameters:
+//   - ctx: The context.
+//   - m: The mailer.
+//   - msgs: The messages.
+//   - opts: The options for every message.
+//
+// Returns:
+//   - []BatchResult: One result per message, in order.
+//   - error: A *BatchError if any message failed.
+func SendBatch(
+	ctx context.Context,
+	m Mailer,
+	msgs []types.Message,
+	opts ...Option,
+) ([]BatchResult, error) {
+	if bs, ok := m.(BatchSender); ok {
+		return bs.SendBatch(ctx, msgs, opts...)
+	}
+	var cfg SendConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	results := make([]BatchResult, len(msgs))
+	ctx = StartBatch(ctx, cfg.Hooks, len(msgs))
+	for i, msg := range msgs {
+		results[i].Index = i
+		if err := ctx.Err(); err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Err = m.Send(ctx, msg, BatchOptions(opts, &results[i])...)
+	}
+	EndBatch(ctx, cfg.Hooks, results)
+	return results, NewBatchError(results)
+}
+
+// BatchOptions returns opts followed by the options that route the
+// outcome of one message of a batch into r. It is meant for
+// BatchSender implementations.
+//
+// Parameters:
+//   - opts: The options of the batch.
+//   - r: The result of the message.
+//
+// Returns:
+//   - []Option: The options for the message.
+func BatchOptions(opts []Option, r *BatchResult) []Option {
+	out := make([]Option, 0, len(opts)+2)
+	out = append(out, opts...)
+	return append(out, WithResult(&r.Result), WithMessageIDOut(nil))
+}
+
+// StartBatch calls the OnBatchStart hook, if any, and returns the
+// context for the messages of the batch. It is meant for BatchSender
+// implementations.
+//
+// Parameters:
+//   - ctx: The context of the batch.
+//   - h: The hooks, may be nil.
+//   - n: The number of messages.
+//
+// Returns:
+//   - context.Context: The context for the messages.
+func StartBatch(ctx context.Context, h *types.Hooks, n int) context.Context {
+	if h != nil && h.OnBatchStart != nil {
+		return h.OnBatchStart(ctx, n)
+	}
+	return ctx
+}
+
+// EndBatch calls the OnBatchDone hook, if any. It is meant for
+// BatchSender implementations.
+//
+// Parameters:
+//   - ctx: The context returned by StartBatch.
+//   - h: The hooks, may be nil.
+//   - results: The results of the batch.
+func EndBatch(ctx context.Context, h *types.Hooks, results []BatchResult) {
+	if h == nil || h.OnBatchDone == nil {
+		return
+	}
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	h.OnBatchDone(ctx, len(results), failed)
+}
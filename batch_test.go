@@ -0,0 +1,83 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// ctxKey marks the context passed on by OnBatchStart.
+type ctxKey struct{}
+
+// batchMailer fails the messages with Subject "reject" and reports
+// each message's Subject as its Message-ID.
+type batchMailer struct{ sawCtx int }
+
+func (m *batchMailer) Send(ctx context.Context, msg types.Message, opts ...Option) error {
+	var cfg SendConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if ctx.Value(ctxKey{}) != nil {
+		m.sawCtx++
+	}
+	if cfg.Result != nil {
+		cfg.Result.MessageID = msg.Subject
+	}
+	if msg.Subject == "reject" {
+		return errRejected
+	}
+	return nil
+}
+
+var errRejected = errors.New("550 rejected")
+
+func TestSendBatchFallback(t *testing.T) {
+	next := &batchMailer{}
+	var started, done, failed int
+	hooks := &types.Hooks{
+		OnBatchStart: func(ctx context.Context, n int) context.Context {
+			started = n
+			return context.WithValue(ctx, ctxKey{}, true)
+		},
+		OnBatchDone: func(ctx context.Context, n, f int) {
+			done, failed = n, f
+		},
+	}
+	msgs := []types.Message{{Subject: "a"}, {Subject: "reject"}, {Subject: "c"}}
+	var ignored SendResult
+	results, err := SendBatch(context.Background(), next, msgs,
+		WithHooks(hooks), WithResult(&ignored))
+
+	var be *BatchError
+	if !errors.As(err, &be) || !errors.Is(err, errRejected) || be.Total != 3 ||
+		len(be.Failed) != 1 || be.Failed[0].Index != 1 {
+		t.Fatalf("err = %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("results = %+v", results)
+	}
+	for i, r := range results {
+		if r.Index != i || r.Result.MessageID != msgs[i].Subject || (r.Err != nil) != (i == 1) {
+			t.Fatalf("result %d = %+v", i, r)
+		}
+	}
+	if started != 3 || done != 3 || failed != 1 || next.sawCtx != 3 {
+		t.Fatalf("hooks: start %d done %d failed %d ctx %d", started, done, failed, next.sawCtx)
+	}
+	if ignored.MessageID != "" {
+		t.Fatalf("WithResult in opts was filled: %+v", ignored)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = SendBatch(ctx, &batchMailer{}, msgs)
+	if !errors.Is(err, context.Canceled) || len(results) != 3 || results[2].Err == nil {
+		t.Fatalf("canceled: %v %+v", err, results)
+	}
+	if results, err := SendBatch(context.Background(), &batchMailer{}, nil); err != nil || len(results) != 0 {
+		t.Fatalf("empty: %v %+v", err, results)
+	}
+}
@@ -0,0 +1,171 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// TXTResolver is the subset of *net.Resolver used for DNS TXT checks.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// BIMIRecord is a parsed BIMI DNS record plus the DMARC policy of the
+// domain, which must be enforcing for receivers to show the logo.
+type BIMIRecord struct {
+	Name        string // queried name, e.g. "default._bimi.example.com"
+	Logo        string // l= tag, https URL of the SVG logo
+	Authority   string // a= tag, https URL of the VMC (optional)
+	DMARCPolicy string // p= of the domain's DMARC record
+}
+
+// WithBIMISelector adds a "BIMI-Selector: v=BIMI1; s=<selector>" header
+// so receivers look up <selector>._bimi.<domain> instead of "default".
+//
+// Parameters:
+//   - selector: The BIMI selector.
+//
+// Returns:
+//   - Option: The option.
+func WithBIMISelector(selector string) Option {
+	return func(c *SendConfig) { c.BIMISelector = selector }
+}
+
+// BIMISelectorHeader returns the BIMI-Selector header value.
+//
+// Parameters:
+//   - selector: The BIMI selector.
+//
+// Returns:
+//   - string: The header value.
+func BIMISelectorHeader(selector string) string {
+	return "v=BIMI1; s=" + selector
+}
+
+// CheckBIMIAlignment verifies that the From domain, DKIM d= and BIMI
+// selector line up: DKIM must be enabled and aligned with From, and a
+// selector must be configured (explicitly or "default").
+//
+// Parameters:
+//   - msg: The message to check.
+//   - opts: The send options that will be used.
+//
+// Returns:
+//   - error: A descriptive error if BIMI would not apply.
+func CheckBIMIAlignment(msg types.Message, opts ...Option) error {
+	var cfg SendConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	from := domainOf(msg.From.Mail)
+	if from == "" {
+		return errors.New("bimi: missing From domain")
+	}
+	if cfg.DKIM == nil || cfg.DKIM.Domain == "" {
+		return errors.New("bimi: DKIM signing is required")
+	}
+	if !internal.DomainsAligned(from, cfg.DKIM.Domain) {
+		return fmt.Errorf("bimi: From domain %q not aligned with DKIM d=%s",
+			from, cfg.DKIM.Domain)
+	}
+	if cfg.BIMISelector != "" && !validDNSLabel(cfg.BIMISelector) {
+		return fmt.Errorf("bimi: invalid selector %q", cfg.BIMISelector)
+	}
+	return nil
+}
+
+// LookupBIMI fetches and validates the BIMI record for selector at
+// domain ("default" if selector is empty) and the domain's DMARC policy.
+// Pass nil to use net.DefaultResolver.
+//
+// Parameters:
+//   - ctx: The context.
+//   - r: The TXT resolver.
+//   - selector: The BIMI selector.
+//   - domain: The From domain.
+//
+// Returns:
+//   - BIMIRecord: The parsed record.
+//   - error: An error if the record is missing or would not be honored.
+func LookupBIMI(
+	ctx context.Context,
+	r TXTResolver,
+	selector string,
+	domain string,
+) (BIMIRecord, error) {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	if selector == "" {
+		selector = "default"
+	}
+	rec := BIMIRecord{Name: selector + "._bimi." + domain}
+	tags, err := lookupTagRecord(ctx, r, rec.Name, "BIMI1")
+	if err != nil {
+		return rec, fmt.Errorf("bimi: %w", err)
+	}
+	rec.Logo, rec.Authority = tags["l"], tags["a"]
+	if !strings.HasPrefix(rec.Logo, "https://") {
+		return rec, fmt.Errorf("bimi: l= must be an https URL, got %q",
+			rec.Logo)
+	}
+	if rec.Authority != "" && !strings.HasPrefix(rec.Authority, "https://") {
+		return rec, fmt.Errorf("bimi: a= must be an https URL, got %q",
+			rec.Authority)
+	}
+
+	dmarc, err := lookupTagRecord(ctx, r, "_dmarc."+domain, "DMARC1")
+	if err != nil {
+		return rec, fmt.Errorf("bimi: dmarc: %w", err)
+	}
+	rec.DMARCPolicy = strings.ToLower(dmarc["p"])
+	if rec.DMARCPolicy != "quarantine" && rec.DMARCPolicy != "reject" {
+		return rec, fmt.Errorf("bimi: DMARC policy %q is not enforcing",
+			rec.DMARCPolicy)
+	}
+	if pct, ok := dmarc["pct"]; ok && pct != "100" {
+		return rec, fmt.Errorf("bimi: DMARC pct=%s must be 100", pct)
+	}
+	return rec, nil
+}
+
+// lookupTagRecord finds the TXT record at name whose v= tag equals
+// version and returns its tags.
+func lookupTagRecord(
+	ctx context.Context,
+	r TXTResolver,
+	name string,
+	version string,
+) (map[string]string, error) {
+	txts, err := r.LookupTXT(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("lookup %s: %w", name, err)
+	}
+	for _, txt := range txts {
+		tags := internal.ParseTags(txt)
+		if strings.EqualFold(tags["v"], version) {
+			return tags, nil
+		}
+	}
+	return nil, fmt.Errorf("no v=%s record at %s", version, name)
+}
+
+// validDNSLabel reports whether s is a non-empty LDH label.
+func validDNSLabel(s string) bool {
+	if s == "" || len(s) > 63 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+			c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
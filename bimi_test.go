@@ -0,0 +1,60 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+type fakeTXT map[string][]string
+
+func (f fakeTXT) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if v, ok := f[name]; ok {
+		return v, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func TestCheckBIMIAlignment(t *testing.T) {
+	msg := types.Message{From: types.Address{Mail: "brand@example.com"}}
+	if err := CheckBIMIAlignment(msg); err == nil {
+		t.Fatalf("expected error without DKIM")
+	}
+	dkim := WithDKIM(types.DKIMConfig{Domain: "mail.example.com"})
+	if err := CheckBIMIAlignment(msg, dkim, WithBIMISelector("brand")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	other := WithDKIM(types.DKIMConfig{Domain: "esp.net"})
+	if err := CheckBIMIAlignment(msg, other); err == nil {
+		t.Fatalf("expected misalignment error")
+	}
+	if err := CheckBIMIAlignment(msg, dkim, WithBIMISelector("bad sel")); err == nil {
+		t.Fatalf("expected invalid selector error")
+	}
+}
+
+func TestLookupBIMI(t *testing.T) {
+	r := fakeTXT{
+		"brand._bimi.example.com": {"v=BIMI1; l=https://cdn.example.com/logo.svg; a=https://cdn.example.com/vmc.pem"},
+		"_dmarc.example.com":      {"v=DMARC1; p=reject; rua=mailto:d@example.com"},
+		"default._bimi.weak.com":  {"v=BIMI1; l=https://weak.com/logo.svg"},
+		"_dmarc.weak.com":         {"v=DMARC1; p=none"},
+	}
+	rec, err := LookupBIMI(context.Background(), r, "brand", "example.com")
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if rec.Logo != "https://cdn.example.com/logo.svg" || rec.DMARCPolicy != "reject" {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+	_, err = LookupBIMI(context.Background(), r, "", "weak.com")
+	if err == nil || !strings.Contains(err.Error(), "not enforcing") {
+		t.Fatalf("expected DMARC enforcement error, got %v", err)
+	}
+	if _, err := LookupBIMI(context.Background(), r, "", "missing.com"); err == nil {
+		t.Fatalf("expected error for missing record")
+	}
+}
@@ -0,0 +1,149 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"slices"
+
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// BuiltMessage is a message in its wire form: RFC 5322 with CRLF line
+// endings, signed if WithDKIM was given. It is immutable and safe for
+// concurrent use, e.g. to hand the same bytes to several transports,
+// store them for audit or hash them.
+type BuiltMessage struct {
+	raw             []byte
+	messageID       string
+	attachmentSizes []int64
+	warnings        []types.BuildWarning
+}
+
+// Build builds msg as the adapters do before sending, without sending
+// it. The build options apply (WithDKIM, WithCharset, WithTransforms,
+// limits, ...); delivery options are ignored. Text parts use 7-bit
+// safe encodings, since no server has advertised 8BITMIME. msg is not
+// modified; see Mailer.
+//
+// Parameters:
+//   - ctx: The context.
+//   - msg: The message.
+//   - opts: The build options.
+//
+// Returns:
+//   - *BuiltMessage: The built message.
+//   - error: The error if the message is invalid or fails to build.
+func Build(ctx context.Context, msg types.Message, opts ...Option) (*BuiltMessage, error) {
+	var cfg SendConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	built, err := internal.Build(ctx, msg, cfg.buildOptions())
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MessageIDOut != nil {
+		*cfg.MessageIDOut = built.MessageID
+	}
+	return &BuiltMessage{
+		raw:             built.Raw,
+		messageID:       built.MessageID,
+		attachmentSizes: built.AttachmentSizes,
+		warnings:        built.Warnings,
+	}, nil
+}
+
+func init() {
+	internal.SendBuildOptions = func(cfg any) internal.BuildOptions {
+		return cfg.(*SendConfig).buildOptions()
+	}
+}
+
+// buildOptions maps the send options to the options of the MIME
+// builder. It is the one mapping used by Build and, through
+// internal.SendBuildOptions, the adapters, which then set what depends
+// on the transport, such as 8-bit support.
+func (c *SendConfig) buildOptions() internal.BuildOptions {
+	opts := internal.BuildOptions{
+		ListUnsub:         c.ListUnsub,
+		DKIM:              c.DKIM,
+		Hooks:             c.Hooks,
+		MaxMessageSize:    c.MaxMessageSize,
+		MaxAttachmentSize: c.MaxAttachmentSize,
+		BIMISelector:      c.BIMISelector,
+
+		MessageIDDomain:    c.MessageIDDomain,
+		MessageIDGenerator: c.MessageIDGenerator,
+
+		Charset:        c.Charset,
+		CharsetEncoder: c.CharsetEncoder,
+		ReadReceiptTo:  c.ReadReceiptTo,
+
+		AttachmentPolicy:  c.AttachmentPolicy,
+		AttachmentScanner: c.AttachmentScanner,
+		Transforms:        c.Transforms,
+		Rand:              c.Rand,
+	}
+	if p := c.HTMLSanitizer; p != nil {
+		opts.SanitizeHTML = func(html []byte) []byte {
+			return []byte(p.Sanitize(string(html)))
+		}
+	}
+	// Avoid storing a nil *AttachmentCache in the interface.
+	if c.AttachmentCache != nil {
+		opts.AttachmentCache = c.AttachmentCache
+	}
+	return opts
+}
+
+// MessageID returns the Message-ID of the message.
+//
+// Returns:
+//   - string: The Message-ID, with angle brackets.
+func (b *BuiltMessage) MessageID() string { return b.messageID }
+
+// Size returns the size of the message in bytes.
+//
+// Returns:
+//   - int: The size.
+func (b *BuiltMessage) Size() int { return len(b.raw) }
+
+// Bytes returns a copy of the raw message.
+//
+// Returns:
+//   - []byte: The raw message.
+func (b *BuiltMessage) Bytes() []byte { return bytes.Clone(b.raw) }
+
+// Reader returns a reader over the raw message, without copying it.
+//
+// Returns:
+//   - io.Reader: The reader.
+func (b *BuiltMessage) Reader() io.Reader { return bytes.NewReader(b.raw) }
+
+// WriteTo writes the raw message to w.
+//
+// Parameters:
+//   - w: The writer.
+//
+// Returns:
+//   - int64: The number of bytes written.
+//   - error: The error from w.
+func (b *BuiltMessage) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(b.raw)
+	return int64(n), err
+}
+
+// AttachmentSizes returns the encoded size of each attachment in
+// Message.Attach order.
+//
+// Returns:
+//   - []int64: The sizes.
+func (b *BuiltMessage) AttachmentSizes() []int64 { return slices.Clone(b.attachmentSizes) }
+
+// Warnings returns the problems the build worked around.
+//
+// Returns:
+//   - []types.BuildWarning: The warnings, nil if there were none.
+func (b *BuiltMessage) Warnings() []types.BuildWarning { return slices.Clone(b.warnings) }
@@ -0,0 +1,91 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestBuildConcurrentSameMessage(t *testing.T) {
+	msg := types.Message{
+		From:    types.Address{Mail: "app@example.com"},
+		To:      make([]types.Address, 1, 4),
+		Plain:   []byte("hello"),
+		Headers: make(types.Headers, 0, 4),
+		Attach: []types.Attachment{{
+			Filename: "report.txt", Reader: bytes.NewReader([]byte("quarterly numbers")),
+		}},
+	}
+	msg.To[0] = types.Address{Mail: "ada@example.org"}
+	msg.Headers.Add("X-Campaign", "spring")
+	hooks := &types.Hooks{
+		OnBuildStart: func(ctx context.Context, m *types.Message) context.Context {
+			m.Headers.Set("X-Campaign", "changed by hook")
+			m.To = append(m.To, types.Address{Mail: "hook@example.org"})
+			m.Plain = []byte("Jello")
+			return ctx
+		},
+	}
+
+	var wg sync.WaitGroup
+	built := make([]*BuiltMessage, 8)
+	errs := make([]error, len(built))
+	for i := range built {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			built[i], errs[i] = Build(context.Background(), msg, WithHooks(hooks))
+		}()
+	}
+	wg.Wait()
+
+	attachment := base64.StdEncoding.EncodeToString([]byte("quarterly numbers"))
+	for i, b := range built {
+		if errs[i] != nil {
+			t.Fatalf("build %d: %v", i, errs[i])
+		}
+		if raw := string(b.Bytes()); !strings.Contains(raw, attachment) {
+			t.Fatalf("build %d lacks the attachment:\n%s", i, raw)
+		}
+	}
+	if msg.Headers.Get("X-Campaign") != "spring" || len(msg.To) != 1 ||
+		msg.To[:2][1].Mail != "" || string(msg.Plain) != "hello" {
+		t.Fatalf("message changed: %+v", msg)
+	}
+
+	b := built[0]
+	raw := b.Bytes()
+	raw[0] = 'x'
+	var buf bytes.Buffer
+	if n, err := b.WriteTo(&buf); err != nil || int(n) != b.Size() || buf.Bytes()[0] == 'x' {
+		t.Fatalf("WriteTo: %d %v", n, err)
+	}
+	if b.MessageID() == "" || !strings.Contains(buf.String(), "Message-ID: "+b.MessageID()) {
+		t.Fatalf("Message-ID %q", b.MessageID())
+	}
+	if sizes := b.AttachmentSizes(); len(sizes) != 1 || sizes[0] == 0 {
+		t.Fatalf("attachment sizes: %v", sizes)
+	}
+}
+
+func TestBuiltMessageWarnings(t *testing.T) {
+	msg := types.Message{
+		From:    types.Address{Mail: "app@example.com"},
+		To:      []types.Address{{Mail: "ada@example.org"}},
+		Plain:   []byte("hello"),
+		Headers: types.Headers{{Name: "From", Value: "other@example.com"}},
+	}
+	b, err := Build(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := b.Warnings()
+	if len(w) != 1 || w[0].Code != types.WarnHeaderIgnored || w[0].Field != "From" {
+		t.Fatalf("warnings: %v", w)
+	}
+}
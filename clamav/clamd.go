@@ -0,0 +1,181 @@
+package clamav
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// Config configures a clamd client.
+type Config struct {
+	// Network is "tcp" (default) or "unix".
+	Network string
+	// Addr is host:port, defaults to "localhost:3310", or the socket
+	// path, e.g. "/var/run/clamav/clamd.ctl".
+	Addr    string
+	Timeout time.Duration // per scan, defaults to 60s
+	// ChunkSize is the INSTREAM chunk size, defaults to 64 KiB. Keep it
+	// below clamd's StreamMaxLength.
+	ChunkSize int
+	// Dialer, if set, opens the connection.
+	Dialer types.ContextDialer
+}
+
+// Clamd scans content with clamd. It implements
+// types.AttachmentScanner and is safe for concurrent use.
+type Clamd struct {
+	cfg Config
+}
+
+// New creates a clamd client.
+//
+// Parameters:
+//   - cfg: The clamd config.
+//
+// Returns:
+//   - *Clamd: The client.
+func New(cfg Config) *Clamd {
+	if cfg.Network == "" {
+		cfg.Network = "tcp"
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = "localhost:3310"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 60 * time.Second
+	}
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = 64 << 10
+	}
+	if cfg.Dialer == nil {
+		cfg.Dialer = &net.Dialer{}
+	}
+	return &Clamd{cfg: cfg}
+}
+
+// ScanAttachment implements types.AttachmentScanner.
+//
+// Parameters:
+//   - ctx: The context.
+//   - r: The content.
+//
+// Returns:
+//   - string: The signature name clamd found, "" if clean.
+//   - error: The error if clamd cannot be reached or fails.
+func (c *Clamd) ScanAttachment(ctx context.Context, r io.Reader) (string, error) {
+	var threat string
+	err := c.do(ctx, func(conn net.Conn, br *bufio.Reader) error {
+		if _, err := io.WriteString(conn, "zINSTREAM\x00"); err != nil {
+			return err
+		}
+		buf := make([]byte, 4+c.cfg.ChunkSize)
+		for {
+			n, rerr := io.ReadFull(r, buf[4:])
+			if n > 0 {
+				binary.BigEndian.PutUint32(buf, uint32(n))
+				if _, err := conn.Write(buf[:4+n]); err != nil {
+					// clamd closes the stream once it exceeds
+					// StreamMaxLength; its reply says so.
+					return replyError(br, err)
+				}
+			}
+			if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+				break
+			}
+			if rerr != nil {
+				return fmt.Errorf("read content: %w", rerr)
+			}
+		}
+		if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+			return replyError(br, err)
+		}
+		reply, err := readReply(br)
+		if err != nil {
+			return err
+		}
+		// "stream: OK", "stream: Eicar-Signature FOUND" or
+		// "INSTREAM size limit exceeded. ERROR".
+		switch {
+		case strings.HasSuffix(reply, " FOUND"):
+			threat = strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+			return nil
+		case strings.HasSuffix(reply, " OK"):
+			return nil
+		default:
+			return fmt.Errorf("clamd: %s", reply)
+		}
+	})
+	return threat, err
+}
+
+// Ping checks that clamd is answering.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - error: The error if clamd does not reply PONG.
+func (c *Clamd) Ping(ctx context.Context) error {
+	return c.do(ctx, func(conn net.Conn, br *bufio.Reader) error {
+		if _, err := io.WriteString(conn, "zPING\x00"); err != nil {
+			return err
+		}
+		reply, err := readReply(br)
+		if err != nil {
+			return err
+		}
+		if reply != "PONG" {
+			return fmt.Errorf("clamd: unexpected reply %q", reply)
+		}
+		return nil
+	})
+}
+
+// do runs fn on a new connection to clamd.
+func (c *Clamd) do(ctx context.Context, fn func(net.Conn, *bufio.Reader) error) error {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+	conn, err := c.cfg.Dialer.DialContext(ctx, c.cfg.Network, c.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("clamav: %w", err)
+	}
+	defer conn.Close()
+	if d, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(d)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+	if err := fn(conn, bufio.NewReader(conn)); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("clamav: %w", err)
+	}
+	return nil
+}
+
+// readReply reads a NUL-terminated clamd reply.
+func readReply(br *bufio.Reader) (string, error) {
+	reply, err := br.ReadString(0)
+	if err != nil && !(errors.Is(err, io.EOF) && reply != "") {
+		return "", fmt.Errorf("read reply: %w", err)
+	}
+	return strings.TrimRight(reply, "\x00\r\n"), nil
+}
+
+// replyError returns clamd's reply explaining why it stopped reading,
+// or err if there is none.
+func replyError(br *bufio.Reader, err error) error {
+	if reply, rerr := readReply(br); rerr == nil && reply != "" {
+		return fmt.Errorf("clamd: %s", reply)
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package clamav
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeClamd answers INSTREAM with "FOUND" when the stream contains
+// "EICAR" and PING with PONG.
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				cmd, err := r.ReadString(0)
+				if err != nil {
+					return
+				}
+				if cmd == "zPING\x00" {
+					_, _ = io.WriteString(conn, "PONG\x00")
+					return
+				}
+				var data []byte
+				for {
+					var n uint32
+					if binary.Read(r, binary.BigEndian, &n) != nil {
+						return
+					}
+					if n == 0 {
+						break
+					}
+					chunk := make([]byte, n)
+					if _, err := io.ReadFull(r, chunk); err != nil {
+						return
+					}
+					data = append(data, chunk...)
+				}
+				if bytes.Contains(data, []byte("EICAR")) {
+					_, _ = io.WriteString(conn, "stream: Eicar-Signature FOUND\x00")
+					return
+				}
+				_, _ = io.WriteString(conn, "stream: OK\x00")
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestClamd(t *testing.T) {
+	c := New(Config{Addr: fakeClamd(t), ChunkSize: 4})
+	ctx := context.Background()
+	if err := c.Ping(ctx); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	threat, err := c.ScanAttachment(ctx, strings.NewReader("hello, world"))
+	if err != nil || threat != "" {
+		t.Fatalf("clean: %q, %v", threat, err)
+	}
+	threat, err = c.ScanAttachment(ctx, strings.NewReader("X5O!P%@AP EICAR-STANDARD-ANTIVIRUS-TEST-FILE"))
+	if err != nil || threat != "Eicar-Signature" {
+		t.Fatalf("infected: %q, %v", threat, err)
+	}
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	if _, err := New(Config{Addr: addr}).ScanAttachment(ctx, strings.NewReader("x")); err == nil ||
+		!strings.HasPrefix(err.Error(), "clamav: ") {
+		t.Fatalf("expected a dial error, got %v", err)
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := c.ScanAttachment(cctx, strings.NewReader("x")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,4 @@
+// Package clamav scans attachments with ClamAV's clamd, for
+// email.WithAttachmentScanner. Content is streamed to clamd with the
+// INSTREAM command over TCP or a Unix socket.
+package clamav
@@ -0,0 +1,25 @@
+package email
+
+import (
+	"time"
+
+	"github.com/aatuh/email/v2/internal"
+)
+
+// SetClock replaces the clock used for the Date header of messages
+// without Message.Date, generated Message-IDs, Resent-Date and DKIM
+// signature timestamps, so built messages are reproducible in tests.
+// It is also the default clock of WarmupLimiter, the smtp
+// RetryScheduler and the digest Digester, and dates the smtpd Received
+// header. nil restores time.Now. The clock is process-wide; tests that
+// set it must not run in parallel with others that build messages.
+//
+// Parameters:
+//   - now: The clock.
+//
+// Returns:
+//   - func(): A function that restores the previous clock.
+func SetClock(now func() time.Time) func() {
+	old := internal.SetClock(now)
+	return func() { internal.SetClock(old) }
+}
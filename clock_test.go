@@ -0,0 +1,28 @@
+package email
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2/internal"
+)
+
+func TestSetClock(t *testing.T) {
+	fixed := time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+	restore := SetClock(func() time.Time { return fixed })
+	if !internal.Now().Equal(fixed) {
+		t.Fatalf("clock not set: %v", internal.Now())
+	}
+	inner := SetClock(nil)
+	if internal.Now().Equal(fixed) {
+		t.Fatal("nil should restore time.Now")
+	}
+	inner()
+	if !internal.Now().Equal(fixed) {
+		t.Fatal("restore should bring back the previous clock")
+	}
+	restore()
+	if internal.Now().Equal(fixed) {
+		t.Fatal("clock not restored")
+	}
+}
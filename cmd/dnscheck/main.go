@@ -0,0 +1,88 @@
+// Command dnscheck verifies the SPF, DKIM and DMARC setup of a sending
+// domain and exits non-zero if mail from it would fail authentication.
+//
+// Usage:
+//
+//	dnscheck -from example.com -ip 192.0.2.7 -selector s1 -key dkim.pem
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/aatuh/email/v2/dkim"
+	"github.com/aatuh/email/v2/dnscheck"
+)
+
+func main() {
+	var cfg dnscheck.Config
+	var ip, key string
+	flag.StringVar(&cfg.FromDomain, "from", "", "From header domain (required)")
+	flag.StringVar(&cfg.EnvelopeDomain, "envelope", "", "MAIL FROM domain (default: -from)")
+	flag.StringVar(&ip, "ip", "", "sending IP address")
+	flag.StringVar(&cfg.SendingHost, "host", "", "sending host, if -ip is not set")
+	flag.StringVar(&cfg.DKIMSelector, "selector", "", "DKIM selector")
+	flag.StringVar(&cfg.DKIMDomain, "dkim-domain", "", "DKIM d= domain (default: -from)")
+	flag.StringVar(&key, "key", "", "DKIM private key PEM file to match")
+	timeout := flag.Duration("timeout", 10*time.Second, "overall DNS timeout")
+	flag.Parse()
+
+	if cfg.FromDomain == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if ip != "" {
+		if cfg.SendingIP = net.ParseIP(ip); cfg.SendingIP == nil {
+			fatal(fmt.Errorf("invalid -ip %q", ip))
+		}
+	}
+	if key != "" {
+		signer, err := dkim.LoadPrivateKeyFile(key)
+		if err != nil {
+			fatal(err)
+		}
+		cfg.DKIMKey = signer.Public()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	rep, err := dnscheck.Check(ctx, nil, cfg)
+	if rep == nil {
+		fatal(err)
+	}
+	report("spf", rep.SPF, rep.SPFResult)
+	if cfg.DKIMSelector != "" {
+		report("dkim", rep.DKIM, "")
+	}
+	report("dmarc", rep.DMARC, rep.DMARCPolicy)
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// report writes the outcome of one check with its record and error.
+func report(name string, r dnscheck.Result, detail string) {
+	status := "ok"
+	if r.Err != nil {
+		status = "FAIL"
+	}
+	if detail != "" {
+		status += " (" + detail + ")"
+	}
+	fmt.Printf("%-5s %s %s\n", name, status, r.Name)
+	if r.Record != "" {
+		fmt.Printf("      %s\n", r.Record)
+	}
+	if r.Err != nil {
+		fmt.Printf("      %v\n", r.Err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "dnscheck:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,233 @@
+// Command email sends and inspects mail from the command line, for
+// runbooks and smoke tests.
+//
+// Usage:
+//
+//	email send -host smtp.example.com -from a@example.com -to b@example.com -subject Hi -text Hello
+//	email render -template ./tmpl -name welcome -data data.json -from ... -to ... -o out.eml
+//	email verify message.eml
+//	email lint -json message.json
+//	email lint -eml message.eml
+//
+// Messages are given with flags, a -json file or a template directory;
+// run "email <command> -h" for the flags of each command.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/dkim"
+	"github.com/aatuh/email/v2/inbound"
+	"github.com/aatuh/email/v2/smtp"
+	"github.com/aatuh/email/v2/types"
+)
+
+const usage = `usage: email <command> [flags]
+
+commands:
+  send    send a message through an SMTP server
+  render  write a message as .eml without sending it
+  verify  verify the DKIM signature of an .eml file
+  lint    report deliverability problems of a message
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmds := map[string]func([]string) error{
+		"send":   send,
+		"render": render,
+		"verify": verify,
+		"lint":   lint,
+	}
+	cmd, ok := cmds[os.Args[1]]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err := cmd(os.Args[2:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fatal(err)
+	}
+}
+
+// send delivers a message through SMTP and prints its Message-ID and
+// the server reply.
+func send(args []string) error {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	var mf messageFlags
+	mf.register(fs)
+	var cfg smtp.SMTPConfig
+	fs.StringVar(&cfg.Host, "host", "", "SMTP host (required)")
+	fs.IntVar(&cfg.Port, "port", 587, "SMTP port")
+	fs.StringVar(&cfg.Username, "user", "", "AUTH user")
+	fs.StringVar(&cfg.Password, "pass", "", "AUTH password (default: $EMAIL_SMTP_PASSWORD)")
+	fs.StringVar(&cfg.LocalName, "helo", "", "EHLO name")
+	fs.BoolVar(&cfg.StartTLS, "starttls", true, "use STARTTLS when the server offers it")
+	fs.BoolVar(&cfg.ImplicitTLS, "tls", false, "use implicit TLS (port 465)")
+	fs.BoolVar(&cfg.SkipVerify, "insecure", false, "skip TLS certificate verification")
+	fs.BoolVar(&cfg.LMTP, "lmtp", false, "speak LMTP")
+	fs.DurationVar(&cfg.Timeout, "timeout", 30*time.Second, "timeout for the send")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if cfg.Host == "" {
+		return errors.New("send: -host is required")
+	}
+	if cfg.Password == "" {
+		cfg.Password = os.Getenv("EMAIL_SMTP_PASSWORD")
+	}
+	if cfg.ImplicitTLS {
+		cfg.StartTLS = false
+	}
+	msg, opts, err := prepare(&mf)
+	if err != nil {
+		return err
+	}
+	var res email.SendResult
+	opts = append(opts, email.WithResult(&res))
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	m := smtp.NewSMTP(cfg)
+	defer m.Close(context.Background())
+	err = m.Send(ctx, msg, opts...)
+	for _, r := range res.Recipients {
+		status := "ok"
+		if r.Err != nil {
+			status = r.Err.Error()
+		}
+		fmt.Printf("%s: %s\n", r.Recipient, status)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(res.MessageID, res.Response)
+	return nil
+}
+
+// render writes the built, optionally signed, message.
+func render(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	var mf messageFlags
+	mf.register(fs)
+	out := fs.String("o", "", "output file (default: stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	msg, opts, err := prepare(&mf)
+	if err != nil {
+		return err
+	}
+	p, err := smtp.NewSMTP(smtp.SMTPConfig{}).PrepareMessage(
+		context.Background(), msg, opts...)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(p.Bytes())
+		return err
+	}
+	return os.WriteFile(*out, p.Bytes(), 0o644)
+}
+
+// verify checks the DKIM signature of a message file, or stdin.
+func verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 10*time.Second, "DNS timeout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	raw, err := readInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	tags, err := dkim.Verify(ctx, nil, raw)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("dkim pass d=%s s=%s a=%s\n", tags["d"], tags["s"], tags["a"])
+	return nil
+}
+
+// lint prints the findings for a message and fails on errors.
+func lint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	var mf messageFlags
+	mf.register(fs)
+	eml := fs.String("eml", "", "lint this .eml file instead of a built message")
+	unsub := fs.String("list-unsubscribe", "", "List-Unsubscribe value used when sending")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var msg types.Message
+	var opts []email.Option
+	if *eml != "" {
+		raw, err := readInput(*eml)
+		if err != nil {
+			return err
+		}
+		parsed, err := inbound.Parse(bytes.NewReader(raw))
+		if err != nil {
+			return err
+		}
+		msg = *parsed
+		if opts, err = mf.options(msg); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if msg, opts, err = prepare(&mf); err != nil {
+			return err
+		}
+	}
+	if *unsub != "" {
+		opts = append(opts, email.WithListUnsubscribe(*unsub))
+	}
+	failed := false
+	for _, f := range email.Lint(msg, opts...) {
+		fmt.Println(f)
+		failed = failed || f.Severity == email.SeverityError
+	}
+	if failed {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// prepare builds the message and its options from mf.
+func prepare(mf *messageFlags) (types.Message, []email.Option, error) {
+	msg, err := mf.message()
+	if err != nil {
+		return msg, nil, err
+	}
+	opts, err := mf.options(msg)
+	return msg, opts, err
+}
+
+// readInput reads the named file, or stdin for "" and "-".
+func readInput(path string) ([]byte, error) {
+	if path == "" || path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "email:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/dkim"
+	"github.com/aatuh/email/v2/types"
+)
+
+// listFlag is a repeatable string flag.
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// messageFlags describe a message given on the command line, in a JSON
+// file or as a template directory.
+type messageFlags struct {
+	from, subject, text, html string
+	to, cc, bcc, replyTo      listFlag
+	headers, attach           listFlag
+
+	json                string
+	tmplDir, tmplName   string
+	tmplData            string
+	dkimKey, dkimDomain string
+	dkimSelector        string
+}
+
+func (f *messageFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.from, "from", "", "From address")
+	fs.Var(&f.to, "to", "To address (repeatable)")
+	fs.Var(&f.cc, "cc", "Cc address (repeatable)")
+	fs.Var(&f.bcc, "bcc", "Bcc address (repeatable)")
+	fs.Var(&f.replyTo, "reply-to", "Reply-To address (repeatable)")
+	fs.StringVar(&f.subject, "subject", "", "subject")
+	fs.StringVar(&f.text, "text", "", "plain text body, or @file")
+	fs.StringVar(&f.html, "html", "", "HTML body, or @file")
+	fs.Var(&f.headers, "header", `extra header "Name: value" (repeatable)`)
+	fs.Var(&f.attach, "attach", "file to attach (repeatable)")
+	fs.StringVar(&f.json, "json", "", "JSON message file; flags override its fields")
+	fs.StringVar(&f.tmplDir, "template", "", "template directory for -name")
+	fs.StringVar(&f.tmplName, "name", "", "template name, rendering <name>.txt.tmpl and <name>.html.tmpl")
+	fs.StringVar(&f.tmplData, "data", "", "JSON file with the template data")
+	fs.StringVar(&f.dkimKey, "dkim-key", "", "DKIM private key PEM file; signs the message")
+	fs.StringVar(&f.dkimSelector, "dkim-selector", "", "DKIM selector")
+	fs.StringVar(&f.dkimDomain, "dkim-domain", "", "DKIM d= domain (default: From domain)")
+}
+
+// message builds the message the flags describe. A -json message
+// (types.Message JSON) is read first; flags override or extend it.
+func (f *messageFlags) message() (types.Message, error) {
+	var msg types.Message
+	if f.json != "" {
+		data, err := os.ReadFile(f.json)
+		if err != nil {
+			return msg, err
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return msg, fmt.Errorf("%s: %w", f.json, err)
+		}
+	}
+	var err error
+	if f.from != "" {
+		if msg.From, err = types.ParseAddress(f.from); err != nil {
+			return msg, fmt.Errorf("from: %w", err)
+		}
+	}
+	for _, l := range []struct {
+		name string
+		dst  *[]types.Address
+		v    []string
+	}{
+		{"to", &msg.To, f.to}, {"cc", &msg.Cc, f.cc},
+		{"bcc", &msg.Bcc, f.bcc}, {"reply-to", &msg.ReplyTo, f.replyTo},
+	} {
+		addrs, err := types.ParseAddressList(l.v)
+		if err != nil {
+			return msg, fmt.Errorf("%s: %w", l.name, err)
+		}
+		*l.dst = append(*l.dst, addrs...)
+	}
+	if f.subject != "" {
+		msg.Subject = f.subject
+	}
+	for _, p := range []struct {
+		dst *[]byte
+		v   string
+	}{{&msg.Plain, f.text}, {&msg.HTML, f.html}} {
+		v, err := fileArg(p.v)
+		if err != nil {
+			return msg, err
+		}
+		if v != "" {
+			*p.dst = []byte(v)
+		}
+	}
+	for _, h := range f.headers {
+		name, v, ok := strings.Cut(h, ":")
+		if !ok {
+			return msg, fmt.Errorf("header %q: missing colon", h)
+		}
+		msg.Headers.Add(strings.TrimSpace(name), strings.TrimSpace(v))
+	}
+	for _, path := range f.attach {
+		msg.Attach = append(msg.Attach, attachment(path))
+	}
+	if f.tmplName != "" {
+		if err := f.render(&msg); err != nil {
+			return msg, err
+		}
+	}
+	return msg, nil
+}
+
+// render fills the bodies of msg from the template set. Inline images
+// referenced with cid are read from the template directory.
+func (f *messageFlags) render(msg *types.Message) error {
+	if f.tmplDir == "" {
+		return errors.New("-name requires -template")
+	}
+	fsys := os.DirFS(f.tmplDir)
+	set, err := email.LoadTemplates(fsys)
+	if err != nil {
+		return err
+	}
+	var data any
+	if f.tmplData != "" {
+		raw, err := os.ReadFile(f.tmplData)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return fmt.Errorf("%s: %w", f.tmplData, err)
+		}
+	}
+	rendered, err := set.WithAssets(fsys).RenderMessage(f.tmplName, data, *msg)
+	if err != nil {
+		return err
+	}
+	*msg = rendered
+	return nil
+}
+
+// options returns the send options the flags ask for.
+func (f *messageFlags) options(msg types.Message) ([]email.Option, error) {
+	if f.dkimKey == "" {
+		return nil, nil
+	}
+	if f.dkimSelector == "" {
+		return nil, errors.New("-dkim-key requires -dkim-selector")
+	}
+	pem, err := os.ReadFile(f.dkimKey)
+	if err != nil {
+		return nil, err
+	}
+	domain := f.dkimDomain
+	if domain == "" {
+		_, domain, _ = strings.Cut(msg.From.Mail, "@")
+	}
+	cfg, err := dkim.NewConfig(domain, f.dkimSelector, pem)
+	if err != nil {
+		return nil, err
+	}
+	return []email.Option{email.WithDKIM(cfg)}, nil
+}
+
+// fileArg returns the contents of the named file for "@file", else v.
+func fileArg(v string) (string, error) {
+	path, ok := strings.CutPrefix(v, "@")
+	if !ok {
+		return v, nil
+	}
+	data, err := os.ReadFile(path)
+	return string(data), err
+}
+
+// attachment references the file at path, typed by its extension.
+func attachment(path string) types.Attachment {
+	ct := mime.TypeByExtension(filepath.Ext(path))
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+	return types.Attachment{
+		Filename:    filepath.Base(path),
+		ContentType: ct,
+		Reader:      types.NewFileReader(path),
+	}
+}
@@ -4,8 +4,41 @@ import (
 	"container/list"
 	"sync"
 	"time"
+
+	"github.com/aatuh/email/v2/internal"
+)
+
+// EvictReason tells why a connection left the pool (PoolHooks.OnEvict).
+type EvictReason string
+
+// Reasons for evicting a connection.
+const (
+	EvictExpired     EvictReason = "expired"     // idle longer than IdleTTL
+	EvictLifetime    EvictReason = "lifetime"    // open longer than MaxLifetime
+	EvictUnhealthy   EvictReason = "unhealthy"   // failed the health check
+	EvictPoolFull    EvictReason = "pool_full"   // returned with MaxIdle reached
+	EvictPoolClosed  EvictReason = "pool_closed" // closed by CloseAll
+	EvictBroken      EvictReason = "broken"      // cut mid-command (Discard)
+	EvictStale       EvictReason = "stale"       // dropped by the server while idle
+	EvictCredentials EvictReason = "credentials" // authenticated with rotated credentials
 )
 
+// PoolHooks report what a ConnPool does with its connections, to find
+// out why throughput degrades without a packet capture. Any hook may be
+// nil. Hooks run with the pool locked, so they must be quick and must
+// not call the pool.
+type PoolHooks struct {
+	// OnDial reports a new connection: the time New took and its error.
+	OnDial func(d time.Duration, err error)
+	// OnReuse reports an idle connection handed out again and how long
+	// it was idle.
+	OnReuse func(idle time.Duration)
+	// OnEvict reports a connection closed instead of reused.
+	OnEvict func(reason EvictReason)
+	// OnCloseError reports an error closing a connection.
+	OnCloseError func(err error)
+}
+
 // ConnPool is a simple sized pool for client connections.
 // It stores opaque connections. Adapter owns the concrete type.
 //
@@ -16,16 +49,28 @@ type ConnPool struct {
 	New       func() (any, error)
 	Close     func(any) error
 	IsHealthy func(any) bool
+	Hooks     *PoolHooks // optional diagnostics
+
+	// MaxLifetime, if set, closes connections open longer than this,
+	// however busy, e.g. to move them to new servers behind a load
+	// balancer. Connections must then be comparable, like pointers.
+	MaxLifetime time.Duration
+	// Jitter shortens IdleTTL and MaxLifetime by a random fraction up
+	// to this (0 to 1) per connection, so connections opened together
+	// do not expire and redial together.
+	Jitter float64
 
 	mu    sync.Mutex
 	idle  *list.List // list of *poolItem
 	inUse int
+	ends  map[any]time.Time // end of life per connection (MaxLifetime)
 }
 
 // poolItem is a pool item for a connection.
 type poolItem struct {
 	conn any
 	ts   time.Time
+	ttl  time.Duration // IdleTTL with jitter
 }
 
 // NewConnPool creates a new pool.
@@ -76,25 +121,39 @@ func (p *ConnPool) Get() (any, error) {
 		back := p.idle.Back()
 		p.idle.Remove(back)
 		it := back.Value.(*poolItem)
-		if time.Since(it.ts) <= p.IdleTTL && (p.IsHealthy == nil ||
-			p.IsHealthy(it.conn)) {
-			p.inUse++
-			return it.conn, nil
+		idle := time.Since(it.ts)
+		if p.ended(it.conn) {
+			p.evict(it.conn, EvictLifetime)
+			continue
+		}
+		if idle > it.ttl {
+			p.evict(it.conn, EvictExpired)
+			continue
+		}
+		if p.IsHealthy != nil && !p.IsHealthy(it.conn) {
+			p.evict(it.conn, EvictUnhealthy)
+			continue
 		}
-		// Drop stale/unhealthy.
-		if p.Close != nil && it.conn != nil {
-			_ = p.Close(it.conn)
+		if p.Hooks != nil && p.Hooks.OnReuse != nil {
+			p.Hooks.OnReuse(idle)
 		}
+		p.inUse++
+		return it.conn, nil
 	}
 
 	// Create new.
 	if p.New == nil {
 		return nil, nil
 	}
+	start := time.Now()
 	conn, err := p.New()
+	if p.Hooks != nil && p.Hooks.OnDial != nil {
+		p.Hooks.OnDial(time.Since(start), err)
+	}
 	if err != nil {
 		return nil, err
 	}
+	p.born(conn)
 	p.inUse++
 	return conn, nil
 }
@@ -111,13 +170,38 @@ func (p *ConnPool) Put(conn any) {
 	defer p.mu.Unlock()
 
 	p.inUse--
+	if p.ended(conn) {
+		p.evict(conn, EvictLifetime)
+		return
+	}
 	if p.idle.Len() >= p.MaxIdle {
-		if p.Close != nil {
-			_ = p.Close(conn)
-		}
+		p.evict(conn, EvictPoolFull)
 		return
 	}
-	p.idle.PushBack(&poolItem{conn: conn, ts: time.Now()})
+	p.idle.PushBack(&poolItem{
+		conn: conn, ts: time.Now(), ttl: p.jitter(p.IdleTTL),
+	})
+}
+
+// Discard gives up conn, taken with Get, instead of returning it to the
+// pool, e.g. because it was cut mid-command. The caller closes it; it
+// may not be safe to Close gracefully.
+//
+// Parameters:
+//   - conn: The connection.
+//   - reason: The reason reported to PoolHooks.OnEvict.
+func (p *ConnPool) Discard(conn any, reason EvictReason) {
+	if conn == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.inUse--
+	if p.MaxLifetime > 0 {
+		delete(p.ends, conn)
+	}
+	p.evict(nil, reason)
 }
 
 // CloseAll drains the pool and closes all idle connections.
@@ -125,10 +209,62 @@ func (p *ConnPool) CloseAll() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	for e := p.idle.Front(); e != nil; e = e.Next() {
-		it := e.Value.(*poolItem)
-		if p.Close != nil && it.conn != nil {
-			_ = p.Close(it.conn)
-		}
+		p.evict(e.Value.(*poolItem).conn, EvictPoolClosed)
 	}
 	p.idle.Init()
 }
+
+// evict closes conn, if not nil, and reports it to the hooks. p.mu must
+// be held.
+func (p *ConnPool) evict(conn any, reason EvictReason) {
+	if p.MaxLifetime > 0 && conn != nil {
+		delete(p.ends, conn)
+	}
+	if p.Hooks != nil && p.Hooks.OnEvict != nil {
+		p.Hooks.OnEvict(reason)
+	}
+	if p.Close == nil || conn == nil {
+		return
+	}
+	if err := p.Close(conn); err != nil && p.Hooks != nil &&
+		p.Hooks.OnCloseError != nil {
+		p.Hooks.OnCloseError(err)
+	}
+}
+
+// born records the end of life of a new conn. p.mu must be held.
+func (p *ConnPool) born(conn any) {
+	if p.MaxLifetime <= 0 {
+		return
+	}
+	if p.ends == nil {
+		p.ends = map[any]time.Time{}
+	}
+	p.ends[conn] = time.Now().Add(p.jitter(p.MaxLifetime))
+}
+
+// ended reports whether conn has outlived MaxLifetime. A connection
+// the pool has not seen, dialed by the caller, starts its life now.
+// p.mu must be held.
+func (p *ConnPool) ended(conn any) bool {
+	if p.MaxLifetime <= 0 {
+		return false
+	}
+	end, ok := p.ends[conn]
+	if !ok {
+		p.born(conn)
+		return false
+	}
+	return time.Now().After(end)
+}
+
+// jitter shortens d by a random fraction up to Jitter, read from the
+// random source (see SetRandSource).
+func (p *ConnPool) jitter(d time.Duration) time.Duration {
+	j := min(max(p.Jitter, 0), 1)
+	f, err := internal.RandFloat64(internal.Rand())
+	if err != nil {
+		return d
+	}
+	return d - time.Duration(f*j*float64(d))
+}
@@ -2,6 +2,7 @@ package email
 
 import (
     "errors"
+    "fmt"
     "sync/atomic"
     "testing"
     "time"
@@ -65,3 +66,94 @@ func TestConnPoolNewError(t *testing.T) {
     }
 }
 
+func TestConnPoolHooks(t *testing.T) {
+    var events []string
+    healthy := true
+    p := NewConnPool(1, 30*time.Millisecond,
+        func() (any, error) { return new(int), nil },
+        func(a any) error { return errors.New("close failed") },
+        func(a any) bool { return healthy },
+    )
+    p.Hooks = &PoolHooks{
+        OnDial:       func(d time.Duration, err error) { events = append(events, "dial") },
+        OnReuse:      func(idle time.Duration) { events = append(events, "reuse") },
+        OnEvict:      func(r EvictReason) { events = append(events, string(r)) },
+        OnCloseError: func(err error) { events = append(events, "close error") },
+    }
+
+    c1, _ := p.Get()
+    c2, _ := p.Get()
+    p.Put(c1)
+    p.Put(c2)
+    c1, _ = p.Get()
+    p.Put(c1)
+    healthy = false
+    c1, _ = p.Get()
+    p.Discard(c1, EvictBroken)
+    healthy = true
+    c1, _ = p.Get()
+    p.Put(c1)
+    time.Sleep(40 * time.Millisecond)
+    c1, _ = p.Get()
+    p.Put(c1)
+    p.CloseAll()
+
+    want := []string{
+        "dial", "dial", "pool_full", "close error", "reuse",
+        "unhealthy", "close error", "dial", "broken", "dial",
+        "expired", "close error", "dial", "pool_closed", "close error",
+    }
+    if fmt.Sprint(events) != fmt.Sprint(want) {
+        t.Fatalf("events = %v\nwant     %v", events, want)
+    }
+}
+
+func TestConnPoolLifetimeAndJitter(t *testing.T) {
+    var evicted []EvictReason
+    p := NewConnPool(2, time.Minute,
+        func() (any, error) { return new(int), nil }, nil, nil)
+    p.MaxLifetime = 30 * time.Millisecond
+    p.Hooks = &PoolHooks{OnEvict: func(r EvictReason) { evicted = append(evicted, r) }}
+
+    c1, _ := p.Get()
+    p.Put(c1)
+    if c, _ := p.Get(); c != c1 { t.Fatalf("expected reuse within lifetime") }
+    time.Sleep(40 * time.Millisecond)
+    // Busy connections expire too: on return and when idle.
+    p.Put(c1)
+    c2, _ := p.Get()
+    p.Put(c2)
+    time.Sleep(40 * time.Millisecond)
+    if c, _ := p.Get(); c == c2 { t.Fatalf("expected a new connection after lifetime") }
+    if len(evicted) != 2 || evicted[0] != EvictLifetime || evicted[1] != EvictLifetime {
+        t.Fatalf("evictions = %v", evicted)
+    }
+
+    p = &ConnPool{Jitter: 0.5}
+    seen := map[time.Duration]bool{}
+    for range 50 {
+        d := p.jitter(time.Second)
+        if d <= 500*time.Millisecond || d > time.Second {
+            t.Fatalf("jittered %v out of range", d)
+        }
+        seen[d] = true
+    }
+    if len(seen) < 2 { t.Fatalf("jitter did not vary") }
+}
+
+
+func BenchmarkConnPoolGetPut(b *testing.B) {
+    p := NewConnPool(4, time.Minute,
+        func() (any, error) { return new(int), nil },
+        func(any) error { return nil },
+        func(any) bool { return true },
+    )
+    b.ReportAllocs()
+    b.RunParallel(func(pb *testing.PB) {
+        for pb.Next() {
+            c, err := p.Get()
+            if err != nil { b.Fatal(err) }
+            p.Put(c)
+        }
+    })
+}
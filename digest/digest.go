@@ -0,0 +1,215 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// Config configures a Digester.
+type Config struct {
+	// Mailer sends the digests.
+	Mailer email.Mailer
+	// Options are passed to every Send.
+	Options []email.Option
+
+	// Templates and Template name the digest template. It is rendered
+	// with a Data value.
+	Templates *email.TemplateSet
+	Template  string
+	// Base supplies From, Subject and other fields of each digest; To
+	// is set to the recipient. A nil Base uses an empty message.
+	Base func(recipient string) types.Message
+
+	// Window is how long items are held: a recipient's digest is due
+	// once its oldest item is Window old. Defaults to one hour.
+	Window time.Duration
+	// MaxItems makes a digest due as soon as this many items wait;
+	// zero means no limit.
+	MaxItems int
+
+	// Store keeps the items; defaults to a MemoryStore.
+	Store Store
+	// Now returns the current time; defaults to the clock set with
+	// email.SetClock.
+	Now func() time.Time
+}
+
+// Data is the template data of a digest.
+type Data struct {
+	Recipient string
+	Items     []Item // oldest first
+	Since     time.Time
+	Until     time.Time
+}
+
+// Digester collects items per recipient and sends them as digests. It
+// is safe for concurrent use.
+type Digester struct {
+	cfg     Config
+	flushMu sync.Mutex // one flush at a time
+}
+
+// New creates a digester.
+//
+// Parameters:
+//   - cfg: The digest config.
+//
+// Returns:
+//   - *Digester: The digester.
+func New(cfg Config) *Digester {
+	if cfg.Window <= 0 {
+		cfg.Window = time.Hour
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryStore()
+	}
+	if cfg.Now == nil {
+		cfg.Now = internal.Now
+	}
+	return &Digester{cfg: cfg}
+}
+
+// Add queues data for the next digest of recipient.
+//
+// Parameters:
+//   - ctx: The context.
+//   - recipient: The recipient address.
+//   - data: The item, available to the template as .Items[i].Data.
+//
+// Returns:
+//   - error: The error if the address is invalid or the store fails.
+func (d *Digester) Add(ctx context.Context, recipient string, data any) error {
+	if _, err := types.ParseAddress(recipient); err != nil {
+		return fmt.Errorf("digest: recipient %q: %w", recipient, err)
+	}
+	return d.cfg.Store.Append(ctx, Item{
+		Recipient: recipient, Time: d.cfg.Now(), Data: data,
+	})
+}
+
+// Flush sends the digests that are due.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - int: The number of digests sent.
+//   - error: The joined errors of failed digests, whose items are kept
+//     for the next flush.
+func (d *Digester) Flush(ctx context.Context) (int, error) {
+	return d.flush(ctx, false)
+}
+
+// FlushAll sends every pending digest regardless of the window, e.g.
+// at shutdown.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - int: The number of digests sent.
+//   - error: The joined errors of failed digests.
+func (d *Digester) FlushAll(ctx context.Context) (int, error) {
+	return d.flush(ctx, true)
+}
+
+// Run calls Flush every interval until ctx ends, then returns
+// ctx.Err(). Flush errors are passed to onErr if it is not nil.
+//
+// Parameters:
+//   - ctx: The context.
+//   - interval: The time between flushes.
+//   - onErr: The flush error handler, or nil.
+//
+// Returns:
+//   - error: The context error.
+func (d *Digester) Run(ctx context.Context, interval time.Duration, onErr func(error)) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			if _, err := d.Flush(ctx); err != nil && onErr != nil {
+				onErr(err)
+			}
+		}
+	}
+}
+
+func (d *Digester) flush(ctx context.Context, all bool) (int, error) {
+	d.flushMu.Lock()
+	defer d.flushMu.Unlock()
+	pending, err := d.cfg.Store.Pending(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("digest: %w", err)
+	}
+	now := d.cfg.Now()
+	sent := 0
+	var errs []error
+	for _, p := range pending {
+		due := all || now.Sub(p.First) >= d.cfg.Window ||
+			d.cfg.MaxItems > 0 && p.Count >= d.cfg.MaxItems
+		if !due {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if err := d.send(ctx, p.Recipient, now); err != nil {
+			errs = append(errs, fmt.Errorf("digest %s: %w", p.Recipient, err))
+			continue
+		}
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
+
+// send takes the items of recipient and sends them as one digest,
+// returning them to the store if that fails.
+func (d *Digester) send(ctx context.Context, recipient string, now time.Time) error {
+	items, err := d.cfg.Store.Take(ctx, recipient)
+	if err != nil || len(items) == 0 {
+		return err
+	}
+	err = d.deliver(ctx, recipient, items, now)
+	if err != nil {
+		for _, it := range items {
+			if aerr := d.cfg.Store.Append(ctx, it); aerr != nil {
+				return errors.Join(err, aerr)
+			}
+		}
+	}
+	return err
+}
+
+func (d *Digester) deliver(ctx context.Context, recipient string, items []Item, now time.Time) error {
+	if d.cfg.Templates == nil || d.cfg.Mailer == nil {
+		return errors.New("no Templates or Mailer")
+	}
+	var base types.Message
+	if d.cfg.Base != nil {
+		base = d.cfg.Base(recipient)
+	}
+	to, err := types.ParseAddress(recipient)
+	if err != nil {
+		return err
+	}
+	base.To = []types.Address{to}
+	msg, err := d.cfg.Templates.RenderMessage(d.cfg.Template, Data{
+		Recipient: recipient, Items: items, Since: items[0].Time, Until: now,
+	}, base)
+	if err != nil {
+		return err
+	}
+	return d.cfg.Mailer.Send(ctx, msg, d.cfg.Options...)
+}
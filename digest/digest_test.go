@@ -0,0 +1,94 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
+
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []types.Message
+	err  error
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg types.Message, opts ...email.Option) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestDigester(t *testing.T) {
+	tpl, err := email.LoadTemplates(fstest.MapFS{
+		"digest.txt.tmpl": {Data: []byte(`{{len .Items}} new:{{range .Items}} {{.Data}}{{end}}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	mailer := &fakeMailer{}
+	d := New(Config{
+		Mailer:    mailer,
+		Templates: tpl,
+		Template:  "digest",
+		Base: func(rcpt string) types.Message {
+			return types.Message{From: types.Address{Mail: "app@example.com"}, Subject: "Your updates"}
+		},
+		Window:   time.Hour,
+		MaxItems: 3,
+		Now:      func() time.Time { return now },
+	})
+	ctx := context.Background()
+
+	d.Add(ctx, "ada@example.org", "comment")
+	for _, s := range []string{"a", "b", "c"} {
+		d.Add(ctx, "bob@example.org", s)
+	}
+	if err := d.Add(ctx, "not an address", "x"); err == nil {
+		t.Fatal("expected invalid recipient error")
+	}
+	now = now.Add(10 * time.Minute)
+	d.Add(ctx, "ada@example.org", "like")
+
+	// bob reached MaxItems; ada waits for the window.
+	if n, err := d.Flush(ctx); n != 1 || err != nil {
+		t.Fatalf("flush 1: %d %v", n, err)
+	}
+	if got := mailer.sent[0]; got.To[0].Mail != "bob@example.org" ||
+		string(got.Plain) != "3 new: a b c" || got.Subject != "Your updates" {
+		t.Fatalf("bob digest = %+v", got)
+	}
+
+	// A failed send keeps the items.
+	now = now.Add(time.Hour)
+	mailer.err = errors.New("smtp down")
+	if n, err := d.Flush(ctx); n != 0 || err == nil || !strings.Contains(err.Error(), "ada@example.org") {
+		t.Fatalf("flush 2: %d %v", n, err)
+	}
+	mailer.err = nil
+	if n, err := d.Flush(ctx); n != 1 || err != nil {
+		t.Fatalf("flush 3: %d %v", n, err)
+	}
+	if got := string(mailer.sent[1].Plain); got != "2 new: comment like" {
+		t.Fatalf("ada digest = %q", got)
+	}
+	if n, _ := d.Flush(ctx); n != 0 {
+		t.Fatalf("nothing should be pending, sent %d", n)
+	}
+
+	d.Add(ctx, "cy@example.org", "x")
+	if n, err := d.FlushAll(ctx); n != 1 || err != nil {
+		t.Fatalf("flush all: %d %v", n, err)
+	}
+}
@@ -0,0 +1,6 @@
+// Package digest batches notifications into periodic roll-up emails.
+// Items are collected per recipient in a Store, and once the first
+// pending item is older than the window (or enough items are pending)
+// they are rendered with an email.TemplateSet into one message and sent
+// through any email.Mailer.
+package digest
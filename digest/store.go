@@ -0,0 +1,106 @@
+package digest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Item is one notification waiting for a digest.
+type Item struct {
+	Recipient string
+	Time      time.Time // when it was added
+	Data      any       // passed to the template
+}
+
+// Pending summarizes the waiting items of one recipient.
+type Pending struct {
+	Recipient string
+	First     time.Time // time of the oldest item
+	Count     int
+}
+
+// Store keeps items until their digest is sent. Implementations must
+// be safe for concurrent use; a persistent store must be able to
+// serialize the Data it is given.
+type Store interface {
+	// Append adds an item.
+	Append(ctx context.Context, it Item) error
+	// Pending lists the recipients with waiting items.
+	Pending(ctx context.Context) ([]Pending, error)
+	// Take removes and returns the items of recipient, oldest first.
+	Take(ctx context.Context, recipient string) ([]Item, error)
+}
+
+// MemoryStore is an in-memory Store. Items are lost on restart.
+type MemoryStore struct {
+	mu    sync.Mutex
+	items map[string][]Item
+}
+
+// NewMemoryStore creates an empty in-memory store.
+//
+// Returns:
+//   - *MemoryStore: The store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: map[string][]Item{}}
+}
+
+// Append adds an item.
+//
+// Parameters:
+//   - ctx: The context.
+//   - it: The item.
+//
+// Returns:
+//   - error: Always nil.
+func (s *MemoryStore) Append(ctx context.Context, it Item) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[it.Recipient] = append(s.items[it.Recipient], it)
+	return nil
+}
+
+// Pending lists the recipients with waiting items, sorted by recipient.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - []Pending: The pending recipients.
+//   - error: Always nil.
+func (s *MemoryStore) Pending(ctx context.Context) ([]Pending, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Pending, 0, len(s.items))
+	for rcpt, items := range s.items {
+		first := items[0].Time
+		for _, it := range items[1:] {
+			if it.Time.Before(first) {
+				first = it.Time
+			}
+		}
+		out = append(out, Pending{Recipient: rcpt, First: first, Count: len(items)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Recipient < out[j].Recipient })
+	return out, nil
+}
+
+// Take removes and returns the items of recipient, oldest first.
+//
+// Parameters:
+//   - ctx: The context.
+//   - recipient: The recipient.
+//
+// Returns:
+//   - []Item: The items.
+//   - error: Always nil.
+func (s *MemoryStore) Take(ctx context.Context, recipient string) ([]Item, error) {
+	s.mu.Lock()
+	items := s.items[recipient]
+	delete(s.items, recipient)
+	s.mu.Unlock()
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Time.Before(items[j].Time) })
+	return items, nil
+}
@@ -0,0 +1,32 @@
+package digest
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.Append(ctx, Item{Recipient: "b@example.org", Time: t0.Add(time.Minute), Data: 2})
+	s.Append(ctx, Item{Recipient: "b@example.org", Time: t0, Data: 1})
+	s.Append(ctx, Item{Recipient: "a@example.org", Time: t0.Add(time.Hour), Data: 3})
+
+	p, _ := s.Pending(ctx)
+	if len(p) != 2 || p[0].Recipient != "a@example.org" ||
+		p[1].Recipient != "b@example.org" || p[1].Count != 2 || !p[1].First.Equal(t0) {
+		t.Fatalf("pending = %+v", p)
+	}
+	items, _ := s.Take(ctx, "b@example.org")
+	if len(items) != 2 || items[0].Data != 1 || items[1].Data != 2 {
+		t.Fatalf("take = %+v", items)
+	}
+	if items, _ := s.Take(ctx, "b@example.org"); len(items) != 0 {
+		t.Fatalf("second take = %+v", items)
+	}
+	if p, _ := s.Pending(ctx); len(p) != 1 {
+		t.Fatalf("pending after take = %+v", p)
+	}
+}
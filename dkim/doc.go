@@ -0,0 +1,5 @@
+// Package dkim provides DKIM key management helpers: generating RSA and
+// Ed25519 key pairs, loading private keys from PEM, rendering the DNS
+// TXT record to publish for a selector, and verifying signed messages
+// against the published key.
+package dkim
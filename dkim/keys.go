@@ -0,0 +1,173 @@
+package dkim
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// KeyType selects the DKIM key algorithm.
+type KeyType string
+
+// Supported key types.
+const (
+	RSA     KeyType = "rsa"
+	Ed25519 KeyType = "ed25519"
+)
+
+// DefaultRSABits is the RSA modulus size used when bits is not positive.
+const DefaultRSABits = 2048
+
+// MinRSABits is the smallest RSA modulus accepted for signing: 1024
+// bits, or 2048 in builds with the fips tag.
+const MinRSABits = internal.MinRSABits
+
+// GenerateKey creates a new private key usable for DKIM signing.
+//
+// Parameters:
+//   - kt: The key type.
+//   - bits: The RSA modulus size; ignored for Ed25519.
+//
+// Returns:
+//   - crypto.Signer: The private key.
+//   - error: An error if generation fails.
+func GenerateKey(kt KeyType, bits int) (crypto.Signer, error) {
+	switch kt {
+	case RSA:
+		if bits <= 0 {
+			bits = DefaultRSABits
+		}
+		if bits < MinRSABits {
+			return nil, fmt.Errorf("dkim: RSA key of %d bits is too small",
+				bits)
+		}
+		return rsa.GenerateKey(internal.Rand(), bits)
+	case Ed25519:
+		_, priv, err := ed25519.GenerateKey(internal.Rand())
+		return priv, err
+	default:
+		return nil, fmt.Errorf("dkim: unknown key type %q", kt)
+	}
+}
+
+// MarshalPrivateKeyPEM encodes key as a PKCS#8 "PRIVATE KEY" PEM block,
+// suitable for types.DKIMConfig.KeyPEM.
+//
+// Parameters:
+//   - key: The private key.
+//
+// Returns:
+//   - []byte: The PEM bytes.
+//   - error: An error if the key cannot be marshaled.
+func MarshalPrivateKeyPEM(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("dkim: marshal key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		nil
+}
+
+// ParsePrivateKeyPEM parses an RSA (PKCS#1 or PKCS#8) or Ed25519
+// (PKCS#8) private key.
+//
+// Parameters:
+//   - pemBytes: The PEM bytes.
+//
+// Returns:
+//   - crypto.Signer: The private key.
+//   - error: An error if the key cannot be parsed.
+func ParsePrivateKeyPEM(pemBytes []byte) (crypto.Signer, error) {
+	key, err := internal.ParseDKIMKey(pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("dkim: parse key: %w", err)
+	}
+	return key, nil
+}
+
+// LoadPrivateKeyFile reads and parses a PEM private key file.
+//
+// Parameters:
+//   - path: The file path.
+//
+// Returns:
+//   - crypto.Signer: The private key.
+//   - error: An error if the file cannot be read or parsed.
+func LoadPrivateKeyFile(path string) (crypto.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("dkim: read key: %w", err)
+	}
+	return ParsePrivateKeyPEM(b)
+}
+
+// NewConfig returns a DKIMConfig with the PEM key parsed once into
+// Signer, avoiding a parse on every send.
+//
+// Parameters:
+//   - domain: The signing domain (d=).
+//   - selector: The selector (s=).
+//   - keyPEM: The PEM encoded private key.
+//
+// Returns:
+//   - types.DKIMConfig: The config.
+//   - error: An error if the key cannot be parsed.
+func NewConfig(domain, selector string, keyPEM []byte) (types.DKIMConfig, error) {
+	key, err := ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return types.DKIMConfig{}, err
+	}
+	return types.DKIMConfig{
+		Domain:   domain,
+		Selector: selector,
+		KeyPEM:   keyPEM,
+		Signer:   key,
+	}, nil
+}
+
+// RecordName returns the DNS name to publish the key under.
+//
+// Parameters:
+//   - selector: The DKIM selector.
+//   - domain: The signing domain.
+//
+// Returns:
+//   - string: The name, e.g. "s1._domainkey.example.com".
+func RecordName(selector, domain string) string {
+	return selector + "._domainkey." + domain
+}
+
+// TXTRecord returns the DNS TXT record value for a public key, e.g.
+// "v=DKIM1; k=rsa; p=MIIB...". RSA keys are published as DER
+// SubjectPublicKeyInfo, Ed25519 keys as the raw 32 bytes (RFC 8463).
+//
+// Parameters:
+//   - pub: The public key (e.g. signer.Public()).
+//
+// Returns:
+//   - string: The TXT record value.
+//   - error: An error if the key type is unsupported.
+func TXTRecord(pub crypto.PublicKey) (string, error) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(k)
+		if err != nil {
+			return "", fmt.Errorf("dkim: marshal public key: %w", err)
+		}
+		return "v=DKIM1; k=rsa; p=" +
+			base64.StdEncoding.EncodeToString(der), nil
+	case ed25519.PublicKey:
+		return "v=DKIM1; k=ed25519; p=" +
+			base64.StdEncoding.EncodeToString(k), nil
+	default:
+		return "", fmt.Errorf("dkim: unsupported public key type %T", pub)
+	}
+}
@@ -0,0 +1,148 @@
+package dkim
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// parseTXT decodes a TXT record produced by TXTRecord.
+func parseTXT(t *testing.T, rec string) crypto.PublicKey {
+	t.Helper()
+	_, p, ok := strings.Cut(rec, "p=")
+	if !ok {
+		t.Fatalf("no p= in %q", rec)
+	}
+	der, err := base64.StdEncoding.DecodeString(p)
+	if err != nil {
+		t.Fatalf("decode p=: %v", err)
+	}
+	if strings.Contains(rec, "k=ed25519") {
+		return ed25519.PublicKey(der)
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		t.Fatalf("parse p=: %v", err)
+	}
+	return pub
+}
+
+func TestGenerateSignVerify(t *testing.T) {
+	for _, kt := range []KeyType{RSA, Ed25519} {
+		key, err := GenerateKey(kt, MinRSABits)
+		if err != nil {
+			t.Fatalf("%s: generate: %v", kt, err)
+		}
+		keyPEM, err := MarshalPrivateKeyPEM(key)
+		if err != nil {
+			t.Fatalf("%s: marshal: %v", kt, err)
+		}
+		path := filepath.Join(t.TempDir(), "key.pem")
+		if err := os.WriteFile(path, keyPEM, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadPrivateKeyFile(path)
+		if err != nil {
+			t.Fatalf("%s: load: %v", kt, err)
+		}
+		switch kt {
+		case RSA:
+			if !loaded.(*rsa.PrivateKey).Equal(key) {
+				t.Fatalf("loaded RSA key differs")
+			}
+		case Ed25519:
+			if !loaded.(ed25519.PrivateKey).Equal(key) {
+				t.Fatalf("loaded Ed25519 key differs")
+			}
+		}
+
+		rec, err := TXTRecord(key.Public())
+		if err != nil || !strings.HasPrefix(rec, "v=DKIM1; k="+string(kt)+"; p=") {
+			t.Fatalf("%s: unexpected record %q %v", kt, rec, err)
+		}
+		pub := parseTXT(t, rec)
+
+		msg := types.Message{
+			From:  types.Address{Mail: "a@example.com"},
+			To:    []types.Address{{Mail: "b@example.com"}},
+			Plain: []byte("hi"),
+		}
+		cfg := types.DKIMConfig{Domain: "example.com", Selector: "s1", KeyPEM: keyPEM}
+		raw, err := internal.BuildMIME(context.Background(), msg, internal.BuildOptions{DKIM: &cfg})
+		if err != nil {
+			t.Fatalf("%s: build: %v", kt, err)
+		}
+		lookup := func(d, s string) (crypto.PublicKey, error) { return pub, nil }
+		if _, err := internal.VerifyDKIM(raw, lookup); err != nil {
+			t.Fatalf("%s: verify: %v", kt, err)
+		}
+	}
+}
+
+func TestGenerateKeyErrors(t *testing.T) {
+	if _, err := GenerateKey("dsa", 0); err == nil {
+		t.Fatalf("expected error for unknown key type")
+	}
+	if _, err := GenerateKey(RSA, 512); err == nil {
+		t.Fatalf("expected error for small RSA key")
+	}
+	if RecordName("s1", "example.com") != "s1._domainkey.example.com" {
+		t.Fatalf("unexpected record name")
+	}
+}
+
+// countingSigner stands in for an HSM-backed key that cannot be exported.
+type countingSigner struct {
+	crypto.Signer
+	calls int
+}
+
+func (s *countingSigner) Sign(r io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.calls++
+	return s.Signer.Sign(r, digest, opts)
+}
+
+func TestSignerConfig(t *testing.T) {
+	key, err := GenerateKey(RSA, MinRSABits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hsm := &countingSigner{Signer: key}
+	cfg := types.DKIMConfig{Domain: "example.com", Selector: "s1", Signer: hsm}
+	msg := types.Message{
+		From:  types.Address{Mail: "a@example.com"},
+		To:    []types.Address{{Mail: "b@example.com"}},
+		Plain: []byte("hi"),
+	}
+	raw, err := internal.BuildMIME(context.Background(), msg, internal.BuildOptions{DKIM: &cfg})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if hsm.calls != 1 {
+		t.Fatalf("expected signer to be used once, got %d", hsm.calls)
+	}
+	lookup := func(d, s string) (crypto.PublicKey, error) { return key.Public(), nil }
+	if _, err := internal.VerifyDKIM(raw, lookup); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	keyPEM, _ := MarshalPrivateKeyPEM(key)
+	nc, err := NewConfig("example.com", "s1", keyPEM)
+	if err != nil || nc.Signer == nil || nc.Domain != "example.com" {
+		t.Fatalf("NewConfig: %+v %v", nc, err)
+	}
+	if _, err := NewConfig("example.com", "s1", []byte("junk")); err == nil {
+		t.Fatalf("expected error for bad PEM")
+	}
+}
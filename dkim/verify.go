@@ -0,0 +1,93 @@
+package dkim
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/aatuh/email/v2/internal"
+)
+
+// TXTResolver is the subset of *net.Resolver used for key lookups.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// ParseTXTRecord parses the public key from a DKIM TXT record value,
+// the inverse of TXTRecord.
+//
+// Parameters:
+//   - txt: The record, e.g. "v=DKIM1; k=rsa; p=MIIB...".
+//
+// Returns:
+//   - crypto.PublicKey: The *rsa.PublicKey or ed25519.PublicKey.
+//   - error: An error if the record is revoked or malformed.
+func ParseTXTRecord(txt string) (crypto.PublicKey, error) {
+	tags := internal.ParseTags(txt)
+	if v, ok := tags["v"]; ok && v != "DKIM1" {
+		return nil, fmt.Errorf("dkim: unsupported record version %q", v)
+	}
+	p, ok := tags["p"]
+	if !ok {
+		return nil, errors.New("dkim: record has no p= tag")
+	}
+	p = strings.Join(strings.Fields(p), "")
+	if p == "" {
+		return nil, errors.New("dkim: key is revoked")
+	}
+	der, err := base64.StdEncoding.DecodeString(p)
+	if err != nil {
+		return nil, fmt.Errorf("dkim: decode p=: %w", err)
+	}
+	switch k := strings.ToLower(tags["k"]); k {
+	case "", string(RSA):
+		pub, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("dkim: parse key: %w", err)
+		}
+		return pub, nil
+	case string(Ed25519):
+		if len(der) != ed25519.PublicKeySize {
+			return nil, errors.New("dkim: invalid ed25519 key length")
+		}
+		return ed25519.PublicKey(der), nil
+	default:
+		return nil, fmt.Errorf("dkim: unsupported key type %q", k)
+	}
+}
+
+// Verify checks the first DKIM-Signature of a raw message against the
+// key published in DNS.
+//
+// Parameters:
+//   - ctx: The context.
+//   - r: The resolver; nil uses net.DefaultResolver.
+//   - raw: The message with CRLF line endings.
+//
+// Returns:
+//   - map[string]string: The signature tags, e.g. "d" and "s".
+//   - error: An error if the signature does not verify.
+func Verify(ctx context.Context, r TXTResolver, raw []byte) (map[string]string, error) {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	return internal.VerifyDKIM(raw, func(domain, selector string) (crypto.PublicKey, error) {
+		name := RecordName(selector, domain)
+		txts, err := r.LookupTXT(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, txt := range txts {
+			if strings.Contains(txt, "p=") {
+				return ParseTXTRecord(txt)
+			}
+		}
+		return nil, fmt.Errorf("no key record at %s", name)
+	})
+}
@@ -0,0 +1,71 @@
+package dkim
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// txtMap resolves TXT records from a map.
+type txtMap map[string][]string
+
+func (m txtMap) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return m[name], nil
+}
+
+func TestVerify(t *testing.T) {
+	for _, kt := range []KeyType{RSA, Ed25519} {
+		key, err := GenerateKey(kt, MinRSABits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec, err := TXTRecord(key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := types.Message{
+			From:  types.Address{Mail: "a@example.com"},
+			To:    []types.Address{{Mail: "b@example.com"}},
+			Plain: []byte("hi"),
+		}
+		cfg := types.DKIMConfig{Domain: "example.com", Selector: "s1", Signer: key}
+		raw, err := internal.BuildMIME(context.Background(), msg,
+			internal.BuildOptions{DKIM: &cfg})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := txtMap{"s1._domainkey.example.com": {"unrelated", rec}}
+		tags, err := Verify(context.Background(), r, raw)
+		if err != nil || tags["d"] != "example.com" || tags["s"] != "s1" {
+			t.Fatalf("%s: verify: %v %v", kt, tags, err)
+		}
+
+		tampered := []byte(strings.Replace(string(raw), "hi", "ho", 1))
+		if _, err := Verify(context.Background(), r, tampered); err == nil {
+			t.Fatalf("%s: tampered message verified", kt)
+		}
+		revoked := txtMap{"s1._domainkey.example.com": {"v=DKIM1; p="}}
+		if _, err := Verify(context.Background(), revoked, raw); err == nil ||
+			!strings.Contains(err.Error(), "revoked") {
+			t.Fatalf("%s: expected revoked key error, got %v", kt, err)
+		}
+	}
+}
+
+func TestParseTXTRecordErrors(t *testing.T) {
+	for _, rec := range []string{
+		"v=DKIM2; p=AAAA",
+		"v=DKIM1; k=rsa",
+		"v=DKIM1; k=ed25519; p=AAAA",
+		"v=DKIM1; k=dsa; p=AAAA",
+		"v=DKIM1; p=!!!",
+	} {
+		if _, err := ParseTXTRecord(rec); err == nil {
+			t.Errorf("%q: expected error", rec)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+// Package dmarcreport parses DMARC aggregate ("rua") reports (RFC 7489
+// 7.2, appendix C), the XML feedback receivers send about mail claiming
+// to be from your domains. Reports arrive gzipped, zipped or as plain
+// XML, usually attached to an email; Parse handles all three and
+// ParseMessage finds them in a message parsed by the inbound package.
+// The records give, per source IP, the message count, the DMARC
+// disposition and the DKIM and SPF results, ready to aggregate for a
+// dashboard.
+package dmarcreport
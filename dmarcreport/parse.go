@@ -0,0 +1,149 @@
+package dmarcreport
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// MaxReportSize caps the decompressed size of a report, guarding against
+// compression bombs. Real reports are far smaller.
+const MaxReportSize = 32 << 20
+
+// ErrNotReport is returned by Parse for content that is not gzip, zip
+// or XML.
+var ErrNotReport = errors.New("dmarcreport: not a DMARC aggregate report")
+
+// Parse reads a report from r, which holds gzip-compressed, zipped or
+// plain XML, as detected from its first bytes. A zip archive must
+// contain one .xml file.
+//
+// Parameters:
+//   - r: The report content.
+//
+// Returns:
+//   - *Report: The report.
+//   - error: The error if the content is not a valid report or exceeds
+//     MaxReportSize.
+func Parse(r io.Reader) (*Report, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("dmarcreport: %w", err)
+		}
+		defer zr.Close()
+		return parseXML(zr)
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return parseZip(br)
+	default:
+		return parseXML(br)
+	}
+}
+
+// ParseMessage parses the reports attached to msg, as parsed by
+// inbound.Parse. Attachments are recognized by a gzip, zip or XML
+// content type or by a .xml, .gz or .zip file name; others are
+// skipped.
+//
+// Parameters:
+//   - msg: The report email.
+//
+// Returns:
+//   - []*Report: The reports, in attachment order.
+//   - error: The error if a report attachment fails to parse, or
+//     ErrNotReport if msg has none.
+func ParseMessage(msg *types.Message) ([]*Report, error) {
+	var out []*Report
+	for _, a := range msg.Attach {
+		if !isReportAttachment(a) || a.Reader == nil {
+			continue
+		}
+		rep, err := Parse(a.Reader)
+		if err != nil {
+			return out, fmt.Errorf("%s: %w", a.Filename, err)
+		}
+		out = append(out, rep)
+	}
+	if len(out) == 0 {
+		return nil, ErrNotReport
+	}
+	return out, nil
+}
+
+// isReportAttachment guesses whether a holds a report.
+func isReportAttachment(a types.Attachment) bool {
+	ctype, _, _ := mime.ParseMediaType(a.ContentType)
+	switch ctype {
+	case "application/gzip", "application/x-gzip", "application/zip",
+		"application/x-zip-compressed", "application/xml", "text/xml":
+		return true
+	}
+	switch strings.ToLower(path.Ext(a.Filename)) {
+	case ".xml", ".gz", ".zip":
+		return true
+	}
+	return false
+}
+
+// parseZip reads the single XML file of a zip archive.
+func parseZip(r io.Reader) (*Report, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxReportSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("dmarcreport: %w", err)
+	}
+	if len(data) > MaxReportSize {
+		return nil, fmt.Errorf("dmarcreport: archive larger than %d bytes", MaxReportSize)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("dmarcreport: %w", err)
+	}
+	var xmlFile *zip.File
+	for _, f := range zr.File {
+		if strings.EqualFold(path.Ext(f.Name), ".xml") {
+			if xmlFile != nil {
+				return nil, errors.New("dmarcreport: archive has more than one XML file")
+			}
+			xmlFile = f
+		}
+	}
+	if xmlFile == nil {
+		return nil, errors.New("dmarcreport: archive has no XML file")
+	}
+	f, err := xmlFile.Open()
+	if err != nil {
+		return nil, fmt.Errorf("dmarcreport: %w", err)
+	}
+	defer f.Close()
+	return parseXML(f)
+}
+
+// parseXML decodes a report, reading at most MaxReportSize bytes.
+func parseXML(r io.Reader) (*Report, error) {
+	lr := &io.LimitedReader{R: r, N: MaxReportSize + 1}
+	var rep Report
+	if err := xml.NewDecoder(lr).Decode(&rep); err != nil {
+		if lr.N <= 0 {
+			return nil, fmt.Errorf("dmarcreport: report larger than %d bytes", MaxReportSize)
+		}
+		var se xml.UnmarshalError
+		if errors.As(err, &se) || errors.Is(err, io.EOF) {
+			return nil, ErrNotReport
+		}
+		return nil, fmt.Errorf("dmarcreport: %w", err)
+	}
+	return &rep, nil
+}
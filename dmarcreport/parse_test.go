@@ -0,0 +1,151 @@
+package dmarcreport
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+const sampleReport = `<?xml version="1.0" encoding="UTF-8" ?>
+<feedback>
+  <report_metadata>
+    <org_name>google.com</org_name>
+    <email>noreply-dmarc-support@google.com</email>
+    <report_id>1234567890</report_id>
+    <date_range>
+      <begin>1767225600</begin>
+      <end>1767311999</end>
+    </date_range>
+  </report_metadata>
+  <policy_published>
+    <domain>example.com</domain>
+    <adkim>r</adkim>
+    <aspf>r</aspf>
+    <p>quarantine</p>
+    <sp>quarantine</sp>
+    <pct>100</pct>
+  </policy_published>
+  <record>
+    <row>
+      <source_ip>192.0.2.10</source_ip>
+      <count>42</count>
+      <policy_evaluated>
+        <disposition>none</disposition>
+        <dkim>pass</dkim>
+        <spf>pass</spf>
+      </policy_evaluated>
+    </row>
+    <identifiers>
+      <header_from>example.com</header_from>
+    </identifiers>
+    <auth_results>
+      <dkim>
+        <domain>example.com</domain>
+        <selector>s1</selector>
+        <result>pass</result>
+      </dkim>
+      <spf>
+        <domain>example.com</domain>
+        <result>pass</result>
+      </spf>
+    </auth_results>
+  </record>
+  <record>
+    <row>
+      <source_ip>2001:db8::1</source_ip>
+      <count>3</count>
+      <policy_evaluated>
+        <disposition>quarantine</disposition>
+        <dkim>fail</dkim>
+        <spf>fail</spf>
+        <reason><type>forwarded</type></reason>
+      </policy_evaluated>
+    </row>
+    <identifiers>
+      <header_from>example.com</header_from>
+    </identifiers>
+    <auth_results>
+      <spf>
+        <domain>forwarder.example</domain>
+        <scope>mfrom</scope>
+        <result>softfail</result>
+      </spf>
+    </auth_results>
+  </record>
+</feedback>`
+
+func TestParseFormats(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(sampleReport))
+	zw.Close()
+
+	var zipped bytes.Buffer
+	arc := zip.NewWriter(&zipped)
+	w, _ := arc.Create("google.com!example.com!1767225600!1767311999.xml")
+	w.Write([]byte(sampleReport))
+	arc.Close()
+
+	for name, data := range map[string][]byte{
+		"xml": []byte(sampleReport), "gzip": gz.Bytes(), "zip": zipped.Bytes(),
+	} {
+		rep, err := Parse(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if rep.Metadata.OrgName != "google.com" || rep.Policy.P != "quarantine" ||
+			rep.Policy.Pct != 100 || len(rep.Records) != 2 {
+			t.Fatalf("%s: %+v", name, rep)
+		}
+		want := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		if !rep.Metadata.DateRange.Begin.Equal(want) {
+			t.Fatalf("%s: begin = %v", name, rep.Metadata.DateRange.Begin)
+		}
+		rec := rep.Records[1]
+		if rec.Row.Count != 3 || rec.Row.PolicyEvaluated.Reasons[0].Type != "forwarded" ||
+			rec.AuthResults.SPF[0].Result != "softfail" {
+			t.Fatalf("%s: record = %+v", name, rec)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	if _, err := Parse(strings.NewReader("<html><body/></html>")); !errors.Is(err, ErrNotReport) {
+		t.Fatalf("html: %v", err)
+	}
+	if _, err := Parse(strings.NewReader("")); !errors.Is(err, ErrNotReport) {
+		t.Fatalf("empty: %v", err)
+	}
+	var zipped bytes.Buffer
+	arc := zip.NewWriter(&zipped)
+	arc.Create("readme.txt")
+	arc.Close()
+	if _, err := Parse(&zipped); err == nil || !strings.Contains(err.Error(), "no XML file") {
+		t.Fatalf("zip without xml: %v", err)
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(sampleReport))
+	zw.Close()
+	msg := &types.Message{Attach: []types.Attachment{
+		{Filename: "logo.png", ContentType: "image/png", Reader: strings.NewReader("png")},
+		{Filename: "google.com!example.com!1767225600!1767311999.xml.gz",
+			ContentType: "application/gzip", Reader: &gz},
+	}}
+	reps, err := ParseMessage(msg)
+	if err != nil || len(reps) != 1 || reps[0].Metadata.ReportID != "1234567890" {
+		t.Fatalf("reports = %v, %v", reps, err)
+	}
+	if _, err := ParseMessage(&types.Message{}); !errors.Is(err, ErrNotReport) {
+		t.Fatalf("no attachments: %v", err)
+	}
+}
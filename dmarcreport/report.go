@@ -0,0 +1,185 @@
+package dmarcreport
+
+import (
+	"encoding/xml"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report is a DMARC aggregate report.
+type Report struct {
+	XMLName  xml.Name        `xml:"feedback"`
+	Version  string          `xml:"version"`
+	Metadata Metadata        `xml:"report_metadata"`
+	Policy   PolicyPublished `xml:"policy_published"`
+	Records  []Record        `xml:"record"`
+}
+
+// Metadata identifies the reporter and the reporting period.
+type Metadata struct {
+	OrgName          string    `xml:"org_name"`
+	Email            string    `xml:"email"`
+	ExtraContactInfo string    `xml:"extra_contact_info"`
+	ReportID         string    `xml:"report_id"`
+	DateRange        DateRange `xml:"date_range"`
+	Errors           []string  `xml:"error"`
+}
+
+// DateRange is the period a report covers.
+type DateRange struct {
+	Begin time.Time
+	End   time.Time
+}
+
+// UnmarshalXML implements xml.Unmarshaler, reading the Unix timestamps
+// of <begin> and <end>.
+func (d *DateRange) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var raw struct {
+		Begin string `xml:"begin"`
+		End   string `xml:"end"`
+	}
+	if err := dec.DecodeElement(&raw, &start); err != nil {
+		return err
+	}
+	var err error
+	if d.Begin, err = unixTime(raw.Begin); err != nil {
+		return err
+	}
+	d.End, err = unixTime(raw.End)
+	return err
+}
+
+// unixTime parses a Unix timestamp in seconds; empty is the zero time.
+func unixTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(n, 0).UTC(), nil
+}
+
+// PolicyPublished is the DMARC record of the domain as the reporter saw
+// it.
+type PolicyPublished struct {
+	Domain string `xml:"domain"`
+	ADKIM  string `xml:"adkim"` // DKIM alignment: "r" or "s"
+	ASPF   string `xml:"aspf"`  // SPF alignment: "r" or "s"
+	P      string `xml:"p"`     // none, quarantine or reject
+	SP     string `xml:"sp"`    // subdomain policy
+	Pct    int    `xml:"pct"`
+	FO     string `xml:"fo"`
+}
+
+// Record is the outcome for the messages from one source with the same
+// identifiers and results.
+type Record struct {
+	Row         Row         `xml:"row"`
+	Identifiers Identifiers `xml:"identifiers"`
+	AuthResults AuthResults `xml:"auth_results"`
+}
+
+// Row holds the source and the DMARC evaluation.
+type Row struct {
+	SourceIP        string          `xml:"source_ip"`
+	Count           int             `xml:"count"`
+	PolicyEvaluated PolicyEvaluated `xml:"policy_evaluated"`
+}
+
+// Addr parses SourceIP.
+//
+// Returns:
+//   - netip.Addr: The source address.
+//   - error: The error if SourceIP is not an IP address.
+func (r Row) Addr() (netip.Addr, error) {
+	return netip.ParseAddr(strings.TrimSpace(r.SourceIP))
+}
+
+// PolicyEvaluated is the DMARC result the receiver applied.
+type PolicyEvaluated struct {
+	Disposition string   `xml:"disposition"` // none, quarantine or reject
+	DKIM        string   `xml:"dkim"`        // aligned DKIM: pass or fail
+	SPF         string   `xml:"spf"`         // aligned SPF: pass or fail
+	Reasons     []Reason `xml:"reason"`      // why the policy was overridden
+}
+
+// Pass reports whether the messages passed DMARC, with aligned DKIM or
+// aligned SPF.
+//
+// Returns:
+//   - bool: True if DMARC passed.
+func (p PolicyEvaluated) Pass() bool {
+	return strings.EqualFold(p.DKIM, "pass") || strings.EqualFold(p.SPF, "pass")
+}
+
+// Reason is a local policy override, e.g. "forwarded" or "mailing_list".
+type Reason struct {
+	Type    string `xml:"type"`
+	Comment string `xml:"comment"`
+}
+
+// Identifiers are the domains the messages were evaluated for.
+type Identifiers struct {
+	HeaderFrom   string `xml:"header_from"`
+	EnvelopeFrom string `xml:"envelope_from"`
+	EnvelopeTo   string `xml:"envelope_to"`
+}
+
+// AuthResults are the raw, unaligned DKIM and SPF results.
+type AuthResults struct {
+	DKIM []DKIMResult `xml:"dkim"`
+	SPF  []SPFResult  `xml:"spf"`
+}
+
+// DKIMResult is the verification result of one DKIM signature.
+type DKIMResult struct {
+	Domain      string `xml:"domain"`
+	Selector    string `xml:"selector"`
+	Result      string `xml:"result"` // pass, fail, neutral, none, ...
+	HumanResult string `xml:"human_result"`
+}
+
+// SPFResult is the SPF result of one identity.
+type SPFResult struct {
+	Domain string `xml:"domain"`
+	Scope  string `xml:"scope"`  // mfrom or helo
+	Result string `xml:"result"` // pass, fail, softfail, neutral, none, ...
+}
+
+// Summary totals the messages of a report.
+type Summary struct {
+	Messages    int // all messages
+	Pass        int // passed DMARC
+	Fail        int // failed DMARC
+	Quarantined int // disposition quarantine
+	Rejected    int // disposition reject
+}
+
+// Summary totals the records of r.
+//
+// Returns:
+//   - Summary: The totals.
+func (r *Report) Summary() Summary {
+	var s Summary
+	for _, rec := range r.Records {
+		n := rec.Row.Count
+		s.Messages += n
+		if rec.Row.PolicyEvaluated.Pass() {
+			s.Pass += n
+		} else {
+			s.Fail += n
+		}
+		switch strings.ToLower(rec.Row.PolicyEvaluated.Disposition) {
+		case "quarantine":
+			s.Quarantined += n
+		case "reject":
+			s.Rejected += n
+		}
+	}
+	return s
+}
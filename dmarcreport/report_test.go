@@ -0,0 +1,44 @@
+package dmarcreport
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReportSummary(t *testing.T) {
+	rep, err := Parse(strings.NewReader(sampleReport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := rep.Summary()
+	want := Summary{Messages: 45, Pass: 42, Fail: 3, Quarantined: 3}
+	if got != want {
+		t.Fatalf("summary = %+v, want %+v", got, want)
+	}
+}
+
+func TestRowAddr(t *testing.T) {
+	addr, err := Row{SourceIP: " 2001:db8::1\n"}.Addr()
+	if err != nil || addr.String() != "2001:db8::1" {
+		t.Fatalf("addr = %v, %v", addr, err)
+	}
+	if _, err := (Row{SourceIP: "mail.example.com"}).Addr(); err == nil {
+		t.Fatal("expected error for a host name")
+	}
+}
+
+func TestPolicyEvaluatedPass(t *testing.T) {
+	for _, c := range []struct {
+		dkim, spf string
+		want      bool
+	}{
+		{"pass", "fail", true},
+		{"fail", "PASS", true},
+		{"fail", "fail", false},
+		{"", "", false},
+	} {
+		if got := (PolicyEvaluated{DKIM: c.dkim, SPF: c.spf}).Pass(); got != c.want {
+			t.Errorf("dkim=%q spf=%q: %v", c.dkim, c.spf, got)
+		}
+	}
+}
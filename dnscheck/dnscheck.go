@@ -0,0 +1,301 @@
+package dnscheck
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/aatuh/email/v2/dkim"
+	"github.com/aatuh/email/v2/internal"
+)
+
+// Resolver is the subset of *net.Resolver used for the checks.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+// Config describes the sending setup to check.
+type Config struct {
+	FromDomain string // domain of the From header; required
+
+	// EnvelopeDomain is the MAIL FROM domain SPF applies to; defaults
+	// to FromDomain.
+	EnvelopeDomain string
+	// SendingIP is the address mail leaves from. If nil, the addresses
+	// of SendingHost are checked; with neither, SPF is only checked to
+	// be published.
+	SendingIP   net.IP
+	SendingHost string
+
+	// DKIMSelector enables the DKIM check of DKIMDomain (defaults to
+	// FromDomain). With DKIMKey set, the published key must match it;
+	// otherwise any valid key passes.
+	DKIMSelector string
+	DKIMDomain   string
+	DKIMKey      crypto.PublicKey
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name   string // DNS name queried
+	Record string // record found, if any
+	Err    error  // nil if the check passed
+}
+
+// Report holds the outcome of each check.
+type Report struct {
+	SPF   Result
+	DKIM  Result // empty if no selector is configured
+	DMARC Result
+
+	// SPFResult is the RFC 7208 result for the sending IP: pass, fail,
+	// softfail, neutral, none, permerror or temperror. It is empty if
+	// no sending IP or host is configured.
+	SPFResult string
+	// DMARCPolicy is the p= tag of the DMARC record.
+	DMARCPolicy string
+}
+
+// Err returns the failed checks joined, or nil.
+//
+// Returns:
+//   - error: The failures.
+func (r *Report) Err() error {
+	return errors.Join(r.SPF.Err, r.DKIM.Err, r.DMARC.Err)
+}
+
+// Check runs the SPF, DKIM and DMARC checks for cfg.
+//
+// Parameters:
+//   - ctx: The context.
+//   - r: The resolver; nil uses net.DefaultResolver.
+//   - cfg: The sending setup.
+//
+// Returns:
+//   - *Report: The outcome of each check.
+//   - error: The failed checks joined (see Report.Err), or nil.
+func Check(ctx context.Context, r Resolver, cfg Config) (*Report, error) {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	from := normDomain(cfg.FromDomain)
+	if from == "" {
+		return nil, errors.New("dnscheck: FromDomain is required")
+	}
+	envelope := normDomain(cfg.EnvelopeDomain)
+	if envelope == "" {
+		envelope = from
+	}
+	dkimDomain := normDomain(cfg.DKIMDomain)
+	if dkimDomain == "" {
+		dkimDomain = from
+	}
+
+	rep := &Report{}
+	checkSPF(ctx, r, cfg, envelope, rep)
+	if cfg.DKIMSelector != "" {
+		rep.DKIM = checkDKIM(ctx, r, cfg.DKIMSelector, dkimDomain, cfg.DKIMKey)
+	}
+	checkDMARC(ctx, r, from, envelope, dkimDomain, cfg.DKIMSelector != "", rep)
+	return rep, rep.Err()
+}
+
+// checkSPF fills the SPF part of rep.
+func checkSPF(ctx context.Context, r Resolver, cfg Config, domain string, rep *Report) {
+	rep.SPF.Name = domain
+	rec, err := spfRecord(ctx, r, domain)
+	rep.SPF.Record = rec
+	if err != nil {
+		rep.SPF.Err = fmt.Errorf("spf: %w", err)
+		return
+	}
+	if rec == "" {
+		rep.SPFResult = "none"
+		rep.SPF.Err = fmt.Errorf("spf: no v=spf1 record at %s", domain)
+		return
+	}
+
+	ips := []net.IP{cfg.SendingIP}
+	if cfg.SendingIP == nil {
+		if cfg.SendingHost == "" {
+			return
+		}
+		addrs, err := r.LookupIPAddr(ctx, cfg.SendingHost)
+		if err != nil {
+			rep.SPF.Err = fmt.Errorf("spf: lookup %s: %w", cfg.SendingHost, err)
+			return
+		}
+		ips = ips[:0]
+		for _, a := range addrs {
+			ips = append(ips, a.IP)
+		}
+	}
+	for _, ip := range ips {
+		e := &spfEval{r: r}
+		res, err := e.check(ctx, ip, domain)
+		rep.SPFResult = res
+		if res != "pass" {
+			msg := fmt.Sprintf("spf: %s for %s at %s", res, ip, domain)
+			if err != nil {
+				msg += ": " + err.Error()
+			}
+			rep.SPF.Err = errors.New(msg)
+			return
+		}
+	}
+}
+
+// checkDKIM verifies the key published for selector at domain.
+func checkDKIM(
+	ctx context.Context,
+	r Resolver,
+	selector string,
+	domain string,
+	want crypto.PublicKey,
+) Result {
+	res := Result{Name: dkim.RecordName(selector, domain)}
+	txts, err := r.LookupTXT(ctx, res.Name)
+	if err != nil {
+		res.Err = fmt.Errorf("dkim: lookup %s: %w", res.Name, err)
+		return res
+	}
+	var tags map[string]string
+	for _, txt := range txts {
+		t := internal.ParseTags(txt)
+		if _, ok := t["p"]; ok && (t["v"] == "" || t["v"] == "DKIM1") {
+			res.Record, tags = txt, t
+			break
+		}
+	}
+	if tags == nil {
+		res.Err = fmt.Errorf("dkim: no key record at %s", res.Name)
+		return res
+	}
+	p := strings.Join(strings.Fields(tags["p"]), "")
+	if p == "" {
+		res.Err = fmt.Errorf("dkim: key at %s is revoked (empty p=)", res.Name)
+		return res
+	}
+	if _, err := base64.StdEncoding.DecodeString(p); err != nil {
+		res.Err = fmt.Errorf("dkim: invalid p= at %s: %w", res.Name, err)
+		return res
+	}
+	if want == nil {
+		return res
+	}
+	wantRec, err := dkim.TXTRecord(want)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	wantTags := internal.ParseTags(wantRec)
+	k := tags["k"]
+	if k == "" {
+		k = "rsa"
+	}
+	if !strings.EqualFold(k, wantTags["k"]) || p != wantTags["p"] {
+		res.Err = fmt.Errorf("dkim: key at %s does not match the signing key",
+			res.Name)
+	}
+	return res
+}
+
+// checkDMARC fills the DMARC part of rep from the SPF and DKIM results.
+func checkDMARC(
+	ctx context.Context,
+	r Resolver,
+	from string,
+	envelope string,
+	dkimDomain string,
+	dkimChecked bool,
+	rep *Report,
+) {
+	names := []string{"_dmarc." + from}
+	if org := internal.OrgDomain(from); org != from {
+		names = append(names, "_dmarc."+org)
+	}
+	var tags map[string]string
+	for _, name := range names {
+		rep.DMARC.Name = name
+		txts, err := r.LookupTXT(ctx, name)
+		if err != nil && !isNotFound(err) {
+			rep.DMARC.Err = fmt.Errorf("dmarc: lookup %s: %w", name, err)
+			return
+		}
+		for _, txt := range txts {
+			if t := internal.ParseTags(txt); t["v"] == "DMARC1" {
+				rep.DMARC.Record, tags = txt, t
+				break
+			}
+		}
+		if tags != nil {
+			break
+		}
+	}
+	if tags == nil {
+		rep.DMARC.Name = names[0]
+		rep.DMARC.Err = fmt.Errorf("dmarc: no v=DMARC1 record for %s", from)
+		return
+	}
+	rep.DMARCPolicy = strings.ToLower(tags["p"])
+	switch rep.DMARCPolicy {
+	case "none", "quarantine", "reject":
+	default:
+		rep.DMARC.Err = fmt.Errorf("dmarc: invalid policy p=%q at %s",
+			tags["p"], rep.DMARC.Name)
+		return
+	}
+
+	dkimOK := dkimChecked && rep.DKIM.Err == nil &&
+		aligned(from, dkimDomain, tags["adkim"] == "s")
+	spfOK := rep.SPFResult == "pass" &&
+		aligned(from, envelope, tags["aspf"] == "s")
+	if dkimOK || spfOK {
+		return
+	}
+	var why []string
+	switch {
+	case !dkimChecked:
+		why = append(why, "DKIM not configured")
+	case rep.DKIM.Err != nil:
+		why = append(why, "DKIM check failed")
+	default:
+		why = append(why, fmt.Sprintf("DKIM d=%s not aligned", dkimDomain))
+	}
+	switch {
+	case rep.SPFResult == "":
+		why = append(why, "SPF not evaluated (no sending IP)")
+	case rep.SPFResult != "pass":
+		why = append(why, "SPF "+rep.SPFResult)
+	default:
+		why = append(why, fmt.Sprintf("SPF domain %s not aligned", envelope))
+	}
+	rep.DMARC.Err = fmt.Errorf("dmarc: %s would fail: %s", from,
+		strings.Join(why, ", "))
+}
+
+// aligned applies DMARC alignment: equal domains if strict, else the
+// same organizational domain.
+func aligned(from, d string, strict bool) bool {
+	if strict {
+		return from == d
+	}
+	return internal.DomainsAligned(from, d)
+}
+
+// normDomain lowercases d and strips a trailing dot.
+func normDomain(d string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(d)), ".")
+}
+
+// isNotFound reports whether err is an NXDOMAIN or no-data answer.
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
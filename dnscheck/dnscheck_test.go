@@ -0,0 +1,105 @@
+package dnscheck
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/aatuh/email/v2/dkim"
+)
+
+func TestCheck(t *testing.T) {
+	key, err := dkim.GenerateKey(dkim.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := dkim.TXTRecord(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := dkim.GenerateKey(dkim.Ed25519, 0)
+	r := &fakeResolver{
+		txt: map[string][]string{
+			"example.com":                {"v=spf1 ip4:192.0.2.0/24 -all"},
+			"bounces.example.com":        {"v=spf1 ip4:192.0.2.0/24 -all"},
+			"s1._domainkey.example.com":  {rec},
+			"old._domainkey.example.com": {"v=DKIM1; p="},
+			"_dmarc.example.com":         {"v=DMARC1; p=reject; adkim=s"},
+			"esp.test":                   {"v=spf1 ip4:198.51.100.0/24 -all"},
+			"s1._domainkey.esp.test":     {rec},
+			"other.co.uk":                {"v=spf1 ip4:192.0.2.0/24 -all"},
+			"_dmarc.shop.co.uk":          {"v=DMARC1; p=reject"},
+		},
+		ip: map[string][]string{"mta.example.com": {"192.0.2.7", "192.0.2.8"}},
+	}
+	ctx := context.Background()
+
+	rep, err := Check(ctx, r, Config{
+		FromDomain: "example.com", SendingHost: "mta.example.com",
+		DKIMSelector: "s1", DKIMKey: key.Public(),
+	})
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if rep.SPFResult != "pass" || rep.DMARCPolicy != "reject" ||
+		rep.DKIM.Name != "s1._domainkey.example.com" {
+		t.Fatalf("report: %+v", rep)
+	}
+
+	// SPF alone aligns in relaxed mode for a subdomain envelope.
+	if _, err := Check(ctx, r, Config{
+		FromDomain: "example.com", EnvelopeDomain: "bounces.example.com",
+		SendingIP: net.ParseIP("192.0.2.9"),
+	}); err != nil {
+		t.Fatalf("spf-only check: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{"wrong key", Config{
+			FromDomain: "example.com", SendingIP: net.ParseIP("192.0.2.9"),
+			DKIMSelector: "s1", DKIMKey: other.Public(),
+		}, []string{"does not match the signing key"}},
+		{"revoked", Config{
+			FromDomain: "example.com", DKIMSelector: "old",
+		}, []string{"revoked", "DKIM check failed", "SPF not evaluated"}},
+		{"spf fail", Config{
+			FromDomain: "example.com", SendingIP: net.ParseIP("203.0.113.1"),
+		}, []string{"spf: fail for 203.0.113.1", "DKIM not configured"}},
+		{"strict dkim alignment", Config{
+			FromDomain: "example.com", DKIMDomain: "esp.test",
+			DKIMSelector: "s1", DKIMKey: key.Public(),
+		}, []string{"DKIM d=esp.test not aligned"}},
+		{"public suffix", Config{
+			FromDomain: "shop.co.uk", EnvelopeDomain: "other.co.uk",
+			SendingIP: net.ParseIP("192.0.2.9"),
+		}, []string{"SPF domain other.co.uk not aligned"}},
+		{"no spf record", Config{FromDomain: "nothing.test"},
+			[]string{"no v=spf1 record", "no v=DMARC1 record"}},
+	}
+	for _, c := range cases {
+		_, err := Check(ctx, r, c.cfg)
+		if err == nil {
+			t.Errorf("%s: expected error", c.name)
+			continue
+		}
+		for _, w := range c.want {
+			if !strings.Contains(err.Error(), w) {
+				t.Errorf("%s: %q missing from %v", c.name, w, err)
+			}
+		}
+	}
+
+	// The organizational domain's record applies to subdomains.
+	rep, err = Check(ctx, r, Config{
+		FromDomain: "mail.example.com", EnvelopeDomain: "bounces.example.com",
+		SendingIP: net.ParseIP("192.0.2.9"),
+	})
+	if err != nil || rep.DMARC.Name != "_dmarc.example.com" {
+		t.Fatalf("org domain fallback: %v %+v", err, rep)
+	}
+}
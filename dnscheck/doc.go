@@ -0,0 +1,7 @@
+// Package dnscheck verifies the DNS side of a sending setup before mail
+// goes out: that the sending IP passes the SPF policy of the envelope
+// domain, that the DKIM selector publishes the expected key, and that
+// the From domain's DMARC policy would pass with aligned SPF or DKIM.
+// Run it at service startup to fail fast on misconfiguration, or from
+// the dnscheck command.
+package dnscheck
@@ -0,0 +1,266 @@
+package dnscheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// maxSPFLookups is the RFC 7208 4.6.4 limit on DNS querying terms.
+const maxSPFLookups = 10
+
+// errTemp marks DNS failures that make the SPF result temperror.
+var errTemp = errors.New("temporary DNS error")
+
+// spfRecord returns the v=spf1 record of domain, or "" if none.
+func spfRecord(ctx context.Context, r Resolver, domain string) (string, error) {
+	txts, err := r.LookupTXT(ctx, domain)
+	if err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("lookup %s: %w", domain, err)
+	}
+	var rec string
+	for _, txt := range txts {
+		if f := strings.Fields(txt); len(f) > 0 && strings.EqualFold(f[0], "v=spf1") {
+			if rec != "" {
+				return "", fmt.Errorf("multiple v=spf1 records at %s", domain)
+			}
+			rec = txt
+		}
+	}
+	return rec, nil
+}
+
+// spfEval evaluates SPF policies (RFC 7208) for one IP, counting DNS
+// lookups across includes. Macros and ptr are not supported: terms
+// using macros are a permerror and ptr never matches.
+type spfEval struct {
+	r       Resolver
+	lookups int
+}
+
+// check returns the SPF result for ip at domain, with the reason for
+// permerror and temperror.
+func (e *spfEval) check(ctx context.Context, ip net.IP, domain string) (string, error) {
+	rec, err := spfRecord(ctx, e.r, domain)
+	if err != nil {
+		if strings.Contains(err.Error(), "multiple") {
+			return "permerror", err
+		}
+		return "temperror", err
+	}
+	if rec == "" {
+		return "none", nil
+	}
+	var redirect string
+	for _, term := range strings.Fields(rec)[1:] {
+		if k, v, ok := strings.Cut(term, "="); ok && !strings.ContainsAny(k, ":/") {
+			if strings.EqualFold(k, "redirect") {
+				redirect = v
+			}
+			continue
+		}
+		qual := byte('+')
+		if strings.IndexByte("+-~?", term[0]) >= 0 {
+			qual, term = term[0], term[1:]
+		}
+		match, err := e.match(ctx, ip, domain, term)
+		if err != nil {
+			if errors.Is(err, errTemp) {
+				return "temperror", err
+			}
+			return "permerror", err
+		}
+		if match {
+			return qualifierResult(qual), nil
+		}
+	}
+	if redirect != "" {
+		if err := e.count(); err != nil {
+			return "permerror", err
+		}
+		res, err := e.check(ctx, ip, redirect)
+		if res == "none" {
+			return "permerror", fmt.Errorf("redirect to %s without SPF record", redirect)
+		}
+		return res, err
+	}
+	return "neutral", nil
+}
+
+// match reports whether the mechanism term matches ip.
+func (e *spfEval) match(ctx context.Context, ip net.IP, domain, term string) (bool, error) {
+	if strings.Contains(term, "%") {
+		return false, fmt.Errorf("macros are not supported: %s", term)
+	}
+	name, arg := term, ""
+	if i := strings.IndexAny(term, ":/"); i >= 0 {
+		name, arg = term[:i], term[i:]
+	}
+	switch strings.ToLower(name) {
+	case "all":
+		return true, nil
+	case "ip4", "ip6":
+		return matchCIDR(ip, strings.TrimPrefix(arg, ":"))
+	case "a", "mx":
+		target, v4, v6, err := parseDomainCIDR(arg, domain)
+		if err != nil {
+			return false, err
+		}
+		if err := e.count(); err != nil {
+			return false, err
+		}
+		hosts := []string{target}
+		if strings.EqualFold(name, "mx") {
+			mxs, err := e.r.LookupMX(ctx, target)
+			if err != nil && !isNotFound(err) {
+				return false, fmt.Errorf("%w: mx %s: %v", errTemp, target, err)
+			}
+			if len(mxs) > maxSPFLookups {
+				return false, fmt.Errorf("too many MX records for %s", target)
+			}
+			hosts = hosts[:0]
+			for _, mx := range mxs {
+				hosts = append(hosts, mx.Host)
+			}
+		}
+		for _, h := range hosts {
+			addrs, err := e.r.LookupIPAddr(ctx, h)
+			if err != nil {
+				if isNotFound(err) {
+					continue
+				}
+				return false, fmt.Errorf("%w: %s: %v", errTemp, h, err)
+			}
+			for _, a := range addrs {
+				if inPrefix(ip, a.IP, v4, v6) {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	case "include":
+		target := strings.TrimPrefix(arg, ":")
+		if target == "" {
+			return false, errors.New("include without domain")
+		}
+		if err := e.count(); err != nil {
+			return false, err
+		}
+		res, err := e.check(ctx, ip, target)
+		switch res {
+		case "pass":
+			return true, nil
+		case "fail", "softfail", "neutral":
+			return false, nil
+		case "none":
+			return false, fmt.Errorf("include of %s without SPF record", target)
+		}
+		return false, err
+	case "exists":
+		target := strings.TrimPrefix(arg, ":")
+		if err := e.count(); err != nil {
+			return false, err
+		}
+		addrs, err := e.r.LookupIPAddr(ctx, target)
+		if err != nil && !isNotFound(err) {
+			return false, fmt.Errorf("%w: %s: %v", errTemp, target, err)
+		}
+		return len(addrs) > 0, nil
+	case "ptr":
+		if err := e.count(); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown mechanism %q", term)
+}
+
+// count records a DNS querying term.
+func (e *spfEval) count() error {
+	e.lookups++
+	if e.lookups > maxSPFLookups {
+		return fmt.Errorf("more than %d DNS lookups", maxSPFLookups)
+	}
+	return nil
+}
+
+// qualifierResult maps a qualifier to its result.
+func qualifierResult(q byte) string {
+	switch q {
+	case '-':
+		return "fail"
+	case '~':
+		return "softfail"
+	case '?':
+		return "neutral"
+	}
+	return "pass"
+}
+
+// matchCIDR reports whether ip is in the ip4/ip6 network s.
+func matchCIDR(ip net.IP, s string) (bool, error) {
+	if !strings.Contains(s, "/") {
+		n := net.ParseIP(s)
+		if n == nil {
+			return false, fmt.Errorf("invalid address %q", s)
+		}
+		return n.Equal(ip), nil
+	}
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		return false, err
+	}
+	return network.Contains(ip), nil
+}
+
+// parseDomainCIDR parses the ":domain/v4//v6" argument of a and mx.
+func parseDomainCIDR(arg, domain string) (target string, v4, v6 int, err error) {
+	target, v4, v6 = domain, 32, 128
+	if rest, ok := strings.CutPrefix(arg, ":"); ok {
+		target, arg, _ = strings.Cut(rest, "/")
+		if arg != "" {
+			arg = "/" + arg
+		}
+	}
+	if arg == "" {
+		return target, v4, v6, nil
+	}
+	// arg is "/v4", "//v6" or "/v4//v6".
+	var p4, p6 string
+	if rest, ok := strings.CutPrefix(arg, "//"); ok {
+		p6 = rest
+	} else {
+		p4, p6, _ = strings.Cut(strings.TrimPrefix(arg, "/"), "//")
+	}
+	if p4 != "" {
+		if v4, err = strconv.Atoi(p4); err != nil || v4 < 0 || v4 > 32 {
+			return "", 0, 0, fmt.Errorf("invalid prefix %q", p4)
+		}
+	}
+	if p6 != "" {
+		if v6, err = strconv.Atoi(p6); err != nil || v6 < 0 || v6 > 128 {
+			return "", 0, 0, fmt.Errorf("invalid prefix %q", p6)
+		}
+	}
+	return target, v4, v6, nil
+}
+
+// inPrefix reports whether ip and addr share the v4 or v6 prefix, as
+// fits their family.
+func inPrefix(ip, addr net.IP, v4, v6 int) bool {
+	if ip4, a4 := ip.To4(), addr.To4(); ip4 != nil || a4 != nil {
+		if ip4 == nil || a4 == nil {
+			return false
+		}
+		m := net.CIDRMask(v4, 32)
+		return ip4.Mask(m).Equal(a4.Mask(m))
+	}
+	m := net.CIDRMask(v6, 128)
+	return ip.Mask(m).Equal(addr.Mask(m))
+}
@@ -0,0 +1,135 @@
+package dnscheck
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+// fakeResolver answers from maps; missing names are NXDOMAIN.
+type fakeResolver struct {
+	txt map[string][]string
+	ip  map[string][]string
+	mx  map[string][]string
+}
+
+func notFound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if v, ok := r.txt[name]; ok {
+		return v, nil
+	}
+	return nil, notFound(name)
+}
+
+func (r *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	v, ok := r.ip[host]
+	if !ok {
+		return nil, notFound(host)
+	}
+	var out []net.IPAddr
+	for _, s := range v {
+		out = append(out, net.IPAddr{IP: net.ParseIP(s)})
+	}
+	return out, nil
+}
+
+func (r *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	v, ok := r.mx[name]
+	if !ok {
+		return nil, notFound(name)
+	}
+	var out []*net.MX
+	for _, h := range v {
+		out = append(out, &net.MX{Host: h, Pref: 10})
+	}
+	return out, nil
+}
+
+func TestSPFEval(t *testing.T) {
+	r := &fakeResolver{
+		txt: map[string][]string{
+			"example.com":       {"v=spf1 ip4:192.0.2.0/24 include:_spf.esp.test a:relay.example.com/30 mx -all"},
+			"_spf.esp.test":     {"v=spf1 ip6:2001:db8::/32 ~all"},
+			"soft.test":         {"v=spf1 ~all"},
+			"redir.test":        {"v=spf1 redirect=example.com"},
+			"loop.test":         {"v=spf1 include:loop.test -all"},
+			"macro.test":        {"v=spf1 exists:%{i}.bl.test -all"},
+			"dup.test":          {"v=spf1 -all", "v=spf1 +all"},
+			"broken-inc.test":   {"v=spf1 include:nothing.test -all"},
+			"v6only.test":       {"v=spf1 a//64 -all"},
+			"unrelated.test":    {"google-site-verification=abc"},
+			"exists.test":       {"v=spf1 exists:ok.test -all"},
+			"neutral-end.test":  {"v=spf1 ip4:203.0.113.1"},
+			"qualified.test":    {"v=spf1 ?ip4:203.0.113.7 -all"},
+			"unknown-mech.test": {"v=spf1 foo:bar -all"},
+		},
+		ip: map[string][]string{
+			"relay.example.com": {"198.51.100.4"},
+			"mx1.example.com":   {"203.0.113.9"},
+			"v6only.test":       {"2001:db8:1::1"},
+			"ok.test":           {"127.0.0.2"},
+		},
+		mx: map[string][]string{"example.com": {"mx1.example.com"}},
+	}
+	cases := []struct {
+		domain, ip, want string
+	}{
+		{"example.com", "192.0.2.10", "pass"},
+		{"example.com", "2001:db8::5", "pass"},
+		{"example.com", "198.51.100.6", "pass"},
+		{"example.com", "198.51.100.8", "fail"},
+		{"example.com", "203.0.113.9", "pass"},
+		{"example.com", "203.0.113.10", "fail"},
+		{"soft.test", "192.0.2.1", "softfail"},
+		{"redir.test", "192.0.2.10", "pass"},
+		{"loop.test", "192.0.2.1", "permerror"},
+		{"macro.test", "192.0.2.1", "permerror"},
+		{"dup.test", "192.0.2.1", "permerror"},
+		{"broken-inc.test", "192.0.2.1", "permerror"},
+		{"v6only.test", "2001:db8:1::ffff", "pass"},
+		{"unrelated.test", "192.0.2.1", "none"},
+		{"missing.test", "192.0.2.1", "none"},
+		{"exists.test", "192.0.2.1", "pass"},
+		{"neutral-end.test", "192.0.2.1", "neutral"},
+		{"qualified.test", "203.0.113.7", "neutral"},
+		{"unknown-mech.test", "192.0.2.1", "permerror"},
+	}
+	for _, c := range cases {
+		e := &spfEval{r: r}
+		got, err := e.check(context.Background(), net.ParseIP(c.ip), c.domain)
+		if got != c.want {
+			t.Errorf("%s %s: got %s (%v), want %s", c.domain, c.ip, got, err, c.want)
+		}
+	}
+}
+
+func TestParseDomainCIDR(t *testing.T) {
+	cases := []struct {
+		arg        string
+		target     string
+		v4, v6     int
+		shouldFail bool
+	}{
+		{"", "d.test", 32, 128, false},
+		{":x.test", "x.test", 32, 128, false},
+		{":x.test/24", "x.test", 24, 128, false},
+		{":x.test//64", "x.test", 32, 64, false},
+		{":x.test/24//64", "x.test", 24, 64, false},
+		{"/28", "d.test", 28, 128, false},
+		{"//48", "d.test", 32, 48, false},
+		{"/33", "", 0, 0, true},
+	}
+	for _, c := range cases {
+		target, v4, v6, err := parseDomainCIDR(c.arg, "d.test")
+		if (err != nil) != c.shouldFail {
+			t.Errorf("%q: err = %v", c.arg, err)
+			continue
+		}
+		if !c.shouldFail && (target != c.target || v4 != c.v4 || v6 != c.v6) {
+			t.Errorf("%q: got %s %d %d", c.arg, target, v4, v6)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+// Package fetch reads incoming mail by polling a mailbox over IMAP or
+// POP3. A Fetcher remembers which messages it has handed out, by UID,
+// and yields each new one once, as raw bytes or parsed into a
+// types.Message. Between polls it waits with IMAP IDLE when the source
+// supports it, so replies are picked up as they arrive; this is the
+// receiving half of a reply-handling bot.
+package fetch
@@ -0,0 +1,242 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"iter"
+	"sync"
+	"time"
+
+	"github.com/aatuh/email/v2/inbound"
+	"github.com/aatuh/email/v2/types"
+)
+
+// Message is a message read from a mailbox.
+type Message struct {
+	// UID identifies the message within its source; it is stable across
+	// polls and is the key for deduplication.
+	UID string
+	Raw []byte
+}
+
+// Parse parses the raw message with inbound.Parse.
+//
+// Returns:
+//   - *types.Message: The parsed message.
+//   - error: The error if the message cannot be parsed.
+func (m Message) Parse() (*types.Message, error) {
+	return inbound.Parse(bytes.NewReader(m.Raw))
+}
+
+// Source is a mailbox to poll.
+type Source interface {
+	// List returns the UIDs of the messages to consider, oldest first.
+	List(ctx context.Context) ([]string, error)
+	// Fetch downloads the messages with uids. UIDs that have vanished
+	// since List are skipped.
+	Fetch(ctx context.Context, uids []string) ([]Message, error)
+}
+
+// Waiter is implemented by sources that can wait for new mail, such as
+// IMAP with IDLE. Wait returns nil when mail may have arrived.
+type Waiter interface {
+	Wait(ctx context.Context) error
+}
+
+// Acker is implemented by sources that record on the server that a
+// message was handled, such as IMAPSource with MarkSeen. Messages calls
+// Ack for each message after yielding it, so a message is not flagged
+// before the caller has it.
+type Acker interface {
+	Ack(ctx context.Context, uid string) error
+}
+
+// SeenStore remembers the UIDs already handed out. Implementations must
+// be safe for concurrent use.
+type SeenStore interface {
+	// Seen reports whether uid was marked.
+	Seen(ctx context.Context, uid string) (bool, error)
+	// MarkSeen marks uid.
+	MarkSeen(ctx context.Context, uid string) error
+}
+
+// MemorySeen is an in-memory SeenStore. It is forgotten on restart and
+// grows with every message, so pair it with a source that only lists
+// new mail, e.g. IMAP UNSEEN or POP3 with Delete.
+type MemorySeen struct {
+	mu   sync.Mutex
+	uids map[string]struct{}
+}
+
+// NewMemorySeen creates an empty in-memory seen store.
+//
+// Returns:
+//   - *MemorySeen: The store.
+func NewMemorySeen() *MemorySeen {
+	return &MemorySeen{uids: map[string]struct{}{}}
+}
+
+// Seen reports whether uid was marked.
+//
+// Parameters:
+//   - ctx: The context.
+//   - uid: The UID.
+//
+// Returns:
+//   - bool: True if uid was marked.
+//   - error: Always nil.
+func (s *MemorySeen) Seen(ctx context.Context, uid string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.uids[uid]
+	return ok, nil
+}
+
+// MarkSeen marks uid.
+//
+// Parameters:
+//   - ctx: The context.
+//   - uid: The UID.
+//
+// Returns:
+//   - error: Always nil.
+func (s *MemorySeen) MarkSeen(ctx context.Context, uid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uids[uid] = struct{}{}
+	return nil
+}
+
+// Config configures a Fetcher.
+type Config struct {
+	Source Source
+	// Seen defaults to NewMemorySeen().
+	Seen SeenStore
+	// Interval is the pause between polls when the source cannot wait
+	// for new mail, or waiting failed; defaults to one minute.
+	Interval time.Duration
+	// IdleTimeout bounds each Wait so the session is renewed before
+	// servers drop it; defaults to 25 minutes.
+	IdleTimeout time.Duration
+}
+
+// Fetcher polls a Source and yields each message once.
+type Fetcher struct {
+	cfg Config
+}
+
+// New creates a Fetcher.
+//
+// Parameters:
+//   - cfg: The config.
+//
+// Returns:
+//   - *Fetcher: The fetcher.
+//   - error: The error if cfg has no Source.
+func New(cfg Config) (*Fetcher, error) {
+	if cfg.Source == nil {
+		return nil, errors.New("fetch: config has no Source")
+	}
+	if cfg.Seen == nil {
+		cfg.Seen = NewMemorySeen()
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 25 * time.Minute
+	}
+	return &Fetcher{cfg: cfg}, nil
+}
+
+// Poll lists the source once and downloads the messages not seen yet.
+// It does not mark or ack them; Messages does so as it yields them.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - []Message: The new messages, oldest first.
+//   - error: The error if the source or seen store fails.
+func (f *Fetcher) Poll(ctx context.Context) ([]Message, error) {
+	uids, err := f.cfg.Source.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var fresh []string
+	for _, uid := range uids {
+		seen, err := f.cfg.Seen.Seen(ctx, uid)
+		if err != nil {
+			return nil, err
+		}
+		if !seen {
+			fresh = append(fresh, uid)
+		}
+	}
+	if len(fresh) == 0 {
+		return nil, nil
+	}
+	return f.cfg.Source.Fetch(ctx, fresh)
+}
+
+// Messages returns an iterator over new messages. It polls, yields
+// each unseen message and then marks it seen, then waits for more mail,
+// until ctx ends or the loop is broken. Errors are yielded with a zero
+// Message and polling goes on, so the caller decides whether to stop.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - iter.Seq2[Message, error]: The messages.
+func (f *Fetcher) Messages(ctx context.Context) iter.Seq2[Message, error] {
+	return func(yield func(Message, error) bool) {
+		for ctx.Err() == nil {
+			msgs, err := f.Poll(ctx)
+			if err != nil && ctx.Err() == nil && !yield(Message{}, err) {
+				return
+			}
+			for _, msg := range msgs {
+				more := yield(msg, nil)
+				if err := f.mark(ctx, msg.UID); err != nil && more {
+					more = yield(Message{}, err)
+				}
+				if !more {
+					return
+				}
+			}
+			f.wait(ctx)
+		}
+	}
+}
+
+// mark marks uid seen in the store and, for an Acker, at the source.
+func (f *Fetcher) mark(ctx context.Context, uid string) error {
+	if err := f.cfg.Seen.MarkSeen(ctx, uid); err != nil {
+		return err
+	}
+	if a, ok := f.cfg.Source.(Acker); ok {
+		return a.Ack(ctx, uid)
+	}
+	return nil
+}
+
+// wait blocks until new mail may have arrived or ctx ends.
+func (f *Fetcher) wait(ctx context.Context) {
+	if w, ok := f.cfg.Source.(Waiter); ok {
+		wctx, cancel := context.WithTimeout(ctx, f.cfg.IdleTimeout)
+		err := w.Wait(wctx)
+		cancel()
+		if err == nil || errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+			return
+		}
+		// Waiting is unsupported or broken; fall back to polling.
+	}
+	t := time.NewTimer(f.cfg.Interval)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
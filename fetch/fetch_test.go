@@ -0,0 +1,147 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSource serves messages from memory and records fetches.
+type fakeSource struct {
+	mu      sync.Mutex
+	msgs    []Message
+	listErr error
+	fetched [][]string
+}
+
+func (s *fakeSource) add(uid, raw string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.msgs = append(s.msgs, Message{UID: uid, Raw: []byte(raw)})
+}
+
+func (s *fakeSource) List(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listErr != nil {
+		err := s.listErr
+		s.listErr = nil
+		return nil, err
+	}
+	var uids []string
+	for _, m := range s.msgs {
+		uids = append(uids, m.UID)
+	}
+	return uids, nil
+}
+
+func (s *fakeSource) Fetch(ctx context.Context, uids []string) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetched = append(s.fetched, uids)
+	var out []Message
+	for _, uid := range uids {
+		for _, m := range s.msgs {
+			if m.UID == uid {
+				out = append(out, m)
+			}
+		}
+	}
+	return out, nil
+}
+
+// waitingSource wakes Wait when a message is added.
+type waitingSource struct {
+	fakeSource
+	wake chan struct{}
+}
+
+func (s *waitingSource) Wait(ctx context.Context) error {
+	select {
+	case <-s.wake:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestMessagesDedupAndWait(t *testing.T) {
+	src := &waitingSource{wake: make(chan struct{}, 1)}
+	src.add("1", "Subject: One\r\n\r\nfirst\r\n")
+	f, err := New(Config{Source: src, Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var got []string
+	for msg, err := range f.Messages(ctx) {
+		if err != nil {
+			t.Fatalf("messages: %v", err)
+		}
+		got = append(got, msg.UID)
+		if len(got) == 1 {
+			parsed, err := msg.Parse()
+			if err != nil || parsed.Subject != "One" {
+				t.Fatalf("parse: %v %+v", err, parsed)
+			}
+			src.add("2", "Subject: Two\r\n\r\nsecond\r\n")
+			src.wake <- struct{}{}
+		}
+		if len(got) == 2 {
+			break
+		}
+	}
+	if len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Fatalf("unexpected messages %q", got)
+	}
+	// The second poll fetched only the new message.
+	if len(src.fetched) != 2 || len(src.fetched[1]) != 1 || src.fetched[1][0] != "2" {
+		t.Fatalf("unexpected fetches %q", src.fetched)
+	}
+	if seen, _ := f.cfg.Seen.Seen(ctx, "2"); !seen {
+		t.Fatalf("expected message 2 marked seen")
+	}
+}
+
+func TestMessagesYieldsErrorsAndPolls(t *testing.T) {
+	src := &fakeSource{listErr: errors.New("boom")}
+	src.add("1", "Subject: One\r\n\r\nx\r\n")
+	f, err := New(Config{Source: src, Interval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs, msgs int
+	for msg, err := range f.Messages(context.Background()) {
+		if err != nil {
+			errs++
+			continue
+		}
+		msgs++
+		if msg.UID != "1" {
+			t.Fatalf("unexpected message %q", msg.UID)
+		}
+		break
+	}
+	if errs != 1 || msgs != 1 {
+		t.Fatalf("got %d errors and %d messages", errs, msgs)
+	}
+}
+
+func TestMessagesStopsWithContext(t *testing.T) {
+	f, err := New(Config{Source: &fakeSource{}, Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	for range f.Messages(ctx) {
+		t.Fatalf("unexpected message")
+	}
+	if _, err := New(Config{}); err == nil {
+		t.Fatalf("expected missing source error")
+	}
+}
@@ -0,0 +1,131 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aatuh/email/v2/imap"
+)
+
+// IMAPSource reads a mailbox over IMAP. Its UIDs combine the mailbox's
+// UIDVALIDITY and the message UID, so a rebuilt mailbox is read afresh.
+// It waits for new mail with IDLE.
+type IMAPSource struct {
+	Client *imap.IMAP
+	// Mailbox defaults to "INBOX".
+	Mailbox string
+	// Criteria selects the messages to read; defaults to "UNSEEN".
+	Criteria string
+	// MarkSeen sets \Seen on each message once it has been yielded (see
+	// Ack), so it drops out of UNSEEN and shows as read in other
+	// clients.
+	MarkSeen bool
+}
+
+// List returns the UIDs of the messages matching Criteria.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - []string: The UIDs, as "uidvalidity.uid".
+//   - error: The error if the search fails.
+func (s *IMAPSource) List(ctx context.Context) ([]string, error) {
+	validity, uids, err := s.Client.Search(ctx, s.mailbox(), s.criteria())
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, len(uids))
+	for i, uid := range uids {
+		out[i] = imapUID(validity, uid)
+	}
+	return out, nil
+}
+
+// Fetch downloads the messages with uids. It does not flag them; see
+// Ack.
+//
+// Parameters:
+//   - ctx: The context.
+//   - uids: UIDs returned by List.
+//
+// Returns:
+//   - []Message: The messages.
+//   - error: The error if the fetch fails.
+func (s *IMAPSource) Fetch(ctx context.Context, uids []string) ([]Message, error) {
+	keys := make(map[uint32]string, len(uids))
+	nums := make([]uint32, 0, len(uids))
+	for _, key := range uids {
+		_, n, _ := strings.Cut(key, ".")
+		uid, err := strconv.ParseUint(n, 10, 32)
+		if err != nil {
+			continue
+		}
+		keys[uint32(uid)] = key
+		nums = append(nums, uint32(uid))
+	}
+	fetched, err := s.Client.Fetch(ctx, s.mailbox(), nums)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Message, 0, len(fetched))
+	for _, m := range fetched {
+		if key, ok := keys[m.UID]; ok {
+			out = append(out, Message{UID: key, Raw: m.Raw})
+		}
+	}
+	return out, nil
+}
+
+// Ack flags the message with uid \Seen when MarkSeen is set. The
+// Fetcher calls it after yielding the message.
+//
+// Parameters:
+//   - ctx: The context.
+//   - uid: A UID returned by List.
+//
+// Returns:
+//   - error: The error if the flag cannot be set.
+func (s *IMAPSource) Ack(ctx context.Context, uid string) error {
+	if !s.MarkSeen {
+		return nil
+	}
+	_, n, _ := strings.Cut(uid, ".")
+	num, err := strconv.ParseUint(n, 10, 32)
+	if err != nil {
+		return fmt.Errorf("fetch: invalid IMAP UID %q", uid)
+	}
+	return s.Client.MarkSeen(ctx, s.mailbox(), []uint32{uint32(num)})
+}
+
+// Wait waits for new mail with IDLE.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - error: nil when mail arrived, or the error.
+func (s *IMAPSource) Wait(ctx context.Context) error {
+	return s.Client.Idle(ctx, s.mailbox())
+}
+
+func (s *IMAPSource) mailbox() string {
+	if s.Mailbox == "" {
+		return "INBOX"
+	}
+	return s.Mailbox
+}
+
+func (s *IMAPSource) criteria() string {
+	if s.Criteria == "" {
+		return "UNSEEN"
+	}
+	return s.Criteria
+}
+
+// imapUID formats a Message UID for an IMAP message.
+func imapUID(validity, uid uint32) string {
+	return strconv.FormatUint(uint64(validity), 10) + "." + strconv.FormatUint(uint64(uid), 10)
+}
@@ -0,0 +1,136 @@
+package fetch
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2/imap"
+)
+
+// fakeIMAP serves one mailbox with UIDVALIDITY 7 and messages keyed by
+// UID; IDLE reports new mail once notify is closed.
+type fakeIMAP struct {
+	ln     net.Listener
+	notify chan struct{}
+
+	mu   sync.Mutex
+	msgs map[uint32]string
+	seen []string
+}
+
+func newFakeIMAP(t *testing.T, msgs map[uint32]string) *fakeIMAP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeIMAP{ln: ln, msgs: msgs, notify: make(chan struct{})}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakeIMAP) client() *imap.IMAP {
+	addr := s.ln.Addr().(*net.TCPAddr)
+	return imap.NewIMAP(imap.IMAPConfig{Host: "127.0.0.1", Port: addr.Port, Username: "ada", Password: "secret"})
+}
+
+func (s *fakeIMAP) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	io.WriteString(c, "* OK ready\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, cmd, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		s.mu.Lock()
+		switch {
+		case strings.HasPrefix(cmd, "EXAMINE"), strings.HasPrefix(cmd, "SELECT"):
+			io.WriteString(c, "* OK [UIDVALIDITY 7] ok\r\n")
+		case strings.HasPrefix(cmd, "UID SEARCH"):
+			resp := "* SEARCH"
+			for uid := uint32(1); uid < 100; uid++ {
+				if _, ok := s.msgs[uid]; ok {
+					resp += " " + strconv.Itoa(int(uid))
+				}
+			}
+			io.WriteString(c, resp+"\r\n")
+		case strings.HasPrefix(cmd, "UID FETCH"):
+			for _, f := range strings.Split(strings.Fields(cmd)[2], ",") {
+				uid, _ := strconv.Atoi(f)
+				if msg, ok := s.msgs[uint32(uid)]; ok {
+					io.WriteString(c, "* 1 FETCH (UID "+f+" BODY[] {"+strconv.Itoa(len(msg))+"}\r\n"+msg+")\r\n")
+				}
+			}
+		case strings.HasPrefix(cmd, "UID STORE"):
+			s.seen = append(s.seen, strings.Fields(cmd)[2])
+		case cmd == "IDLE":
+			s.mu.Unlock()
+			io.WriteString(c, "+ idling\r\n")
+			<-s.notify
+			io.WriteString(c, "* 2 EXISTS\r\n")
+			r.ReadString('\n') // DONE
+			s.mu.Lock()
+		}
+		s.mu.Unlock()
+		io.WriteString(c, tag+" OK done\r\n")
+		if cmd == "LOGOUT" {
+			return
+		}
+	}
+}
+
+func TestIMAPSource(t *testing.T) {
+	srv := newFakeIMAP(t, map[uint32]string{3: "Subject: Three\r\n\r\nx\r\n"})
+	src := &IMAPSource{Client: srv.client(), MarkSeen: true}
+	f, err := New(Config{Source: src, Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var got []string
+	for msg, err := range f.Messages(ctx) {
+		if err != nil {
+			t.Fatalf("messages: %v", err)
+		}
+		got = append(got, msg.UID)
+		if len(got) == 1 {
+			srv.mu.Lock()
+			if len(srv.seen) != 0 {
+				t.Fatalf("flagged before it was yielded: %q", srv.seen)
+			}
+			srv.msgs[5] = "Subject: Five\r\n\r\ny\r\n"
+			srv.mu.Unlock()
+			close(srv.notify)
+			continue
+		}
+		break
+	}
+	if strings.Join(got, ",") != "7.3,7.5" {
+		t.Fatalf("unexpected messages %q", got)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if strings.Join(srv.seen, "|") != "3|5" {
+		t.Fatalf("unexpected STORE %q", srv.seen)
+	}
+}
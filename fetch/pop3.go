@@ -0,0 +1,288 @@
+package fetch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMaxMessageSize is the default of POP3Config.MaxMessageSize.
+const defaultMaxMessageSize = 25 << 20
+
+// POP3Config configures a POP3 source.
+type POP3Config struct {
+	Host        string
+	Port        int // defaults to 995 with ImplicitTLS, else 110
+	Username    string
+	Password    string
+	Timeout     time.Duration // bounds each session (0 = only ctx)
+	StartTLS    bool          // upgrade with STLS
+	ImplicitTLS bool
+	SkipVerify  bool
+
+	// Delete removes fetched messages from the server.
+	Delete bool
+	// MaxMessageSize caps each downloaded message; defaults to 25 MiB.
+	MaxMessageSize int
+}
+
+// POP3 reads a maildrop over POP3 (RFC 1939), keyed by UIDL. Each call
+// opens its own session, so it is safe for concurrent use. POP3 cannot
+// wait for new mail, so a Fetcher polls it every Interval.
+type POP3 struct {
+	cfg POP3Config
+}
+
+// NewPOP3 creates a POP3 source.
+//
+// Parameters:
+//   - cfg: The POP3 config.
+//
+// Returns:
+//   - *POP3: The source.
+func NewPOP3(cfg POP3Config) *POP3 {
+	if cfg.Port == 0 {
+		cfg.Port = 110
+		if cfg.ImplicitTLS {
+			cfg.Port = 995
+		}
+	}
+	if cfg.MaxMessageSize <= 0 {
+		cfg.MaxMessageSize = defaultMaxMessageSize
+	}
+	return &POP3{cfg: cfg}
+}
+
+// List returns the UIDL of every message in the maildrop.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - []string: The UIDs, in maildrop order.
+//   - error: The error if the session fails.
+func (p *POP3) List(ctx context.Context) ([]string, error) {
+	c, cancel, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	defer c.close()
+	uidl, err := c.uidl()
+	if err != nil {
+		return nil, err
+	}
+	_ = c.cmd("QUIT")
+	out := make([]string, len(uidl))
+	for i, e := range uidl {
+		out[i] = e.uid
+	}
+	return out, nil
+}
+
+// Fetch downloads the messages with uids and, with Delete, removes
+// them from the server.
+//
+// Parameters:
+//   - ctx: The context.
+//   - uids: UIDs returned by List.
+//
+// Returns:
+//   - []Message: The messages.
+//   - error: The error if the session fails.
+func (p *POP3) Fetch(ctx context.Context, uids []string) ([]Message, error) {
+	c, cancel, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	defer c.close()
+	uidl, err := c.uidl()
+	if err != nil {
+		return nil, err
+	}
+	nums := make(map[string]string, len(uidl))
+	for _, e := range uidl {
+		nums[e.uid] = e.num
+	}
+	var out []Message
+	for _, uid := range uids {
+		num, ok := nums[uid]
+		if !ok {
+			continue
+		}
+		if err := c.cmd("RETR", num); err != nil {
+			return nil, err
+		}
+		raw, err := c.readMulti(p.cfg.MaxMessageSize)
+		if err != nil {
+			return nil, fmt.Errorf("pop3 RETR: %w", err)
+		}
+		out = append(out, Message{UID: uid, Raw: raw})
+		if p.cfg.Delete {
+			if err := c.cmd("DELE", num); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Deletions take effect on QUIT.
+	if err := c.cmd("QUIT"); err != nil && p.cfg.Delete {
+		return nil, err
+	}
+	return out, nil
+}
+
+// dial opens an authenticated session that ends with ctx or the
+// configured timeout.
+func (p *POP3) dial(ctx context.Context) (*pop3Conn, context.CancelFunc, error) {
+	cancel := context.CancelFunc(func() {})
+	if p.cfg.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.Timeout)
+	}
+	hostPort := net.JoinHostPort(p.cfg.Host, strconv.Itoa(p.cfg.Port))
+	conf := &tls.Config{
+		ServerName:         p.cfg.Host,
+		InsecureSkipVerify: p.cfg.SkipVerify,
+	}
+	var nc net.Conn
+	var err error
+	if p.cfg.ImplicitTLS {
+		d := &tls.Dialer{Config: conf}
+		nc, err = d.DialContext(ctx, "tcp", hostPort)
+	} else {
+		var d net.Dialer
+		nc, err = d.DialContext(ctx, "tcp", hostPort)
+	}
+	if err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("pop3 dial: %w", err)
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		_ = nc.SetDeadline(dl)
+	}
+	// Unblock reads and writes if ctx ends mid-session.
+	stop := context.AfterFunc(ctx, func() { _ = nc.SetDeadline(time.Now()) })
+	c := &pop3Conn{nc: nc, r: bufio.NewReader(nc), stop: stop}
+	fail := func(err error) (*pop3Conn, context.CancelFunc, error) {
+		c.close()
+		cancel()
+		return nil, nil, err
+	}
+	if _, err := c.status("greeting"); err != nil {
+		return fail(err)
+	}
+	if p.cfg.StartTLS && !p.cfg.ImplicitTLS {
+		if err := c.cmd("STLS"); err != nil {
+			return fail(err)
+		}
+		tc := tls.Client(c.nc, conf)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			return fail(fmt.Errorf("pop3 starttls: %w", err))
+		}
+		c.nc, c.r = tc, bufio.NewReader(tc)
+	}
+	if err := c.cmd("USER", p.cfg.Username); err != nil {
+		return fail(err)
+	}
+	if err := c.cmd("PASS", p.cfg.Password); err != nil {
+		return fail(err)
+	}
+	return c, cancel, nil
+}
+
+// pop3Conn is a POP3 session.
+type pop3Conn struct {
+	nc   net.Conn
+	r    *bufio.Reader
+	stop func() bool
+}
+
+func (c *pop3Conn) close() {
+	c.stop()
+	_ = c.nc.Close()
+}
+
+// cmd sends a command and reads its status line.
+func (c *pop3Conn) cmd(name string, args ...string) error {
+	line := strings.Join(append([]string{name}, args...), " ")
+	if strings.ContainsAny(line, "\r\n") {
+		return fmt.Errorf("pop3 %s: invalid argument", name)
+	}
+	if _, err := io.WriteString(c.nc, line+"\r\n"); err != nil {
+		return fmt.Errorf("pop3 %s: %w", name, err)
+	}
+	_, err := c.status(name)
+	return err
+}
+
+// status reads a status line and converts "-ERR" to an error.
+func (c *pop3Conn) status(name string) (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("pop3 %s: %w", name, err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "+OK") {
+		return "", fmt.Errorf("pop3 %s: %s", name, line)
+	}
+	return line, nil
+}
+
+// readMulti reads a multi-line response up to the terminating ".",
+// undoing dot-stuffing. Line endings are kept as sent.
+func (c *pop3Conn) readMulti(limit int) ([]byte, error) {
+	var b bytes.Buffer
+	start := true // at the start of a line
+	for {
+		line, err := c.r.ReadSlice('\n')
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+		full := err == nil
+		if start && full && string(bytes.TrimRight(line, "\r\n")) == "." {
+			return b.Bytes(), nil
+		}
+		if start && len(line) > 0 && line[0] == '.' {
+			line = line[1:]
+		}
+		if b.Len()+len(line) > limit {
+			return nil, errors.New("message too large")
+		}
+		b.Write(line)
+		start = full
+	}
+}
+
+// uidlEntry maps a message number to its unique id.
+type uidlEntry struct {
+	num, uid string
+}
+
+// uidl lists the maildrop with UIDL.
+func (c *pop3Conn) uidl() ([]uidlEntry, error) {
+	if err := c.cmd("UIDL"); err != nil {
+		return nil, err
+	}
+	data, err := c.readMulti(maxUIDLSize)
+	if err != nil {
+		return nil, fmt.Errorf("pop3 UIDL: %w", err)
+	}
+	var out []uidlEntry
+	for _, line := range strings.Split(string(data), "\n") {
+		if f := strings.Fields(line); len(f) == 2 {
+			out = append(out, uidlEntry{num: f[0], uid: f[1]})
+		}
+	}
+	return out, nil
+}
+
+// maxUIDLSize caps a UIDL listing.
+const maxUIDLSize = 8 << 20
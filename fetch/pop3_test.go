@@ -0,0 +1,132 @@
+package fetch
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakePOP3 is a POP3 server over a fixed maildrop.
+type fakePOP3 struct {
+	ln   net.Listener
+	msgs []string // index+1 is the message number
+	uids []string
+
+	mu       sync.Mutex
+	commands []string
+	deleted  []string
+}
+
+func newFakePOP3(t *testing.T, uids, msgs []string) *fakePOP3 {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakePOP3{ln: ln, uids: uids, msgs: msgs}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakePOP3) config() POP3Config {
+	addr := s.ln.Addr().(*net.TCPAddr)
+	return POP3Config{Host: "127.0.0.1", Port: addr.Port, Username: "ada", Password: "secret"}
+}
+
+func (s *fakePOP3) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	io.WriteString(c, "+OK POP3 ready\r\n")
+	var dele []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.mu.Unlock()
+		name, arg, _ := strings.Cut(line, " ")
+		switch name {
+		case "PASS":
+			if arg != "secret" {
+				io.WriteString(c, "-ERR [AUTH] invalid password\r\n")
+				continue
+			}
+			io.WriteString(c, "+OK\r\n")
+		case "UIDL":
+			io.WriteString(c, "+OK\r\n")
+			for i, uid := range s.uids {
+				io.WriteString(c, string(rune('1'+i))+" "+uid+"\r\n")
+			}
+			io.WriteString(c, ".\r\n")
+		case "RETR":
+			msg := s.msgs[arg[0]-'1']
+			stuffed := strings.ReplaceAll("\n"+msg, "\n.", "\n..")[1:]
+			io.WriteString(c, "+OK\r\n"+stuffed+".\r\n")
+		case "DELE":
+			dele = append(dele, arg)
+			io.WriteString(c, "+OK\r\n")
+		case "QUIT":
+			s.mu.Lock()
+			s.deleted = append(s.deleted, dele...)
+			s.mu.Unlock()
+			io.WriteString(c, "+OK bye\r\n")
+			return
+		default:
+			io.WriteString(c, "+OK\r\n")
+		}
+	}
+}
+
+func TestPOP3ListAndFetch(t *testing.T) {
+	msg2 := "Subject: Two\r\n\r\n.hidden dot\r\n..two dots\r\n"
+	srv := newFakePOP3(t, []string{"uid-a", "uid-b"}, []string{"Subject: One\r\n\r\nx\r\n", msg2})
+	cfg := srv.config()
+	cfg.Delete = true
+	p := NewPOP3(cfg)
+	ctx := context.Background()
+
+	uids, err := p.List(ctx)
+	if err != nil || strings.Join(uids, ",") != "uid-a,uid-b" {
+		t.Fatalf("list: %v %q", err, uids)
+	}
+	msgs, err := p.Fetch(ctx, []string{"uid-b", "uid-gone"})
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].UID != "uid-b" || string(msgs[0].Raw) != msg2 {
+		t.Fatalf("unexpected messages %+v", msgs)
+	}
+	srv.mu.Lock()
+	deleted := srv.deleted
+	srv.mu.Unlock()
+	if strings.Join(deleted, ",") != "2" {
+		t.Fatalf("unexpected deletions %q", deleted)
+	}
+
+	cfg.Password = "wrong"
+	if _, err := NewPOP3(cfg).List(ctx); err == nil || !strings.Contains(err.Error(), "pop3 PASS: -ERR") {
+		t.Fatalf("expected auth error, got %v", err)
+	}
+	cfg.Password = "secret"
+	cfg.MaxMessageSize = 4
+	if _, err := NewPOP3(cfg).Fetch(ctx, []string{"uid-a"}); err == nil ||
+		!strings.Contains(err.Error(), "too large") {
+		t.Fatalf("expected size error, got %v", err)
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/aatuh/email/v2
 
 go 1.25.1
+
+require golang.org/x/net v0.47.0
@@ -0,0 +1,5 @@
+// Package imap is a minimal IMAP4rev1 client for storing messages, such
+// as copies of sent mail, with APPEND, and for reading a mailbox with
+// SEARCH, FETCH and IDLE. It implements email.SentCopier; the fetch
+// package builds a polling inbox reader on it.
+package imap
@@ -0,0 +1,278 @@
+package imap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMaxMessageSize is the default of IMAPConfig.MaxMessageSize.
+const defaultMaxMessageSize = 25 << 20
+
+// fetchBatch is the number of UIDs fetched per FETCH command.
+const fetchBatch = 100
+
+// Message is a message fetched from a mailbox.
+type Message struct {
+	UID uint32 // unique within the mailbox while UIDVALIDITY holds
+	Raw []byte
+}
+
+// Search returns the UIDs of the messages in mailbox matching criteria,
+// e.g. "UNSEEN", "ALL" or "SINCE 1-Jan-2026", in ascending order, and
+// the mailbox's UIDVALIDITY. UIDs are only comparable while UIDVALIDITY
+// stays the same.
+//
+// Parameters:
+//   - ctx: The context.
+//   - mailbox: The mailbox name, e.g. "INBOX".
+//   - criteria: The IMAP search criteria.
+//
+// Returns:
+//   - uint32: The UIDVALIDITY of the mailbox.
+//   - []uint32: The matching UIDs.
+//   - error: The error if the search fails.
+func (m *IMAP) Search(ctx context.Context, mailbox, criteria string) (uint32, []uint32, error) {
+	if criteria == "" || strings.ContainsAny(criteria, "\r\n") {
+		return 0, nil, fmt.Errorf("imap: invalid search criteria %q", criteria)
+	}
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	c, validity, err := m.selectMailbox(ctx, mailbox, true)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer c.close()
+	data, err := c.cmdData("UID SEARCH", criteria)
+	if err != nil {
+		return 0, nil, err
+	}
+	var uids []uint32
+	for _, line := range data {
+		rest, ok := strings.CutPrefix(line, "* SEARCH")
+		if !ok {
+			continue
+		}
+		for _, f := range strings.Fields(rest) {
+			if uid, err := strconv.ParseUint(f, 10, 32); err == nil {
+				uids = append(uids, uint32(uid))
+			}
+		}
+	}
+	_ = c.cmd("LOGOUT")
+	return validity, uids, nil
+}
+
+// Fetch downloads the messages with uids from mailbox without setting
+// \Seen. UIDs that no longer exist are skipped. Messages larger than
+// IMAPConfig.MaxMessageSize fail the fetch.
+//
+// Parameters:
+//   - ctx: The context.
+//   - mailbox: The mailbox name.
+//   - uids: The UIDs, e.g. from Search.
+//
+// Returns:
+//   - []Message: The messages, in the order the server sent them.
+//   - error: The error if the fetch fails.
+func (m *IMAP) Fetch(ctx context.Context, mailbox string, uids []uint32) ([]Message, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	c, _, err := m.selectMailbox(ctx, mailbox, true)
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+	c.max = m.cfg.MaxMessageSize + 1024
+	var out []Message
+	for len(uids) > 0 {
+		n := min(len(uids), fetchBatch)
+		data, err := c.cmdData("UID FETCH", uidSet(uids[:n]), "(UID BODY.PEEK[])")
+		if err != nil {
+			return out, err
+		}
+		for _, line := range data {
+			if msg, ok := parseFetch(line); ok {
+				out = append(out, msg)
+			}
+		}
+		uids = uids[n:]
+	}
+	_ = c.cmd("LOGOUT")
+	return out, nil
+}
+
+// MarkSeen sets the \Seen flag on the messages with uids.
+//
+// Parameters:
+//   - ctx: The context.
+//   - mailbox: The mailbox name.
+//   - uids: The UIDs.
+//
+// Returns:
+//   - error: The error if the flags are not stored.
+func (m *IMAP) MarkSeen(ctx context.Context, mailbox string, uids []uint32) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	c, _, err := m.selectMailbox(ctx, mailbox, false)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	if err := c.cmd("UID STORE", uidSet(uids), `+FLAGS.SILENT (\Seen)`); err != nil {
+		return err
+	}
+	_ = c.cmd("LOGOUT")
+	return nil
+}
+
+// Idle waits with IMAP IDLE (RFC 2177) until a message arrives in
+// mailbox or ctx ends. It is not bounded by IMAPConfig.Timeout; servers
+// may end an idle session after 30 minutes, so bound ctx below that
+// and call Idle again.
+//
+// Parameters:
+//   - ctx: The context.
+//   - mailbox: The mailbox name.
+//
+// Returns:
+//   - error: nil when new mail arrived, ctx.Err() when ctx ended, or
+//     the error if the server does not support IDLE.
+func (m *IMAP) Idle(ctx context.Context, mailbox string) error {
+	c, _, err := m.selectMailbox(ctx, mailbox, true)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	c.tag++
+	tag := "a" + strconv.Itoa(c.tag)
+	if _, err := io.WriteString(c.nc, tag+" IDLE\r\n"); err != nil {
+		return fmt.Errorf("imap IDLE: %w", err)
+	}
+	if err := c.waitContinue(tag, "IDLE"); err != nil {
+		return err
+	}
+	for {
+		resp, err := c.readLine()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// The socket deadline can fire just before ctx reports it.
+			if dl, ok := ctx.Deadline(); ok && !time.Now().Before(dl) {
+				return context.DeadlineExceeded
+			}
+			return fmt.Errorf("imap IDLE: %w", err)
+		}
+		if rest, ok := strings.CutPrefix(resp, tag+" "); ok {
+			if err := status("IDLE", rest); err != nil {
+				return err
+			}
+			return fmt.Errorf("imap IDLE: ended by server")
+		}
+		if strings.HasPrefix(resp, "* ") && strings.HasSuffix(resp, " EXISTS") {
+			break
+		}
+	}
+	if _, err := io.WriteString(c.nc, "DONE\r\n"); err != nil {
+		return nil // new mail arrived; the session is closed anyway
+	}
+	for {
+		resp, err := c.readLine()
+		if err != nil || strings.HasPrefix(resp, tag+" ") {
+			break
+		}
+	}
+	_ = c.cmd("LOGOUT")
+	return nil
+}
+
+// withTimeout applies IMAPConfig.Timeout to ctx.
+func (m *IMAP) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.cfg.Timeout > 0 {
+		return context.WithTimeout(ctx, m.cfg.Timeout)
+	}
+	return ctx, func() {}
+}
+
+// uidValidityRe matches the UIDVALIDITY response code of SELECT.
+var uidValidityRe = regexp.MustCompile(`\[UIDVALIDITY (\d+)\]`)
+
+// selectMailbox opens a session with mailbox selected, read-only with
+// EXAMINE, and returns its UIDVALIDITY.
+func (m *IMAP) selectMailbox(ctx context.Context, mailbox string, readOnly bool) (*conn, uint32, error) {
+	c, err := m.dial(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	name := "SELECT"
+	if readOnly {
+		name = "EXAMINE"
+	}
+	data, err := c.cmdData(name, quote(encodeMailbox(mailbox)))
+	if err != nil {
+		c.close()
+		return nil, 0, err
+	}
+	var validity uint32
+	for _, line := range data {
+		if sm := uidValidityRe.FindStringSubmatch(line); sm != nil {
+			v, _ := strconv.ParseUint(sm[1], 10, 32)
+			validity = uint32(v)
+		}
+	}
+	return c, validity, nil
+}
+
+// uidSet formats uids as an IMAP sequence set.
+func uidSet(uids []uint32) string {
+	parts := make([]string, len(uids))
+	for i, uid := range uids {
+		parts[i] = strconv.FormatUint(uint64(uid), 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// fetchUIDRe matches the UID item of a FETCH response.
+var fetchUIDRe = regexp.MustCompile(`\bUID (\d+)`)
+
+// parseFetch extracts the UID and body of a FETCH response whose
+// literal readLine has inlined after "BODY[] {n}".
+func parseFetch(line string) (Message, bool) {
+	if !strings.HasPrefix(line, "* ") || !strings.Contains(line, " FETCH (") {
+		return Message{}, false
+	}
+	i := strings.Index(line, "BODY[] {")
+	if i < 0 {
+		return Message{}, false
+	}
+	j := strings.Index(line[i:], "}\r\n")
+	if j < 0 {
+		return Message{}, false
+	}
+	j += i
+	n, err := strconv.Atoi(strings.TrimSuffix(line[i+len("BODY[] {"):j], "+"))
+	start := j + len("}\r\n")
+	if err != nil || n < 0 || start+n > len(line) {
+		return Message{}, false
+	}
+	sm := fetchUIDRe.FindStringSubmatch(line[:i] + line[start+n:])
+	if sm == nil {
+		return Message{}, false
+	}
+	uid, err := strconv.ParseUint(sm[1], 10, 32)
+	if err != nil {
+		return Message{}, false
+	}
+	return Message{UID: uint32(uid), Raw: []byte(line[start : start+n])}, true
+}
@@ -0,0 +1,95 @@
+package imap
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchAndFetch(t *testing.T) {
+	body1 := "Subject: One\r\n\r\nfirst\r\n"
+	body2 := "Subject: Two\r\n\r\nBODY[] {3}\r\n"
+	srv := newDataServer(t, nil, map[string]string{
+		"EXAMINE": "* OK [UIDVALIDITY 42] UIDs valid\r\n",
+		"UID": "* SEARCH 7 9\r\n" +
+			"* 1 FETCH (UID 7 BODY[] {" + strconv.Itoa(len(body1)) + "}\r\n" + body1 + ")\r\n" +
+			"* 2 FETCH (BODY[] {" + strconv.Itoa(len(body2)) + "}\r\n" + body2 + " UID 9)\r\n" +
+			"* 3 FETCH (FLAGS (\\Seen))\r\n",
+	})
+	m := NewIMAP(srv.config())
+	ctx := context.Background()
+
+	validity, uids, err := m.Search(ctx, "INBOX", "UNSEEN")
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if validity != 42 || len(uids) != 2 || uids[0] != 7 || uids[1] != 9 {
+		t.Fatalf("unexpected search result %d %v", validity, uids)
+	}
+	msgs, err := m.Fetch(ctx, "INBOX", uids)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if len(msgs) != 2 || msgs[0].UID != 7 || string(msgs[0].Raw) != body1 ||
+		msgs[1].UID != 9 || string(msgs[1].Raw) != body2 {
+		t.Fatalf("unexpected messages %+v", msgs)
+	}
+	if err := m.MarkSeen(ctx, "INBOX", uids); err != nil {
+		t.Fatalf("mark seen: %v", err)
+	}
+	cmds, _ := srv.log()
+	want := []string{
+		`EXAMINE "INBOX"`, `UID SEARCH UNSEEN`,
+		`EXAMINE "INBOX"`, `UID FETCH 7,9 (UID BODY.PEEK[])`,
+		`SELECT "INBOX"`, `UID STORE 7,9 +FLAGS.SILENT (\Seen)`,
+	}
+	var got []string
+	for _, c := range cmds {
+		if !strings.HasPrefix(c, "LOGIN") && c != "LOGOUT" {
+			got = append(got, c)
+		}
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected commands %q", got)
+	}
+
+	if _, _, err := m.Search(ctx, "INBOX", "ALL\r\na9 LOGOUT"); err == nil {
+		t.Fatalf("expected invalid criteria error")
+	}
+	if msgs, err := m.Fetch(ctx, "INBOX", nil); err != nil || msgs != nil {
+		t.Fatalf("expected empty fetch, got %v %v", msgs, err)
+	}
+}
+
+func TestFetchTooLarge(t *testing.T) {
+	srv := newDataServer(t, nil, map[string]string{
+		"UID": "* 1 FETCH (UID 7 BODY[] {5000}\r\n" + strings.Repeat("x", 5000) + ")\r\n",
+	})
+	cfg := srv.config()
+	cfg.MaxMessageSize = 1000
+	_, err := NewIMAP(cfg).Fetch(context.Background(), "INBOX", []uint32{7})
+	if err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Fatalf("expected size error, got %v", err)
+	}
+}
+
+func TestIdle(t *testing.T) {
+	srv := newDataServer(t, nil, map[string]string{"IDLE": "* 2 EXISTS\r\n"})
+	if err := NewIMAP(srv.config()).Idle(context.Background(), "INBOX"); err != nil {
+		t.Fatalf("idle: %v", err)
+	}
+	cmds, _ := srv.log()
+	if strings.Join(cmds[1:], "|") != `EXAMINE "INBOX"|IDLE|LOGOUT` {
+		t.Fatalf("unexpected commands %q", cmds)
+	}
+
+	quiet := newDataServer(t, nil, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := NewIMAP(quiet.config()).Idle(ctx, "INBOX"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline, got %v", err)
+	}
+}
@@ -0,0 +1,358 @@
+package imap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// IMAPConfig configures the IMAP client.
+type IMAPConfig struct {
+	Host        string
+	Port        int // defaults to 993 with ImplicitTLS, else 143
+	Username    string
+	Password    string
+	Timeout     time.Duration // bounds each session (0 = only ctx)
+	StartTLS    bool
+	ImplicitTLS bool
+	SkipVerify  bool
+
+	// Mailbox receives CopySent messages; defaults to "Sent".
+	Mailbox string
+	// Flags are set on CopySent messages; nil means \Seen.
+	Flags []string
+
+	// MaxMessageSize caps each message Fetch downloads; defaults to
+	// 25 MiB.
+	MaxMessageSize int
+}
+
+// IMAP stores and fetches messages on an IMAP server. Each call opens
+// its own session, so it is safe for concurrent use.
+type IMAP struct {
+	cfg IMAPConfig
+}
+
+// NewIMAP creates a new IMAP client.
+//
+// Parameters:
+//   - cfg: The IMAP config.
+//
+// Returns:
+//   - *IMAP: The IMAP client.
+func NewIMAP(cfg IMAPConfig) *IMAP {
+	if cfg.Port == 0 {
+		cfg.Port = 143
+		if cfg.ImplicitTLS {
+			cfg.Port = 993
+		}
+	}
+	if cfg.Mailbox == "" {
+		cfg.Mailbox = "Sent"
+	}
+	if cfg.Flags == nil {
+		cfg.Flags = []string{`\Seen`}
+	}
+	if cfg.MaxMessageSize <= 0 {
+		cfg.MaxMessageSize = defaultMaxMessageSize
+	}
+	return &IMAP{cfg: cfg}
+}
+
+// CopySent appends raw to the configured mailbox with the configured
+// flags. It implements email.SentCopier.
+//
+// Parameters:
+//   - ctx: The context.
+//   - raw: The message.
+//
+// Returns:
+//   - error: The error if the message is not stored.
+func (m *IMAP) CopySent(ctx context.Context, raw []byte) error {
+	return m.Append(ctx, m.cfg.Mailbox, m.cfg.Flags, raw)
+}
+
+// Append stores raw in mailbox with flags using IMAP APPEND.
+//
+// Parameters:
+//   - ctx: The context.
+//   - mailbox: The mailbox name, e.g. "Sent" or "INBOX.Sent".
+//   - flags: The flags to set, e.g. `\Seen`.
+//   - raw: The message, with CRLF line endings.
+//
+// Returns:
+//   - error: The error if the message is not stored.
+func (m *IMAP) Append(
+	ctx context.Context,
+	mailbox string,
+	flags []string,
+	raw []byte,
+) error {
+	for _, f := range flags {
+		if f == "" || strings.ContainsAny(f, " ()\r\n{\"") {
+			return fmt.Errorf("imap: invalid flag %q", f)
+		}
+	}
+	if m.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.cfg.Timeout)
+		defer cancel()
+	}
+	c, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	err = c.cmd("APPEND", quote(encodeMailbox(mailbox)),
+		"("+strings.Join(flags, " ")+")", literal(raw))
+	if err != nil {
+		return err
+	}
+	_ = c.cmd("LOGOUT")
+	return nil
+}
+
+// dial opens an authenticated session that ends with ctx.
+func (m *IMAP) dial(ctx context.Context) (*conn, error) {
+	hostPort := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	conf := &tls.Config{
+		ServerName:         m.cfg.Host,
+		InsecureSkipVerify: m.cfg.SkipVerify,
+	}
+	var nc net.Conn
+	var err error
+	if m.cfg.ImplicitTLS {
+		d := &tls.Dialer{Config: conf}
+		nc, err = d.DialContext(ctx, "tcp", hostPort)
+	} else {
+		var d net.Dialer
+		nc, err = d.DialContext(ctx, "tcp", hostPort)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("imap dial: %w", err)
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		_ = nc.SetDeadline(dl)
+	}
+	// Unblock reads and writes if ctx ends mid-session.
+	stop := context.AfterFunc(ctx, func() { _ = nc.SetDeadline(time.Now()) })
+	c := &conn{nc: nc, r: bufio.NewReader(nc), stop: stop}
+
+	greeting, err := c.readLine()
+	if err != nil {
+		c.close()
+		return nil, fmt.Errorf("imap greeting: %w", err)
+	}
+	preauth := strings.HasPrefix(greeting, "* PREAUTH")
+	if !preauth && !strings.HasPrefix(greeting, "* OK") {
+		c.close()
+		return nil, fmt.Errorf("imap greeting: %s", greeting)
+	}
+	if m.cfg.StartTLS && !m.cfg.ImplicitTLS {
+		if err := c.cmd("STARTTLS"); err != nil {
+			c.close()
+			return nil, err
+		}
+		tc := tls.Client(c.nc, conf)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			c.close()
+			return nil, fmt.Errorf("imap starttls: %w", err)
+		}
+		c.nc, c.r = tc, bufio.NewReader(tc)
+	}
+	if !preauth {
+		if err := c.cmd("LOGIN", quote(m.cfg.Username), quote(m.cfg.Password)); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// literal is a command argument sent as an IMAP literal.
+type literal []byte
+
+// quote returns s as a quoted string, or as a literal if it holds
+// characters a quoted string cannot.
+func quote(s string) any {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c > 0x7e {
+			return literal(s)
+		}
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// maxLine caps a server response line, including literals.
+const maxLine = 1 << 20
+
+// conn is an IMAP session.
+type conn struct {
+	nc   net.Conn
+	r    *bufio.Reader
+	tag  int
+	stop func() bool
+	max  int // response line cap; 0 means maxLine
+}
+
+func (c *conn) close() {
+	c.stop()
+	_ = c.nc.Close()
+}
+
+// cmd sends a tagged command and waits for its completion. args are
+// strings written as is, or literals.
+func (c *conn) cmd(name string, args ...any) error {
+	_, err := c.cmdData(name, args...)
+	return err
+}
+
+// cmdData is cmd returning the untagged responses ("* ...") the server
+// sent before the completion.
+func (c *conn) cmdData(name string, args ...any) ([]string, error) {
+	c.tag++
+	tag := "a" + strconv.Itoa(c.tag)
+	line := tag + " " + name
+	for _, a := range args {
+		switch a := a.(type) {
+		case literal:
+			line += " {" + strconv.Itoa(len(a)) + "}\r\n"
+			if _, err := io.WriteString(c.nc, line); err != nil {
+				return nil, fmt.Errorf("imap %s: %w", name, err)
+			}
+			if err := c.waitContinue(tag, name); err != nil {
+				return nil, err
+			}
+			if _, err := c.nc.Write(a); err != nil {
+				return nil, fmt.Errorf("imap %s: %w", name, err)
+			}
+			line = ""
+		case string:
+			line += " " + a
+		}
+	}
+	if _, err := io.WriteString(c.nc, line+"\r\n"); err != nil {
+		return nil, fmt.Errorf("imap %s: %w", name, err)
+	}
+	var data []string
+	for {
+		resp, err := c.readLine()
+		if err != nil {
+			return nil, fmt.Errorf("imap %s: %w", name, err)
+		}
+		if rest, ok := strings.CutPrefix(resp, tag+" "); ok {
+			return data, status(name, rest)
+		}
+		if strings.HasPrefix(resp, "* ") {
+			data = append(data, resp)
+		}
+	}
+}
+
+// waitContinue reads until the server asks for the literal.
+func (c *conn) waitContinue(tag, name string) error {
+	for {
+		resp, err := c.readLine()
+		if err != nil {
+			return fmt.Errorf("imap %s: %w", name, err)
+		}
+		if strings.HasPrefix(resp, "+") {
+			return nil
+		}
+		if rest, ok := strings.CutPrefix(resp, tag+" "); ok {
+			if err := status(name, rest); err != nil {
+				return err
+			}
+			return fmt.Errorf("imap %s: unexpected completion", name)
+		}
+	}
+}
+
+// status converts a tagged response to an error unless it is OK.
+func status(name, resp string) error {
+	if strings.HasPrefix(resp, "OK") {
+		return nil
+	}
+	return fmt.Errorf("imap %s: %s", name, resp)
+}
+
+// readLine reads a response line without CRLF, inlining any literals
+// it announces.
+func (c *conn) readLine() (string, error) {
+	limit := maxLine
+	if c.max > 0 {
+		limit = c.max
+	}
+	var b strings.Builder
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		b.WriteString(line)
+		if b.Len() > limit {
+			return "", errors.New("response too long")
+		}
+		i := strings.LastIndexByte(line, '{')
+		if i < 0 || !strings.HasSuffix(line, "}") {
+			return b.String(), nil
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(line[i+1:len(line)-1], "+"))
+		if err != nil {
+			return b.String(), nil
+		}
+		if n < 0 || b.Len()+n > limit {
+			return "", errors.New("response too long")
+		}
+		lit := make([]byte, n)
+		if _, err := io.ReadFull(c.r, lit); err != nil {
+			return "", err
+		}
+		b.WriteString("\r\n")
+		b.Write(lit)
+	}
+}
+
+// encodeMailbox encodes a mailbox name in modified UTF-7 (RFC 3501
+// 5.1.3).
+func encodeMailbox(name string) string {
+	enc := base64.StdEncoding.WithPadding(base64.NoPadding)
+	var b strings.Builder
+	var run []rune
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		u := utf16.Encode(run)
+		buf := make([]byte, 0, 2*len(u))
+		for _, r := range u {
+			buf = append(buf, byte(r>>8), byte(r))
+		}
+		b.WriteString("&" + strings.ReplaceAll(enc.EncodeToString(buf), "/", ",") + "-")
+		run = run[:0]
+	}
+	for _, r := range name {
+		switch {
+		case r == '&':
+			flush()
+			b.WriteString("&-")
+		case r >= 0x20 && r <= 0x7e:
+			flush()
+			b.WriteRune(r)
+		default:
+			run = append(run, r)
+		}
+	}
+	flush()
+	return b.String()
+}
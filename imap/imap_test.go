@@ -0,0 +1,175 @@
+package imap
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2"
+)
+
+var _ email.SentCopier = (*IMAP)(nil)
+
+// fakeServer is a scripted IMAP server. reply maps a command name to
+// its tagged completion text; unknown commands complete with OK. data
+// maps a command name to untagged responses sent before the completion.
+type fakeServer struct {
+	ln    net.Listener
+	reply map[string]string
+	data  map[string]string
+
+	mu       sync.Mutex
+	commands []string
+	appended []byte
+}
+
+func newFakeServer(t *testing.T, reply map[string]string) *fakeServer {
+	t.Helper()
+	return newDataServer(t, reply, nil)
+}
+
+func newDataServer(t *testing.T, reply, data map[string]string) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{ln: ln, reply: reply, data: data}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) config() IMAPConfig {
+	addr := s.ln.Addr().(*net.TCPAddr)
+	return IMAPConfig{Host: "127.0.0.1", Port: addr.Port, Username: "ada", Password: "secret"}
+}
+
+func (s *fakeServer) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	io.WriteString(c, "* OK IMAP4rev1 ready\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		// Read literals the client sends after a continuation.
+		for strings.HasSuffix(line, "}") {
+			i := strings.LastIndexByte(line, '{')
+			n, _ := strconv.Atoi(line[i+1 : len(line)-1])
+			io.WriteString(c, "+ Ready\r\n")
+			lit := make([]byte, n)
+			io.ReadFull(r, lit)
+			rest, _ := r.ReadString('\n')
+			if strings.HasPrefix(line, "a") && strings.Contains(line, " APPEND ") {
+				s.mu.Lock()
+				s.appended = lit
+				s.mu.Unlock()
+			}
+			line = line[:i] + "<" + string(lit) + ">" + strings.TrimRight(rest, "\r\n")
+		}
+		tag, cmd, _ := strings.Cut(line, " ")
+		name, _, _ := strings.Cut(cmd, " ")
+		s.mu.Lock()
+		s.commands = append(s.commands, cmd)
+		s.mu.Unlock()
+		reply := "OK done"
+		if r, ok := s.reply[name]; ok {
+			reply = r
+		}
+		if name == "LOGOUT" {
+			io.WriteString(c, "* BYE\r\n")
+		}
+		if name == "IDLE" {
+			io.WriteString(c, "+ idling\r\n"+s.data[name])
+			if _, err := r.ReadString('\n'); err != nil { // DONE
+				return
+			}
+			io.WriteString(c, tag+" "+reply+"\r\n")
+			continue
+		}
+		io.WriteString(c, "* 1 EXISTS\r\n"+s.data[name]+tag+" "+reply+"\r\n")
+		if name == "LOGOUT" {
+			return
+		}
+	}
+}
+
+func (s *fakeServer) log() ([]string, []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...), s.appended
+}
+
+func TestCopySent(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	m := NewIMAP(srv.config())
+	raw := []byte("Subject: Hi\r\n\r\nbody\r\n")
+	if err := m.CopySent(context.Background(), raw); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	cmds, appended := srv.log()
+	want := []string{
+		`LOGIN "ada" "secret"`,
+		`APPEND "Sent" (\Seen) <` + string(raw) + `>`,
+		`LOGOUT`,
+	}
+	if strings.Join(cmds, "|") != strings.Join(want, "|") || string(appended) != string(raw) {
+		t.Fatalf("unexpected session %q", cmds)
+	}
+}
+
+func TestAppendLiteralsAndErrors(t *testing.T) {
+	srv := newFakeServer(t, map[string]string{"APPEND": "NO [TRYCREATE] no such mailbox"})
+	cfg := srv.config()
+	cfg.Password = "pässword"
+	cfg.Timeout = time.Second
+	m := NewIMAP(cfg)
+	err := m.Append(context.Background(), "Gesendete Objekte & Entwürfe", nil, []byte("x\r\n"))
+	if err == nil || !strings.Contains(err.Error(), "imap APPEND: NO [TRYCREATE]") {
+		t.Fatalf("expected APPEND error, got %v", err)
+	}
+	cmds, _ := srv.log()
+	if cmds[0] != `LOGIN "ada" <pässword>` ||
+		cmds[1] != `APPEND "Gesendete Objekte &- Entw&APw-rfe" () <x`+"\r\n>" {
+		t.Fatalf("unexpected commands %q", cmds)
+	}
+
+	srv = newFakeServer(t, map[string]string{"LOGIN": "NO [AUTHENTICATIONFAILED] bad"})
+	if err := NewIMAP(srv.config()).CopySent(context.Background(), []byte("x")); err == nil ||
+		!strings.Contains(err.Error(), "imap LOGIN: NO") {
+		t.Fatalf("expected LOGIN error, got %v", err)
+	}
+	if err := m.Append(context.Background(), "Sent", []string{"bad flag"}, nil); err == nil {
+		t.Fatalf("expected invalid flag error")
+	}
+}
+
+func TestEncodeMailbox(t *testing.T) {
+	for in, want := range map[string]string{
+		"Sent":               "Sent",
+		"A&B":                "A&-B",
+		"日本語":                "&ZeVnLIqe-",
+		"~peter/mail/台北/日本語": "~peter/mail/&U,BTFw-/&ZeVnLIqe-",
+	} {
+		if got := encodeMailbox(in); got != want {
+			t.Fatalf("encodeMailbox(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package inbound
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
+
+// Feedback types (RFC 5965 7.3).
+const (
+	FeedbackAbuse   = "abuse"
+	FeedbackFraud   = "fraud"
+	FeedbackVirus   = "virus"
+	FeedbackOther   = "other"
+	FeedbackNotSpam = "not-spam"
+)
+
+// Feedback is an abuse report in the Abuse Reporting Format (RFC 5965),
+// as sent by mailbox providers' feedback loops when a recipient marks a
+// message as spam.
+type Feedback struct {
+	Type             string    // Feedback-Type, lowercased, e.g. FeedbackAbuse
+	UserAgent        string    // software that generated the report
+	OriginalMailFrom string    // envelope sender of the reported message
+	OriginalRcptTo   []string  // envelope recipients, if not redacted
+	ArrivalDate      time.Time // zero if missing or invalid
+	ReportingMTA     string    // without the "dns;" prefix
+	SourceIP         string
+	ReportedDomain   string
+
+	// OriginalMessageID, OriginalFrom and OriginalTo come from the
+	// headers of the reported message, which is included whole or as
+	// text/rfc822-headers.
+	OriginalMessageID string // with angle brackets
+	OriginalFrom      string
+	OriginalTo        []string
+
+	Text string // human-readable part
+}
+
+// Recipients returns the addresses that complained: OriginalRcptTo,
+// or the To addresses of the reported message if the envelope
+// recipients were left out.
+//
+// Returns:
+//   - []string: The addresses.
+func (f *Feedback) Recipients() []string {
+	if len(f.OriginalRcptTo) > 0 {
+		return f.OriginalRcptTo
+	}
+	return f.OriginalTo
+}
+
+// Suppress adds the complaining recipients to l with reason
+// email.SuppressComplaint. Reports of type FeedbackNotSpam add
+// nothing.
+//
+// Parameters:
+//   - ctx: The context.
+//   - l: The suppression list.
+//
+// Returns:
+//   - []string: The suppressed addresses.
+//   - error: The error if the report names no recipient or l fails.
+func (f *Feedback) Suppress(ctx context.Context, l email.SuppressionList) ([]string, error) {
+	if f.Type == FeedbackNotSpam {
+		return nil, nil
+	}
+	rcpts := f.Recipients()
+	if len(rcpts) == 0 {
+		return nil, errors.New("inbound: feedback report names no recipient")
+	}
+	for i, addr := range rcpts {
+		if err := l.Suppress(ctx, addr, email.SuppressComplaint); err != nil {
+			return rcpts[:i], err
+		}
+	}
+	return rcpts, nil
+}
+
+// ParseARF parses a raw abuse report (multipart/report with a
+// message/feedback-report part).
+//
+// Parameters:
+//   - r: The raw message.
+//
+// Returns:
+//   - *Feedback: The report.
+//   - error: An error if r is not a valid ARF report.
+func ParseARF(r io.Reader) (*Feedback, error) {
+	msg, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	var fields, orig types.Headers
+	found := false
+	for _, a := range msg.Attach {
+		mt, _, _ := mime.ParseMediaType(a.ContentType)
+		switch mt {
+		case "message/feedback-report":
+			if fields, err = readHeader(bufio.NewReader(a.Reader)); err != nil {
+				return nil, err
+			}
+			found = true
+		case "message/rfc822", "text/rfc822-headers":
+			if orig, err = readHeader(bufio.NewReader(a.Reader)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !found {
+		return nil, errors.New("inbound: no message/feedback-report part")
+	}
+	f := &Feedback{
+		Type:              strings.ToLower(strings.TrimSpace(fields.Get("Feedback-Type"))),
+		UserAgent:         fields.Get("User-Agent"),
+		OriginalMailFrom:  strings.Trim(fields.Get("Original-Mail-From"), "<> "),
+		ReportingMTA:      addrField(fields.Get("Reporting-MTA")),
+		SourceIP:          strings.TrimSpace(fields.Get("Source-IP")),
+		ReportedDomain:    strings.TrimSpace(fields.Get("Reported-Domain")),
+		OriginalMessageID: strings.TrimSpace(orig.Get("Message-ID")),
+		Text:              string(msg.Plain),
+	}
+	if f.Type == "" {
+		return nil, errors.New("inbound: feedback report without Feedback-Type")
+	}
+	for _, v := range fields.Values("Original-Rcpt-To") {
+		if addr := strings.Trim(v, "<> "); addr != "" {
+			f.OriginalRcptTo = append(f.OriginalRcptTo, addr)
+		}
+	}
+	if d := fields.Get("Arrival-Date"); d != "" {
+		f.ArrivalDate, _ = mail.ParseDate(d)
+	}
+	if as := parseAddrs(orig.Get("From")); len(as) > 0 {
+		f.OriginalFrom = as[0].Mail
+	}
+	for _, a := range parseAddrs(orig.Get("To")) {
+		f.OriginalTo = append(f.OriginalTo, a.Mail)
+	}
+	return f, nil
+}
@@ -0,0 +1,109 @@
+package inbound
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2"
+)
+
+// arfExample is adapted from RFC 5965 appendix B.2.
+const arfExample = "From: <abusedesk@example.com>\r\n" +
+	"Date: Thu, 8 Mar 2005 17:40:36 EDT\r\n" +
+	"Subject: FW: Earn money\r\n" +
+	"To: <abuse@example.net>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=feedback-report;\r\n" +
+	"\tboundary=\"part1_13d.2e68ed54_boundary\"\r\n" +
+	"\r\n" +
+	"--part1_13d.2e68ed54_boundary\r\n" +
+	"Content-Type: text/plain; charset=\"US-ASCII\"\r\n" +
+	"Content-Transfer-Encoding: 7bit\r\n" +
+	"\r\n" +
+	"This is an email abuse report for an email message received from IP\r\n" +
+	"192.0.2.1 on Thu, 8 Mar 2005 14:00:00 EDT.\r\n" +
+	"--part1_13d.2e68ed54_boundary\r\n" +
+	"Content-Type: message/feedback-report\r\n" +
+	"\r\n" +
+	"Feedback-Type: abuse\r\n" +
+	"User-Agent: SomeGenerator/1.0\r\n" +
+	"Version: 1\r\n" +
+	"Original-Mail-From: <somespammer@example.net>\r\n" +
+	"Original-Rcpt-To: <user@example.com>\r\n" +
+	"Arrival-Date: Thu, 8 Mar 2005 14:00:00 EDT\r\n" +
+	"Reporting-MTA: dns; mail.example.com\r\n" +
+	"Source-IP: 192.0.2.1\r\n" +
+	"Authentication-Results: mail.example.com;\r\n" +
+	"\tspf=fail smtp.mail=somespammer@example.com\r\n" +
+	"Reported-Domain: example.net\r\n" +
+	"\r\n" +
+	"--part1_13d.2e68ed54_boundary\r\n" +
+	"Content-Type: message/rfc822\r\n" +
+	"Content-Disposition: inline\r\n" +
+	"\r\n" +
+	"From: <somespammer@example.net>\r\n" +
+	"Received: from mailserver.example.net (mailserver.example.net\r\n" +
+	"\t[192.0.2.1]) by example.com with ESMTP id M63d4137594e46;\r\n" +
+	"\tThu, 08 Mar 2005 14:00:00 -0400\r\n" +
+	"To: <Undisclosed Recipients>\r\n" +
+	"Subject: Earn money\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-type: text/plain\r\n" +
+	"Message-ID: 8787KJKJ3K4J3K4J3K4J3.mail@example.net\r\n" +
+	"Date: Thu, 02 Sep 2004 12:31:03 -0500\r\n" +
+	"\r\n" +
+	"Spam Spam Spam\r\n" +
+	"--part1_13d.2e68ed54_boundary--\r\n"
+
+func TestParseARF(t *testing.T) {
+	f, err := ParseARF(strings.NewReader(arfExample))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if f.Type != FeedbackAbuse || f.UserAgent != "SomeGenerator/1.0" ||
+		f.OriginalMailFrom != "somespammer@example.net" ||
+		f.ReportingMTA != "mail.example.com" || f.SourceIP != "192.0.2.1" ||
+		f.ReportedDomain != "example.net" ||
+		f.OriginalMessageID != "8787KJKJ3K4J3K4J3K4J3.mail@example.net" ||
+		f.OriginalFrom != "somespammer@example.net" ||
+		!strings.HasPrefix(f.Text, "This is an email abuse report") {
+		t.Fatalf("unexpected report: %+v", f)
+	}
+	if got := f.Recipients(); len(got) != 1 || got[0] != "user@example.com" {
+		t.Fatalf("recipients = %v", got)
+	}
+	// The zone abbreviation leaves the offset unknown.
+	if f.ArrivalDate.Format(time.DateTime) != "2005-03-08 14:00:00" {
+		t.Fatalf("arrival = %v", f.ArrivalDate)
+	}
+
+	if _, err := ParseARF(strings.NewReader("From: a@example.com\r\n\r\nhi")); err == nil {
+		t.Fatal("expected error for a message without a feedback report")
+	}
+}
+
+func TestFeedbackSuppress(t *testing.T) {
+	ctx := context.Background()
+	list := email.NewMemorySuppressionList()
+
+	// Redacted envelope recipients fall back to the original To.
+	f := &Feedback{Type: FeedbackAbuse, OriginalTo: []string{"Ada@Example.org"}}
+	got, err := f.Suppress(ctx, list)
+	if err != nil || len(got) != 1 {
+		t.Fatalf("suppress = %v, %v", got, err)
+	}
+	err = email.SuppressionCheck(list).CheckAddress(ctx, "ada@example.org")
+	if !errors.Is(err, email.ErrSuppressed) {
+		t.Fatalf("check = %v", err)
+	}
+
+	if got, err := (&Feedback{Type: FeedbackNotSpam, OriginalTo: []string{"bob@example.org"}}).Suppress(ctx, list); got != nil || err != nil {
+		t.Fatalf("not-spam suppressed %v, %v", got, err)
+	}
+	if _, err := (&Feedback{Type: FeedbackAbuse}).Suppress(ctx, list); err == nil {
+		t.Fatal("expected error without recipients")
+	}
+}
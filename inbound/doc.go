@@ -0,0 +1,5 @@
+// Package inbound parses received messages into types.Message and
+// handles message disposition notifications (read receipts, RFC 8098):
+// building an MDN in reply to a message that requested one, and parsing
+// MDNs that come back.
+package inbound
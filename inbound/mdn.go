@@ -0,0 +1,265 @@
+package inbound
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// Disposition types (RFC 8098 3.2.6.2).
+const (
+	DispositionDisplayed  = "displayed"
+	DispositionDeleted    = "deleted"
+	DispositionDispatched = "dispatched"
+	DispositionProcessed  = "processed"
+)
+
+// Disposition is the Disposition field of an MDN, e.g.
+// "manual-action/MDN-sent-manually; displayed".
+type Disposition struct {
+	Automatic bool   // automatic-action instead of manual-action
+	AutoSent  bool   // MDN-sent-automatically instead of manually
+	Type      string // e.g. DispositionDisplayed
+}
+
+// String renders the Disposition field value.
+//
+// Returns:
+//   - string: The field value.
+func (d Disposition) String() string {
+	action, sending := "manual-action", "MDN-sent-manually"
+	if d.Automatic {
+		action = "automatic-action"
+	}
+	if d.AutoSent {
+		sending = "MDN-sent-automatically"
+	}
+	return action + "/" + sending + "; " + d.Type
+}
+
+// MDN is a message disposition notification (RFC 8098).
+type MDN struct {
+	ReportingUA       string
+	OriginalRecipient string // address, without the "rfc822;" prefix
+	FinalRecipient    string // address, without the "rfc822;" prefix
+	OriginalMessageID string // with angle brackets
+	Disposition       Disposition
+	Text              string // human-readable part
+}
+
+// ReceiptTo returns the address a read receipt for msg should be sent
+// to, from Disposition-Notification-To or the legacy Return-Receipt-To,
+// or "" if none was requested.
+//
+// Parameters:
+//   - msg: The received message.
+//
+// Returns:
+//   - string: The receipt address.
+func ReceiptTo(msg *types.Message) string {
+	for _, name := range []string{
+		"Disposition-Notification-To", "Return-Receipt-To",
+	} {
+		if as := parseAddrs(msg.Headers.Get(name)); len(as) > 0 {
+			return as[0].Mail
+		}
+	}
+	return ""
+}
+
+// BuildMDN builds a raw multipart/report MDN answering orig, addressed
+// to ReceiptTo(orig). Empty fields of m default from orig and from:
+// FinalRecipient to from.Mail, OriginalMessageID to orig's Message-ID,
+// Disposition.Type to displayed and Text to a short notice. RFC 8098
+// expects user consent before a manual-action MDN is sent.
+//
+// Parameters:
+//   - orig: The received message that requested a receipt.
+//   - from: The recipient sending the receipt.
+//   - m: The notification fields.
+//
+// Returns:
+//   - []byte: The raw message, ready for SMTP DATA.
+//   - error: An error if orig did not request a receipt.
+func BuildMDN(orig *types.Message, from types.Address, m MDN) ([]byte, error) {
+	to := ReceiptTo(orig)
+	if to == "" {
+		return nil, errors.New("inbound: message does not request a receipt")
+	}
+	if m.FinalRecipient == "" {
+		m.FinalRecipient = from.Mail
+	}
+	if m.OriginalMessageID == "" {
+		m.OriginalMessageID = orig.Headers.Get("Message-ID")
+	}
+	if m.Disposition.Type == "" {
+		m.Disposition.Type = DispositionDisplayed
+	}
+	if m.Text == "" {
+		m.Text = fmt.Sprintf("The message sent to %s was %s.\r\n",
+			m.FinalRecipient, m.Disposition.Type)
+	}
+
+	var report bytes.Buffer
+	var err error
+	add := func(name, value string) {
+		if value == "" || err != nil {
+			return
+		}
+		if err = types.ValidateHeaderValue(name, value); err == nil {
+			fmt.Fprintf(&report, "%s: %s\r\n", name, value)
+		}
+	}
+	add("Reporting-UA", m.ReportingUA)
+	if m.OriginalRecipient != "" {
+		add("Original-Recipient", "rfc822;"+m.OriginalRecipient)
+	}
+	add("Final-Recipient", "rfc822;"+m.FinalRecipient)
+	add("Original-Message-ID", m.OriginalMessageID)
+	add("Disposition", m.Disposition.String())
+	if err != nil {
+		return nil, err
+	}
+
+	boundary, err := internal.NewBoundary(internal.Rand())
+	if err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return nil, err
+	}
+	pw, _ := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {`text/plain; charset="UTF-8"`},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	qw := quotedprintable.NewWriter(pw)
+	_, _ = io.WriteString(qw, m.Text)
+	_ = qw.Close()
+	pw, _ = mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"message/disposition-notification"},
+	})
+	_, _ = pw.Write(report.Bytes())
+	_ = mw.Close()
+
+	subject := strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, "Read: "+orig.Subject)
+	var h types.Headers
+	h.Add("Date", time.Now().UTC().Format(time.RFC1123Z))
+	h.Add("From", from.String())
+	h.Add("To", to)
+	h.Add("Message-ID", mdnMessageID(from.Mail))
+	if m.OriginalMessageID != "" {
+		h.Add("In-Reply-To", m.OriginalMessageID)
+		h.Add("References", m.OriginalMessageID)
+	}
+	h.Add("Subject", mime.QEncoding.Encode("UTF-8", subject))
+	h.Add("MIME-Version", "1.0")
+	h.Add("Content-Type", fmt.Sprintf(
+		`multipart/report; report-type=disposition-notification; boundary="%s"`,
+		mw.Boundary()))
+	h.Add("Auto-Submitted", "auto-replied")
+	for _, f := range h {
+		if err := types.ValidateHeaderValue(f.Name, f.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	internal.WriteHeaders(&out, h)
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+// ParseMDN parses a raw MDN (multipart/report with a
+// message/disposition-notification part).
+//
+// Parameters:
+//   - r: The raw message.
+//
+// Returns:
+//   - *MDN: The notification.
+//   - error: An error if r is not a valid MDN.
+func ParseMDN(r io.Reader) (*MDN, error) {
+	msg, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range msg.Attach {
+		mt, _, _ := mime.ParseMediaType(a.ContentType)
+		if mt != "message/disposition-notification" {
+			continue
+		}
+		fields, err := readHeader(bufio.NewReader(a.Reader))
+		if err != nil {
+			return nil, err
+		}
+		m := &MDN{
+			ReportingUA:       fields.Get("Reporting-UA"),
+			OriginalRecipient: addrField(fields.Get("Original-Recipient")),
+			FinalRecipient:    addrField(fields.Get("Final-Recipient")),
+			OriginalMessageID: fields.Get("Original-Message-ID"),
+			Text:              string(msg.Plain),
+		}
+		d, err := parseDisposition(fields.Get("Disposition"))
+		if err != nil {
+			return nil, err
+		}
+		m.Disposition = d
+		if m.FinalRecipient == "" {
+			return nil, errors.New("inbound: MDN without Final-Recipient")
+		}
+		return m, nil
+	}
+	return nil, errors.New("inbound: no message/disposition-notification part")
+}
+
+// addrField strips the address type from "rfc822;addr".
+func addrField(v string) string {
+	if _, addr, ok := strings.Cut(v, ";"); ok {
+		return strings.TrimSpace(addr)
+	}
+	return strings.TrimSpace(v)
+}
+
+// parseDisposition parses "action/sending; type[/modifier,...]".
+func parseDisposition(v string) (Disposition, error) {
+	modes, typ, ok := strings.Cut(v, ";")
+	if !ok {
+		return Disposition{}, fmt.Errorf("inbound: invalid Disposition %q", v)
+	}
+	action, sending, _ := strings.Cut(modes, "/")
+	typ, _, _ = strings.Cut(typ, "/")
+	return Disposition{
+		Automatic: strings.EqualFold(strings.TrimSpace(action), "automatic-action"),
+		AutoSent:  strings.EqualFold(strings.TrimSpace(sending), "MDN-sent-automatically"),
+		Type:      strings.ToLower(strings.TrimSpace(typ)),
+	}, nil
+}
+
+// mdnMessageID returns a random Message-ID at the domain of addr.
+func mdnMessageID(addr string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(addr, "@"); i != -1 {
+		domain = addr[i+1:]
+	}
+	var r [12]byte
+	_, _ = io.ReadFull(internal.Rand(), r[:])
+	return fmt.Sprintf("<%x.mdn@%s>", r, domain)
+}
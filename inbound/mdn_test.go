@@ -0,0 +1,124 @@
+package inbound
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestMDNRoundTrip(t *testing.T) {
+	orig := types.Message{
+		From:    types.Address{Mail: "app@example.com"},
+		To:      []types.Address{{Mail: "ada@example.org"}},
+		Subject: "Contract",
+		Plain:   []byte("please confirm"),
+	}
+	built, err := internal.Build(context.Background(), orig, internal.BuildOptions{
+		ReadReceiptTo: "receipts@example.com",
+	})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if !bytes.Contains(built.Raw, []byte("Disposition-Notification-To: receipts@example.com\r\n")) ||
+		!bytes.Contains(built.Raw, []byte("Return-Receipt-To: receipts@example.com\r\n")) {
+		t.Fatalf("missing receipt headers:\n%s", built.Raw)
+	}
+
+	received, err := Parse(bytes.NewReader(built.Raw))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := ReceiptTo(received); got != "receipts@example.com" {
+		t.Fatalf("ReceiptTo = %q", got)
+	}
+	raw, err := BuildMDN(received, types.Address{Mail: "ada@example.org"}, MDN{
+		ReportingUA: "mail.example.org; test",
+	})
+	if err != nil {
+		t.Fatalf("build mdn: %v", err)
+	}
+	s := string(raw)
+	if !strings.Contains(s, "To: receipts@example.com\r\n") ||
+		!strings.Contains(s, "report-type=disposition-notification") ||
+		!strings.Contains(s, "In-Reply-To: "+built.MessageID+"\r\n") {
+		t.Fatalf("unexpected MDN:\n%s", s)
+	}
+
+	m, err := ParseMDN(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parse mdn: %v", err)
+	}
+	if m.OriginalMessageID != built.MessageID || m.FinalRecipient != "ada@example.org" ||
+		m.ReportingUA != "mail.example.org; test" ||
+		m.Disposition != (Disposition{Type: DispositionDisplayed}) ||
+		!strings.Contains(m.Text, "displayed") {
+		t.Fatalf("unexpected MDN: %+v", m)
+	}
+}
+
+func TestParseMDNExample(t *testing.T) {
+	// Adapted from RFC 8098 section 9.
+	raw := "Date: Wed, 20 Sep 1995 00:19:00 (EDT) -0400\r\n" +
+		"From: Joe Recipient <Joe_Recipient@example.com>\r\n" +
+		"Message-Id: <199509200019.12345@example.com>\r\n" +
+		"Subject: Disposition notification\r\n" +
+		"To: Jane Sender <Jane_Sender@example.org>\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/report; report-type=disposition-notification;\r\n" +
+		"\tboundary=\"RAA14128.773615765/example.com\"\r\n" +
+		"\r\n" +
+		"--RAA14128.773615765/example.com\r\n" +
+		"\r\n" +
+		"The message sent on 1995 Sep 19 at 13:30:00 (EDT) -0400 to Joe\r\n" +
+		"Recipient <Joe_Recipient@example.com> with subject \"First draft of\r\n" +
+		"report\" has been displayed.\r\n" +
+		"--RAA14128.773615765/example.com\r\n" +
+		"content-type: message/disposition-notification\r\n" +
+		"\r\n" +
+		"Reporting-UA: joes-pc.cs.example.com; Foomail 97.1\r\n" +
+		"Original-Recipient: rfc822;Joe_Recipient@example.com\r\n" +
+		"Final-Recipient: rfc822;Joe_Recipient@example.com\r\n" +
+		"Original-Message-ID: <199509192301.23456@example.org>\r\n" +
+		"Disposition: manual-action/MDN-sent-manually; displayed\r\n" +
+		"\r\n" +
+		"--RAA14128.773615765/example.com--\r\n"
+	m, err := ParseMDN(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if m.OriginalRecipient != "Joe_Recipient@example.com" ||
+		m.OriginalMessageID != "<199509192301.23456@example.org>" ||
+		m.Disposition.Type != DispositionDisplayed || m.Disposition.Automatic ||
+		!strings.HasPrefix(m.Text, "The message sent") {
+		t.Fatalf("unexpected MDN: %+v", m)
+	}
+
+	if _, err := ParseMDN(strings.NewReader("From: a@example.com\r\n\r\nhi")); err == nil {
+		t.Fatalf("expected error for non-MDN message")
+	}
+}
+
+func TestBuildMDNRequiresRequest(t *testing.T) {
+	msg := &types.Message{From: types.Address{Mail: "a@example.com"}}
+	if _, err := BuildMDN(msg, types.Address{Mail: "b@example.com"}, MDN{}); err == nil {
+		t.Fatalf("expected error without Disposition-Notification-To")
+	}
+}
+
+func TestBuildReadReceiptRejectsInjection(t *testing.T) {
+	msg := types.Message{
+		From:  types.Address{Mail: "a@example.com"},
+		To:    []types.Address{{Mail: "b@example.com"}},
+		Plain: []byte("hi"),
+	}
+	_, err := internal.BuildMIME(context.Background(), msg, internal.BuildOptions{
+		ReadReceiptTo: "r@example.com\r\nBcc: evil@example.com",
+	})
+	if err == nil {
+		t.Fatalf("expected invalid read receipt address to fail")
+	}
+}
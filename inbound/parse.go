@@ -0,0 +1,208 @@
+package inbound
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// maxDepth bounds multipart nesting.
+const maxDepth = 10
+
+// Parse reads a raw RFC 5322 message into a types.Message. Address
+// fields, Subject, Return-Path, In-Reply-To and References map to their
+// fields; all other header fields are kept in order, RFC 2047 decoded,
+// in Headers. The first text/plain and text/html parts that are not
+// attachments fill Plain and HTML; every other part becomes an
+// in-memory attachment. Transfer encodings are decoded, but text is
+// left in its declared charset and line endings are not changed.
+//
+// Parameters:
+//   - r: The raw message.
+//
+// Returns:
+//   - *types.Message: The parsed message.
+//   - error: An error if the message cannot be parsed.
+func Parse(r io.Reader) (*types.Message, error) {
+	br := bufio.NewReader(r)
+	hdr, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	msg := &types.Message{}
+	mimeHdr := textproto.MIMEHeader{}
+	for _, f := range hdr {
+		switch strings.ToLower(f.Name) {
+		case "from":
+			if as := parseAddrs(f.Value); len(as) > 0 {
+				msg.From, msg.ExtraFrom = as[0], as[1:]
+			}
+		case "sender":
+			if as := parseAddrs(f.Value); len(as) > 0 {
+				msg.Sender = as[0]
+			}
+		case "to":
+			msg.To = append(msg.To, parseAddrs(f.Value)...)
+		case "cc":
+			msg.Cc = append(msg.Cc, parseAddrs(f.Value)...)
+		case "bcc":
+			msg.Bcc = append(msg.Bcc, parseAddrs(f.Value)...)
+		case "reply-to":
+			msg.ReplyTo = append(msg.ReplyTo, parseAddrs(f.Value)...)
+		case "subject":
+			msg.Subject = decodeText(f.Value)
+		case "return-path":
+			msg.EnvelopeFrom = strings.Trim(f.Value, "<> ")
+		case "in-reply-to":
+			msg.InReplyTo = strings.TrimSpace(f.Value)
+		case "references":
+			msg.References = strings.Fields(f.Value)
+		case "mime-version":
+		case "content-type", "content-transfer-encoding",
+			"content-disposition", "content-id":
+			mimeHdr.Add(f.Name, f.Value)
+		default:
+			msg.Headers.Add(f.Name, decodeText(f.Value))
+		}
+	}
+	if err := parseEntity(msg, mimeHdr, br, 0); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// readHeader reads and unfolds header fields up to the blank line.
+func readHeader(r *bufio.Reader) (types.Headers, error) {
+	var h types.Headers
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("inbound: read header: %w", err)
+		}
+		eof := err == io.EOF
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			return h, nil
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(h) == 0 {
+				return nil, errors.New("inbound: continuation line before first header")
+			}
+			h[len(h)-1].Value += line
+		} else {
+			name, value, ok := strings.Cut(line, ":")
+			if !ok || strings.TrimSpace(name) == "" {
+				return nil, fmt.Errorf("inbound: malformed header line %q", line)
+			}
+			h.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+		if eof {
+			return h, nil
+		}
+	}
+}
+
+// parseEntity adds the body of one MIME entity to msg.
+func parseEntity(
+	msg *types.Message,
+	h textproto.MIMEHeader,
+	body io.Reader,
+	depth int,
+) error {
+	ctype := h.Get("Content-Type")
+	mt, params, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		// RFC 2045 5.2: default to text/plain.
+		mt, params, ctype = "text/plain", nil, "text/plain"
+	}
+	if strings.HasPrefix(mt, "multipart/") {
+		if depth >= maxDepth {
+			return errors.New("inbound: multipart nesting too deep")
+		}
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("inbound: read part: %w", err)
+			}
+			if err := parseEntity(msg, p.Header, p, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransfer(h.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("inbound: decode body: %w", err)
+	}
+	disp, dparams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	inline := disp != "attachment"
+	switch {
+	case inline && mt == "text/plain" && msg.Plain == nil:
+		msg.Plain = data
+	case inline && mt == "text/html" && msg.HTML == nil:
+		msg.HTML = data
+	default:
+		name := dparams["filename"]
+		if name == "" {
+			name = params["name"]
+		}
+		msg.Attach = append(msg.Attach, types.Attachment{
+			Filename:    decodeText(name),
+			ContentType: ctype,
+			ContentID:   strings.Trim(h.Get("Content-ID"), "<> "),
+			Reader:      bytes.NewReader(data),
+		})
+	}
+	return nil
+}
+
+// decodeTransfer wraps r with a Content-Transfer-Encoding decoder.
+func decodeTransfer(cte string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(cte)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// parseAddrs parses an address list leniently, skipping bad entries.
+// Groups are kept; see types.ParseAddressList.
+func parseAddrs(v string) []types.Address {
+	if out, err := types.ParseAddressList([]string{v}); err == nil {
+		return out
+	}
+	out := []types.Address{}
+	for _, part := range strings.Split(v, ",") {
+		if a, err := mail.ParseAddress(part); err == nil {
+			out = append(out, types.Address{Name: a.Name, Mail: a.Address})
+		}
+	}
+	return out
+}
+
+// decodeText decodes RFC 2047 encoded-words, returning v unchanged if
+// it cannot be decoded.
+func decodeText(v string) string {
+	s, err := new(mime.WordDecoder).DecodeHeader(v)
+	if err != nil {
+		return v
+	}
+	return s
+}
@@ -0,0 +1,102 @@
+package inbound
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestParseRoundTrip(t *testing.T) {
+	var h types.Headers
+	h.Add("X-Tag", "one")
+	h.Add("X-Tag", "two")
+	orig := types.Message{
+		From:       types.Address{Name: "Jörg Müller", Mail: "jorg@example.com"},
+		ExtraFrom:  []types.Address{{Mail: "anna@example.com"}},
+		Sender:     types.Address{Name: "Office", Mail: "office@example.com"},
+		To:         []types.Address{{Mail: "a@example.org"}, {Name: "B", Mail: "b@example.org"}},
+		Cc:         []types.Address{{Mail: "c@example.org"}},
+		Subject:    "Grüße aus Köln",
+		Plain:      []byte("hello\r\nworld"),
+		HTML:       []byte("<p>日本語のメールです</p>"),
+		InReplyTo:  "parent@example.com",
+		References: []string{"<root@example.com>", "<parent@example.com>"},
+		Headers:    h,
+		Attach: []types.Attachment{{
+			Filename: "report ü.txt", ContentType: "text/plain",
+			Reader: strings.NewReader("attached"),
+		}},
+	}
+	raw, err := internal.BuildMIME(context.Background(), orig, internal.BuildOptions{})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	msg, err := Parse(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if msg.From != orig.From || len(msg.ExtraFrom) != 1 || msg.ExtraFrom[0] != orig.ExtraFrom[0] ||
+		msg.Sender != orig.Sender || msg.Headers.Get("Sender") != "" || len(msg.To) != 2 || msg.To[1] != orig.To[1] ||
+		len(msg.Cc) != 1 || msg.Subject != orig.Subject {
+		t.Fatalf("unexpected addresses/subject: %+v", msg)
+	}
+	if string(msg.Plain) != "hello\r\nworld\r\n" || string(msg.HTML) != string(orig.HTML) {
+		t.Fatalf("unexpected bodies: %q %q", msg.Plain, msg.HTML)
+	}
+	if msg.InReplyTo != "<parent@example.com>" || len(msg.References) != 2 {
+		t.Fatalf("unexpected threading: %q %v", msg.InReplyTo, msg.References)
+	}
+	if got := msg.Headers.Values("X-Tag"); len(got) != 2 || got[1] != "two" ||
+		msg.Headers.Get("Message-ID") == "" {
+		t.Fatalf("unexpected headers: %v", msg.Headers)
+	}
+	if len(msg.Attach) != 1 || msg.Attach[0].Filename != "report ü.txt" {
+		t.Fatalf("unexpected attachments: %+v", msg.Attach)
+	}
+	if b, _ := io.ReadAll(msg.Attach[0].Reader); string(b) != "attached" {
+		t.Fatalf("unexpected attachment content: %q", b)
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	raw := "Return-Path: <bounce@example.com>\r\n" +
+		"From: sender@example.com\r\n" +
+		"To: ok@example.com, not an address, other@example.com\r\n" +
+		"Subject: folded\r\n subject\r\n" +
+		"\r\n" +
+		"body"
+	msg, err := Parse(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if msg.EnvelopeFrom != "bounce@example.com" || len(msg.To) != 2 ||
+		msg.Subject != "folded subject" || string(msg.Plain) != "body" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	if _, err := Parse(strings.NewReader(" continuation\r\n\r\n")); err == nil {
+		t.Fatalf("expected error for leading continuation line")
+	}
+	if _, err := Parse(strings.NewReader("no colon here\r\n\r\n")); err == nil {
+		t.Fatalf("expected error for malformed header")
+	}
+}
+
+func TestParseGroups(t *testing.T) {
+	raw := "From: sender@example.com\r\n" +
+		"To: undisclosed-recipients:;\r\n" +
+		"Cc: Team: a@example.org, b@example.org;\r\n" +
+		"\r\n" +
+		"body"
+	msg, err := Parse(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(msg.To) != 1 || msg.To[0] != (types.Address{Group: types.UndisclosedRecipients}) ||
+		len(msg.Cc) != 2 || msg.Cc[1] != (types.Address{Mail: "b@example.org", Group: "Team"}) {
+		t.Fatalf("unexpected recipients: %+v %+v", msg.To, msg.Cc)
+	}
+}
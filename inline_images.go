@@ -0,0 +1,190 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aatuh/email/v2/transform"
+	"github.com/aatuh/email/v2/types"
+)
+
+// InlineImageConfig controls InlineRemoteImages.
+type InlineImageConfig struct {
+	// AllowedHosts lists the hosts images may be fetched from, either
+	// exact ("cdn.example.com") or as a subdomain wildcard
+	// ("*.example.com"). Images on other hosts stay remote.
+	AllowedHosts []string
+	// MaxBytes caps each image (default 1 MiB); MaxTotalBytes caps all
+	// inlined images together (default 5 MiB).
+	MaxBytes      int64
+	MaxTotalBytes int64
+	// Client fetches the images; defaults to a client with a 10 second
+	// timeout. Redirects to hosts not in AllowedHosts are refused.
+	Client *http.Client
+}
+
+// InlineRemoteImages downloads the http(s) images referenced by <img
+// src> in msg.HTML from allowed hosts, attaches them as inline CID
+// parts and points src at them, so they render when recipients block
+// remote content. An image that cannot be fetched, is not an image or
+// exceeds a size limit is left remote. msg is not modified.
+//
+// Parameters:
+//   - ctx: The context for the downloads.
+//   - msg: The message.
+//   - cfg: The download settings.
+//
+// Returns:
+//   - types.Message: The message with images inlined.
+//   - error: The context error if ctx ends during the downloads.
+func InlineRemoteImages(
+	ctx context.Context,
+	msg types.Message,
+	cfg InlineImageConfig,
+) (types.Message, error) {
+	if len(msg.HTML) == 0 || len(cfg.AllowedHosts) == 0 {
+		return msg, nil
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 1 << 20
+	}
+	if cfg.MaxTotalBytes <= 0 {
+		cfg.MaxTotalBytes = 5 << 20
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	if cfg.Client != nil {
+		c := *cfg.Client
+		client = &c
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 || !cfg.allowed(req.URL) {
+			return errors.New("redirect not allowed")
+		}
+		return nil
+	}
+
+	// Collect the image URLs first, then fetch each once.
+	var urls []string
+	collect := transform.RewriteURLs(
+		func(tag, attr string, u *url.URL) (*url.URL, error) {
+			if tag == "img" && attr == "src" && cfg.allowed(u) {
+				urls = append(urls, u.String())
+			}
+			return u, nil
+		})
+	if _, err := collect.TransformHTML(ctx, msg.HTML); err != nil {
+		return msg, err
+	}
+
+	cids := map[string]string{}
+	var attach []types.Attachment
+	var total int64
+	for _, raw := range urls {
+		if _, done := cids[raw]; done {
+			continue
+		}
+		cids[raw] = ""
+		data, ctype, err := fetchImage(ctx, client, raw, cfg.MaxBytes)
+		if ctx.Err() != nil {
+			return msg, ctx.Err()
+		}
+		if err != nil || total+int64(len(data)) > cfg.MaxTotalBytes {
+			continue
+		}
+		total += int64(len(data))
+		sum := sha256.Sum256([]byte(raw))
+		cid := fmt.Sprintf("img-%x@inline", sum[:8])
+		cids[raw] = cid
+		u, _ := url.Parse(raw)
+		name := path.Base(u.Path)
+		if name == "/" || name == "." {
+			name = "image"
+		}
+		attach = append(attach, types.Attachment{
+			Filename:    name,
+			ContentType: ctype,
+			ContentID:   cid,
+			Reader:      bytes.NewReader(data),
+		})
+	}
+	if len(attach) == 0 {
+		return msg, nil
+	}
+
+	rewrite := transform.RewriteURLs(
+		func(tag, attr string, u *url.URL) (*url.URL, error) {
+			if tag != "img" || attr != "src" || cids[u.String()] == "" {
+				return u, nil
+			}
+			return &url.URL{Scheme: "cid", Opaque: cids[u.String()]}, nil
+		})
+	html, err := rewrite.TransformHTML(ctx, msg.HTML)
+	if err != nil {
+		return msg, err
+	}
+	msg.HTML = html
+	msg.Attach = append(append([]types.Attachment(nil), msg.Attach...), attach...)
+	return msg, nil
+}
+
+// fetchImage downloads an image of at most max bytes.
+func fetchImage(
+	ctx context.Context,
+	client *http.Client,
+	raw string,
+	max int64,
+) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, raw, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetch %s: %s", raw, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, max+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > max {
+		return nil, "", fmt.Errorf("fetch %s: larger than %d bytes", raw, max)
+	}
+	ctype, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(ctype, "image/") {
+		ctype, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+	if !strings.HasPrefix(ctype, "image/") {
+		return nil, "", fmt.Errorf("fetch %s: not an image", raw)
+	}
+	return data, ctype, nil
+}
+
+// allowed reports whether u is an http(s) URL on an allowed host.
+func (cfg InlineImageConfig) allowed(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range cfg.AllowedHosts {
+		h = strings.ToLower(h)
+		if host == h ||
+			strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestInlineRemoteImages(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/logo.png":
+			w.Write(png)
+		case "/big.png":
+			w.Write(bytes.Repeat(png, 100))
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<p>not an image</p>"))
+		case "/redirect.png":
+			http.Redirect(w, r, "https://evil.example/x.png", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	html := `<img src="` + srv.URL + `/logo.png"><img alt=again src="` + srv.URL + `/logo.png">` +
+		`<img src="` + srv.URL + `/big.png"><img src="` + srv.URL + `/page.html">` +
+		`<img src="` + srv.URL + `/missing.png"><img src="` + srv.URL + `/redirect.png">` +
+		`<img src="https://other.example/x.png"><a href="` + srv.URL + `/logo.png">link</a>`
+	msg := types.Message{
+		HTML:   []byte(html),
+		Attach: []types.Attachment{{Filename: "a.txt"}},
+	}
+	out, err := InlineRemoteImages(context.Background(), msg, InlineImageConfig{
+		AllowedHosts: []string{"127.0.0.1"},
+		MaxBytes:     1024,
+	})
+	if err != nil {
+		t.Fatalf("inline: %v", err)
+	}
+	if len(out.Attach) != 2 || len(msg.Attach) != 1 || string(msg.HTML) != html {
+		t.Fatalf("expected one new attachment and msg untouched: %+v", out.Attach)
+	}
+	a := out.Attach[1]
+	if a.Filename != "logo.png" || a.ContentType != "image/png" || a.ContentID == "" {
+		t.Fatalf("unexpected attachment: %+v", a)
+	}
+	if b, _ := io.ReadAll(a.Reader); !bytes.Equal(b, png) {
+		t.Fatalf("unexpected image content")
+	}
+	got := string(out.HTML)
+	if strings.Count(got, `src="cid:`+a.ContentID+`"`) != 2 ||
+		!strings.Contains(got, srv.URL+"/big.png") ||
+		!strings.Contains(got, srv.URL+"/page.html") ||
+		!strings.Contains(got, srv.URL+"/redirect.png") ||
+		!strings.Contains(got, `<a href="`+srv.URL+`/logo.png">`) {
+		t.Fatalf("unexpected html: %s", got)
+	}
+	// logo, big, page, missing and redirect are each fetched once.
+	if n := hits.Load(); n != 5 {
+		t.Fatalf("expected 5 fetches, got %d", n)
+	}
+
+	out, err = InlineRemoteImages(context.Background(), msg, InlineImageConfig{
+		AllowedHosts: []string{"*.example.com"},
+	})
+	if err != nil || len(out.Attach) != 1 {
+		t.Fatalf("expected no change for disallowed host: %v %+v", err, out.Attach)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := InlineRemoteImages(ctx, msg, InlineImageConfig{
+		AllowedHosts: []string{"127.0.0.1"},
+	}); err == nil {
+		t.Fatalf("expected context error")
+	}
+}
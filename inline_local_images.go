@@ -0,0 +1,153 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/aatuh/email/v2/transform"
+	"github.com/aatuh/email/v2/types"
+)
+
+// LocalImageConfig controls InlineLocalImages.
+type LocalImageConfig struct {
+	// FS holds the images. Paths resolve inside it, so nothing outside
+	// FS can be attached; use os.DirFS for a directory.
+	FS fs.FS
+	// Scheme marks local image references; defaults to "file", as in
+	// "file:///img/logo.png". With a custom scheme such as "asset",
+	// "asset:img/logo.png" and "asset://img/logo.png" both name
+	// img/logo.png in FS.
+	Scheme string
+	// MaxBytes caps each image (default 1 MiB).
+	MaxBytes int64
+}
+
+// InlineLocalImages attaches the images referenced by <img src> with
+// cfg.Scheme in msg.HTML as inline CID parts and points src at them, so
+// HTML can refer to image files without managing Content-IDs. Each file
+// is attached once, however often it is referenced. msg is not
+// modified.
+//
+// Parameters:
+//   - ctx: The context.
+//   - msg: The message.
+//   - cfg: The image source.
+//
+// Returns:
+//   - types.Message: The message with images inlined.
+//   - error: The error if an image is missing, too large or not an
+//     image.
+func InlineLocalImages(
+	ctx context.Context,
+	msg types.Message,
+	cfg LocalImageConfig,
+) (types.Message, error) {
+	if len(msg.HTML) == 0 {
+		return msg, nil
+	}
+	if cfg.Scheme == "" {
+		cfg.Scheme = "file"
+	}
+	cfg.Scheme = strings.ToLower(cfg.Scheme)
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 1 << 20
+	}
+
+	cids := map[string]string{} // FS path -> Content-ID
+	var attach []types.Attachment
+	rewrite := transform.RewriteURLs(
+		func(tag, attr string, u *url.URL) (*url.URL, error) {
+			if tag != "img" || attr != "src" || u.Scheme != cfg.Scheme {
+				return u, nil
+			}
+			name, err := cfg.path(u)
+			if err != nil {
+				return nil, err
+			}
+			cid, ok := cids[name]
+			if !ok {
+				a, err := cfg.attachment(name)
+				if err != nil {
+					return nil, err
+				}
+				sum := sha256.Sum256([]byte(name))
+				cid = fmt.Sprintf("img-%x@inline", sum[:8])
+				a.ContentID = cid
+				cids[name] = cid
+				attach = append(attach, a)
+			}
+			return &url.URL{Scheme: "cid", Opaque: cid}, nil
+		})
+	html, err := rewrite.TransformHTML(ctx, msg.HTML)
+	if err != nil {
+		return msg, err
+	}
+	if len(attach) == 0 {
+		return msg, nil
+	}
+	msg.HTML = html
+	msg.Attach = append(append([]types.Attachment(nil), msg.Attach...), attach...)
+	return msg, nil
+}
+
+// path returns the FS path a local image URL refers to.
+func (cfg LocalImageConfig) path(u *url.URL) (string, error) {
+	var p string
+	switch {
+	case u.Opaque != "":
+		p = u.Opaque
+	case cfg.Scheme == "file":
+		if u.Host != "" && u.Host != "localhost" {
+			return "", fmt.Errorf("inline image %s: remote file host", u)
+		}
+		p = u.Path
+	default:
+		p = u.Host + u.Path
+	}
+	p = strings.TrimPrefix(p, "/")
+	if !fs.ValidPath(p) || p == "." {
+		return "", fmt.Errorf("inline image %s: invalid path", u)
+	}
+	return p, nil
+}
+
+// attachment reads the image name from cfg.FS.
+func (cfg LocalImageConfig) attachment(name string) (types.Attachment, error) {
+	if cfg.FS == nil {
+		return types.Attachment{}, fmt.Errorf("inline image %s: no FS", name)
+	}
+	f, err := cfg.FS.Open(name)
+	if err != nil {
+		return types.Attachment{}, fmt.Errorf("inline image: %w", err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, cfg.MaxBytes+1))
+	if err != nil {
+		return types.Attachment{}, fmt.Errorf("inline image %s: %w", name, err)
+	}
+	if int64(len(data)) > cfg.MaxBytes {
+		return types.Attachment{}, fmt.Errorf("inline image %s: larger than %d bytes",
+			name, cfg.MaxBytes)
+	}
+	ctype := mime.TypeByExtension(path.Ext(name))
+	if !strings.HasPrefix(ctype, "image/") {
+		ctype, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+	if !strings.HasPrefix(ctype, "image/") {
+		return types.Attachment{}, fmt.Errorf("inline image %s: not an image", name)
+	}
+	return types.Attachment{
+		Filename:    path.Base(name),
+		ContentType: ctype,
+		Reader:      bytes.NewReader(data),
+	}, nil
+}
@@ -0,0 +1,56 @@
+package email
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestInlineLocalImages(t *testing.T) {
+	assets := fstest.MapFS{
+		"img/logo.png": {Data: []byte("\x89PNG\r\n\x1a\n")},
+		"badge":        {Data: []byte("GIF89a")},
+		"notes.txt":    {Data: []byte("hello")},
+		"big.png":      {Data: make([]byte, 2048)},
+	}
+	ctx := context.Background()
+	msg := types.Message{HTML: []byte(`<img src="file:///img/logo.png"><img src="file:///img/logo.png">` +
+		`<a href="file:///img/logo.png">x</a><img src="https://cdn.example.com/a.png">`)}
+
+	out, err := InlineLocalImages(ctx, msg, LocalImageConfig{FS: assets})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Attach) != 1 || len(msg.Attach) != 0 {
+		t.Fatalf("attachments = %+v", out.Attach)
+	}
+	a := out.Attach[0]
+	if a.Filename != "logo.png" || a.ContentType != "image/png" || a.ContentID == "" {
+		t.Fatalf("attachment = %+v", a)
+	}
+	cid := `src="cid:` + a.ContentID + `"`
+	html := string(out.HTML)
+	if strings.Count(html, cid) != 2 || !strings.Contains(html, `href="file:///img/logo.png"`) ||
+		!strings.Contains(html, "https://cdn.example.com/a.png") {
+		t.Fatalf("html = %s", html)
+	}
+
+	// A custom scheme, with the type sniffed from the content.
+	msg.HTML = []byte(`<img src="asset:badge"><img src="asset://img/logo.png">`)
+	out, err = InlineLocalImages(ctx, msg, LocalImageConfig{FS: assets, Scheme: "asset"})
+	if err != nil || len(out.Attach) != 2 || out.Attach[0].ContentType != "image/gif" {
+		t.Fatalf("custom scheme: %+v %v", out.Attach, err)
+	}
+
+	for _, src := range []string{"file:///missing.png", "file:///notes.txt",
+		"file:///big.png", "file://host/img/logo.png", "file:///../etc/passwd"} {
+		msg.HTML = []byte(`<img src="` + src + `">`)
+		_, err := InlineLocalImages(ctx, msg, LocalImageConfig{FS: assets, MaxBytes: 1024})
+		if err == nil {
+			t.Fatalf("expected error for %s", src)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// Content is a message prepared for an HTTP API adapter: the HTML body
+// has been sanitized and transformed, attachments are read and base64
+// encoded, and the headers the builder would add are collected.
+type Content struct {
+	Plain   []byte
+	HTML    []byte
+	Attach  []APIAttachment
+	Headers types.Headers // custom and threading headers, in order
+}
+
+// APIAttachment is an attachment encoded for a JSON API.
+type APIAttachment struct {
+	Filename    string
+	ContentType string
+	ContentID   string // empty for regular attachments
+	Base64      string
+}
+
+// PrepareContent validates msg and prepares it for an API adapter.
+// opts are applied as by Build where an API can express them; DKIM,
+// charsets and transfer encodings are left to the provider. If
+// template is true, the provider renders the body, so msg may have
+// none. Like Build, it works on a clone of msg.
+func PrepareContent(
+	ctx context.Context,
+	msg types.Message,
+	opts BuildOptions,
+	template bool,
+) (*Content, error) {
+	msg = msg.Clone()
+	v := msg
+	if template && len(v.Plain) == 0 && len(v.HTML) == 0 {
+		v.Plain = []byte(" ")
+	}
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+	if len(msg.ExtraFrom) > 0 {
+		return nil, errors.New("email: multiple From addresses are not supported by this API")
+	}
+	if err := types.ValidateHeaderValue("List-Unsubscribe", opts.ListUnsub); err != nil {
+		return nil, err
+	}
+	if err := types.ValidateHeaderValue("BIMI-Selector", opts.BIMISelector); err != nil {
+		return nil, err
+	}
+	if opts.ReadReceiptTo != "" {
+		if _, err := mail.ParseAddress(opts.ReadReceiptTo); err != nil {
+			return nil, &types.HeaderError{
+				Field: "Disposition-Notification-To", Reason: err.Error(),
+			}
+		}
+	}
+	if opts.AttachmentPolicy != nil {
+		for _, a := range msg.Attach {
+			if err := opts.AttachmentPolicy.Check(a.Filename); err != nil {
+				return nil, err
+			}
+		}
+	}
+	hooks := opts.Hooks
+	fail := func(err error) (*Content, error) {
+		if hooks != nil && hooks.OnBuildDone != nil {
+			hooks.OnBuildDone(ctx, &msg, 0, err)
+		}
+		return nil, err
+	}
+	if hooks != nil && hooks.OnBuildStart != nil {
+		ctx = hooks.OnBuildStart(ctx, &msg)
+	}
+
+	c := &Content{Plain: msg.Plain, HTML: msg.HTML}
+	if opts.SanitizeHTML != nil && len(c.HTML) > 0 {
+		c.HTML = opts.SanitizeHTML(c.HTML)
+	}
+	var err error
+	for _, t := range opts.Transforms {
+		if len(c.HTML) == 0 {
+			break
+		}
+		if c.HTML, err = t.TransformHTML(ctx, c.HTML); err != nil {
+			return fail(fmt.Errorf("email: html transform: %w", err))
+		}
+	}
+	attach, err := ScanAttachments(ctx, msg.Attach, opts.AttachmentScanner)
+	if err != nil {
+		return fail(err)
+	}
+	size := len(c.Plain) + len(c.HTML)
+	for _, a := range attach {
+		ct := a.ContentType
+		if ct == "" {
+			ct, a.Reader = sniffContentType(a)
+		}
+		var data []byte
+		if a.Reader != nil {
+			if data, err = io.ReadAll(a.Reader); err != nil {
+				return fail(fmt.Errorf("read attachment %q: %w",
+					a.Filename, err))
+			}
+		}
+		enc := base64.StdEncoding.EncodeToString(data)
+		if n := int64(len(enc)); opts.MaxAttachmentSize > 0 &&
+			n > opts.MaxAttachmentSize {
+			return fail(&types.SizeError{
+				Attachment: a.Filename, Size: n,
+				Limit: opts.MaxAttachmentSize,
+			})
+		}
+		size += len(enc)
+		c.Attach = append(c.Attach, APIAttachment{
+			Filename: a.Filename, ContentType: ct,
+			ContentID: a.ContentID, Base64: enc,
+		})
+	}
+
+	if msg.Sender.Mail != "" {
+		setHeader(&c.Headers, "Sender", msg.Sender.String())
+	}
+	setHeader(&c.Headers, "In-Reply-To", formatMsgID(msg.InReplyTo))
+	if len(msg.References) > 0 {
+		var refs []string
+		for _, r := range msg.References {
+			if id := formatMsgID(r); id != "" {
+				refs = append(refs, id)
+			}
+		}
+		setHeader(&c.Headers, "References", strings.Join(refs, " "))
+	}
+	setHeader(&c.Headers, "Disposition-Notification-To", opts.ReadReceiptTo)
+	setHeader(&c.Headers, "Return-Receipt-To", opts.ReadReceiptTo)
+	listUnsub := opts.ListUnsub
+	if listUnsub == "" {
+		listUnsub = msg.Headers.Get("List-Unsubscribe")
+	}
+	setHeader(&c.Headers, "List-Unsubscribe", listUnsub)
+	if opts.BIMISelector != "" {
+		setHeader(&c.Headers, "BIMI-Selector", "v=BIMI1; s="+opts.BIMISelector)
+	}
+	if msg.TrackingID != "" {
+		setHeader(&c.Headers, "X-Tracking-ID", sanitizeHeader(msg.TrackingID))
+	}
+	core := c.Headers.Clone()
+	for _, f := range msg.Headers {
+		if !core.Has(f.Name) && f.Value != "" {
+			c.Headers.Add(f.Name, f.Value)
+		}
+	}
+
+	if hooks != nil && hooks.OnBuildDone != nil {
+		hooks.OnBuildDone(ctx, &msg, size, nil)
+	}
+	return c, nil
+}
+
+// Sizes returns the encoded size of each attachment.
+//
+// Returns:
+//   - []int64: The sizes in attachment order.
+func (c *Content) Sizes() []int64 {
+	out := make([]int64, len(c.Attach))
+	for i, a := range c.Attach {
+		out[i] = int64(len(a.Base64))
+	}
+	return out
+}
+
+// PostJSON posts body to url and returns the response status and body.
+// Transport errors are returned as is; the caller interprets the status.
+func PostJSON(
+	ctx context.Context,
+	client *http.Client,
+	url string,
+	header http.Header,
+	body []byte,
+) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url,
+		bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	// Provider replies are small; cap them in case of a misbehaving
+	// proxy.
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return resp.StatusCode, data, err
+}
+
+// Backoff mirrors email.Backoff so adapters can pass it through.
+type Backoff interface {
+	Next(i int) (time.Duration, bool)
+}
+
+// Retry calls try on the schedule of bo (a single attempt if nil)
+// until it succeeds or fails with an error transient rejects, running
+// the attempt hooks around each call. It returns the number of
+// attempts made.
+func Retry(
+	ctx context.Context,
+	bo Backoff,
+	hooks *types.Hooks,
+	transient func(error) bool,
+	try func(ctx context.Context) error,
+) (int, error) {
+	var last error
+	for attempt := 0; ; attempt++ {
+		if hooks != nil && hooks.OnAttemptStart != nil {
+			ctx = hooks.OnAttemptStart(ctx, attempt)
+		}
+		d, ok := time.Duration(0), attempt == 0
+		if bo != nil {
+			d, ok = bo.Next(attempt)
+		}
+		if !ok {
+			if hooks != nil && hooks.OnAttemptDone != nil {
+				hooks.OnAttemptDone(ctx, attempt,
+					fmt.Errorf("attempts exhausted"))
+			}
+			return attempt, fmt.Errorf(
+				"send attempts exhausted after %d tries: %w", attempt, last)
+		}
+		if d > 0 {
+			select {
+			case <-time.After(d):
+			case <-ctx.Done():
+				if hooks != nil && hooks.OnAttemptDone != nil {
+					hooks.OnAttemptDone(ctx, attempt, ctx.Err())
+				}
+				return attempt, ctx.Err()
+			}
+		}
+		last = try(ctx)
+		if hooks != nil && hooks.OnAttemptDone != nil {
+			hooks.OnAttemptDone(ctx, attempt, last)
+		}
+		if last == nil || !transient(last) {
+			return attempt + 1, last
+		}
+	}
+}
+
+// IsTransientAPI reports whether a failed API request may be retried:
+// temporary API errors and transport errors, unless the caller's
+// context was canceled.
+func IsTransientAPI(err error) bool {
+	var apiErr *types.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	return !errors.Is(err, context.Canceled)
+}
@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestPrepareContent(t *testing.T) {
+	msg := types.Message{
+		From:       types.Address{Mail: "a@example.com"},
+		To:         []types.Address{{Mail: "b@example.com"}},
+		HTML:       []byte(`<a href="http://example.com">x</a>`),
+		References: []string{"one@example.com", "<two@example.com>"},
+		TrackingID: "t-1",
+		Headers:    types.Headers{{Name: "X-A", Value: "1"}, {Name: "X-A", Value: "2"}},
+		Attach:     []types.Attachment{{Filename: "a.txt", Reader: strings.NewReader("attached")}},
+	}
+	var done int
+	c, err := PrepareContent(context.Background(), msg, BuildOptions{
+		Transforms: []types.BuildTransform{types.BuildTransformFunc(
+			func(_ context.Context, html []byte) ([]byte, error) {
+				return []byte(strings.ReplaceAll(string(html), "http:", "https:")), nil
+			})},
+		Hooks: &types.Hooks{OnBuildDone: func(_ context.Context, _ *types.Message, size int, err error) {
+			done = size
+		}},
+	}, false)
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	if string(c.HTML) != `<a href="https://example.com">x</a>` || string(msg.HTML) == string(c.HTML) {
+		t.Fatalf("unexpected html: %s", c.HTML)
+	}
+	if c.Headers.Get("References") != "<one@example.com> <two@example.com>" ||
+		c.Headers.Get("X-Tracking-ID") != "t-1" || len(c.Headers.Values("X-A")) != 2 {
+		t.Fatalf("unexpected headers: %+v", c.Headers)
+	}
+	a := c.Attach[0]
+	if a.ContentType != "text/plain; charset=utf-8" || a.Base64 != "YXR0YWNoZWQ=" ||
+		c.Sizes()[0] != 12 || done != len(c.HTML)+12 {
+		t.Fatalf("unexpected attachment: %+v %d", a, done)
+	}
+
+	msg.Sender = types.Address{Mail: "pa@example.com"}
+	if c, err = PrepareContent(context.Background(), msg, BuildOptions{}, false); err != nil ||
+		c.Headers.Get("Sender") != "pa@example.com" {
+		t.Fatalf("unexpected Sender: %v %+v", err, c)
+	}
+	msg.ExtraFrom = []types.Address{{Mail: "c@example.com"}}
+	if _, err := PrepareContent(context.Background(), msg, BuildOptions{}, false); err == nil {
+		t.Fatal("expected error for multiple From")
+	}
+	msg.Sender, msg.ExtraFrom = types.Address{}, nil
+
+	msg.HTML, msg.Attach = nil, nil
+	if _, err := PrepareContent(context.Background(), msg, BuildOptions{}, false); err == nil {
+		t.Fatalf("expected missing body error")
+	}
+	if _, err := PrepareContent(context.Background(), msg, BuildOptions{}, true); err != nil {
+		t.Fatalf("template send needs no body: %v", err)
+	}
+	msg.Attach = []types.Attachment{{Filename: "big.bin", Reader: strings.NewReader("0123456789")}}
+	var sizeErr *types.SizeError
+	if _, err := PrepareContent(context.Background(), msg,
+		BuildOptions{MaxAttachmentSize: 8}, true); !errors.As(err, &sizeErr) ||
+		sizeErr.Attachment != "big.bin" {
+		t.Fatalf("expected size error, got %v", err)
+	}
+}
+
+type fixedBackoff int
+
+func (b fixedBackoff) Next(i int) (time.Duration, bool) { return time.Millisecond, i < int(b) }
+
+func TestRetry(t *testing.T) {
+	temp := errors.New("temporary")
+	isTemp := func(err error) bool { return errors.Is(err, temp) }
+	var calls int
+	n, err := Retry(context.Background(), fixedBackoff(3), nil, isTemp,
+		func(context.Context) error {
+			calls++
+			if calls < 2 {
+				return temp
+			}
+			return nil
+		})
+	if err != nil || n != 2 {
+		t.Fatalf("expected success on attempt 2, got %d %v", n, err)
+	}
+
+	var started, finished int
+	hooks := &types.Hooks{
+		OnAttemptStart: func(ctx context.Context, _ int) context.Context { started++; return ctx },
+		OnAttemptDone:  func(context.Context, int, error) { finished++ },
+	}
+	n, err = Retry(context.Background(), nil, hooks, isTemp,
+		func(context.Context) error { return temp })
+	if !errors.Is(err, temp) || n != 1 || started != 2 || finished != 2 {
+		t.Fatalf("expected one attempt without backoff: %d %v %d/%d", n, err, started, finished)
+	}
+
+	n, err = Retry(context.Background(), fixedBackoff(3), nil, isTemp,
+		func(context.Context) error { return errors.New("permanent") })
+	if err == nil || n != 1 {
+		t.Fatalf("expected permanent error to stop retries: %d %v", n, err)
+	}
+}
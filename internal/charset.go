@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// defaultCharset is the charset of text parts unless one is configured.
+const defaultCharset = "UTF-8"
+
+// charset returns the configured text charset.
+func (o BuildOptions) charset() string {
+	if o.Charset == "" {
+		return defaultCharset
+	}
+	return o.Charset
+}
+
+// isUTF8 reports whether the output charset is UTF-8.
+func (o BuildOptions) isUTF8() bool {
+	cs := strings.ToUpper(o.charset())
+	return cs == "UTF-8" || cs == "UTF8"
+}
+
+// transcode converts UTF-8 text to the configured charset.
+func (o BuildOptions) transcode(b []byte) ([]byte, error) {
+	if o.isUTF8() || len(b) == 0 {
+		return b, nil
+	}
+	if o.CharsetEncoder == nil {
+		return nil, fmt.Errorf("email: no encoder for charset %q", o.Charset)
+	}
+	out, err := o.CharsetEncoder(b)
+	if err != nil {
+		return nil, fmt.Errorf("email: encode %s: %w", o.Charset, err)
+	}
+	return out, nil
+}
+
+// encodeHeaderText returns s as RFC 2047 encoded-words if it contains
+// non-ASCII characters, or unchanged otherwise. UTF-8 text uses Q
+// encoding split into words of at most 75 characters; other charsets
+// use a single B-encoded word, since splitting a stateful charset such
+// as ISO-2022-JP needs charset knowledge.
+func (o BuildOptions) encodeHeaderText(s string) (string, error) {
+	if o.isUTF8() {
+		return mime.QEncoding.Encode("UTF-8", s), nil
+	}
+	b, err := o.transcode([]byte(s))
+	if err != nil {
+		return "", err
+	}
+	return mime.BEncoding.Encode(o.charset(), string(b)), nil
+}
+
+// textType returns the Content-Type of a text part.
+func textType(subtype, charset string) string {
+	return fmt.Sprintf(`text/%s; charset="%s"`, subtype, charset)
+}
+
+// validCharset reports whether name is a valid charset token (RFC 2978).
+func validCharset(name string) bool {
+	if name == "" || len(name) > 40 {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+			c >= '0' && c <= '9' || strings.ContainsRune("!#$%&'+-^_`{}~.:", c)) {
+			return false
+		}
+	}
+	return true
+}
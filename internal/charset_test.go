@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// iso2022jp encodes the few kanji used in tests as JIS X 0208.
+func iso2022jp(b []byte) ([]byte, error) {
+	jis := map[rune]string{'日': "F|", '本': "K\\", '語': "8l"}
+	var out strings.Builder
+	kanji := false
+	for _, r := range string(b) {
+		code, ok := jis[r]
+		switch {
+		case ok && !kanji:
+			out.WriteString("\x1b$B")
+			kanji = true
+		case !ok && kanji:
+			out.WriteString("\x1b(B")
+			kanji = false
+		}
+		if !ok && r > 127 {
+			return nil, errors.New("unmappable rune")
+		}
+		if ok {
+			out.WriteString(code)
+		} else {
+			out.WriteRune(r)
+		}
+	}
+	if kanji {
+		out.WriteString("\x1b(B")
+	}
+	return []byte(out.String()), nil
+}
+
+// latin1 encodes runes below 256 as single bytes.
+func latin1(b []byte) ([]byte, error) {
+	var out []byte
+	for _, r := range string(b) {
+		if r > 255 {
+			return nil, errors.New("unmappable rune")
+		}
+		out = append(out, byte(r))
+	}
+	return out, nil
+}
+
+func TestBuildCharsetISO2022JP(t *testing.T) {
+	msg := types.Message{
+		From:    types.Address{Mail: "no-reply@example.jp"},
+		To:      []types.Address{{Mail: "to@example.jp"}},
+		Subject: "日本語",
+		Plain:   []byte("日本語\n"),
+	}
+	b, err := BuildMIME(context.Background(), msg, BuildOptions{
+		Charset: "ISO-2022-JP", CharsetEncoder: iso2022jp,
+	})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	s := string(b)
+	if !strings.Contains(s, "Content-Type: text/plain; charset=\"ISO-2022-JP\"\r\n") ||
+		!strings.Contains(s, "Content-Transfer-Encoding: 7bit\r\n") ||
+		!strings.Contains(s, "\r\n\r\n\x1b$BF|K\\8l\x1b(B\r\n") {
+		t.Fatalf("unexpected ISO-2022-JP message:\n%q", s)
+	}
+	want := "=?ISO-2022-JP?b?" +
+		base64.StdEncoding.EncodeToString([]byte("\x1b$BF|K\\8l\x1b(B")) + "?="
+	if !strings.Contains(s, "Subject: "+want+"\r\n") {
+		t.Fatalf("unexpected Subject, want %s:\n%s", want, s)
+	}
+}
+
+func TestBuildCharsetLatin1(t *testing.T) {
+	msg := types.Message{
+		From:  types.Address{Mail: "no-reply@example.com"},
+		To:    []types.Address{{Mail: "to@example.com"}},
+		Plain: []byte("Grüße"),
+		HTML:  []byte("<p>Grüße</p>"),
+	}
+	b, err := BuildMIME(context.Background(), msg, BuildOptions{
+		Charset: "ISO-8859-1", CharsetEncoder: latin1,
+	})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	s := string(b)
+	if strings.Count(s, "charset=\"ISO-8859-1\"") != 2 ||
+		!strings.Contains(s, "Gr=FC=DFe") {
+		t.Fatalf("unexpected Latin-1 message:\n%s", s)
+	}
+}
+
+func TestBuildCharsetErrors(t *testing.T) {
+	msg := types.Message{
+		From:  types.Address{Mail: "no-reply@example.com"},
+		To:    []types.Address{{Mail: "to@example.com"}},
+		Plain: []byte("€"),
+	}
+	for name, opts := range map[string]BuildOptions{
+		"no encoder":   {Charset: "ISO-8859-1"},
+		"unmappable":   {Charset: "ISO-8859-1", CharsetEncoder: latin1},
+		"invalid name": {Charset: "x\"\r\nBcc: a@b", CharsetEncoder: latin1},
+	} {
+		if _, err := BuildMIME(context.Background(), msg, opts); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := BuildMIME(context.Background(), msg, BuildOptions{Charset: "utf-8"}); err != nil {
+		t.Errorf("utf-8 needs no encoder: %v", err)
+	}
+}
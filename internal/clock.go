@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// clock holds the function behind Now; nil means time.Now.
+var clock atomic.Pointer[func() time.Time]
+
+// Now returns the current time from the clock set with SetClock.
+func Now() time.Time {
+	if now := clock.Load(); now != nil {
+		return (*now)()
+	}
+	return time.Now()
+}
+
+// SetClock replaces the clock behind Now and returns the previous one.
+// nil restores time.Now.
+func SetClock(now func() time.Time) func() time.Time {
+	var old *func() time.Time
+	if now == nil {
+		old = clock.Swap(nil)
+	} else {
+		old = clock.Swap(&now)
+	}
+	if old == nil {
+		return nil
+	}
+	return *old
+}
@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestClockDrivesDateAndMessageID(t *testing.T) {
+	fixed := time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+	old := SetClock(func() time.Time { return fixed })
+	defer SetClock(old)
+	if !Now().Equal(fixed) {
+		t.Fatalf("Now() = %v", Now())
+	}
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("hi"),
+	}
+	raw, err := BuildMIME(context.Background(), msg, BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), "Date: Sat, 03 Feb 2024 04:05:06 +0000\r\n") ||
+		!strings.Contains(string(raw), fmt.Sprintf("Message-ID: <%x", fixed.UnixNano())) {
+		t.Fatalf("clock not used:\n%s", raw)
+	}
+
+	// Message.Date wins over the clock.
+	msg.Date = time.Date(2001, 9, 9, 3, 46, 40, 0, time.FixedZone("EEST", 3*3600))
+	raw, err = BuildMIME(context.Background(), msg, BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), "Date: Sun, 09 Sep 2001 00:46:40 +0000\r\n") {
+		t.Fatalf("Message.Date not used:\n%s", raw)
+	}
+
+	if prev := SetClock(nil); prev == nil || !prev().Equal(fixed) {
+		t.Fatal("SetClock should return the previous clock")
+	}
+	if time.Since(Now()) > time.Minute {
+		t.Fatal("nil should restore time.Now")
+	}
+}
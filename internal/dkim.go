@@ -3,6 +3,7 @@ package internal
 import (
 	"bytes"
 	"crypto"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
@@ -10,6 +11,7 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -17,61 +19,128 @@ import (
 	"github.com/aatuh/email/v2/types"
 )
 
-// buildDKIMSignature creates the DKIM-Signature header value for the
-// given headers map and body bytes using relaxed/relaxed c14n and
-// rsa-sha256. Only standard library is used.
+// BuildDKIMSignature creates the DKIM-Signature header value for the
+// given headers map and body bytes using rsa-sha256 or ed25519-sha256
+// (depending on the key) and the configured
+// canonicalization (relaxed/relaxed by default). The returned value is
+// pre-folded (contains CRLF + WSP) and must be written verbatim so that
+// simple header canonicalization matches the bytes on the wire. Only
+// standard library is used.
 func BuildDKIMSignature(
-	headers map[string]string,
+	headers types.Headers,
 	body []byte,
 	cfg types.DKIMConfig,
 ) (string, error) {
-	if cfg.Domain == "" || cfg.Selector == "" || len(cfg.KeyPEM) == 0 {
-		return "", errors.New("dkim: incomplete config")
+	sig, _, err := buildDKIMSignature(headers, body, cfg)
+	return sig, err
+}
+
+// buildDKIMSignature is BuildDKIMSignature that also returns the names
+// in cfg.Headers that the headers lack.
+func buildDKIMSignature(
+	headers types.Headers,
+	body []byte,
+	cfg types.DKIMConfig,
+) (string, []string, error) {
+	if cfg.Domain == "" || cfg.Selector == "" ||
+		(cfg.Signer == nil && len(cfg.KeyPEM) == 0) {
+		return "", nil, errors.New("dkim: incomplete config")
+	}
+	hc, bc, err := dkimCanon(cfg)
+	if err != nil {
+		return "", nil, err
+	}
+	key := cfg.Signer
+	if key == nil {
+		key, err = ParseDKIMKey(cfg.KeyPEM)
+		if err != nil {
+			return "", nil, fmt.Errorf("dkim: parse key: %w", err)
+		}
 	}
-	key, err := parseRSAPrivateKey(cfg.KeyPEM)
+	alg, err := dkimAlgorithm(key.Public())
 	if err != nil {
-		return "", fmt.Errorf("dkim: parse key: %w", err)
+		return "", nil, err
+	}
+	opts, err := dkimSignerOpts(key.Public(), cfg.RSAPadding)
+	if err != nil {
+		return "", nil, err
 	}
 
-	// Canonicalize body (relaxed) and compute bh=
-	cBody := dkimCanonicalizeBodyRelaxed(body)
+	// Canonicalize body and compute bh=, honoring the l= limit.
+	var cBody []byte
+	if bc == types.DKIMCanonSimple {
+		cBody = dkimCanonicalizeBodySimple(body)
+	} else {
+		cBody = dkimCanonicalizeBodyRelaxed(body)
+	}
+	if cfg.BodyLength > 0 && cfg.BodyLength < int64(len(cBody)) {
+		cBody = cBody[:cfg.BodyLength]
+	}
 	bh := sha256.Sum256(cBody)
 	bhB64 := base64.StdEncoding.EncodeToString(bh[:])
 
-	// Determine header list to sign in order. If cfg.Headers is empty,
-	// sign a sensible default subset commonly used.
-	hlist := cfg.Headers
-	if len(hlist) == 0 {
-		hlist = []string{
-			"from", "to", "subject", "date",
-			"mime-version", "content-type", "message-id",
-		}
+	// Determine header list to sign in order.
+	hlist, err := dkimHeaderList(headers, cfg)
+	if err != nil {
+		return "", nil, err
 	}
-	// Take only headers present; keep requested order.
-	var signedNames []string
-	var signedLines []string
+	// Take only headers present; keep requested order. Repeated names
+	// select instances from the bottom up, as verifiers do (RFC 6376
+	// 5.4.2).
+	var signedNames, signedLines, missing []string
+	used := map[int]bool{}
 	for _, name := range hlist {
-		hn := headerLookup(headers, name)
-		if hn == "" {
+		i := lastUnused(headers, name, used)
+		if i < 0 {
+			if len(cfg.Headers) > 0 && !headers.Has(name) &&
+				!slices.Contains(missing, name) {
+				missing = append(missing, name)
+			}
 			continue
 		}
-		val := headers[hn]
-		signedNames = append(signedNames, strings.ToLower(hn))
-		signedLines = append(signedLines, dkimCanonHeaderRelaxed(hn, val))
+		used[i] = true
+		f := headers[i]
+		signedNames = append(signedNames, strings.ToLower(f.Name))
+		if hc == types.DKIMCanonSimple {
+			signedLines = append(signedLines, foldHeader(f.Name, f.Value))
+		} else {
+			signedLines = append(signedLines,
+				dkimCanonHeaderRelaxed(f.Name, f.Value)+"\r\n")
+		}
+	}
+	// An h= name without an unsigned instance left signs as empty, so
+	// one extra entry per name makes any added instance break the
+	// signature.
+	if cfg.Oversign {
+		seen := map[string]bool{}
+		for _, name := range hlist {
+			name = strings.ToLower(name)
+			if !seen[name] {
+				seen[name] = true
+				signedNames = append(signedNames, name)
+			}
+		}
 	}
 
 	// Prepare DKIM-Signature header (without b= value).
-	now := time.Now().Unix()
+	now := Now().Unix()
 	dkimFields := map[string]string{
 		"v":  "1",
-		"a":  "rsa-sha256",
-		"c":  "relaxed/relaxed",
+		"a":  alg,
+		"c":  hc + "/" + bc,
 		"d":  cfg.Domain,
 		"s":  cfg.Selector,
 		"t":  fmt.Sprintf("%d", now),
 		"bh": bhB64,
 		"h":  strings.Join(signedNames, ":"),
 	}
+	if cfg.BodyLength > 0 {
+		dkimFields["l"] = fmt.Sprintf("%d", len(cBody))
+	}
+	if cfg.Expiration > 0 {
+		dkimFields["x"] = fmt.Sprintf("%d",
+			now+int64(cfg.Expiration/time.Second))
+	}
 	// Join tag=value; order by tag name (typical practice).
 	var tags []string
 	for k := range dkimFields {
@@ -87,35 +156,154 @@ func BuildDKIMSignature(
 		b.WriteString("=")
 		b.WriteString(dkimFields[k])
 	}
-	// Append b= empty; folding done by our header writer later.
-	unsignedDKIM := "DKIM-Signature: " + b.String() + "; b="
+	b.WriteString(";")
 
-	// Build signing input: signed headers + DKIM-Signature w/ empty b=
+	// Fold the tag list ourselves and put b= on its own line so the
+	// signed prefix is byte-identical once the signature is appended.
+	prefix := strings.TrimSuffix(
+		foldHeader("DKIM-Signature", b.String()), "\r\n")
+	prefix = strings.TrimPrefix(prefix, "DKIM-Signature: ")
+	prefix = strings.TrimPrefix(prefix, "DKIM-Signature:")
+	unsigned := prefix + "\r\n b="
+
+	// Build signing input: signed headers + DKIM-Signature with empty
+	// b= and no trailing CRLF (RFC 6376 3.7).
 	var toSign bytes.Buffer
 	for _, line := range signedLines {
 		toSign.WriteString(line)
-		toSign.WriteString("\r\n")
 	}
-	toSign.WriteString(dkimCanonLine(unsignedDKIM))
-	toSign.WriteString("\r\n")
+	if hc == types.DKIMCanonSimple {
+		toSign.WriteString("DKIM-Signature: " + unsigned)
+	} else {
+		toSign.WriteString(dkimCanonHeaderRelaxed("DKIM-Signature", unsigned))
+	}
 
-	// Sign with RSA-SHA256
+	// Sign the SHA-256 digest with the options of dkimSignerOpts.
 	hash := sha256.Sum256(toSign.Bytes())
-	sig, err := rsa.SignPKCS1v15(
-		nil, key, crypto.SHA256, hash[:],
-	)
+	sig, err := key.Sign(Rand(), hash[:], opts)
 	if err != nil {
-		return "", fmt.Errorf("dkim: sign: %w", err)
+		return "", nil, fmt.Errorf("dkim: sign: %w", err)
 	}
 	sigB64 := base64.StdEncoding.EncodeToString(sig)
 
-	// Final DKIM-Signature header value (without field name).
-	// We do not add CRLF here; caller writes headers with folding.
-	return b.String() + "; b=" + sigB64, nil
+	// Final DKIM-Signature header value (without field name). The b=
+	// value is folded with FWS, which verifiers ignore.
+	return unsigned + foldBase64(sigB64, 72), missing, nil
+}
+
+// dkimDefaultHeaders and dkimRecommendedHeaders are the h= lists of
+// DKIMHeadersDefault and DKIMHeadersRecommended.
+var (
+	dkimDefaultHeaders = []string{
+		"from", "to", "subject", "date",
+		"mime-version", "content-type", "message-id",
+	}
+	dkimRecommendedHeaders = []string{
+		"from", "sender", "reply-to", "to", "cc", "subject", "date", "message-id",
+		"in-reply-to", "references", "mime-version", "content-type",
+		"content-transfer-encoding", "list-id", "list-unsubscribe",
+		"list-unsubscribe-post",
+	}
+)
+
+// dkimHeaderList returns the header names to sign, in order.
+func dkimHeaderList(headers types.Headers, cfg types.DKIMConfig) ([]string, error) {
+	if len(cfg.Headers) > 0 {
+		return cfg.Headers, nil
+	}
+	switch cfg.HeaderSet {
+	case types.DKIMHeadersDefault:
+		return dkimDefaultHeaders, nil
+	case types.DKIMHeadersRecommended:
+		return dkimRecommendedHeaders, nil
+	case types.DKIMHeadersAll:
+		names := make([]string, 0, len(headers))
+		for _, f := range headers {
+			// Trace fields belong to the transport, not the author.
+			if !strings.EqualFold(f.Name, "DKIM-Signature") &&
+				!types.IsTraceHeader(f.Name) {
+				names = append(names, strings.ToLower(f.Name))
+			}
+		}
+		return names, nil
+	}
+	return nil, fmt.Errorf("dkim: unknown header set %q", cfg.HeaderSet)
+}
+
+// foldBase64 splits a base64 value into chunks joined by CRLF + SP.
+func foldBase64(s string, n int) string {
+	var b strings.Builder
+	for len(s) > n {
+		b.WriteString(s[:n])
+		b.WriteString("\r\n ")
+		s = s[n:]
+	}
+	b.WriteString(s)
+	return b.String()
 }
 
-// parseRSAPrivateKey parses an RSA private key from PEM bytes.
-func parseRSAPrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+// dkimCanon returns the header and body canonicalization to use.
+func dkimCanon(cfg types.DKIMConfig) (string, string, error) {
+	hc, bc := cfg.HeaderCanon, cfg.BodyCanon
+	if hc == "" {
+		hc = types.DKIMCanonRelaxed
+	}
+	if bc == "" {
+		bc = types.DKIMCanonRelaxed
+	}
+	for _, c := range []string{hc, bc} {
+		if c != types.DKIMCanonSimple && c != types.DKIMCanonRelaxed {
+			return "", "", fmt.Errorf("dkim: unknown canonicalization %q", c)
+		}
+	}
+	return hc, bc, nil
+}
+
+// DKIM signing algorithms.
+const (
+	DKIMAlgRSA     = "rsa-sha256"
+	DKIMAlgEd25519 = "ed25519-sha256"
+)
+
+// dkimAlgorithm returns the a= value for a public key.
+func dkimAlgorithm(pub crypto.PublicKey) (string, error) {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return DKIMAlgRSA, nil
+	case ed25519.PublicKey:
+		return DKIMAlgEd25519, nil
+	default:
+		return "", fmt.Errorf("dkim: unsupported key type %T", pub)
+	}
+}
+
+// dkimSignerOpts returns the signer options for a key: PKCS#1 v1.5 or
+// PSS over SHA-256 for RSA keys of at least MinRSABits, PureEdDSA over
+// the digest for Ed25519 (RFC 8463).
+func dkimSignerOpts(pub crypto.PublicKey, padding string) (crypto.SignerOpts, error) {
+	k, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return crypto.Hash(0), nil
+	}
+	if bits := k.N.BitLen(); bits < MinRSABits {
+		return nil, fmt.Errorf("dkim: RSA key of %d bits is too small", bits)
+	}
+	switch padding {
+	case types.DKIMPaddingPKCS1v15:
+		return crypto.SHA256, nil
+	case types.DKIMPaddingPSS:
+		return &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthEqualsHash,
+			Hash:       crypto.SHA256,
+		}, nil
+	default:
+		return nil, fmt.Errorf("dkim: unknown RSA padding %q", padding)
+	}
+}
+
+// ParseDKIMKey parses an RSA (PKCS#1 or PKCS#8) or Ed25519 (PKCS#8)
+// private key from PEM bytes.
+func ParseDKIMKey(pemBytes []byte) (crypto.Signer, error) {
 	block, _ := pem.Decode(pemBytes)
 	if block == nil {
 		return nil, errors.New("no PEM block found")
@@ -128,11 +316,14 @@ func parseRSAPrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
 		if err != nil {
 			return nil, err
 		}
-		rk, ok := key.(*rsa.PrivateKey)
-		if !ok {
-			return nil, errors.New("not an RSA key")
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return k, nil
+		case ed25519.PrivateKey:
+			return k, nil
+		default:
+			return nil, fmt.Errorf("unsupported key type %T", key)
 		}
-		return rk, nil
 	default:
 		return nil, fmt.Errorf("unsupported key type: %s", block.Type)
 	}
@@ -167,6 +358,21 @@ func dkimCanonicalizeBodyRelaxed(b []byte) []byte {
 	return res
 }
 
+// simple body canonicalization per RFC 6376 3.4.3: remove trailing
+// empty lines; an empty body becomes a single CRLF.
+func dkimCanonicalizeBodySimple(b []byte) []byte {
+	for bytes.HasSuffix(b, []byte("\r\n\r\n")) {
+		b = b[:len(b)-2]
+	}
+	if len(b) == 0 {
+		return []byte("\r\n")
+	}
+	if !bytes.HasSuffix(b, []byte("\r\n")) {
+		return append(append([]byte{}, b...), '\r', '\n')
+	}
+	return b
+}
+
 // relaxed header canonicalization per RFC 6376 3.4.2
 func dkimCanonHeaderRelaxed(name, value string) string {
 	lname := strings.ToLower(strings.TrimSpace(name))
@@ -177,15 +383,6 @@ func dkimCanonHeaderRelaxed(name, value string) string {
 	return lname + ":" + v
 }
 
-// dkimCanonLine canonicalizes a header line.
-func dkimCanonLine(line string) string {
-	parts := strings.SplitN(line, ":", 2)
-	if len(parts) != 2 {
-		return strings.ToLower(strings.TrimSpace(line))
-	}
-	return dkimCanonHeaderRelaxed(parts[0], parts[1])
-}
-
 // unfoldHeader unfolds a header line.
 func unfoldHeader(v string) string {
 	v = strings.ReplaceAll(v, "\r\n", "\n")
@@ -273,13 +470,13 @@ func compressWSP(b []byte) *wsWriter {
 	return &w
 }
 
-// headerLookup looks up a header name in a map.
-func headerLookup(h map[string]string, name string) string {
-	lname := strings.ToLower(name)
-	for k := range h {
-		if strings.ToLower(k) == lname {
-			return k
+// lastUnused returns the index of the last field named name that is not
+// in used, or -1.
+func lastUnused(h types.Headers, name string, used map[int]bool) int {
+	for i := len(h) - 1; i >= 0; i-- {
+		if !used[i] && strings.EqualFold(h[i].Name, name) {
+			return i
 		}
 	}
-	return ""
+	return -1
 }
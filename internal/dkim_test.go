@@ -1,6 +1,9 @@
 package internal
 
 import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -9,6 +12,7 @@ import (
 	"encoding/pem"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/aatuh/email/v2/types"
 )
@@ -20,17 +24,17 @@ func TestBuildDKIMSignatureIncludesBH(t *testing.T) {
 	bh := base64.StdEncoding.EncodeToString(sum[:])
 
 	// Generate a small RSA key for testing.
-	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	key, err := rsa.GenerateKey(rand.Reader, MinRSABits)
 	if err != nil {
 		t.Fatalf("generate key: %v", err)
 	}
 	keyDER := x509.MarshalPKCS1PrivateKey(key)
 	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: keyDER})
 
-	headers := map[string]string{
-		"From": "no-reply@example.com",
-		"To":   "to@example.com",
-		"Date": "Mon, 01 Jan 2000 00:00:00 +0000",
+	headers := types.Headers{
+		{Name: "From", Value: "no-reply@example.com"},
+		{Name: "To", Value: "to@example.com"},
+		{Name: "Date", Value: "Mon, 01 Jan 2000 00:00:00 +0000"},
 	}
 	cfg := types.DKIMConfig{Domain: "example.com", Selector: "sel", KeyPEM: keyPEM, Headers: []string{"from", "to", "date"}}
 	sig, err := BuildDKIMSignature(headers, []byte{}, cfg)
@@ -44,3 +48,197 @@ func TestBuildDKIMSignatureIncludesBH(t *testing.T) {
 		t.Fatalf("missing domain/selector: %s", sig)
 	}
 }
+
+func testDKIMKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, MinRSABits)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	return key, keyPEM
+}
+
+func TestDKIMCanonicalizationRoundTrip(t *testing.T) {
+	key, keyPEM := testDKIMKey(t)
+	lookup := func(d, s string) (crypto.PublicKey, error) { return &key.PublicKey, nil }
+	msg := types.Message{
+		From:    types.Address{Name: "Sender", Mail: "no-reply@example.com"},
+		To:      []types.Address{{Mail: "to@example.com"}},
+		Subject: "A fairly long subject line that will need folding once the header name is added to it",
+		Plain:   []byte("hello  world \n\n\n"),
+	}
+	for _, c := range [][2]string{
+		{"relaxed", "relaxed"}, {"relaxed", "simple"},
+		{"simple", "relaxed"}, {"simple", "simple"},
+	} {
+		cfg := types.DKIMConfig{
+			Domain: "example.com", Selector: "sel", KeyPEM: keyPEM,
+			HeaderCanon: c[0], BodyCanon: c[1],
+			BodyLength: 5, Expiration: time.Hour,
+		}
+		raw, err := BuildMIME(context.Background(), msg, BuildOptions{DKIM: &cfg})
+		if err != nil {
+			t.Fatalf("%v: build: %v", c, err)
+		}
+		tags, err := VerifyDKIM(raw, lookup)
+		if err != nil {
+			t.Fatalf("%v: verify: %v\n%s", c, err, raw)
+		}
+		if tags["c"] != c[0]+"/"+c[1] || tags["l"] != "5" || tags["x"] == "" {
+			t.Fatalf("%v: unexpected tags: %v", c, tags)
+		}
+		// Tampering with a signed header must break verification.
+		bad := strings.Replace(string(raw), "Subject: A fairly", "Subject: B fairly", 1)
+		if _, err := VerifyDKIM([]byte(bad), lookup); err == nil {
+			t.Fatalf("%v: expected verification failure after tampering", c)
+		}
+	}
+}
+
+func TestDKIMRejectsUnknownCanon(t *testing.T) {
+	_, keyPEM := testDKIMKey(t)
+	cfg := types.DKIMConfig{Domain: "example.com", Selector: "sel", KeyPEM: keyPEM, BodyCanon: "nowsp"}
+	if _, err := BuildDKIMSignature(types.Headers{{Name: "From", Value: "a@example.com"}}, nil, cfg); err == nil {
+		t.Fatalf("expected error for unknown canonicalization")
+	}
+}
+
+func TestDKIMRSAPadding(t *testing.T) {
+	key, keyPEM := testDKIMKey(t)
+	lookup := func(d, s string) (crypto.PublicKey, error) { return &key.PublicKey, nil }
+	msg := types.Message{
+		From:    types.Address{Mail: "no-reply@example.com"},
+		To:      []types.Address{{Mail: "to@example.com"}},
+		Subject: "Hi",
+		Plain:   []byte("hello"),
+	}
+	cfg := types.DKIMConfig{
+		Domain: "example.com", Selector: "sel", KeyPEM: keyPEM,
+		RSAPadding: types.DKIMPaddingPSS,
+	}
+	raw, err := BuildMIME(context.Background(), msg, BuildOptions{DKIM: &cfg})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	tags, err := VerifyDKIM(raw, lookup)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	sig, _ := base64.StdEncoding.DecodeString(tags["b"])
+	if len(sig) == 0 {
+		t.Fatalf("missing signature: %v", tags)
+	}
+	if tags["a"] != DKIMAlgRSA {
+		t.Fatalf("a=%q", tags["a"])
+	}
+
+	cfg.RSAPadding = "oaep"
+	if _, err := BuildMIME(context.Background(), msg, BuildOptions{DKIM: &cfg}); err == nil {
+		t.Fatal("expected error for unknown padding")
+	}
+}
+
+func TestDKIMHeaderSetsAndOversign(t *testing.T) {
+	key, keyPEM := testDKIMKey(t)
+	lookup := func(d, s string) (crypto.PublicKey, error) { return &key.PublicKey, nil }
+	msg := types.Message{
+		From:    types.Address{Mail: "news@example.com"},
+		To:      []types.Address{{Mail: "to@example.com"}},
+		Subject: "News",
+		Plain:   []byte("hello"),
+		Headers: types.Headers{{Name: "X-Campaign", Value: "spring"}},
+	}
+	opts := BuildOptions{ListUnsub: "<https://example.com/u>"}
+
+	for _, tc := range []struct {
+		set      string
+		oversign bool
+		want     string
+	}{
+		{types.DKIMHeadersRecommended, false, "from:to:subject:date:message-id:mime-version:content-type:content-transfer-encoding:list-unsubscribe"},
+		{types.DKIMHeadersRecommended, true, "from:to:subject:date:message-id:mime-version:content-type:content-transfer-encoding:list-unsubscribe:" +
+			"from:sender:reply-to:to:cc:subject:date:message-id:in-reply-to:references:mime-version:content-type:" +
+			"content-transfer-encoding:list-id:list-unsubscribe:list-unsubscribe-post"},
+		{types.DKIMHeadersAll, false, "date:from:to:message-id:subject:mime-version:content-type:content-transfer-encoding:list-unsubscribe:x-campaign"},
+	} {
+		cfg := types.DKIMConfig{
+			Domain: "example.com", Selector: "sel", KeyPEM: keyPEM,
+			HeaderSet: tc.set, Oversign: tc.oversign,
+		}
+		opts.DKIM = &cfg
+		raw, err := BuildMIME(context.Background(), msg, opts)
+		if err != nil {
+			t.Fatalf("%s: build: %v", tc.set, err)
+		}
+		tags, err := VerifyDKIM(raw, lookup)
+		if err != nil {
+			t.Fatalf("%s: verify: %v", tc.set, err)
+		}
+		if tags["h"] != tc.want {
+			t.Fatalf("%s/%v: h=%s", tc.set, tc.oversign, tags["h"])
+		}
+		// An added Reply-To only breaks oversigned signatures.
+		added := strings.Replace(string(raw), "\r\nSubject:", "\r\nReply-To: evil@example.net\r\nSubject:", 1)
+		_, err = VerifyDKIM([]byte(added), lookup)
+		if tc.oversign != (err != nil) {
+			t.Fatalf("%s/%v: verify after adding Reply-To: %v", tc.set, tc.oversign, err)
+		}
+	}
+
+	cfg := types.DKIMConfig{Domain: "example.com", Selector: "sel", KeyPEM: keyPEM, HeaderSet: "most"}
+	if _, err := BuildDKIMSignature(types.Headers{{Name: "From", Value: "a@example.com"}}, nil, cfg); err == nil {
+		t.Fatal("expected error for unknown header set")
+	}
+}
+
+func TestDKIMCanonicalizeBodySimple(t *testing.T) {
+	cases := map[string]string{
+		"":               "\r\n",
+		"a":              "a\r\n",
+		"a \r\n\r\n\r\n": "a \r\n",
+		"a\r\n \r\n":     "a\r\n \r\n",
+	}
+	for in, want := range cases {
+		if got := string(dkimCanonicalizeBodySimple([]byte(in))); got != want {
+			t.Fatalf("simple(%q)=%q want %q", in, got, want)
+		}
+	}
+}
+
+func BenchmarkBuildDKIMSignature(b *testing.B) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		b.Fatal(err)
+	}
+	headers := types.Headers{
+		{Name: "From", Value: "shop@example.com"},
+		{Name: "To", Value: "ada@example.org"},
+		{Name: "Subject", Value: "Your order has shipped"},
+		{Name: "Date", Value: "Mon, 01 Jan 2000 00:00:00 +0000"},
+	}
+	body := []byte(strings.Repeat("Thanks for your order.  \r\n", 4000))
+	for _, bc := range []struct {
+		name   string
+		signer crypto.Signer
+	}{{"rsa-2048", rsaKey}, {"ed25519", edKey}} {
+		b.Run(bc.name, func(b *testing.B) {
+			cfg := types.DKIMConfig{
+				Domain: "example.com", Selector: "sel", Signer: bc.signer,
+			}
+			b.SetBytes(int64(len(body)))
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := BuildDKIMSignature(headers, body, cfg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package internal
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// DKIMKeyLookup returns the public key for a selector and domain, as
+// published in the selector's DNS TXT record.
+type DKIMKeyLookup func(domain, selector string) (crypto.PublicKey, error)
+
+// rawField is a header field as it appears on the wire.
+type rawField struct {
+	name string
+	raw  string // "Name: value\r\n" including folding
+}
+
+// value returns the unparsed field body after the colon.
+func (f rawField) value() string {
+	_, v, _ := strings.Cut(f.raw, ":")
+	return strings.TrimSuffix(v, "\r\n")
+}
+
+// VerifyDKIM verifies the first DKIM-Signature of a raw CRLF message.
+// Both rsa-sha256 and ed25519-sha256 are supported.
+//
+// Parameters:
+//   - raw: The message bytes.
+//   - lookup: The public key lookup.
+//
+// Returns:
+//   - map[string]string: The parsed signature tags.
+//   - error: An error if the signature does not verify.
+func VerifyDKIM(raw []byte, lookup DKIMKeyLookup) (map[string]string, error) {
+	fields, body, err := splitRawMessage(raw)
+	if err != nil {
+		return nil, err
+	}
+	var sigField *rawField
+	for i := range fields {
+		if strings.EqualFold(fields[i].name, "DKIM-Signature") {
+			sigField = &fields[i]
+			break
+		}
+	}
+	if sigField == nil {
+		return nil, errors.New("dkim: no DKIM-Signature header")
+	}
+	tags := map[string]string{}
+	for _, part := range strings.Split(sigField.value(), ";") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		tags[strings.TrimSpace(k)] = stripWSP(v)
+	}
+	hc, bc, _ := strings.Cut(tags["c"], "/")
+	if hc == "" {
+		hc = types.DKIMCanonSimple
+	}
+	if bc == "" {
+		bc = types.DKIMCanonSimple
+	}
+	if tags["a"] != DKIMAlgRSA && tags["a"] != DKIMAlgEd25519 {
+		return tags, fmt.Errorf("dkim: unsupported algorithm %q", tags["a"])
+	}
+
+	var cBody []byte
+	if bc == types.DKIMCanonRelaxed {
+		cBody = dkimCanonicalizeBodyRelaxed(body)
+	} else {
+		cBody = dkimCanonicalizeBodySimple(body)
+	}
+	if l, ok := tags["l"]; ok {
+		n, err := strconv.ParseInt(l, 10, 64)
+		if err != nil || n > int64(len(cBody)) {
+			return tags, fmt.Errorf("dkim: invalid l=%s", l)
+		}
+		cBody = cBody[:n]
+	}
+	bh := sha256.Sum256(cBody)
+	if base64.StdEncoding.EncodeToString(bh[:]) != tags["bh"] {
+		return tags, errors.New("dkim: body hash mismatch")
+	}
+
+	// Select signed header instances from the bottom up (RFC 6376 5.4.2).
+	used := map[int]bool{}
+	var toSign bytes.Buffer
+	for _, name := range strings.Split(tags["h"], ":") {
+		name = strings.TrimSpace(name)
+		for i := len(fields) - 1; i >= 0; i-- {
+			if used[i] || !strings.EqualFold(fields[i].name, name) {
+				continue
+			}
+			used[i] = true
+			toSign.WriteString(canonField(fields[i], hc) + "\r\n")
+			break
+		}
+	}
+	unsigned := rawField{name: sigField.name, raw: stripBTag(sigField.raw)}
+	toSign.WriteString(canonField(unsigned, hc))
+
+	pub, err := lookup(tags["d"], tags["s"])
+	if err != nil {
+		return tags, fmt.Errorf("dkim: key lookup: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return tags, fmt.Errorf("dkim: decode b=: %w", err)
+	}
+	sum := sha256.Sum256(toSign.Bytes())
+	if alg, err := dkimAlgorithm(pub); err != nil || alg != tags["a"] {
+		return tags, errors.New("dkim: key type does not match a=")
+	}
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		// Accept PSS as well, for signers using DKIMPaddingPSS.
+		err = rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig)
+		if err != nil && rsa.VerifyPSS(k, crypto.SHA256, sum[:], sig,
+			&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto}) == nil {
+			err = nil
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, sum[:], sig) {
+			err = errors.New("ed25519 verification failed")
+		}
+	}
+	if err != nil {
+		return tags, fmt.Errorf("dkim: bad signature: %w", err)
+	}
+	return tags, nil
+}
+
+// splitRawMessage splits raw bytes into header fields and body.
+func splitRawMessage(raw []byte) ([]rawField, []byte, error) {
+	i := bytes.Index(raw, []byte("\r\n\r\n"))
+	if i < 0 {
+		return nil, nil, errors.New("dkim: no header/body separator")
+	}
+	head, body := string(raw[:i+2]), raw[i+4:]
+	var fields []rawField
+	for _, line := range strings.SplitAfter(head, "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1].raw += line
+			continue
+		}
+		name, _, _ := strings.Cut(line, ":")
+		fields = append(fields, rawField{name: strings.TrimSpace(name),
+			raw: line})
+	}
+	return fields, body, nil
+}
+
+// canonField canonicalizes a field without its trailing CRLF.
+func canonField(f rawField, hc string) string {
+	if hc == types.DKIMCanonRelaxed {
+		return dkimCanonHeaderRelaxed(f.name, f.value())
+	}
+	return strings.TrimSuffix(f.raw, "\r\n")
+}
+
+// stripBTag empties the b= tag value, preserving everything else.
+func stripBTag(raw string) string {
+	parts := strings.Split(raw, ";")
+	for i, p := range parts {
+		k, _, ok := strings.Cut(p, "=")
+		if ok && strings.TrimSpace(k) == "b" {
+			parts[i] = k + "="
+			if strings.HasSuffix(p, "\r\n") {
+				parts[i] += "\r\n"
+			}
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// stripWSP removes all whitespace, including folding, from a tag value.
+func stripWSP(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			return -1
+		}
+		return r
+	}, s)
+}
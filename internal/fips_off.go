@@ -0,0 +1,10 @@
+//go:build !fips
+
+package internal
+
+// FIPS reports whether the module was built with the fips tag, which
+// leaves out algorithms that are not FIPS 140-approved.
+const FIPS = false
+
+// MinRSABits is the smallest RSA key accepted for DKIM signing.
+const MinRSABits = 1024
@@ -3,77 +3,391 @@ package internal
 import (
 	"bytes"
 	"context"
-	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"math"
 	"mime"
 	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
 	"net/textproto"
+	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aatuh/email/v2/types"
 )
 
-// buildMIME assembles headers + body. If dkim != nil, it signs the
-// message and inserts a DKIM-Signature header. Hooks wrap build timing.
+// BuildOptions carries per-send settings that affect the built message.
+type BuildOptions struct {
+	ListUnsub         string
+	DKIM              *types.DKIMConfig
+	Hooks             *types.Hooks
+	MaxMessageSize    int64 // 0 means unlimited
+	MaxAttachmentSize int64 // encoded bytes per attachment, 0 is unlimited
+	BIMISelector      string
+
+	MessageIDDomain    string
+	MessageIDGenerator types.MessageIDGenerator
+
+	// Allow8Bit and AllowBinary let automatic encoding selection use
+	// 8bit and binary text parts; set them when the receiving server
+	// advertises 8BITMIME, or BINARYMIME and CHUNKING.
+	Allow8Bit   bool
+	AllowBinary bool
+
+	// Charset, if set to other than UTF-8, is the charset of the text
+	// parts and Subject, transcoded with CharsetEncoder.
+	Charset        string
+	CharsetEncoder types.CharsetEncoder
+
+	// ReadReceiptTo adds Disposition-Notification-To and
+	// Return-Receipt-To headers requesting an MDN.
+	ReadReceiptTo string
+
+	// AttachmentCache, if set, reuses encoded attachment bodies across
+	// builds, keyed by content hash.
+	AttachmentCache types.AttachmentCache
+
+	// AttachmentPolicy, if set, rejects attachments by filename before
+	// the message is built.
+	AttachmentPolicy *types.AttachmentPolicy
+
+	// SanitizeHTML, if set, rewrites the HTML body before it is encoded.
+	SanitizeHTML func(html []byte) []byte
+	// Transforms rewrite the HTML body, in order, after SanitizeHTML.
+	Transforms []types.BuildTransform
+
+	// AttachmentScanner, if set, scans every attachment; a threat fails
+	// the build with a *types.MalwareError.
+	AttachmentScanner types.AttachmentScanner
+
+	// Rand, if set, replaces Rand as the source of the MIME boundaries
+	// and the generated Message-ID.
+	Rand io.Reader
+}
+
+// SendBuildOptions maps cfg, an *email.SendConfig, to BuildOptions. The
+// email package sets it, since this package cannot import it, so that
+// Build and every adapter share one mapping.
+var SendBuildOptions func(cfg any) BuildOptions
+
+// Built is the result of a MIME build.
+type Built struct {
+	Raw       []byte
+	MessageID string // with angle brackets
+	BodyType  string // SMTP BODY= value: "", Body8BitMIME or BodyBinaryMIME
+
+	// AttachmentSizes holds the encoded size of each attachment body in
+	// msg.Attach order.
+	AttachmentSizes []int64
+
+	// Warnings lists the problems the build worked around.
+	Warnings []types.BuildWarning
+}
+
+// BuildMIME assembles headers + body and returns the raw bytes. See Build.
 func BuildMIME(
 	ctx context.Context,
 	msg types.Message,
-	listUnsub string,
-	dkim *types.DKIMConfig,
-	hooks *types.Hooks,
+	opts BuildOptions,
 ) ([]byte, error) {
+	b, err := Build(ctx, msg, opts)
+	if err != nil {
+		return nil, err
+	}
+	return b.Raw, nil
+}
+
+// Build assembles headers + body. If opts.DKIM != nil, it signs the
+// message and inserts a DKIM-Signature header. Hooks wrap build timing.
+// msg is copied first, so neither the builder nor the hooks change the
+// caller's headers, addresses or attachments; see buildCopy.
+func Build(
+	ctx context.Context,
+	msg types.Message,
+	opts BuildOptions,
+) (*Built, error) {
+	msg = buildCopy(msg)
+	listUnsub, dkim, hooks := opts.ListUnsub, opts.DKIM, opts.Hooks
 	if err := msg.Validate(); err != nil {
 		return nil, err
 	}
+	if err := types.ValidateHeaderValue("List-Unsubscribe", listUnsub); err != nil {
+		return nil, err
+	}
+	if err := types.ValidateHeaderValue("BIMI-Selector", opts.BIMISelector); err != nil {
+		return nil, err
+	}
+	if opts.ReadReceiptTo != "" {
+		if _, err := mail.ParseAddress(opts.ReadReceiptTo); err != nil {
+			return nil, &types.HeaderError{
+				Field: "Disposition-Notification-To", Reason: err.Error(),
+			}
+		}
+	}
+	if opts.AttachmentPolicy != nil {
+		for _, a := range msg.Attach {
+			if err := opts.AttachmentPolicy.Check(a.Filename); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !validCharset(opts.charset()) {
+		return nil, fmt.Errorf("email: invalid charset %q", opts.Charset)
+	}
+	fail := func(err error) (*Built, error) {
+		if hooks != nil && hooks.OnBuildDone != nil {
+			hooks.OnBuildDone(ctx, &msg, 0, err)
+		}
+		return nil, err
+	}
 
 	if hooks != nil && hooks.OnBuildStart != nil {
 		ctx = hooks.OnBuildStart(ctx, &msg)
 	}
+	var warnings []types.BuildWarning
+	warn := func(code, field, message string) {
+		w := types.BuildWarning{Code: code, Field: field, Message: message}
+		warnings = append(warnings, w)
+		if hooks != nil && hooks.OnBuildWarning != nil {
+			hooks.OnBuildWarning(ctx, w)
+		}
+	}
 
-	h := msg.CloneHeaders()
-	ensureListUnsub(h, listUnsub)
+	// text holds the text parts in the output charset.
+	text := msg
+	var err error
+	if text.Plain, err = opts.transcode(msg.Plain); err != nil {
+		return fail(err)
+	}
+	if opts.SanitizeHTML != nil && len(msg.HTML) > 0 {
+		text.HTML = opts.SanitizeHTML(msg.HTML)
+	}
+	for _, t := range opts.Transforms {
+		if len(text.HTML) == 0 {
+			break
+		}
+		if text.HTML, err = t.TransformHTML(ctx, text.HTML); err != nil {
+			return fail(fmt.Errorf("email: html transform: %w", err))
+		}
+	}
+	if text.HTML, err = opts.transcode(text.HTML); err != nil {
+		return fail(err)
+	}
+	if text.Attach, err = ScanAttachments(ctx, msg.Attach, opts.AttachmentScanner); err != nil {
+		return fail(err)
+	}
+	subject, err := opts.encodeHeaderText(sanitizeHeader(msg.Subject))
+	if err != nil {
+		return fail(err)
+	}
+	enc, err := chooseEncodings(text, opts)
+	if err != nil {
+		return fail(err)
+	}
+	if msg.TextEncoding == types.TransferAuto {
+		for _, p := range []struct {
+			sub  string
+			body []byte
+			enc  types.TransferEncoding
+		}{{"plain", text.Plain, enc.plain}, {"html", text.HTML, enc.html}} {
+			if (p.enc == types.TransferQuotedPrintable ||
+				p.enc == types.TransferBase64) && hasLongLine(p.body) {
+				warn(types.WarnTextRewrapped, "text/"+p.sub, fmt.Sprintf(
+					"lines over %d octets encoded as %s", maxLineOctets, p.enc))
+			}
+		}
+	}
+
+	// Build body first into bodyBuf so DKIM can hash it. The body writer
+	// enforces MaxMessageSize while streaming.
+	bodyBuf := getBuffer()
+	defer putBuffer(bodyBuf)
+	bodyBuf.Grow(estimateBodySize(text, opts))
+	var body io.Writer = bodyBuf
+	if opts.MaxMessageSize > 0 {
+		body = &limitWriter{w: bodyBuf, limit: opts.MaxMessageSize}
+	}
+	ctype, cte, sizes, err := writeBody(body, text, enc, opts)
+	if err != nil {
+		return fail(err)
+	}
+	if cte == "" {
+		cte = enc.multipartCTE()
+	}
+	if lw, ok := body.(*limitWriter); ok && lw.err != nil {
+		return fail(lw.err)
+	}
 
-	setHeader(h, "From", msg.From.String())
-	if len(msg.To) > 0 {
-		setHeader(h, "To", joinAddrs(msg.To))
+	// Core fields are owned by the builder; custom headers with the same
+	// name are ignored, except Message-ID and List-Unsubscribe which the
+	// caller may supply. Custom headers may repeat.
+	var h types.Headers
+	date := msg.Date
+	if date.IsZero() {
+		date = Now()
+	}
+	setHeader(&h, "Date", date.UTC().Format(time.RFC1123Z))
+	setHeader(&h, "From", joinAddrs(msg.FromList()))
+	if msg.Sender.Mail != "" {
+		setHeader(&h, "Sender", msg.Sender.String())
+	}
+	if len(msg.ReplyTo) > 0 {
+		setHeader(&h, "Reply-To", joinAddrs(msg.ReplyTo))
+	}
+	switch {
+	case len(msg.To) > 0:
+		setHeader(&h, "To", joinAddrs(msg.To))
+	case len(msg.Cc) == 0:
+		// Bcc only: name no one rather than omit To (RFC 5322 3.6.3).
+		setHeader(&h, "To", joinAddrs(types.Group(types.UndisclosedRecipients)))
 	}
 	if len(msg.Cc) > 0 {
-		setHeader(h, "Cc", joinAddrs(msg.Cc))
+		setHeader(&h, "Cc", joinAddrs(msg.Cc))
+	}
+	msgID := msg.Headers.Get("Message-ID")
+	if msgID == "" {
+		id, err := genMessageID(msg, opts)
+		if err != nil {
+			return fail(err)
+		}
+		msgID = formatMsgID(id)
+		if err := types.ValidateHeaderValue("Message-ID", msgID); err != nil {
+			return fail(err)
+		}
+	}
+	setHeader(&h, "Message-ID", msgID)
+	setHeader(&h, "In-Reply-To", formatMsgID(msg.InReplyTo))
+	if len(msg.References) > 0 {
+		refs := make([]string, 0, len(msg.References))
+		for _, r := range msg.References {
+			if id := formatMsgID(r); id != "" {
+				refs = append(refs, id)
+			}
+		}
+		setHeader(&h, "References", strings.Join(refs, " "))
+	}
+	setHeader(&h, "Subject", subject)
+	setHeader(&h, "MIME-Version", "1.0")
+	setHeader(&h, "Content-Type", ctype)
+	setHeader(&h, "Content-Transfer-Encoding", cte)
+	setHeader(&h, "Disposition-Notification-To", opts.ReadReceiptTo)
+	setHeader(&h, "Return-Receipt-To", opts.ReadReceiptTo)
+	if listUnsub == "" {
+		listUnsub = msg.Headers.Get("List-Unsubscribe")
+	}
+	setHeader(&h, "List-Unsubscribe", listUnsub)
+	if opts.BIMISelector != "" {
+		setHeader(&h, "BIMI-Selector", "v=BIMI1; s="+opts.BIMISelector)
 	}
-	setHeader(h, "Subject", sanitizeHeader(msg.Subject))
-	setHeader(h, "Date", time.Now().UTC().Format(time.RFC1123Z))
-	setHeader(h, "MIME-Version", "1.0")
 	if msg.TrackingID != "" {
-		setHeader(h, "X-Tracking-ID", sanitizeHeader(msg.TrackingID))
+		setHeader(&h, "X-Tracking-ID", sanitizeHeader(msg.TrackingID))
+	}
+	// Custom headers follow in insertion order. Trace fields go first,
+	// in their original order, as a relay received them.
+	core := h.Clone()
+	var trace types.Headers
+	for _, f := range msg.Headers {
+		switch {
+		case f.Value == "":
+			warn(types.WarnHeaderIgnored, f.Name, "empty value")
+		case core.Has(f.Name):
+			if core.Get(f.Name) != f.Value {
+				warn(types.WarnHeaderIgnored, f.Name, "set by the builder")
+			}
+		case types.IsTraceHeader(f.Name):
+			trace = append(trace, f)
+		default:
+			h.Add(f.Name, f.Value)
+		}
+	}
+	h = append(trace, h...)
+	for _, f := range h {
+		// Only a field longer than the limit can leave a line over it.
+		if len(f.Name)+len(": ")+len(f.Value) > maxLineOctets &&
+			hasLongLine([]byte(foldHeader(f.Name, f.Value))) {
+			warn(types.WarnLongHeaderLine, f.Name, fmt.Sprintf(
+				"cannot fold to %d octets per line", maxLineOctets))
+		}
+	}
+
+	// If DKIM enabled, compute and prepend DKIM-Signature.
+	if dkim != nil {
+		sigVal, missing, err := buildDKIMSignature(h, bodyBuf.Bytes(), *dkim)
+		if err != nil {
+			return fail(err)
+		}
+		for _, name := range missing {
+			warn(types.WarnDKIMHeaderMissing, name, "not in the message; left unsigned")
+		}
+		h = append(types.Headers{{Name: "DKIM-Signature", Value: sigVal}},
+			h...)
 	}
-	if _, ok := h["Message-ID"]; !ok {
-		setHeader(h, "Message-ID", genMessageID(msg))
+
+	// Now write headers + CRLF + body to the final buffer, allocated
+	// once at its exact size since it outlives the pooled buffers.
+	hdrBuf := getBuffer()
+	defer putBuffer(hdrBuf)
+	WriteHeaders(hdrBuf, h)
+	raw := make([]byte, 0, hdrBuf.Len()+bodyBuf.Len())
+	raw = append(append(raw, hdrBuf.Bytes()...), bodyBuf.Bytes()...)
+	if opts.MaxMessageSize > 0 && int64(len(raw)) > opts.MaxMessageSize {
+		return fail(&types.SizeError{
+			Size: int64(len(raw)), Limit: opts.MaxMessageSize,
+		})
+	}
+
+	if hooks != nil && hooks.OnBuildDone != nil {
+		hooks.OnBuildDone(ctx, &msg, len(raw), nil)
 	}
 
-	// Build body first into bodyBuf so DKIM can hash it.
-	var bodyBuf bytes.Buffer
+	return &Built{
+		Raw: raw, MessageID: msgID, BodyType: enc.bodyType(),
+		AttachmentSizes: sizes, Warnings: warnings,
+	}, nil
+}
+
+// writeBody writes the MIME body for msg and returns the top-level
+// Content-Type and Content-Transfer-Encoding (empty for multipart) and
+// the encoded size of each attachment.
+func writeBody(
+	body io.Writer,
+	msg types.Message,
+	enc textEncodings,
+	opts BuildOptions,
+) (string, string, []int64, error) {
 	hasPlain := len(msg.Plain) > 0
 	hasHTML := len(msg.HTML) > 0
 	hasAttach := len(msg.Attach) > 0
+	plainType := textType("plain", opts.charset())
+	htmlType := textType("html", opts.charset())
 
 	switch {
 	case hasAttach:
-		mixedW, mixedBoundary := newMixed(&bodyBuf)
-		h["Content-Type"] = fmt.Sprintf(
-			`multipart/mixed; boundary="%s"`, mixedBoundary,
-		)
+		mixedW, mixedBoundary, err := newMixed(body, opts.rand())
+		if err != nil {
+			return "", "", nil, err
+		}
+		ctype := fmt.Sprintf(`multipart/mixed; boundary="%s"`, mixedBoundary)
 		// Alternatives nested part.
 		if hasPlain || hasHTML {
-			var altBuf bytes.Buffer
-			altW, altBoundary := newAlternative(&altBuf)
+			altBuf := getBuffer()
+			defer putBuffer(altBuf)
+			altW, altBoundary, err := newAlternative(altBuf, opts.rand())
+			if err != nil {
+				return "", "", nil, err
+			}
 			if hasPlain {
-				writeTextPart(altW, msg.Plain)
+				writeTextPart(altW, plainType, msg.Plain, enc.plain)
 			}
 			if hasHTML {
-				writeHTMLPart(altW, msg.HTML)
+				writeTextPart(altW, htmlType, msg.HTML, enc.html)
 			}
 			_ = altW.Close()
 
@@ -81,64 +395,45 @@ func BuildMIME(
 			hdr.Set("Content-Type",
 				fmt.Sprintf(`multipart/alternative; boundary="%s"`,
 					altBoundary))
+			if cte := enc.multipartCTE(); cte != "" {
+				hdr.Set("Content-Transfer-Encoding", cte)
+			}
 			pw, _ := mixedW.CreatePart(hdr)
-			_, _ = io.Copy(pw, &altBuf)
+			_, _ = pw.Write(altBuf.Bytes())
 		}
-		for _, a := range msg.Attach {
-			writeAttachment(mixedW, a)
+		sizes := make([]int64, len(msg.Attach))
+		for i, a := range msg.Attach {
+			n, err := writeAttachment(mixedW, a, opts)
+			if err != nil {
+				return "", "", nil, err
+			}
+			sizes[i] = n
 		}
-		_ = mixedW.Close()
+		return ctype, "", sizes, mixedW.Close()
 
 	case hasPlain && hasHTML:
-		altW, altBoundary := newAlternative(&bodyBuf)
-		h["Content-Type"] = fmt.Sprintf(
-			`multipart/alternative; boundary="%s"`, altBoundary,
-		)
-		writeTextPart(altW, msg.Plain)
-		writeHTMLPart(altW, msg.HTML)
+		altW, altBoundary, err := newAlternative(body, opts.rand())
+		if err != nil {
+			return "", "", nil, err
+		}
+		writeTextPart(altW, plainType, msg.Plain, enc.plain)
+		writeTextPart(altW, htmlType, msg.HTML, enc.html)
 		_ = altW.Close()
+		return fmt.Sprintf(`multipart/alternative; boundary="%s"`,
+			altBoundary), "", nil, nil
 
 	case hasHTML:
-		h["Content-Type"] = `text/html; charset="UTF-8"`
-		h["Content-Transfer-Encoding"] = "quoted-printable"
-		writeQuotedPrintable(&bodyBuf, msg.HTML)
+		writeText(body, msg.HTML, enc.html)
+		return htmlType, string(enc.html), nil, nil
 
 	default:
-		h["Content-Type"] = `text/plain; charset="UTF-8"`
-		h["Content-Transfer-Encoding"] = "quoted-printable"
-		writeQuotedPrintable(&bodyBuf, msg.Plain)
-	}
-
-	// If DKIM enabled, compute and insert DKIM-Signature.
-	if dkim != nil {
-		sigVal, err := BuildDKIMSignature(h, bodyBuf.Bytes(), *dkim)
-		if err != nil {
-			if hooks != nil && hooks.OnBuildDone != nil {
-				hooks.OnBuildDone(ctx, &msg, 0, err)
-			}
-			return nil, err
-		}
-		setHeader(h, "DKIM-Signature", sigVal)
-	}
-
-	// Now write headers + CRLF + body to final buffer.
-	var out bytes.Buffer
-	writeHeaders(&out, h)
-	_, _ = io.Copy(&out, &bodyBuf)
-
-	if hooks != nil && hooks.OnBuildDone != nil {
-		hooks.OnBuildDone(ctx, &msg, out.Len(), nil)
+		writeText(body, msg.Plain, enc.plain)
+		return plainType, string(enc.plain), nil, nil
 	}
-
-	return out.Bytes(), nil
 }
 
 func joinAddrs(xs []types.Address) string {
-	out := make([]string, 0, len(xs))
-	for _, a := range xs {
-		out = append(out, a.String())
-	}
-	return strings.Join(out, ", ")
+	return types.FormatAddressList(xs)
 }
 
 func sanitizeHeader(s string) string {
@@ -147,73 +442,210 @@ func sanitizeHeader(s string) string {
 	return s
 }
 
-func genMessageID(m types.Message) string {
-	var r [12]byte
-	_, _ = rand.Read(r[:])
-	host := "localhost"
-	if i := strings.LastIndex(m.From.Mail, "@"); i != -1 {
-		host = m.From.Mail[i+1:]
+// formatMsgID trims id and wraps it in angle brackets if missing.
+func formatMsgID(id string) string {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return ""
 	}
-	return fmt.Sprintf("<%x%x@%s>", time.Now().UnixNano(), r, host)
+	return "<" + strings.Trim(id, "<>") + ">"
 }
 
-func writeHeaders(w io.Writer, h map[string]string) {
-	for k, v := range h {
-		writeFoldedHeader(w, k, v)
+// genMessageID returns a Message-ID using opts.MessageIDGenerator, or
+// time + random bits at opts.MessageIDDomain (default: the From domain).
+func genMessageID(m types.Message, opts BuildOptions) (string, error) {
+	host := opts.MessageIDDomain
+	if host == "" {
+		host = "localhost"
+		if i := strings.LastIndex(m.From.Mail, "@"); i != -1 {
+			host = m.From.Mail[i+1:]
+		}
+	}
+	if opts.MessageIDGenerator != nil {
+		return opts.MessageIDGenerator(&m, host), nil
+	}
+	return defaultMessageID(opts.rand(), host)
+}
+
+// defaultMessageID returns "<time+random@domain>" with 12 random bytes
+// from r.
+func defaultMessageID(r io.Reader, domain string) (string, error) {
+	var b [12]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return "", fmt.Errorf("email: message id: %w", err)
+	}
+	return fmt.Sprintf("<%x%x@%s>", Now().UnixNano(), b, domain), nil
+}
+
+// WriteHeaders writes h folded as by Build, followed by the blank line
+// that ends the header section.
+//
+// Parameters:
+//   - w: The destination.
+//   - h: The header fields.
+func WriteHeaders(w io.Writer, h types.Headers) {
+	for _, f := range h {
+		writeFoldedHeader(w, f.Name, f.Value)
 	}
 	io.WriteString(w, "\r\n")
 }
 
+// FoldHeader returns the header field folded as written by Build,
+// terminated by CRLF.
+func FoldHeader(key, val string) string {
+	return foldHeader(key, val)
+}
+
 func writeFoldedHeader(w io.Writer, key, val string) {
-	const limit = 78
+	io.WriteString(w, foldHeader(key, val))
+}
+
+// foldLimit is the preferred maximum line length (RFC 5322 2.1.1).
+const foldLimit = 78
+
+// foldDelims maps structured header names to the delimiter after which
+// folding is preferred, so list elements and parameters stay whole.
+var foldDelims = map[string]byte{
+	"from": ',', "sender": ',', "reply-to": ',', "to": ',', "cc": ',',
+	"bcc": ',', "list-unsubscribe": ',',
+	"resent-from": ',', "resent-to": ',', "resent-cc": ',',
+	"content-type": ';', "content-disposition": ';',
+	"dkim-signature": ';', "bimi-selector": ';', "received": ';',
+}
+
+// foldHeader returns the header field as written on the wire, folded to
+// stay within 78 columns and terminated by CRLF. Folding only happens at
+// existing whitespace, so encoded-words, msg-ids and other tokens are
+// never split and unfolding yields the original value. Structured
+// headers fold after their list delimiter when possible; a token longer
+// than the limit is kept on its own line. Values that already contain
+// CRLF are treated as pre-folded and kept verbatim so signers can rely
+// on the exact byte representation.
+func foldHeader(key, val string) string {
 	line := key + ": " + val
-	if len(line) <= limit {
-		io.WriteString(w, line+"\r\n")
-		return
+	if len(line) <= foldLimit || strings.Contains(val, "\r\n") {
+		return line + "\r\n"
+	}
+	delim := foldDelims[strings.ToLower(key)]
+	var b strings.Builder
+	b.WriteString(key + ":")
+	// fresh: the line is a new continuation line; first: no value token
+	// has been written yet.
+	col, fresh, first := len(key)+1, false, true
+	canFold := func(w int) bool {
+		return !fresh && col+w > foldLimit && (!first || w <= foldLimit)
 	}
-	words := strings.Fields(val)
-	curr := key + ":"
-	for _, wd := range words {
-		if len(curr)+1+len(wd) > limit {
-			io.WriteString(w, curr+"\r\n")
-			curr = " " + wd
-		} else {
-			curr += " " + wd
+	for _, el := range foldElements(" "+val, delim) {
+		w := 0
+		for _, at := range el {
+			w += len(at)
+		}
+		if canFold(w) && w <= foldLimit {
+			b.WriteString("\r\n")
+			col, fresh = 0, true
+		}
+		for _, at := range el {
+			// A folded line must not consist of whitespace only.
+			if canFold(len(at)) && strings.TrimLeft(at, " \t") != "" {
+				b.WriteString("\r\n")
+				col = 0
+			}
+			b.WriteString(at)
+			col += len(at)
+			fresh, first = false, false
 		}
 	}
-	io.WriteString(w, curr+"\r\n")
+	b.WriteString("\r\n")
+	return b.String()
 }
 
-func newMixed(buf *bytes.Buffer) (*multipart.Writer, string) {
-	w := multipart.NewWriter(buf)
-	return w, w.Boundary()
+// foldElements splits v into atoms, each a whitespace run followed by a
+// token, and groups the atoms into elements ending after delim. With
+// delim set, quoted strings are treated as part of a single token.
+func foldElements(v string, delim byte) [][]string {
+	var (
+		out     [][]string
+		el      []string
+		start   int
+		inQuote bool
+	)
+	flush := func(end int) {
+		if end > start {
+			el = append(el, v[start:end])
+			start = end
+		}
+	}
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case delim != 0 && c == '\\' && inQuote:
+			i++
+		case delim != 0 && c == '"':
+			inQuote = !inQuote
+		case (c == ' ' || c == '\t') && !inQuote &&
+			i > 0 && v[i-1] != ' ' && v[i-1] != '\t':
+			flush(i)
+			if delim != 0 && v[i-1] == delim {
+				out = append(out, el)
+				el = nil
+			}
+		}
+	}
+	flush(len(v))
+	if len(el) > 0 {
+		out = append(out, el)
+	}
+	return out
 }
 
-func newAlternative(buf *bytes.Buffer) (*multipart.Writer, string) {
-	w := multipart.NewWriter(buf)
-	return w, w.Boundary()
+func newMixed(buf io.Writer, r io.Reader) (*multipart.Writer, string, error) {
+	return newMultipart(buf, r)
 }
 
-func writeTextPart(w *multipart.Writer, body []byte) {
-	h := textproto.MIMEHeader{}
-	h.Set("Content-Type", `text/plain; charset="UTF-8"`)
-	h.Set("Content-Transfer-Encoding", "quoted-printable")
-	pw, _ := w.CreatePart(h)
-	writeQuotedPrintable(pw, body)
+func newAlternative(buf io.Writer, r io.Reader) (*multipart.Writer, string, error) {
+	return newMultipart(buf, r)
 }
 
-func writeHTMLPart(w *multipart.Writer, body []byte) {
+// newMultipart returns a multipart writer with a boundary from r.
+func newMultipart(buf io.Writer, r io.Reader) (*multipart.Writer, string, error) {
+	boundary, err := NewBoundary(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("email: mime boundary: %w", err)
+	}
+	w := multipart.NewWriter(buf)
+	if err := w.SetBoundary(boundary); err != nil {
+		return nil, "", err
+	}
+	return w, boundary, nil
+}
+
+func writeTextPart(
+	w *multipart.Writer,
+	ctype string,
+	body []byte,
+	enc types.TransferEncoding,
+) {
 	h := textproto.MIMEHeader{}
-	h.Set("Content-Type", `text/html; charset="UTF-8"`)
-	h.Set("Content-Transfer-Encoding", "quoted-printable")
+	h.Set("Content-Type", ctype)
+	h.Set("Content-Transfer-Encoding", string(enc))
 	pw, _ := w.CreatePart(h)
-	writeQuotedPrintable(pw, body)
+	writeText(pw, body, enc)
 }
 
-func writeAttachment(w *multipart.Writer, a types.Attachment) {
+// writeAttachment streams a base64 encoded attachment part and returns
+// its encoded size. If opts.MaxAttachmentSize is positive, the encoded
+// size is capped and a *types.SizeError naming the attachment is
+// returned once exceeded. With opts.AttachmentCache the content is read
+// into memory and hashed so the encoding can be reused.
+func writeAttachment(
+	w *multipart.Writer,
+	a types.Attachment,
+	opts BuildOptions,
+) (int64, error) {
+	maxSize := opts.MaxAttachmentSize
 	ct := a.ContentType
 	if ct == "" {
-		ct = "application/octet-stream"
+		ct, a.Reader = sniffContentType(a)
 	}
 	h := textproto.MIMEHeader{}
 	if a.ContentID != "" {
@@ -229,40 +661,202 @@ func writeAttachment(w *multipart.Writer, a types.Attachment) {
 	h.Set("Content-Type", ct)
 	h.Set("Content-Transfer-Encoding", "base64")
 
-	pw, _ := w.CreatePart(h)
-	enc := base64.NewEncoder(base64.StdEncoding, newCRLFWriter(pw, 76))
-	defer enc.Close()
-	_, _ = io.Copy(enc, a.Reader)
+	pw, err := w.CreatePart(h)
+	if err != nil {
+		return 0, err
+	}
+	lw := &limitWriter{w: pw, limit: maxSize, name: a.Filename}
+	if maxSize <= 0 {
+		lw.limit = math.MaxInt64
+	}
+	if opts.AttachmentCache != nil {
+		encoded, err := cachedAttachment(a.Reader, opts.AttachmentCache)
+		if err != nil {
+			return 0, err
+		}
+		_, err = lw.Write(encoded)
+		return lw.n, err
+	}
+	enc := base64.NewEncoder(base64.StdEncoding, newCRLFWriter(lw, 76))
+	if a.Reader != nil {
+		if _, err := io.Copy(enc, a.Reader); err != nil {
+			return lw.n, err
+		}
+	}
+	err = enc.Close()
+	return lw.n, err
 }
 
-// writeQuotedPrintable writes text as quoted-printable with CRLF breaks.
+// ScanAttachments scans each attachment with scanner and returns the
+// attachments with readers over the scanned content, which is read
+// into memory. A nil scanner returns attach unchanged.
+//
+// Parameters:
+//   - ctx: The context.
+//   - attach: The attachments.
+//   - scanner: The scanner, or nil.
+//
+// Returns:
+//   - []types.Attachment: The attachments, ready to be read again.
+//   - error: A *types.MalwareError naming an infected attachment, or
+//     the error reading or scanning one.
+func ScanAttachments(
+	ctx context.Context,
+	attach []types.Attachment,
+	scanner types.AttachmentScanner,
+) ([]types.Attachment, error) {
+	if scanner == nil || len(attach) == 0 {
+		return attach, nil
+	}
+	out := make([]types.Attachment, len(attach))
+	for i, a := range attach {
+		var data []byte
+		if a.Reader != nil {
+			var err error
+			if data, err = io.ReadAll(a.Reader); err != nil {
+				return nil, fmt.Errorf("read attachment %q: %w", a.Filename, err)
+			}
+		}
+		threat, err := scanner.ScanAttachment(ctx, bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("scan attachment %q: %w", a.Filename, err)
+		}
+		if threat != "" {
+			return nil, &types.MalwareError{Filename: a.Filename, Threat: threat}
+		}
+		a.Reader = bytes.NewReader(data)
+		out[i] = a
+	}
+	return out, nil
+}
+
+// sniffContentType guesses the content type of a from its filename
+// extension, falling back to content sniffing. It returns a reader that
+// still yields the full content.
+func sniffContentType(a types.Attachment) (string, io.Reader) {
+	if ct := mime.TypeByExtension(path.Ext(a.Filename)); ct != "" {
+		return ct, a.Reader
+	}
+	if a.Reader == nil {
+		return "application/octet-stream", nil
+	}
+	// http.DetectContentType considers at most 512 bytes.
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(a.Reader, head)
+	head = head[:n]
+	r := io.MultiReader(bytes.NewReader(head), a.Reader)
+	if n == 0 {
+		return "application/octet-stream", r
+	}
+	return http.DetectContentType(head), r
+}
+
+// cachedAttachment returns the encoded body of r from cache, encoding
+// and storing it on a miss.
+func cachedAttachment(r io.Reader, cache types.AttachmentCache) ([]byte, error) {
+	var data []byte
+	if r != nil {
+		var err error
+		if data, err = io.ReadAll(r); err != nil {
+			return nil, err
+		}
+	}
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:])
+	if encoded, ok := cache.Get(key); ok {
+		return encoded, nil
+	}
+	var buf bytes.Buffer
+	buf.Grow(base64Size(int64(len(data))))
+	enc := base64.NewEncoder(base64.StdEncoding, newCRLFWriter(&buf, 76))
+	_, _ = enc.Write(data)
+	_ = enc.Close()
+	cache.Put(key, buf.Bytes())
+	return buf.Bytes(), nil
+}
+
+// limitWriter fails with a *types.SizeError once more than limit bytes
+// have been written. The error is sticky. name is the attachment being
+// limited, empty for the whole message.
+type limitWriter struct {
+	w     io.Writer
+	n     int64
+	limit int64
+	name  string
+	err   error
+}
+
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	if lw.err != nil {
+		return 0, lw.err
+	}
+	if lw.n+int64(len(p)) > lw.limit {
+		lw.err = &types.SizeError{
+			Attachment: lw.name,
+			Size:       lw.n + int64(len(p)),
+			Limit:      lw.limit,
+		}
+		return 0, lw.err
+	}
+	n, err := lw.w.Write(p)
+	lw.n += int64(n)
+	return n, err
+}
+
+// writeQuotedPrintable writes text as quoted-printable with CRLF line
+// breaks. LF, CR and CRLF line endings in b are all normalized to CRLF,
+// trailing whitespace is encoded, and a "." starting a line is written
+// as "=2E" so the body never depends on SMTP dot-stuffing.
 func writeQuotedPrintable(w io.Writer, b []byte) {
-	const hex = "0123456789ABCDEF"
-	col := 0
-	for _, c := range b {
-		var out []byte
-		switch {
-		case c == '\r':
-			continue
-		case c == '\n':
-			w.Write([]byte("\r\n"))
-			col = 0
-			continue
-		case c == '=' || c < 32 || c > 126:
-			out = []byte{'=', hex[c>>4], hex[c&15]}
-		default:
-			out = []byte{c}
+	dw := &dotSafeWriter{w: w}
+	qw := quotedprintable.NewWriter(dw)
+	_, _ = qw.Write(b)
+	_ = qw.Close()
+	_ = dw.flush()
+	_, _ = w.Write([]byte("\r\n"))
+}
+
+// dotSafeWriter buffers quoted-printable output a line at a time and
+// encodes a leading ".", adding a soft break if the line would exceed
+// 76 columns.
+type dotSafeWriter struct {
+	w    io.Writer
+	line []byte
+}
+
+func (d *dotSafeWriter) Write(p []byte) (int, error) {
+	for i, c := range p {
+		d.line = append(d.line, c)
+		if c == '\n' {
+			if err := d.flush(); err != nil {
+				return i, err
+			}
 		}
-		if col+len(out) > 75 {
-			w.Write([]byte("=\r\n"))
-			col = 0
+	}
+	return len(p), nil
+}
+
+// flush writes the buffered line.
+func (d *dotSafeWriter) flush() error {
+	line := d.line
+	d.line = d.line[:0]
+	if len(line) > 0 && line[0] == '.' {
+		prefix := "=2E"
+		if len(bytes.TrimSuffix(line, []byte("\r\n")))+2 > 76 {
+			prefix = "=2E=\r\n"
+		}
+		if _, err := io.WriteString(d.w, prefix); err != nil {
+			return err
 		}
-		w.Write(out)
-		col += len(out)
+		line = line[1:]
 	}
-	w.Write([]byte("\r\n"))
+	_, err := d.w.Write(line)
+	return err
 }
 
+// crlf is shared so that line breaks do not allocate per line.
+var crlf = []byte("\r\n")
+
 type crlfWriter struct {
 	w   io.Writer
 	col int
@@ -278,7 +872,7 @@ func (cw *crlfWriter) Write(p []byte) (int, error) {
 	for len(p) > 0 {
 		remain := cw.n - cw.col
 		if remain <= 0 {
-			if _, err := cw.w.Write([]byte("\r\n")); err != nil {
+			if _, err := cw.w.Write(crlf); err != nil {
 				return written, err
 			}
 			cw.col = 0
@@ -296,7 +890,7 @@ func (cw *crlfWriter) Write(p []byte) (int, error) {
 		}
 		p = p[n:]
 		if cw.col >= cw.n {
-			if _, err := cw.w.Write([]byte("\r\n")); err != nil {
+			if _, err := cw.w.Write(crlf); err != nil {
 				return written, err
 			}
 			cw.col = 0
@@ -305,18 +899,80 @@ func (cw *crlfWriter) Write(p []byte) (int, error) {
 	return written, nil
 }
 
-// setHeader sets/overwrites a header key.
-func setHeader(h map[string]string, key, val string) {
+// setHeader sets/overwrites a header key. Empty values are skipped.
+func setHeader(h *types.Headers, key, val string) {
 	if val == "" {
 		return
 	}
-	h[key] = val
+	h.Set(key, val)
 }
 
-// ensureListUnsub folds header variants into the standard key.
-func ensureListUnsub(h map[string]string, listUnsub string) {
-	if listUnsub == "" {
+// maxPooledBuffer is the largest buffer returned to bufferPool; bigger
+// ones are left to the garbage collector so one huge message does not
+// pin its memory.
+const maxPooledBuffer = 4 << 20
+
+// bufferPool recycles the scratch buffers of Build.
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from bufferPool.
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer resets b and returns it to bufferPool.
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBuffer {
 		return
 	}
-	setHeader(h, "List-Unsubscribe", listUnsub)
+	b.Reset()
+	bufferPool.Put(b)
+}
+
+// partOverhead approximates the boundary and headers of one MIME part.
+const partOverhead = 512
+
+// buildCopy copies msg for Build like Clone, but shares the Plain and
+// HTML bodies: the builder only reassigns them, and copying them on
+// every build would cost as much as the build. Hooks must likewise
+// replace a body rather than change its bytes.
+func buildCopy(msg types.Message) types.Message {
+	plain, html := msg.Plain, msg.HTML
+	msg.Plain, msg.HTML = nil, nil
+	c := msg.Clone()
+	c.Plain, c.HTML = plain, html
+	return c
+}
+
+// estimateBodySize guesses the encoded body size of msg so the body
+// buffer can be grown once. Attachment readers that report their
+// length (bytes.Reader, strings.Reader, bytes.Buffer, io.SectionReader)
+// are counted at their base64 size; others are not counted. The
+// estimate is capped at MaxMessageSize.
+func estimateBodySize(msg types.Message, opts BuildOptions) int {
+	// Quoted-printable and base64 grow text by about a third at most
+	// for typical content.
+	n := int64(len(msg.Plain)+len(msg.HTML))*4/3 + 2*partOverhead
+	for _, a := range msg.Attach {
+		n += partOverhead
+		switch r := a.Reader.(type) {
+		case interface{ Len() int }:
+			n += int64(base64Size(int64(r.Len())))
+		case interface{ Size() int64 }:
+			n += int64(base64Size(r.Size()))
+		}
+	}
+	if opts.MaxMessageSize > 0 && n > opts.MaxMessageSize {
+		n = opts.MaxMessageSize
+	}
+	return int(min(n, math.MaxInt32))
+}
+
+// base64Size returns the size of n bytes encoded as base64 in 76
+// character lines with CRLF line breaks.
+func base64Size(n int64) int {
+	enc := int64(base64.StdEncoding.EncodedLen(int(n)))
+	return int(enc + (enc+75)/76*2)
 }
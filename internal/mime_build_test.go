@@ -3,8 +3,17 @@ package internal
 import (
 	"bytes"
 	"context"
+	"crypto"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
 	"net/textproto"
+	"slices"
 	"strings"
 	"testing"
 
@@ -18,7 +27,7 @@ func TestBuildMIMEPlainOnly(t *testing.T) {
 		Plain:   []byte("hello\nworld"),
 		Subject: "Hi",
 	}
-	b, err := BuildMIME(context.Background(), msg, "<mailto:unsub@x>", nil, nil)
+	b, err := BuildMIME(context.Background(), msg, BuildOptions{ListUnsub: "<mailto:unsub@x>"})
 	if err != nil {
 		t.Fatalf("build: %v", err)
 	}
@@ -34,13 +43,213 @@ func TestBuildMIMEPlainOnly(t *testing.T) {
 	}
 }
 
+func TestBuildMIMERejectsListUnsubInjection(t *testing.T) {
+	msg := types.Message{
+		From:  types.Address{Mail: "no-reply@example.com"},
+		To:    []types.Address{{Mail: "to@example.com"}},
+		Plain: []byte("hi"),
+	}
+	_, err := BuildMIME(context.Background(), msg, BuildOptions{ListUnsub: "<mailto:x>\r\nBcc: evil@example.com"})
+	var he *types.HeaderError
+	if !errors.As(err, &he) {
+		t.Fatalf("expected HeaderError, got %v", err)
+	}
+}
+
+func TestBuildMIMEBIMISelector(t *testing.T) {
+	msg := types.Message{
+		From:  types.Address{Mail: "no-reply@example.com"},
+		To:    []types.Address{{Mail: "to@example.com"}},
+		Plain: []byte("hi"),
+	}
+	b, err := BuildMIME(context.Background(), msg, BuildOptions{BIMISelector: "brand"})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if !strings.Contains(string(b), "BIMI-Selector: v=BIMI1; s=brand\r\n") {
+		t.Fatalf("missing BIMI-Selector header: %s", b)
+	}
+}
+
+func TestBuildMIMEThreadingHeaders(t *testing.T) {
+	var refs []string
+	for i := 0; i < 6; i++ {
+		refs = append(refs, fmt.Sprintf("thread-%d-0123456789@example.com", i))
+	}
+	refs[1] = "<" + refs[1] + ">"
+	msg := types.Message{
+		From:       types.Address{Mail: "support@example.com"},
+		To:         []types.Address{{Mail: "to@example.com"}},
+		ReplyTo:    []types.Address{{Name: "Support", Mail: "tickets@example.com"}},
+		InReplyTo:  "thread-5-0123456789@example.com",
+		References: refs,
+		Plain:      []byte("hi"),
+	}
+	b, err := BuildMIME(context.Background(), msg, BuildOptions{})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	s := string(b)
+	if !strings.Contains(s, "Reply-To: \"Support\" <tickets@example.com>\r\n") {
+		t.Fatalf("missing Reply-To: %s", s)
+	}
+	if !strings.Contains(s, "In-Reply-To: <thread-5-0123456789@example.com>\r\n") {
+		t.Fatalf("missing In-Reply-To: %s", s)
+	}
+	i := strings.Index(s, "References:")
+	end := strings.Index(s[i:], "\r\n\r\n")
+	hdr := s[i : i+end]
+	for _, line := range strings.Split(hdr, "\r\n") {
+		if len(line) > 78 {
+			t.Fatalf("References not folded: %q", line)
+		}
+	}
+	unfolded := strings.ReplaceAll(hdr, "\r\n", "")
+	if !strings.Contains(unfolded, "<thread-0-0123456789@example.com> <thread-1-0123456789@example.com>") ||
+		strings.Contains(unfolded, "<<") {
+		t.Fatalf("References malformed: %q", unfolded)
+	}
+}
+
+func TestBuildMIMESender(t *testing.T) {
+	msg := types.Message{
+		From:      types.Address{Name: "Ann", Mail: "ann@example.com"},
+		ExtraFrom: []types.Address{{Mail: "bob@example.com"}},
+		Sender:    types.Address{Name: "Assistant", Mail: "pa@example.com"},
+		To:        []types.Address{{Mail: "to@example.org"}},
+		Plain:     []byte("hi"),
+	}
+	b, err := BuildMIME(context.Background(), msg, BuildOptions{})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	s := string(b)
+	if !strings.Contains(s, "\r\nFrom: \"Ann\" <ann@example.com>, bob@example.com\r\n"+
+		"Sender: \"Assistant\" <pa@example.com>\r\n") {
+		t.Fatalf("missing From/Sender: %s", s)
+	}
+	msg.Sender = types.Address{}
+	if _, err := BuildMIME(context.Background(), msg, BuildOptions{}); err == nil {
+		t.Fatal("expected error for multiple From without Sender")
+	}
+}
+
+func TestBuildMessageID(t *testing.T) {
+	msg := types.Message{
+		From:  types.Address{Mail: "no-reply@example.com"},
+		To:    []types.Address{{Mail: "to@example.com"}},
+		Plain: []byte("hi"),
+	}
+	b, err := Build(context.Background(), msg, BuildOptions{MessageIDDomain: "ids.example.net"})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if !strings.HasSuffix(b.MessageID, "@ids.example.net>") ||
+		!strings.Contains(string(b.Raw), "Message-ID: "+b.MessageID+"\r\n") {
+		t.Fatalf("unexpected Message-ID %q", b.MessageID)
+	}
+
+	gen := func(m *types.Message, domain string) string { return "queue-42@" + domain }
+	b, err = Build(context.Background(), msg, BuildOptions{MessageIDGenerator: gen})
+	if err != nil || b.MessageID != "<queue-42@example.com>" {
+		t.Fatalf("generator not used: %q %v", b.MessageID, err)
+	}
+
+	bad := func(m *types.Message, domain string) string { return "x\r\nBcc: evil@example.com" }
+	if _, err := Build(context.Background(), msg, BuildOptions{MessageIDGenerator: bad}); err == nil {
+		t.Fatalf("expected invalid generated Message-ID to fail")
+	}
+
+	msg.Headers = types.Headers{{Name: "Message-ID", Value: "<fixed@example.com>"}}
+	b, err = Build(context.Background(), msg, BuildOptions{MessageIDGenerator: gen})
+	if err != nil || b.MessageID != "<fixed@example.com>" {
+		t.Fatalf("explicit Message-ID should win: %q %v", b.MessageID, err)
+	}
+}
+
+func TestBuildMIMEHeaderOrderDeterministic(t *testing.T) {
+	msg := types.Message{
+		From:    types.Address{Mail: "no-reply@example.com"},
+		To:      []types.Address{{Mail: "to@example.com"}},
+		Subject: "Hi",
+		Plain:   []byte("hi"),
+		Headers: types.Headers{
+			{Name: "X-B", Value: "2"}, {Name: "X-A", Value: "1"},
+			{Name: "Message-ID", Value: "<fixed@example.com>"},
+			{Name: "From", Value: "ignored@example.com"},
+		},
+	}
+	names := func() []string {
+		b, err := BuildMIME(context.Background(), msg, BuildOptions{ListUnsub: "<mailto:u@x>"})
+		if err != nil {
+			t.Fatalf("build: %v", err)
+		}
+		head := string(b[:bytes.Index(b, []byte("\r\n\r\n"))])
+		var out []string
+		for _, line := range strings.Split(head, "\r\n") {
+			name, _, _ := strings.Cut(line, ":")
+			out = append(out, name)
+		}
+		if strings.Contains(head, "ignored@example.com") {
+			t.Fatalf("custom From must not override core field: %s", head)
+		}
+		return out
+	}
+	want := []string{
+		"Date", "From", "To", "Message-ID", "Subject", "MIME-Version",
+		"Content-Type", "Content-Transfer-Encoding", "List-Unsubscribe",
+		"X-B", "X-A",
+	}
+	for i := 0; i < 5; i++ {
+		if got := names(); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("unexpected header order:\n got=%v\nwant=%v", got, want)
+		}
+	}
+}
+
+func TestBuildMIMERepeatedCustomHeaders(t *testing.T) {
+	key, keyPEM := testDKIMKey(t)
+	lookup := func(d, s string) (crypto.PublicKey, error) { return &key.PublicKey, nil }
+	var h types.Headers
+	h.Add("X-Tag", "one")
+	h.Add("X-Other", "x")
+	h.Add("X-Tag", "two")
+	msg := types.Message{
+		From:    types.Address{Mail: "no-reply@example.com"},
+		To:      []types.Address{{Mail: "to@example.com"}},
+		Subject: "Hi",
+		Plain:   []byte("hi"),
+		Headers: h,
+	}
+	cfg := types.DKIMConfig{
+		Domain: "example.com", Selector: "sel", KeyPEM: keyPEM,
+		Headers: []string{"From", "X-Tag", "X-Tag"},
+	}
+	raw, err := BuildMIME(context.Background(), msg, BuildOptions{DKIM: &cfg})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	s := string(raw)
+	i, j := strings.Index(s, "X-Tag: one\r\n"), strings.Index(s, "X-Tag: two\r\n")
+	if i < 0 || j < i || !strings.Contains(s[i:j], "X-Other: x") {
+		t.Fatalf("repeated headers not kept in order:\n%s", s)
+	}
+	if _, err := VerifyDKIM(raw, lookup); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	bad := strings.Replace(s, "X-Tag: one", "X-Tag: uno", 1)
+	if _, err := VerifyDKIM([]byte(bad), lookup); err == nil {
+		t.Fatalf("expected verification failure after tampering")
+	}
+}
+
 func TestBuildMIMEHTMLOnly(t *testing.T) {
 	msg := types.Message{
 		From: types.Address{Mail: "no-reply@example.com"},
 		To:   []types.Address{{Mail: "to@example.com"}},
 		HTML: []byte("<p>Hi</p>"),
 	}
-	b, err := BuildMIME(context.Background(), msg, "", nil, nil)
+	b, err := BuildMIME(context.Background(), msg, BuildOptions{})
 	if err != nil {
 		t.Fatalf("build: %v", err)
 	}
@@ -57,7 +266,7 @@ func TestBuildMIMEMultipartAlternative(t *testing.T) {
 		Plain: []byte("hi"),
 		HTML:  []byte("<b>hi</b>"),
 	}
-	b, err := BuildMIME(context.Background(), msg, "", nil, nil)
+	b, err := BuildMIME(context.Background(), msg, BuildOptions{})
 	if err != nil {
 		t.Fatalf("build: %v", err)
 	}
@@ -79,7 +288,7 @@ func TestBuildMIMEMixedWithAttachment(t *testing.T) {
 			{Filename: "file.txt", Reader: bytes.NewReader([]byte("hello"))},
 		},
 	}
-	b, err := BuildMIME(context.Background(), msg, "", nil, nil)
+	b, err := BuildMIME(context.Background(), msg, BuildOptions{})
 	if err != nil {
 		t.Fatalf("build: %v", err)
 	}
@@ -95,6 +304,265 @@ func TestBuildMIMEMixedWithAttachment(t *testing.T) {
 	}
 }
 
+func TestBuildMIMESizeLimits(t *testing.T) {
+	msg := func() types.Message {
+		return types.Message{
+			From:  types.Address{Mail: "no-reply@example.com"},
+			To:    []types.Address{{Mail: "to@example.com"}},
+			Plain: []byte("hi"),
+			Attach: []types.Attachment{
+				{Filename: "small.txt", Reader: strings.NewReader("ok")},
+				{Filename: "big.bin", Reader: strings.NewReader(strings.Repeat("x", 4096))},
+			},
+		}
+	}
+	_, err := BuildMIME(context.Background(), msg(), BuildOptions{MaxAttachmentSize: 1024})
+	var se *types.SizeError
+	if !errors.As(err, &se) || se.Attachment != "big.bin" || se.Limit != 1024 {
+		t.Fatalf("expected attachment SizeError for big.bin, got %v", err)
+	}
+
+	_, err = BuildMIME(context.Background(), msg(), BuildOptions{MaxMessageSize: 2048})
+	if !errors.As(err, &se) || se.Attachment != "" || se.Limit != 2048 {
+		t.Fatalf("expected message SizeError, got %v", err)
+	}
+
+	b, err := BuildMIME(context.Background(), msg(), BuildOptions{MaxMessageSize: 1 << 20, MaxAttachmentSize: 8192})
+	if err != nil || len(b) == 0 {
+		t.Fatalf("expected build within limits, got %v", err)
+	}
+}
+
+func TestBuildAttachmentContentType(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 600)
+	msg := types.Message{
+		From:  types.Address{Mail: "no-reply@example.com"},
+		To:    []types.Address{{Mail: "to@example.com"}},
+		Plain: []byte("hi"),
+		Attach: []types.Attachment{
+			{Filename: "doc.pdf", Reader: strings.NewReader("%PDF-1.4")},
+			{Filename: "image", Reader: strings.NewReader(png)},
+			{Filename: "blob", Reader: strings.NewReader("\x00\x01\x02")},
+			{Filename: "given.bin", ContentType: "text/csv", Reader: strings.NewReader("a,b")},
+		},
+	}
+	raw, err := BuildMIME(context.Background(), msg, BuildOptions{})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	_, params, _ := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	r := multipart.NewReader(m.Body, params["boundary"])
+	var ctypes []string
+	var imageBody []byte
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("part: %v", err)
+		}
+		ctypes = append(ctypes, p.Header.Get("Content-Type"))
+		if p.FileName() == "image" {
+			imageBody, _ = io.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+		}
+	}
+	want := []string{"application/pdf", "image/png", "application/octet-stream", "text/csv"}
+	if len(ctypes) != 5 || !slices.Equal(ctypes[1:], want) {
+		t.Fatalf("unexpected content types: %v", ctypes)
+	}
+	if string(imageBody) != png {
+		t.Fatalf("sniffed attachment content was truncated: %d bytes", len(imageBody))
+	}
+}
+
+func TestBuildSanitizeHTML(t *testing.T) {
+	msg := types.Message{
+		From: types.Address{Mail: "no-reply@example.com"},
+		To:   []types.Address{{Mail: "to@example.com"}},
+		HTML: []byte("<p>hi</p><script>x</script>"),
+	}
+	b, err := BuildMIME(context.Background(), msg, BuildOptions{
+		SanitizeHTML: func(h []byte) []byte {
+			return bytes.ReplaceAll(h, []byte("<script>x</script>"), nil)
+		},
+	})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if bytes.Contains(b, []byte("script")) || string(msg.HTML) != "<p>hi</p><script>x</script>" {
+		t.Fatalf("sanitizer not applied to the copy only:\n%s", b)
+	}
+}
+
+func TestBuildTransforms(t *testing.T) {
+	msg := types.Message{
+		From: types.Address{Mail: "no-reply@example.com"},
+		To:   []types.Address{{Mail: "to@example.com"}},
+		HTML: []byte("a"),
+	}
+	appendStr := func(s string) types.BuildTransform {
+		return types.BuildTransformFunc(func(ctx context.Context, h []byte) ([]byte, error) {
+			return append(h, s...), nil
+		})
+	}
+	b, err := BuildMIME(context.Background(), msg, BuildOptions{
+		SanitizeHTML: func(h []byte) []byte { return append(h, 's') },
+		Transforms:   []types.BuildTransform{appendStr("1"), appendStr("2")},
+	})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if !bytes.Contains(b, []byte("\r\n\r\nas12\r\n")) || string(msg.HTML) != "a" {
+		t.Fatalf("transforms not applied in order:\n%s", b)
+	}
+
+	boom := errors.New("boom")
+	_, err = BuildMIME(context.Background(), msg, BuildOptions{
+		Transforms: []types.BuildTransform{types.BuildTransformFunc(
+			func(ctx context.Context, h []byte) ([]byte, error) { return nil, boom })},
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected transform error, got %v", err)
+	}
+}
+
+func TestBuildAttachmentPolicy(t *testing.T) {
+	msg := types.Message{
+		From:   types.Address{Mail: "no-reply@example.com"},
+		To:     []types.Address{{Mail: "to@example.com"}},
+		Plain:  []byte("hi"),
+		Attach: []types.Attachment{{Filename: "invoice.pdf.exe"}},
+	}
+	if _, err := BuildMIME(context.Background(), msg, BuildOptions{}); err != nil {
+		t.Fatalf("no policy should allow any name: %v", err)
+	}
+	p := types.DefaultAttachmentPolicy()
+	_, err := BuildMIME(context.Background(), msg, BuildOptions{AttachmentPolicy: &p})
+	var ae *types.AttachmentError
+	if !errors.As(err, &ae) || ae.Filename != "invoice.pdf.exe" {
+		t.Fatalf("expected AttachmentError, got %v", err)
+	}
+}
+
+// markerScanner reports content containing "EICAR" as infected.
+type markerScanner struct{ err error }
+
+func (s markerScanner) ScanAttachment(ctx context.Context, r io.Reader) (string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil || s.err != nil {
+		return "", errors.Join(err, s.err)
+	}
+	if bytes.Contains(b, []byte("EICAR")) {
+		return "Eicar-Signature", nil
+	}
+	return "", nil
+}
+
+func TestBuildAttachmentScanner(t *testing.T) {
+	msg := types.Message{
+		From:  types.Address{Mail: "no-reply@example.com"},
+		To:    []types.Address{{Mail: "to@example.com"}},
+		Plain: []byte("hi"),
+		Attach: []types.Attachment{
+			{Filename: "a.txt", Reader: strings.NewReader("clean")},
+			{Filename: "b.txt", Reader: strings.NewReader("X5O EICAR test")},
+		},
+	}
+	_, err := BuildMIME(context.Background(), msg, BuildOptions{AttachmentScanner: markerScanner{}})
+	var me *types.MalwareError
+	if !errors.As(err, &me) || me.Filename != "b.txt" || me.Threat != "Eicar-Signature" {
+		t.Fatalf("expected MalwareError, got %v", err)
+	}
+
+	msg.Attach = msg.Attach[:1]
+	msg.Attach[0].Reader = strings.NewReader("clean")
+	raw, err := BuildMIME(context.Background(), msg, BuildOptions{AttachmentScanner: markerScanner{}})
+	if err != nil || !strings.Contains(string(raw), base64.StdEncoding.EncodeToString([]byte("clean"))) {
+		t.Fatalf("clean attachment: %v\n%s", err, raw)
+	}
+
+	msg.Attach[0].Reader = strings.NewReader("clean")
+	_, err = BuildMIME(context.Background(), msg, BuildOptions{
+		AttachmentScanner: markerScanner{err: errors.New("clamd down")},
+	})
+	if err == nil || !strings.Contains(err.Error(), `scan attachment "a.txt": clamd down`) {
+		t.Fatalf("expected a scan error, got %v", err)
+	}
+}
+
+// countingCache is a map-backed types.AttachmentCache counting hits.
+type countingCache struct {
+	m    map[string][]byte
+	hits int
+}
+
+func (c *countingCache) Get(key string) ([]byte, bool) {
+	b, ok := c.m[key]
+	if ok {
+		c.hits++
+	}
+	return b, ok
+}
+
+func (c *countingCache) Put(key string, encoded []byte) { c.m[key] = encoded }
+
+func TestBuildAttachmentCache(t *testing.T) {
+	content := strings.Repeat("report data ", 100)
+	msg := func() types.Message {
+		return types.Message{
+			From:  types.Address{Mail: "no-reply@example.com"},
+			To:    []types.Address{{Mail: "to@example.com"}},
+			Plain: []byte("hi"),
+			Attach: []types.Attachment{
+				{Filename: "a.txt", Reader: strings.NewReader(content)},
+				{Filename: "copy.txt", Reader: strings.NewReader(content)},
+				{Filename: "empty.txt"},
+			},
+		}
+	}
+	plain, err := Build(context.Background(), msg(), BuildOptions{})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	cache := &countingCache{m: map[string][]byte{}}
+	for i := 0; i < 2; i++ {
+		b, err := Build(context.Background(), msg(), BuildOptions{AttachmentCache: cache})
+		if err != nil {
+			t.Fatalf("build: %v", err)
+		}
+		if !slices.Equal(b.AttachmentSizes, plain.AttachmentSizes) {
+			t.Fatalf("sizes differ: %v vs %v", b.AttachmentSizes, plain.AttachmentSizes)
+		}
+		_, body, _ := strings.Cut(string(b.Raw), "\r\n\r\n")
+		_, want, _ := strings.Cut(string(plain.Raw), "\r\n\r\n")
+		if strings.Count(body, "cmVwb3J0IGRhdGEg") != strings.Count(want, "cmVwb3J0IGRhdGEg") {
+			t.Fatalf("cached encoding differs from streamed encoding")
+		}
+	}
+	// The first build misses twice then hits the duplicate; the second
+	// build hits all three.
+	if len(cache.m) != 2 || cache.hits != 4 {
+		t.Fatalf("unexpected cache use: %d entries, %d hits", len(cache.m), cache.hits)
+	}
+	if plain.AttachmentSizes[0] != int64(len(content)*4/3+len(content)*4/3/76*2) ||
+		plain.AttachmentSizes[2] != 0 {
+		t.Fatalf("unexpected sizes: %v", plain.AttachmentSizes)
+	}
+
+	_, err = Build(context.Background(), msg(), BuildOptions{
+		AttachmentCache: cache, MaxAttachmentSize: 1024,
+	})
+	var se *types.SizeError
+	if !errors.As(err, &se) || se.Attachment != "a.txt" {
+		t.Fatalf("expected SizeError for cached attachment, got %v", err)
+	}
+}
+
 // Ensure quoted-printable line folding works under 76/75 char rules.
 func TestQuotedPrintableWrapping(t *testing.T) {
 	long := strings.Repeat("A", 200)
@@ -107,6 +575,64 @@ func TestQuotedPrintableWrapping(t *testing.T) {
 	}
 }
 
+func TestQuotedPrintableEdgeCases(t *testing.T) {
+	tests := []struct {
+		name, in, want string // want is the decoded text, CRLF-normalized
+		contains       []string
+	}{
+		{"trailing space", "a \nb", "a \r\nb", []string{"a=20\r\n"}},
+		{"trailing tab", "a\t\r\nb", "a\t\r\nb", []string{"a=09\r\n"}},
+		{"trailing space at end", "end  ", "end  ", []string{"end =20\r\n"}},
+		{"dot line", "x\n.\ny", "x\r\n.\r\ny", []string{"\r\n=2E\r\n"}},
+		{"leading dot", ".hidden\n..two", ".hidden\r\n..two", []string{"=2Ehidden", "=2E.two"}},
+		{"dot after soft break", strings.Repeat("a", 75) + ".b", strings.Repeat("a", 75) + ".b", []string{"=\r\n=2Eb"}},
+		{"long dot line", "." + strings.Repeat("b", 74), "." + strings.Repeat("b", 74), []string{"=2E=\r\nbbb"}},
+		{"bare CR", "a\rb", "a\r\nb", nil},
+		{"blank lines", "a\n\n\nb", "a\r\n\r\n\r\nb", nil},
+		{"equals", "a=b", "a=b", []string{"a=3Db"}},
+		{"utf8", "grüße", "grüße", []string{"gr=C3=BC=C3=9Fe"}},
+		{"long utf8", strings.Repeat("ü", 60), strings.Repeat("ü", 60), nil},
+		{"empty", "", "", nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writeQuotedPrintable(&buf, []byte(tc.in))
+			out := buf.String()
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("missing final CRLF: %q", out)
+			}
+			for _, l := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+				if len(l) > 76 {
+					t.Fatalf("line over 76 chars: %q", l)
+				}
+				if strings.HasPrefix(l, ".") {
+					t.Fatalf("line starts with dot: %q", out)
+				}
+				if strings.HasSuffix(l, " ") || strings.HasSuffix(l, "\t") {
+					t.Fatalf("unencoded trailing whitespace: %q", out)
+				}
+			}
+			if strings.Contains(strings.ReplaceAll(out, "\r\n", ""), "\r") ||
+				strings.Count(out, "\n") != strings.Count(out, "\r\n") {
+				t.Fatalf("bare CR or LF in output: %q", out)
+			}
+			for _, c := range tc.contains {
+				if !strings.Contains(out, c) {
+					t.Fatalf("output %q missing %q", out, c)
+				}
+			}
+			dec, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(out)))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got := strings.TrimSuffix(string(dec), "\r\n"); got != tc.want {
+				t.Fatalf("round-trip:\n got=%q\nwant=%q", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestBuildMIMEHooks(t *testing.T) {
 	var started, done bool
 	var size int
@@ -128,7 +654,7 @@ func TestBuildMIMEHooks(t *testing.T) {
 		To:    []types.Address{{Mail: "to@example.com"}},
 		Plain: []byte("hi"),
 	}
-	b, err := BuildMIME(context.Background(), msg, "", nil, hooks)
+	b, err := BuildMIME(context.Background(), msg, BuildOptions{Hooks: hooks})
 	if err != nil {
 		t.Fatalf("build: %v", err)
 	}
@@ -137,6 +663,52 @@ func TestBuildMIMEHooks(t *testing.T) {
 	}
 }
 
+func TestBuildWarnings(t *testing.T) {
+	_, keyPEM := testDKIMKey(t)
+	var hooked []types.BuildWarning
+	hooks := &types.Hooks{
+		OnBuildWarning: func(ctx context.Context, w types.BuildWarning) {
+			hooked = append(hooked, w)
+		},
+	}
+	msg := types.Message{
+		From:    types.Address{Mail: "no-reply@example.com"},
+		To:      []types.Address{{Mail: "to@example.com"}},
+		Subject: "Hi",
+		Plain:   []byte(strings.Repeat("a", 1200) + "\nend"),
+		HTML:    []byte("<p>short</p>"),
+		Headers: types.Headers{
+			{Name: "Subject", Value: "Other"},
+			{Name: "X-Empty", Value: ""},
+			{Name: "X-Token", Value: strings.Repeat("b", 1000)},
+			{Name: "X-Ok", Value: "ok"},
+		},
+	}
+	opts := BuildOptions{Hooks: hooks, DKIM: &types.DKIMConfig{
+		Domain: "example.com", Selector: "sel", KeyPEM: keyPEM,
+		Headers: []string{"from", "x-campaign", "x-campaign"},
+	}}
+	b, err := Build(context.Background(), msg, opts)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	want := []types.BuildWarning{
+		{Code: types.WarnTextRewrapped, Field: "text/plain", Message: "lines over 998 octets encoded as quoted-printable"},
+		{Code: types.WarnHeaderIgnored, Field: "Subject", Message: "set by the builder"},
+		{Code: types.WarnHeaderIgnored, Field: "X-Empty", Message: "empty value"},
+		{Code: types.WarnLongHeaderLine, Field: "X-Token", Message: "cannot fold to 998 octets per line"},
+		{Code: types.WarnDKIMHeaderMissing, Field: "x-campaign", Message: "not in the message; left unsigned"},
+	}
+	if !slices.Equal(b.Warnings, want) || !slices.Equal(hooked, want) {
+		t.Fatalf("warnings %v, hooked %v", b.Warnings, hooked)
+	}
+
+	msg.Plain, msg.Headers = []byte("hi"), nil
+	if b, err = Build(context.Background(), msg, BuildOptions{}); err != nil || b.Warnings != nil {
+		t.Fatalf("clean build: %v, %v", b.Warnings, err)
+	}
+}
+
 func TestNewCRLFWriterWraps(t *testing.T) {
 	var buf bytes.Buffer
 	w := newCRLFWriter(&buf, 10)
@@ -158,12 +730,93 @@ func TestWriteFoldedHeader(t *testing.T) {
 	}
 }
 
+func TestFoldHeader(t *testing.T) {
+	addrs := strings.Repeat(`"Doe, Jane" <jane.doe@example.com>, `, 4) + "x@example.com"
+	tests := []struct {
+		name, key, val string
+		check          func(t *testing.T, lines []string)
+	}{
+		{"preserves whitespace", "Subject", strings.Repeat("a  b\t", 20), nil},
+		{"long token", "References", "<" + strings.Repeat("x", 90) + "@example.com> <b@example.com>",
+			func(t *testing.T, lines []string) {
+				if len(lines) != 2 || !strings.HasPrefix(lines[1], " <b@") {
+					t.Fatalf("long token not isolated: %q", lines)
+				}
+			}},
+		{"address list", "To", addrs, func(t *testing.T, lines []string) {
+			for _, l := range lines[1:] {
+				if !strings.HasPrefix(l, ` "Doe, Jane"`) && l != " x@example.com" {
+					t.Fatalf("address split mid-element: %q", lines)
+				}
+			}
+		}},
+		{"trailing space", "Subject", strings.Repeat("word ", 20) + "   ", nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := foldHeader(tc.key, tc.val)
+			lines := strings.Split(strings.TrimSuffix(got, "\r\n"), "\r\n")
+			for i, l := range lines {
+				if len(l) > 78 && !strings.Contains(l, strings.Repeat("x", 90)) {
+					t.Fatalf("line %d too long: %q", i, l)
+				}
+				if i > 0 && strings.TrimSpace(l) == "" {
+					t.Fatalf("whitespace-only line %d: %q", i, got)
+				}
+			}
+			if u := strings.ReplaceAll(got, "\r\n", ""); u != tc.key+": "+tc.val {
+				t.Fatalf("unfold mismatch:\n got=%q\nwant=%q", u, tc.key+": "+tc.val)
+			}
+			if tc.check != nil {
+				tc.check(t, lines)
+			}
+		})
+	}
+}
+
+func TestBuildMIMEEncodedSubject(t *testing.T) {
+	subj := strings.Repeat("Grüße aus Köln, ", 8) + "日本語の件名"
+	msg := types.Message{
+		From:    types.Address{Name: "Jörg Müller-Lüdenscheidt Größenwahn", Mail: "no-reply@example.com"},
+		To:      []types.Address{{Mail: "to@example.com"}},
+		Subject: subj,
+		Plain:   []byte("hi"),
+	}
+	b, err := BuildMIME(context.Background(), msg, BuildOptions{})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	m, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	got, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if err != nil || got != subj {
+		t.Fatalf("subject round-trip: %q %v", got, err)
+	}
+	from, err := m.Header.AddressList("From")
+	if err != nil || from[0].Name != msg.From.Name {
+		t.Fatalf("from round-trip: %v %v", from, err)
+	}
+	head := string(b[:bytes.Index(b, []byte("\r\n\r\n"))])
+	for _, l := range strings.Split(head, "\r\n") {
+		if len(l) > 78 {
+			t.Fatalf("line too long: %q", l)
+		}
+		for _, f := range strings.Fields(l) {
+			if strings.HasPrefix(f, "=?") != strings.HasSuffix(f, "?=") {
+				t.Fatalf("encoded-word split: %q", l)
+			}
+		}
+	}
+}
+
 // Ensure multipart writer boundaries are present and valid.
 func TestMultipartBoundaryHelpers(t *testing.T) {
 	var b1, b2 bytes.Buffer
-	w1, bd1 := newMixed(&b1)
-	w2, bd2 := newAlternative(&b2)
-	if bd1 == "" || bd2 == "" {
+	w1, bd1, err1 := newMixed(&b1, Rand())
+	w2, bd2, err2 := newAlternative(&b2, Rand())
+	if err1 != nil || err2 != nil || bd1 == "" || bd2 == "" {
 		t.Fatalf("empty boundary")
 	}
 	// Write a simple part to ensure writers are functional.
@@ -181,3 +834,116 @@ func TestMultipartBoundaryHelpers(t *testing.T) {
 		t.Fatalf("alt boundary unreadable: %v", err)
 	}
 }
+
+func TestBuildKeepsTraceHeadersFirst(t *testing.T) {
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("hi"),
+	}
+	msg.Headers.Add("X-Custom", "1")
+	msg.Headers.Add("Received", "from b by c; Mon, 12 Oct 2026 09:00:00 +0000")
+	msg.Headers.Add("Received", "from a by b; Mon, 12 Oct 2026 08:00:00 +0000")
+	msg.Headers.Prepend("Received", "from c by d; Mon, 12 Oct 2026 10:00:00 +0000")
+	raw, err := BuildMIME(context.Background(), msg, BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(raw), "\r\n")
+	for i, want := range []string{"from c by d", "from b by c", "from a by b"} {
+		if !strings.HasPrefix(lines[i], "Received: "+want+";") {
+			t.Fatalf("line %d = %q", i, lines[i])
+		}
+	}
+	if !strings.Contains(string(raw), "\r\nX-Custom: 1\r\n") {
+		t.Fatalf("custom header lost:\n%s", raw)
+	}
+}
+
+func TestBuildGroupRecipients(t *testing.T) {
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		Cc:    types.Group("Team", types.Address{Mail: "a@example.org"}, types.Address{Mail: "b@example.org"}),
+		Plain: []byte("hi"),
+	}
+	raw, err := BuildMIME(context.Background(), msg, BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), "\r\nCc: Team: a@example.org, b@example.org;\r\n") ||
+		strings.Contains(string(raw), "\r\nTo:") {
+		t.Fatalf("unexpected headers:\n%s", raw)
+	}
+	msg.Cc = nil
+	msg.Bcc = []types.Address{{Mail: "c@example.org"}}
+	raw, err = BuildMIME(context.Background(), msg, BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), "\r\nTo: undisclosed-recipients:;\r\n") ||
+		strings.Contains(string(raw), "c@example.org") {
+		t.Fatalf("unexpected headers:\n%s", raw)
+	}
+}
+
+// benchMessage returns a text and HTML message, with an attachment of
+// attachSize bytes for each entry of attachSizes.
+func benchMessage(attachSizes ...int) types.Message {
+	msg := types.Message{
+		From:    types.Address{Name: "Shop", Mail: "shop@example.com"},
+		To:      []types.Address{{Mail: "ada@example.org"}},
+		Subject: "Your order has shipped",
+		Plain:   bytes.Repeat([]byte("Thanks for your order. "), 200),
+		HTML:    bytes.Repeat([]byte("<p>Thanks for your <b>order</b>.</p>\n"), 200),
+	}
+	for i, n := range attachSizes {
+		msg.Attach = append(msg.Attach, types.Attachment{
+			Filename: fmt.Sprintf("invoice-%d.pdf", i), ContentType: "application/pdf",
+			Reader: bytes.NewReader(bytes.Repeat([]byte{0xA5}, n)),
+		})
+	}
+	return msg
+}
+
+func BenchmarkBuild(b *testing.B) {
+	small := types.Message{
+		From:    types.Address{Mail: "app@example.com"},
+		To:      []types.Address{{Mail: "ada@example.org"}},
+		Subject: "Sign-in code",
+		Plain:   []byte("Your code is 123456.\n"),
+	}
+	largeHTML := benchMessage()
+	largeHTML.HTML = bytes.Repeat([]byte("<tr><td>Item</td><td>€ 12,00</td></tr>\n"), 25000)
+	for _, bc := range []struct {
+		name string
+		msg  types.Message
+	}{
+		{"small-text", small},
+		{"text+html", benchMessage()},
+		{"large-html-1MiB", largeHTML},
+		{"multi-attachment", benchMessage(16<<10, 64<<10, 128<<10)},
+		{"attachment-256KiB", benchMessage(256 << 10)},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			msg := bc.msg
+			b.ReportAllocs()
+			for b.Loop() {
+				for _, a := range msg.Attach {
+					a.Reader.(*bytes.Reader).Seek(0, io.SeekStart)
+				}
+				if _, err := Build(context.Background(), msg, BuildOptions{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkWriteQuotedPrintable(b *testing.B) {
+	text := bytes.Repeat([]byte("Grüße aus Köln – the café opens at 9.\n"), 2000)
+	b.SetBytes(int64(len(text)))
+	b.ReportAllocs()
+	for b.Loop() {
+		writeQuotedPrintable(io.Discard, text)
+	}
+}
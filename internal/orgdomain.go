@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// OrgDomain returns the organizational domain of d (RFC 7489 3.2): its
+// public suffix, per the Public Suffix List, plus one label, e.g.
+// "example.co.uk" for "mail.example.co.uk". d is lowercased, without
+// a trailing dot, and in ASCII form; a public suffix itself is returned
+// as is.
+func OrgDomain(d string) string {
+	d = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(d)), ".")
+	if ascii, err := types.DomainToASCII(d); err == nil {
+		d = ascii
+	}
+	org, err := publicsuffix.EffectiveTLDPlusOne(d)
+	if err != nil {
+		return d
+	}
+	return org
+}
+
+// DomainsAligned reports whether a and b are aligned as by DMARC
+// relaxed alignment: they have the same organizational domain.
+func DomainsAligned(a, b string) bool {
+	if strings.TrimSpace(a) == "" || strings.TrimSpace(b) == "" {
+		return false
+	}
+	return OrgDomain(a) == OrgDomain(b)
+}
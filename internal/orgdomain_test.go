@@ -0,0 +1,28 @@
+package internal
+
+import "testing"
+
+func TestDomainsAligned(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"example.com", "example.com", true},
+		{"mail.example.com", "example.com", true},
+		{"example.com", "Mail.Example.com.", true},
+		{"bounces.example.com", "news.example.com", true},
+		{"a.example.co.uk", "b.example.co.uk", true},
+		{"badexample.com", "example.com", false},
+		{"example.co.uk", "other.co.uk", false},
+		{"co.uk", "example.co.uk", false},
+		{"", "example.com", false},
+	}
+	for _, c := range cases {
+		if got := DomainsAligned(c.a, c.b); got != c.want {
+			t.Fatalf("DomainsAligned(%q,%q)=%v want %v", c.a, c.b, got, c.want)
+		}
+	}
+	if got := OrgDomain("Mail.Example.CO.UK."); got != "example.co.uk" {
+		t.Fatalf("OrgDomain = %q", got)
+	}
+}
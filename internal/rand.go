@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"sync/atomic"
+)
+
+// randSource holds the reader behind Rand; nil means crypto/rand.Reader.
+var randSource atomic.Pointer[io.Reader]
+
+// Rand returns the random source set with SetRand.
+func Rand() io.Reader {
+	if r := randSource.Load(); r != nil {
+		return *r
+	}
+	return rand.Reader
+}
+
+// SetRand replaces the random source behind Rand and returns the
+// previous one. nil restores crypto/rand.Reader.
+func SetRand(r io.Reader) io.Reader {
+	var old *io.Reader
+	if r == nil {
+		old = randSource.Swap(nil)
+	} else {
+		old = randSource.Swap(&r)
+	}
+	if old == nil {
+		return nil
+	}
+	return *old
+}
+
+// rand returns the random source of a build: opts.Rand, or Rand.
+func (o BuildOptions) rand() io.Reader {
+	if o.Rand != nil {
+		return o.Rand
+	}
+	return Rand()
+}
+
+// NewBoundary returns a multipart boundary of 30 random bytes from r in
+// hex, like the ones multipart.Writer makes, for builders that must not
+// use the crypto/rand boundaries of multipart.NewWriter.
+func NewBoundary(r io.Reader) (string, error) {
+	var b [30]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// RandInt63n returns a uniform random number in [0, n) read from r. n
+// must be positive.
+func RandInt63n(r io.Reader, n int64) (int64, error) {
+	// Reject values from the top partial range so every result is
+	// equally likely.
+	limit := int64(1<<63-1) - (int64(1<<63-1)%n+1)%n
+	for {
+		var b [8]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, err
+		}
+		v := int64(binary.BigEndian.Uint64(b[:]) >> 1)
+		if v <= limit {
+			return v % n, nil
+		}
+	}
+}
+
+// RandFloat64 returns a uniform random number in [0, 1) read from r.
+func RandFloat64(r io.Reader) (float64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return float64(binary.BigEndian.Uint64(b[:])>>11) / (1 << 53), nil
+}
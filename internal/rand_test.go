@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// countingReader yields an increasing byte sequence.
+type countingReader struct{ n byte }
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = r.n
+		r.n++
+	}
+	return len(p), nil
+}
+
+func TestBuildRandSource(t *testing.T) {
+	restore := SetClock(func() time.Time { return time.Unix(1700000000, 0) })
+	defer SetClock(restore)
+	msg := types.Message{
+		From:   types.Address{Mail: "app@example.com"},
+		To:     []types.Address{{Mail: "ada@example.org"}},
+		Plain:  []byte("hi"),
+		HTML:   []byte("<p>hi</p>"),
+		Attach: []types.Attachment{{Filename: "a.txt", Reader: bytes.NewReader([]byte("a"))}},
+	}
+	build := func(r io.Reader) ([]byte, error) {
+		return BuildMIME(context.Background(), msg, BuildOptions{Rand: r})
+	}
+	a, err := build(&countingReader{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := build(&countingReader{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Fatalf("builds differ:\n%s\n%s", a, b)
+	}
+	if !bytes.Contains(a, []byte("boundary=\"000102")) {
+		t.Fatalf("boundary not from the source:\n%s", a)
+	}
+
+	old := SetRand(&countingReader{n: 7})
+	c, err := build(nil)
+	SetRand(old)
+	if err != nil || !bytes.Contains(c, []byte("boundary=\"0708")) {
+		t.Fatalf("SetRand not used: %v\n%s", err, c)
+	}
+
+	fail := errors.New("drbg failure")
+	if _, err := build(iotest.ErrReader(fail)); !errors.Is(err, fail) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestRandInt63n(t *testing.T) {
+	r := &countingReader{}
+	for _, n := range []int64{1, 2, 3, 10, 1 << 40} {
+		for range 100 {
+			v, err := RandInt63n(r, n)
+			if err != nil || v < 0 || v >= n {
+				t.Fatalf("RandInt63n(%d) = %d, %v", n, v, err)
+			}
+		}
+	}
+	f, err := RandFloat64(bytes.NewReader(bytes.Repeat([]byte{0xff}, 8)))
+	if err != nil || f >= 1 || f < 0.99 {
+		t.Fatalf("RandFloat64 = %v, %v", f, err)
+	}
+}
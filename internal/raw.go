@@ -0,0 +1,24 @@
+package internal
+
+import (
+	"bytes"
+	"net/mail"
+)
+
+// Raw wraps an already built message for delivery. The Message-ID is
+// taken from its header, if present, and the body type is derived from
+// the bytes: BINARYMIME for NUL bytes or overlong lines, 8BITMIME for
+// other non-ASCII content.
+func Raw(raw []byte) *Built {
+	b := &Built{Raw: raw}
+	if m, err := mail.ReadMessage(bytes.NewReader(raw)); err == nil {
+		b.MessageID = formatMsgID(m.Header.Get("Message-ID"))
+	}
+	switch {
+	case needsBinary(raw):
+		b.BodyType = BodyBinaryMIME
+	case !is7Bit(raw):
+		b.BodyType = Body8BitMIME
+	}
+	return b
+}
@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// Resend returns raw with a block of Resent-* fields for r prepended,
+// ready to be sent to r's recipients. The original header and body are
+// kept byte for byte.
+func Resend(raw []byte, r types.Resent) (*Built, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	date := r.Date
+	if date.IsZero() {
+		date = Now()
+	}
+	msgID := formatMsgID(r.MessageID)
+	if msgID == "" {
+		id, err := genMessageID(types.Message{From: r.From}, BuildOptions{})
+		if err != nil {
+			return nil, err
+		}
+		msgID = id
+	}
+	var h types.Headers
+	setHeader(&h, "Resent-Date", date.UTC().Format(time.RFC1123Z))
+	setHeader(&h, "Resent-From", r.From.String())
+	if len(r.To) > 0 {
+		setHeader(&h, "Resent-To", joinAddrs(r.To))
+	}
+	if len(r.Cc) > 0 {
+		setHeader(&h, "Resent-Cc", joinAddrs(r.Cc))
+	}
+	setHeader(&h, "Resent-Message-ID", msgID)
+
+	var out bytes.Buffer
+	out.Grow(len(raw) + 512)
+	for _, f := range h {
+		out.WriteString(foldHeader(f.Name, f.Value))
+	}
+	out.Write(raw)
+	b := &Built{Raw: out.Bytes(), MessageID: msgID}
+	if !is7Bit(raw) {
+		b.BodyType = Body8BitMIME
+	}
+	return b, nil
+}
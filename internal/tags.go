@@ -0,0 +1,18 @@
+package internal
+
+import "strings"
+
+// ParseTags parses a tag list such as "v=DMARC1; p=reject", the format
+// of DKIM, DMARC and BIMI records (RFC 6376 3.2). Keys are lowercased
+// and values trimmed; parts without "=" are skipped.
+func ParseTags(s string) map[string]string {
+	out := map[string]string{}
+	for _, part := range strings.Split(s, ";") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		out[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+	}
+	return out
+}
@@ -0,0 +1,14 @@
+package internal
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	got := ParseTags(" V=DMARC1;p = reject ; junk;rua=mailto:d@example.com; ")
+	want := map[string]string{"v": "DMARC1", "p": "reject", "rua": "mailto:d@example.com"}
+	if !maps.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// SMTP BODY= parameter values (RFC 6152, RFC 3030).
+const (
+	Body8BitMIME   = "8BITMIME"
+	BodyBinaryMIME = "BINARYMIME"
+)
+
+// maxLineOctets is the line length limit excluding CRLF (RFC 5322 2.1.1).
+const maxLineOctets = 998
+
+// textEncodings holds the chosen encodings of the text parts.
+type textEncodings struct {
+	plain types.TransferEncoding
+	html  types.TransferEncoding
+}
+
+// bodyType returns the SMTP BODY= value the encodings require.
+func (e textEncodings) bodyType() string {
+	switch {
+	case e.plain == types.TransferBinary || e.html == types.TransferBinary:
+		return BodyBinaryMIME
+	case e.plain == types.Transfer8Bit || e.html == types.Transfer8Bit:
+		return Body8BitMIME
+	}
+	return ""
+}
+
+// multipartCTE returns the Content-Transfer-Encoding a multipart entity
+// containing the text parts must declare, or "" for 7bit.
+func (e textEncodings) multipartCTE() string {
+	switch e.bodyType() {
+	case BodyBinaryMIME:
+		return string(types.TransferBinary)
+	case Body8BitMIME:
+		return string(types.Transfer8Bit)
+	}
+	return ""
+}
+
+// chooseEncodings picks the encodings of msg's text parts.
+func chooseEncodings(
+	msg types.Message,
+	opts BuildOptions,
+) (textEncodings, error) {
+	var e textEncodings
+	var err error
+	if e.plain, err = chooseEncoding(msg.Plain, msg.TextEncoding, opts); err != nil {
+		return e, err
+	}
+	if e.html, err = chooseEncoding(msg.HTML, msg.TextEncoding, opts); err != nil {
+		return e, err
+	}
+	return e, nil
+}
+
+// chooseEncoding returns want if it can carry b. Otherwise it picks
+// 7bit for 7-bit clean text in a non-UTF-8 charset (ISO-2022-JP is
+// expected unescaped), binary or 8bit when the options allow and the
+// text needs them, base64
+// when more than half the bytes would need escaping (e.g. CJK), and
+// quoted-printable for everything else.
+func chooseEncoding(
+	b []byte,
+	want types.TransferEncoding,
+	opts BuildOptions,
+) (types.TransferEncoding, error) {
+	binary := needsBinary(b)
+	switch want {
+	case types.TransferQuotedPrintable, types.TransferBase64,
+		types.TransferBinary:
+		return want, nil
+	case types.Transfer8Bit:
+		if binary {
+			return "", fmt.Errorf(
+				"email: 8bit text has NUL bytes or lines over %d octets",
+				maxLineOctets)
+		}
+		return want, nil
+	case types.Transfer7Bit:
+		if binary || !is7Bit(b) {
+			return "", fmt.Errorf("email: text is not 7-bit clean")
+		}
+		return want, nil
+	case types.TransferAuto:
+	default:
+		return "", fmt.Errorf("email: unknown transfer encoding %q", want)
+	}
+
+	if binary && opts.AllowBinary {
+		return types.TransferBinary, nil
+	}
+	if !binary && !opts.isUTF8() && is7Bit(b) {
+		return types.Transfer7Bit, nil
+	}
+	esc, nonASCII := 0, false
+	for _, c := range b {
+		switch {
+		case c > 126:
+			esc++
+			nonASCII = true
+		case c == '=' || c < 32 && c != '\n' && c != '\r' && c != '\t':
+			esc++
+		}
+	}
+	if nonASCII && !binary && opts.Allow8Bit && utf8.Valid(b) {
+		return types.Transfer8Bit, nil
+	}
+	// Text that is mostly escapes (CJK and similar) is smaller and more
+	// robust as base64; accented Latin text stays readable as QP.
+	if esc*2 > len(b) {
+		return types.TransferBase64, nil
+	}
+	return types.TransferQuotedPrintable, nil
+}
+
+// needsBinary reports whether b has NUL bytes or lines too long for 8bit.
+func needsBinary(b []byte) bool {
+	return bytes.IndexByte(b, 0) != -1 || hasLongLine(b)
+}
+
+// hasLongLine reports whether b has a line over maxLineOctets.
+func hasLongLine(b []byte) bool {
+	for len(b) > 0 {
+		i := bytes.IndexAny(b, "\r\n")
+		if i == -1 {
+			i = len(b)
+		}
+		if i > maxLineOctets {
+			return true
+		}
+		b = b[min(i+1, len(b)):]
+	}
+	return false
+}
+
+// is7Bit reports whether b only has bytes below 128.
+func is7Bit(b []byte) bool {
+	for _, c := range b {
+		if c > 127 {
+			return false
+		}
+	}
+	return true
+}
+
+// writeText writes a text part body in the given transfer encoding.
+// Line endings are normalized to CRLF except for binary, which is
+// written unchanged.
+func writeText(w io.Writer, b []byte, enc types.TransferEncoding) {
+	switch enc {
+	case types.TransferBase64:
+		bw := base64.NewEncoder(base64.StdEncoding, newCRLFWriter(w, 76))
+		_, _ = bw.Write(normalizeCRLF(b))
+		_ = bw.Close()
+	case types.Transfer7Bit, types.Transfer8Bit:
+		_, _ = w.Write(normalizeCRLF(b))
+	case types.TransferBinary:
+		_, _ = w.Write(b)
+	default:
+		writeQuotedPrintable(w, b)
+		return
+	}
+	_, _ = w.Write([]byte("\r\n"))
+}
+
+// normalizeCRLF converts LF, CR and CRLF line endings to CRLF.
+func normalizeCRLF(b []byte) []byte {
+	out := make([]byte, 0, len(b)+len(b)/32)
+	for i := 0; i < len(b); i++ {
+		switch b[i] {
+		case '\r':
+			if i+1 < len(b) && b[i+1] == '\n' {
+				i++
+			}
+			out = append(out, '\r', '\n')
+		case '\n':
+			out = append(out, '\r', '\n')
+		default:
+			out = append(out, b[i])
+		}
+	}
+	return out
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestChooseEncoding(t *testing.T) {
+	long := strings.Repeat("x", 1000)
+	tests := []struct {
+		name string
+		body string
+		want types.TransferEncoding
+		opts BuildOptions
+		enc  types.TransferEncoding
+	}{
+		{"ascii", "hello", "", BuildOptions{}, types.TransferQuotedPrintable},
+		{"latin", "Grüße aus Köln", "", BuildOptions{}, types.TransferQuotedPrintable},
+		{"cjk", "日本語のメールです", "", BuildOptions{}, types.TransferBase64},
+		{"8bit allowed", "Grüße", "", BuildOptions{Allow8Bit: true}, types.Transfer8Bit},
+		{"8bit ascii stays qp", "hello", "", BuildOptions{Allow8Bit: true}, types.TransferQuotedPrintable},
+		{"8bit invalid utf8", "\xff\xfe", "", BuildOptions{Allow8Bit: true}, types.TransferBase64},
+		{"long line", long, "", BuildOptions{Allow8Bit: true}, types.TransferQuotedPrintable},
+		{"binary allowed", long, "", BuildOptions{AllowBinary: true}, types.TransferBinary},
+		{"nul", "a\x00b", "", BuildOptions{AllowBinary: true}, types.TransferBinary},
+		{"override base64", "hello", types.TransferBase64, BuildOptions{}, types.TransferBase64},
+		{"override 8bit", "hello", types.Transfer8Bit, BuildOptions{}, types.Transfer8Bit},
+	}
+	for _, tc := range tests {
+		got, err := chooseEncoding([]byte(tc.body), tc.want, tc.opts)
+		if err != nil || got != tc.enc {
+			t.Errorf("%s: got %q, %v; want %q", tc.name, got, err, tc.enc)
+		}
+	}
+	if _, err := chooseEncoding([]byte(long), types.Transfer8Bit, BuildOptions{}); err == nil {
+		t.Errorf("expected 8bit with long line to fail")
+	}
+	if _, err := chooseEncoding([]byte("ü"), types.Transfer7Bit, BuildOptions{}); err == nil {
+		t.Errorf("expected 7bit with non-ASCII to fail")
+	}
+	if _, err := chooseEncoding([]byte("x"), "uuencode", BuildOptions{}); err == nil {
+		t.Errorf("expected unknown encoding to fail")
+	}
+}
+
+func TestWriteTextBase64(t *testing.T) {
+	var buf bytes.Buffer
+	writeText(&buf, []byte(strings.Repeat("日本語\n", 20)), types.TransferBase64)
+	for _, l := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(l) > 76 {
+			t.Fatalf("line too long: %q", l)
+		}
+	}
+	dec, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding,
+		strings.NewReader(strings.ReplaceAll(buf.String(), "\r\n", ""))))
+	if err != nil || string(dec) != strings.Repeat("日本語\r\n", 20) {
+		t.Fatalf("round-trip: %q %v", dec, err)
+	}
+}
+
+func TestBuildTransferEncodings(t *testing.T) {
+	msg := types.Message{
+		From:  types.Address{Mail: "no-reply@example.com"},
+		To:    []types.Address{{Mail: "to@example.com"}},
+		Plain: []byte("Grüße\n"),
+		HTML:  []byte("<p>日本語のメールです</p>"),
+	}
+	b, err := Build(context.Background(), msg, BuildOptions{})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if b.BodyType != "" {
+		t.Fatalf("unexpected body type %q", b.BodyType)
+	}
+	parts := readParts(t, b.Raw)
+	if parts[0] != "quoted-printable" || parts[1] != "base64" {
+		t.Fatalf("unexpected part encodings: %v", parts)
+	}
+
+	b, err = Build(context.Background(), msg, BuildOptions{Allow8Bit: true})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if b.BodyType != Body8BitMIME ||
+		!strings.Contains(string(b.Raw), "Content-Transfer-Encoding: 8bit\r\n\r\n--") ||
+		!strings.Contains(string(b.Raw), "Grüße\r\n") {
+		t.Fatalf("expected 8bit body:\n%s", b.Raw)
+	}
+
+	msg.TextEncoding = types.TransferBase64
+	b, err = Build(context.Background(), msg, BuildOptions{Allow8Bit: true})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if parts := readParts(t, b.Raw); parts[0] != "base64" || parts[1] != "base64" {
+		t.Fatalf("override ignored: %v", parts)
+	}
+}
+
+// readParts returns the Content-Transfer-Encoding of each alternative.
+func readParts(t *testing.T, raw []byte) []string {
+	t.Helper()
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	_, params, _ := strings.Cut(m.Header.Get("Content-Type"), "boundary=")
+	r := multipart.NewReader(m.Body, strings.Trim(params, `"`))
+	var out []string
+	for {
+		p, err := r.NextRawPart()
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatalf("part: %v", err)
+		}
+		out = append(out, p.Header.Get("Content-Transfer-Encoding"))
+	}
+}
@@ -0,0 +1,217 @@
+package email
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// Severity ranks lint findings.
+type Severity int
+
+// Severity levels.
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+// String returns the lowercase severity name.
+//
+// Returns:
+//   - string: The severity name.
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// Lint finding codes.
+const (
+	LintMissingPlain     = "missing-plain"
+	LintMissingListUnsub = "missing-list-unsubscribe"
+	LintImageOnlyHTML    = "image-only-html"
+	LintLargeInlineImage = "large-inline-image"
+	LintSubjectSpammy    = "subject-spam-trigger"
+	LintDKIMMisaligned   = "dkim-misaligned"
+
+	LintReturnPathMisaligned = "return-path-misaligned"
+)
+
+// Finding is a single deliverability problem reported by Lint.
+type Finding struct {
+	Code     string
+	Severity Severity
+	Message  string
+}
+
+// String renders the finding as "severity code: message".
+//
+// Returns:
+//   - string: The rendered finding.
+func (f Finding) String() string {
+	return fmt.Sprintf("%s %s: %s", f.Severity, f.Code, f.Message)
+}
+
+// LintMaxInlineImage is the inline image size above which Lint warns.
+const LintMaxInlineImage = 100 << 10
+
+// bulkRecipientThreshold is the recipient count from which a message is
+// treated as bulk even without Precedence/List-Id headers.
+const bulkRecipientThreshold = 50
+
+var (
+	imgTagRe = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+	tagRe    = regexp.MustCompile(`(?s)<[^>]*>`)
+	styleRe  = regexp.MustCompile(`(?is)<(style|script)\b.*?</(style|script)>`)
+
+	spamSubjectRes = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\bfree\b.*!`),
+		regexp.MustCompile(`(?i)\b100% (free|guaranteed)\b`),
+		regexp.MustCompile(`(?i)\b(act now|click here|winner|risk[- ]free)\b`),
+		regexp.MustCompile(`(?i)\b(cash|money) (bonus|back)\b`),
+		regexp.MustCompile(`!{3,}|\${2,}|\?{3,}`),
+	}
+)
+
+// Lint inspects msg for common deliverability problems. Options supply
+// send-time context such as WithListUnsubscribe and WithDKIM. Lint does
+// not build or send the message and never mutates msg.
+//
+// Parameters:
+//   - msg: The message to inspect.
+//   - opts: The send options that will be used.
+//
+// Returns:
+//   - []Finding: The findings, empty if none.
+func Lint(msg types.Message, opts ...Option) []Finding {
+	var cfg SendConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	var out []Finding
+	add := func(code string, sev Severity, format string, args ...any) {
+		out = append(out, Finding{
+			Code: code, Severity: sev, Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if len(msg.HTML) > 0 && len(msg.Plain) == 0 {
+		add(LintMissingPlain, SeverityWarning,
+			"HTML body has no plain-text alternative")
+	}
+
+	if isBulk(&msg) && cfg.ListUnsub == "" &&
+		msg.Headers.Get("List-Unsubscribe") == "" {
+		add(LintMissingListUnsub, SeverityError,
+			"bulk mail without List-Unsubscribe header")
+	}
+
+	if len(msg.HTML) > 0 {
+		html := string(msg.HTML)
+		imgs := len(imgTagRe.FindAllString(html, -1))
+		text := strings.TrimSpace(tagRe.ReplaceAllString(
+			styleRe.ReplaceAllString(html, ""), " "))
+		if imgs > 0 && len(strings.Fields(text)) < 10 {
+			add(LintImageOnlyHTML, SeverityWarning,
+				"HTML body is mostly images (%d img, little text)", imgs)
+		}
+	}
+
+	for _, a := range msg.Attach {
+		if a.ContentID == "" {
+			continue
+		}
+		if n, ok := readerLen(a.Reader); ok && n > LintMaxInlineImage {
+			add(LintLargeInlineImage, SeverityWarning,
+				"inline image %q is %d bytes", a.Filename, n)
+		}
+	}
+
+	if msg.Subject != "" {
+		if isShouting(msg.Subject) {
+			add(LintSubjectSpammy, SeverityWarning,
+				"subject is mostly uppercase")
+		}
+		for _, re := range spamSubjectRes {
+			if re.MatchString(msg.Subject) {
+				add(LintSubjectSpammy, SeverityWarning,
+					"subject matches spam trigger %q", re.String())
+				break
+			}
+		}
+	}
+
+	if cfg.DKIM != nil && cfg.DKIM.Domain != "" {
+		from := domainOf(msg.From.Mail)
+		if !internal.DomainsAligned(from, cfg.DKIM.Domain) {
+			add(LintDKIMMisaligned, SeverityError,
+				"From domain %q is not aligned with DKIM d=%s",
+				from, cfg.DKIM.Domain)
+		}
+	}
+
+	// A misaligned Return-Path only fails DMARC without aligned DKIM.
+	if env, from := returnPathDomains(msg, &cfg); env != "" && !internal.DomainsAligned(env, from) {
+		if cfg.DKIM != nil && internal.DomainsAligned(from, cfg.DKIM.Domain) {
+			add(LintReturnPathMisaligned, SeverityWarning,
+				"envelope sender domain %q is not aligned with From domain %q; "+
+					"DMARC relies on DKIM d=%s alone", env, from, cfg.DKIM.Domain)
+		} else {
+			add(LintReturnPathMisaligned, SeverityError,
+				"envelope sender domain %q is not aligned with From domain %q "+
+					"and there is no aligned DKIM signature; DMARC will fail", env, from)
+		}
+	}
+	return out
+}
+
+// isBulk guesses whether msg is bulk mail from its headers or audience.
+func isBulk(m *types.Message) bool {
+	p := strings.ToLower(m.Headers.Get("Precedence"))
+	if p == "bulk" || p == "list" || m.Headers.Get("List-Id") != "" {
+		return true
+	}
+	return len(m.EnvelopeRecipients()) >= bulkRecipientThreshold
+}
+
+// isShouting reports whether most letters in s are uppercase.
+func isShouting(s string) bool {
+	var upper, letters int
+	for _, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			upper++
+			letters++
+		} else if r >= 'a' && r <= 'z' {
+			letters++
+		}
+	}
+	return letters >= 8 && upper*10 >= letters*8
+}
+
+// readerLen returns the remaining length of common in-memory readers.
+func readerLen(r any) (int64, bool) {
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		return int64(v.Len()), true
+	case interface{ Size() int64 }:
+		return v.Size(), true
+	}
+	return 0, false
+}
+
+// domainOf returns the lowercased domain of an address.
+func domainOf(addr string) string {
+	if i := strings.LastIndex(addr, "@"); i != -1 {
+		return strings.ToLower(strings.TrimSpace(addr[i+1:]))
+	}
+	return ""
+}
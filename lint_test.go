@@ -0,0 +1,59 @@
+package email
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+func lintCodes(fs []Finding) map[string]bool {
+	out := map[string]bool{}
+	for _, f := range fs {
+		out[f.Code] = true
+	}
+	return out
+}
+
+func TestLintCleanMessage(t *testing.T) {
+	msg := types.Message{
+		From:    types.Address{Mail: "news@mail.example.com"},
+		To:      []types.Address{{Mail: "ada@example.org"}},
+		Subject: "Your weekly summary",
+		Plain:   []byte("Hello"),
+		HTML:    []byte("<p>Hello, here is your weekly summary of activity in your account.</p>"),
+	}
+	fs := Lint(msg, WithDKIM(types.DKIMConfig{Domain: "example.com"}))
+	if len(fs) != 0 {
+		t.Fatalf("expected no findings, got %v", fs)
+	}
+}
+
+func TestLintFindings(t *testing.T) {
+	msg := types.Message{
+		From:    types.Address{Mail: "news@example.com"},
+		To:      []types.Address{{Mail: "ada@example.org"}},
+		Subject: "FREE MONEY FOR YOU NOW!!!",
+		HTML:    []byte(`<a href="x"><img src="cid:banner"></a>`),
+		Headers: types.Headers{{Name: "Precedence", Value: "bulk"}},
+		Attach: []types.Attachment{{
+			Filename:  "banner.png",
+			ContentID: "banner",
+			Reader:    bytes.NewReader(make([]byte, LintMaxInlineImage+1)),
+		}},
+	}
+	codes := lintCodes(Lint(msg, WithDKIM(types.DKIMConfig{Domain: "other.net"})))
+	for _, c := range []string{
+		LintMissingPlain, LintMissingListUnsub, LintImageOnlyHTML,
+		LintLargeInlineImage, LintSubjectSpammy, LintDKIMMisaligned,
+	} {
+		if !codes[c] {
+			t.Fatalf("expected finding %s, got %v", c, codes)
+		}
+	}
+
+	codes = lintCodes(Lint(msg, WithListUnsubscribe("<mailto:u@example.com>")))
+	if codes[LintMissingListUnsub] {
+		t.Fatalf("List-Unsubscribe option should satisfy bulk check")
+	}
+}
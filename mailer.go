@@ -9,6 +9,11 @@ import (
 // Mailer defines the interface for email sending adapters.
 // Implementations should handle connection management, authentication,
 // and delivery according to their specific protocol (SMTP, API, etc.).
+//
+// Send must not modify msg or anything it references, so the same
+// message can be sent concurrently. The adapters in this module work on
+// a clone (see types.Message.Clone); attachment readers that cannot
+// start over are still read, so such a message can be sent only once.
 type Mailer interface {
 	// Send sends an email message with the given options.
 	//
@@ -23,3 +28,66 @@ type Mailer interface {
 	//     or times out.
 	Send(ctx context.Context, msg types.Message, opts ...Option) error
 }
+
+// SendResult describes a completed send. Pass a pointer via WithResult
+// to have adapters fill it in.
+type SendResult struct {
+	MessageID string // Message-ID header of the sent message
+	Size      int    // size of the built message in bytes
+	Attempts  int    // number of delivery attempts made
+	Response  string // final server reply, e.g. "250 2.0.0 Ok: queued"
+
+	// Queued is set when a transient failure left the message stored
+	// for later redelivery, as by smtp.RetryScheduler.
+	Queued bool
+
+	// ProviderMessageID is the ID an HTTP API provider assigned to the
+	// message, for matching its webhooks and logs.
+	ProviderMessageID string
+
+	// AttachmentSizes holds the encoded size of each attachment in
+	// Message.Attach order.
+	AttachmentSizes []int64
+
+	// SentCopyErr is the error storing the sent copy (WithSentCopy),
+	// which does not fail the send.
+	SentCopyErr error
+
+	// ArchiveErr is the error sending the archive copy
+	// (WithArchiveCopy with ArchiveBestEffort), which does not fail the
+	// send.
+	ArchiveErr error
+
+	// Recipients holds one entry per envelope recipient for sends
+	// with WithFanOut and for adapters that report them, like Mailjet
+	// or SMTP in LMTP mode.
+	Recipients []RecipientResult
+
+	// LinkedAttachments lists the attachments replaced by download
+	// links after the message was rejected as too large
+	// (WithOversizeFallback).
+	LinkedAttachments []LinkedAttachment
+
+	// Fallbacks lists why features were dropped for this server, e.g.
+	// an 8bit body rebuilt because 8BITMIME was not advertised.
+	Fallbacks []string
+
+	// Spam is the spam filter verdict (WithSpamCheck), also set when
+	// the message was blocked.
+	Spam *SpamReport
+
+	// Warnings lists the problems the MIME builder worked around, e.g.
+	// a custom header it ignored; see types.BuildWarning.
+	Warnings []types.BuildWarning
+}
+
+// RecipientResult is the outcome of a send to one recipient.
+type RecipientResult struct {
+	Recipient    string
+	EnvelopeFrom string // MAIL FROM used, e.g. the VERP address
+	MessageID    string
+	Response     string // final server reply on success
+
+	ProviderMessageID string // ID assigned by an HTTP API provider
+	Err               error  // nil on success
+}
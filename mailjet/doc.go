@@ -0,0 +1,3 @@
+// Package mailjet is a mailer adapter for the Mailjet Send API v3.1. It
+// implements the Mailer interface.
+package mailjet
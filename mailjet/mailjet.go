@@ -0,0 +1,347 @@
+package mailjet
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// DefaultBaseURL is the Mailjet API endpoint.
+const DefaultBaseURL = "https://api.mailjet.com"
+
+// MailjetConfig configures the Mailjet mailer.
+type MailjetConfig struct {
+	APIKey    string
+	SecretKey string
+
+	// SandboxMode validates messages without delivering them.
+	SandboxMode bool
+
+	BaseURL string       // defaults to DefaultBaseURL
+	Client  *http.Client // defaults to a client with Timeout
+	Timeout time.Duration
+}
+
+// Mailjet implements the Mailer interface over the Mailjet Send API
+// v3.1.
+type Mailjet struct {
+	cfg    MailjetConfig
+	client *http.Client
+}
+
+// NewMailjet creates a new Mailjet mailer.
+//
+// Parameters:
+//   - cfg: The Mailjet config.
+//
+// Returns:
+//   - *Mailjet: The Mailjet mailer.
+func NewMailjet(cfg MailjetConfig) *Mailjet {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	client := cfg.Client
+	if client == nil {
+		t := cfg.Timeout
+		if t <= 0 {
+			t = 30 * time.Second
+		}
+		client = &http.Client{Timeout: t}
+	}
+	return &Mailjet{cfg: cfg, client: client}
+}
+
+// Send sends an email. Mailjet assigns a message ID per recipient:
+// they are reported in SendResult.Recipients, and the first one in
+// SendResult.ProviderMessageID. Message.TrackingID is sent as the
+// Mailjet CustomID, which is echoed in event webhooks. Mailjet builds
+// and signs the message, so options of the MIME build (WithDKIM,
+// WithCharset, WithMessageIDDomain, WithMaxMessageSize, ...) are
+// ignored.
+//
+// Parameters:
+//   - ctx: The context.
+//   - msg: The message.
+//   - opts: The options.
+//
+// Returns:
+//   - error: The error if the email fails to send.
+func (m *Mailjet) Send(
+	ctx context.Context,
+	msg types.Message,
+	opts ...email.Option,
+) error {
+	var cfg email.SendConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if cfg.FanOut != nil {
+		return errors.New("mailjet: WithFanOut is not supported")
+	}
+	if len(msg.EnvelopeTo) > 0 {
+		return errors.New("mailjet: Message.EnvelopeTo is not supported")
+	}
+	msg, err := email.ExpandRecipients(ctx, msg, opts...)
+	if err != nil {
+		return err
+	}
+	if err := email.CheckPolicy(ctx, msg, opts...); err != nil {
+		return err
+	}
+	if cfg.ArchiveCopy != "" {
+		// The API takes no envelope; a Bcc is delivered but not shown.
+		msg.Bcc = append(slices.Clip(msg.Bcc), types.Address{Mail: cfg.ArchiveCopy})
+	}
+	if cfg.AddrCheck != nil {
+		for _, rcpt := range msg.RecipientList() {
+			if err := cfg.AddrCheck.CheckAddress(ctx, rcpt); err != nil {
+				return err
+			}
+		}
+	}
+	if cfg.InlineImages != nil {
+		var err error
+		if msg, err = email.InlineRemoteImages(ctx, msg, *cfg.InlineImages); err != nil {
+			return err
+		}
+	}
+	if cfg.AttachmentLinks != nil {
+		var err error
+		if msg, _, err = cfg.AttachmentLinks.Apply(ctx, msg); err != nil {
+			return err
+		}
+	}
+	if cfg.Rate != nil {
+		if err := cfg.Rate.Acquire(ctx); err != nil {
+			return err
+		}
+	}
+
+	content, err := internal.PrepareContent(ctx, msg, internal.SendBuildOptions(&cfg), false)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(request{
+		Messages:    []message{newMessage(msg, content)},
+		SandboxMode: m.cfg.SandboxMode,
+	})
+	if err != nil {
+		return err
+	}
+	if cfg.MaxMessageSize > 0 && int64(len(body)) > cfg.MaxMessageSize {
+		return &types.SizeError{
+			Size: int64(len(body)), Limit: cfg.MaxMessageSize,
+		}
+	}
+
+	res := cfg.Result
+	if res == nil {
+		res = &email.SendResult{}
+	}
+	*res = email.SendResult{
+		MessageID:       msg.Headers.Get("Message-ID"),
+		Size:            len(body),
+		AttachmentSizes: content.Sizes(),
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Basic "+basicAuth(m.cfg.APIKey, m.cfg.SecretKey))
+	url := m.cfg.BaseURL + "/v3.1/send"
+	res.Attempts, err = internal.Retry(ctx, cfg.Backoff, cfg.Hooks,
+		internal.IsTransientAPI, func(ctx context.Context) error {
+			status, data, err := internal.PostJSON(ctx, m.client, url,
+				header, body)
+			if err != nil {
+				return err
+			}
+			var r response
+			if err := json.Unmarshal(data, &r); err != nil && status == http.StatusOK {
+				return fmt.Errorf("mailjet: decode response: %w", err)
+			}
+			if err := r.err(status); err != nil {
+				return err
+			}
+			res.Response = r.Messages[0].Status
+			res.Recipients = r.Messages[0].recipients()
+			if len(res.Recipients) > 0 {
+				res.ProviderMessageID = res.Recipients[0].ProviderMessageID
+			}
+			return nil
+		})
+	if cfg.MessageIDOut != nil {
+		*cfg.MessageIDOut = res.MessageID
+	}
+	return err
+}
+
+// request is the JSON body of /v3.1/send.
+type request struct {
+	Messages    []message `json:"Messages"`
+	SandboxMode bool      `json:"SandboxMode,omitempty"`
+}
+
+type message struct {
+	From               address           `json:"From"`
+	To                 []address         `json:"To,omitempty"`
+	Cc                 []address         `json:"Cc,omitempty"`
+	Bcc                []address         `json:"Bcc,omitempty"`
+	ReplyTo            *address          `json:"ReplyTo,omitempty"`
+	Subject            string            `json:"Subject"`
+	TextPart           string            `json:"TextPart,omitempty"`
+	HTMLPart           string            `json:"HTMLPart,omitempty"`
+	Headers            map[string]string `json:"Headers,omitempty"`
+	Attachments        []attachment      `json:"Attachments,omitempty"`
+	InlinedAttachments []attachment      `json:"InlinedAttachments,omitempty"`
+	CustomID           string            `json:"CustomID,omitempty"`
+}
+
+type address struct {
+	Email string `json:"Email"`
+	Name  string `json:"Name,omitempty"`
+}
+
+type attachment struct {
+	ContentType   string `json:"ContentType"`
+	Filename      string `json:"Filename"`
+	ContentID     string `json:"ContentID,omitempty"`
+	Base64Content string `json:"Base64Content"`
+}
+
+// response is the JSON reply. Request level errors use the top-level
+// fields; message level errors are listed per message.
+type response struct {
+	Messages []messageResult `json:"Messages"`
+
+	ErrorIdentifier string `json:"ErrorIdentifier"`
+	ErrorCode       string `json:"ErrorCode"`
+	ErrorMessage    string `json:"ErrorMessage"`
+}
+
+type messageResult struct {
+	Status string `json:"Status"`
+	Errors []struct {
+		ErrorCode      string   `json:"ErrorCode"`
+		ErrorMessage   string   `json:"ErrorMessage"`
+		ErrorRelatedTo []string `json:"ErrorRelatedTo"`
+	} `json:"Errors"`
+	To  []sentTo `json:"To"`
+	Cc  []sentTo `json:"Cc"`
+	Bcc []sentTo `json:"Bcc"`
+}
+
+type sentTo struct {
+	Email       string `json:"Email"`
+	MessageUUID string `json:"MessageUUID"`
+	MessageID   int64  `json:"MessageID"`
+}
+
+// err returns the error the response reports, if any.
+func (r *response) err(status int) error {
+	apiErr := &types.APIError{Provider: "mailjet", StatusCode: status}
+	switch {
+	case len(r.Messages) > 0 && r.Messages[0].Status != "success":
+		if errs := r.Messages[0].Errors; len(errs) > 0 {
+			apiErr.Code, apiErr.Message = errs[0].ErrorCode, errs[0].ErrorMessage
+			if len(errs[0].ErrorRelatedTo) > 0 {
+				apiErr.Message += " (" +
+					strings.Join(errs[0].ErrorRelatedTo, ", ") + ")"
+			}
+		}
+	case status != http.StatusOK:
+		apiErr.Code, apiErr.Message = r.ErrorCode, r.ErrorMessage
+	case len(r.Messages) == 0:
+		apiErr.Message = "no message status in response"
+	default:
+		return nil
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(status)
+	}
+	return apiErr
+}
+
+// recipients returns the outcome for each recipient in envelope order.
+func (r *messageResult) recipients() []email.RecipientResult {
+	var out []email.RecipientResult
+	for _, list := range [][]sentTo{r.To, r.Cc, r.Bcc} {
+		for _, s := range list {
+			out = append(out, email.RecipientResult{
+				Recipient:         s.Email,
+				Response:          r.Status,
+				ProviderMessageID: strconv.FormatInt(s.MessageID, 10),
+			})
+		}
+	}
+	return out
+}
+
+// newMessage maps msg and its prepared content to a message.
+func newMessage(msg types.Message, c *internal.Content) message {
+	m := message{
+		From:     newAddress(msg.From),
+		To:       newAddresses(msg.To),
+		Cc:       newAddresses(msg.Cc),
+		Bcc:      newAddresses(msg.Bcc),
+		Subject:  msg.Subject,
+		TextPart: string(c.Plain),
+		HTMLPart: string(c.HTML),
+		CustomID: msg.TrackingID,
+	}
+	if len(msg.ReplyTo) > 0 {
+		a := newAddress(msg.ReplyTo[0])
+		m.ReplyTo = &a
+	}
+	// Headers are a JSON object, so only the first of repeated fields
+	// is kept.
+	for _, f := range c.Headers {
+		if _, ok := m.Headers[f.Name]; ok {
+			continue
+		}
+		if m.Headers == nil {
+			m.Headers = map[string]string{}
+		}
+		m.Headers[f.Name] = f.Value
+	}
+	for _, a := range c.Attach {
+		ma := attachment{
+			ContentType: a.ContentType, Filename: a.Filename,
+			ContentID: a.ContentID, Base64Content: a.Base64,
+		}
+		if a.ContentID != "" {
+			m.InlinedAttachments = append(m.InlinedAttachments, ma)
+		} else {
+			m.Attachments = append(m.Attachments, ma)
+		}
+	}
+	return m
+}
+
+func newAddress(a types.Address) address {
+	return address{Email: strings.TrimSpace(a.Mail), Name: a.Name}
+}
+
+func newAddresses(xs []types.Address) []address {
+	var out []address
+	for _, a := range xs {
+		if a.Mail != "" {
+			out = append(out, newAddress(a))
+		}
+	}
+	return out
+}
+
+// basicAuth returns the base64 user:password credentials.
+func basicAuth(user, pass string) string {
+	return base64.StdEncoding.EncodeToString([]byte(user + ":" + pass))
+}
@@ -0,0 +1,114 @@
+package mailjet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
+
+var _ email.Mailer = (*Mailjet)(nil)
+
+func TestSend(t *testing.T) {
+	var got request
+	var path string
+	var user, pass string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		user, pass, _ = r.BasicAuth()
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &got)
+		w.Write([]byte(`{"Messages":[{"Status":"success","CustomID":"order-1",` +
+			`"To":[{"Email":"ada@example.org","MessageUUID":"u1","MessageID":1152921504},` +
+			`{"Email":"bob@example.org","MessageUUID":"u2","MessageID":1152921505}],` +
+			`"Cc":[],"Bcc":[{"Email":"audit@example.com","MessageUUID":"u3","MessageID":1152921506}]}]}`))
+	}))
+	defer srv.Close()
+
+	m := NewMailjet(MailjetConfig{APIKey: "key", SecretKey: "secret", BaseURL: srv.URL})
+	msg := types.Message{
+		From:       types.Address{Name: "News", Mail: "news@example.com"},
+		To:         []types.Address{{Name: "Ada", Mail: "ada@example.org"}, {Mail: "bob@example.org"}},
+		Bcc:        []types.Address{{Mail: "audit@example.com"}},
+		ReplyTo:    []types.Address{{Mail: "support@example.com"}},
+		Subject:    "Hello",
+		HTML:       []byte(`<p>hi <img src="cid:logo"></p>`),
+		TrackingID: "order-1",
+		Headers:    types.Headers{{Name: "X-Campaign", Value: "spring"}},
+		Attach: []types.Attachment{
+			{Filename: "a.txt", Reader: strings.NewReader("attached")},
+			{Filename: "logo.png", ContentID: "logo", Reader: strings.NewReader("png")},
+		},
+	}
+	var res email.SendResult
+	if err := m.Send(context.Background(), msg, email.WithResult(&res)); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if path != "/v3.1/send" || user != "key" || pass != "secret" || len(got.Messages) != 1 {
+		t.Fatalf("unexpected request %s %s:%s %+v", path, user, pass, got)
+	}
+	g := got.Messages[0]
+	if g.From.Email != "news@example.com" || g.From.Name != "News" || len(g.To) != 2 ||
+		g.To[0].Name != "Ada" || g.Bcc[0].Email != "audit@example.com" ||
+		g.ReplyTo == nil || g.ReplyTo.Email != "support@example.com" ||
+		g.CustomID != "order-1" || g.Headers["X-Campaign"] != "spring" ||
+		g.Headers["X-Tracking-ID"] != "order-1" || g.HTMLPart == "" || g.TextPart != "" {
+		t.Fatalf("unexpected message: %+v", g)
+	}
+	if len(g.Attachments) != 1 || g.Attachments[0].Base64Content != "YXR0YWNoZWQ=" ||
+		len(g.InlinedAttachments) != 1 || g.InlinedAttachments[0].ContentID != "logo" ||
+		g.InlinedAttachments[0].ContentType != "image/png" {
+		t.Fatalf("unexpected attachments: %+v %+v", g.Attachments, g.InlinedAttachments)
+	}
+	if res.ProviderMessageID != "1152921504" || res.Response != "success" ||
+		len(res.Recipients) != 3 || res.Recipients[2].Recipient != "audit@example.com" ||
+		res.Recipients[2].ProviderMessageID != "1152921506" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	reply := `{"Messages":[{"Status":"error","Errors":[{"ErrorIdentifier":"x",` +
+		`"ErrorCode":"mj-0013","StatusCode":400,"ErrorMessage":"\"bad\" is an invalid email address.",` +
+		`"ErrorRelatedTo":["To[0].Email"]}]}]}`
+	status := http.StatusBadRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(reply))
+	}))
+	defer srv.Close()
+
+	m := NewMailjet(MailjetConfig{APIKey: "key", SecretKey: "secret", BaseURL: srv.URL})
+	msg := types.Message{
+		From:  types.Address{Mail: "news@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("hi"),
+	}
+	err := m.Send(context.Background(), msg)
+	var apiErr *types.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "mj-0013" ||
+		!strings.Contains(apiErr.Message, "(To[0].Email)") || apiErr.Temporary() {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status = http.StatusUnauthorized
+	reply = `{"ErrorIdentifier":"y","ErrorCode":"mj-0002","StatusCode":401,"ErrorMessage":"API key authentication/authorization failure."}`
+	err = m.Send(context.Background(), msg)
+	if !errors.As(err, &apiErr) || apiErr.Code != "mj-0002" || apiErr.StatusCode != 401 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status = http.StatusBadGateway
+	reply = `<html>bad gateway</html>`
+	err = m.Send(context.Background(), msg)
+	if !errors.As(err, &apiErr) || !apiErr.Temporary() || apiErr.Message != "Bad Gateway" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -1,11 +1,12 @@
 package email
 
 import (
-	"crypto/rand"
+	"context"
 	"math"
-	mrand "math/rand"
 	"time"
 
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/sanitize"
 	"github.com/aatuh/email/v2/types"
 )
 
@@ -16,10 +17,98 @@ type Option func(*SendConfig)
 type SendConfig struct {
 	ListUnsub string
 	Backoff   Backoff
-	Rate      *TokenBucket
+	Rate      RateLimiter
 	Pool      *ConnPool
 	Hooks     *types.Hooks
 	DKIM      *types.DKIMConfig
+
+	// MaxMessageSize caps the encoded message size in bytes (0 = none).
+	MaxMessageSize int64
+	// MaxAttachmentSize caps each encoded attachment in bytes (0 = none).
+	MaxAttachmentSize int64
+
+	AddrCheck    AddressChecker
+	BIMISelector string
+	EnvelopeFrom string
+
+	MessageIDDomain    string
+	MessageIDGenerator types.MessageIDGenerator
+	MessageIDOut       *string
+	Result             *SendResult
+
+	Charset        string
+	CharsetEncoder types.CharsetEncoder
+
+	ReadReceiptTo string
+
+	AttachmentCache   *AttachmentCache
+	AttachmentPolicy  *types.AttachmentPolicy
+	AttachmentScanner types.AttachmentScanner
+
+	HTMLSanitizer *sanitize.Policy
+	Transforms    []types.BuildTransform
+	InlineImages  *InlineImageConfig
+	FanOut        *FanOut
+
+	MessageStream string
+
+	Priority Priority
+
+	SentCopier SentCopier
+
+	Oversize        *OversizeFallback
+	AttachmentLinks *AttachmentLinkPolicy
+
+	RequireReturnPathAlignment bool
+
+	ArchiveCopy string
+	ArchiveMode ArchiveMode
+
+	SpamCheck *SpamCheck
+
+	Policies []Policy
+
+	Rand RandSource
+
+	Recipients *RecipientExpansion
+}
+
+// ArchiveMode tells how a failed archive copy affects the send; see
+// WithArchiveCopy.
+type ArchiveMode int
+
+const (
+	// ArchiveBestEffort sends the archive copy in its own transaction
+	// after the message was delivered. A failure does not fail the
+	// send; it is reported in SendResult.ArchiveErr.
+	ArchiveBestEffort ArchiveMode = iota
+	// ArchiveRequired adds the archive address to the recipients of the
+	// message itself, so the send fails unless the archive accepts it.
+	ArchiveRequired
+)
+
+// FanOut configures per-recipient delivery; see WithFanOut.
+type FanOut struct {
+	// PersonalizeTo builds a copy per recipient whose To header names
+	// only that recipient and which has no Cc.
+	PersonalizeTo bool
+	// VERP, if set, is the bounce address each recipient is encoded
+	// into for its own MAIL FROM (see VERP), e.g.
+	// "bounces@example.com".
+	VERP string
+}
+
+// AddressChecker validates a recipient address before sending. The
+// validate package provides an implementation.
+type AddressChecker interface {
+	CheckAddress(ctx context.Context, addr string) error
+}
+
+// SentCopier stores a copy of each sent message, e.g. in the sender's
+// IMAP "Sent" folder. The imap package provides an implementation.
+type SentCopier interface {
+	// CopySent stores raw, the exact bytes that were sent.
+	CopySent(ctx context.Context, raw []byte) error
 }
 
 // WithListUnsubscribe sets the List-Unsubscribe header.
@@ -44,15 +133,16 @@ func WithRetry(b Backoff) Option {
 	return func(c *SendConfig) { c.Backoff = b }
 }
 
-// WithRateLimit attaches a token bucket for throttling.
+// WithRateLimit paces sends with a rate limiter, such as a
+// *TokenBucket or a *WarmupLimiter.
 //
 // Parameters:
-//   - bucket: The token bucket.
+//   - limiter: The rate limiter.
 //
 // Returns:
 //   - Option: The option.
-func WithRateLimit(bucket *TokenBucket) Option {
-	return func(c *SendConfig) { c.Rate = bucket }
+func WithRateLimit(limiter RateLimiter) Option {
+	return func(c *SendConfig) { c.Rate = limiter }
 }
 
 // WithPool sets a connection pool to reuse adapter connections.
@@ -88,6 +178,252 @@ func WithDKIM(cfg types.DKIMConfig) Option {
 	return func(c *SendConfig) { c.DKIM = &cfg }
 }
 
+// WithMaxMessageSize limits the size of the built message. Exceeding it
+// fails the build with a *types.SizeError.
+//
+// Parameters:
+//   - n: The maximum encoded message size in bytes.
+//
+// Returns:
+//   - Option: The option.
+func WithMaxMessageSize(n int64) Option {
+	return func(c *SendConfig) { c.MaxMessageSize = n }
+}
+
+// WithMaxAttachmentSize limits the encoded size of each attachment.
+// Exceeding it fails the build with a *types.SizeError naming the file.
+//
+// Parameters:
+//   - n: The maximum encoded attachment size in bytes.
+//
+// Returns:
+//   - Option: The option.
+func WithMaxAttachmentSize(n int64) Option {
+	return func(c *SendConfig) { c.MaxAttachmentSize = n }
+}
+
+// WithAddressCheck validates every envelope recipient before the message
+// is built. The first failing recipient aborts the send.
+//
+// Parameters:
+//   - ac: The address checker.
+//
+// Returns:
+//   - Option: The option.
+func WithAddressCheck(ac AddressChecker) Option {
+	return func(c *SendConfig) { c.AddrCheck = ac }
+}
+
+// WithEnvelopeFrom overrides the SMTP MAIL FROM address for this send,
+// taking precedence over Message.EnvelopeFrom. The From header is not
+// changed.
+//
+// Parameters:
+//   - addr: The envelope sender address.
+//
+// Returns:
+//   - Option: The option.
+func WithEnvelopeFrom(addr string) Option {
+	return func(c *SendConfig) { c.EnvelopeFrom = addr }
+}
+
+// WithMessageIDDomain sets the domain used for generated Message-IDs
+// instead of the From domain.
+//
+// Parameters:
+//   - domain: The Message-ID domain.
+//
+// Returns:
+//   - Option: The option.
+func WithMessageIDDomain(domain string) Option {
+	return func(c *SendConfig) { c.MessageIDDomain = domain }
+}
+
+// WithMessageIDGenerator replaces the default Message-ID generator, e.g.
+// to embed queue IDs or produce deterministic IDs in tests. It is not
+// called when the message already has a Message-ID header.
+//
+// Parameters:
+//   - gen: The generator.
+//
+// Returns:
+//   - Option: The option.
+func WithMessageIDGenerator(gen types.MessageIDGenerator) Option {
+	return func(c *SendConfig) { c.MessageIDGenerator = gen }
+}
+
+// WithMessageIDOut stores the Message-ID of the built message in dst,
+// so callers can record it for threading or storage.
+//
+// Parameters:
+//   - dst: The destination.
+//
+// Returns:
+//   - Option: The option.
+func WithMessageIDOut(dst *string) Option {
+	return func(c *SendConfig) { c.MessageIDOut = dst }
+}
+
+// WithResult fills dst with the outcome of the send. Fields known before
+// delivery (MessageID, Size) are set even when the send fails.
+//
+// Parameters:
+//   - dst: The destination.
+//
+// Returns:
+//   - Option: The option.
+func WithResult(dst *SendResult) Option {
+	return func(c *SendConfig) { c.Result = dst }
+}
+
+// WithCharset writes the text parts and Subject in charset instead of
+// UTF-8, transcoded with enc. Display names stay UTF-8.
+//
+// Parameters:
+//   - charset: The MIME charset name, e.g. "ISO-2022-JP".
+//   - enc: The encoder from UTF-8 to charset.
+//
+// Returns:
+//   - Option: The option.
+func WithCharset(charset string, enc types.CharsetEncoder) Option {
+	return func(c *SendConfig) {
+		c.Charset = charset
+		c.CharsetEncoder = enc
+	}
+}
+
+// WithReadReceipt requests a read receipt (MDN, RFC 8098) to addr by
+// adding Disposition-Notification-To and the legacy Return-Receipt-To
+// headers. Receiving clients may ignore or ask before honoring it.
+//
+// Parameters:
+//   - addr: The address receipts are sent to.
+//
+// Returns:
+//   - Option: The option.
+func WithReadReceipt(addr string) Option {
+	return func(c *SendConfig) { c.ReadReceiptTo = addr }
+}
+
+// WithAttachmentCache reuses encoded attachment bodies from cache. Share
+// one cache across a bulk send so each distinct file is encoded once.
+//
+// Parameters:
+//   - cache: The cache.
+//
+// Returns:
+//   - Option: The option.
+func WithAttachmentCache(cache *AttachmentCache) Option {
+	return func(c *SendConfig) { c.AttachmentCache = cache }
+}
+
+// WithAttachmentPolicy rejects attachments whose filenames violate p,
+// failing the send with a *types.AttachmentError before the message is
+// built. See types.DefaultAttachmentPolicy.
+//
+// Parameters:
+//   - p: The policy.
+//
+// Returns:
+//   - Option: The option.
+func WithAttachmentPolicy(p types.AttachmentPolicy) Option {
+	return func(c *SendConfig) { c.AttachmentPolicy = &p }
+}
+
+// WithAttachmentScanner scans every attachment with s, e.g. a clamav
+// client, while the message is built. An infected attachment fails the
+// send with a *types.MalwareError naming the file; a scanner that
+// cannot be reached fails it too. Attachments are read into memory to
+// be scanned.
+//
+// Parameters:
+//   - s: The scanner.
+//
+// Returns:
+//   - Option: The option.
+func WithAttachmentScanner(s types.AttachmentScanner) Option {
+	return func(c *SendConfig) { c.AttachmentScanner = s }
+}
+
+// WithHTMLSanitizer cleans Message.HTML with p before the message is
+// built. Use it when the whole body is untrusted; to clean only embedded
+// snippets, use the sanitizeHTML template func instead.
+//
+// Parameters:
+//   - p: The policy, e.g. sanitize.DefaultPolicy().
+//
+// Returns:
+//   - Option: The option.
+func WithHTMLSanitizer(p *sanitize.Policy) Option {
+	return func(c *SendConfig) { c.HTMLSanitizer = p }
+}
+
+// WithTransform adds HTML body transforms, run in order before the
+// message is built. See the transform package for link rewriting.
+//
+// Parameters:
+//   - t: The transforms.
+//
+// Returns:
+//   - Option: The option.
+func WithTransform(t ...types.BuildTransform) Option {
+	return func(c *SendConfig) { c.Transforms = append(c.Transforms, t...) }
+}
+
+// WithInlineRemoteImages downloads remote images from allowed hosts and
+// embeds them as CID attachments before the message is built. See
+// InlineRemoteImages.
+//
+// Parameters:
+//   - cfg: The download settings.
+//
+// Returns:
+//   - Option: The option.
+func WithInlineRemoteImages(cfg InlineImageConfig) Option {
+	return func(c *SendConfig) { c.InlineImages = &cfg }
+}
+
+// WithFanOut delivers to each recipient in its own SMTP transaction,
+// sharing one connection, so every recipient has an individual envelope
+// and bounces can be attributed. The error joins the failures of
+// individual recipients; per-recipient outcomes are reported in
+// SendResult.Recipients.
+//
+// Parameters:
+//   - f: The fan-out settings.
+//
+// Returns:
+//   - Option: The option.
+func WithFanOut(f FanOut) Option {
+	return func(c *SendConfig) { c.FanOut = &f }
+}
+
+// WithMessageStream selects the provider message stream, such as a
+// Postmark "broadcast" stream, for adapters that separate transactional
+// and bulk mail. Other adapters ignore it.
+//
+// Parameters:
+//   - stream: The stream ID.
+//
+// Returns:
+//   - Option: The option.
+func WithMessageStream(stream string) Option {
+	return func(c *SendConfig) { c.MessageStream = stream }
+}
+
+// WithSentCopy stores a copy of the message with c after a successful
+// send. A failed copy does not fail the send; it is reported in
+// SendResult.SentCopyErr.
+//
+// Parameters:
+//   - c: The copier.
+//
+// Returns:
+//   - Option: The option.
+func WithSentCopy(c SentCopier) Option {
+	return func(cfg *SendConfig) { cfg.SentCopier = c }
+}
+
 // Backoff describes retry sleep schedule.
 type Backoff interface {
 	// Next returns sleep before attempt i (0-based). ok=false when no more.
@@ -115,17 +451,11 @@ func ExponentialBackoff(
 	if attempts <= 0 {
 		attempts = 1
 	}
-	var seed int64
-	_ = binaryReadRand(&seed)
-	src := mrand.NewSource(seed ^ time.Now().UnixNano())
-	r := mrand.New(src)
-
 	return &expBackoff{
 		attempts:   attempts,
 		base:       base,
 		max:        max,
 		fullJitter: fullJitter,
-		r:          r,
 	}
 }
 
@@ -135,7 +465,6 @@ type expBackoff struct {
 	base       time.Duration
 	max        time.Duration
 	fullJitter bool
-	r          *mrand.Rand
 }
 
 // Next returns sleep before attempt i (0-based). ok=false when no more.
@@ -152,23 +481,71 @@ func (b *expBackoff) Next(i int) (time.Duration, bool) {
 		d = b.max
 	}
 	if b.fullJitter {
-		return time.Duration(b.r.Int63n(int64(d + 1))), true
+		return backoffJitter(0, d), true
 	}
 	half := d / 2
-	j := time.Duration(b.r.Int63n(int64(half + 1)))
-	return half + j, true
+	return backoffJitter(half, d), true
 }
 
-// binaryReadRand reads a random number from the binary.
-func binaryReadRand(out *int64) error {
-	var buf [8]byte
-	if _, err := rand.Read(buf[:]); err != nil {
-		return err
-	}
-	var v int64
-	for i := 0; i < 8; i++ {
-		v = (v << 8) | int64(buf[i])
+// backoffJitter returns a random duration in [lo, hi] read from the
+// random source (see SetRandSource), or hi if it fails.
+func backoffJitter(lo, hi time.Duration) time.Duration {
+	n, err := internal.RandInt63n(internal.Rand(), int64(hi-lo)+1)
+	if err != nil {
+		return hi
 	}
-	*out = v
-	return nil
+	return lo + time.Duration(n)
+}
+
+// WithOversizeFallback retries a message the server rejected as too
+// large (SMTP 552) once, with its attachments uploaded by cfg.Upload
+// and replaced by download links (see LinkAttachments). The replaced
+// attachments are reported in SendResult.LinkedAttachments.
+// Attachments are buffered in memory for the retry.
+//
+// Parameters:
+//   - cfg: The fallback settings.
+//
+// Returns:
+//   - Option: The option.
+func WithOversizeFallback(cfg OversizeFallback) Option {
+	return func(c *SendConfig) { c.Oversize = &cfg }
+}
+
+// WithAttachmentLinks uploads attachments of at least p.MinSize bytes
+// with p.Uploader before the message is built and replaces them with a
+// block of download links. See AttachmentLinkPolicy.Apply.
+//
+// Parameters:
+//   - p: The link policy.
+//
+// Returns:
+//   - Option: The option.
+func WithAttachmentLinks(p AttachmentLinkPolicy) Option {
+	return func(c *SendConfig) { c.AttachmentLinks = &p }
+}
+
+// WithArchiveCopy adds addr as a hidden envelope recipient of every
+// send, e.g. a compliance journaling mailbox. It is not written to any
+// header.
+//
+// Parameters:
+//   - addr: The archive address.
+//   - mode: How a refused archive copy affects the send.
+//
+// Returns:
+//   - Option: The option.
+func WithArchiveCopy(addr string, mode ArchiveMode) Option {
+	return func(c *SendConfig) { c.ArchiveCopy, c.ArchiveMode = addr, mode }
+}
+
+// WithReturnPathAlignment makes the SMTP and sendmail adapters refuse
+// to send when the envelope sender domain is not aligned with the From
+// domain (see CheckReturnPathAlignment). Lint reports the same problem
+// as a finding.
+//
+// Returns:
+//   - Option: The option.
+func WithReturnPathAlignment() Option {
+	return func(c *SendConfig) { c.RequireReturnPathAlignment = true }
 }
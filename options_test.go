@@ -1,16 +1,24 @@
 package email
 
 import (
+	"context"
 	"testing"
 
+	"github.com/aatuh/email/v2/sanitize"
 	"github.com/aatuh/email/v2/types"
 )
 
+type nopCopier struct{}
+
+func (*nopCopier) CopySent(context.Context, []byte) error { return nil }
+
 func TestOptionsApply(t *testing.T) {
 	var cfg SendConfig
 	rl := NewTokenBucket(5, 2)
 	pool := NewConnPool(1, 0, nil, nil, nil)
 	hooks := &types.Hooks{}
+	cache := NewAttachmentCache(1 << 20)
+	copier := &nopCopier{}
 	dkim := types.DKIMConfig{Domain: "example.com", Selector: "sel", KeyPEM: []byte("k")}
 
 	opts := []Option{
@@ -19,6 +27,19 @@ func TestOptionsApply(t *testing.T) {
 		WithPool(pool),
 		WithHooks(hooks),
 		WithDKIM(dkim),
+		WithMaxMessageSize(1 << 20),
+		WithMaxAttachmentSize(1 << 10),
+		WithEnvelopeFrom("bounces@example.com"),
+		WithCharset("ISO-8859-1", func(b []byte) ([]byte, error) { return b, nil }),
+		WithReadReceipt("receipts@example.com"),
+		WithAttachmentCache(cache),
+		WithAttachmentPolicy(types.DefaultAttachmentPolicy()),
+		WithHTMLSanitizer(sanitize.DefaultPolicy()),
+		WithTransform(types.BuildTransformFunc(nil), types.BuildTransformFunc(nil)),
+		WithInlineRemoteImages(InlineImageConfig{AllowedHosts: []string{"cdn.example.com"}}),
+		WithFanOut(FanOut{PersonalizeTo: true, VERP: "bounces@example.com"}),
+		WithMessageStream("broadcast"),
+		WithSentCopy(copier),
 	}
 	for _, o := range opts {
 		o(&cfg)
@@ -27,6 +48,42 @@ func TestOptionsApply(t *testing.T) {
 	if cfg.ListUnsub == "" || cfg.Rate != rl || cfg.Pool != pool || cfg.Hooks != hooks || cfg.DKIM == nil {
 		t.Fatalf("options not applied: %+v", cfg)
 	}
+	if cfg.EnvelopeFrom != "bounces@example.com" {
+		t.Fatalf("envelope from not applied: %+v", cfg)
+	}
+	if cfg.AttachmentPolicy == nil || !cfg.AttachmentPolicy.BlockDoubleExtensions {
+		t.Fatalf("attachment policy not applied: %+v", cfg)
+	}
+	if cfg.InlineImages == nil || cfg.InlineImages.AllowedHosts[0] != "cdn.example.com" {
+		t.Fatalf("inline images not applied: %+v", cfg)
+	}
+	if cfg.FanOut == nil || !cfg.FanOut.PersonalizeTo || cfg.FanOut.VERP != "bounces@example.com" {
+		t.Fatalf("fan-out not applied: %+v", cfg)
+	}
+	if cfg.SentCopier != copier {
+		t.Fatalf("sent copier not applied: %+v", cfg)
+	}
+	if cfg.MessageStream != "broadcast" {
+		t.Fatalf("message stream not applied: %+v", cfg)
+	}
+	if len(cfg.Transforms) != 2 {
+		t.Fatalf("transforms not applied: %+v", cfg)
+	}
+	if cfg.HTMLSanitizer == nil {
+		t.Fatalf("html sanitizer not applied: %+v", cfg)
+	}
+	if cfg.AttachmentCache != cache {
+		t.Fatalf("attachment cache not applied: %+v", cfg)
+	}
+	if cfg.ReadReceiptTo != "receipts@example.com" {
+		t.Fatalf("read receipt not applied: %+v", cfg)
+	}
+	if cfg.Charset != "ISO-8859-1" || cfg.CharsetEncoder == nil {
+		t.Fatalf("charset not applied: %+v", cfg)
+	}
+	if cfg.MaxMessageSize != 1<<20 || cfg.MaxAttachmentSize != 1<<10 {
+		t.Fatalf("size limits not applied: %+v", cfg)
+	}
 	if cfg.DKIM.Domain != "example.com" || cfg.DKIM.Selector != "sel" {
 		t.Fatalf("dkim option not set correctly: %+v", cfg.DKIM)
 	}
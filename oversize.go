@@ -0,0 +1,71 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// UploadFunc stores an attachment that is too large to send and
+// returns a link to download it. expires is shown to the recipient if
+// not zero.
+type UploadFunc func(
+	ctx context.Context,
+	a types.Attachment,
+) (link string, expires time.Time, err error)
+
+// OversizeFallback controls LinkAttachments; see WithOversizeFallback.
+type OversizeFallback struct {
+	// Upload stores each replaced attachment. Required.
+	Upload UploadFunc
+	// MinSize keeps attachments smaller than this many bytes in the
+	// message (0 = replace all). Inline attachments are always kept.
+	MinSize int64
+	// Intro introduces the list of links; defaults to "Some attachments
+	// were too large to send by email. Download them here:".
+	Intro string
+}
+
+// LinkedAttachment is an attachment replaced by a download link.
+type LinkedAttachment struct {
+	Filename string
+	Size     int64
+	Link     string
+	Expires  time.Time
+}
+
+// LinkAttachments uploads the non-inline attachments of msg with
+// cfg.Upload and replaces them with a list of download links appended
+// to the text and HTML bodies, for a relay that rejected the message as
+// too large. The attachment readers are consumed; msg is not otherwise
+// modified.
+//
+// Parameters:
+//   - ctx: The context for the uploads.
+//   - msg: The message.
+//   - cfg: The fallback settings.
+//
+// Returns:
+//   - types.Message: The message with attachments linked.
+//   - []LinkedAttachment: The replaced attachments, in Attach order.
+//   - error: The error if an attachment fails to read or upload.
+func LinkAttachments(
+	ctx context.Context,
+	msg types.Message,
+	cfg OversizeFallback,
+) (types.Message, []LinkedAttachment, error) {
+	if cfg.Upload == nil {
+		return msg, nil, errors.New("oversize fallback: no upload function")
+	}
+	intro := cfg.Intro
+	if intro == "" {
+		intro = "Some attachments were too large to send by email. Download them here:"
+	}
+	return AttachmentLinkPolicy{
+		Uploader: cfg.Upload,
+		MinSize:  cfg.MinSize,
+		Intro:    intro,
+	}.Apply(ctx, msg)
+}
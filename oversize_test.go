@@ -0,0 +1,67 @@
+package email
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestLinkAttachments(t *testing.T) {
+	msg := types.Message{
+		Plain: []byte("Hi"),
+		HTML:  []byte("<html><body><p>Hi</p></BODY></html>"),
+		Attach: []types.Attachment{
+			{Filename: "a&b.zip", Reader: strings.NewReader(strings.Repeat("x", 3000))},
+			{Filename: "small.txt", Reader: strings.NewReader("tiny")},
+			{Filename: "logo.png", ContentID: "logo", Reader: strings.NewReader("png")},
+		},
+	}
+	var names []string
+	cfg := OversizeFallback{
+		MinSize: 1024,
+		Upload: func(ctx context.Context, a types.Attachment) (string, time.Time, error) {
+			names = append(names, a.Filename)
+			return "https://files.example.com/1?a=1&b=2", time.Time{}, nil
+		},
+	}
+	out, linked, err := LinkAttachments(context.Background(), msg, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(linked) != 1 || linked[0].Size != 3000 || strings.Join(names, ",") != "a&b.zip" {
+		t.Fatalf("linked = %+v, uploaded %v", linked, names)
+	}
+	if len(out.Attach) != 2 || out.Attach[0].Filename != "small.txt" {
+		t.Fatalf("kept = %+v", out.Attach)
+	}
+	if b, _ := io.ReadAll(out.Attach[0].Reader); string(b) != "tiny" {
+		t.Fatalf("kept attachment = %q", b)
+	}
+	wantText := "Hi\n\n" + "Some attachments were too large to send by email. Download them here:\n" +
+		"\n- a&b.zip (2.9 KB): https://files.example.com/1?a=1&b=2\n"
+	if string(out.Plain) != wantText {
+		t.Fatalf("plain = %q", out.Plain)
+	}
+	wantHTML := `<li><a href="https://files.example.com/1?a=1&amp;b=2">a&amp;b.zip</a> (2.9 KB)</li></ul></BODY></html>`
+	if !strings.HasSuffix(string(out.HTML), wantHTML) || string(msg.HTML) == string(out.HTML) {
+		t.Fatalf("html = %q", out.HTML)
+	}
+
+	// Text is only added to an empty body when there is no HTML.
+	out, _, err = LinkAttachments(context.Background(), types.Message{
+		Attach: []types.Attachment{{Filename: "a.zip", Reader: strings.NewReader("x")}},
+	}, OversizeFallback{Intro: "Files:", Upload: func(context.Context, types.Attachment) (string, time.Time, error) {
+		return "https://x.example", time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC), nil
+	}})
+	if err != nil || string(out.Plain) != "Files:\n\n- a.zip (1 bytes): https://x.example\n  available until 2026-10-20 12:00 UTC\n" {
+		t.Fatalf("plain = %q, %v", out.Plain, err)
+	}
+
+	if _, _, err := LinkAttachments(context.Background(), msg, OversizeFallback{}); err == nil {
+		t.Fatal("expected error without Upload")
+	}
+}
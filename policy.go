@@ -0,0 +1,100 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// ErrPolicyViolation is matched by the *PolicyError of a rejected
+// message.
+var ErrPolicyViolation = errors.New("email: message violates policy")
+
+// Policy decides whether a message may be sent, e.g. to enforce
+// outbound mail rules of an organization. The policy package provides
+// rules and combinators.
+type Policy interface {
+	// CheckMessage returns a *PolicyError, or another error, if msg
+	// must not be sent.
+	CheckMessage(ctx context.Context, msg types.Message) error
+}
+
+// PolicyFunc adapts a function to Policy.
+type PolicyFunc func(ctx context.Context, msg types.Message) error
+
+// CheckMessage implements Policy.
+//
+// Parameters:
+//   - ctx: The context.
+//   - msg: The message.
+//
+// Returns:
+//   - error: The error of f.
+func (f PolicyFunc) CheckMessage(ctx context.Context, msg types.Message) error {
+	return f(ctx, msg)
+}
+
+// PolicyError reports a message rejected by a Policy.
+type PolicyError struct {
+	Rule   string // rule name, e.g. "max-recipients"
+	Reason string // human readable reason
+}
+
+// Error implements the error interface.
+//
+// Returns:
+//   - string: The error message.
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("email: policy %s: %s", e.Rule, e.Reason)
+}
+
+// Is matches ErrPolicyViolation.
+//
+// Parameters:
+//   - target: The error to compare with.
+//
+// Returns:
+//   - bool: True if target is ErrPolicyViolation.
+func (e *PolicyError) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
+// WithPolicy checks the message against p before anything else is
+// done with it; the first policy that fails aborts the send with its
+// error. It applies to the message as given, with the aliases of
+// WithRecipientExpansion expanded, but before WithArchiveCopy
+// recipients or rewrites such as WithInlineRemoteImages are added.
+//
+// Parameters:
+//   - p: The policies.
+//
+// Returns:
+//   - Option: The option.
+func WithPolicy(p ...Policy) Option {
+	return func(c *SendConfig) { c.Policies = append(c.Policies, p...) }
+}
+
+// CheckPolicy runs the policies of WithPolicy options in opts against
+// msg, as the adapters do before sending.
+//
+// Parameters:
+//   - ctx: The context.
+//   - msg: The message to check.
+//   - opts: The send options that will be used.
+//
+// Returns:
+//   - error: The error of the first failing policy.
+func CheckPolicy(ctx context.Context, msg types.Message, opts ...Option) error {
+	var cfg SendConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	for _, p := range cfg.Policies {
+		if err := p.CheckMessage(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
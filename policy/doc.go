@@ -0,0 +1,13 @@
+// Package policy provides rules for email.WithPolicy: recipient domain
+// allow and deny lists, a recipient limit, required headers and banned
+// words. Rules compose with All, Any and When, and Rule turns a
+// predicate into a policy, so an organization's outbound mail rules can
+// be assembled in one place:
+//
+//	p := policy.All(
+//		policy.DenyRecipientDomains("competitor.example"),
+//		policy.MaxRecipients(50),
+//		policy.When(policy.External("example.com"),
+//			policy.BannedWords("confidential", "internal only")),
+//	)
+package policy
@@ -0,0 +1,282 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
+
+// Rule returns a policy named name that rejects a message for which
+// check returns a non-empty reason, with a *email.PolicyError.
+//
+// Parameters:
+//   - name: The rule name reported in errors.
+//   - check: The predicate, returning "" for an acceptable message.
+//
+// Returns:
+//   - email.Policy: The policy.
+func Rule(name string, check func(msg types.Message) string) email.Policy {
+	return email.PolicyFunc(func(ctx context.Context, msg types.Message) error {
+		if reason := check(msg); reason != "" {
+			return &email.PolicyError{Rule: name, Reason: reason}
+		}
+		return nil
+	})
+}
+
+// All returns a policy that passes if every one of p passes. The first
+// failure is returned.
+//
+// Parameters:
+//   - p: The policies.
+//
+// Returns:
+//   - email.Policy: The policy.
+func All(p ...email.Policy) email.Policy {
+	return email.PolicyFunc(func(ctx context.Context, msg types.Message) error {
+		for _, q := range p {
+			if err := q.CheckMessage(ctx, msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Any returns a policy that passes if at least one of p passes. If all
+// fail, their errors are joined.
+//
+// Parameters:
+//   - p: The policies.
+//
+// Returns:
+//   - email.Policy: The policy.
+func Any(p ...email.Policy) email.Policy {
+	return email.PolicyFunc(func(ctx context.Context, msg types.Message) error {
+		var errs []error
+		for _, q := range p {
+			err := q.CheckMessage(ctx, msg)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// When returns a policy that applies p only to messages for which cond
+// is true.
+//
+// Parameters:
+//   - cond: The condition.
+//   - p: The policy.
+//
+// Returns:
+//   - email.Policy: The policy.
+func When(cond func(msg types.Message) bool, p email.Policy) email.Policy {
+	return email.PolicyFunc(func(ctx context.Context, msg types.Message) error {
+		if !cond(msg) {
+			return nil
+		}
+		return p.CheckMessage(ctx, msg)
+	})
+}
+
+// External returns a condition for When that is true if any envelope
+// recipient is outside domains (see AllowRecipientDomains for the
+// patterns).
+//
+// Parameters:
+//   - domains: The internal domains.
+//
+// Returns:
+//   - func(types.Message) bool: The condition.
+func External(domains ...string) func(msg types.Message) bool {
+	m := newDomainMatcher(domains)
+	return func(msg types.Message) bool {
+		for _, rcpt := range msg.EnvelopeRecipients() {
+			if !m.match(rcpt) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AllowRecipientDomains rejects messages with an envelope recipient
+// outside domains. "example.com" matches that domain only and
+// "*.example.com" its subdomains. Domains compare case-insensitively,
+// internationalized ones in ASCII form.
+//
+// Parameters:
+//   - domains: The allowed domains.
+//
+// Returns:
+//   - email.Policy: The policy.
+func AllowRecipientDomains(domains ...string) email.Policy {
+	m := newDomainMatcher(domains)
+	return Rule("allow-recipient-domains", func(msg types.Message) string {
+		for _, rcpt := range msg.EnvelopeRecipients() {
+			if !m.match(rcpt) {
+				return fmt.Sprintf("recipient %s is not in an allowed domain", rcpt)
+			}
+		}
+		return ""
+	})
+}
+
+// DenyRecipientDomains rejects messages with an envelope recipient in
+// domains, matched as by AllowRecipientDomains.
+//
+// Parameters:
+//   - domains: The denied domains.
+//
+// Returns:
+//   - email.Policy: The policy.
+func DenyRecipientDomains(domains ...string) email.Policy {
+	m := newDomainMatcher(domains)
+	return Rule("deny-recipient-domains", func(msg types.Message) string {
+		for _, rcpt := range msg.EnvelopeRecipients() {
+			if m.match(rcpt) {
+				return fmt.Sprintf("recipient %s is in a denied domain", rcpt)
+			}
+		}
+		return ""
+	})
+}
+
+// MaxRecipients rejects messages with more than n envelope recipients.
+//
+// Parameters:
+//   - n: The recipient limit.
+//
+// Returns:
+//   - email.Policy: The policy.
+func MaxRecipients(n int) email.Policy {
+	return Rule("max-recipients", func(msg types.Message) string {
+		if got := len(msg.EnvelopeRecipients()); got > n {
+			return fmt.Sprintf("%d recipients, at most %d allowed", got, n)
+		}
+		return ""
+	})
+}
+
+// RequireHeaders rejects messages whose Headers lack any of names or
+// have it empty. Headers added by send options, such as
+// WithListUnsubscribe, are not seen.
+//
+// Parameters:
+//   - names: The header names.
+//
+// Returns:
+//   - email.Policy: The policy.
+func RequireHeaders(names ...string) email.Policy {
+	return Rule("require-headers", func(msg types.Message) string {
+		for _, name := range names {
+			if strings.TrimSpace(msg.Headers.Get(name)) == "" {
+				return "missing header " + name
+			}
+		}
+		return ""
+	})
+}
+
+// BannedWords rejects messages whose subject, plain text or HTML text
+// contains one of words, as a whole word or phrase compared
+// case-insensitively. HTML tags are ignored and entities decoded.
+//
+// Parameters:
+//   - words: The banned words or phrases.
+//
+// Returns:
+//   - email.Policy: The policy.
+func BannedWords(words ...string) email.Policy {
+	if len(words) == 0 {
+		return All()
+	}
+	alts := make([]string, len(words))
+	for i, w := range words {
+		alts[i] = strings.Join(strings.Fields(regexp.QuoteMeta(w)), `[\s\p{Z}]+`)
+	}
+	re := regexp.MustCompile(`(?i)(?:^|\W)(` + strings.Join(alts, "|") + `)(?:\W|$)`)
+	return Rule("banned-words", func(msg types.Message) string {
+		for _, part := range []struct{ name, text string }{
+			{"subject", msg.Subject},
+			{"plain text", string(msg.Plain)},
+			{"HTML", htmlText(msg.HTML)},
+		} {
+			if m := re.FindStringSubmatch(part.text); m != nil {
+				return fmt.Sprintf("%s contains %q", part.name,
+					strings.Join(strings.Fields(m[1]), " "))
+			}
+		}
+		return ""
+	})
+}
+
+var (
+	htmlHidden = regexp.MustCompile(`(?is)<(style|script)\b.*?</(style|script)>`)
+	htmlTag    = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// htmlText returns the text of an HTML body.
+func htmlText(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	s := htmlHidden.ReplaceAllString(string(b), " ")
+	return html.UnescapeString(htmlTag.ReplaceAllString(s, " "))
+}
+
+// domainMatcher matches addresses against domain patterns.
+type domainMatcher struct {
+	exact    map[string]bool
+	suffixes []string // ".example.com" for "*.example.com"
+}
+
+// newDomainMatcher compiles patterns.
+func newDomainMatcher(patterns []string) domainMatcher {
+	m := domainMatcher{exact: map[string]bool{}}
+	for _, p := range patterns {
+		if rest, ok := strings.CutPrefix(p, "*."); ok {
+			m.suffixes = append(m.suffixes, "."+asciiDomain(rest))
+			continue
+		}
+		m.exact[asciiDomain(p)] = true
+	}
+	return m
+}
+
+// match reports whether the domain of addr matches.
+func (m domainMatcher) match(addr string) bool {
+	i := strings.LastIndexByte(addr, '@')
+	if i < 0 {
+		return false
+	}
+	d := asciiDomain(addr[i+1:])
+	if m.exact[d] {
+		return true
+	}
+	for _, s := range m.suffixes {
+		if strings.HasSuffix(d, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// asciiDomain returns domain lowercased, in ASCII form if it is valid.
+func asciiDomain(domain string) string {
+	d := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+	if a, err := types.DomainToASCII(d); err == nil {
+		return a
+	}
+	return d
+}
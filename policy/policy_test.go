@@ -0,0 +1,71 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestRules(t *testing.T) {
+	msg := types.Message{
+		From:    types.Address{Mail: "app@example.com"},
+		To:      []types.Address{{Mail: "ada@Example.com"}, {Mail: "bob@eu.partner.example"}},
+		Cc:      []types.Address{{Mail: "eve@bücher.example"}},
+		Subject: "Quarterly numbers",
+		Plain:   []byte("See the attached report."),
+		HTML:    []byte(`<p title="internal only">Strictly&nbsp;<b>Confidential</b></p>`),
+	}
+	msg.Headers.Set("X-Department", "finance")
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name string
+		p    email.Policy
+		want string // error substring, "" to pass
+	}{
+		{"allow", AllowRecipientDomains("example.com", "*.partner.example", "xn--bcher-kva.example"), ""},
+		{"allow missing", AllowRecipientDomains("example.com", "bücher.example"),
+			"allow-recipient-domains: recipient bob@eu.partner.example is not in an allowed domain"},
+		{"deny", DenyRecipientDomains("*.partner.example"), "recipient bob@eu.partner.example is in a denied domain"},
+		{"deny other", DenyRecipientDomains("partner.example"), ""},
+		{"max", MaxRecipients(3), ""},
+		{"max exceeded", MaxRecipients(2), "max-recipients: 3 recipients, at most 2 allowed"},
+		{"headers", RequireHeaders("x-department"), ""},
+		{"headers missing", RequireHeaders("X-Department", "X-Campaign"), "missing header X-Campaign"},
+		{"words", BannedWords("report card", "number"), ""},
+		{"words plain", BannedWords("attached   report"), `plain text contains "attached report"`},
+		{"words html", BannedWords("strictly confidential"), `HTML contains "Strictly Confidential"`},
+		{"words attribute", BannedWords("internal only"), ""},
+		{"all", All(MaxRecipients(5), MaxRecipients(1)), "at most 1 allowed"},
+		{"any", Any(MaxRecipients(1), MaxRecipients(5)), ""},
+		{"any fails", Any(MaxRecipients(1), MaxRecipients(2)), "at most 1 allowed\nemail: policy max-recipients: 3 recipients, at most 2 allowed"},
+		{"when external", When(External("example.com"), MaxRecipients(1)), "at most 1 allowed"},
+		{"when internal", When(External("example.com", "*.example", "bücher.example"), MaxRecipients(1)), ""},
+		{"rule", Rule("subject", func(m types.Message) string {
+			if strings.HasPrefix(m.Subject, "Quarterly") {
+				return "quarterly mail needs review"
+			}
+			return ""
+		}), "policy subject: quarterly mail needs review"},
+	} {
+		err := tc.p.CheckMessage(ctx, msg)
+		switch {
+		case tc.want == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+			t.Errorf("%s: got %v, want %q", tc.name, err, tc.want)
+		case err != nil && !errors.Is(err, email.ErrPolicyViolation):
+			t.Errorf("%s: %v does not match ErrPolicyViolation", tc.name, err)
+		}
+	}
+
+	err := email.CheckPolicy(ctx, msg, email.WithPolicy(MaxRecipients(5)),
+		email.WithPolicy(DenyRecipientDomains("example.com")))
+	var pe *email.PolicyError
+	if !errors.As(err, &pe) || pe.Rule != "deny-recipient-domains" {
+		t.Fatalf("CheckPolicy: %v", err)
+	}
+}
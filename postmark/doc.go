@@ -0,0 +1,4 @@
+// Package postmark is a mailer adapter for the Postmark HTTP API. It
+// implements the Mailer interface and sends templates with
+// SendTemplate.
+package postmark
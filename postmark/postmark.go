@@ -0,0 +1,330 @@
+package postmark
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// DefaultBaseURL is the Postmark API endpoint.
+const DefaultBaseURL = "https://api.postmarkapp.com"
+
+// PostmarkConfig configures the Postmark mailer.
+type PostmarkConfig struct {
+	ServerToken string // X-Postmark-Server-Token
+
+	// MessageStream is the default stream; email.WithMessageStream
+	// overrides it per send. Empty uses the server's default
+	// transactional stream.
+	MessageStream string
+
+	BaseURL string       // defaults to DefaultBaseURL
+	Client  *http.Client // defaults to a client with Timeout
+	Timeout time.Duration
+}
+
+// Template selects a Postmark template for SendTemplate by ID or alias.
+type Template struct {
+	ID        int64
+	Alias     string
+	Model     any  // template model, marshaled to JSON
+	InlineCSS bool // inline the template's CSS
+}
+
+// Postmark implements the Mailer interface over the Postmark API.
+type Postmark struct {
+	cfg    PostmarkConfig
+	client *http.Client
+}
+
+// NewPostmark creates a new Postmark mailer.
+//
+// Parameters:
+//   - cfg: The Postmark config.
+//
+// Returns:
+//   - *Postmark: The Postmark mailer.
+func NewPostmark(cfg PostmarkConfig) *Postmark {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	client := cfg.Client
+	if client == nil {
+		t := cfg.Timeout
+		if t <= 0 {
+			t = 30 * time.Second
+		}
+		client = &http.Client{Timeout: t}
+	}
+	return &Postmark{cfg: cfg, client: client}
+}
+
+// Send sends an email via the /email endpoint. The Postmark MessageID
+// is reported in SendResult.ProviderMessageID. Postmark builds and
+// signs the message, so options of the MIME build (WithDKIM,
+// WithCharset, WithMessageIDDomain, WithMaxMessageSize, ...) are
+// ignored.
+//
+// Parameters:
+//   - ctx: The context.
+//   - msg: The message.
+//   - opts: The options.
+//
+// Returns:
+//   - error: The error if the email fails to send.
+func (p *Postmark) Send(
+	ctx context.Context,
+	msg types.Message,
+	opts ...email.Option,
+) error {
+	return p.send(ctx, msg, nil, opts)
+}
+
+// SendTemplate sends msg rendered by a Postmark template via the
+// /email/withTemplate endpoint. The template supplies the subject and
+// body, so msg.Subject, msg.Plain and msg.HTML are ignored.
+//
+// Parameters:
+//   - ctx: The context.
+//   - msg: The message addresses, headers and attachments.
+//   - tmpl: The template and its model.
+//   - opts: The options.
+//
+// Returns:
+//   - error: The error if the email fails to send.
+func (p *Postmark) SendTemplate(
+	ctx context.Context,
+	msg types.Message,
+	tmpl Template,
+	opts ...email.Option,
+) error {
+	if tmpl.ID == 0 && tmpl.Alias == "" {
+		return errors.New("postmark: template ID or alias required")
+	}
+	msg.Subject, msg.Plain, msg.HTML = "", nil, nil
+	return p.send(ctx, msg, &tmpl, opts)
+}
+
+// send delivers msg, rendered by tmpl if set, with retries.
+func (p *Postmark) send(
+	ctx context.Context,
+	msg types.Message,
+	tmpl *Template,
+	opts []email.Option,
+) error {
+	var cfg email.SendConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if cfg.FanOut != nil {
+		return errors.New("postmark: WithFanOut is not supported")
+	}
+	if len(msg.EnvelopeTo) > 0 {
+		return errors.New("postmark: Message.EnvelopeTo is not supported")
+	}
+	msg, err := email.ExpandRecipients(ctx, msg, opts...)
+	if err != nil {
+		return err
+	}
+	if err := email.CheckPolicy(ctx, msg, opts...); err != nil {
+		return err
+	}
+	if cfg.ArchiveCopy != "" {
+		// The API takes no envelope; a Bcc is delivered but not shown.
+		msg.Bcc = append(slices.Clip(msg.Bcc), types.Address{Mail: cfg.ArchiveCopy})
+	}
+	if cfg.AddrCheck != nil {
+		for _, rcpt := range msg.RecipientList() {
+			if err := cfg.AddrCheck.CheckAddress(ctx, rcpt); err != nil {
+				return err
+			}
+		}
+	}
+	if cfg.InlineImages != nil {
+		var err error
+		if msg, err = email.InlineRemoteImages(ctx, msg, *cfg.InlineImages); err != nil {
+			return err
+		}
+	}
+	if cfg.AttachmentLinks != nil {
+		var err error
+		if msg, _, err = cfg.AttachmentLinks.Apply(ctx, msg); err != nil {
+			return err
+		}
+	}
+	if cfg.Rate != nil {
+		if err := cfg.Rate.Acquire(ctx); err != nil {
+			return err
+		}
+	}
+
+	content, err := internal.PrepareContent(ctx, msg, internal.SendBuildOptions(&cfg),
+		tmpl != nil)
+	if err != nil {
+		return err
+	}
+	stream := p.cfg.MessageStream
+	if cfg.MessageStream != "" {
+		stream = cfg.MessageStream
+	}
+	req := newRequest(msg, content, stream, tmpl)
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if cfg.MaxMessageSize > 0 && int64(len(body)) > cfg.MaxMessageSize {
+		return &types.SizeError{
+			Size: int64(len(body)), Limit: cfg.MaxMessageSize,
+		}
+	}
+
+	res := cfg.Result
+	if res == nil {
+		res = &email.SendResult{}
+	}
+	*res = email.SendResult{
+		MessageID:       msg.Headers.Get("Message-ID"),
+		Size:            len(body),
+		AttachmentSizes: content.Sizes(),
+	}
+	url := p.cfg.BaseURL + "/email"
+	if tmpl != nil {
+		url += "/withTemplate"
+	}
+	header := http.Header{}
+	header.Set("X-Postmark-Server-Token", p.cfg.ServerToken)
+	res.Attempts, err = internal.Retry(ctx, cfg.Backoff, cfg.Hooks,
+		internal.IsTransientAPI, func(ctx context.Context) error {
+			status, data, err := internal.PostJSON(ctx, p.client, url,
+				header, body)
+			if err != nil {
+				return err
+			}
+			var r response
+			if err := json.Unmarshal(data, &r); err != nil && status == http.StatusOK {
+				return fmt.Errorf("postmark: decode response: %w", err)
+			}
+			if status != http.StatusOK || r.ErrorCode != 0 {
+				apiErr := &types.APIError{
+					Provider: "postmark", StatusCode: status,
+					Message: r.Message,
+				}
+				if r.ErrorCode != 0 {
+					apiErr.Code = strconv.Itoa(r.ErrorCode)
+				}
+				if apiErr.Message == "" {
+					apiErr.Message = http.StatusText(status)
+				}
+				return apiErr
+			}
+			res.ProviderMessageID = r.MessageID
+			res.Response = r.Message
+			return nil
+		})
+	if cfg.MessageIDOut != nil {
+		*cfg.MessageIDOut = res.MessageID
+	}
+	return err
+}
+
+// request is the JSON body of /email and /email/withTemplate.
+type request struct {
+	From          string       `json:"From"`
+	To            string       `json:"To,omitempty"`
+	Cc            string       `json:"Cc,omitempty"`
+	Bcc           string       `json:"Bcc,omitempty"`
+	ReplyTo       string       `json:"ReplyTo,omitempty"`
+	Subject       string       `json:"Subject,omitempty"`
+	TextBody      string       `json:"TextBody,omitempty"`
+	HtmlBody      string       `json:"HtmlBody,omitempty"`
+	Headers       []header     `json:"Headers,omitempty"`
+	Attachments   []attachment `json:"Attachments,omitempty"`
+	MessageStream string       `json:"MessageStream,omitempty"`
+
+	TemplateID    int64  `json:"TemplateId,omitempty"`
+	TemplateAlias string `json:"TemplateAlias,omitempty"`
+	TemplateModel any    `json:"TemplateModel,omitempty"`
+	InlineCSS     bool   `json:"InlineCss,omitempty"`
+}
+
+type header struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
+
+type attachment struct {
+	Name        string `json:"Name"`
+	Content     string `json:"Content"`
+	ContentType string `json:"ContentType"`
+	ContentID   string `json:"ContentID,omitempty"`
+}
+
+// response is the JSON reply, also used for errors.
+type response struct {
+	MessageID string `json:"MessageID"`
+	ErrorCode int    `json:"ErrorCode"`
+	Message   string `json:"Message"`
+}
+
+// newRequest maps msg and its prepared content to a request.
+func newRequest(
+	msg types.Message,
+	c *internal.Content,
+	stream string,
+	tmpl *Template,
+) request {
+	r := request{
+		From:          msg.From.String(),
+		To:            joinAddrs(msg.To),
+		Cc:            joinAddrs(msg.Cc),
+		Bcc:           joinAddrs(msg.Bcc),
+		ReplyTo:       joinAddrs(msg.ReplyTo),
+		Subject:       msg.Subject,
+		TextBody:      string(c.Plain),
+		HtmlBody:      string(c.HTML),
+		MessageStream: stream,
+	}
+	if tmpl != nil {
+		r.TemplateID, r.TemplateAlias = tmpl.ID, tmpl.Alias
+		r.TemplateModel, r.InlineCSS = tmpl.Model, tmpl.InlineCSS
+		if r.TemplateModel == nil {
+			r.TemplateModel = struct{}{}
+		}
+	}
+	for _, f := range c.Headers {
+		r.Headers = append(r.Headers, header{Name: f.Name, Value: f.Value})
+	}
+	for _, a := range c.Attach {
+		pa := attachment{
+			Name: a.Filename, Content: a.Base64, ContentType: a.ContentType,
+		}
+		if a.ContentID != "" {
+			pa.ContentID = "cid:" + a.ContentID
+		}
+		r.Attachments = append(r.Attachments, pa)
+	}
+	return r
+}
+
+// joinAddrs renders addresses as a comma separated list. Groups are
+// flattened to their members, as the API takes plain addresses.
+func joinAddrs(xs []types.Address) string {
+	out := make([]string, 0, len(xs))
+	for _, a := range xs {
+		if a.Mail != "" {
+			out = append(out, a.String())
+		}
+	}
+	return strings.Join(out, ", ")
+}
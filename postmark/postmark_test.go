@@ -0,0 +1,151 @@
+package postmark
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
+
+var _ email.Mailer = (*Postmark)(nil)
+
+func testMessage() types.Message {
+	return types.Message{
+		From:    types.Address{Name: "News", Mail: "news@example.com"},
+		To:      []types.Address{{Name: "Ada", Mail: "ada@example.org"}},
+		Bcc:     []types.Address{{Mail: "audit@example.com"}},
+		Subject: "Hello",
+		Plain:   []byte("hi"),
+		HTML:    []byte(`<p>hi <img src="cid:logo"></p>`),
+		Attach: []types.Attachment{
+			{Filename: "a.txt", Reader: strings.NewReader("attached")},
+			{Filename: "logo.png", ContentType: "image/png", ContentID: "logo",
+				Reader: strings.NewReader("png")},
+		},
+		InReplyTo: "parent@example.com",
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got map[string]any
+	var path, token string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, token = r.URL.Path, r.Header.Get("X-Postmark-Server-Token")
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &got)
+		w.Write([]byte(`{"To":"ada@example.org","MessageID":"b7bc2f4a-e38e","ErrorCode":0,"Message":"OK"}`))
+	}))
+	defer srv.Close()
+
+	p := NewPostmark(PostmarkConfig{
+		ServerToken: "token", BaseURL: srv.URL + "/", MessageStream: "outbound",
+	})
+	var res email.SendResult
+	err := p.Send(context.Background(), testMessage(), email.WithResult(&res),
+		email.WithMessageStream("broadcast"),
+		email.WithListUnsubscribe("<https://example.com/u>"))
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if path != "/email" || token != "token" {
+		t.Fatalf("unexpected request %s %q", path, token)
+	}
+	if res.ProviderMessageID != "b7bc2f4a-e38e" || res.Attempts != 1 ||
+		res.Response != "OK" || len(res.AttachmentSizes) != 2 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if got["From"] != `"News" <news@example.com>` || got["To"] != `"Ada" <ada@example.org>` ||
+		got["Bcc"] != "audit@example.com" || got["MessageStream"] != "broadcast" ||
+		got["TextBody"] != "hi" || got["Subject"] != "Hello" {
+		t.Fatalf("unexpected request: %v", got)
+	}
+	headers := map[string]any{}
+	for _, h := range got["Headers"].([]any) {
+		h := h.(map[string]any)
+		headers[h["Name"].(string)] = h["Value"]
+	}
+	if headers["In-Reply-To"] != "<parent@example.com>" ||
+		headers["List-Unsubscribe"] != "<https://example.com/u>" {
+		t.Fatalf("unexpected headers: %v", headers)
+	}
+	attach, _ := json.Marshal(got["Attachments"])
+	if !strings.Contains(string(attach), `"Content":"YXR0YWNoZWQ=","ContentType":"text/plain; charset=utf-8"`) ||
+		!strings.Contains(string(attach), `"ContentID":"cid:logo"`) {
+		t.Fatalf("unexpected attachments: %s", attach)
+	}
+}
+
+func TestSendTemplate(t *testing.T) {
+	var got map[string]any
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &got)
+		w.Write([]byte(`{"MessageID":"tmpl-1","ErrorCode":0,"Message":"OK"}`))
+	}))
+	defer srv.Close()
+
+	p := NewPostmark(PostmarkConfig{ServerToken: "token", BaseURL: srv.URL})
+	msg := testMessage()
+	msg.Plain, msg.HTML, msg.Attach = nil, nil, nil
+	var res email.SendResult
+	err := p.SendTemplate(context.Background(), msg, Template{
+		Alias: "welcome", Model: map[string]string{"name": "Ada"},
+	}, email.WithResult(&res))
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if path != "/email/withTemplate" || res.ProviderMessageID != "tmpl-1" {
+		t.Fatalf("unexpected request %s %+v", path, res)
+	}
+	model, _ := got["TemplateModel"].(map[string]any)
+	if got["TemplateAlias"] != "welcome" || model["name"] != "Ada" ||
+		got["Subject"] != nil || got["MessageStream"] != nil {
+		t.Fatalf("unexpected request: %v", got)
+	}
+	if err := p.SendTemplate(context.Background(), msg, Template{}); err == nil {
+		t.Fatalf("expected error without template")
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	var calls atomic.Int32
+	status := http.StatusUnprocessableEntity
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(status)
+		w.Write([]byte(`{"ErrorCode":300,"Message":"Invalid 'To' address"}`))
+	}))
+	defer srv.Close()
+
+	p := NewPostmark(PostmarkConfig{ServerToken: "token", BaseURL: srv.URL})
+	retry := email.WithRetry(email.ExponentialBackoff(3, time.Millisecond, time.Millisecond, false))
+	err := p.Send(context.Background(), testMessage(), retry)
+	var apiErr *types.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "300" || apiErr.StatusCode != status ||
+		apiErr.Temporary() || calls.Load() != 1 {
+		t.Fatalf("expected permanent API error after one call, got %v (%d calls)", err, calls.Load())
+	}
+
+	status = http.StatusServiceUnavailable
+	calls.Store(0)
+	var res email.SendResult
+	err = p.Send(context.Background(), testMessage(), retry, email.WithResult(&res))
+	if !errors.As(err, &apiErr) || !apiErr.Temporary() || calls.Load() != 3 || res.Attempts != 3 {
+		t.Fatalf("expected 3 retried attempts, got %v (%d calls) %+v", err, calls.Load(), res)
+	}
+
+	if err := p.Send(context.Background(), testMessage(), email.WithFanOut(email.FanOut{})); err == nil {
+		t.Fatalf("expected fan-out to be rejected")
+	}
+}
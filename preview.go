@@ -0,0 +1,272 @@
+package email
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltmpl "html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// PreviewOptions configures PreviewHandler.
+type PreviewOptions struct {
+	// Load is passed to LoadTemplatesWithOptions.
+	Load LoadOptions
+	// Base supplies addresses, subject and headers of the rendered
+	// messages. From and To default to example.com addresses and the
+	// Subject to the template name.
+	Base types.Message
+	// Poll is how often an open page checks the templates for changes.
+	// Default 1s.
+	Poll time.Duration
+}
+
+// PreviewHandler returns a development server for the templates in
+// fsys. It lists the templates and shows each one rendered as HTML,
+// plain text and a downloadable .eml, with data from the sidecar file
+// name.sample.json if present. Templates are reloaded on every request
+// and open pages reload when a file in fsys changes, so with
+// os.DirFS edits show up on save. Inline images referenced with cid
+// are read from fsys.
+//
+// It is meant for local use only; do not expose it in production.
+//
+// Parameters:
+//   - fsys: The template directory.
+//   - opts: The preview settings.
+//
+// Returns:
+//   - http.Handler: The handler.
+func PreviewHandler(fsys fs.FS, opts PreviewOptions) http.Handler {
+	if opts.Poll <= 0 {
+		opts.Poll = time.Second
+	}
+	p := &preview{fsys: fsys, opts: opts}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", p.index)
+	mux.HandleFunc("GET /view", p.view)
+	mux.HandleFunc("GET /render", p.render)
+	mux.HandleFunc("GET /part", p.part)
+	mux.HandleFunc("GET /version", p.version)
+	return mux
+}
+
+// preview serves PreviewHandler.
+type preview struct {
+	fsys fs.FS
+	opts PreviewOptions
+}
+
+// previewCIDRef finds cid: URLs in rendered HTML, to point them at the
+// part endpoint.
+var previewCIDRef = regexp.MustCompile(`cid:([^"'\s)>]+)`)
+
+// previewPage lists the templates, or shows one of them.
+var previewPage = htmltmpl.Must(htmltmpl.New("preview").Parse(`<!doctype html>
+<html><head><meta charset="utf-8"><title>{{if .Name}}{{.Name}} - {{end}}email preview</title>
+<style>
+body{font-family:sans-serif;margin:1em 2em}
+iframe{width:100%;height:60vh;border:1px solid #ccc}
+pre{background:#f6f6f6;padding:1em;white-space:pre-wrap}
+.err{color:#b00}
+</style></head><body>
+{{if .Name}}<p><a href="./">All templates</a></p><h1>{{.Name}}</h1>{{else}}<h1>Templates</h1>{{end}}
+{{if .Err}}<pre class="err">{{.Err}}</pre>{{end}}
+{{if .Names}}<ul>{{range .Names}}<li><a href="view?name={{.}}">{{.}}</a></li>{{end}}</ul>{{end}}
+{{if .Rendered}}
+<p><a href="render?name={{.Name}}&amp;format=eml">Download .eml</a></p>
+{{if .HTML}}<h2>HTML</h2><iframe src="render?name={{.Name}}&amp;format=html"></iframe>{{end}}
+{{if .Plain}}<h2>Plain text</h2><pre>{{.Plain}}</pre>{{end}}
+{{end}}
+<script>
+(function(){var v={{.Version}};setInterval(function(){
+fetch("version").then(function(r){return r.text()}).then(function(t){
+if(t!==v){location.reload()}}).catch(function(){})},{{.Poll}})})();
+</script>
+</body></html>
+`))
+
+// previewData is the data of previewPage.
+type previewData struct {
+	Name     string
+	Names    []string
+	Err      string
+	Rendered bool
+	HTML     bool
+	Plain    string
+	Version  string
+	Poll     int64 // milliseconds
+}
+
+// index lists the templates.
+func (p *preview) index(w http.ResponseWriter, r *http.Request) {
+	d := p.page("")
+	ts, err := LoadTemplatesWithOptions(p.fsys, p.opts.Load)
+	if err != nil {
+		d.Err = err.Error()
+	} else {
+		d.Names = ts.Names()
+	}
+	p.write(w, d)
+}
+
+// view shows the template named by the name query parameter.
+func (p *preview) view(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	d := p.page(name)
+	msg, err := p.message(name)
+	if err != nil {
+		d.Err = err.Error()
+	} else {
+		d.Rendered = true
+		d.HTML = len(msg.HTML) > 0
+		d.Plain = string(msg.Plain)
+	}
+	p.write(w, d)
+}
+
+// render serves one rendering of a template: format "html", "text" or
+// "eml".
+func (p *preview) render(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	msg, err := p.message(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	switch r.URL.Query().Get("format") {
+	case "html":
+		html := previewCIDRef.ReplaceAllStringFunc(string(msg.HTML), func(ref string) string {
+			return "part?name=" + url.QueryEscape(name) +
+				"&amp;cid=" + url.QueryEscape(strings.TrimPrefix(ref, "cid:"))
+		})
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = io.WriteString(w, html)
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write(msg.Plain)
+	case "eml":
+		raw, err := internal.BuildMIME(r.Context(), msg, internal.BuildOptions{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "message/rfc822")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType(
+			"attachment", map[string]string{"filename": path.Base(name) + ".eml"}))
+		_, _ = w.Write(raw)
+	default:
+		http.Error(w, "format must be html, text or eml", http.StatusBadRequest)
+	}
+}
+
+// part serves the inline image with the content ID given by the cid
+// query parameter.
+func (p *preview) part(w http.ResponseWriter, r *http.Request) {
+	msg, err := p.message(r.URL.Query().Get("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	cid := r.URL.Query().Get("cid")
+	for _, a := range msg.Attach {
+		if a.ContentID != cid || a.Reader == nil {
+			continue
+		}
+		w.Header().Set("Content-Type", a.ContentType)
+		_, _ = io.Copy(w, a.Reader)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// version serves the fingerprint of the files in fsys.
+func (p *preview) version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = io.WriteString(w, p.fingerprint())
+}
+
+// message renders the template name with its sample data into a copy
+// of the base message.
+func (p *preview) message(name string) (types.Message, error) {
+	if name == "" {
+		return types.Message{}, errors.New("missing template name")
+	}
+	ts, err := LoadTemplatesWithOptions(p.fsys, p.opts.Load)
+	if err != nil {
+		return types.Message{}, err
+	}
+	var data any
+	sample, err := fs.ReadFile(p.fsys, name+".sample.json")
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(sample, &data); err != nil {
+			return types.Message{}, fmt.Errorf("%s.sample.json: %w", name, err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return types.Message{}, err
+	}
+	base := p.opts.Base
+	if base.From.Mail == "" {
+		base.From = types.Address{Name: "Preview", Mail: "preview@example.com"}
+	}
+	if len(base.To) == 0 {
+		base.To = []types.Address{{Mail: "recipient@example.com"}}
+	}
+	if base.Subject == "" {
+		base.Subject = name
+	}
+	return ts.WithAssets(p.fsys).RenderMessage(name, data, base)
+}
+
+// page returns the page data common to all pages.
+func (p *preview) page(name string) previewData {
+	return previewData{
+		Name:    name,
+		Version: p.fingerprint(),
+		Poll:    p.opts.Poll.Milliseconds(),
+	}
+}
+
+// write renders the page d.
+func (p *preview) write(w http.ResponseWriter, d previewData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if d.Err != "" {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	_ = previewPage.Execute(w, d)
+}
+
+// fingerprint hashes the names and contents of the files in fsys, so
+// pages can tell when to reload.
+func (p *preview) fingerprint() string {
+	h := sha256.New()
+	_ = fs.WalkDir(p.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		b, err := fs.ReadFile(p.fsys, name)
+		if err != nil {
+			return nil
+		}
+		_, _ = io.WriteString(h, name+"\x00")
+		sum := sha256.Sum256(b)
+		h.Write(sum[:])
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
@@ -0,0 +1,72 @@
+package email
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPreviewHandler(t *testing.T) {
+	mfs := fstest.MapFS{
+		"mail/welcome.html.tmpl":   {Data: []byte(`<p>Hi {{.Name}}</p><img src="{{cid "logo"}}">`)},
+		"mail/welcome.txt.tmpl":    {Data: []byte("Hi {{.Name}}")},
+		"mail/welcome.sample.json": {Data: []byte(`{"Name": "Ada"}`)},
+		"logo.png":                 {Data: []byte("\x89PNG\r\n\x1a\n")},
+		"broken.txt.tmpl":          {Data: []byte("{{len 3}}")},
+	}
+	srv := httptest.NewServer(PreviewHandler(mfs, PreviewOptions{}))
+	defer srv.Close()
+	get := func(path string) (int, string, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, resp.Header.Get("Content-Type"), string(b)
+	}
+
+	code, _, body := get("/")
+	if code != 200 || !strings.Contains(body, `view?name=mail%2fwelcome`) ||
+		!strings.Contains(body, `view?name=broken`) {
+		t.Fatalf("index: %d %s", code, body)
+	}
+	code, _, body = get("/view?name=mail/welcome")
+	if code != 200 || !strings.Contains(body, "<pre>Hi Ada</pre>") ||
+		!strings.Contains(body, "format=html") {
+		t.Fatalf("view: %d %s", code, body)
+	}
+	_, _, body = get("/render?name=mail/welcome&format=html")
+	if !strings.Contains(body, "<p>Hi Ada</p>") ||
+		!strings.Contains(body, `src="part?name=mail%2Fwelcome&amp;cid=logo%40template"`) {
+		t.Fatalf("html: %s", body)
+	}
+	code, ctype, body := get("/part?name=mail/welcome&cid=logo@template")
+	if code != 200 || ctype != "image/png" || !strings.HasPrefix(body, "\x89PNG") {
+		t.Fatalf("part: %d %s %q", code, ctype, body)
+	}
+	code, ctype, body = get("/render?name=mail/welcome&format=eml")
+	if code != 200 || ctype != "message/rfc822" ||
+		!strings.Contains(body, "Subject: mail/welcome") ||
+		!strings.Contains(body, "To: recipient@example.com") {
+		t.Fatalf("eml: %d %s %s", code, ctype, body)
+	}
+	code, _, body = get("/view?name=broken")
+	if code != 500 || !strings.Contains(body, `class="err"`) {
+		t.Fatalf("broken: %d %s", code, body)
+	}
+
+	_, _, v1 := get("/version")
+	mfs["mail/welcome.txt.tmpl"] = &fstest.MapFile{Data: []byte("Hello {{.Name}}")}
+	_, _, v2 := get("/version")
+	if v1 == v2 || v1 == "" {
+		t.Fatalf("version did not change: %q %q", v1, v2)
+	}
+	if _, _, body = get("/render?name=mail/welcome&format=text"); body != "Hello Ada" {
+		t.Fatalf("text after edit: %q", body)
+	}
+}
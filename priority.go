@@ -0,0 +1,288 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// ErrPriorityMailerClosed is returned by PriorityMailer.Send after Close.
+var ErrPriorityMailerClosed = errors.New("email: priority mailer closed")
+
+// Priority is the lane a message is sent in; see PriorityMailer.
+type Priority int
+
+// Priorities, from most to least urgent. The zero value is
+// PriorityNormal.
+const (
+	PriorityHigh   Priority = 1  // e.g. password resets, sign-in codes
+	PriorityNormal Priority = 0  // e.g. receipts, notifications
+	PriorityLow    Priority = -1 // e.g. newsletters, digests
+)
+
+// lanes lists the priorities in dispatch order.
+var lanes = [...]Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+// WithPriority sets the lane of a send through a PriorityMailer. Other
+// mailers ignore it.
+//
+// Parameters:
+//   - p: The priority.
+//
+// Returns:
+//   - Option: The option.
+func WithPriority(p Priority) Option {
+	return func(c *SendConfig) { c.Priority = p }
+}
+
+// PriorityConfig configures a PriorityMailer.
+type PriorityConfig struct {
+	// Workers is the number of concurrent sends to the wrapped mailer;
+	// defaults to 1.
+	Workers int
+	// Rates gives each lane its own budget, so a newsletter burst
+	// cannot use up the budget of password resets. The budget is
+	// acquired for the message at the head of the lane while it waits
+	// in the queue, never by a worker, so a lane waiting for its budget
+	// holds up no other lane. A message removed from the queue leaves
+	// the budget it was given to the next one. A lane without a limiter
+	// is not paced.
+	Rates map[Priority]RateLimiter
+}
+
+// PriorityMailer is a Mailer that dispatches sends to another Mailer
+// through a fixed number of workers, always taking the queued message
+// of the highest priority (WithPriority) first. A high-priority message
+// thus overtakes every queued lower-priority one; sends already in
+// progress are not interrupted. Send blocks until its message is sent.
+type PriorityMailer struct {
+	next  Mailer
+	rates map[Priority]RateLimiter
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queues map[Priority][]*priorityJob
+	ready  map[Priority]bool // the paced lane holds budget for one send
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// priorityJob is a queued send.
+type priorityJob struct {
+	ctx   context.Context
+	prio  Priority
+	msg   types.Message
+	opts  []Option
+	taken bool
+	done  chan error
+}
+
+// NewPriorityMailer creates a priority mailer sending through next and
+// starts its workers. Call Close to stop them.
+//
+// Parameters:
+//   - next: The mailer that sends the messages.
+//   - cfg: The priority config.
+//
+// Returns:
+//   - *PriorityMailer: The priority mailer.
+func NewPriorityMailer(next Mailer, cfg PriorityConfig) *PriorityMailer {
+	p := &PriorityMailer{
+		next:   next,
+		rates:  cfg.Rates,
+		queues: map[Priority][]*priorityJob{},
+		ready:  map[Priority]bool{},
+	}
+	p.cond = sync.NewCond(&p.mu)
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	p.wg.Add(workers)
+	for range workers {
+		go p.work()
+	}
+	for _, prio := range lanes {
+		if rl := cfg.Rates[prio]; rl != nil {
+			p.wg.Add(1)
+			go p.pace(prio, rl)
+		}
+	}
+	return p
+}
+
+// Send queues msg in its lane and waits until a worker has sent it,
+// once the lane's rate budget allows. Priorities above PriorityHigh or
+// below PriorityLow are clamped.
+//
+// Parameters:
+//   - ctx: The context; ending it while queued removes the message.
+//   - msg: The message.
+//   - opts: The options, passed on to the wrapped mailer.
+//
+// Returns:
+//   - error: The error of the wrapped mailer, ctx.Err(), or
+//     ErrPriorityMailerClosed.
+func (p *PriorityMailer) Send(ctx context.Context, msg types.Message, opts ...Option) error {
+	var cfg SendConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	prio := min(max(cfg.Priority, PriorityLow), PriorityHigh)
+	job := &priorityJob{ctx: ctx, prio: prio, msg: msg, opts: opts, done: make(chan error, 1)}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrPriorityMailerClosed
+	}
+	p.queues[prio] = append(p.queues[prio], job)
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	select {
+	case err := <-job.done:
+		return err
+	case <-ctx.Done():
+	}
+	p.mu.Lock()
+	if !job.taken {
+		p.remove(job)
+		p.cond.Broadcast()
+		p.mu.Unlock()
+		return ctx.Err()
+	}
+	p.mu.Unlock()
+	return <-job.done
+}
+
+// Queued returns the number of messages waiting in lane prio.
+//
+// Parameters:
+//   - prio: The priority.
+//
+// Returns:
+//   - int: The queue length.
+func (p *PriorityMailer) Queued(prio Priority) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.queues[prio])
+}
+
+// Close stops accepting messages, lets the workers send the ones already
+// queued and waits for them to finish.
+//
+// Returns:
+//   - error: Always nil.
+func (p *PriorityMailer) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	p.wg.Wait()
+	return nil
+}
+
+// work sends queued messages, highest priority first, until Close.
+func (p *PriorityMailer) work() {
+	defer p.wg.Done()
+	for {
+		job := p.take()
+		if job == nil {
+			return
+		}
+		job.done <- p.next.Send(job.ctx, job.msg, job.opts...)
+	}
+}
+
+// pace acquires the budget of the paced lane prio, one send at a time,
+// for the message at its head. The head's context bounds the wait, so a
+// cancelled message does not hold up the next; the budget stays with
+// the lane until a worker takes a message. pace returns once the
+// mailer is closed and the lane is empty.
+func (p *PriorityMailer) pace(prio Priority, rl RateLimiter) {
+	defer p.wg.Done()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		q := p.queues[prio]
+		if len(q) > 0 && q[0].ctx.Err() != nil {
+			// Cancelled; its Send returns ctx.Err().
+			p.queues[prio] = q[1:]
+			p.cond.Broadcast()
+			continue
+		}
+		switch {
+		case len(q) == 0 && p.closed:
+			return
+		case len(q) == 0 || p.ready[prio]:
+			p.cond.Wait()
+			continue
+		}
+		job := q[0]
+		p.mu.Unlock()
+		err := rl.Acquire(job.ctx)
+		p.mu.Lock()
+		switch {
+		case err == nil:
+			p.ready[prio] = true
+			p.cond.Broadcast()
+		case job.ctx.Err() == nil && !job.taken:
+			// Not allowed, e.g. over quota: fail the message.
+			p.remove(job)
+			job.taken = true
+			job.done <- err
+			p.cond.Broadcast()
+		}
+	}
+}
+
+// remove deletes job from its queue. p.mu must be held.
+func (p *PriorityMailer) remove(job *priorityJob) {
+	q := p.queues[job.prio]
+	for i, j := range q {
+		if j == job {
+			p.queues[job.prio] = append(q[:i:i], q[i+1:]...)
+			return
+		}
+	}
+}
+
+// take waits for and takes the queued job of the highest priority,
+// skipping paced lanes without budget. It returns nil once the mailer
+// is closed and the queues are empty.
+func (p *PriorityMailer) take() *priorityJob {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		for _, prio := range lanes {
+			q := p.queues[prio]
+			if len(q) == 0 || p.rates[prio] != nil && !p.ready[prio] {
+				continue
+			}
+			job := q[0]
+			p.queues[prio] = q[1:]
+			job.taken = true
+			if p.rates[prio] != nil {
+				p.ready[prio] = false
+				p.cond.Broadcast() // wake the lane's pace
+			}
+			return job
+		}
+		if p.closed && p.empty() {
+			return nil
+		}
+		p.cond.Wait()
+	}
+}
+
+// empty reports whether no message is queued. p.mu must be held.
+func (p *PriorityMailer) empty() bool {
+	for _, q := range p.queues {
+		if len(q) > 0 {
+			return false
+		}
+	}
+	return true
+}
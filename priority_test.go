@@ -0,0 +1,201 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// gateMailer records subjects and blocks each send until gate yields.
+type gateMailer struct {
+	gate    chan struct{}
+	entered atomic.Int32 // sends waiting at the gate or past it
+	mu      sync.Mutex
+	sent    []string
+}
+
+func (m *gateMailer) Send(ctx context.Context, msg types.Message, opts ...Option) error {
+	m.entered.Add(1)
+	<-m.gate
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg.Subject)
+	return nil
+}
+
+func TestPriorityMailerOrder(t *testing.T) {
+	next := &gateMailer{gate: make(chan struct{})}
+	p := NewPriorityMailer(next, PriorityConfig{})
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	send := func(subject string, prio Priority) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.Send(ctx, types.Message{Subject: subject}, WithPriority(prio)); err != nil {
+				t.Errorf("send %s: %v", subject, err)
+			}
+		}()
+	}
+	waitQueued := func(prio Priority, n int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for p.Queued(prio) != n {
+			if time.Now().After(deadline) {
+				t.Fatalf("lane %d: %d queued, want %d", prio, p.Queued(prio), n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// The first send occupies the only worker.
+	send("busy", PriorityLow)
+	waitQueued(PriorityLow, 0)
+	time.Sleep(10 * time.Millisecond)
+	send("news-1", PriorityLow)
+	waitQueued(PriorityLow, 1)
+	send("news-2", PriorityLow)
+	waitQueued(PriorityLow, 2)
+	send("receipt", PriorityNormal)
+	waitQueued(PriorityNormal, 1)
+	send("reset", PriorityHigh)
+	waitQueued(PriorityHigh, 1)
+
+	close(next.gate)
+	wg.Wait()
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"busy", "reset", "receipt", "news-1", "news-2"}
+	for i := range want {
+		if i >= len(next.sent) || next.sent[i] != want[i] {
+			t.Fatalf("sent = %v, want %v", next.sent, want)
+		}
+	}
+	if err := p.Send(ctx, types.Message{}); err != ErrPriorityMailerClosed {
+		t.Fatalf("send after close = %v", err)
+	}
+}
+
+func TestPriorityMailerCancelQueued(t *testing.T) {
+	next := &gateMailer{gate: make(chan struct{})}
+	p := NewPriorityMailer(next, PriorityConfig{
+		Rates: map[Priority]RateLimiter{PriorityLow: NewTokenBucket(1000, 10)},
+	})
+	go p.Send(context.Background(), types.Message{Subject: "busy"})
+	for next.entered.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := p.Send(ctx, types.Message{Subject: "late"}, WithPriority(PriorityLow)); err != context.DeadlineExceeded {
+		t.Fatalf("err = %v", err)
+	}
+	if n := p.Queued(PriorityLow); n != 0 {
+		t.Fatalf("cancelled message still queued: %d", n)
+	}
+	close(next.gate)
+	p.Close()
+	if len(next.sent) != 1 {
+		t.Fatalf("sent = %v", next.sent)
+	}
+}
+
+func TestPriorityMailerCancelQueuedKeepsBudget(t *testing.T) {
+	next := &gateMailer{gate: make(chan struct{})}
+	// One token; the next would take over 15 minutes.
+	p := NewPriorityMailer(next, PriorityConfig{
+		Rates: map[Priority]RateLimiter{PriorityLow: NewTokenBucket(0.001, 1)},
+	})
+	go p.Send(context.Background(), types.Message{Subject: "busy"})
+	for next.entered.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := p.Send(ctx, types.Message{Subject: "late"}, WithPriority(PriorityLow)); err != context.DeadlineExceeded {
+		t.Fatalf("err = %v", err)
+	}
+
+	// The cancelled send left the token for this one.
+	close(next.gate)
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := p.Send(ctx, types.Message{Subject: "news"}, WithPriority(PriorityLow)); err != nil {
+		t.Fatalf("send after cancel: %v", err)
+	}
+	p.Close()
+	if len(next.sent) != 2 || next.sent[1] != "news" {
+		t.Fatalf("sent = %v", next.sent)
+	}
+}
+
+func TestPriorityMailerPacedLaneDoesNotHoldWorker(t *testing.T) {
+	next := &gateMailer{gate: make(chan struct{})}
+	close(next.gate)
+	// One worker, and budget for one newsletter in over 15 minutes.
+	p := NewPriorityMailer(next, PriorityConfig{
+		Rates: map[Priority]RateLimiter{PriorityLow: NewTokenBucket(0.001, 1)},
+	})
+	defer p.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := p.Send(ctx, types.Message{Subject: "news-1"}, WithPriority(PriorityLow)); err != nil {
+		t.Fatalf("news-1: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- p.Send(ctx, types.Message{Subject: "news-2"}, WithPriority(PriorityLow)) }()
+	deadline := time.Now().Add(2 * time.Second)
+	for p.Queued(PriorityLow) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("news-2 not queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// news-2 waits for its budget in the queue, not in the only worker.
+	rctx, rcancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer rcancel()
+	if err := p.Send(rctx, types.Message{Subject: "reset"}, WithPriority(PriorityHigh)); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if err := p.Send(rctx, types.Message{Subject: "receipt"}); err != nil {
+		t.Fatalf("receipt: %v", err)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("news-2: %v", err)
+	}
+	next.mu.Lock()
+	defer next.mu.Unlock()
+	if got := strings.Join(next.sent, " "); got != "news-1 reset receipt" {
+		t.Fatalf("sent %s", got)
+	}
+}
+
+func TestPriorityMailerQuotaError(t *testing.T) {
+	next := &gateMailer{gate: make(chan struct{})}
+	close(next.gate)
+	p := NewPriorityMailer(next, PriorityConfig{
+		Rates: map[Priority]RateLimiter{PriorityLow: NewWarmupLimiter(WarmupConfig{Schedule: []int{1, 1}})},
+	})
+	defer p.Close()
+	ctx := context.Background()
+	if err := p.Send(ctx, types.Message{Subject: "a"}, WithPriority(PriorityLow)); err != nil {
+		t.Fatalf("a: %v", err)
+	}
+	if err := p.Send(ctx, types.Message{Subject: "b"}, WithPriority(PriorityLow)); !errors.Is(err, ErrWarmupQuota) {
+		t.Fatalf("b: got %v, want ErrWarmupQuota", err)
+	}
+	if n := p.Queued(PriorityLow); n != 0 {
+		t.Fatalf("%d still queued", n)
+	}
+}
@@ -0,0 +1,3 @@
+// Package proxy provides dialers that tunnel connections through SOCKS5
+// and HTTP CONNECT proxies, for use as smtp.SMTPConfig.Dialer.
+package proxy
@@ -0,0 +1,190 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listen serves each accepted connection with fn.
+func listen(t *testing.T, fn func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				fn(c)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// target greets first, like an SMTP server, then echoes.
+func target(t *testing.T) string {
+	return listen(t, func(c net.Conn) {
+		io.WriteString(c, "220 hello\r\n")
+		io.Copy(c, c)
+	})
+}
+
+// splice connects c to addr in both directions.
+func splice(c net.Conn, addr string) {
+	up, err := net.Dial("tcp", addr)
+	if err != nil {
+		return
+	}
+	defer up.Close()
+	go io.Copy(up, c)
+	io.Copy(c, up)
+}
+
+// checkTunnel verifies the greeting and an echo through c.
+func checkTunnel(t *testing.T, c net.Conn) {
+	t.Helper()
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(2 * time.Second))
+	r := bufio.NewReader(c)
+	if l, err := r.ReadString('\n'); err != nil || l != "220 hello\r\n" {
+		t.Fatalf("unexpected greeting %q %v", l, err)
+	}
+	io.WriteString(c, "EHLO x\r\n")
+	if l, err := r.ReadString('\n'); err != nil || l != "EHLO x\r\n" {
+		t.Fatalf("unexpected echo %q %v", l, err)
+	}
+}
+
+// socks5Server is a minimal SOCKS5 proxy requiring user "u", password
+// "p". It records the requested destination.
+func socks5Server(t *testing.T, dest *string) string {
+	return listen(t, func(c net.Conn) {
+		b := make([]byte, 2)
+		io.ReadFull(c, b)
+		methods := make([]byte, b[1])
+		io.ReadFull(c, methods)
+		c.Write([]byte{0x05, 0x02})
+		io.ReadFull(c, b[:2])
+		user := make([]byte, b[1])
+		io.ReadFull(c, user)
+		io.ReadFull(c, b[:1])
+		pass := make([]byte, b[0])
+		io.ReadFull(c, pass)
+		if string(user) != "u" || string(pass) != "p" {
+			c.Write([]byte{0x01, 0x01})
+			return
+		}
+		c.Write([]byte{0x01, 0x00})
+		hdr := make([]byte, 5)
+		io.ReadFull(c, hdr)
+		host := make([]byte, hdr[4])
+		io.ReadFull(c, host)
+		port := make([]byte, 2)
+		io.ReadFull(c, port)
+		*dest = string(host) + ":" + strconv.Itoa(int(binary.BigEndian.Uint16(port)))
+		if string(host) != "localhost" {
+			c.Write([]byte{0x05, 0x04, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		c.Write([]byte{0x05, 0x00, 0x00, 0x01, 127, 0, 0, 1, 0, 0})
+		splice(c, "127.0.0.1:"+strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+	})
+}
+
+func TestSOCKS5(t *testing.T) {
+	_, port, _ := net.SplitHostPort(target(t))
+	var dest string
+	u, _ := url.Parse("socks5://u:p@" + socks5Server(t, &dest))
+	d, err := FromURL(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := d.DialContext(context.Background(), "tcp", "localhost:"+port)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	checkTunnel(t, c)
+	if dest != "localhost:"+port {
+		t.Fatalf("proxy asked for %q", dest)
+	}
+
+	if _, err := d.DialContext(context.Background(), "tcp", "mx.invalid:25"); err == nil ||
+		!strings.Contains(err.Error(), "host unreachable") {
+		t.Fatalf("expected host unreachable, got %v", err)
+	}
+	u.User = url.UserPassword("u", "wrong")
+	d, _ = FromURL(u, nil)
+	if _, err := d.DialContext(context.Background(), "tcp", "localhost:"+port); err == nil ||
+		!strings.Contains(err.Error(), "authentication failed") {
+		t.Fatalf("expected auth failure, got %v", err)
+	}
+}
+
+func TestHTTPConnect(t *testing.T) {
+	addr := target(t)
+	var auth string
+	proxyAddr := listen(t, func(c net.Conn) {
+		req, err := http.ReadRequest(bufio.NewReader(c))
+		if err != nil {
+			return
+		}
+		auth = req.Header.Get("Proxy-Authorization")
+		if req.Method != http.MethodConnect || req.Host != addr {
+			io.WriteString(c, "HTTP/1.1 403 Forbidden\r\n\r\n")
+			return
+		}
+		io.WriteString(c, "HTTP/1.1 200 Connection established\r\n\r\n")
+		splice(c, addr)
+	})
+	u, _ := url.Parse("http://u:p@" + proxyAddr)
+	d, err := FromURL(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := d.DialContext(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	checkTunnel(t, c)
+	if auth != "Basic dTpw" {
+		t.Fatalf("unexpected Proxy-Authorization %q", auth)
+	}
+
+	if _, err := d.DialContext(context.Background(), "tcp", "mx.invalid:25"); err == nil ||
+		!strings.Contains(err.Error(), "403 Forbidden") {
+		t.Fatalf("expected 403, got %v", err)
+	}
+	if _, err := FromURL(&url.URL{Scheme: "ftp", Host: "x"}, nil); err == nil {
+		t.Fatalf("expected unsupported scheme")
+	}
+}
+
+func TestDialContextCanceled(t *testing.T) {
+	// A proxy that never answers.
+	addr := listen(t, func(c net.Conn) { io.Copy(io.Discard, c) })
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := SOCKS5(addr, nil, nil).DialContext(ctx, "tcp", "localhost:25")
+	// The connection deadline set from ctx may fire just before ctx.
+	if !errors.Is(err, os.ErrDeadlineExceeded) && !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+}
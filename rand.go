@@ -0,0 +1,47 @@
+package email
+
+import (
+	"io"
+
+	"github.com/aatuh/email/v2/internal"
+)
+
+// RandSource supplies random bytes, like crypto/rand.Reader, the
+// default. It must be safe for concurrent use.
+type RandSource interface {
+	io.Reader
+}
+
+// SetRandSource replaces the random source of the module: MIME
+// boundaries, generated Message-IDs, backoff and pool jitter, DKIM
+// signatures and keys, MDNs and upload keys. Builds that fail to read
+// from it fail; jitter falls back to none. nil restores
+// crypto/rand.Reader. The source is process-wide; set it at start-up,
+// e.g. to route all randomness through a certified DRBG.
+//
+// Parameters:
+//   - r: The random source.
+//
+// Returns:
+//   - func(): A function that restores the previous source.
+func SetRandSource(r RandSource) func() {
+	var rd io.Reader
+	if r != nil {
+		rd = r
+	}
+	old := internal.SetRand(rd)
+	return func() { internal.SetRand(old) }
+}
+
+// WithRandSource sets the random source for the MIME boundaries and
+// the generated Message-ID of this send, instead of the one set with
+// SetRandSource.
+//
+// Parameters:
+//   - r: The random source.
+//
+// Returns:
+//   - Option: The option.
+func WithRandSource(r RandSource) Option {
+	return func(c *SendConfig) { c.Rand = r }
+}
@@ -0,0 +1,45 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// zeroSource yields zero bytes.
+type zeroSource struct{}
+
+func (zeroSource) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestSetRandSource(t *testing.T) {
+	restore := SetRandSource(zeroSource{})
+	b := ExponentialBackoff(3, 100*time.Millisecond, time.Second, false)
+	d, _ := b.Next(2)
+	restore()
+	if d != 100*time.Millisecond {
+		t.Fatalf("half jitter with zero source = %v, want 100ms", d)
+	}
+
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("hi"),
+	}
+	built, err := Build(context.Background(), msg, WithRandSource(zeroSource{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := built.MessageID(); !strings.HasSuffix(id, strings.Repeat("00", 12)+"@example.com>") {
+		t.Fatalf("Message-ID %q not from the source", id)
+	}
+	if !bytes.Contains(built.Bytes(), []byte(built.MessageID())) {
+		t.Fatal("Message-ID missing from message")
+	}
+}
@@ -1,10 +1,21 @@
 package email
 
 import (
+	"context"
 	"sync"
 	"time"
 )
 
+// RateLimiter paces sends. Adapters call Acquire once per Send before
+// connecting; an error fails the send without attempting delivery.
+// TokenBucket and WarmupLimiter implement it.
+type RateLimiter interface {
+	// Acquire blocks until a send may proceed. It returns an error if
+	// ctx ends first or the send is not allowed, e.g. when a quota is
+	// used up.
+	Acquire(ctx context.Context) error
+}
+
 // TokenBucket is a simple thread-safe token bucket.
 type TokenBucket struct {
 	rate   float64 // tokens per second
@@ -46,27 +57,49 @@ func NewTokenBucket(rate float64, burst int) *TokenBucket {
 // Returns:
 //   - void: The token bucket is blocked until one token is available.
 func (tb *TokenBucket) Wait() {
-	tb.mu.Lock()
-	defer tb.mu.Unlock()
+	_ = tb.Acquire(context.Background())
+}
 
-	for {
-		now := time.Now()
-		dt := now.Sub(tb.last).Seconds()
-		tb.last = now
+// Acquire takes one token, waiting until it is available or ctx ends.
+// Waiters are served in arrival order. It implements RateLimiter.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - error: ctx.Err() if ctx ends first; the token is returned.
+func (tb *TokenBucket) Acquire(ctx context.Context) error {
+	if tb == nil {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// Reserve the token now, going into debt if needed, and sleep until
+	// the debt is paid.
+	tb.mu.Lock()
+	now := time.Now()
+	tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+	tb.last = now
+	if tb.tokens > float64(tb.burst) {
+		tb.tokens = float64(tb.burst)
+	}
+	tb.tokens--
+	wait := time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+	tb.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
 
-		tb.tokens += dt * tb.rate
-		if tb.tokens > float64(tb.burst) {
-			tb.tokens = float64(tb.burst)
-		}
-		if tb.tokens >= 1 {
-			tb.tokens -= 1
-			return
-		}
-		need := 1 - tb.tokens
-		sleep := time.Duration(need/tb.rate*1000) * time.Millisecond
-		if sleep < time.Millisecond {
-			sleep = time.Millisecond
-		}
-		time.Sleep(sleep)
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		tb.mu.Lock()
+		tb.tokens++
+		tb.mu.Unlock()
+		return ctx.Err()
 	}
 }
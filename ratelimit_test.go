@@ -1,6 +1,8 @@
 package email
 
 import (
+    "context"
+    "errors"
     "sync/atomic"
     "testing"
     "time"
@@ -35,3 +37,18 @@ func TestTokenBucketWait(t *testing.T) {
     }
 }
 
+func TestTokenBucketAcquireContext(t *testing.T) {
+    tb := NewTokenBucket(1, 1)
+    if err := tb.Acquire(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+    defer cancel()
+    start := time.Now()
+    if err := tb.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("expected deadline, got %v", err)
+    }
+    if time.Since(start) > 500*time.Millisecond {
+        t.Fatal("Acquire ignored the context")
+    }
+}
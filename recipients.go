@@ -0,0 +1,256 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// Errors of recipient expansion, returned wrapped with the aliases
+// involved.
+var (
+	ErrRecipientLoop  = errors.New("email: recipient alias loop")
+	ErrRecipientLimit = errors.New("email: recipient expansion limit exceeded")
+)
+
+// Default limits of RecipientExpansion.
+const (
+	DefaultMaxRecipients = 1000
+	DefaultMaxAliasDepth = 8
+)
+
+// RecipientResolver expands aliases and groups, such as
+// "team-eng@internal", into the addresses they stand for.
+// Implementations must be safe for concurrent use.
+type RecipientResolver interface {
+	// ResolveRecipient returns the members of addr and true if addr is
+	// an alias, or false if it is a mailbox to deliver to as is.
+	// Members may be aliases themselves.
+	ResolveRecipient(ctx context.Context, addr types.Address) (members []types.Address, ok bool, err error)
+}
+
+// RecipientResolverFunc adapts a function to RecipientResolver.
+type RecipientResolverFunc func(ctx context.Context, addr types.Address) ([]types.Address, bool, error)
+
+// ResolveRecipient implements RecipientResolver.
+//
+// Parameters:
+//   - ctx: The context.
+//   - addr: The address.
+//
+// Returns:
+//   - []types.Address: The members.
+//   - bool: True if addr is an alias.
+//   - error: The error of f.
+func (f RecipientResolverFunc) ResolveRecipient(ctx context.Context, addr types.Address) ([]types.Address, bool, error) {
+	return f(ctx, addr)
+}
+
+// RecipientExpansion configures WithRecipientExpansion.
+type RecipientExpansion struct {
+	Resolver RecipientResolver
+	// MaxRecipients caps the recipients of each of To+Cc+Bcc and
+	// EnvelopeTo after expansion; 0 means DefaultMaxRecipients.
+	MaxRecipients int
+	// MaxDepth caps how deeply aliases may nest; 0 means
+	// DefaultMaxAliasDepth.
+	MaxDepth int
+}
+
+// WithRecipientExpansion replaces aliases among the recipients with
+// their members, as resolved by e.Resolver, before anything else is
+// done with the message. Members of an alias in To are added to To,
+// and so on for Cc, Bcc and EnvelopeTo; addresses that appear more
+// than once are kept once. A loop of aliases fails the send with
+// ErrRecipientLoop, and exceeding a limit with ErrRecipientLimit.
+//
+// Parameters:
+//   - e: The expansion settings.
+//
+// Returns:
+//   - Option: The option.
+func WithRecipientExpansion(e RecipientExpansion) Option {
+	return func(c *SendConfig) { c.Recipients = &e }
+}
+
+// ExpandRecipients applies the WithRecipientExpansion option in opts to
+// msg, as the adapters do before sending. Without it, msg is returned
+// unchanged.
+//
+// Parameters:
+//   - ctx: The context.
+//   - msg: The message.
+//   - opts: The send options that will be used.
+//
+// Returns:
+//   - types.Message: The message with expanded recipients.
+//   - error: The error of the resolver, or an error matching
+//     ErrRecipientLoop or ErrRecipientLimit.
+func ExpandRecipients(ctx context.Context, msg types.Message, opts ...Option) (types.Message, error) {
+	var cfg SendConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	e := cfg.Recipients
+	if e == nil || e.Resolver == nil {
+		return msg, nil
+	}
+	x := &expander{e: *e, seen: map[string]bool{}}
+	if x.e.MaxRecipients <= 0 {
+		x.e.MaxRecipients = DefaultMaxRecipients
+	}
+	if x.e.MaxDepth <= 0 {
+		x.e.MaxDepth = DefaultMaxAliasDepth
+	}
+	out := msg.Clone()
+	var err error
+	for _, list := range []*[]types.Address{&out.To, &out.Cc, &out.Bcc} {
+		if *list, err = x.expandList(ctx, *list); err != nil {
+			return msg, err
+		}
+	}
+	if len(out.EnvelopeTo) > 0 {
+		x.seen, x.n = map[string]bool{}, 0
+		addrs := make([]types.Address, len(out.EnvelopeTo))
+		for i, r := range out.EnvelopeTo {
+			addrs[i] = types.Address{Mail: r}
+		}
+		if addrs, err = x.expandList(ctx, addrs); err != nil {
+			return msg, err
+		}
+		out.EnvelopeTo = out.EnvelopeTo[:0]
+		for _, a := range addrs {
+			out.EnvelopeTo = append(out.EnvelopeTo, a.Mail)
+		}
+	}
+	return out, nil
+}
+
+// expander expands the recipient lists of one message. seen holds the
+// normalized addresses and aliases already taken; n counts the
+// addresses kept.
+type expander struct {
+	e    RecipientExpansion
+	seen map[string]bool
+	n    int
+}
+
+// expandList returns list with its aliases expanded.
+func (x *expander) expandList(ctx context.Context, list []types.Address) ([]types.Address, error) {
+	var out []types.Address
+	for _, a := range list {
+		var err error
+		if out, err = x.expand(ctx, out, a, nil); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// expand appends a, or the members of a if it is an alias, to out.
+// path holds the aliases being expanded, outermost first.
+func (x *expander) expand(
+	ctx context.Context,
+	out []types.Address,
+	a types.Address,
+	path []string,
+) ([]types.Address, error) {
+	if a.Mail == "" {
+		// An empty group carries no address to resolve.
+		return append(out, a), nil
+	}
+	key := normalizeAddr(a.Mail)
+	for _, p := range path {
+		if p == key {
+			return nil, fmt.Errorf("%w: %s -> %s", ErrRecipientLoop,
+				strings.Join(path, " -> "), key)
+		}
+	}
+	if x.seen[key] {
+		return out, nil
+	}
+	members, ok, err := x.e.Resolver.ResolveRecipient(ctx, a)
+	if err != nil {
+		return nil, fmt.Errorf("email: resolve %s: %w", a.Mail, err)
+	}
+	x.seen[key] = true
+	if !ok {
+		if x.n++; x.n > x.e.MaxRecipients {
+			return nil, fmt.Errorf("%w: more than %d recipients",
+				ErrRecipientLimit, x.e.MaxRecipients)
+		}
+		return append(out, a), nil
+	}
+	if len(path) >= x.e.MaxDepth {
+		return nil, fmt.Errorf("%w: %s nested deeper than %d aliases",
+			ErrRecipientLimit, a.Mail, x.e.MaxDepth)
+	}
+	path = append(path, key)
+	for _, m := range members {
+		if m.Group == "" {
+			m.Group = a.Group
+		}
+		if out, err = x.expand(ctx, out, m, path); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// AddressBook is an in-memory RecipientResolver of aliases. Aliases
+// compare like addresses of a SuppressionList: case-insensitively, with
+// internationalized domains in ASCII form.
+type AddressBook struct {
+	mu      sync.RWMutex
+	aliases map[string][]types.Address // normalized alias -> members
+}
+
+// NewAddressBook creates an empty address book.
+//
+// Returns:
+//   - *AddressBook: The address book.
+func NewAddressBook() *AddressBook {
+	return &AddressBook{aliases: map[string][]types.Address{}}
+}
+
+// Set makes alias stand for members, replacing earlier members.
+//
+// Parameters:
+//   - alias: The alias address, e.g. "team-eng@internal".
+//   - members: The members, which may be aliases themselves.
+func (b *AddressBook) Set(alias string, members ...types.Address) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.aliases[normalizeAddr(alias)] = append([]types.Address(nil), members...)
+}
+
+// Remove deletes alias.
+//
+// Parameters:
+//   - alias: The alias address.
+func (b *AddressBook) Remove(alias string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.aliases, normalizeAddr(alias))
+}
+
+// ResolveRecipient returns the members of addr if it is an alias.
+//
+// Parameters:
+//   - ctx: The context.
+//   - addr: The address.
+//
+// Returns:
+//   - []types.Address: The members.
+//   - bool: True if addr is an alias.
+//   - error: Always nil.
+func (b *AddressBook) ResolveRecipient(ctx context.Context, addr types.Address) ([]types.Address, bool, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	members, ok := b.aliases[normalizeAddr(addr.Mail)]
+	return members, ok, nil
+}
@@ -0,0 +1,70 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestExpandRecipients(t *testing.T) {
+	book := NewAddressBook()
+	book.Set("team-eng@internal",
+		types.Address{Name: "Ada", Mail: "ada@example.com"},
+		types.Address{Mail: "leads@internal"})
+	book.Set("Leads@Internal", types.Address{Mail: "grace@example.com"}, types.Address{Mail: "ada@example.com"})
+	msg := types.Message{
+		From:       types.Address{Mail: "app@example.com"},
+		To:         []types.Address{{Mail: "team-eng@internal"}},
+		Cc:         []types.Address{{Mail: "grace@example.com"}, {Mail: "bob@example.com"}},
+		Bcc:        []types.Address{{Mail: "leads@internal"}},
+		EnvelopeTo: []string{"leads@internal", "audit@example.com"},
+		Plain:      []byte("hi"),
+	}
+	ctx := context.Background()
+	got, err := ExpandRecipients(ctx, msg, WithRecipientExpansion(RecipientExpansion{Resolver: book}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []types.Address{{Name: "Ada", Mail: "ada@example.com"}, {Mail: "grace@example.com"}}
+	if !slices.Equal(got.To, want) ||
+		!slices.Equal(got.Cc, []types.Address{{Mail: "bob@example.com"}}) || len(got.Bcc) != 0 {
+		t.Fatalf("to %v cc %v bcc %v", got.To, got.Cc, got.Bcc)
+	}
+	if !slices.Equal(got.EnvelopeTo, []string{"grace@example.com", "ada@example.com", "audit@example.com"}) {
+		t.Fatalf("envelope %v", got.EnvelopeTo)
+	}
+	if msg.To[0].Mail != "team-eng@internal" || len(msg.EnvelopeTo) != 2 {
+		t.Fatalf("message changed: %+v", msg)
+	}
+
+	if got, err := ExpandRecipients(ctx, msg); err != nil || got.To[0] != msg.To[0] {
+		t.Fatalf("without option: %v %v", got.To, err)
+	}
+
+	book.Set("ada@example.com", types.Address{Mail: "team-eng@internal"})
+	_, err = ExpandRecipients(ctx, msg, WithRecipientExpansion(RecipientExpansion{Resolver: book}))
+	if !errors.Is(err, ErrRecipientLoop) {
+		t.Fatalf("loop: %v", err)
+	}
+	book.Remove("ada@example.com")
+
+	for _, e := range []RecipientExpansion{
+		{Resolver: book, MaxRecipients: 2},
+		{Resolver: book, MaxDepth: 1},
+	} {
+		if _, err := ExpandRecipients(ctx, msg, WithRecipientExpansion(e)); !errors.Is(err, ErrRecipientLimit) {
+			t.Fatalf("%+v: %v", e, err)
+		}
+	}
+
+	boom := errors.New("directory down")
+	failing := RecipientResolverFunc(func(ctx context.Context, a types.Address) ([]types.Address, bool, error) {
+		return nil, false, boom
+	})
+	if _, err := ExpandRecipients(ctx, msg, WithRecipientExpansion(RecipientExpansion{Resolver: failing})); !errors.Is(err, boom) {
+		t.Fatalf("resolver error: %v", err)
+	}
+}
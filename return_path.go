@@ -0,0 +1,56 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// ErrReturnPathMisaligned is wrapped by the error of
+// CheckReturnPathAlignment.
+var ErrReturnPathMisaligned = errors.New("email: envelope sender not aligned with From domain")
+
+// CheckReturnPathAlignment verifies that the envelope sender (the
+// Return-Path, checked by SPF) is aligned with the From domain, which
+// DMARC needs for SPF to count. The envelope sender is taken from
+// WithFanOut's VERP address, WithEnvelopeFrom or Message.EnvelopeFrom,
+// in that order; without any the From address is used and is aligned.
+//
+// Parameters:
+//   - msg: The message to check.
+//   - opts: The send options that will be used.
+//
+// Returns:
+//   - error: An error wrapping ErrReturnPathMisaligned if the domains
+//     are not aligned.
+func CheckReturnPathAlignment(msg types.Message, opts ...Option) error {
+	var cfg SendConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	env, from := returnPathDomains(msg, &cfg)
+	if env == "" || internal.DomainsAligned(env, from) {
+		return nil
+	}
+	return fmt.Errorf("%w: envelope sender domain %q, From domain %q",
+		ErrReturnPathMisaligned, env, from)
+}
+
+// returnPathDomains returns the envelope sender domain, "" if the From
+// address is used, and the From domain.
+func returnPathDomains(msg types.Message, cfg *SendConfig) (string, string) {
+	env := msg.EnvelopeFrom
+	if cfg.EnvelopeFrom != "" {
+		env = cfg.EnvelopeFrom
+	}
+	if cfg.FanOut != nil && cfg.FanOut.VERP != "" {
+		env = cfg.FanOut.VERP
+	}
+	from := domainOf(msg.From.Mail)
+	if env == "" {
+		return "", from
+	}
+	return domainOf(env), from
+}
@@ -0,0 +1,62 @@
+package email
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestCheckReturnPathAlignment(t *testing.T) {
+	msg := types.Message{From: types.Address{Mail: "news@example.com"}}
+	tests := []struct {
+		name     string
+		envelope string
+		opts     []Option
+		aligned  bool
+	}{
+		{"from address", "", nil, true},
+		{"subdomain", "bounces@mail.example.com", nil, true},
+		{"esp domain", "bounces@esp.example.net", nil, false},
+		{"option wins", "bounces@esp.example.net",
+			[]Option{WithEnvelopeFrom("b@bounce.example.com")}, true},
+		{"verp", "", []Option{WithFanOut(FanOut{VERP: "b@esp.example.net"})}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := msg
+			m.EnvelopeFrom = tt.envelope
+			err := CheckReturnPathAlignment(m, tt.opts...)
+			if (err == nil) != tt.aligned || err != nil && !errors.Is(err, ErrReturnPathMisaligned) {
+				t.Fatalf("err = %v, want aligned %v", err, tt.aligned)
+			}
+		})
+	}
+}
+
+func TestLintReturnPath(t *testing.T) {
+	msg := types.Message{
+		From:         types.Address{Mail: "news@example.com"},
+		EnvelopeFrom: "bounces@esp.example.net",
+		Plain:        []byte("Hi"),
+	}
+	severity := func(opts ...Option) Severity {
+		t.Helper()
+		for _, f := range Lint(msg, opts...) {
+			if f.Code == LintReturnPathMisaligned {
+				return f.Severity
+			}
+		}
+		t.Fatal("no return-path finding")
+		return 0
+	}
+	if s := severity(); s != SeverityError {
+		t.Fatalf("without DKIM: %v", s)
+	}
+	if s := severity(WithDKIM(types.DKIMConfig{Domain: "example.com"})); s != SeverityWarning {
+		t.Fatalf("with aligned DKIM: %v", s)
+	}
+	if fs := Lint(msg, WithEnvelopeFrom("b@example.com")); lintCodes(fs)[LintReturnPathMisaligned] {
+		t.Fatalf("unexpected finding %v", fs)
+	}
+}
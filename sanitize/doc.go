@@ -0,0 +1,7 @@
+// Package sanitize cleans untrusted HTML for embedding in email bodies
+// using an allow-list of elements, attributes and URL schemes. Scripts,
+// event handlers, forms and unsafe URLs are removed; disallowed markup
+// is dropped while its text is kept. Use it directly, through the
+// "sanitizeHTML" template func of email.TemplateSet, or on the whole
+// body with email.WithHTMLSanitizer.
+package sanitize
@@ -0,0 +1,339 @@
+package sanitize
+
+import (
+	"html"
+	"slices"
+	"strings"
+)
+
+// Policy is an allow-list of elements, attributes and URL schemes.
+// Attributes starting with "on" are never allowed.
+type Policy struct {
+	// Elements maps allowed element names to the attributes allowed on
+	// them in addition to GlobalAttributes.
+	Elements map[string][]string
+	// GlobalAttributes are allowed on every allowed element.
+	GlobalAttributes []string
+	// URLSchemes are the schemes allowed in URL attributes such as href
+	// and src. Relative URLs are always allowed.
+	URLSchemes []string
+}
+
+// DefaultPolicy returns a policy suited to user content in email:
+// formatting, lists, tables, links and images, with inline styles that
+// do not load resources. Link schemes are limited to http, https,
+// mailto, tel and cid.
+//
+// Returns:
+//   - *Policy: The policy.
+func DefaultPolicy() *Policy {
+	p := &Policy{
+		Elements: map[string][]string{
+			"a":          {"href", "name"},
+			"img":        {"src", "alt"},
+			"blockquote": {"cite"},
+			"q":          {"cite"},
+			"font":       {"face", "size"},
+			"ol":         {"start", "type"},
+			"td":         {"colspan", "rowspan", "nowrap"},
+			"th":         {"colspan", "rowspan", "nowrap", "scope"},
+			"table":      {"border", "cellpadding", "cellspacing"},
+		},
+		GlobalAttributes: []string{
+			"align", "bgcolor", "class", "color", "dir", "height", "lang",
+			"style", "title", "valign", "width",
+		},
+		URLSchemes: []string{"http", "https", "mailto", "tel", "cid"},
+	}
+	for _, e := range []string{
+		"abbr", "b", "br", "caption", "center", "code", "col", "colgroup",
+		"dd", "del", "div", "dl", "dt", "em", "h1", "h2", "h3", "h4", "h5",
+		"h6", "hr", "i", "ins", "kbd", "li", "p", "pre", "s", "small",
+		"span", "strike", "strong", "sub", "sup", "tbody", "tfoot", "thead",
+		"tr", "tt", "u", "ul",
+	} {
+		if _, ok := p.Elements[e]; !ok {
+			p.Elements[e] = nil
+		}
+	}
+	return p
+}
+
+var defaultPolicy = DefaultPolicy()
+
+// HTML sanitizes s with DefaultPolicy.
+//
+// Parameters:
+//   - s: The untrusted HTML.
+//
+// Returns:
+//   - string: The sanitized HTML.
+func HTML(s string) string {
+	return defaultPolicy.Sanitize(s)
+}
+
+// voidElements have no end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+// dropElements are removed together with their content.
+var dropElements = map[string]bool{
+	"script": true, "style": true, "title": true, "textarea": true,
+	"xmp": true, "iframe": true, "noembed": true, "noframes": true,
+	"noscript": true, "template": true, "object": true, "applet": true,
+	"svg": true, "math": true, "plaintext": true,
+}
+
+// urlAttributes hold URLs and are checked against Policy.URLSchemes.
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "cite": true, "action": true,
+	"formaction": true, "background": true, "poster": true,
+	"longdesc": true, "usemap": true,
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;",
+		`"`, "&quot;")
+)
+
+// Sanitize returns s with everything outside the policy removed. The
+// output is well formed: allowed elements left open are closed, and
+// text and attribute values are re-escaped.
+//
+// Parameters:
+//   - s: The untrusted HTML.
+//
+// Returns:
+//   - string: The sanitized HTML.
+func (p *Policy) Sanitize(s string) string {
+	var b strings.Builder
+	var open []string
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			writeText(&b, s)
+			break
+		}
+		writeText(&b, s[:i])
+		s = s[i:]
+		switch {
+		case strings.HasPrefix(s, "<!--"):
+			s = skipPast(s[4:], "-->")
+		case len(s) > 1 && (s[1] == '!' || s[1] == '?'):
+			s = skipPast(s, ">")
+		case len(s) > 2 && s[1] == '/' && isLetter(s[2]):
+			name, rest := readName(s[2:])
+			s = skipPast(rest, ">")
+			if j := slices.Index(open, name); j >= 0 {
+				for k := len(open) - 1; k >= j; k-- {
+					b.WriteString("</" + open[k] + ">")
+				}
+				open = open[:j]
+			}
+		case len(s) > 1 && s[1] == '/':
+			s = skipPast(s, ">")
+		case len(s) > 1 && isLetter(s[1]):
+			t, rest, ok := readTag(s[1:])
+			if !ok {
+				// An unterminated tag is not rendered.
+				s = ""
+				break
+			}
+			s = rest
+			if dropElements[t.name] {
+				if !t.selfClosing {
+					s = skipElement(s, t.name)
+				}
+				break
+			}
+			attrs, allowed := p.Elements[t.name]
+			if !allowed {
+				break
+			}
+			b.WriteString("<" + t.name)
+			p.writeAttrs(&b, t.attrs, attrs)
+			b.WriteString(">")
+			if !voidElements[t.name] {
+				open = append(open, t.name)
+			}
+		default:
+			b.WriteString("&lt;")
+			s = s[1:]
+		}
+	}
+	for k := len(open) - 1; k >= 0; k-- {
+		b.WriteString("</" + open[k] + ">")
+	}
+	return b.String()
+}
+
+// writeAttrs writes the allowed attributes, keeping the first of any
+// duplicates.
+func (p *Policy) writeAttrs(b *strings.Builder, attrs [][2]string, allowed []string) {
+	seen := map[string]bool{}
+	for _, a := range attrs {
+		name, val := a[0], a[1]
+		if seen[name] || strings.HasPrefix(name, "on") ||
+			!(slices.Contains(allowed, name) || slices.Contains(p.GlobalAttributes, name)) {
+			continue
+		}
+		seen[name] = true
+		if urlAttributes[name] && !p.safeURL(val) {
+			continue
+		}
+		if name == "style" && !safeCSS(val) {
+			continue
+		}
+		b.WriteString(" " + name + `="` + attrEscaper.Replace(val) + `"`)
+	}
+}
+
+// safeURL reports whether v is relative or uses an allowed scheme.
+// Browsers ignore whitespace and control characters inside a scheme,
+// so they are removed before checking.
+func (p *Policy) safeURL(v string) bool {
+	v = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, v)
+	i := strings.IndexAny(v, ":/?#")
+	if i < 0 || v[i] != ':' {
+		return true
+	}
+	return slices.Contains(p.URLSchemes, strings.ToLower(v[:i]))
+}
+
+// safeCSS rejects inline styles that can run script or load resources.
+// CSS escapes and comments can hide these, so both are rejected too.
+func safeCSS(v string) bool {
+	v = strings.ToLower(strings.Join(strings.Fields(v), ""))
+	for _, bad := range []string{
+		`\`, "/*", "expression(", "javascript:", "vbscript:", "url(",
+		"@import", "behavior:", "-moz-binding",
+	} {
+		if strings.Contains(v, bad) {
+			return false
+		}
+	}
+	return true
+}
+
+// tag is a parsed start tag.
+type tag struct {
+	name        string
+	attrs       [][2]string // lower-cased name, unescaped value
+	selfClosing bool
+}
+
+// readTag parses a start tag after its "<". ok is false if the input
+// ends before the closing ">".
+func readTag(s string) (t tag, rest string, ok bool) {
+	t.name, s = readName(s)
+	for {
+		s = strings.TrimLeft(s, " \t\r\n\f/")
+		if s == "" {
+			return t, "", false
+		}
+		if s[0] == '>' {
+			return t, s[1:], true
+		}
+		if strings.HasPrefix(s, "/>") {
+			t.selfClosing = true
+			return t, s[2:], true
+		}
+		n := strings.IndexAny(s, " \t\r\n\f/>=")
+		if n == 0 {
+			// A leading "=" is part of the attribute name in HTML.
+			n = strings.IndexAny(s[1:], " \t\r\n\f/>=") + 1
+			if n == 0 {
+				return t, "", false
+			}
+		}
+		if n < 0 {
+			return t, "", false
+		}
+		name := strings.ToLower(s[:n])
+		s = strings.TrimLeft(s[n:], " \t\r\n\f")
+		var val string
+		if strings.HasPrefix(s, "=") {
+			s = strings.TrimLeft(s[1:], " \t\r\n\f")
+			if s == "" {
+				return t, "", false
+			}
+			if q := s[0]; q == '"' || q == '\'' {
+				end := strings.IndexByte(s[1:], q)
+				if end < 0 {
+					return t, "", false
+				}
+				val, s = s[1:1+end], s[2+end:]
+			} else {
+				end := strings.IndexAny(s, " \t\r\n\f>")
+				if end < 0 {
+					return t, "", false
+				}
+				val, s = s[:end], s[end:]
+			}
+		}
+		t.attrs = append(t.attrs, [2]string{name, html.UnescapeString(val)})
+	}
+}
+
+// readName reads a lower-cased tag name.
+func readName(s string) (string, string) {
+	n := strings.IndexAny(s, " \t\r\n\f/>")
+	if n < 0 {
+		n = len(s)
+	}
+	return strings.ToLower(s[:n]), s[n:]
+}
+
+// skipElement skips the content of name up to and including its end
+// tag, or to the end of input.
+func skipElement(s, name string) string {
+	for i := 0; ; {
+		j := indexFold(s[i:], "</"+name)
+		if j < 0 {
+			return ""
+		}
+		end := i + j + 2 + len(name)
+		if end == len(s) || strings.IndexByte(" \t\r\n\f/>", s[end]) >= 0 {
+			return skipPast(s[end:], ">")
+		}
+		i = end
+	}
+}
+
+// indexFold is strings.Index ignoring case, for an ASCII substr. It
+// does not lower-case s, which could shift byte offsets.
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// skipPast returns s after the first occurrence of sep, or "".
+func skipPast(s, sep string) string {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[i+len(sep):]
+	}
+	return ""
+}
+
+// writeText writes text re-escaped, so entities stay entities and stray
+// angle brackets cannot form markup.
+func writeText(b *strings.Builder, s string) {
+	b.WriteString(textEscaper.Replace(html.UnescapeString(s)))
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
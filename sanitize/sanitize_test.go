@@ -0,0 +1,60 @@
+package sanitize
+
+import "testing"
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain", "Hello &amp; welcome", "Hello &amp; welcome"},
+		{"keeps formatting", `<p class="x">Hi <b>there</b><br/></p>`,
+			`<p class="x">Hi <b>there</b><br></p>`},
+		{"script", `a<script>alert("<b>")</script>b`, "ab"},
+		{"script case", `a<SCRIPT type=x>alert(1)</script >b`, "ab"},
+		{"style element", `<style>p{color:red}</style>x`, "x"},
+		{"event handler", `<img src="https://x.example/a.png" onerror="alert(1)" alt=a>`,
+			`<img src="https://x.example/a.png" alt="a">`},
+		{"javascript href", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"obfuscated scheme", `<a href="jav&#x09;ascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"entity scheme", `<a href="&#106;avascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"relative href", `<a href="/path?q=1&amp;r=2#f">x</a>`,
+			`<a href="/path?q=1&amp;r=2#f">x</a>`},
+		{"mailto and cid", `<a href="mailto:a@example.com">m</a><img src="cid:logo">`,
+			`<a href="mailto:a@example.com">m</a><img src="cid:logo">`},
+		{"form", `<form action="https://evil.example/"><input name=p>Go<button>x</button></form>`,
+			"Gox"},
+		{"iframe", `<iframe src="https://evil.example/">fallback</iframe>ok`, "ok"},
+		{"comment", `a<!-- <script>alert(1)</script> -->b`, "ab"},
+		{"unclosed", `<div><b>bold`, `<div><b>bold</b></div>`},
+		{"stray close", `</b>text</div>`, "text"},
+		{"misnested", `<b><i>x</b>y</i>`, `<b><i>x</i></b>y`},
+		{"stray lt", `1 < 2 > 0`, `1 &lt; 2 &gt; 0`},
+		{"unterminated tag", `ok<img src=x onerror=alert(1)`, "ok"},
+		{"quoted gt", `<span title="a>b">x</span>`, `<span title="a&gt;b">x</span>`},
+		{"attr quote", `<span title='say "hi"'>x</span>`, `<span title="say &quot;hi&quot;">x</span>`},
+		{"duplicate attr", `<a href="https://ok.example" href="javascript:x">x</a>`,
+			`<a href="https://ok.example">x</a>`},
+		{"safe style", `<p style="color: red; font-weight: bold">x</p>`,
+			`<p style="color: red; font-weight: bold">x</p>`},
+		{"style url", `<p style="background: URL(https://track.example/p)">x</p>`, `<p>x</p>`},
+		{"style expression", `<p style="width: expr\65ssion(alert(1))">x</p>`, `<p>x</p>`},
+		{"svg", `<svg><script>alert(1)</script></svg>after`, "after"},
+		{"doctype", `<!DOCTYPE html><html><body>x</body></html>`, "x"},
+	}
+	for _, tc := range tests {
+		if got := HTML(tc.in); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCustomPolicy(t *testing.T) {
+	p := &Policy{
+		Elements:   map[string][]string{"a": {"href"}},
+		URLSchemes: []string{"https"},
+	}
+	got := p.Sanitize(`<p><a href="http://x.example" class="c">x</a><a href="https://x.example">y</a></p>`)
+	if want := `<a>x</a><a href="https://x.example">y</a>`; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
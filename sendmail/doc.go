@@ -0,0 +1,4 @@
+// Package sendmail is a mailer adapter that pipes messages to a local
+// sendmail-compatible binary, such as the one shipped with Postfix or
+// Exim. It implements the Mailer interface.
+package sendmail
@@ -0,0 +1,275 @@
+package sendmail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// DefaultPath is the conventional location of the sendmail binary.
+const DefaultPath = "/usr/sbin/sendmail"
+
+// SendmailConfig configures the sendmail mailer.
+type SendmailConfig struct {
+	Path string // defaults to DefaultPath
+
+	// Args are passed before the adapter's own arguments (-i, -f and
+	// the recipients), e.g. []string{"-C", "/etc/exim/alt.conf"}.
+	Args []string
+
+	// Timeout bounds each run of the binary (0 = only ctx).
+	Timeout time.Duration
+
+	// EightBitMIME lets text parts be written as 8bit. Local MTAs
+	// convert them when relaying to servers without 8BITMIME.
+	EightBitMIME bool
+}
+
+// ExitError reports that sendmail exited with a non-zero status. Codes
+// follow sysexits.h.
+type ExitError struct {
+	Code   int    // exit status
+	Stderr string // trimmed standard error output
+}
+
+// Error implements the error interface.
+//
+// Returns:
+//   - string: The error message.
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("sendmail: exit status %d", e.Code)
+	if name := exitNames[e.Code]; name != "" {
+		msg += " (" + name + ")"
+	}
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+	return msg
+}
+
+// Temporary reports whether retrying may succeed: the MTA asked to try
+// again later (EX_TEMPFAIL) or hit an OS or I/O error.
+//
+// Returns:
+//   - bool: True if the error is temporary.
+func (e *ExitError) Temporary() bool {
+	switch e.Code {
+	case 71, 74, 75: // EX_OSERR, EX_IOERR, EX_TEMPFAIL
+		return true
+	}
+	return false
+}
+
+// exitNames are the sysexits.h names for sendmail exit codes.
+var exitNames = map[int]string{
+	64: "EX_USAGE", 65: "EX_DATAERR", 66: "EX_NOINPUT", 67: "EX_NOUSER",
+	68: "EX_NOHOST", 69: "EX_UNAVAILABLE", 70: "EX_SOFTWARE",
+	71: "EX_OSERR", 72: "EX_OSFILE", 73: "EX_CANTCREAT", 74: "EX_IOERR",
+	75: "EX_TEMPFAIL", 76: "EX_PROTOCOL", 77: "EX_NOPERM", 78: "EX_CONFIG",
+}
+
+// Sendmail implements the Mailer interface by piping messages to a
+// local sendmail binary.
+type Sendmail struct {
+	cfg SendmailConfig
+}
+
+// NewSendmail creates a new sendmail mailer.
+//
+// Parameters:
+//   - cfg: The sendmail config.
+//
+// Returns:
+//   - *Sendmail: The sendmail mailer.
+func NewSendmail(cfg SendmailConfig) *Sendmail {
+	if cfg.Path == "" {
+		cfg.Path = DefaultPath
+	}
+	return &Sendmail{cfg: cfg}
+}
+
+// Send builds msg and pipes it to sendmail with the envelope sender
+// (-f) and recipients on the command line, so Bcc recipients are
+// delivered without appearing in the headers.
+//
+// Parameters:
+//   - ctx: The context.
+//   - msg: The message.
+//   - opts: The options.
+//
+// Returns:
+//   - error: The error if the email fails to send. A non-zero exit
+//     status is reported as an *ExitError.
+func (s *Sendmail) Send(
+	ctx context.Context,
+	msg types.Message,
+	opts ...email.Option,
+) error {
+	var cfg email.SendConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if cfg.FanOut != nil {
+		return errors.New("sendmail: WithFanOut is not supported")
+	}
+	msg, err := email.ExpandRecipients(ctx, msg, opts...)
+	if err != nil {
+		return err
+	}
+	if err := email.CheckPolicy(ctx, msg, opts...); err != nil {
+		return err
+	}
+	if cfg.RequireReturnPathAlignment {
+		if err := email.CheckReturnPathAlignment(msg, opts...); err != nil {
+			return err
+		}
+	}
+	rcpts := msg.EnvelopeRecipients()
+	if cfg.ArchiveCopy != "" && cfg.ArchiveMode == email.ArchiveRequired &&
+		!slices.Contains(rcpts, cfg.ArchiveCopy) {
+		rcpts = append(slices.Clip(rcpts), cfg.ArchiveCopy)
+	}
+	if cfg.AddrCheck != nil {
+		for _, rcpt := range rcpts {
+			if err := cfg.AddrCheck.CheckAddress(ctx, rcpt); err != nil {
+				return err
+			}
+		}
+	}
+	if cfg.InlineImages != nil {
+		var err error
+		if msg, err = email.InlineRemoteImages(ctx, msg, *cfg.InlineImages); err != nil {
+			return err
+		}
+	}
+	if cfg.AttachmentLinks != nil {
+		var err error
+		if msg, _, err = cfg.AttachmentLinks.Apply(ctx, msg); err != nil {
+			return err
+		}
+	}
+	if cfg.Rate != nil {
+		if err := cfg.Rate.Acquire(ctx); err != nil {
+			return err
+		}
+	}
+
+	built, err := internal.Build(ctx, msg, s.buildOptions(&cfg))
+	if err != nil {
+		return err
+	}
+	if cfg.MessageIDOut != nil {
+		*cfg.MessageIDOut = built.MessageID
+	}
+	res := cfg.Result
+	if res == nil {
+		res = &email.SendResult{}
+	}
+	*res = email.SendResult{
+		MessageID:       built.MessageID,
+		Size:            len(built.Raw),
+		AttachmentSizes: built.AttachmentSizes,
+		Warnings:        built.Warnings,
+	}
+	if cfg.SpamCheck != nil {
+		if built.Raw, res.Spam, err = cfg.SpamCheck.Apply(ctx, built.Raw); err != nil {
+			return err
+		}
+	}
+
+	from := msg.EnvelopeSender()
+	if cfg.EnvelopeFrom != "" {
+		from = cfg.EnvelopeFrom
+	}
+	archive := cfg.ArchiveCopy != "" && cfg.ArchiveMode == email.ArchiveBestEffort
+	check := append([]string{from}, rcpts...)
+	if archive {
+		check = append(check, cfg.ArchiveCopy)
+	}
+	for _, a := range check {
+		// A leading dash would be parsed as an option.
+		if strings.HasPrefix(a, "-") {
+			return fmt.Errorf("sendmail: invalid address %q", a)
+		}
+	}
+	args := append(append([]string(nil), s.cfg.Args...), "-i", "-f", from, "--")
+	args = append(args, rcpts...)
+	// The local submission interface expects native line endings.
+	raw := bytes.ReplaceAll(built.Raw, []byte("\r\n"), []byte("\n"))
+
+	res.Attempts, err = internal.Retry(ctx, cfg.Backoff, cfg.Hooks,
+		isTransient, func(ctx context.Context) error {
+			out, err := s.run(ctx, args, raw)
+			res.Response = out
+			return err
+		})
+	if err == nil && cfg.SentCopier != nil {
+		res.SentCopyErr = cfg.SentCopier.CopySent(ctx, built.Raw)
+	}
+	if err == nil && archive {
+		// A separate submission, so its failure leaves the send alone.
+		args := append(append([]string(nil), s.cfg.Args...), "-i", "-f", from, "--",
+			cfg.ArchiveCopy)
+		_, res.ArchiveErr = s.run(ctx, args, raw)
+	}
+	return err
+}
+
+// run runs sendmail once with raw on stdin and returns its trimmed
+// output.
+func (s *Sendmail) run(ctx context.Context, args []string, raw []byte) (string, error) {
+	if s.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, s.cfg.Path, args...)
+	cmd.Stdin = bytes.NewReader(raw)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	// Children of a killed sendmail may hold the pipes open.
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+	out := strings.TrimSpace(stdout.String())
+	if ctx.Err() != nil {
+		return out, fmt.Errorf("sendmail: %w", ctx.Err())
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return out, &ExitError{
+			Code:   exitErr.ExitCode(),
+			Stderr: strings.TrimSpace(stderr.String()),
+		}
+	}
+	if err != nil {
+		return out, fmt.Errorf("sendmail: %w", err)
+	}
+	return out, nil
+}
+
+// buildOptions maps send options to MIME build options.
+func (s *Sendmail) buildOptions(cfg *email.SendConfig) internal.BuildOptions {
+	opts := internal.SendBuildOptions(cfg)
+	opts.Allow8Bit = s.cfg.EightBitMIME
+	return opts
+}
+
+// isTransient reports whether a failed run may be retried.
+func isTransient(err error) bool {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Temporary()
+	}
+	// A per-run timeout may clear up; a canceled ctx or a missing
+	// binary will not.
+	return errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,155 @@
+package sendmail
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
+
+var _ email.Mailer = (*Sendmail)(nil)
+
+// fakeSendmail writes a script that records its arguments and input in
+// dir and runs body.
+func fakeSendmail(t *testing.T, body string) (string, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sendmail")
+	script := "#!/bin/sh\n" +
+		`printf '%s\n' "$@" > "` + dir + `/args"` + "\n" +
+		`cat > "` + dir + `/stdin"` + "\n" + body + "\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path, dir
+}
+
+type copierFunc func(ctx context.Context, raw []byte) error
+
+func (f copierFunc) CopySent(ctx context.Context, raw []byte) error { return f(ctx, raw) }
+
+func testMessage() types.Message {
+	return types.Message{
+		From:    types.Address{Mail: "news@example.com"},
+		To:      []types.Address{{Mail: "ada@example.org"}},
+		Bcc:     []types.Address{{Mail: "audit@example.com"}},
+		Subject: "Hello",
+		Plain:   []byte("hi"),
+	}
+}
+
+func TestSend(t *testing.T) {
+	path, dir := fakeSendmail(t, "echo queued")
+	s := NewSendmail(SendmailConfig{Path: path, Args: []string{"-oi"}})
+	var res email.SendResult
+	err := s.Send(context.Background(), testMessage(), email.WithResult(&res),
+		email.WithEnvelopeFrom("bounces@example.com"))
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	want := "-oi\n-i\n-f\nbounces@example.com\n--\nada@example.org\naudit@example.com\n"
+	if string(args) != want {
+		t.Fatalf("got args %q, want %q", args, want)
+	}
+	stdin, _ := os.ReadFile(filepath.Join(dir, "stdin"))
+	if strings.Contains(string(stdin), "\r") || strings.Contains(string(stdin), "audit@") ||
+		!strings.Contains(string(stdin), "Subject: Hello\n") {
+		t.Fatalf("unexpected input:\n%s", stdin)
+	}
+	if res.Response != "queued" || res.Attempts != 1 || res.MessageID == "" ||
+		res.Size != len(stdin)+strings.Count(string(stdin), "\n") {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	var copied []byte
+	copier := copierFunc(func(_ context.Context, raw []byte) error {
+		copied = raw
+		return errors.New("imap down")
+	})
+	res = email.SendResult{}
+	if err := s.Send(context.Background(), testMessage(), email.WithResult(&res),
+		email.WithSentCopy(copier)); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if res.SentCopyErr == nil || !strings.Contains(string(copied), "Subject: Hello\r\n") {
+		t.Fatalf("expected CRLF copy and copy error: %v %q", res.SentCopyErr, copied)
+	}
+
+	msg := testMessage()
+	msg.To = []types.Address{{Mail: "-oQ/tmp@example.org"}}
+	if err := s.Send(context.Background(), msg); err == nil {
+		t.Fatalf("expected option-like address to be rejected")
+	}
+}
+
+func TestSendExitCodes(t *testing.T) {
+	path, dir := fakeSendmail(t, `echo "try later" >&2; echo x >> "`+
+		"$(dirname \"$0\")"+`/runs"; exit 75`)
+	s := NewSendmail(SendmailConfig{Path: path})
+	var res email.SendResult
+	err := s.Send(context.Background(), testMessage(), email.WithResult(&res),
+		email.WithRetry(email.ExponentialBackoff(2, time.Millisecond, time.Millisecond, false)))
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 75 || exitErr.Stderr != "try later" ||
+		!exitErr.Temporary() || res.Attempts != 2 {
+		t.Fatalf("expected retried EX_TEMPFAIL, got %v %+v", err, res)
+	}
+	if !strings.Contains(err.Error(), "EX_TEMPFAIL") {
+		t.Fatalf("expected exit name in %q", err)
+	}
+	runs, _ := os.ReadFile(filepath.Join(dir, "runs"))
+	if len(runs) != 4 {
+		t.Fatalf("expected 2 runs, got %q", runs)
+	}
+
+	path, _ = fakeSendmail(t, "exit 67")
+	s = NewSendmail(SendmailConfig{Path: path})
+	err = s.Send(context.Background(), testMessage(),
+		email.WithRetry(email.ExponentialBackoff(3, time.Millisecond, time.Millisecond, false)))
+	if !errors.As(err, &exitErr) || exitErr.Code != 67 || exitErr.Temporary() {
+		t.Fatalf("expected permanent EX_NOUSER, got %v", err)
+	}
+
+	path, _ = fakeSendmail(t, "exec sleep 5")
+	s = NewSendmail(SendmailConfig{Path: path, Timeout: 50 * time.Millisecond})
+	if err := s.Send(context.Background(), testMessage()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+
+	s = NewSendmail(SendmailConfig{Path: filepath.Join(t.TempDir(), "missing")})
+	if err := s.Send(context.Background(), testMessage()); err == nil || errors.As(err, &exitErr) {
+		t.Fatalf("expected exec error, got %v", err)
+	}
+}
+
+func TestSendArchiveCopy(t *testing.T) {
+	path, dir := fakeSendmail(t, `case "$*" in *journal@*) echo "no such user" >&2; exit 67;; esac`)
+	s := NewSendmail(SendmailConfig{Path: path})
+	var res email.SendResult
+	err := s.Send(context.Background(), testMessage(), email.WithResult(&res),
+		email.WithArchiveCopy("journal@example.com", email.ArchiveBestEffort))
+	var exitErr *ExitError
+	if err != nil || !errors.As(res.ArchiveErr, &exitErr) {
+		t.Fatalf("send: %v, archive: %v", err, res.ArchiveErr)
+	}
+
+	path, dir = fakeSendmail(t, "")
+	s = NewSendmail(SendmailConfig{Path: path})
+	err = s.Send(context.Background(), testMessage(),
+		email.WithArchiveCopy("journal@example.com", email.ArchiveRequired))
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if err != nil || !strings.HasSuffix(string(args), "ada@example.org\naudit@example.com\njournal@example.com\n") {
+		t.Fatalf("send: %v, args: %q", err, args)
+	}
+}
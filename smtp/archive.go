@@ -0,0 +1,53 @@
+package smtp
+
+import (
+	"context"
+	"slices"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/internal"
+)
+
+// withArchive adds the WithArchiveCopy address to rcpts if it must be
+// delivered in the same transaction (ArchiveRequired).
+func withArchive(cfg *email.SendConfig, rcpts []string) []string {
+	if cfg.ArchiveCopy == "" || cfg.ArchiveMode != email.ArchiveRequired ||
+		slices.Contains(rcpts, cfg.ArchiveCopy) {
+		return rcpts
+	}
+	return append(slices.Clip(rcpts), cfg.ArchiveCopy)
+}
+
+// archiveCopy sends built to the WithArchiveCopy address in its own
+// transaction (ArchiveBestEffort), recording a failure in res instead
+// of failing the send.
+func (m *SMTP) archiveCopy(
+	ctx context.Context,
+	cfg *email.SendConfig,
+	res *email.SendResult,
+	from string,
+	built *internal.Built,
+) {
+	if cfg.ArchiveCopy == "" || cfg.ArchiveMode != email.ArchiveBestEffort {
+		return
+	}
+	res.ArchiveErr = m.withConn(ctx, cfg, func(conn *smtpConn) error {
+		return archiveTransaction(ctx, conn, from, cfg.ArchiveCopy, built)
+	})
+}
+
+// archiveTransaction sends built to addr alone, resetting the session
+// if the transaction fails so the connection can be reused.
+func archiveTransaction(
+	ctx context.Context,
+	conn *smtpConn,
+	from string,
+	addr string,
+	built *internal.Built,
+) error {
+	_, err := transaction(ctx, conn, from, []string{addr}, built)
+	if err != nil && conn.c.Reset() != nil {
+		conn.broken = true
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestArchiveCopy(t *testing.T) {
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("hi"),
+	}
+	rcpts := func(srv *fakeServer) []string {
+		var out []string
+		for _, c := range srv.commands() {
+			if strings.HasPrefix(c, "RCPT TO:") {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+
+	// Best effort: a separate transaction after delivery.
+	srv := newFakeServer(t)
+	var res email.SendResult
+	err := NewSMTP(srv.config()).Send(context.Background(), msg, email.WithResult(&res),
+		email.WithArchiveCopy("journal@example.com", email.ArchiveBestEffort))
+	if err != nil || res.ArchiveErr != nil {
+		t.Fatalf("send: %v, archive: %v", err, res.ArchiveErr)
+	}
+	got := rcpts(srv)
+	if len(got) != 2 || got[1] != "RCPT TO:<journal@example.com>" || len(srv.messages()) != 2 ||
+		strings.Contains(srv.messages()[1], "journal@") {
+		t.Fatalf("unexpected archive delivery: %v\n%s", got, srv.messages())
+	}
+
+	// A refused archive copy does not fail the send.
+	srv = newFakeServer(t)
+	srv.rcptReply = map[string]string{"journal@example.com": "550 5.1.1 no such mailbox"}
+	err = NewSMTP(srv.config()).Send(context.Background(), msg, email.WithResult(&res),
+		email.WithArchiveCopy("journal@example.com", email.ArchiveBestEffort))
+	var se *types.SMTPError
+	if err != nil || !errors.As(res.ArchiveErr, &se) || se.Recipient != "journal@example.com" ||
+		len(srv.messages()) != 1 {
+		t.Fatalf("send: %v, archive: %v", err, res.ArchiveErr)
+	}
+
+	// Required: one transaction, which fails with the archive.
+	srv = newFakeServer(t)
+	srv.rcptReply = map[string]string{"journal@example.com": "550 5.1.1 no such mailbox"}
+	err = NewSMTP(srv.config()).Send(context.Background(), msg,
+		email.WithArchiveCopy("journal@example.com", email.ArchiveRequired))
+	if !errors.As(err, &se) || se.Recipient != "journal@example.com" || len(srv.messages()) != 0 {
+		t.Fatalf("expected the send to fail, got %v", err)
+	}
+	srv.mu.Lock()
+	srv.rcptReply = nil
+	srv.mu.Unlock()
+	if err := NewSMTP(srv.config()).Send(context.Background(), msg,
+		email.WithArchiveCopy("journal@example.com", email.ArchiveRequired)); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.messages()) != 1 || len(rcpts(srv)) != 4 {
+		t.Fatalf("expected one transaction to both: %v", rcpts(srv))
+	}
+}
@@ -0,0 +1,13 @@
+//go:build fips
+
+package smtp
+
+import (
+	"errors"
+	"net/smtp"
+)
+
+// cramMD5Auth fails: CRAM-MD5 uses MD5, which is not FIPS-approved.
+func cramMD5Auth(user, secret string) (smtp.Auth, error) {
+	return nil, errors.New("smtp: CRAM-MD5 is not available in fips builds")
+}
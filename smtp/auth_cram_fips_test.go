@@ -0,0 +1,12 @@
+//go:build fips
+
+package smtp
+
+import "testing"
+
+func TestCRAMMD5AuthFIPS(t *testing.T) {
+	c := Credentials{Username: "app", Password: "secret"}
+	if _, err := c.auth("localhost", AuthCRAMMD5); err == nil {
+		t.Fatal("expected CRAM-MD5 to fail in fips builds")
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !fips
+
+package smtp
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/hex"
+	"net/smtp"
+	"testing"
+)
+
+func TestCRAMMD5Auth(t *testing.T) {
+	c := Credentials{Username: "app", Password: "secret"}
+	a, err := c.auth("localhost", AuthCRAMMD5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _, _ := a.Start(&smtp.ServerInfo{Name: "localhost"}); got != "CRAM-MD5" {
+		t.Fatalf("mechanism %q", got)
+	}
+	challenge := []byte("<1.2@localhost>")
+	mac := hmac.New(md5.New, []byte("secret"))
+	mac.Write(challenge)
+	want := "app " + hex.EncodeToString(mac.Sum(nil))
+	if resp, err := a.Next(challenge, true); err != nil || string(resp) != want {
+		t.Fatalf("response %q, %v; want %q", resp, err, want)
+	}
+}
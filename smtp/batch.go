@@ -0,0 +1,272 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// errBatchDone is returned by SendOne after Commit.
+var errBatchDone = errors.New("smtp: batch already committed")
+
+// Batch sends several messages over one connection, one MAIL FROM
+// transaction each, so EHLO, STARTTLS and AUTH run once for the whole
+// batch. It is not safe for concurrent use; start one batch per
+// goroutine.
+type Batch struct {
+	m    *SMTP
+	conn *smtpConn // nil after a failed redial
+	done bool
+}
+
+// Begin starts a batch on a connection from the mailer's pool, or a new
+// one. The batch holds the connection until Commit; Close waits for
+// open batches to be committed.
+//
+// Parameters:
+//   - ctx: The context for connecting.
+//
+// Returns:
+//   - *Batch: The batch.
+//   - error: The error if no connection could be opened.
+func (m *SMTP) Begin(ctx context.Context) (*Batch, error) {
+	if m.cfg.LMTP {
+		return nil, errors.New("smtp: batches are not supported with LMTP")
+	}
+	if err := m.begin(); err != nil {
+		return nil, err
+	}
+	conn, _, err := m.acquire(ctx, m.pool)
+	if err != nil {
+		m.inflight.Done()
+		return nil, err
+	}
+	return &Batch{m: m, conn: conn}, nil
+}
+
+// SendOne sends msg in the batch. Build and delivery options apply as
+// in Send, except that WithRetry, WithPool and WithFanOut are not
+// supported: a failed message is reported and the batch continues with
+// the next one. If the server drops the connection, the next message
+// is sent on a new one.
+//
+// Parameters:
+//   - ctx: The context.
+//   - msg: The message.
+//   - opts: The options.
+//
+// Returns:
+//   - error: The error if the message fails to send.
+func (b *Batch) SendOne(
+	ctx context.Context,
+	msg types.Message,
+	opts ...email.Option,
+) error {
+	if b.done {
+		return errBatchDone
+	}
+	cfg := sendConfig(opts)
+	if cfg.FanOut != nil {
+		return errors.New("smtp: WithFanOut is not supported in a batch")
+	}
+	msg, err := email.ExpandRecipients(ctx, msg, opts...)
+	if err != nil {
+		return err
+	}
+	if err := email.CheckPolicy(ctx, msg, opts...); err != nil {
+		return err
+	}
+	if err := checkAddresses(ctx, &cfg, msg.EnvelopeRecipients()); err != nil {
+		return err
+	}
+	if err := checkReturnPath(msg, &cfg, opts); err != nil {
+		return err
+	}
+	msg, err = rewriteMessage(ctx, &cfg, msg)
+	if err != nil {
+		return err
+	}
+	if cfg.Rate != nil {
+		if err := cfg.Rate.Acquire(ctx); err != nil {
+			return err
+		}
+	}
+	built, rebuild, err := buildSend(ctx, msg, b.m.buildOptions(&cfg))
+	if err != nil {
+		return err
+	}
+	built, spam, err := checkSpam(ctx, &cfg, built)
+	if err != nil {
+		return err
+	}
+	rebuild = spamRebuild(ctx, &cfg, rebuild)
+	from := msg.EnvelopeSender()
+	if cfg.EnvelopeFrom != "" {
+		from = cfg.EnvelopeFrom
+	}
+	rcpts := withArchive(&cfg, msg.EnvelopeRecipients())
+
+	if cfg.MessageIDOut != nil {
+		*cfg.MessageIDOut = built.MessageID
+	}
+	res := cfg.Result
+	if res == nil {
+		res = &email.SendResult{}
+	}
+	*res = email.SendResult{
+		MessageID:       built.MessageID,
+		Size:            len(built.Raw),
+		AttachmentSizes: built.AttachmentSizes,
+		Warnings:        built.Warnings,
+		Attempts:        1,
+		Spam:            spam,
+	}
+	if cfg.Hooks != nil && cfg.Hooks.OnAttemptStart != nil {
+		ctx = cfg.Hooks.OnAttemptStart(ctx, 0)
+	}
+	res.Response, err = b.send(ctx, from, rcpts, built)
+	if errors.Is(err, errBodyUnsupported) && rebuild != nil {
+		fallback(ctx, &cfg, res, err.Error()+"; rebuilt with 7-bit encodings")
+		if built, err = rebuild(); err == nil {
+			res.Size = len(built.Raw)
+			res.Response, err = b.send(ctx, from, rcpts, built)
+		}
+	}
+	if cfg.Hooks != nil && cfg.Hooks.OnAttemptDone != nil {
+		cfg.Hooks.OnAttemptDone(ctx, 0, err)
+	}
+	if err == nil {
+		copySent(ctx, &cfg, res, built.Raw)
+		if cfg.ArchiveCopy != "" && cfg.ArchiveMode == email.ArchiveBestEffort {
+			_, res.ArchiveErr = b.send(ctx, from, []string{cfg.ArchiveCopy}, built)
+		}
+	}
+	return err
+}
+
+// send runs one transaction on the batch connection, redialing once if
+// the connection was dropped while idle.
+func (b *Batch) send(
+	ctx context.Context,
+	from string,
+	rcpts []string,
+	built *internal.Built,
+) (string, error) {
+	fresh := false
+	if b.conn == nil || b.conn.broken {
+		if err := b.redial(ctx); err != nil {
+			return "", err
+		}
+		fresh = true
+	}
+	var resp string
+	fn := func(conn *smtpConn) error {
+		c := conn.c
+		var err error
+		resp, err = transaction(ctx, conn, from, rcpts, built)
+		if err != nil && !errors.Is(err, errBodyUnsupported) {
+			// Clear the failed transaction for the next message.
+			if c.Reset() != nil {
+				b.conn.broken = true
+			}
+		}
+		return err
+	}
+	reads := b.conn.nc.reads()
+	err := b.m.session(ctx, b.conn, fn)
+	if err != nil && (!fresh && b.conn.stale(err, reads) || errors.Is(err, errCredentialsRotated)) {
+		b.conn.broken = true
+		if err := b.redial(ctx); err != nil {
+			return "", err
+		}
+		err = b.m.session(ctx, b.conn, fn)
+	}
+	return resp, err
+}
+
+// redial replaces the batch connection with a new one.
+func (b *Batch) redial(ctx context.Context) error {
+	if b.conn != nil {
+		b.m.release(b.m.pool, b.conn)
+		b.conn = nil
+	}
+	conn, err := b.m.redial(ctx, b.m.pool)
+	if err != nil {
+		return err
+	}
+	conn.used = true
+	b.conn = conn
+	return nil
+}
+
+// Commit ends the batch: the connection goes back to the mailer's pool,
+// or is closed with QUIT if there is none. Commit is idempotent.
+//
+// Returns:
+//   - error: The error from QUIT, if any.
+func (b *Batch) Commit() error {
+	if b.done {
+		return nil
+	}
+	b.done = true
+	defer b.m.inflight.Done()
+	switch {
+	case b.conn == nil:
+		return nil
+	case b.m.pool == nil && !b.conn.broken:
+		stop := b.conn.bind(context.Background(), b.m.cfg.Timeout)
+		defer stop()
+		return b.conn.c.Quit()
+	}
+	b.m.release(b.m.pool, b.conn)
+	return nil
+}
+
+// SendBatch sends msgs in one batch (see Begin), with opts applied to
+// each of them as in SendOne, and reports every message on its own. A
+// failed message does not stop the batch; if no connection can be
+// opened, every message fails with that error.
+//
+// Parameters:
+//   - ctx: The context.
+//   - msgs: The messages.
+//   - opts: The options for every message.
+//
+// Returns:
+//   - []email.BatchResult: One result per message, in order.
+//   - error: An *email.BatchError if any message failed.
+func (m *SMTP) SendBatch(
+	ctx context.Context,
+	msgs []types.Message,
+	opts ...email.Option,
+) ([]email.BatchResult, error) {
+	cfg := sendConfig(opts)
+	results := make([]email.BatchResult, len(msgs))
+	for i := range results {
+		results[i].Index = i
+	}
+	ctx = email.StartBatch(ctx, cfg.Hooks, len(msgs))
+	var b *Batch
+	var err error
+	if len(msgs) > 0 {
+		b, err = m.Begin(ctx)
+	}
+	for i, msg := range msgs {
+		switch {
+		case err != nil:
+			results[i].Err = err
+		case ctx.Err() != nil:
+			results[i].Err = ctx.Err()
+		default:
+			results[i].Err = b.SendOne(ctx, msg, email.BatchOptions(opts, &results[i])...)
+		}
+	}
+	if b != nil {
+		_ = b.Commit()
+	}
+	email.EndBatch(ctx, cfg.Hooks, results)
+	return results, email.NewBatchError(results)
+}
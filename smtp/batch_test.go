@@ -0,0 +1,159 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestBatchReusesConnection(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.mu.Lock()
+	srv.rcptReply = map[string]string{"bob@example.org": "550 5.1.1 no such user"}
+	srv.mu.Unlock()
+	m := NewSMTP(srv.config())
+	ctx := context.Background()
+
+	b, err := m.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rcpt := range []string{"ada@example.org", "bob@example.org", "cy@example.org"} {
+		msg := types.Message{
+			From:  types.Address{Mail: "app@example.com"},
+			To:    []types.Address{{Mail: rcpt}},
+			Plain: []byte("hi " + rcpt),
+		}
+		var res email.SendResult
+		err := b.SendOne(ctx, msg, email.WithResult(&res))
+		if rcpt == "bob@example.org" {
+			if err == nil || !strings.Contains(err.Error(), "550") {
+				t.Fatalf("expected bob to be rejected, got %v", err)
+			}
+			continue
+		}
+		if err != nil || !strings.HasPrefix(res.Response, "250") || res.MessageID == "" {
+			t.Fatalf("%s: %v %+v", rcpt, err, res)
+		}
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SendOne(ctx, types.Message{}); err == nil {
+		t.Fatal("SendOne after Commit should fail")
+	}
+
+	if n := srv.connections(); n != 1 {
+		t.Fatalf("connections = %d, want 1", n)
+	}
+	var ehlo, rset int
+	for _, c := range srv.commands() {
+		if strings.HasPrefix(c, "EHLO") {
+			ehlo++
+		}
+		if c == "RSET" {
+			rset++
+		}
+	}
+	if ehlo != 1 || rset != 1 || len(srv.messages()) != 2 {
+		t.Fatalf("ehlo=%d rset=%d messages=%d: %v", ehlo, rset,
+			len(srv.messages()), srv.commands())
+	}
+	if cmds := srv.commands(); cmds[len(cmds)-1] != "QUIT" {
+		t.Fatalf("batch not ended with QUIT: %v", cmds)
+	}
+}
+
+func TestBatchRedialsAndPools(t *testing.T) {
+	srv := newFakeServer(t)
+	cfg := srv.config()
+	cfg.PoolMaxIdle = 1
+	m := NewSMTP(cfg)
+	ctx := context.Background()
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("hi"),
+	}
+
+	b, err := m.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SendOne(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	srv.dropAll()
+	if err := b.SendOne(ctx, msg); err != nil {
+		t.Fatalf("send after drop: %v", err)
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	// The batch connection went back to the pool.
+	if err := m.Send(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.connections(); n != 2 {
+		t.Fatalf("connections = %d, want 2", n)
+	}
+	if n := len(srv.messages()); n != 3 {
+		t.Fatalf("messages = %d, want 3", n)
+	}
+
+	b, err = m.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := make(chan error, 1)
+	go func() { closed <- m.Close(ctx) }()
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Begin(ctx); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Begin after Close: %v", err)
+	}
+}
+
+func TestSendBatch(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.mu.Lock()
+	srv.rcptReply = map[string]string{"bob@example.org": "550 5.1.1 no such user"}
+	srv.mu.Unlock()
+	m := NewSMTP(srv.config())
+	var msgs []types.Message
+	for _, rcpt := range []string{"ada@example.org", "bob@example.org", "cy@example.org"} {
+		msgs = append(msgs, types.Message{
+			From:  types.Address{Mail: "app@example.com"},
+			To:    []types.Address{{Mail: rcpt}},
+			Plain: []byte("hi " + rcpt),
+		})
+	}
+
+	results, err := email.SendBatch(context.Background(), m, msgs)
+	var be *email.BatchError
+	if !errors.As(err, &be) || len(be.Failed) != 1 || be.Failed[0].Index != 1 {
+		t.Fatalf("err = %v", err)
+	}
+	for i, r := range results {
+		if r.Index != i || r.Result.MessageID == "" {
+			t.Fatalf("result %d = %+v", i, r)
+		}
+		if ok := r.Err == nil && strings.HasPrefix(r.Result.Response, "250"); ok == (i == 1) {
+			t.Fatalf("result %d = %+v", i, r)
+		}
+	}
+	if n := srv.connections(); n != 1 {
+		t.Fatalf("connections = %d, want 1", n)
+	}
+	if n := len(srv.messages()); n != 2 {
+		t.Fatalf("messages = %d, want 2", n)
+	}
+}
@@ -0,0 +1,61 @@
+package smtp
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// writeChunk is how much message data is written between checks of the
+// context.
+const writeChunk = 32 << 10
+
+// ctxWriter writes to w in chunks and stops with ctx.Err() once ctx is
+// done.
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw ctxWriter) Write(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if err := cw.ctx.Err(); err != nil {
+			return n, err
+		}
+		end := min(n+writeChunk, len(p))
+		k, err := cw.w.Write(p[n:end])
+		n += k
+		if err != nil {
+			if cerr := cw.ctx.Err(); cerr != nil {
+				return n, cerr
+			}
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// bind sets the connection deadline to ctx's, or to timeout from now,
+// and interrupts pending I/O when ctx is done. The returned stop
+// function clears the deadline and reports whether ctx was still live.
+func (sc *smtpConn) bind(ctx context.Context, timeout time.Duration) func() bool {
+	if sc.nc == nil {
+		return func() bool { return ctx.Err() == nil }
+	}
+	dl, ok := ctx.Deadline()
+	if !ok && timeout > 0 {
+		dl = time.Now().Add(timeout)
+	}
+	_ = sc.nc.SetDeadline(dl)
+	stop := context.AfterFunc(ctx, func() {
+		_ = sc.nc.SetDeadline(time.Now())
+	})
+	return func() bool {
+		if !stop() {
+			return false
+		}
+		_ = sc.nc.SetDeadline(time.Time{})
+		return true
+	}
+}
@@ -0,0 +1,128 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestSendCancelDuringData(t *testing.T) {
+	srv := newFakeServer(t)
+	stall := make(chan struct{})
+	t.Cleanup(func() { close(stall) })
+	srv.mu.Lock()
+	srv.stall = stall
+	srv.mu.Unlock()
+
+	cfg := srv.config()
+	cfg.Timeout = 10 * time.Second
+	cfg.PoolMaxIdle = 1
+	m := NewSMTP(cfg)
+	msg := types.Message{
+		From: types.Address{Mail: "app@example.com"},
+		To:   []types.Address{{Mail: "ada@example.org"}},
+		// Larger than the socket buffers, so the write blocks.
+		Plain: bytes.Repeat([]byte("0123456789abcdef\n"), 1<<18),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var canceled time.Time
+	go func() {
+		// Cancel once the client is blocked writing the message.
+		for len(srv.commands()) < 4 {
+			time.Sleep(5 * time.Millisecond)
+		}
+		time.Sleep(50 * time.Millisecond)
+		canceled = time.Now()
+		cancel()
+	}()
+	err := m.Send(ctx, msg)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if d := time.Since(canceled); d > time.Second {
+		t.Fatalf("cancellation took %v", d)
+	}
+
+	// The interrupted connection is not pooled.
+	srv.mu.Lock()
+	srv.stall = nil
+	srv.mu.Unlock()
+	msg.Plain = []byte("hi")
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if n := srv.connections(); n != 2 {
+		t.Fatalf("connections = %d, want 2", n)
+	}
+}
+
+func TestCtxWriter(t *testing.T) {
+	var buf bytes.Buffer
+	data := bytes.Repeat([]byte("x"), 3*writeChunk+1)
+	n, err := (ctxWriter{context.Background(), &buf}).Write(data)
+	if err != nil || n != len(data) || buf.Len() != len(data) {
+		t.Fatalf("write: %d %v", n, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	buf.Reset()
+	n, err = (ctxWriter{ctx, &buf}).Write(data)
+	if !errors.Is(err, context.Canceled) || n != 0 || buf.Len() != 0 {
+		t.Fatalf("canceled write: %d %v", n, err)
+	}
+}
+
+// blockingDialer blocks every dial until its ctx ends, counting the
+// dials waiting at once.
+type blockingDialer struct {
+	waiting chan struct{}
+}
+
+func (d blockingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	d.waiting <- struct{}{}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestSendCancelDuringPooledDial(t *testing.T) {
+	d := blockingDialer{waiting: make(chan struct{}, 2)}
+	m := NewSMTP(SMTPConfig{
+		Host: "mail.example.test", Port: 25, Timeout: 10 * time.Second,
+		PoolMaxIdle: 2, Dialer: d,
+	})
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("hi"),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() { errs <- m.Send(ctx, msg) }()
+	}
+	// Both sends dial at once, outside the pool lock.
+	for range 2 {
+		select {
+		case <-d.waiting:
+		case <-time.After(2 * time.Second):
+			t.Fatal("pooled dials are serialized")
+		}
+	}
+	cancel()
+	for range 2 {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected context.Canceled, got %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("send did not end with its context")
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrClosed is returned by sends on a closed mailer.
+var ErrClosed = errors.New("smtp: mailer closed")
+
+// begin registers an in-flight send unless the mailer is closed.
+func (m *SMTP) begin() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	m.inflight.Add(1)
+	return nil
+}
+
+// putConn returns conn to the mailer's pool, or closes it if Close has
+// already drained the pool.
+func (m *SMTP) putConn(conn *smtpConn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		_ = conn.c.Quit()
+		return
+	}
+	m.pool.Put(conn)
+}
+
+// Close stops the mailer: later sends fail with ErrClosed, sends in
+// flight are waited for until ctx ends, and idle pooled connections are
+// closed with QUIT. Connections of sends still running when ctx ends
+// are closed when those sends finish. Close is safe to call more than
+// once. A pool passed with email.WithPool is left to its owner.
+//
+// Parameters:
+//   - ctx: The context bounding the wait for in-flight sends.
+//
+// Returns:
+//   - error: ctx.Err() if sends were still in flight when ctx ended.
+func (m *SMTP) Close(ctx context.Context) error {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.inflight.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if m.pool != nil {
+		m.pool.CloseAll()
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestClose(t *testing.T) {
+	srv := newFakeServer(t)
+	cfg := srv.config()
+	cfg.PoolMaxIdle = 2
+	m := NewSMTP(cfg)
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("hi"),
+	}
+	for i := 0; i < 2; i++ {
+		if err := m.Send(context.Background(), msg); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+	if n := srv.connections(); n != 1 {
+		t.Fatalf("pooled connection not reused: %d connections", n)
+	}
+
+	if err := m.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for !slices.Contains(srv.commands(), "QUIT") {
+		if time.Now().After(deadline) {
+			t.Fatal("idle connection not closed with QUIT")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := m.Send(context.Background(), msg); !errors.Is(err, ErrClosed) {
+		t.Fatalf("send after close: %v", err)
+	}
+	if err := m.Close(context.Background()); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+}
+
+func TestCloseWaitsForInflight(t *testing.T) {
+	m := NewSMTP(SMTPConfig{})
+	if err := m.begin(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := m.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	m = NewSMTP(SMTPConfig{})
+	if err := m.begin(); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		m.inflight.Done()
+	}()
+	if err := m.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+}
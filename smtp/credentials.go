@@ -0,0 +1,116 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/smtp"
+)
+
+// errCredentialsRotated is returned by session for a connection that
+// authenticated with credentials the provider no longer returns.
+var errCredentialsRotated = errors.New("smtp: credentials rotated")
+
+// Credentials are the AUTH credentials of a connection. Token, if set,
+// is an OAuth 2.0 access token sent with XOAUTH2 instead of Password
+// with PLAIN.
+type Credentials struct {
+	Username string
+	Password string
+	Token    string
+}
+
+// CredentialsProvider supplies the credentials for SMTPConfig.Credentials,
+// e.g. from Vault or a cloud secrets manager. It is called before every
+// send, so it should cache and return quickly.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// CredentialsFunc adapts a function to CredentialsProvider.
+type CredentialsFunc func(ctx context.Context) (Credentials, error)
+
+// Credentials calls f.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - Credentials: The credentials.
+//   - error: The error fetching them, if any.
+func (f CredentialsFunc) Credentials(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// credentials returns the provider's credentials, or the static ones
+// of the config.
+func (m *SMTP) credentials(ctx context.Context) (Credentials, error) {
+	if m.cfg.Credentials == nil {
+		return Credentials{Username: m.cfg.Username, Password: m.cfg.Password}, nil
+	}
+	creds, err := m.cfg.Credentials.Credentials(ctx)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("smtp credentials: %w", err)
+	}
+	return creds, nil
+}
+
+// SASL mechanisms for SMTPConfig.AuthMechanism.
+const (
+	// AuthAuto uses XOAUTH2 for credentials with a Token, else PLAIN.
+	AuthAuto = ""
+	// AuthPlain sends the password with PLAIN, only over TLS or to
+	// localhost.
+	AuthPlain = "PLAIN"
+	// AuthCRAMMD5 answers a challenge with an HMAC-MD5 of the password,
+	// for legacy servers without TLS. MD5 is not FIPS-approved; builds
+	// with the fips tag leave it out and fail sends that select it.
+	AuthCRAMMD5 = "CRAM-MD5"
+)
+
+// auth returns the AUTH mechanism mech for c, or nil if c is
+// incomplete.
+func (c Credentials) auth(host, mech string) (smtp.Auth, error) {
+	switch {
+	case c.Username == "":
+		return nil, nil
+	case mech == AuthCRAMMD5:
+		return cramMD5Auth(c.Username, c.Password)
+	case mech != AuthAuto && mech != AuthPlain:
+		return nil, fmt.Errorf("smtp: unknown auth mechanism %q", mech)
+	case c.Token != "" && mech == AuthAuto:
+		return &xoauth2Auth{user: c.Username, token: c.Token, host: host}, nil
+	case c.Password != "":
+		return smtp.PlainAuth("", c.Username, c.Password, host), nil
+	}
+	return nil, nil
+}
+
+// xoauth2Auth implements the XOAUTH2 mechanism of Gmail and Microsoft
+// 365. Like PLAIN, it refuses to send the token unencrypted except to
+// localhost.
+type xoauth2Auth struct {
+	user, token, host string
+}
+
+// Start begins the exchange with the initial response.
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	local := server.Name == "localhost" || server.Name == "127.0.0.1" || server.Name == "::1"
+	if !server.TLS && !local {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	resp := "user=" + a.user + "\x01auth=Bearer " + a.token + "\x01\x01"
+	return "XOAUTH2", []byte(resp), nil
+}
+
+// Next answers a challenge, which for XOAUTH2 carries error details;
+// an empty response makes the server send the final failure.
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return []byte{}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,115 @@
+package smtp
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// authLines returns the decoded AUTH responses recorded by srv.
+func authLines(t *testing.T, srv *fakeServer) []string {
+	t.Helper()
+	var out []string
+	for _, c := range srv.commands() {
+		f := strings.Fields(c)
+		if len(f) == 3 && f[0] == "AUTH" {
+			b, err := base64.StdEncoding.DecodeString(f[2])
+			if err != nil {
+				t.Fatalf("decode %q: %v", c, err)
+			}
+			out = append(out, f[1]+" "+string(b))
+		}
+	}
+	return out
+}
+
+func TestCredentialsRotation(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.mu.Lock()
+	srv.ext = []string{"AUTH PLAIN XOAUTH2"}
+	srv.mu.Unlock()
+	srv.setReply("AUTH", "235 2.7.0 ok")
+
+	var mu sync.Mutex
+	creds := Credentials{Username: "app", Password: "one"}
+	cfg := srv.config()
+	cfg.PoolMaxIdle = 1
+	cfg.Credentials = CredentialsFunc(func(ctx context.Context) (Credentials, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return creds, nil
+	})
+	m := NewSMTP(cfg)
+	defer m.Close(context.Background())
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("hi"),
+	}
+	ctx := context.Background()
+	for range 2 {
+		if err := m.Send(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := authLines(t, srv); len(got) != 1 || got[0] != "PLAIN \x00app\x00one" || srv.connections() != 1 {
+		t.Fatalf("auth %q on %d connections", got, srv.connections())
+	}
+
+	mu.Lock()
+	creds = Credentials{Username: "app", Token: "tok"}
+	mu.Unlock()
+	if err := m.Send(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	got := authLines(t, srv)
+	if len(got) != 2 || got[1] != "XOAUTH2 user=app\x01auth=Bearer tok\x01\x01" || srv.connections() != 2 {
+		t.Fatalf("auth %q on %d connections", got, srv.connections())
+	}
+	if n := len(srv.messages()); n != 3 {
+		t.Fatalf("%d messages delivered, want 3", n)
+	}
+}
+
+func TestCredentialsProviderError(t *testing.T) {
+	srv := newFakeServer(t)
+	cfg := srv.config()
+	boom := errors.New("vault sealed")
+	cfg.Credentials = CredentialsFunc(func(ctx context.Context) (Credentials, error) {
+		return Credentials{}, boom
+	})
+	err := NewSMTP(cfg).Send(context.Background(), types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("hi"),
+	})
+	if !errors.Is(err, boom) || len(srv.messages()) != 0 {
+		t.Fatalf("expected provider error, got %v", err)
+	}
+}
+
+func TestCredentialsAuthMechanism(t *testing.T) {
+	c := Credentials{Username: "app", Password: "secret", Token: "tok"}
+	info := &smtp.ServerInfo{Name: "localhost", TLS: true}
+	for _, tc := range []struct{ mech, want string }{
+		{AuthAuto, "XOAUTH2"},
+		{AuthPlain, "PLAIN"},
+	} {
+		a, err := c.auth("localhost", tc.mech)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _, _ := a.Start(info); got != tc.want {
+			t.Fatalf("%q: mechanism %q, want %q", tc.mech, got, tc.want)
+		}
+	}
+	if _, err := c.auth("localhost", "LOGIN"); err == nil {
+		t.Fatal("expected error for unknown mechanism")
+	}
+}
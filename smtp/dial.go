@@ -0,0 +1,149 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// Resolver is the subset of *net.Resolver used to resolve the server.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// defaultAttemptDelay is the RFC 8305 recommended connection attempt
+// delay.
+const defaultAttemptDelay = 250 * time.Millisecond
+
+// dialAll resolves the host of hostPort and races connections to its
+// addresses, starting them one by one.
+func (m *SMTP) dialAll(ctx context.Context, hostPort string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	if net.ParseIP(host) != nil {
+		return d.DialContext(ctx, "tcp", hostPort)
+	}
+	var r Resolver = net.DefaultResolver
+	if m.cfg.Resolver != nil {
+		r = m.cfg.Resolver
+	}
+	ips, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range interleave(ips) {
+		addrs = append(addrs, net.JoinHostPort(ip.String(), port))
+	}
+	delay := m.cfg.DialAttemptDelay
+	if delay <= 0 {
+		delay = defaultAttemptDelay
+	}
+	return dialParallel(ctx, &d, addrs, delay, m.cfg.DialAddrTimeout)
+}
+
+// interleave orders addresses by alternating families, starting with
+// the family of the first one (RFC 8305 section 4).
+func interleave(ips []net.IPAddr) []net.IPAddr {
+	var first, other []net.IPAddr
+	v4 := ips[0].IP.To4() != nil
+	for _, ip := range ips {
+		if (ip.IP.To4() != nil) == v4 {
+			first = append(first, ip)
+		} else {
+			other = append(other, ip)
+		}
+	}
+	out := make([]net.IPAddr, 0, len(ips))
+	for i := 0; i < len(first) || i < len(other); i++ {
+		if i < len(first) {
+			out = append(out, first[i])
+		}
+		if i < len(other) {
+			out = append(out, other[i])
+		}
+	}
+	return out
+}
+
+// dialParallel dials addrs in order, starting the next attempt after
+// delay or when the previous one fails, and returns the first
+// connection. Each attempt is bounded by perAddr if positive; ending
+// ctx stops them all.
+func dialParallel(
+	ctx context.Context,
+	d *net.Dialer,
+	addrs []string,
+	delay, perAddr time.Duration,
+) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		c   net.Conn
+		err error
+	}
+	results := make(chan result)
+	next, pending := 0, 0
+	start := func() {
+		addr := addrs[next]
+		next++
+		pending++
+		go func() {
+			actx := ctx
+			if perAddr > 0 {
+				var cancel context.CancelFunc
+				actx, cancel = context.WithTimeout(ctx, perAddr)
+				defer cancel()
+			}
+			c, err := d.DialContext(actx, "tcp", addr)
+			select {
+			case results <- result{c, err}:
+			case <-ctx.Done():
+				// Another attempt won.
+				if c != nil {
+					_ = c.Close()
+				}
+			}
+		}()
+	}
+
+	start()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var errs []error
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				return r.c, nil
+			}
+			errs = append(errs, r.err)
+			if next < len(addrs) {
+				start()
+				timer.Reset(delay)
+			}
+		case <-timer.C:
+			if next < len(addrs) {
+				start()
+				timer.Reset(delay)
+			}
+		case <-ctx.Done():
+			// The attempts drop their results and close what they
+			// connected.
+			return nil, ctx.Err()
+		}
+	}
+	if len(errs) == 1 {
+		return nil, errs[0]
+	}
+	return nil, errors.Join(errs...)
+}
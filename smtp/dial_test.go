@@ -0,0 +1,97 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// fakeResolver resolves every host to ips.
+type fakeResolver []string
+
+func (r fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	var out []net.IPAddr
+	for _, s := range r {
+		out = append(out, net.IPAddr{IP: net.ParseIP(s)})
+	}
+	return out, nil
+}
+
+func TestInterleave(t *testing.T) {
+	var ips []net.IPAddr
+	for _, s := range []string{"2001:db8::1", "2001:db8::2", "2001:db8::3", "192.0.2.1"} {
+		ips = append(ips, net.IPAddr{IP: net.ParseIP(s)})
+	}
+	var got []string
+	for _, ip := range interleave(ips) {
+		got = append(got, ip.String())
+	}
+	want := "2001:db8::1 192.0.2.1 2001:db8::2 2001:db8::3"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("got %v, want %s", got, want)
+	}
+}
+
+func TestSendDialFallback(t *testing.T) {
+	srv := newFakeServer(t)
+	cfg := srv.config()
+	cfg.Host = "mail.example.test"
+	// An unroutable address that hangs or fails, one that refuses,
+	// then the server.
+	cfg.Resolver = fakeResolver{"192.0.2.1", "127.0.0.2", "127.0.0.1"}
+	cfg.DialAttemptDelay = 50 * time.Millisecond
+	cfg.DialAddrTimeout = 500 * time.Millisecond
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("hi"),
+	}
+	start := time.Now()
+	if err := NewSMTP(cfg).Send(context.Background(), msg); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("fallback took %v", d)
+	}
+	if srv.connections() != 1 {
+		t.Fatalf("connections = %d", srv.connections())
+	}
+
+	cfg.Resolver = fakeResolver{"127.0.0.2", "127.0.0.3"}
+	err := NewSMTP(cfg).Send(context.Background(), msg)
+	if err == nil || !strings.Contains(err.Error(), "127.0.0.2") ||
+		!strings.Contains(err.Error(), "127.0.0.3") {
+		t.Fatalf("expected errors for both addresses, got %v", err)
+	}
+}
+
+func TestDialParallelContextEnds(t *testing.T) {
+	// Attempts that only return once ctx ends.
+	d := &net.Dialer{ControlContext: func(ctx context.Context, network, address string, c syscall.RawConn) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	for range 40 {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		done := make(chan error, 1)
+		go func() {
+			_, err := dialParallel(ctx, d, []string{"127.0.0.1:1", "127.0.0.1:2"}, time.Millisecond, 0)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("err = %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("dialParallel did not return after ctx ended")
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,249 @@
+package smtp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer is a scripted SMTP server for adapter tests. It records
+// commands and message data; reply overrides responses by command verb.
+type fakeServer struct {
+	t  *testing.T
+	ln net.Listener
+
+	mu    sync.Mutex
+	cmds  []string
+	data  []string
+	ext   []string
+	reply map[string]string
+	conns int
+	open  []net.Conn
+
+	// stall, if set, blocks the DATA phase after the 354 reply until it
+	// is closed, like a relay that stops reading.
+	stall chan struct{}
+
+	// lmtp makes the server reply after the message once per accepted
+	// recipient, with lmtpReplies in order if set.
+	lmtp        bool
+	lmtpReplies []string
+	// rcptReply overrides the RCPT reply for an address.
+	rcptReply map[string]string
+	// maxSize, if set, rejects larger DATA payloads with 552.
+	maxSize int
+}
+
+// newFakeServer starts a fake server listening on localhost.
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	return startFakeServer(t, ln)
+}
+
+// newFakeTLSServer starts a fake server speaking implicit TLS with cert.
+func newFakeTLSServer(t *testing.T, cert tls.Certificate) *fakeServer {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0",
+		&tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	return startFakeServer(t, ln)
+}
+
+// startFakeServer serves on ln until the test ends.
+func startFakeServer(t *testing.T, ln net.Listener) *fakeServer {
+	s := &fakeServer{t: t, ln: ln, reply: map[string]string{},
+		ext: []string{"8BITMIME", "PIPELINING"}}
+	go s.serve()
+	t.Cleanup(func() { _ = ln.Close() })
+	return s
+}
+
+// config returns an SMTPConfig pointing at the fake server.
+func (s *fakeServer) config() SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return SMTPConfig{Host: host, Port: p, Timeout: 2 * time.Second}
+}
+
+// setReply overrides the reply for a command verb (e.g. "RCPT").
+func (s *fakeServer) setReply(verb, reply string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reply[verb] = reply
+}
+
+// commands returns the recorded commands.
+func (s *fakeServer) commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.cmds...)
+}
+
+// messages returns the recorded DATA and BDAT payloads.
+func (s *fakeServer) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.data...)
+}
+
+// connections returns the number of accepted connections.
+func (s *fakeServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+// dropAll closes every accepted connection, like a server timing out
+// idle clients.
+func (s *fakeServer) dropAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.open {
+		_ = c.Close()
+	}
+	s.open = nil
+}
+
+func (s *fakeServer) serve() {
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns++
+		s.open = append(s.open, c)
+		s.mu.Unlock()
+		go s.handle(c)
+	}
+}
+
+func (s *fakeServer) handle(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	write := func(line string) { _, _ = c.Write([]byte(line + "\r\n")) }
+	write("220 fake ESMTP")
+	accepted := 0
+	// endData writes the replies after the message.
+	endData := func(def string) {
+		s.mu.Lock()
+		lmtp, replies := s.lmtp, s.lmtpReplies
+		s.mu.Unlock()
+		if !lmtp {
+			write(def)
+			return
+		}
+		for i := 0; i < accepted; i++ {
+			if i < len(replies) {
+				write(replies[i])
+			} else {
+				write("250 2.1.5 delivered")
+			}
+		}
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.Fields(line + " x")[0])
+		s.mu.Lock()
+		s.cmds = append(s.cmds, line)
+		override, ok := s.reply[verb]
+		ext := append([]string(nil), s.ext...)
+		s.mu.Unlock()
+		if ok {
+			for _, l := range strings.Split(override, "\n") {
+				write(l)
+			}
+			if strings.HasPrefix(override, "4") ||
+				strings.HasPrefix(override, "5") || verb != "DATA" {
+				if verb == "QUIT" {
+					return
+				}
+				continue
+			}
+		}
+		switch verb {
+		case "EHLO", "LHLO":
+			write("250-fake")
+			for _, e := range ext {
+				write("250-" + e)
+			}
+			write("250 HELP")
+		case "DATA":
+			if !ok {
+				write("354 go ahead")
+			}
+			s.mu.Lock()
+			stall := s.stall
+			s.mu.Unlock()
+			if stall != nil {
+				<-stall
+			}
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				b.WriteString(l)
+			}
+			s.mu.Lock()
+			s.data = append(s.data, b.String())
+			tooLarge := s.maxSize > 0 && b.Len() > s.maxSize
+			s.mu.Unlock()
+			if tooLarge {
+				write("552 5.3.4 message size exceeds fixed limit")
+				continue
+			}
+			endData("250 2.0.0 Ok: queued as ABC123")
+		case "BDAT":
+			f := strings.Fields(line)
+			n, _ := strconv.Atoi(f[1])
+			chunk := make([]byte, n)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = append(s.data, string(chunk))
+			s.mu.Unlock()
+			endData("250 2.0.0 Ok: queued as BDAT1")
+		case "MAIL", "RSET":
+			accepted = 0
+			write("250 ok")
+		case "RCPT":
+			addr := strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>")
+			s.mu.Lock()
+			r, ok := s.rcptReply[addr]
+			s.mu.Unlock()
+			if ok {
+				write(r)
+				continue
+			}
+			accepted++
+			write("250 ok")
+		case "QUIT":
+			write("221 bye")
+			return
+		default:
+			write("250 ok")
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// sendEach delivers msg to each recipient in its own transaction over a
+// shared connection (WithFanOut). Recipients that fail transiently are
+// retried on the backoff schedule; the others are reported as is.
+func (m *SMTP) sendEach(
+	ctx context.Context,
+	msg types.Message,
+	cfg *email.SendConfig,
+	bopts internal.BuildOptions,
+) error {
+	fo := cfg.FanOut
+	rcpts := msg.EnvelopeRecipients()
+	if len(rcpts) == 0 {
+		return types.ErrNoRecipients
+	}
+	if cfg.ArchiveCopy != "" && cfg.ArchiveMode == email.ArchiveRequired {
+		return errors.New("smtp: WithFanOut supports only ArchiveBestEffort archive copies")
+	}
+	res := cfg.Result
+	if res == nil {
+		res = &email.SendResult{}
+	}
+	*res = email.SendResult{Recipients: make([]email.RecipientResult, len(rcpts))}
+	for i, rcpt := range rcpts {
+		from := msg.EnvelopeSender()
+		if cfg.EnvelopeFrom != "" {
+			from = cfg.EnvelopeFrom
+		}
+		if fo.VERP != "" {
+			v, err := email.VERP(fo.VERP, rcpt)
+			if err != nil {
+				return err
+			}
+			from = v
+		}
+		res.Recipients[i] = email.RecipientResult{Recipient: rcpt, EnvelopeFrom: from}
+	}
+
+	// Personalized copies are built per recipient, so attachment
+	// readers are buffered to be read more than once.
+	var attach func() []types.Attachment
+	if fo.PersonalizeTo {
+		var err error
+		if attach, err = bufferAttachments(msg.Attach); err != nil {
+			return err
+		}
+	}
+	// Per-recipient results stay in res; a blocked copy fails only its
+	// recipient.
+	spamCfg := email.SendConfig{SpamCheck: cfg.SpamCheck}
+	var shared *internal.Built
+	build := func(rcpt string) (*internal.Built, error) {
+		if !fo.PersonalizeTo {
+			if shared == nil {
+				b, err := internal.Build(ctx, msg, bopts)
+				if err != nil {
+					return nil, err
+				}
+				if shared, _, err = checkSpam(ctx, &spamCfg, b); err != nil {
+					return nil, err
+				}
+			}
+			return shared, nil
+		}
+		pm := msg
+		pm.To = []types.Address{addressFor(msg, rcpt)}
+		pm.Cc, pm.Bcc = nil, nil
+		pm.Attach = attach()
+		b, err := internal.Build(ctx, pm, bopts)
+		if err != nil {
+			return nil, err
+		}
+		b, _, err = checkSpam(ctx, &spamCfg, b)
+		return b, err
+	}
+
+	var bo email.Backoff = &singleAttempt{}
+	if cfg.Backoff != nil {
+		bo = cfg.Backoff
+	}
+	pending := make([]int, len(rcpts))
+	for i := range pending {
+		pending[i] = i
+	}
+	checked := false
+	// sent is the first delivered copy, stored with WithSentCopy and
+	// sent as the archive copy.
+	var sent *internal.Built
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if cfg.Hooks != nil && cfg.Hooks.OnAttemptStart != nil {
+			ctx = cfg.Hooks.OnAttemptStart(ctx, attempt)
+		}
+		d, ok := bo.Next(attempt)
+		if !ok {
+			break
+		}
+		if d > 0 {
+			select {
+			case <-time.After(d):
+			case <-ctx.Done():
+				for _, i := range pending {
+					res.Recipients[i].Err = ctx.Err()
+				}
+				pending = nil
+				continue
+			}
+		}
+
+		res.Attempts = attempt + 1
+		var retry []int
+		err := m.withConn(ctx, cfg, func(conn *smtpConn) error {
+			c := conn.c
+			if !checked {
+				// Build only what this server accepts.
+				if bopts.Allow8Bit && !conn.caps.Has("8BITMIME") {
+					bopts.Allow8Bit = false
+					fallback(ctx, cfg, res, "server lacks 8BITMIME; building with 7-bit encodings")
+				}
+				if bopts.AllowBinary && !(conn.caps.Has("BINARYMIME") && conn.caps.Has("CHUNKING")) {
+					bopts.AllowBinary = false
+					fallback(ctx, cfg, res, "server lacks BINARYMIME or CHUNKING; building without binary bodies")
+				}
+				checked = true
+			}
+			for len(pending) > 0 {
+				i := pending[0]
+				pending = pending[1:]
+				r := &res.Recipients[i]
+				b, err := build(r.Recipient)
+				if err != nil {
+					r.Err = err
+					continue
+				}
+				r.MessageID = b.MessageID
+				r.Response, r.Err = transaction(ctx, conn, r.EnvelopeFrom,
+					[]string{r.Recipient}, b)
+				if r.Err == nil {
+					if sent == nil {
+						sent = b
+					}
+					continue
+				}
+				if isTransient(r.Err) {
+					retry = append(retry, i)
+				}
+				if err := c.Reset(); err != nil {
+					return commandError("RSET", "", err)
+				}
+			}
+			return nil
+		})
+		pending = append(retry, pending...)
+		if cfg.Hooks != nil && cfg.Hooks.OnAttemptDone != nil {
+			cfg.Hooks.OnAttemptDone(ctx, attempt, err)
+		}
+		if err != nil && !isTransient(err) {
+			for _, i := range pending {
+				res.Recipients[i].Err = err
+			}
+			pending = nil
+		}
+	}
+	for _, i := range pending {
+		if res.Recipients[i].Err == nil {
+			res.Recipients[i].Err = errors.New("send attempts exhausted")
+		}
+	}
+
+	res.MessageID = res.Recipients[0].MessageID
+	if shared != nil {
+		res.Size = len(shared.Raw)
+		res.AttachmentSizes = shared.AttachmentSizes
+		res.Warnings = shared.Warnings
+	}
+	if cfg.MessageIDOut != nil {
+		*cfg.MessageIDOut = res.MessageID
+	}
+	if sent != nil {
+		copySent(ctx, cfg, res, sent.Raw)
+		from := msg.EnvelopeSender()
+		if cfg.EnvelopeFrom != "" {
+			from = cfg.EnvelopeFrom
+		}
+		m.archiveCopy(ctx, cfg, res, from, sent)
+	}
+	var errs []error
+	for _, r := range res.Recipients {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Recipient, r.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// addressFor returns the message address for rcpt, keeping its display
+// name but not its group.
+func addressFor(msg types.Message, rcpt string) types.Address {
+	for _, list := range [][]types.Address{msg.To, msg.Cc, msg.Bcc} {
+		for _, a := range list {
+			if strings.TrimSpace(a.Mail) == rcpt {
+				a.Group = ""
+				return a
+			}
+		}
+	}
+	return types.Address{Mail: rcpt}
+}
+
+// bufferAttachments reads the attachment contents once and returns a
+// function producing fresh copies with new readers.
+func bufferAttachments(in []types.Attachment) (func() []types.Attachment, error) {
+	data := make([][]byte, len(in))
+	for i, a := range in {
+		if a.Reader == nil {
+			continue
+		}
+		b, err := io.ReadAll(a.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("read attachment %q: %w", a.Filename, err)
+		}
+		data[i] = b
+	}
+	return func() []types.Attachment {
+		out := make([]types.Attachment, len(in))
+		for i, a := range in {
+			a.Reader = bytes.NewReader(data[i])
+			out[i] = a
+		}
+		return out
+	}, nil
+}
@@ -0,0 +1,216 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// lhloConn turns the EHLO that net/smtp sends into LHLO, which net/smtp
+// cannot send itself.
+type lhloConn struct {
+	net.Conn
+}
+
+func (c *lhloConn) Write(p []byte) (int, error) {
+	if bytes.HasPrefix(p, []byte("EHLO ")) {
+		q := append([]byte("LHLO "), p[5:]...)
+		return c.Conn.Write(q)
+	}
+	return c.Conn.Write(p)
+}
+
+// deliverLMTP sends built to rcpts over LMTP, filling res.Recipients
+// with each recipient's reply. Recipients that fail transiently are
+// retried on the backoff schedule; delivered ones are not sent again.
+func (m *SMTP) deliverLMTP(
+	ctx context.Context,
+	built *internal.Built,
+	from string,
+	rcpts []string,
+	cfg *email.SendConfig,
+	res *email.SendResult,
+	rebuild func() (*internal.Built, error),
+) error {
+	if len(rcpts) == 0 {
+		return types.ErrNoRecipients
+	}
+	res.Recipients = make([]email.RecipientResult, len(rcpts))
+	pending := make([]int, len(rcpts))
+	for i, rcpt := range rcpts {
+		res.Recipients[i] = email.RecipientResult{
+			Recipient: rcpt, EnvelopeFrom: from, MessageID: built.MessageID,
+		}
+		pending[i] = i
+	}
+
+	var bo email.Backoff = &singleAttempt{}
+	if cfg.Backoff != nil {
+		bo = cfg.Backoff
+	}
+	delivered := false
+	var last error // last transient transaction error
+	for attempt := 0; len(pending) > 0; {
+		if cfg.Hooks != nil && cfg.Hooks.OnAttemptStart != nil {
+			ctx = cfg.Hooks.OnAttemptStart(ctx, attempt)
+		}
+		d, ok := bo.Next(attempt)
+		if !ok {
+			break
+		}
+		if d > 0 {
+			select {
+			case <-time.After(d):
+			case <-ctx.Done():
+				for _, i := range pending {
+					res.Recipients[i].Err = ctx.Err()
+				}
+				pending = nil
+				continue
+			}
+		}
+
+		res.Attempts = attempt + 1
+		batch := make([]string, len(pending))
+		for k, i := range pending {
+			batch[k] = rcpts[i]
+		}
+		var replies []lmtpReply
+		err := m.withConn(ctx, cfg, func(conn *smtpConn) error {
+			var err error
+			replies, err = lmtpTransaction(ctx, conn, from, batch, built)
+			return err
+		})
+		if cfg.Hooks != nil && cfg.Hooks.OnAttemptDone != nil {
+			cfg.Hooks.OnAttemptDone(ctx, attempt, err)
+		}
+		if errors.Is(err, errBodyUnsupported) && rebuild != nil {
+			fallback(ctx, cfg, res, err.Error()+"; rebuilt with 7-bit encodings")
+			if built, err = rebuild(); err != nil {
+				return err
+			}
+			rebuild = nil
+			res.Size = len(built.Raw)
+			continue
+		}
+		if err != nil {
+			if !isTransient(err) {
+				for _, i := range pending {
+					res.Recipients[i].Err = err
+				}
+				pending = nil
+			}
+			last = err
+			attempt++
+			continue
+		}
+		var retry []int
+		for k, i := range pending {
+			r := &res.Recipients[i]
+			r.Response, r.Err = replies[k].resp, replies[k].err
+			if r.Err == nil {
+				if !delivered {
+					res.Response = r.Response
+				}
+				delivered = true
+			} else if isTransient(r.Err) {
+				retry = append(retry, i)
+			}
+		}
+		pending = retry
+		attempt++
+	}
+	for _, i := range pending {
+		if res.Recipients[i].Err == nil {
+			res.Recipients[i].Err = errors.New("send attempts exhausted")
+			if last != nil {
+				res.Recipients[i].Err = fmt.Errorf("send attempts exhausted: %w", last)
+			}
+		}
+	}
+
+	if delivered {
+		copySent(ctx, cfg, res, built.Raw)
+		m.archiveCopy(ctx, cfg, res, from, built)
+	}
+	var errs []error
+	for _, r := range res.Recipients {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Recipient, r.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lmtpReply is the outcome for one recipient of an LMTP transaction.
+type lmtpReply struct {
+	resp string
+	err  error
+}
+
+// lmtpTransaction runs one LMTP transaction. Recipients rejected at RCPT
+// are skipped; after the message, the server replies once for each
+// accepted recipient (RFC 2033 4.2). The error is for the transaction
+// as a whole.
+func lmtpTransaction(
+	ctx context.Context,
+	conn *smtpConn,
+	from string,
+	rcpts []string,
+	built *internal.Built,
+) ([]lmtpReply, error) {
+	if err := checkBodyType(conn.caps, built.BodyType); err != nil {
+		return nil, err
+	}
+	if err := checkSize(conn.caps, built); err != nil {
+		return nil, err
+	}
+	c := conn.c
+	if err := mailFrom(c, from, built.BodyType); err != nil {
+		return nil, commandError("MAIL FROM", "", err)
+	}
+	replies := make([]lmtpReply, len(rcpts))
+	var accepted []int
+	for i, rcpt := range rcpts {
+		if err := c.Rcpt(rcpt); err != nil {
+			replies[i].err = commandError("RCPT TO", rcpt, err)
+			continue
+		}
+		accepted = append(accepted, i)
+	}
+	if len(accepted) == 0 {
+		if err := c.Reset(); err != nil {
+			return nil, commandError("RSET", "", err)
+		}
+		return replies, nil
+	}
+
+	if err := writeBody(ctx, c, built); err != nil {
+		return nil, err
+	}
+	for k, i := range accepted {
+		code, text, err := c.Text.ReadResponse(250)
+		if err == nil {
+			replies[i].resp = fmt.Sprintf("%d %s", code, text)
+			continue
+		}
+		replies[i].err = commandError("end data", "", err)
+		var tpErr *textproto.Error
+		if !errors.As(err, &tpErr) {
+			// The remaining replies are lost with the connection.
+			for _, j := range accepted[k+1:] {
+				replies[j].err = replies[i].err
+			}
+			break
+		}
+	}
+	return replies, nil
+}
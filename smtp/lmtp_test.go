@@ -0,0 +1,94 @@
+package smtp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestSendLMTP(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.mu.Lock()
+	srv.lmtp = true
+	srv.lmtpReplies = []string{"250 2.1.5 delivered", "452 4.2.2 mailbox full"}
+	srv.rcptReply = map[string]string{"bob@example.org": "550 5.1.1 no such user"}
+	srv.mu.Unlock()
+
+	cfg := srv.config()
+	cfg.LMTP = true
+	msg := types.Message{
+		From: types.Address{Mail: "app@example.com"},
+		To: []types.Address{
+			{Mail: "ada@example.org"},
+			{Mail: "bob@example.org"},
+			{Mail: "cy@example.org"},
+		},
+		Plain: []byte("hi"),
+	}
+	var res email.SendResult
+	err := NewSMTP(cfg).Send(context.Background(), msg,
+		email.WithRetry(email.ExponentialBackoff(2, time.Millisecond,
+			time.Millisecond, false)),
+		email.WithResult(&res))
+	if err == nil || !strings.Contains(err.Error(), "bob@example.org") ||
+		strings.Contains(err.Error(), "cy@example.org") {
+		t.Fatalf("expected only bob to fail, got %v", err)
+	}
+	cmds := srv.commands()
+	if !strings.HasPrefix(cmds[0], "LHLO ") {
+		t.Fatalf("LHLO not sent: %v", cmds)
+	}
+	for _, c := range cmds {
+		if strings.HasPrefix(c, "EHLO") || strings.HasPrefix(c, "HELO") {
+			t.Fatalf("unexpected %q", c)
+		}
+	}
+	if res.Attempts != 2 || len(srv.messages()) != 2 {
+		t.Fatalf("attempts = %d, messages = %d", res.Attempts, len(srv.messages()))
+	}
+	if len(res.Recipients) != 3 {
+		t.Fatalf("recipients: %+v", res.Recipients)
+	}
+	ada, bob, cy := res.Recipients[0], res.Recipients[1], res.Recipients[2]
+	if ada.Err != nil || ada.Response != "250 2.1.5 delivered" {
+		t.Fatalf("ada: %+v", ada)
+	}
+	if bob.Err == nil || !strings.Contains(bob.Err.Error(), "550") {
+		t.Fatalf("bob: %+v", bob)
+	}
+	// cy got 452 first and was delivered on the retry, alone.
+	if cy.Err != nil || cy.Response != "250 2.1.5 delivered" {
+		t.Fatalf("cy: %+v", cy)
+	}
+	var rcpts []string
+	for _, c := range cmds {
+		if strings.HasPrefix(c, "RCPT") {
+			rcpts = append(rcpts, c)
+		}
+	}
+	if len(rcpts) != 4 || rcpts[3] != "RCPT TO:<cy@example.org>" {
+		t.Fatalf("RCPT commands: %v", rcpts)
+	}
+	if res.MessageID == "" || res.Response != "250 2.1.5 delivered" {
+		t.Fatalf("result: %+v", res)
+	}
+}
+
+func TestLMTPRejectsStartTLS(t *testing.T) {
+	srv := newFakeServer(t)
+	cfg := srv.config()
+	cfg.LMTP, cfg.StartTLS = true, true
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("hi"),
+	}
+	err := NewSMTP(cfg).Send(context.Background(), msg)
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS is not supported with LMTP") {
+		t.Fatalf("expected STARTTLS error, got %v", err)
+	}
+}
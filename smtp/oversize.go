@@ -0,0 +1,64 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"strings"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// isTooLarge reports whether err is a rejection of the message size:
+// reply code 552 or enhanced status 5.3.4, or a message over the
+// advertised SIZE.
+func isTooLarge(err error) bool {
+	var se *types.SizeError
+	if errors.As(err, &se) && se.Attachment == "" {
+		return true
+	}
+	var te *textproto.Error
+	if !errors.As(err, &te) {
+		return false
+	}
+	return te.Code == 552 || strings.HasPrefix(te.Msg, "5.3.4")
+}
+
+// sendLinked retries msg with its attachments, fresh from attach,
+// replaced by download links (WithOversizeFallback), keeping the
+// Message-ID of the rejected message. It returns rejected if no
+// attachment qualifies.
+func (m *SMTP) sendLinked(
+	ctx context.Context,
+	msg types.Message,
+	attach []types.Attachment,
+	messageID string,
+	from string,
+	cfg *email.SendConfig,
+	bopts internal.BuildOptions,
+	rejected error,
+) error {
+	msg.Attach = attach
+	msg, linked, err := email.LinkAttachments(ctx, msg, *cfg.Oversize)
+	if err != nil {
+		return errors.Join(rejected, err)
+	}
+	if len(linked) == 0 {
+		return rejected
+	}
+	if !msg.Headers.Has("Message-ID") {
+		msg.Headers = msg.CloneHeaders()
+		msg.Headers.Set("Message-ID", messageID)
+	}
+	built, rebuild, err := buildSend(ctx, msg, bopts)
+	if err != nil {
+		return err
+	}
+	err = m.deliver(ctx, built, from, msg.EnvelopeRecipients(), cfg, rebuild)
+	if cfg.Result != nil {
+		cfg.Result.LinkedAttachments = linked
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestSendOversizeFallback(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.maxSize = 4096
+	m := NewSMTP(srv.config())
+	newMsg := func() types.Message {
+		return types.Message{
+			From:    types.Address{Mail: "app@example.com"},
+			To:      []types.Address{{Mail: "ada@example.org"}},
+			Subject: "Report",
+			Plain:   []byte("See the report."),
+			Attach: []types.Attachment{
+				{Filename: "report.pdf", ContentType: "application/pdf",
+					Reader: bytes.NewReader(bytes.Repeat([]byte("x"), 8000))},
+				{Filename: "logo.png", ContentType: "image/png", ContentID: "logo",
+					Reader: strings.NewReader("png")},
+			},
+		}
+	}
+	var uploaded []byte
+	expires := time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC)
+	fallback := email.OversizeFallback{
+		Upload: func(ctx context.Context, a types.Attachment) (string, time.Time, error) {
+			b := new(bytes.Buffer)
+			_, _ = b.ReadFrom(a.Reader)
+			uploaded = b.Bytes()
+			return "https://files.example.com/r/1", expires, nil
+		},
+	}
+
+	var res email.SendResult
+	err := m.Send(context.Background(), newMsg(),
+		email.WithOversizeFallback(fallback), email.WithResult(&res))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := srv.messages()
+	if len(msgs) != 2 || len(uploaded) != 8000 {
+		t.Fatalf("messages = %d, uploaded = %d", len(msgs), len(uploaded))
+	}
+	got := msgs[1]
+	if strings.Contains(got, "report.pdf\"") || !strings.Contains(got, "logo.png") ||
+		!strings.Contains(got, "https://files.example.com/r/1") ||
+		!strings.Contains(got, "Message-ID: "+res.MessageID+"\r\n") ||
+		!strings.Contains(msgs[0], "Message-ID: "+res.MessageID+"\r\n") {
+		t.Fatalf("unexpected retry %+v:\n%s", res, got)
+	}
+	if len(res.LinkedAttachments) != 1 || res.LinkedAttachments[0].Filename != "report.pdf" ||
+		!res.LinkedAttachments[0].Expires.Equal(expires) {
+		t.Fatalf("linked = %+v", res.LinkedAttachments)
+	}
+
+	// Without the option the rejection is returned.
+	err = m.Send(context.Background(), newMsg())
+	if err == nil || !isTooLarge(err) {
+		t.Fatalf("expected 552, got %v", err)
+	}
+
+	// A failed upload keeps the rejection.
+	fallback.Upload = func(context.Context, types.Attachment) (string, time.Time, error) {
+		return "", time.Time{}, errors.New("bucket unavailable")
+	}
+	err = m.Send(context.Background(), newMsg(), email.WithOversizeFallback(fallback))
+	if !isTooLarge(err) || !strings.Contains(err.Error(), "bucket unavailable") {
+		t.Fatalf("expected joined error, got %v", err)
+	}
+}
+
+func TestSendAttachmentLinks(t *testing.T) {
+	srv := newFakeServer(t)
+	msg := types.Message{
+		From:   types.Address{Mail: "app@example.com"},
+		To:     []types.Address{{Mail: "ada@example.org"}},
+		Plain:  []byte("Hi"),
+		Attach: []types.Attachment{{Filename: "big.bin", Reader: strings.NewReader("data")}},
+	}
+	err := NewSMTP(srv.config()).Send(context.Background(), msg,
+		email.WithAttachmentLinks(email.AttachmentLinkPolicy{
+			Uploader: email.UploadFunc(func(context.Context, types.Attachment) (string, time.Time, error) {
+				return "https://files.example.com/big.bin", time.Time{}, nil
+			}),
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := srv.messages()[0]
+	if strings.Contains(got, "filename=") || !strings.Contains(got, "https://files.example.com/big.bin") {
+		t.Fatalf("attachment not linked:\n%s", got)
+	}
+}
@@ -0,0 +1,130 @@
+package smtp
+
+import (
+	"context"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// knownExtensions are the EHLO keywords reported by Capabilities.
+var knownExtensions = []string{
+	"8BITMIME", "AUTH", "BINARYMIME", "CHUNKING", "DSN",
+	"ENHANCEDSTATUSCODES", "PIPELINING", "REQUIRETLS", "SIZE",
+	"SMTPUTF8", "STARTTLS",
+}
+
+// Capabilities describes the server: what its EHLO reply advertised,
+// as recorded on every connection, and for a health check the latency.
+type Capabilities struct {
+	TLS        bool              // the connection is encrypted
+	Extensions map[string]string // advertised known extensions and their parameters
+	MaxSize    int64             // SIZE limit in bytes; 0 if none advertised
+	Auth       []string          // AUTH mechanisms, e.g. PLAIN, LOGIN
+	Latency    time.Duration     // round trip of the NOOP
+}
+
+// Ping checks that the server is reachable and accepts commands, e.g.
+// for a readiness probe; see Capabilities.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - error: The error if the server cannot be reached, the TLS
+//     handshake or AUTH fails, or NOOP is refused.
+func (m *SMTP) Ping(ctx context.Context) error {
+	_, err := m.Capabilities(ctx)
+	return err
+}
+
+// Capabilities borrows a pooled connection, or dials one with EHLO,
+// TLS and AUTH as for Send, sends NOOP and reports what the server
+// advertised. The connection is returned to the pool.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - Capabilities: The server capabilities.
+//   - error: The error if the server cannot be reached, the TLS
+//     handshake or AUTH fails, or NOOP is refused.
+func (m *SMTP) Capabilities(ctx context.Context) (Capabilities, error) {
+	if err := m.begin(); err != nil {
+		return Capabilities{}, err
+	}
+	defer m.inflight.Done()
+
+	var caps Capabilities
+	var cfg email.SendConfig
+	err := m.withConn(ctx, &cfg, func(conn *smtpConn) error {
+		start := time.Now()
+		if err := conn.c.Noop(); err != nil {
+			return commandError("NOOP", "", err)
+		}
+		caps = conn.caps
+		caps.Latency = time.Since(start)
+		return nil
+	})
+	return caps, err
+}
+
+// Has reports whether the server advertised ext, e.g. "PIPELINING".
+//
+// Parameters:
+//   - ext: The EHLO keyword, in any case.
+//
+// Returns:
+//   - bool: True if advertised.
+func (c Capabilities) Has(ext string) bool {
+	_, ok := c.Extensions[strings.ToUpper(ext)]
+	return ok
+}
+
+// checkSize fails a message larger than the advertised SIZE before it
+// is sent, with the error isTooLarge accepts.
+func checkSize(caps Capabilities, built *internal.Built) error {
+	if caps.MaxSize > 0 && int64(len(built.Raw)) > caps.MaxSize {
+		return &types.SizeError{Size: int64(len(built.Raw)), Limit: caps.MaxSize}
+	}
+	return nil
+}
+
+// fallback records in res, and reports to Hooks.OnFallback, that the
+// message was sent without a feature the server lacks.
+func fallback(
+	ctx context.Context,
+	cfg *email.SendConfig,
+	res *email.SendResult,
+	reason string,
+) {
+	res.Fallbacks = append(res.Fallbacks, reason)
+	if cfg.Hooks != nil && cfg.Hooks.OnFallback != nil {
+		cfg.Hooks.OnFallback(ctx, reason)
+	}
+}
+
+// capabilities reads the EHLO reply recorded by c.
+func capabilities(c *smtp.Client) Capabilities {
+	_, encrypted := c.TLSConnectionState()
+	caps := Capabilities{TLS: encrypted, Extensions: map[string]string{}}
+	for _, ext := range knownExtensions {
+		ok, param := c.Extension(ext)
+		if !ok {
+			continue
+		}
+		caps.Extensions[ext] = param
+		switch ext {
+		case "SIZE":
+			caps.MaxSize, _ = strconv.ParseInt(param, 10, 64)
+		case "AUTH":
+			caps.Auth = strings.Fields(param)
+		}
+	}
+	return caps
+}
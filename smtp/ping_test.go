@@ -0,0 +1,89 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestCapabilities(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.mu.Lock()
+	srv.ext = []string{"SIZE 1000", "8BITMIME", "AUTH PLAIN LOGIN", "X-UNKNOWN"}
+	srv.mu.Unlock()
+	cfg := srv.config()
+	cfg.PoolMaxIdle = 1
+	m := NewSMTP(cfg)
+	ctx := context.Background()
+
+	caps, err := m.Capabilities(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps.TLS || caps.MaxSize != 1000 || !slices.Equal(caps.Auth, []string{"PLAIN", "LOGIN"}) ||
+		len(caps.Extensions) != 3 || caps.Extensions["8BITMIME"] != "" {
+		t.Fatalf("unexpected capabilities: %+v", caps)
+	}
+	if err := m.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if srv.connections() != 1 {
+		t.Fatalf("%d connections, want the pooled one reused", srv.connections())
+	}
+	var noops int
+	for _, c := range srv.commands() {
+		if c == "NOOP" {
+			noops++
+		}
+	}
+	// The pool health check adds one when handing out the idle connection.
+	if noops != 3 {
+		t.Fatalf("%d NOOPs, want 3: %v", noops, srv.commands())
+	}
+
+	srv.setReply("NOOP", "421 4.3.2 shutting down")
+	var se *types.SMTPError
+	if err := m.Ping(ctx); !errors.As(err, &se) || se.Code != 421 || !strings.Contains(err.Error(), "NOOP") {
+		t.Fatalf("expected NOOP refusal, got %v", err)
+	}
+	m.Close(ctx)
+	if err := m.Ping(ctx); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestPingUnreachable(t *testing.T) {
+	srv := newFakeServer(t)
+	cfg := srv.config()
+	srv.ln.Close()
+	if err := NewSMTP(cfg).Ping(context.Background()); err == nil {
+		t.Fatalf("expected dial error")
+	}
+}
+
+func TestSendChecksAdvertisedSize(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.ext = []string{"SIZE 200"}
+	m := NewSMTP(srv.config())
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte(strings.Repeat("x", 300)),
+	}
+	var se *types.SizeError
+	err := m.Send(context.Background(), msg)
+	if !errors.As(err, &se) || se.Limit != 200 || !errors.Is(err, types.ErrMessageTooLarge) ||
+		!isTooLarge(err) {
+		t.Fatalf("expected SIZE error, got %v", err)
+	}
+	if slices.ContainsFunc(srv.commands(), func(c string) bool { return strings.HasPrefix(c, "MAIL") }) {
+		t.Fatalf("message was sent: %v", srv.commands())
+	}
+	if !(Capabilities{Extensions: map[string]string{"PIPELINING": ""}}).Has("pipelining") {
+		t.Fatalf("Has should ignore case")
+	}
+}
@@ -0,0 +1,67 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// SendRaw relays an already built RFC 5322 message, e.g. one read from
+// a queue, parsed by the inbound package or produced by another system,
+// without going through types.Message. The bytes are sent unchanged:
+// no headers are added and WithDKIM and other build options are
+// ignored. A body with 8-bit or binary content needs a server with
+// 8BITMIME, or BINARYMIME and CHUNKING; it is not re-encoded. Delivery
+// options apply as in SendPrepared, and WithMaxMessageSize caps the
+// bytes read from r.
+//
+// Parameters:
+//   - ctx: The context.
+//   - from: The envelope sender; empty sends the null sender "<>".
+//   - rcpts: The envelope recipients.
+//   - r: The message, with CRLF line endings.
+//   - opts: The delivery options.
+//
+// Returns:
+//   - error: The error if r cannot be read, exceeds the size limit or
+//     the message fails to send.
+func (m *SMTP) SendRaw(
+	ctx context.Context,
+	from string,
+	rcpts []string,
+	r io.Reader,
+	opts ...email.Option,
+) error {
+	if len(rcpts) == 0 {
+		return types.ErrNoRecipients
+	}
+	cfg := sendConfig(opts)
+	raw, err := readRaw(r, cfg.MaxMessageSize)
+	if err != nil {
+		return err
+	}
+	if len(raw) == 0 {
+		return errors.New("empty message")
+	}
+	return m.sendBuilt(ctx, internal.Raw(raw), from, rcpts, &cfg)
+}
+
+// readRaw reads r, failing with a *types.SizeError beyond limit bytes
+// (0 = no limit).
+func readRaw(r io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(r)
+	}
+	raw, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(raw)) > limit {
+		return nil, &types.SizeError{Size: int64(len(raw)), Limit: limit}
+	}
+	return raw, nil
+}
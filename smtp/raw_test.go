@@ -0,0 +1,76 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestSendRaw(t *testing.T) {
+	srv := newFakeServer(t)
+	m := NewSMTP(srv.config())
+	ctx := context.Background()
+	raw := "From: shop@example.com\r\nTo: ada@example.org\r\n" +
+		"Message-ID: <order-1@example.com>\r\nSubject: Grüße\r\n\r\nThanks\r\n"
+
+	var res email.SendResult
+	err := m.SendRaw(ctx, "bounces@example.com", []string{"ada@example.org", "audit@example.com"},
+		strings.NewReader(raw), email.WithResult(&res))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgs := srv.messages(); len(msgs) != 1 || msgs[0] != raw {
+		t.Fatalf("message changed: %q", msgs)
+	}
+	if res.MessageID != "<order-1@example.com>" || res.Size != len(raw) {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	cmds := srv.commands()
+	for _, want := range []string{
+		"MAIL FROM:<bounces@example.com> BODY=8BITMIME",
+		"RCPT TO:<ada@example.org>", "RCPT TO:<audit@example.com>",
+	} {
+		if !slices.Contains(cmds, want) {
+			t.Fatalf("missing %q in %v", want, cmds)
+		}
+	}
+
+	var size *types.SizeError
+	err = m.SendRaw(ctx, "", []string{"ada@example.org"}, strings.NewReader(raw),
+		email.WithMaxMessageSize(10))
+	if !errors.As(err, &size) || size.Limit != 10 {
+		t.Fatalf("expected size error, got %v", err)
+	}
+	if err := m.SendRaw(ctx, "", nil, strings.NewReader(raw)); err == nil {
+		t.Fatal("expected error without recipients")
+	}
+	if err := m.SendRaw(ctx, "", []string{"ada@example.org"}, strings.NewReader("")); err == nil {
+		t.Fatal("expected error for empty message")
+	}
+}
+
+func TestSendRawBodyType(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.ext = nil
+	raw := "Subject: x\r\n\r\nGrüße\r\n"
+	err := NewSMTP(srv.config()).SendRaw(context.Background(), "a@example.com",
+		[]string{"b@example.org"}, strings.NewReader(raw))
+	if err == nil || !strings.Contains(err.Error(), "8BITMIME") {
+		t.Fatalf("expected unsupported body error, got %v", err)
+	}
+
+	srv = newFakeServer(t)
+	srv.ext = []string{"BINARYMIME", "CHUNKING"}
+	raw = "Subject: x\r\n\r\n" + strings.Repeat("x", 1200) + "\r\n"
+	var res email.SendResult
+	err = NewSMTP(srv.config()).SendRaw(context.Background(), "a@example.com",
+		[]string{"b@example.org"}, strings.NewReader(raw), email.WithResult(&res))
+	if err != nil || res.MessageID != "" || srv.messages()[0] != raw {
+		t.Fatalf("binary send: %v %+v", err, res)
+	}
+}
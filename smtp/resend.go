@@ -0,0 +1,99 @@
+package smtp
+
+import (
+	"context"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// Resend sends a stored message again to new recipients, e.g. for a
+// "resend receipt" feature. A block of Resent-From, Resent-To,
+// Resent-Cc, Resent-Date and Resent-Message-ID fields is added on top of
+// raw, which is otherwise sent unchanged, so an existing DKIM signature
+// stays valid. The envelope sender is r.From unless WithEnvelopeFrom is
+// given. Delivery options apply as in SendPrepared; the reported
+// Message-ID is the Resent-Message-ID.
+//
+// Parameters:
+//   - ctx: The context.
+//   - raw: The stored message.
+//   - r: The resent sender and recipients.
+//   - opts: The delivery options.
+//
+// Returns:
+//   - error: The error if r is invalid or the message fails to send.
+func (m *SMTP) Resend(
+	ctx context.Context,
+	raw []byte,
+	r types.Resent,
+	opts ...email.Option,
+) error {
+	built, err := internal.Resend(raw, r)
+	if err != nil {
+		return err
+	}
+	return m.resend(ctx, built, r, opts)
+}
+
+// ResendMessage is Resend for a parsed message, which is built with the
+// build options in opts first. Keep its Message-ID header to preserve
+// the original identity.
+//
+// Parameters:
+//   - ctx: The context.
+//   - msg: The stored message.
+//   - r: The resent sender and recipients.
+//   - opts: The build and delivery options.
+//
+// Returns:
+//   - error: The error if the message fails to build or send.
+func (m *SMTP) ResendMessage(
+	ctx context.Context,
+	msg types.Message,
+	r types.Resent,
+	opts ...email.Option,
+) error {
+	cfg := sendConfig(opts)
+	orig, err := internal.Build(ctx, msg, m.buildOptions(&cfg))
+	if err != nil {
+		return err
+	}
+	built, err := internal.Resend(orig.Raw, r)
+	if err != nil {
+		return err
+	}
+	if orig.BodyType != "" {
+		built.BodyType = orig.BodyType
+	}
+	return m.resend(ctx, built, r, opts)
+}
+
+// resend delivers a message prepared by internal.Resend.
+func (m *SMTP) resend(
+	ctx context.Context,
+	built *internal.Built,
+	r types.Resent,
+	opts []email.Option,
+) error {
+	if err := m.begin(); err != nil {
+		return err
+	}
+	defer m.inflight.Done()
+	cfg := sendConfig(opts)
+	rcpts := r.RecipientList()
+	if err := checkAddresses(ctx, &cfg, rcpts); err != nil {
+		return err
+	}
+	if cfg.Rate != nil {
+		if err := cfg.Rate.Acquire(ctx); err != nil {
+			return err
+		}
+	}
+	from := r.From.Mail
+	if cfg.EnvelopeFrom != "" {
+		from = cfg.EnvelopeFrom
+	}
+	return m.deliver(ctx, built, from, rcpts, &cfg, nil)
+}
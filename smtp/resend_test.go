@@ -0,0 +1,90 @@
+package smtp
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestResend(t *testing.T) {
+	srv := newFakeServer(t)
+	m := NewSMTP(srv.config())
+	ctx := context.Background()
+	raw := "Received: from a by b; Mon, 12 Oct 2026 09:00:00 +0000\r\n" +
+		"From: shop@example.com\r\nTo: ada@example.org\r\n" +
+		"Message-ID: <order-1@example.com>\r\nSubject: Receipt\r\n\r\nThanks\r\n"
+	r := types.Resent{
+		From: types.Address{Name: "Support", Mail: "support@example.com"},
+		To:   []types.Address{{Mail: "ada@example.net"}},
+		Bcc:  []types.Address{{Mail: "audit@example.com"}},
+	}
+
+	var res email.SendResult
+	if err := m.Resend(ctx, []byte(raw), r, email.WithResult(&res)); err != nil {
+		t.Fatal(err)
+	}
+	msgs := srv.messages()
+	if len(msgs) != 1 {
+		t.Fatalf("messages = %d", len(msgs))
+	}
+	got := msgs[0]
+	if !strings.HasPrefix(got, "Resent-Date: ") || !strings.HasSuffix(got, raw) {
+		t.Fatalf("unexpected message:\n%s", got)
+	}
+	for _, want := range []string{
+		"\r\nResent-From: \"Support\" <support@example.com>\r\n",
+		"\r\nResent-To: ada@example.net\r\n",
+		"\r\nResent-Message-ID: " + res.MessageID + "\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "audit@") || !strings.HasSuffix(res.MessageID, "@example.com>") {
+		t.Fatalf("bcc leaked or bad id %q:\n%s", res.MessageID, got)
+	}
+	cmds := srv.commands()
+	for _, want := range []string{"RCPT TO:<ada@example.net>", "RCPT TO:<audit@example.com>"} {
+		if !slices.Contains(cmds, want) {
+			t.Fatalf("missing %q in %v", want, cmds)
+		}
+	}
+	if !slices.ContainsFunc(cmds, func(c string) bool {
+		return strings.HasPrefix(c, "MAIL FROM:<support@example.com>")
+	}) {
+		t.Fatalf("unexpected envelope sender: %v", cmds)
+	}
+
+	if err := m.Resend(ctx, []byte(raw), types.Resent{From: r.From}); err == nil {
+		t.Fatal("expected error without recipients")
+	}
+}
+
+func TestResendMessage(t *testing.T) {
+	srv := newFakeServer(t)
+	m := NewSMTP(srv.config())
+	msg := types.Message{
+		From:    types.Address{Mail: "shop@example.com"},
+		To:      []types.Address{{Mail: "ada@example.org"}},
+		Subject: "Receipt",
+		Plain:   []byte("Thanks"),
+	}
+	msg.Headers.Set("Message-ID", "<order-1@example.com>")
+	r := types.Resent{
+		From:      types.Address{Mail: "support@example.com"},
+		To:        []types.Address{{Mail: "ada@example.net"}},
+		MessageID: "resend-1@example.com",
+	}
+	if err := m.ResendMessage(context.Background(), msg, r); err != nil {
+		t.Fatal(err)
+	}
+	got := srv.messages()[0]
+	if !strings.Contains(got, "\r\nResent-Message-ID: <resend-1@example.com>\r\n") ||
+		!strings.Contains(got, "\r\nMessage-ID: <order-1@example.com>\r\n") {
+		t.Fatalf("unexpected message:\n%s", got)
+	}
+}
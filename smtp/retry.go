@@ -0,0 +1,354 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// RetryEntry is a built message waiting for redelivery.
+type RetryEntry struct {
+	ID        string    // Message-ID, unique per entry
+	Raw       []byte    // the message as built and signed
+	BodyType  string    // MAIL FROM BODY= value: "", "8BITMIME" or "BINARYMIME"
+	From      string    // envelope sender
+	Rcpts     []string  // recipients still to deliver to
+	Attempts  int       // delivery attempts made so far
+	NextTry   time.Time // earliest time of the next attempt
+	LastError string    // error of the last attempt
+}
+
+// RetryStore persists messages between delivery attempts. Implementations
+// must be safe for concurrent use.
+type RetryStore interface {
+	// Put adds e, replacing an entry with the same ID.
+	Put(ctx context.Context, e RetryEntry) error
+	// Due returns the entries whose NextTry is not after now.
+	Due(ctx context.Context, now time.Time) ([]RetryEntry, error)
+	// Delete removes the entry with id; a missing entry is not an error.
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryRetryStore is an in-memory RetryStore. Entries are lost on
+// restart.
+type MemoryRetryStore struct {
+	mu      sync.Mutex
+	entries map[string]RetryEntry
+}
+
+// NewMemoryRetryStore creates an empty in-memory retry store.
+//
+// Returns:
+//   - *MemoryRetryStore: The store.
+func NewMemoryRetryStore() *MemoryRetryStore {
+	return &MemoryRetryStore{entries: map[string]RetryEntry{}}
+}
+
+// Put adds e, replacing an entry with the same ID.
+//
+// Parameters:
+//   - ctx: The context.
+//   - e: The entry.
+//
+// Returns:
+//   - error: Always nil.
+func (s *MemoryRetryStore) Put(ctx context.Context, e RetryEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[e.ID] = e
+	return nil
+}
+
+// Due returns the entries whose NextTry is not after now, earliest
+// first.
+//
+// Parameters:
+//   - ctx: The context.
+//   - now: The current time.
+//
+// Returns:
+//   - []RetryEntry: The due entries.
+//   - error: Always nil.
+func (s *MemoryRetryStore) Due(ctx context.Context, now time.Time) ([]RetryEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []RetryEntry
+	for _, e := range s.entries {
+		if !e.NextTry.After(now) {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].NextTry.Before(out[j].NextTry) })
+	return out, nil
+}
+
+// Delete removes the entry with id.
+//
+// Parameters:
+//   - ctx: The context.
+//   - id: The entry ID.
+//
+// Returns:
+//   - error: Always nil.
+func (s *MemoryRetryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, id)
+	return nil
+}
+
+// Len returns the number of stored entries.
+//
+// Returns:
+//   - int: The number of entries.
+func (s *MemoryRetryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// RetrySchedulerConfig configures a RetryScheduler.
+type RetrySchedulerConfig struct {
+	// Store keeps the messages between attempts; defaults to a
+	// MemoryRetryStore.
+	Store RetryStore
+	// Schedule gives the delay before each redelivery: Next(n) is the
+	// wait after the n-th failed attempt, and ok=false gives up.
+	// Defaults to ExponentialBackoff(10, time.Minute, 4*time.Hour,
+	// false): ten attempts over six to eight hours.
+	Schedule email.Backoff
+	// Options are the delivery options of redeliveries (pool, rate
+	// limit, hooks). Retry options are ignored.
+	Options []email.Option
+	// OnGiveUp, if set, is called for an entry dropped after a
+	// permanent error or the last scheduled attempt.
+	OnGiveUp func(e RetryEntry, err error)
+	// Now returns the current time; defaults to the clock set with
+	// email.SetClock.
+	Now func() time.Time
+}
+
+// RetryScheduler sends messages with one immediate attempt and, on a
+// transient failure, persists the built message and redelivers it from
+// a background loop, so the caller is not held up by retry sleeps. It
+// implements email.Mailer.
+type RetryScheduler struct {
+	m         *SMTP
+	cfg       RetrySchedulerConfig
+	processMu sync.Mutex // one Process at a time
+}
+
+// NewRetryScheduler creates a retry scheduler delivering through m.
+//
+// Parameters:
+//   - m: The SMTP mailer.
+//   - cfg: The scheduler config.
+//
+// Returns:
+//   - *RetryScheduler: The scheduler.
+func NewRetryScheduler(m *SMTP, cfg RetrySchedulerConfig) *RetryScheduler {
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryRetryStore()
+	}
+	if cfg.Schedule == nil {
+		cfg.Schedule = email.ExponentialBackoff(10, time.Minute, 4*time.Hour, false)
+	}
+	if cfg.Now == nil {
+		cfg.Now = internal.Now
+	}
+	return &RetryScheduler{m: m, cfg: cfg}
+}
+
+// Send builds msg and makes one delivery attempt. If it fails
+// transiently, the message is stored for redelivery, Result.Queued is
+// set and Send returns nil. WithRetry is ignored. As with
+// PrepareMessage, a stored 8bit body is not rebuilt for a server that
+// lacks 8BITMIME.
+//
+// Parameters:
+//   - ctx: The context.
+//   - msg: The message.
+//   - opts: The options.
+//
+// Returns:
+//   - error: The error if the message fails to build, fails
+//     permanently or cannot be stored.
+func (s *RetryScheduler) Send(
+	ctx context.Context,
+	msg types.Message,
+	opts ...email.Option,
+) error {
+	p, err := s.m.PrepareMessage(ctx, msg, opts...)
+	if err != nil {
+		return err
+	}
+	cfg := sendConfig(opts)
+	cfg.Backoff = nil
+	res := cfg.Result
+	if res == nil {
+		res = &email.SendResult{}
+		cfg.Result = res
+	}
+	rcpts := p.rcpts
+	if len(rcpts) == 0 {
+		return types.ErrNoRecipients
+	}
+	from := p.envelopeFrom
+	err = s.m.sendBuilt(ctx, p.built, from, rcpts, &cfg)
+	if err == nil || !isTransient(err) {
+		return err
+	}
+	e := RetryEntry{
+		ID:        p.built.MessageID,
+		Raw:       p.built.Raw,
+		BodyType:  p.built.BodyType,
+		From:      from,
+		Rcpts:     retryRecipients(rcpts, res),
+		Attempts:  1,
+		LastError: err.Error(),
+	}
+	d, ok := s.cfg.Schedule.Next(1)
+	if !ok || len(e.Rcpts) == 0 {
+		return err
+	}
+	e.NextTry = s.cfg.Now().Add(d)
+	if perr := s.cfg.Store.Put(ctx, e); perr != nil {
+		return errors.Join(err, fmt.Errorf("retry store: %w", perr))
+	}
+	res.Queued = true
+	return nil
+}
+
+// Process redelivers the stored messages that are due. Delivered and
+// given-up messages are removed; the others are rescheduled.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - int: The number of messages delivered.
+//   - error: The error if the store fails.
+func (s *RetryScheduler) Process(ctx context.Context) (int, error) {
+	s.processMu.Lock()
+	defer s.processMu.Unlock()
+	due, err := s.cfg.Store.Due(ctx, s.cfg.Now())
+	if err != nil {
+		return 0, fmt.Errorf("retry store: %w", err)
+	}
+	sent := 0
+	for _, e := range due {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		ok, err := s.redeliver(ctx, e)
+		if err != nil {
+			return sent, fmt.Errorf("retry store: %w", err)
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// Run calls Process every interval until ctx ends, then returns
+// ctx.Err(). Process errors are passed to onErr if it is not nil.
+//
+// Parameters:
+//   - ctx: The context.
+//   - interval: The time between runs.
+//   - onErr: The error handler, or nil.
+//
+// Returns:
+//   - error: The context error.
+func (s *RetryScheduler) Run(ctx context.Context, interval time.Duration, onErr func(error)) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			if _, err := s.Process(ctx); err != nil && onErr != nil {
+				onErr(err)
+			}
+		}
+	}
+}
+
+// redeliver makes one attempt for e and updates the store. It reports
+// whether the message was delivered; the error is a store error.
+func (s *RetryScheduler) redeliver(ctx context.Context, e RetryEntry) (bool, error) {
+	cfg := sendConfig(s.cfg.Options)
+	cfg.Backoff = nil
+	res := &email.SendResult{}
+	cfg.Result = res
+	built := &internal.Built{Raw: e.Raw, MessageID: e.ID, BodyType: e.BodyType}
+	err := s.m.sendBuilt(ctx, built, e.From, e.Rcpts, &cfg)
+	if err == nil {
+		return true, s.cfg.Store.Delete(ctx, e.ID)
+	}
+	if errors.Is(err, ErrClosed) || ctx.Err() != nil {
+		return false, nil // not an attempt; keep the schedule
+	}
+	e.Attempts++
+	e.LastError = err.Error()
+	e.Rcpts = retryRecipients(e.Rcpts, res)
+	d, ok := s.cfg.Schedule.Next(e.Attempts)
+	if !isTransient(err) || !ok || len(e.Rcpts) == 0 {
+		if s.cfg.OnGiveUp != nil {
+			s.cfg.OnGiveUp(e, err)
+		}
+		return false, s.cfg.Store.Delete(ctx, e.ID)
+	}
+	e.NextTry = s.cfg.Now().Add(d)
+	return false, s.cfg.Store.Put(ctx, e)
+}
+
+// sendBuilt delivers built like SendPrepared.
+func (m *SMTP) sendBuilt(
+	ctx context.Context,
+	built *internal.Built,
+	from string,
+	rcpts []string,
+	cfg *email.SendConfig,
+) error {
+	if err := m.begin(); err != nil {
+		return err
+	}
+	defer m.inflight.Done()
+	if err := checkAddresses(ctx, cfg, rcpts); err != nil {
+		return err
+	}
+	if cfg.Rate != nil {
+		if err := cfg.Rate.Acquire(ctx); err != nil {
+			return err
+		}
+	}
+	if cfg.EnvelopeFrom != "" {
+		from = cfg.EnvelopeFrom
+	}
+	return m.deliver(ctx, built, from, rcpts, cfg, nil)
+}
+
+// retryRecipients returns the recipients to try again: those that
+// failed transiently if res has per-recipient results (LMTP), else all.
+func retryRecipients(rcpts []string, res *email.SendResult) []string {
+	if len(res.Recipients) == 0 {
+		return rcpts
+	}
+	var out []string
+	for _, r := range res.Recipients {
+		if r.Err != nil && isTransient(r.Err) {
+			out = append(out, r.Recipient)
+		}
+	}
+	return out
+}
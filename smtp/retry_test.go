@@ -0,0 +1,103 @@
+package smtp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
+
+// fixedSchedule waits a minute between attempts and allows n attempts.
+type fixedSchedule int
+
+func (n fixedSchedule) Next(i int) (time.Duration, bool) {
+	return time.Minute, i < int(n)
+}
+
+func TestRetrySchedulerRedelivers(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.setReply("RCPT", "451 4.3.0 try again later")
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryRetryStore()
+	s := NewRetryScheduler(NewSMTP(srv.config()), RetrySchedulerConfig{
+		Store:    store,
+		Schedule: fixedSchedule(3),
+		Now:      func() time.Time { return now },
+	})
+	msg := types.Message{
+		From:    types.Address{Mail: "a@example.com"},
+		To:      []types.Address{{Mail: "b@example.org"}},
+		Subject: "Hi",
+		Plain:   []byte("hello"),
+	}
+	var res email.SendResult
+	if err := s.Send(context.Background(), msg, email.WithResult(&res)); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if !res.Queued || store.Len() != 1 {
+		t.Fatalf("queued = %v, stored = %d", res.Queued, store.Len())
+	}
+
+	// Not due yet.
+	if n, err := s.Process(context.Background()); n != 0 || err != nil {
+		t.Fatalf("early process: %d %v", n, err)
+	}
+	now = now.Add(time.Minute)
+	if n, err := s.Process(context.Background()); n != 0 || err != nil {
+		t.Fatalf("process: %d %v", n, err)
+	}
+	due, _ := store.Due(context.Background(), now.Add(time.Minute))
+	if len(due) != 1 || due[0].Attempts != 2 ||
+		!strings.Contains(due[0].LastError, "451") {
+		t.Fatalf("entry = %+v", due)
+	}
+
+	srv.setReply("RCPT", "250 OK")
+	now = now.Add(time.Minute)
+	if n, err := s.Process(context.Background()); n != 1 || err != nil {
+		t.Fatalf("final process: %d %v", n, err)
+	}
+	if store.Len() != 0 {
+		t.Fatal("delivered entry not removed")
+	}
+	msgs := srv.messages()
+	if len(msgs) != 1 || !strings.Contains(msgs[0], res.MessageID) {
+		t.Fatalf("messages = %q", msgs)
+	}
+}
+
+func TestRetrySchedulerGivesUp(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.setReply("RCPT", "451 4.3.0 try again later")
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var gaveUp []RetryEntry
+	s := NewRetryScheduler(NewSMTP(srv.config()), RetrySchedulerConfig{
+		Schedule: fixedSchedule(2),
+		Now:      func() time.Time { return now },
+		OnGiveUp: func(e RetryEntry, err error) { gaveUp = append(gaveUp, e) },
+	})
+	msg := types.Message{
+		From:  types.Address{Mail: "a@example.com"},
+		To:    []types.Address{{Mail: "b@example.org"}},
+		Plain: []byte("hello"),
+	}
+	if err := s.Send(context.Background(), msg); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	srv.setReply("RCPT", "550 5.1.1 no such user")
+	now = now.Add(time.Minute)
+	if n, err := s.Process(context.Background()); n != 0 || err != nil {
+		t.Fatalf("process: %d %v", n, err)
+	}
+	if len(gaveUp) != 1 || gaveUp[0].Attempts != 2 {
+		t.Fatalf("gave up = %+v", gaveUp)
+	}
+
+	// Permanent failures are returned directly.
+	if err := s.Send(context.Background(), msg); err == nil {
+		t.Fatal("expected permanent error")
+	}
+}
@@ -1,14 +1,21 @@
 package smtp
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"net"
 	"net/smtp"
+	"net/textproto"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aatuh/email/v2"
@@ -28,21 +35,100 @@ type SMTPConfig struct {
 	ImplicitTLS bool
 	SkipVerify  bool
 
+	// Credentials, if set, supplies the username and password, or a
+	// token, for each send instead of Username and Password, so rotated
+	// secrets apply without a restart. Pooled connections that
+	// authenticated with older credentials are closed and redialed.
+	Credentials CredentialsProvider
+	// AuthMechanism selects the SASL mechanism: AuthAuto (the
+	// default), AuthPlain or AuthCRAMMD5.
+	AuthMechanism string
+
+	// TLSConfig, if set, is used for STARTTLS and implicit TLS, e.g. to
+	// set MinVersion, RootCAs or a client certificate for mutual TLS.
+	// It is cloned; ServerName defaults to Host and SkipVerify still
+	// applies.
+	TLSConfig *tls.Config
+	// PinnedSPKI, if set, requires the server chain to contain a
+	// certificate whose public key matches one of these base64 SHA-256
+	// SPKI hashes (see SPKIPin), in addition to normal verification.
+	PinnedSPKI []string
+
 	// Pool settings (optional). If PoolMaxIdle <= 0, no pooling is used.
-	PoolMaxIdle int
-	PoolIdleTTL time.Duration
+	// PoolMaxLifetime closes connections after that long however busy,
+	// and PoolJitter randomly shortens the TTL and lifetime per
+	// connection by up to that fraction (see email.ConnPool). PoolHooks,
+	// if set, reports dials, reuses and evictions of the pool's
+	// connections.
+	PoolMaxIdle     int
+	PoolIdleTTL     time.Duration
+	PoolMaxLifetime time.Duration
+	PoolJitter      float64
+	PoolHooks       *email.PoolHooks
+
+	// EightBitMIME lets text parts be sent as 8bit and BinaryMIME as
+	// binary (via BDAT). If the server does not advertise 8BITMIME, or
+	// BINARYMIME and CHUNKING, the message is rebuilt with 7-bit
+	// encodings.
+	EightBitMIME bool
+	BinaryMIME   bool
+
+	// Dialer, if set, opens the TCP connection, e.g. through a proxy
+	// (see the proxy package). TLS, implicit or via STARTTLS, runs over
+	// the dialed connection.
+	Dialer types.ContextDialer
+
+	// Without a Dialer, Host is resolved with Resolver (defaults to
+	// net.DefaultResolver) and its addresses are dialed in the Happy
+	// Eyeballs style of RFC 8305: families alternate, a new attempt
+	// starts every DialAttemptDelay (defaults to 250ms) or as soon as
+	// the previous one fails, and the first connection wins.
+	// DialAddrTimeout bounds each attempt (0 = only Timeout).
+	Resolver         Resolver
+	DialAttemptDelay time.Duration
+	DialAddrTimeout  time.Duration
+
+	// LMTP speaks LMTP (RFC 2033) instead of SMTP, e.g. to deliver
+	// into Dovecot or Cyrus: LHLO replaces EHLO and the server answers
+	// DATA once per recipient, reported in SendResult.Recipients.
+	// STARTTLS is not supported; use a Dialer for a Unix socket.
+	LMTP bool
+
+	// VerifyFrom is the MAIL FROM of Verify probes; empty sends the
+	// null sender "<>". Accepted addresses and 5xx refusals are cached
+	// for VerifyCacheTTL (0 = no caching), and probes for one domain
+	// run one at a time, at least VerifyInterval apart.
+	VerifyFrom     string
+	VerifyCacheTTL time.Duration
+	VerifyInterval time.Duration
 }
 
+// errBodyUnsupported is returned when the server lacks the extension
+// the built body needs.
+var errBodyUnsupported = errors.New("smtp: server does not support body type")
+
 // smtpConn is a connection to the SMTP server.
 type smtpConn struct {
-	c   *smtp.Client
-	tls bool
+	c    *smtp.Client
+	nc   *countConn   // underlying connection, for deadlines
+	caps Capabilities // from the EHLO reply, after STARTTLS
+
+	used   bool        // has run a session
+	authed bool        // AUTH succeeded or was not needed
+	creds  Credentials // the credentials of the AUTH
+	broken bool        // interrupted mid-command; close instead of reusing
 }
 
 // SMTP implements the Mailer interface over SMTP.
 type SMTP struct {
 	cfg  SMTPConfig
 	pool *email.ConnPool
+
+	mu       sync.Mutex
+	closed   bool
+	inflight sync.WaitGroup
+
+	verifier verifier
 }
 
 // NewSMTP creates a new SMTP mailer.
@@ -55,23 +141,35 @@ type SMTP struct {
 func NewSMTP(cfg SMTPConfig) *SMTP {
 	m := &SMTP{cfg: cfg}
 	if cfg.PoolMaxIdle > 0 {
+		// No New: acquire dials outside the pool lock, with the
+		// send's context.
 		m.pool = email.NewConnPool(
 			cfg.PoolMaxIdle,
 			cfg.PoolIdleTTL,
-			func() (any, error) { return m.newConn() },
+			nil,
 			func(a any) error {
 				if sc, ok := a.(*smtpConn); ok && sc.c != nil {
-					return sc.c.Quit()
+					if err := sc.c.Quit(); err != nil {
+						return sc.c.Close()
+					}
 				}
 				return nil
 			},
 			func(a any) bool {
 				if sc, ok := a.(*smtpConn); ok && sc.c != nil {
+					// A connection dropped by a NAT may never answer.
+					if cfg.Timeout > 0 {
+						_ = sc.nc.SetDeadline(time.Now().Add(cfg.Timeout))
+						defer sc.nc.SetDeadline(time.Time{})
+					}
 					return sc.c.Noop() == nil
 				}
 				return false
 			},
 		)
+		m.pool.Hooks = cfg.PoolHooks
+		m.pool.MaxLifetime = cfg.PoolMaxLifetime
+		m.pool.Jitter = cfg.PoolJitter
 	}
 	return m
 }
@@ -90,20 +188,306 @@ func (m *SMTP) Send(
 	msg types.Message,
 	opts ...email.Option,
 ) error {
-	var cfg email.SendConfig
-	for _, o := range opts {
-		o(&cfg)
+	if err := m.begin(); err != nil {
+		return err
+	}
+	defer m.inflight.Done()
+	cfg := sendConfig(opts)
+	msg, err := email.ExpandRecipients(ctx, msg, opts...)
+	if err != nil {
+		return err
+	}
+	if err := email.CheckPolicy(ctx, msg, opts...); err != nil {
+		return err
+	}
+	if err := checkAddresses(ctx, &cfg, msg.EnvelopeRecipients()); err != nil {
+		return err
+	}
+	if err := checkReturnPath(msg, &cfg, opts); err != nil {
+		return err
+	}
+	msg, err = rewriteMessage(ctx, &cfg, msg)
+	if err != nil {
+		return err
 	}
 
 	if cfg.Rate != nil {
-		cfg.Rate.Wait()
+		if err := cfg.Rate.Acquire(ctx); err != nil {
+			return err
+		}
 	}
 
-	// Build MIME once (DKIM signs body). Hooks wrap build.
-	raw, err := internal.BuildMIME(ctx, msg, cfg.ListUnsub, cfg.DKIM, cfg.Hooks)
+	bopts := m.buildOptions(&cfg)
+	if cfg.FanOut != nil {
+		return m.sendEach(ctx, msg, &cfg, bopts)
+	}
+
+	// Keep the attachments for a retry with download links.
+	var attach func() []types.Attachment
+	if cfg.Oversize != nil && len(msg.Attach) > 0 {
+		if attach, err = bufferAttachments(msg.Attach); err != nil {
+			return err
+		}
+		msg.Attach = attach()
+	}
+
+	built, rebuild, err := buildSend(ctx, msg, bopts)
 	if err != nil {
 		return err
 	}
+	built, spam, err := checkSpam(ctx, &cfg, built)
+	if err != nil {
+		return err
+	}
+	rebuild = spamRebuild(ctx, &cfg, rebuild)
+	from := msg.EnvelopeSender()
+	if cfg.EnvelopeFrom != "" {
+		from = cfg.EnvelopeFrom
+	}
+	err = m.deliver(ctx, built, from, msg.EnvelopeRecipients(), &cfg, rebuild)
+	if attach != nil && isTooLarge(err) {
+		err = m.sendLinked(ctx, msg, attach(), built.MessageID, from, &cfg, bopts, err)
+	}
+	if cfg.Result != nil {
+		cfg.Result.Spam = spam
+	}
+	return err
+}
+
+// checkSpam applies WithSpamCheck to built, returning it with the
+// message to send. A blocked message is reported in cfg.Result.
+func checkSpam(
+	ctx context.Context,
+	cfg *email.SendConfig,
+	built *internal.Built,
+) (*internal.Built, *email.SpamReport, error) {
+	if cfg.SpamCheck == nil {
+		return built, nil, nil
+	}
+	raw, rep, err := cfg.SpamCheck.Apply(ctx, built.Raw)
+	if err != nil {
+		if cfg.Result != nil {
+			*cfg.Result = email.SendResult{
+				MessageID: built.MessageID, Size: len(built.Raw), Spam: rep,
+			}
+		}
+		return nil, rep, err
+	}
+	b := *built
+	b.Raw = raw
+	return &b, rep, nil
+}
+
+// spamRebuild wraps rebuild to check the rebuilt message for spam too.
+func spamRebuild(
+	ctx context.Context,
+	cfg *email.SendConfig,
+	rebuild func() (*internal.Built, error),
+) func() (*internal.Built, error) {
+	if rebuild == nil || cfg.SpamCheck == nil {
+		return rebuild
+	}
+	return func() (*internal.Built, error) {
+		b, err := rebuild()
+		if err != nil {
+			return nil, err
+		}
+		b, _, err = checkSpam(ctx, cfg, b)
+		return b, err
+	}
+}
+
+// buildSend builds msg once (DKIM signs the body; hooks wrap the
+// build). rebuild, if not nil, rebuilds an automatically chosen
+// 8bit/binary body with 7-bit encodings for servers without the
+// extension, keeping the same Message-ID.
+func buildSend(
+	ctx context.Context,
+	msg types.Message,
+	bopts internal.BuildOptions,
+) (*internal.Built, func() (*internal.Built, error), error) {
+	built, err := internal.Build(ctx, msg, bopts)
+	if err != nil {
+		return nil, nil, err
+	}
+	var rebuild func() (*internal.Built, error)
+	if msg.TextEncoding == types.TransferAuto &&
+		(bopts.Allow8Bit || bopts.AllowBinary) {
+		rebuild = func() (*internal.Built, error) {
+			bopts.Allow8Bit, bopts.AllowBinary = false, false
+			if !msg.Headers.Has("Message-ID") {
+				msg.Headers = msg.CloneHeaders()
+				msg.Headers.Set("Message-ID", built.MessageID)
+			}
+			return internal.Build(ctx, msg, bopts)
+		}
+	}
+	return built, rebuild, nil
+}
+
+// Prepared is a built and signed message that can be sent repeatedly to
+// different envelope recipients without rebuilding. It is immutable and
+// safe for concurrent use.
+type Prepared struct {
+	built        *internal.Built
+	envelopeFrom string
+	rcpts        []string
+}
+
+// MessageID returns the Message-ID of the prepared message.
+//
+// Returns:
+//   - string: The Message-ID, with angle brackets.
+func (p *Prepared) MessageID() string { return p.built.MessageID }
+
+// Size returns the size of the prepared message in bytes.
+//
+// Returns:
+//   - int: The size.
+func (p *Prepared) Size() int { return len(p.built.Raw) }
+
+// Bytes returns a copy of the raw message.
+//
+// Returns:
+//   - []byte: The raw message.
+func (p *Prepared) Bytes() []byte { return bytes.Clone(p.built.Raw) }
+
+// Recipients returns the envelope recipients taken from the message's
+// To, Cc and Bcc, used when SendPrepared is given none.
+//
+// Returns:
+//   - []string: The recipients.
+func (p *Prepared) Recipients() []string { return slices.Clone(p.rcpts) }
+
+// PrepareMessage builds and, with WithDKIM, signs msg once for repeated
+// delivery with SendPrepared. Build options (DKIM, headers, charset,
+// limits, envelope sender, ...) are fixed at this point. The body is
+// not rebuilt for servers lacking 8BITMIME or BINARYMIME; sending to
+// such a server fails, so leave EightBitMIME and BinaryMIME off when
+// that matters.
+//
+// Parameters:
+//   - ctx: The context.
+//   - msg: The message.
+//   - opts: The build options.
+//
+// Returns:
+//   - *Prepared: The prepared message.
+//   - error: The error if the message fails to build.
+func (m *SMTP) PrepareMessage(
+	ctx context.Context,
+	msg types.Message,
+	opts ...email.Option,
+) (*Prepared, error) {
+	cfg := sendConfig(opts)
+	msg, err := email.ExpandRecipients(ctx, msg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := email.CheckPolicy(ctx, msg, opts...); err != nil {
+		return nil, err
+	}
+	if err := checkReturnPath(msg, &cfg, opts); err != nil {
+		return nil, err
+	}
+	msg, err = rewriteMessage(ctx, &cfg, msg)
+	if err != nil {
+		return nil, err
+	}
+	built, err := internal.Build(ctx, msg, m.buildOptions(&cfg))
+	if err != nil {
+		return nil, err
+	}
+	if built, _, err = checkSpam(ctx, &cfg, built); err != nil {
+		return nil, err
+	}
+	if cfg.MessageIDOut != nil {
+		*cfg.MessageIDOut = built.MessageID
+	}
+	from := msg.EnvelopeSender()
+	if cfg.EnvelopeFrom != "" {
+		from = cfg.EnvelopeFrom
+	}
+	return &Prepared{
+		built:        built,
+		envelopeFrom: from,
+		rcpts:        msg.EnvelopeRecipients(),
+	}, nil
+}
+
+// SendPrepared sends p to rcpts, or to p.Recipients() if rcpts is
+// empty. The message bytes are sent unchanged, so recipients outside
+// To and Cc receive it as a blind copy. Delivery options (retries,
+// rate limit, pool, hooks, address check, result, envelope sender)
+// apply; build options are ignored.
+//
+// Parameters:
+//   - ctx: The context.
+//   - p: The prepared message.
+//   - rcpts: The envelope recipients.
+//   - opts: The delivery options.
+//
+// Returns:
+//   - error: The error if the email fails to send.
+func (m *SMTP) SendPrepared(
+	ctx context.Context,
+	p *Prepared,
+	rcpts []string,
+	opts ...email.Option,
+) error {
+	if err := m.begin(); err != nil {
+		return err
+	}
+	defer m.inflight.Done()
+	cfg := sendConfig(opts)
+	if len(rcpts) == 0 {
+		rcpts = p.rcpts
+	}
+	if len(rcpts) == 0 {
+		return types.ErrNoRecipients
+	}
+	if err := checkAddresses(ctx, &cfg, rcpts); err != nil {
+		return err
+	}
+	if cfg.Rate != nil {
+		if err := cfg.Rate.Acquire(ctx); err != nil {
+			return err
+		}
+	}
+	from := p.envelopeFrom
+	if cfg.EnvelopeFrom != "" {
+		from = cfg.EnvelopeFrom
+	}
+	return m.deliver(ctx, p.built, from, rcpts, &cfg, nil)
+}
+
+// deliver sends built with retries, filling cfg.Result. rebuild, if
+// set, is used once to replace a body the server cannot accept.
+func (m *SMTP) deliver(
+	ctx context.Context,
+	built *internal.Built,
+	from string,
+	rcpts []string,
+	cfg *email.SendConfig,
+	rebuild func() (*internal.Built, error),
+) error {
+	if cfg.MessageIDOut != nil {
+		*cfg.MessageIDOut = built.MessageID
+	}
+	res := cfg.Result
+	if res == nil {
+		res = &email.SendResult{}
+	}
+	*res = email.SendResult{
+		MessageID:       built.MessageID,
+		Size:            len(built.Raw),
+		AttachmentSizes: built.AttachmentSizes,
+		Warnings:        built.Warnings,
+	}
+	rcpts = withArchive(cfg, rcpts)
+	if m.cfg.LMTP {
+		return m.deliverLMTP(ctx, built, from, rcpts, cfg, res, rebuild)
+	}
 
 	// Choose attempt schedule.
 	var bo email.Backoff = &singleAttempt{}
@@ -112,6 +496,7 @@ func (m *SMTP) Send(
 	}
 
 	attempt := 0
+	var last error
 	for {
 		if cfg.Hooks != nil && cfg.Hooks.OnAttemptStart != nil {
 			ctx = cfg.Hooks.OnAttemptStart(ctx, attempt)
@@ -123,8 +508,8 @@ func (m *SMTP) Send(
 				cfg.Hooks.OnAttemptDone(ctx, attempt,
 					fmt.Errorf("attempts exhausted"))
 			}
-			return fmt.Errorf("send attempts exhausted after %d tries",
-				attempt)
+			return fmt.Errorf("send attempts exhausted after %d tries: %w",
+				attempt, last)
 		}
 		if d > 0 {
 			select {
@@ -137,20 +522,104 @@ func (m *SMTP) Send(
 			}
 		}
 
-		err = m.trySend(ctx, msg, raw, &cfg)
+		res.Attempts = attempt + 1
+		var err error
+		res.Response, err = m.trySend(ctx, from, rcpts, built, cfg)
 		if cfg.Hooks != nil && cfg.Hooks.OnAttemptDone != nil {
 			cfg.Hooks.OnAttemptDone(ctx, attempt, err)
 		}
 		if err == nil {
+			copySent(ctx, cfg, res, built.Raw)
+			m.archiveCopy(ctx, cfg, res, from, built)
 			return nil
 		}
+		if errors.Is(err, errBodyUnsupported) && rebuild != nil {
+			fallback(ctx, cfg, res, err.Error()+"; rebuilt with 7-bit encodings")
+			if built, err = rebuild(); err != nil {
+				return err
+			}
+			rebuild = nil
+			res.Size = len(built.Raw)
+			continue
+		}
 		if !isTransient(err) {
 			return err
 		}
+		last = err
 		attempt++
 	}
 }
 
+// copySent stores the sent copy (WithSentCopy), recording a failure in
+// res instead of failing the send.
+func copySent(
+	ctx context.Context,
+	cfg *email.SendConfig,
+	res *email.SendResult,
+	raw []byte,
+) {
+	if cfg.SentCopier != nil {
+		res.SentCopyErr = cfg.SentCopier.CopySent(ctx, raw)
+	}
+}
+
+// sendConfig applies opts to a new SendConfig.
+func sendConfig(opts []email.Option) email.SendConfig {
+	var cfg email.SendConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	return cfg
+}
+
+// checkAddresses runs the configured AddressChecker over rcpts.
+func checkAddresses(ctx context.Context, cfg *email.SendConfig, rcpts []string) error {
+	if cfg.AddrCheck == nil {
+		return nil
+	}
+	for _, rcpt := range rcpts {
+		if err := cfg.AddrCheck.CheckAddress(ctx, rcpt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkReturnPath applies WithReturnPathAlignment.
+func checkReturnPath(msg types.Message, cfg *email.SendConfig, opts []email.Option) error {
+	if !cfg.RequireReturnPathAlignment {
+		return nil
+	}
+	return email.CheckReturnPathAlignment(msg, opts...)
+}
+
+// rewriteMessage applies WithInlineRemoteImages and
+// WithAttachmentLinks.
+func rewriteMessage(
+	ctx context.Context,
+	cfg *email.SendConfig,
+	msg types.Message,
+) (types.Message, error) {
+	var err error
+	if cfg.InlineImages != nil {
+		if msg, err = email.InlineRemoteImages(ctx, msg, *cfg.InlineImages); err != nil {
+			return msg, err
+		}
+	}
+	if cfg.AttachmentLinks != nil {
+		msg, _, err = cfg.AttachmentLinks.Apply(ctx, msg)
+	}
+	return msg, err
+}
+
+// buildOptions maps send options to MIME build options for the
+// extensions this client may use.
+func (m *SMTP) buildOptions(cfg *email.SendConfig) internal.BuildOptions {
+	opts := internal.SendBuildOptions(cfg)
+	opts.Allow8Bit, opts.AllowBinary = m.cfg.EightBitMIME, m.cfg.BinaryMIME
+	return opts
+}
+
 // singleAttempt is a single attempt backoff.
 type singleAttempt struct{}
 
@@ -169,148 +638,460 @@ func (s *singleAttempt) Next(i int) (time.Duration, bool) {
 	return 0, false
 }
 
-// trySend tries to send an email.
+// trySend tries to send an email. It returns the server's final reply.
 func (m *SMTP) trySend(
 	ctx context.Context,
-	msg types.Message,
-	raw []byte,
+	from string,
+	rcpts []string,
+	built *internal.Built,
+	cfg *email.SendConfig,
+) (string, error) {
+	var resp string
+	err := m.withConn(ctx, cfg, func(conn *smtpConn) error {
+		var err error
+		resp, err = transaction(ctx, conn, from, rcpts, built)
+		return err
+	})
+	return resp, err
+}
+
+// withConn runs fn on a pooled or new, authenticated connection.
+func (m *SMTP) withConn(
+	ctx context.Context,
 	cfg *email.SendConfig,
+	fn func(conn *smtpConn) error,
 ) error {
-	var conn *smtpConn
-	var err error
+	// WithPool takes precedence over the mailer's own pool.
+	pool := cfg.Pool
+	if pool == nil {
+		pool = m.pool
+	}
+	conn, reused, err := m.acquire(ctx, pool)
+	if err != nil {
+		return err
+	}
+	reads := conn.nc.reads()
+	err = m.session(ctx, conn, fn)
+	if err != nil && (reused && conn.stale(err, reads) || errors.Is(err, errCredentialsRotated)) {
+		// The server dropped the idle connection, or it authenticated
+		// with rotated credentials. Nothing was accepted, so redial
+		// once instead of spending a retry attempt.
+		reason := email.EvictStale
+		if errors.Is(err, errCredentialsRotated) {
+			reason = email.EvictCredentials
+		}
+		conn.broken = true
+		m.discard(pool, conn, reason)
+		if conn, err = m.redial(ctx, pool); err != nil {
+			return err
+		}
+		err = m.session(ctx, conn, fn)
+	}
+	m.release(pool, conn)
+	return err
+}
 
-	if cfg.Pool != nil {
-		aconn, aerr := cfg.Pool.Get()
-		if aerr != nil {
-			return aerr
+// acquire returns an idle connection from pool, or dials a new one
+// with ctx, reporting it to the pool's OnDial hook. reused reports
+// whether it was idle in the pool.
+func (m *SMTP) acquire(
+	ctx context.Context,
+	pool *email.ConnPool,
+) (conn *smtpConn, reused bool, err error) {
+	if pool != nil {
+		aconn, err := pool.Get()
+		if err != nil {
+			return nil, false, err
 		}
 		if aconn != nil {
 			conn = aconn.(*smtpConn)
+			reused = conn.used
 		}
 	}
 	if conn == nil {
-		conn, err = m.newConn()
-		if err != nil {
-			return err
+		if conn, err = m.redial(ctx, pool); err != nil {
+			return nil, false, err
 		}
-		defer func() {
-			if cfg.Pool == nil && conn != nil && conn.c != nil {
-				_ = conn.c.Quit()
-			}
-		}()
 	}
-	defer func() {
-		if cfg.Pool != nil && conn != nil {
-			cfg.Pool.Put(conn)
-		}
-	}()
+	conn.used = true
+	return conn, reused, nil
+}
 
-	c := conn.c
+// redial dials a connection, e.g. to replace one that could not be
+// used, reporting it to the pool's OnDial hook.
+func (m *SMTP) redial(ctx context.Context, pool *email.ConnPool) (*smtpConn, error) {
+	start := time.Now()
+	conn, err := m.newConn(ctx)
+	if pool != nil && pool.Hooks != nil && pool.Hooks.OnDial != nil {
+		pool.Hooks.OnDial(time.Since(start), err)
+	}
+	return conn, err
+}
 
-	// Set deadlines using context if possible.
-	var cancel context.CancelFunc
-	if dl, ok := ctx.Deadline(); ok {
-		_, cancel = context.WithDeadline(ctx, dl)
-	} else if m.cfg.Timeout > 0 {
-		_, cancel = context.WithDeadline(ctx, time.Now().Add(m.cfg.Timeout))
+// discard closes a broken conn, reporting reason to the pool's hooks.
+func (m *SMTP) discard(pool *email.ConnPool, conn *smtpConn, reason email.EvictReason) {
+	if pool != nil {
+		pool.Discard(conn, reason)
+	}
+	if err := conn.c.Close(); err != nil && pool != nil && pool.Hooks != nil &&
+		pool.Hooks.OnCloseError != nil {
+		pool.Hooks.OnCloseError(err)
 	}
-	defer cancel()
+}
 
-	if m.cfg.Username != "" && m.cfg.Password != "" {
-		auth := smtp.PlainAuth(
-			"", m.cfg.Username, m.cfg.Password, m.cfg.Host,
-		)
+// release returns conn to pool, or ends the session if there is none.
+func (m *SMTP) release(pool *email.ConnPool, conn *smtpConn) {
+	switch {
+	case conn.broken:
+		// Cut mid-command or dead; it cannot be reused.
+		m.discard(pool, conn, email.EvictBroken)
+	case pool == nil:
+		_ = conn.c.Quit()
+	case pool == m.pool:
+		m.putConn(conn)
+	default:
+		pool.Put(conn)
+	}
+}
+
+// session authenticates conn if needed and runs fn with I/O bound to
+// ctx.
+func (m *SMTP) session(
+	ctx context.Context,
+	conn *smtpConn,
+	fn func(conn *smtpConn) error,
+) error {
+	c := conn.c
+	creds, err := m.credentials(ctx)
+	if err != nil {
+		return err
+	}
+	if conn.authed && creds != conn.creds {
+		// AUTH cannot be repeated in a session (RFC 4954).
+		conn.broken = true
+		return errCredentialsRotated
+	}
+
+	// Bind I/O to ctx: its deadline, or Timeout, bounds the session and
+	// cancellation interrupts a blocked read or write.
+	stop := conn.bind(ctx, m.cfg.Timeout)
+	defer func() {
+		if !stop() {
+			conn.broken = true
+		}
+	}()
+
+	auth, err := creds.auth(m.cfg.Host, m.cfg.AuthMechanism)
+	if err == nil && !conn.authed && auth != nil {
 		if ok, _ := c.Extension("AUTH"); ok {
-			if err := c.Auth(auth); err != nil {
-				return fmt.Errorf("smtp auth: %w", err)
+			if aerr := c.Auth(auth); aerr != nil {
+				err = commandError("auth", "", aerr)
 			}
 		}
 	}
+	if err == nil {
+		conn.authed, conn.creds = true, creds
+		err = fn(conn)
+	}
+	if ctx.Err() != nil {
+		conn.broken = true
+		if err != nil {
+			return fmt.Errorf("smtp: %w", ctx.Err())
+		}
+	}
+	return err
+}
 
-	if err := c.Mail(msg.From.Mail); err != nil {
-		return fmt.Errorf("smtp MAIL FROM: %w", err)
+// transaction runs one MAIL/RCPT/DATA transaction and returns the
+// server's final reply.
+func transaction(
+	ctx context.Context,
+	conn *smtpConn,
+	from string,
+	rcpts []string,
+	built *internal.Built,
+) (string, error) {
+	if err := checkBodyType(conn.caps, built.BodyType); err != nil {
+		return "", err
+	}
+	if err := checkSize(conn.caps, built); err != nil {
+		return "", err
+	}
+	c := conn.c
+	if err := mailFrom(c, from, built.BodyType); err != nil {
+		return "", commandError("MAIL FROM", "", err)
 	}
-	for _, rcpt := range msg.RecipientList() {
+	for _, rcpt := range rcpts {
 		if err := c.Rcpt(rcpt); err != nil {
-			return fmt.Errorf("smtp RCPT TO %s: %w", rcpt, err)
+			return "", commandError("RCPT TO", rcpt, err)
+		}
+	}
+
+	if err := writeBody(ctx, c, built); err != nil {
+		return "", err
+	}
+	code, text, err := c.Text.ReadResponse(250)
+	if err != nil {
+		return "", commandError("end data", "", err)
+	}
+	return fmt.Sprintf("%d %s", code, text), nil
+}
+
+// commandError wraps the error of an SMTP command in a
+// *types.SMTPError, with the server's reply if there was one.
+func commandError(cmd, rcpt string, err error) error {
+	e := &types.SMTPError{Command: cmd, Recipient: rcpt, Err: err}
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		e.Code, e.Message = tpErr.Code, tpErr.Msg
+	}
+	return e
+}
+
+// checkBodyType verifies that the server advertises the extensions the
+// body type needs.
+func checkBodyType(caps Capabilities, bodyType string) error {
+	var need []string
+	switch bodyType {
+	case internal.Body8BitMIME:
+		need = []string{"8BITMIME"}
+	case internal.BodyBinaryMIME:
+		need = []string{"BINARYMIME", "CHUNKING"}
+	}
+	for _, ext := range need {
+		if !caps.Has(ext) {
+			return fmt.Errorf("%w %s: missing %s", errBodyUnsupported,
+				bodyType, ext)
 		}
 	}
+	return nil
+}
+
+// mailFrom issues MAIL FROM. net/smtp adds BODY=8BITMIME on its own
+// when advertised; BODY=BINARYMIME has to be sent by hand.
+func mailFrom(c *smtp.Client, from, bodyType string) error {
+	if bodyType != internal.BodyBinaryMIME {
+		return c.Mail(from)
+	}
+	if err := types.ValidateHeaderValue("MAIL FROM", from); err != nil {
+		return err
+	}
+	id, err := c.Text.Cmd("MAIL FROM:<%s> BODY=BINARYMIME", from)
+	if err != nil {
+		return err
+	}
+	c.Text.StartResponse(id)
+	defer c.Text.EndResponse(id)
+	_, _, err = c.Text.ReadResponse(250)
+	return err
+}
+
+// writeBody sends the message with DATA, or with BDAT for binary
+// bodies, leaving the final reply to be read. It drives the textproto
+// connection directly because net/smtp discards the reply text after
+// the terminating dot.
+func writeBody(ctx context.Context, c *smtp.Client, built *internal.Built) error {
+	if built.BodyType == internal.BodyBinaryMIME {
+		return writeBDAT(ctx, c, built.Raw)
+	}
+	return writeData(ctx, c, built.Raw)
+}
 
-	w, err := c.Data()
+// writeData runs the DATA command and writes raw, dot-stuffed.
+func writeData(ctx context.Context, c *smtp.Client, raw []byte) error {
+	id, err := c.Text.Cmd("DATA")
 	if err != nil {
-		return fmt.Errorf("smtp DATA: %w", err)
+		return commandError("DATA", "", err)
 	}
-	if _, err := w.Write(raw); err != nil {
-		_ = w.Close()
-		return fmt.Errorf("smtp write: %w", err)
+	c.Text.StartResponse(id)
+	_, _, err = c.Text.ReadResponse(354)
+	c.Text.EndResponse(id)
+	if err != nil {
+		return commandError("DATA", "", err)
+	}
+	w := c.Text.DotWriter()
+	if _, err := (ctxWriter{ctx, w}).Write(raw); err != nil {
+		// Without the final dot the transaction is abandoned.
+		return commandError("write", "", err)
 	}
 	if err := w.Close(); err != nil {
-		return fmt.Errorf("smtp end data: %w", err)
+		return commandError("write", "", err)
 	}
+	return nil
+}
 
+// writeBDAT transfers raw as a single BDAT LAST chunk (RFC 3030).
+// Unlike DATA, no dot-stuffing is applied, which is what makes binary
+// bodies possible.
+func writeBDAT(ctx context.Context, c *smtp.Client, raw []byte) error {
+	id, err := c.Text.Cmd("BDAT %d LAST", len(raw))
+	if err != nil {
+		return commandError("BDAT", "", err)
+	}
+	// Mark the reply slot as taken so later commands are not blocked;
+	// the caller reads the reply.
+	c.Text.StartResponse(id)
+	c.Text.EndResponse(id)
+	if _, err := (ctxWriter{ctx, c.Text.W}).Write(raw); err != nil {
+		return commandError("write", "", err)
+	}
+	if err := c.Text.W.Flush(); err != nil {
+		return commandError("write", "", err)
+	}
 	return nil
 }
 
 // newConn creates a new SMTP connection.
-func (m *SMTP) newConn() (*smtpConn, error) {
+func (m *SMTP) newConn(ctx context.Context) (*smtpConn, error) {
 	hostPort := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
 	local := m.cfg.LocalName
 	if local == "" {
 		local, _ = internal.OsHostname()
 	}
 
-	var c *smtp.Client
-	var err error
-	if m.cfg.ImplicitTLS {
-		conf := &tls.Config{
-			ServerName:         m.cfg.Host,
-			InsecureSkipVerify: m.cfg.SkipVerify,
-		}
-		dialer := &net.Dialer{Timeout: m.cfg.Timeout}
-		conn, derr := tls.DialWithDialer(dialer, "tcp", hostPort, conf)
-		if derr != nil {
-			return nil, fmt.Errorf("smtp tls dial: %w", derr)
-		}
-		c, err = smtp.NewClient(conn, m.cfg.Host)
-		if err != nil {
-			return nil, fmt.Errorf("smtp new client: %w", err)
+	conf := m.tlsConfig()
+	nc, err := m.dial(ctx, hostPort)
+	if err != nil {
+		if m.cfg.ImplicitTLS {
+			return nil, fmt.Errorf("smtp tls dial: %w", err)
 		}
-	} else {
-		dialer := &net.Dialer{Timeout: m.cfg.Timeout}
-		conn, derr := dialer.Dial("tcp", hostPort)
-		if derr != nil {
-			return nil, fmt.Errorf("smtp dial: %w", derr)
+		return nil, fmt.Errorf("smtp dial: %w", err)
+	}
+	raw := &countConn{Conn: nc}
+	var conn net.Conn = raw
+	if m.cfg.LMTP && m.cfg.StartTLS && !m.cfg.ImplicitTLS {
+		_ = nc.Close()
+		return nil, errors.New("smtp: STARTTLS is not supported with LMTP")
+	}
+	if m.cfg.ImplicitTLS {
+		tc := tls.Client(conn, conf)
+		if err := m.handshake(ctx, tc); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("smtp tls dial: %w", err)
 		}
-		c, err = smtp.NewClient(conn, m.cfg.Host)
-		if err != nil {
-			return nil, fmt.Errorf("smtp new client: %w", err)
+		conn = tc
+	}
+	if m.cfg.LMTP {
+		conn = &lhloConn{Conn: conn}
+	}
+	c, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("smtp new client: %w", err)
+	}
+	// Hello must come first: Extension would otherwise send EHLO with
+	// the default name and make Hello fail. StartTLS repeats it.
+	if err := c.Hello(local); err != nil {
+		_ = c.Quit()
+		return nil, commandError("EHLO", "", err)
+	}
+	if m.cfg.StartTLS && !m.cfg.ImplicitTLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if terr := c.StartTLS(conf); terr != nil {
+				_ = c.Quit()
+				return nil, commandError("starttls", "", terr)
+			}
 		}
-		if m.cfg.StartTLS {
-			conf := &tls.Config{
-				ServerName:         m.cfg.Host,
-				InsecureSkipVerify: m.cfg.SkipVerify,
+	}
+	return &smtpConn{
+		c: c, nc: raw, caps: capabilities(c),
+	}, nil
+}
+
+// tlsConfig returns the TLS settings for a new connection.
+func (m *SMTP) tlsConfig() *tls.Config {
+	conf := &tls.Config{}
+	if m.cfg.TLSConfig != nil {
+		conf = m.cfg.TLSConfig.Clone()
+	}
+	if conf.ServerName == "" {
+		conf.ServerName = m.cfg.Host
+	}
+	if m.cfg.SkipVerify {
+		conf.InsecureSkipVerify = true
+	}
+	if len(m.cfg.PinnedSPKI) > 0 {
+		pins := m.cfg.PinnedSPKI
+		next := conf.VerifyConnection
+		conf.VerifyConnection = func(cs tls.ConnectionState) error {
+			if err := verifyPins(cs.PeerCertificates, pins); err != nil {
+				return err
 			}
-			if ok, _ := c.Extension("STARTTLS"); ok {
-				if terr := c.StartTLS(conf); terr != nil {
-					_ = c.Quit()
-					return nil, fmt.Errorf("smtp starttls: %w", terr)
-				}
+			if next != nil {
+				return next(cs)
 			}
+			return nil
 		}
 	}
+	return conf
+}
 
-	if err := c.Hello(local); err != nil {
-		_ = c.Quit()
-		return nil, fmt.Errorf("smtp EHLO: %w", err)
+// SPKIPin returns the base64 SHA-256 hash of the certificate's public
+// key (SubjectPublicKeyInfo), for use in SMTPConfig.PinnedSPKI. The
+// same value is printed by:
+//
+//	openssl x509 -pubkey -noout | openssl pkey -pubin -outform der |
+//	openssl dgst -sha256 -binary | base64
+//
+// Parameters:
+//   - cert: The certificate.
+//
+// Returns:
+//   - string: The pin.
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// verifyPins checks that one of certs matches one of pins.
+func verifyPins(certs []*x509.Certificate, pins []string) error {
+	for _, c := range certs {
+		if slices.Contains(pins, SPKIPin(c)) {
+			return nil
+		}
 	}
-	return &smtpConn{c: c, tls: m.cfg.ImplicitTLS || m.cfg.StartTLS}, nil
+	return errors.New("smtp tls: no certificate matches the pinned keys")
 }
 
-// isTransient checks if an error is transient.
+// dial connects to hostPort with the configured Dialer, or to each
+// address of the host in turn (see dialAll). Timeout bounds the dial.
+func (m *SMTP) dial(ctx context.Context, hostPort string) (net.Conn, error) {
+	if m.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.cfg.Timeout)
+		defer cancel()
+	}
+	if m.cfg.Dialer != nil {
+		// The dialer may resolve the host itself, e.g. a SOCKS5 proxy.
+		return m.cfg.Dialer.DialContext(ctx, "tcp", hostPort)
+	}
+	return m.dialAll(ctx, hostPort)
+}
+
+// handshake runs the TLS handshake, bounded by Timeout.
+func (m *SMTP) handshake(ctx context.Context, tc *tls.Conn) error {
+	if m.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.cfg.Timeout)
+		defer cancel()
+	}
+	return tc.HandshakeContext(ctx)
+}
+
+// isTransient checks if an error is transient: a 4xx reply, a timeout
+// or, for errors without a reply code, text that reads like one.
 func isTransient(err error) bool {
 	if errors.Is(err, context.DeadlineExceeded) {
 		return true
 	}
+	var se *types.SMTPError
+	if errors.As(err, &se) && se.Code != 0 {
+		return se.Code/100 == 4
+	}
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		return tpErr.Code/100 == 4
+	}
 	msg := err.Error()
 	if strings.Contains(msg, " 4") || strings.Contains(msg, "4xx") {
 		return true
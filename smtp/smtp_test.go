@@ -1,26 +1,705 @@
 package smtp
 
-import "testing"
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
 
 func TestIsTransient(t *testing.T) {
-    cases := []struct{
-        err error
-        want bool
-    }{
-        {errString("421 try again later"), true},
-        {errString("4xx mailbox full"), true},
-        {errString("Timeout while reading"), true},
-        {errString("connection reset by peer"), true},
-        {errString("permanent 550 user unknown"), false},
-        {errString("syntax error"), false},
-    }
-    for _, c := range cases {
-        if got := isTransient(c.err); got != c.want {
-            t.Fatalf("isTransient(%q)=%v want %v", c.err, got, c.want)
-        }
-    }
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{errString("421 try again later"), true},
+		{errString("4xx mailbox full"), true},
+		{errString("Timeout while reading"), true},
+		{errString("connection reset by peer"), true},
+		{errString("permanent 550 user unknown"), false},
+		{errString("syntax error"), false},
+		{&types.SMTPError{Command: "RCPT TO", Code: 452, Message: "4.2.2 mailbox full"}, true},
+		{&types.SMTPError{Command: "RCPT TO", Code: 550, Message: "5.1.1 user unknown, try again never"}, false},
+		{fmt.Errorf("send: %w", &types.SMTPError{Command: "DATA", Code: 554, Message: "5.7.1 rejected 4xx"}), false},
+		{&types.SMTPError{Command: "DATA", Err: errString("connection reset by peer")}, true},
+		{&textproto.Error{Code: 421, Msg: "4.3.2 shutting down"}, true},
+		{&textproto.Error{Code: 535, Msg: "5.7.8 temporarily locked"}, false},
+	}
+	for _, c := range cases {
+		if got := isTransient(c.err); got != c.want {
+			t.Fatalf("isTransient(%q)=%v want %v", c.err, got, c.want)
+		}
+	}
 }
 
 type errString string
+
 func (e errString) Error() string { return string(e) }
 
+func TestSendUsesEnvelopeFrom(t *testing.T) {
+	srv := newFakeServer(t)
+	m := NewSMTP(srv.config())
+	msg := types.Message{
+		From:         types.Address{Mail: "app@example.com"},
+		To:           []types.Address{{Mail: "ada@example.org"}},
+		Plain:        []byte("hi"),
+		EnvelopeFrom: "bounces@example.com",
+	}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if err := m.Send(context.Background(), msg, email.WithEnvelopeFrom("verp@example.com")); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	var froms []string
+	for _, c := range srv.commands() {
+		if strings.HasPrefix(c, "MAIL FROM:") {
+			froms = append(froms, c)
+		}
+	}
+	if len(froms) != 2 || !strings.HasPrefix(froms[0], "MAIL FROM:<bounces@example.com>") ||
+		!strings.HasPrefix(froms[1], "MAIL FROM:<verp@example.com>") {
+		t.Fatalf("unexpected MAIL FROM commands: %v", froms)
+	}
+	msgs := srv.messages()
+	if len(msgs) != 2 || !strings.Contains(msgs[0], "From: app@example.com\r\n") {
+		t.Fatalf("From header should be unchanged: %v", msgs)
+	}
+}
+
+func TestSendUsesEnvelopeTo(t *testing.T) {
+	srv := newFakeServer(t)
+	m := NewSMTP(srv.config())
+	msg := types.Message{
+		From:       types.Address{Mail: "app@example.com"},
+		To:         []types.Address{{Name: "Team", Mail: "team@example.com"}},
+		Plain:      []byte("hi"),
+		EnvelopeTo: []string{"ada@example.org", "archive@example.com"},
+	}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	var rcpts []string
+	for _, c := range srv.commands() {
+		if strings.HasPrefix(c, "RCPT TO:") {
+			rcpts = append(rcpts, c)
+		}
+	}
+	if len(rcpts) != 2 || rcpts[0] != "RCPT TO:<ada@example.org>" ||
+		rcpts[1] != "RCPT TO:<archive@example.com>" {
+		t.Fatalf("unexpected RCPT TO commands: %v", rcpts)
+	}
+	if !strings.Contains(srv.messages()[0], "To: \"Team\" <team@example.com>\r\n") {
+		t.Fatalf("To header should be unchanged:\n%s", srv.messages()[0])
+	}
+}
+
+func TestSendMessageIDOut(t *testing.T) {
+	srv := newFakeServer(t)
+	m := NewSMTP(srv.config())
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("hi"),
+	}
+	var id string
+	err := m.Send(context.Background(), msg,
+		email.WithMessageIDDomain("mail.example.com"),
+		email.WithMessageIDOut(&id))
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if !strings.HasSuffix(id, "@mail.example.com>") ||
+		!strings.Contains(srv.messages()[0], "Message-ID: "+id+"\r\n") {
+		t.Fatalf("unexpected Message-ID %q", id)
+	}
+}
+
+func TestSendResult(t *testing.T) {
+	srv := newFakeServer(t)
+	m := NewSMTP(srv.config())
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("hi"),
+	}
+	var res email.SendResult
+	if err := m.Send(context.Background(), msg, email.WithResult(&res)); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if res.MessageID == "" || res.Attempts != 1 || res.Size == 0 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if res.Response != "250 2.0.0 Ok: queued as ABC123" {
+		t.Fatalf("unexpected response: %q", res.Response)
+	}
+	if got := len(srv.messages()[0]); got != res.Size {
+		t.Fatalf("size mismatch: server saw %d, result says %d", got, res.Size)
+	}
+
+	srv.setReply("RCPT", "550 5.1.1 no such user")
+	res = email.SendResult{}
+	if err := m.Send(context.Background(), msg, email.WithResult(&res)); err == nil {
+		t.Fatalf("expected error")
+	}
+	if res.MessageID == "" || res.Attempts != 1 || res.Response != "" {
+		t.Fatalf("unexpected result after failure: %+v", res)
+	}
+
+	m = NewSMTP(newFakeServer(t).config())
+	cache := email.NewAttachmentCache(1 << 20)
+	for i := 0; i < 2; i++ {
+		msg.Attach = []types.Attachment{{Filename: "a.txt", Reader: strings.NewReader("hello")}}
+		res = email.SendResult{}
+		err := m.Send(context.Background(), msg,
+			email.WithResult(&res), email.WithAttachmentCache(cache))
+		if err != nil {
+			t.Fatalf("send with attachment: %v", err)
+		}
+		if len(res.AttachmentSizes) != 1 || res.AttachmentSizes[0] != 8 {
+			t.Fatalf("unexpected attachment sizes: %v", res.AttachmentSizes)
+		}
+	}
+	if cache.Len() != 1 {
+		t.Fatalf("expected one cached attachment, got %d", cache.Len())
+	}
+}
+
+func TestSendTransferEncodings(t *testing.T) {
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("Grüße aus Köln"),
+	}
+	send := func(ext []string, cfgFn func(*SMTPConfig)) (*fakeServer, email.SendResult) {
+		t.Helper()
+		srv := newFakeServer(t)
+		srv.ext = ext
+		cfg := srv.config()
+		cfgFn(&cfg)
+		var res email.SendResult
+		if err := NewSMTP(cfg).Send(context.Background(), msg, email.WithResult(&res)); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+		return srv, res
+	}
+	mailCmd := func(srv *fakeServer) string {
+		for _, c := range srv.commands() {
+			if strings.HasPrefix(c, "MAIL FROM:") {
+				return c
+			}
+		}
+		return ""
+	}
+
+	srv, _ := send([]string{"8BITMIME"}, func(c *SMTPConfig) { c.EightBitMIME = true })
+	if !strings.Contains(mailCmd(srv), "BODY=8BITMIME") ||
+		!strings.Contains(srv.messages()[0], "Content-Transfer-Encoding: 8bit\r\n") ||
+		!strings.Contains(srv.messages()[0], "Grüße aus Köln") {
+		t.Fatalf("expected 8bit body: %q\n%s", mailCmd(srv), srv.messages()[0])
+	}
+
+	// Without 8BITMIME the message is rebuilt as quoted-printable.
+	srv, res := send(nil, func(c *SMTPConfig) { c.EightBitMIME = true })
+	data := srv.messages()[0]
+	if strings.Contains(mailCmd(srv), "BODY=") ||
+		!strings.Contains(data, "Content-Transfer-Encoding: quoted-printable\r\n") ||
+		!strings.Contains(data, "Message-ID: "+res.MessageID+"\r\n") ||
+		res.Size != len(data) {
+		t.Fatalf("expected 7-bit fallback: %+v\n%s", res, data)
+	}
+	if len(res.Fallbacks) != 1 || !strings.Contains(res.Fallbacks[0], "missing 8BITMIME") {
+		t.Fatalf("expected the fallback recorded: %q", res.Fallbacks)
+	}
+
+	msg.Plain = []byte(strings.Repeat("x", 1200))
+	srv, res = send([]string{"BINARYMIME", "CHUNKING"}, func(c *SMTPConfig) { c.BinaryMIME = true })
+	if !strings.Contains(mailCmd(srv), "BODY=BINARYMIME") ||
+		!strings.Contains(srv.messages()[0], "Content-Transfer-Encoding: binary\r\n") ||
+		res.Response != "250 2.0.0 Ok: queued as BDAT1" {
+		t.Fatalf("expected binary body over BDAT: %q %+v", mailCmd(srv), res)
+	}
+
+	// An explicit 8bit override fails instead of falling back.
+	msg.TextEncoding = types.Transfer8Bit
+	msg.Plain = []byte("Grüße")
+	srv = newFakeServer(t)
+	srv.ext = nil
+	if err := NewSMTP(srv.config()).Send(context.Background(), msg); err == nil ||
+		!strings.Contains(err.Error(), "8BITMIME") {
+		t.Fatalf("expected unsupported body error, got %v", err)
+	}
+}
+
+func TestSendPrepared(t *testing.T) {
+	srv := newFakeServer(t)
+	m := NewSMTP(srv.config())
+	msg := types.Message{
+		From:    types.Address{Mail: "news@example.com"},
+		To:      []types.Address{{Mail: "list@example.com"}},
+		Subject: "Issue 1",
+		Plain:   []byte("hello"),
+		Attach:  []types.Attachment{{Filename: "a.txt", Reader: strings.NewReader("x")}},
+	}
+	p, err := m.PrepareMessage(context.Background(), msg,
+		email.WithEnvelopeFrom("bounces@example.com"))
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	if p.MessageID() == "" || p.Size() != len(p.Bytes()) ||
+		!slices.Equal(p.Recipients(), []string{"list@example.com"}) {
+		t.Fatalf("unexpected prepared message: %s %d %v", p.MessageID(), p.Size(), p.Recipients())
+	}
+
+	var res email.SendResult
+	if err := m.SendPrepared(context.Background(), p,
+		[]string{"a@example.org", "b@example.org"}, email.WithResult(&res)); err != nil {
+		t.Fatalf("send 1: %v", err)
+	}
+	if res.MessageID != p.MessageID() || res.Size != p.Size() || res.Attempts != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if err := m.SendPrepared(context.Background(), p, nil,
+		email.WithEnvelopeFrom("other@example.com")); err != nil {
+		t.Fatalf("send 2: %v", err)
+	}
+	msgs := srv.messages()
+	if len(msgs) != 2 || msgs[0] != msgs[1] || !strings.Contains(msgs[0], "Subject: Issue 1") {
+		t.Fatalf("expected identical messages, got %d", len(msgs))
+	}
+	var env []string
+	for _, c := range srv.commands() {
+		if strings.HasPrefix(c, "MAIL") || strings.HasPrefix(c, "RCPT") {
+			env = append(env, c)
+		}
+	}
+	want := []string{
+		"MAIL FROM:<bounces@example.com> BODY=8BITMIME",
+		"RCPT TO:<a@example.org>", "RCPT TO:<b@example.org>",
+		"MAIL FROM:<other@example.com> BODY=8BITMIME",
+		"RCPT TO:<list@example.com>",
+	}
+	if !slices.Equal(env, want) {
+		t.Fatalf("unexpected envelope commands:\n%v", env)
+	}
+}
+
+func TestSendFanOut(t *testing.T) {
+	srv := newFakeServer(t)
+	m := NewSMTP(srv.config())
+	msg := types.Message{
+		From:    types.Address{Mail: "news@example.com"},
+		To:      []types.Address{{Name: "Ada", Mail: "ada@example.org"}},
+		Cc:      []types.Address{{Mail: "cc@example.org"}},
+		Bcc:     []types.Address{{Mail: "hidden@example.net"}},
+		Subject: "Hello",
+		Plain:   []byte("hi"),
+		Attach:  []types.Attachment{{Filename: "a.txt", Reader: strings.NewReader("attached")}},
+	}
+	var res email.SendResult
+	err := m.Send(context.Background(), msg, email.WithResult(&res),
+		email.WithFanOut(email.FanOut{PersonalizeTo: true, VERP: "bounces@example.com"}))
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if srv.connections() != 1 || len(res.Recipients) != 3 {
+		t.Fatalf("expected one connection and 3 results: %d %+v", srv.connections(), res)
+	}
+	msgs := srv.messages()
+	for i, r := range res.Recipients {
+		if r.Err != nil || r.MessageID == "" || !strings.HasPrefix(r.Response, "250") {
+			t.Fatalf("unexpected result %d: %+v", i, r)
+		}
+		want, _ := email.VERP("bounces@example.com", r.Recipient)
+		if r.EnvelopeFrom != want {
+			t.Fatalf("unexpected envelope from %q", r.EnvelopeFrom)
+		}
+		if strings.Contains(msgs[i], "Cc:") || strings.Contains(msgs[i], "hidden@") &&
+			r.Recipient != "hidden@example.net" || !strings.Contains(msgs[i], "YXR0YWNoZWQ=") {
+			t.Fatalf("message %d leaks recipients or lost attachment:\n%s", i, msgs[i])
+		}
+	}
+	if !strings.Contains(msgs[0], `To: "Ada" <ada@example.org>`+"\r\n") ||
+		!strings.Contains(msgs[2], "To: hidden@example.net\r\n") {
+		t.Fatalf("To not personalized:\n%s\n%s", msgs[0], msgs[2])
+	}
+	var rcpts int
+	for _, c := range srv.commands() {
+		if strings.HasPrefix(c, "RCPT") {
+			rcpts++
+		}
+	}
+	if rcpts != 3 {
+		t.Fatalf("expected one RCPT per transaction, got %d", rcpts)
+	}
+
+	// Shared body: identical bytes, Bcc never in headers; failures are
+	// reported per recipient and the connection is reset between them.
+	srv = newFakeServer(t)
+	m = NewSMTP(srv.config())
+	msg.Attach = nil
+	if err := m.Send(context.Background(), msg, email.WithFanOut(email.FanOut{})); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	msgs = srv.messages()
+	if len(msgs) != 3 || msgs[0] != msgs[2] || strings.Contains(msgs[0], "hidden@") ||
+		!strings.Contains(msgs[0], "Cc: cc@example.org") {
+		t.Fatalf("unexpected shared messages:\n%s", msgs[0])
+	}
+
+	srv.setReply("RCPT", "550 5.1.1 no such user")
+	res = email.SendResult{}
+	err = m.Send(context.Background(), msg, email.WithResult(&res), email.WithFanOut(email.FanOut{}))
+	if err == nil || !strings.Contains(err.Error(), "hidden@example.net: smtp RCPT TO") {
+		t.Fatalf("expected joined per-recipient errors, got %v", err)
+	}
+	for _, r := range res.Recipients {
+		if r.Err == nil {
+			t.Fatalf("expected failure for %s", r.Recipient)
+		}
+	}
+	var rsets int
+	for _, c := range srv.commands() {
+		if c == "RSET" {
+			rsets++
+		}
+	}
+	if rsets != 3 {
+		t.Fatalf("expected RSET after each failure, got %d", rsets)
+	}
+}
+
+type recordingCopier struct {
+	raw [][]byte
+	err error
+}
+
+func (c *recordingCopier) CopySent(_ context.Context, raw []byte) error {
+	c.raw = append(c.raw, raw)
+	return c.err
+}
+
+func TestSendSentCopy(t *testing.T) {
+	srv := newFakeServer(t)
+	m := NewSMTP(srv.config())
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Bcc:   []types.Address{{Mail: "hidden@example.org"}},
+		Plain: []byte("hi"),
+	}
+	copier := &recordingCopier{}
+	var res email.SendResult
+	if err := m.Send(context.Background(), msg, email.WithResult(&res),
+		email.WithSentCopy(copier)); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if len(copier.raw) != 1 || string(copier.raw[0]) != srv.messages()[0] || res.SentCopyErr != nil {
+		t.Fatalf("copy differs from sent message:\n%s", copier.raw)
+	}
+
+	// A failed copy is reported but does not fail the send.
+	copier = &recordingCopier{err: errors.New("imap down")}
+	if err := m.Send(context.Background(), msg, email.WithResult(&res),
+		email.WithSentCopy(copier)); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if res.SentCopyErr == nil || res.Response == "" {
+		t.Fatalf("expected copy error in result: %+v", res)
+	}
+
+	// Nothing is copied when the send fails, and fan-out copies once.
+	srv.setReply("RCPT", "550 5.1.1 no such user")
+	copier = &recordingCopier{}
+	if err := m.Send(context.Background(), msg, email.WithSentCopy(copier)); err == nil || len(copier.raw) != 0 {
+		t.Fatalf("expected failed send without copy: %v %d", err, len(copier.raw))
+	}
+	m = NewSMTP(newFakeServer(t).config())
+	if err := m.Send(context.Background(), msg, email.WithSentCopy(copier),
+		email.WithFanOut(email.FanOut{PersonalizeTo: true})); err != nil || len(copier.raw) != 1 {
+		t.Fatalf("expected one copy for fan-out: %v %d", err, len(copier.raw))
+	}
+}
+
+type countingDialer struct {
+	net.Dialer
+	addrs []string
+}
+
+func (d *countingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	d.addrs = append(d.addrs, addr)
+	return d.Dialer.DialContext(ctx, network, addr)
+}
+
+func TestSendCustomDialer(t *testing.T) {
+	srv := newFakeServer(t)
+	cfg := srv.config()
+	d := &countingDialer{}
+	cfg.Dialer = d
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("hi"),
+	}
+	if err := NewSMTP(cfg).Send(context.Background(), msg); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	want := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	if len(d.addrs) != 1 || d.addrs[0] != want {
+		t.Fatalf("dialer not used: %v", d.addrs)
+	}
+
+	// Implicit TLS runs over the dialed connection; the fake server
+	// does not speak TLS, so only the dial is checked.
+	cfg.ImplicitTLS, cfg.Timeout = true, time.Second
+	if err := NewSMTP(cfg).Send(context.Background(), msg); err == nil ||
+		!strings.Contains(err.Error(), "smtp tls dial") || len(d.addrs) != 2 {
+		t.Fatalf("expected TLS failure after custom dial: %v %v", err, d.addrs)
+	}
+}
+
+// testCert returns a self-signed certificate for 127.0.0.1.
+func testCert(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake smtp"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, leaf
+}
+
+func TestSendTLSConfigPinning(t *testing.T) {
+	cert, leaf := testCert(t)
+	srv := newFakeTLSServer(t, cert)
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("hi"),
+	}
+	base := srv.config()
+	base.ImplicitTLS = true
+
+	// The self-signed certificate is not trusted by default.
+	if err := NewSMTP(base).Send(context.Background(), msg); err == nil {
+		t.Fatal("expected verification failure")
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	tlsConf := &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	cfg := base
+	cfg.TLSConfig = tlsConf
+	cfg.PinnedSPKI = []string{SPKIPin(leaf)}
+	if err := NewSMTP(cfg).Send(context.Background(), msg); err != nil {
+		t.Fatalf("send with pin: %v", err)
+	}
+	if tlsConf.ServerName != "" || tlsConf.VerifyConnection != nil {
+		t.Fatal("TLSConfig was modified")
+	}
+
+	cfg.PinnedSPKI = []string{"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}
+	err := NewSMTP(cfg).Send(context.Background(), msg)
+	if err == nil || !strings.Contains(err.Error(), "no certificate matches the pinned keys") {
+		t.Fatalf("expected pin mismatch, got %v", err)
+	}
+
+	// Pins are checked even when chain verification is skipped.
+	cfg.TLSConfig, cfg.SkipVerify = nil, true
+	if err := NewSMTP(cfg).Send(context.Background(), msg); err == nil {
+		t.Fatal("expected pin mismatch with SkipVerify")
+	}
+	cfg.PinnedSPKI = []string{SPKIPin(leaf)}
+	if err := NewSMTP(cfg).Send(context.Background(), msg); err != nil {
+		t.Fatalf("send with SkipVerify and pin: %v", err)
+	}
+}
+
+func TestSendReturnPathAlignment(t *testing.T) {
+	srv := newFakeServer(t)
+	m := NewSMTP(srv.config())
+	msg := types.Message{
+		From:         types.Address{Mail: "news@example.com"},
+		To:           []types.Address{{Mail: "ada@example.org"}},
+		EnvelopeFrom: "bounces@esp.example.net",
+		Plain:        []byte("Hi"),
+	}
+	err := m.Send(context.Background(), msg, email.WithReturnPathAlignment())
+	if !errors.Is(err, email.ErrReturnPathMisaligned) || len(srv.commands()) != 0 {
+		t.Fatalf("expected refusal before connecting, got %v", err)
+	}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("without the option: %v", err)
+	}
+	err = m.Send(context.Background(), msg, email.WithReturnPathAlignment(),
+		email.WithEnvelopeFrom("bounces@mail.example.com"))
+	if err != nil {
+		t.Fatalf("aligned override: %v", err)
+	}
+}
+
+func TestSendTypedErrors(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.mu.Lock()
+	srv.rcptReply = map[string]string{"bob@example.org": "550 5.1.1 no such user"}
+	srv.mu.Unlock()
+	m := NewSMTP(srv.config())
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "bob@example.org"}},
+		Plain: []byte("hi"),
+	}
+	err := m.Send(context.Background(), msg)
+	var se *types.SMTPError
+	if !errors.As(err, &se) || se.Command != "RCPT TO" || se.Recipient != "bob@example.org" ||
+		se.Code != 550 || se.EnhancedCode() != "5.1.1" || se.Temporary() {
+		t.Fatalf("unexpected error %v (%+v)", err, se)
+	}
+	if !strings.HasPrefix(err.Error(), "smtp RCPT TO bob@example.org: 550 ") {
+		t.Fatalf("error text changed: %q", err)
+	}
+
+	// Every line of a multi-line reply is kept.
+	srv.setReply("MAIL", "554-5.7.1 blocked using zen.spamhaus.org\n554 5.7.1 see https://check.spamhaus.org/")
+	err = m.Send(context.Background(), msg)
+	if !errors.As(err, &se) || se.Code != 554 || len(se.Lines()) != 2 ||
+		!strings.Contains(err.Error(), "see https://check.spamhaus.org/") {
+		t.Fatalf("multi-line reply lost: %v", err)
+	}
+	msg.To = nil
+	if err := m.Send(context.Background(), msg); !errors.Is(err, types.ErrNoRecipients) {
+		t.Fatalf("expected ErrNoRecipients, got %v", err)
+	}
+}
+
+func TestSendPolicy(t *testing.T) {
+	srv := newFakeServer(t)
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}, {Mail: "bob@example.org"}},
+		Plain: []byte("hi"),
+	}
+	maxOne := email.PolicyFunc(func(ctx context.Context, m types.Message) error {
+		if len(m.EnvelopeRecipients()) > 1 {
+			return &email.PolicyError{Rule: "max-recipients", Reason: "too many"}
+		}
+		return nil
+	})
+	err := NewSMTP(srv.config()).Send(context.Background(), msg, email.WithPolicy(maxOne),
+		email.WithArchiveCopy("journal@example.com", email.ArchiveRequired))
+	if !errors.Is(err, email.ErrPolicyViolation) || len(srv.commands()) != 0 {
+		t.Fatalf("expected a policy error before connecting, got %v, %v", err, srv.commands())
+	}
+	msg.To = msg.To[:1]
+	if err := NewSMTP(srv.config()).Send(context.Background(), msg, email.WithPolicy(maxOne),
+		email.WithArchiveCopy("journal@example.com", email.ArchiveRequired)); err != nil {
+		t.Fatalf("archive recipient counted by policy: %v", err)
+	}
+}
+
+func TestSendRecipientExpansion(t *testing.T) {
+	srv := newFakeServer(t)
+	book := email.NewAddressBook()
+	book.Set("team@internal", types.Address{Mail: "ada@example.org"}, types.Address{Mail: "bob@example.org"})
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "team@internal"}},
+		Plain: []byte("hi"),
+	}
+	err := NewSMTP(srv.config()).Send(context.Background(), msg,
+		email.WithRecipientExpansion(email.RecipientExpansion{Resolver: book}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rcpts []string
+	for _, c := range srv.commands() {
+		if strings.HasPrefix(c, "RCPT TO:") {
+			rcpts = append(rcpts, c)
+		}
+	}
+	if len(rcpts) != 2 || !strings.Contains(rcpts[0], "ada@example.org") ||
+		!strings.Contains(rcpts[1], "bob@example.org") {
+		t.Fatalf("RCPT %v", rcpts)
+	}
+	if raw := srv.messages()[0]; !strings.Contains(raw, "To: ada@example.org, bob@example.org") {
+		t.Fatalf("To header not expanded:\n%s", raw)
+	}
+}
+
+func TestSendSameMessageConcurrently(t *testing.T) {
+	srv := newFakeServer(t)
+	cfg := srv.config()
+	cfg.PoolMaxIdle = 4
+	m := NewSMTP(cfg)
+	msg := types.Message{
+		From:    types.Address{Mail: "app@example.com"},
+		To:      []types.Address{{Mail: "ada@example.org"}},
+		Plain:   []byte("hello"),
+		Headers: make(types.Headers, 0, 4),
+		Attach: []types.Attachment{{
+			Filename: "a.txt", Reader: strings.NewReader("attached"),
+		}},
+	}
+	msg.Headers.Add("X-Campaign", "spring")
+	hooks := &types.Hooks{
+		OnBuildStart: func(ctx context.Context, m *types.Message) context.Context {
+			m.Headers.Set("X-Campaign", "hook")
+			return ctx
+		},
+	}
+	errs := make(chan error, 4)
+	for range cap(errs) {
+		go func() { errs <- m.Send(context.Background(), msg, email.WithHooks(hooks)) }()
+	}
+	for range cap(errs) {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgs := srv.messages()
+	if len(msgs) != 4 {
+		t.Fatalf("messages = %d", len(msgs))
+	}
+	for _, got := range msgs {
+		if !strings.Contains(got, "YXR0YWNoZWQ=") || !strings.Contains(got, "X-Campaign: hook") {
+			t.Fatalf("message:\n%s", got)
+		}
+	}
+	if msg.Headers.Get("X-Campaign") != "spring" {
+		t.Fatalf("message changed: %+v", msg.Headers)
+	}
+}
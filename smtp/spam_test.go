@@ -0,0 +1,79 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
+
+// fixedSpam is a SpamChecker returning a fixed report.
+type fixedSpam struct {
+	rep   email.SpamReport
+	calls int
+}
+
+func (f *fixedSpam) CheckSpam(ctx context.Context, raw []byte) (email.SpamReport, error) {
+	f.calls++
+	if !strings.Contains(string(raw), "Subject: Deal") {
+		return email.SpamReport{}, errors.New("unexpected message")
+	}
+	return f.rep, nil
+}
+
+func TestSpamCheck(t *testing.T) {
+	msg := types.Message{
+		From:    types.Address{Mail: "app@example.com"},
+		To:      []types.Address{{Mail: "ada@example.org"}},
+		Subject: "Deal",
+		Plain:   []byte("hi"),
+	}
+	spam := &fixedSpam{rep: email.SpamReport{Score: 7.5, Rules: []email.SpamRule{
+		{Name: "FREE_MONEY", Score: 4}, {Name: "URGENT", Score: 3.5},
+	}}}
+
+	srv := newFakeServer(t)
+	var res email.SendResult
+	err := NewSMTP(srv.config()).Send(context.Background(), msg, email.WithResult(&res),
+		email.WithSpamCheck(spam, 5, email.SpamBlock))
+	var se *email.SpamError
+	if !errors.As(err, &se) || se.Report.Score != 7.5 || len(srv.messages()) != 0 ||
+		res.Spam == nil || res.MessageID == "" {
+		t.Fatalf("expected a blocked send, got %v, %+v", err, res)
+	}
+	if !strings.Contains(err.Error(), "7.5 exceeds threshold 5.0 (FREE_MONEY, URGENT)") {
+		t.Fatalf("error: %v", err)
+	}
+
+	err = NewSMTP(srv.config()).Send(context.Background(), msg, email.WithResult(&res),
+		email.WithSpamCheck(spam, 5, email.SpamAnnotate))
+	if err != nil || len(srv.messages()) != 1 || res.Spam.Score != 7.5 {
+		t.Fatalf("annotate: %v, %+v", err, res)
+	}
+	if got := srv.messages()[0]; !strings.HasPrefix(got, "X-Spam-Flag: YES\r\n"+
+		"X-Spam-Status: Yes, score=7.5 required=5.0 tests=FREE_MONEY, URGENT\r\n") {
+		t.Fatalf("annotated message:\n%s", got)
+	}
+
+	spam.rep.Score = 1
+	err = NewSMTP(srv.config()).Send(context.Background(), msg, email.WithResult(&res),
+		email.WithSpamCheck(spam, 5, email.SpamBlock))
+	if err != nil || len(srv.messages()) != 2 || strings.Contains(srv.messages()[1], "X-Spam") {
+		t.Fatalf("clean: %v\n%s", err, srv.messages())
+	}
+
+	// Batches check each message too.
+	spam.rep.Score = 9
+	b, err := NewSMTP(srv.config()).Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Commit()
+	err = b.SendOne(context.Background(), msg, email.WithSpamCheck(spam, 5, email.SpamBlock))
+	if !errors.As(err, &se) || len(srv.messages()) != 2 {
+		t.Fatalf("batch: %v", err)
+	}
+}
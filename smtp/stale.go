@@ -0,0 +1,44 @@
+package smtp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"sync/atomic"
+	"syscall"
+)
+
+// countConn counts the bytes read from a connection.
+type countConn struct {
+	net.Conn
+	n atomic.Int64
+}
+
+func (c *countConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// reads returns the number of bytes read so far.
+func (c *countConn) reads() int64 { return c.n.Load() }
+
+// stale reports whether err shows that the server had dropped the
+// connection before the session, given the bytes read at its start.
+// Either nothing came back at all, or the server announced it was
+// closing (421); in both cases no message was accepted.
+func (sc *smtpConn) stale(err error, readsBefore int64) bool {
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		return tpErr.Code == 421
+	}
+	if sc.nc.reads() != readsBefore {
+		return false
+	}
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,76 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestSendRedialsStalePooledConn(t *testing.T) {
+	srv := newFakeServer(t)
+	m := NewSMTP(srv.config())
+	// No health check, so the dropped connection is handed out.
+	pool := email.NewConnPool(1, time.Minute, nil, nil, nil)
+	var evicted []email.EvictReason
+	var dials int
+	pool.Hooks = &email.PoolHooks{
+		OnDial:  func(time.Duration, error) { dials++ },
+		OnEvict: func(r email.EvictReason) { evicted = append(evicted, r) },
+	}
+	msg := types.Message{
+		From:  types.Address{Mail: "app@example.com"},
+		To:    []types.Address{{Mail: "ada@example.org"}},
+		Plain: []byte("hi"),
+	}
+	if err := m.Send(context.Background(), msg, email.WithPool(pool)); err != nil {
+		t.Fatalf("first send: %v", err)
+	}
+	srv.dropAll()
+
+	var res email.SendResult
+	err := m.Send(context.Background(), msg, email.WithPool(pool),
+		email.WithResult(&res))
+	if err != nil {
+		t.Fatalf("send on stale connection: %v", err)
+	}
+	if res.Attempts != 1 {
+		t.Fatalf("attempts = %d, want 1", res.Attempts)
+	}
+	if n := srv.connections(); n != 2 {
+		t.Fatalf("connections = %d, want 2", n)
+	}
+	if n := len(srv.messages()); n != 2 {
+		t.Fatalf("messages = %d, want 2", n)
+	}
+	// The first connection and the redial.
+	if dials != 2 || len(evicted) != 1 || evicted[0] != email.EvictStale {
+		t.Fatalf("hooks saw %d dials, evictions %v", dials, evicted)
+	}
+}
+
+func TestStale(t *testing.T) {
+	sc := &smtpConn{nc: &countConn{}}
+	cases := []struct {
+		err   error
+		reads int64
+		want  bool
+	}{
+		{fmt.Errorf("smtp MAIL FROM: %w", io.EOF), 0, true},
+		{fmt.Errorf("smtp end data: %w", io.EOF), -1, false},
+		{&textproto.Error{Code: 421, Msg: "idle timeout"}, -1, true},
+		{&textproto.Error{Code: 550, Msg: "no such user"}, 0, false},
+		{errors.New("header injection"), 0, false},
+	}
+	for _, c := range cases {
+		if got := sc.stale(c.err, c.reads); got != c.want {
+			t.Errorf("stale(%v, %d) = %v, want %v", c.err, c.reads, got, c.want)
+		}
+	}
+}
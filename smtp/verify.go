@@ -0,0 +1,221 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aatuh/email/v2"
+)
+
+// VerifyError reports that the server refused a recipient in Verify.
+type VerifyError struct {
+	Address string
+	Code    int    // RCPT reply code, e.g. 550
+	Reply   string // RCPT reply text
+}
+
+// Error implements the error interface.
+//
+// Returns:
+//   - string: The error message.
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("smtp verify %s: %d %s", e.Address, e.Code, e.Reply)
+}
+
+// Temporary reports whether the refusal was a 4xx reply, such as
+// greylisting, so a later probe may succeed.
+//
+// Returns:
+//   - bool: True if the error is temporary.
+func (e *VerifyError) Temporary() bool {
+	return e.Code >= 400 && e.Code < 500
+}
+
+// verifyGateSweep is the number of domain gates from which idle ones
+// are dropped.
+const verifyGateSweep = 256
+
+// verifier holds the Verify cache and the per-domain gates.
+type verifier struct {
+	mu    sync.Mutex
+	cache map[string]verifyEntry
+	gates map[string]*verifyGate
+}
+
+// verifyEntry is a cached definitive answer.
+type verifyEntry struct {
+	err error
+	exp time.Time
+}
+
+// verifyGate serializes probes for one domain. next is written with
+// both verifier.mu and sem held.
+type verifyGate struct {
+	sem  chan struct{}
+	next time.Time // earliest start of the next probe
+	refs int
+}
+
+// Verify checks whether the server accepts addr as a recipient without
+// sending a message: it runs MAIL FROM (VerifyFrom, by default the null
+// sender) and RCPT TO, then RSET, on a connection set up like Send's
+// (pool, TLS, AUTH). Use it at signup time against a server that knows
+// the mailboxes, such as a relay doing recipient verification.
+//
+// Accepted addresses and 5xx refusals are cached for VerifyCacheTTL.
+// Probes for the same domain run one at a time, VerifyInterval apart,
+// so that bursts of signups do not hammer the server.
+//
+// Parameters:
+//   - ctx: The context.
+//   - addr: The address to verify.
+//
+// Returns:
+//   - error: nil if accepted, a *VerifyError if refused, or the error
+//     that prevented the probe.
+func (m *SMTP) Verify(ctx context.Context, addr string) error {
+	at := strings.LastIndexByte(addr, '@')
+	if at <= 0 || at == len(addr)-1 {
+		return fmt.Errorf("smtp verify: invalid address %q", addr)
+	}
+	domain := strings.ToLower(addr[at+1:])
+	key := addr[:at+1] + domain
+	if err, ok := m.verifier.cached(key); ok {
+		return err
+	}
+	if err := m.begin(); err != nil {
+		return err
+	}
+	defer m.inflight.Done()
+
+	release, err := m.verifier.wait(ctx, domain)
+	if err != nil {
+		return fmt.Errorf("smtp verify: %w", err)
+	}
+	defer release(m.cfg.VerifyInterval)
+	// A probe for the same address may have finished while waiting.
+	if err, ok := m.verifier.cached(key); ok {
+		return err
+	}
+
+	var cfg email.SendConfig
+	var verr error
+	err = m.withConn(ctx, &cfg, func(conn *smtpConn) error {
+		c := conn.c
+		if err := c.Mail(m.cfg.VerifyFrom); err != nil {
+			return commandError("MAIL FROM", "", err)
+		}
+		verr = c.Rcpt(addr)
+		if err := c.Reset(); err != nil {
+			return commandError("RSET", "", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var tpErr *textproto.Error
+	if errors.As(verr, &tpErr) {
+		verr = &VerifyError{Address: addr, Code: tpErr.Code, Reply: tpErr.Msg}
+	}
+	if vErr, ok := verr.(*VerifyError); verr == nil || ok && !vErr.Temporary() {
+		m.verifier.store(key, verr, m.cfg.VerifyCacheTTL)
+	}
+	return verr
+}
+
+// cached returns the cached answer for key, if any.
+func (v *verifier) cached(key string) (error, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	e, ok := v.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.exp) {
+		delete(v.cache, key)
+		return nil, false
+	}
+	return e.err, true
+}
+
+// store caches err for key for ttl; ttl <= 0 disables caching.
+func (v *verifier) store(key string, err error, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.cache == nil {
+		v.cache = map[string]verifyEntry{}
+	}
+	now := time.Now()
+	for k, e := range v.cache {
+		if now.After(e.exp) {
+			delete(v.cache, k)
+		}
+	}
+	v.cache[key] = verifyEntry{err: err, exp: now.Add(ttl)}
+}
+
+// wait blocks until a probe for domain may start. The returned release
+// ends the probe and holds off the next one for interval.
+func (v *verifier) wait(
+	ctx context.Context,
+	domain string,
+) (func(interval time.Duration), error) {
+	v.mu.Lock()
+	if v.gates == nil {
+		v.gates = map[string]*verifyGate{}
+	}
+	g := v.gates[domain]
+	if g == nil {
+		if len(v.gates) >= verifyGateSweep {
+			now := time.Now()
+			for d, g := range v.gates {
+				if g.refs == 0 && now.After(g.next) {
+					delete(v.gates, d)
+				}
+			}
+		}
+		g = &verifyGate{sem: make(chan struct{}, 1)}
+		v.gates[domain] = g
+	}
+	g.refs++
+	v.mu.Unlock()
+
+	unref := func() {
+		v.mu.Lock()
+		g.refs--
+		v.mu.Unlock()
+	}
+	select {
+	case g.sem <- struct{}{}:
+	case <-ctx.Done():
+		unref()
+		return nil, ctx.Err()
+	}
+	if d := time.Until(g.next); d > 0 {
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			<-g.sem
+			unref()
+			return nil, ctx.Err()
+		}
+	}
+	return func(interval time.Duration) {
+		v.mu.Lock()
+		g.next = time.Now().Add(interval)
+		g.refs--
+		v.mu.Unlock()
+		<-g.sem
+	}, nil
+}
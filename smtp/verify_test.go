@@ -0,0 +1,87 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.mu.Lock()
+	srv.rcptReply = map[string]string{
+		"bob@example.org":   "550 5.1.1 no such user",
+		"carol@example.org": "450 4.7.1 greylisted",
+	}
+	srv.mu.Unlock()
+	cfg := srv.config()
+	cfg.VerifyCacheTTL = time.Minute
+	m := NewSMTP(cfg)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := m.Verify(ctx, "ada@Example.org"); err != nil {
+			t.Fatalf("ada: %v", err)
+		}
+		var vErr *VerifyError
+		if err := m.Verify(ctx, "bob@example.org"); !errors.As(err, &vErr) ||
+			vErr.Code != 550 || vErr.Temporary() {
+			t.Fatalf("bob: %v", err)
+		}
+		if err := m.Verify(ctx, "carol@example.org"); !errors.As(err, &vErr) ||
+			!vErr.Temporary() {
+			t.Fatalf("carol: %v", err)
+		}
+	}
+	// ada and bob are cached; the temporary refusal is not.
+	if n := srv.connections(); n != 4 {
+		t.Fatalf("connections = %d, want 4", n)
+	}
+	cmds := srv.commands()
+	nullSender := func(c string) bool { return strings.HasPrefix(c, "MAIL FROM:<> ") }
+	if !slices.ContainsFunc(cmds, nullSender) || !slices.Contains(cmds, "RSET") {
+		t.Fatalf("unexpected commands: %v", cmds)
+	}
+	for _, c := range cmds {
+		if strings.HasPrefix(c, "DATA") || strings.HasPrefix(c, "BDAT") {
+			t.Fatalf("message data sent: %v", cmds)
+		}
+	}
+	if err := m.Verify(ctx, "no-domain@"); err == nil {
+		t.Fatal("expected invalid address error")
+	}
+}
+
+func TestVerifyDomainInterval(t *testing.T) {
+	srv := newFakeServer(t)
+	cfg := srv.config()
+	cfg.VerifyInterval = 100 * time.Millisecond
+	m := NewSMTP(cfg)
+	ctx := context.Background()
+
+	start := time.Now()
+	if err := m.Verify(ctx, "a@example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Verify(ctx, "b@example.net"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d >= cfg.VerifyInterval {
+		t.Fatalf("different domains were spaced: %v", d)
+	}
+	if err := m.Verify(ctx, "c@example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < cfg.VerifyInterval {
+		t.Fatalf("same domain probed after %v", d)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := m.Verify(ctx, "d@example.org"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline while waiting, got %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+// Package smtpd is a minimal inbound SMTP server (RFC 5321) for
+// receiving replies and bounces without running a full MTA. It accepts
+// mail for any recipient a callback allows, supports STARTTLS and size
+// limits, and hands each message to a Handler as its envelope and raw
+// bytes, ready for inbound.Parse or inbound.ParseARF. It does not relay
+// or queue mail.
+package smtpd
@@ -0,0 +1,160 @@
+package smtpd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Envelope is the SMTP envelope of a received message.
+type Envelope struct {
+	RemoteAddr net.Addr
+	Helo       string   // name given in HELO or EHLO
+	From       string   // MAIL FROM; empty for the null sender of bounces
+	To         []string // accepted RCPT TO addresses
+	// TLS is the connection state after STARTTLS or on an implicit TLS
+	// listener; nil on a plain connection.
+	TLS *tls.ConnectionState
+}
+
+// Handler receives a message. raw holds the message as sent, with a
+// Received header prepended. Returning nil accepts the message;
+// an *Error chooses the reply, and any other error is answered with a
+// temporary failure so the sender retries.
+type Handler func(ctx context.Context, env *Envelope, raw []byte) error
+
+// Error is an SMTP reply returned by a Handler or recipient check.
+type Error struct {
+	Code    int    // e.g. 550
+	Message string // e.g. "5.1.1 no such user"
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+// Config configures a Server.
+type Config struct {
+	// Addr is the listen address of ListenAndServe; defaults to ":25".
+	Addr string
+	// Hostname is announced in the greeting and Received headers;
+	// defaults to os.Hostname.
+	Hostname string
+	// TLSConfig, if set, enables STARTTLS.
+	TLSConfig *tls.Config
+	// RequireTLS refuses mail until STARTTLS has been negotiated.
+	RequireTLS bool
+	// MaxMessageSize caps a message in bytes and is advertised with
+	// SIZE; defaults to 25 MiB.
+	MaxMessageSize int
+	// MaxRecipients caps RCPT TO per message; defaults to 100.
+	MaxRecipients int
+	// Timeout bounds each command and the whole DATA transfer, however
+	// fast the lines come; defaults to 5 minutes.
+	Timeout time.Duration
+
+	// Handler receives each message; it is required.
+	Handler Handler
+	// CheckRecipient, if set, is asked about each RCPT TO; an error
+	// refuses the recipient, with its reply if it is an *Error or 550
+	// otherwise. Without it every recipient is accepted.
+	CheckRecipient func(ctx context.Context, addr string) error
+}
+
+// Server is an inbound SMTP server.
+type Server struct {
+	cfg Config
+}
+
+// NewServer creates a Server.
+//
+// Parameters:
+//   - cfg: The server config.
+//
+// Returns:
+//   - *Server: The server.
+//   - error: The error if cfg has no Handler.
+func NewServer(cfg Config) (*Server, error) {
+	if cfg.Handler == nil {
+		return nil, errors.New("smtpd: config has no Handler")
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = ":25"
+	}
+	if cfg.Hostname == "" {
+		cfg.Hostname, _ = os.Hostname()
+		if cfg.Hostname == "" {
+			cfg.Hostname = "localhost"
+		}
+	}
+	if cfg.MaxMessageSize <= 0 {
+		cfg.MaxMessageSize = 25 << 20
+	}
+	if cfg.MaxRecipients <= 0 {
+		cfg.MaxRecipients = 100
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Minute
+	}
+	return &Server{cfg: cfg}, nil
+}
+
+// ListenAndServe listens on Config.Addr and serves until ctx ends.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - error: The error if listening fails; nil after ctx ends.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	var lc net.ListenConfig
+	ln, err := lc.Listen(ctx, "tcp", s.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("smtpd listen: %w", err)
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln until ctx ends, then closes ln and
+// the open sessions and waits for them. Wrap ln with tls.NewListener
+// for implicit TLS (port 465).
+//
+// Parameters:
+//   - ctx: The context.
+//   - ln: The listener.
+//
+// Returns:
+//   - error: The error if accepting fails; nil after ctx ends.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { _ = ln.Close() })
+	defer stop()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			_ = ln.Close()
+			return fmt.Errorf("smtpd accept: %w", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			closeConn := context.AfterFunc(ctx, func() { _ = nc.Close() })
+			defer closeConn()
+			s.serveConn(ctx, nc)
+		}()
+	}
+}
@@ -0,0 +1,282 @@
+package smtpd
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2/inbound"
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/smtp"
+	"github.com/aatuh/email/v2/types"
+)
+
+// received collects the messages a test server accepted.
+type received struct {
+	mu   sync.Mutex
+	envs []*Envelope
+	raws [][]byte
+}
+
+func (r *received) handle(ctx context.Context, env *Envelope, raw []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.envs = append(r.envs, env)
+	r.raws = append(r.raws, raw)
+	return nil
+}
+
+func (r *received) last() (*Envelope, []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.envs) == 0 {
+		return nil, nil
+	}
+	return r.envs[len(r.envs)-1], r.raws[len(r.raws)-1]
+}
+
+// startServer serves cfg on a local port until the test ends.
+func startServer(t *testing.T, cfg Config) string {
+	t.Helper()
+	s, err := NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	})
+	return ln.Addr().String()
+}
+
+// testCert returns a self-signed certificate for 127.0.0.1.
+func testCert(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "smtpd test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, leaf
+}
+
+func TestServerReceivesFromSMTPClient(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	defer internal.SetClock(internal.SetClock(func() time.Time { return now }))
+	cert, leaf := testCert(t)
+	var got received
+	addr := startServer(t, Config{
+		Hostname:   "mx.example.org",
+		TLSConfig:  &tls.Config{Certificates: []tls.Certificate{cert}},
+		RequireTLS: true,
+		Handler:    got.handle,
+	})
+	host, port, _ := net.SplitHostPort(addr)
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	portNum, _ := strconv.Atoi(port)
+	client := smtp.NewSMTP(smtp.SMTPConfig{
+		Host: host, Port: portNum, StartTLS: true, Timeout: 5 * time.Second,
+		TLSConfig: &tls.Config{RootCAs: roots},
+	})
+	msg := types.Message{
+		From:    types.Address{Mail: "app@example.com"},
+		To:      []types.Address{{Mail: "bounces+42@example.org"}},
+		Subject: "Re: hello",
+		Plain:   []byte("first line\n.leading dot\n"),
+	}
+	if err := client.Send(context.Background(), msg); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	env, raw := got.last()
+	if env == nil {
+		t.Fatal("no message received")
+	}
+	if env.From != "app@example.com" || len(env.To) != 1 || env.To[0] != "bounces+42@example.org" ||
+		env.TLS == nil || env.Helo == "" {
+		t.Fatalf("unexpected envelope %+v", env)
+	}
+	if !strings.HasPrefix(string(raw), "Received: from ") ||
+		!strings.Contains(string(raw), "by mx.example.org with ESMTPS; "+now.Format(time.RFC1123Z)) {
+		t.Fatalf("missing Received header:\n%s", raw)
+	}
+	parsed, err := inbound.Parse(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if parsed.Subject != "Re: hello" || !strings.Contains(string(parsed.Plain), "\n.leading dot") {
+		t.Fatalf("unexpected message %q %q", parsed.Subject, parsed.Plain)
+	}
+}
+
+func TestServeStopsWithContext(t *testing.T) {
+	s, err := NewServer(Config{Handler: (&received{}).handle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, ln) }()
+
+	// An idle session is closed when the server stops.
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	buf := make([]byte, 64)
+	if _, err := c.Read(buf); err != nil {
+		t.Fatalf("greeting: %v", err)
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("serve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return")
+	}
+	_ = c.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c.Read(buf); err == nil {
+		t.Fatal("expected closed session")
+	}
+
+	if _, err := NewServer(Config{}); err == nil {
+		t.Fatal("expected missing handler error")
+	}
+	if err := (&Error{Code: 550, Message: "5.1.1 no"}).Error(); err != "550 5.1.1 no" {
+		t.Fatalf("unexpected error text %q", err)
+	}
+}
+
+func TestServerRejectsBareLFInData(t *testing.T) {
+	var got received
+	addr := startServer(t, Config{Handler: got.handle})
+	smuggled := "MAIL FROM:<admin@bank.example>\r\nRCPT TO:<b@example.org>\r\nDATA\r\n" +
+		"From: admin@bank.example\r\n\r\nforged\r\n."
+	for _, body := range []string{
+		"hello\n.\n" + smuggled,
+		"hello\r\n.\n" + smuggled,
+		"hello\n.\r\n" + smuggled,
+	} {
+		replies := dialog(t, addr, "EHLO client", "MAIL FROM:<a@example.com>",
+			"RCPT TO:<b@example.org>", "DATA", body, "NOOP")
+		if !strings.HasPrefix(replies[4], "554 5.6.0") || !strings.HasPrefix(replies[5], "250") {
+			t.Fatalf("body %q: unexpected replies %q", body, replies)
+		}
+	}
+	if env, _ := got.last(); env != nil {
+		t.Fatalf("accepted smuggled message %+v", env)
+	}
+
+	// An over-long line keeps its line ending for the terminator check.
+	addr = startServer(t, Config{MaxMessageSize: 16, Handler: got.handle})
+	replies := dialog(t, addr, "EHLO client", "MAIL FROM:<a@example.com>",
+		"RCPT TO:<b@example.org>", "DATA", strings.Repeat("x", 64)+"\n.\n"+smuggled, "NOOP")
+	if !strings.HasPrefix(replies[4], "554") || !strings.HasPrefix(replies[5], "250") {
+		t.Fatalf("unexpected replies %q", replies)
+	}
+}
+
+func TestServerBoundsDataTransfer(t *testing.T) {
+	var got received
+	addr := startServer(t, Config{Timeout: 200 * time.Millisecond, Handler: got.handle})
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(c)
+	for _, cmd := range []string{"", "HELO client", "MAIL FROM:<a@example.com>", "RCPT TO:<b@example.org>", "DATA"} {
+		if cmd != "" {
+			io.WriteString(c, cmd+"\r\n")
+		}
+		if _, err := r.ReadString('\n'); err != nil {
+			t.Fatalf("%q: %v", cmd, err)
+		}
+	}
+	// A line every 50ms would renew a per-line deadline forever.
+	go func() {
+		for {
+			time.Sleep(50 * time.Millisecond)
+			if _, err := io.WriteString(c, "x\r\n"); err != nil {
+				return
+			}
+		}
+	}()
+	start := time.Now()
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Fatal("expected the server to close the connection")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("DATA stayed open for %v", d)
+	}
+}
+
+func TestServerValidatesHelo(t *testing.T) {
+	var got received
+	addr := startServer(t, Config{Handler: got.handle})
+	for _, helo := range []string{"evil\rX-Injected: yes", "two words", "bad\x00host", "[192.0.2.1", "[]"} {
+		if r := dialog(t, addr, "EHLO "+helo); !strings.HasPrefix(r[0], "501") {
+			t.Fatalf("EHLO %q: got %q", helo, r[0])
+		}
+	}
+	replies := dialog(t, addr, "HELO [IPv6:2001:db8::1]", "MAIL FROM:<a@example.com>",
+		"RCPT TO:<b@example.org>", "DATA", "x\r\n.")
+	if !strings.HasPrefix(replies[4], "250") {
+		t.Fatalf("unexpected replies %q", replies)
+	}
+	_, raw := got.last()
+	if !strings.HasPrefix(string(raw), "Received: from [IPv6:2001:db8::1] (") {
+		t.Fatalf("unexpected trace header %q", raw)
+	}
+	if !validHelo("mail.bücher.example") || !validHelo("client_1.example") {
+		t.Fatal("expected valid HELO names")
+	}
+}
@@ -0,0 +1,374 @@
+package smtpd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aatuh/email/v2/internal"
+)
+
+// maxCommandLine caps a command line (RFC 5321 4.5.3.1.4 allows 512;
+// extensions make it longer in practice).
+const maxCommandLine = 4096
+
+// errLineTooLong is returned by readLine for an over-long line.
+var errLineTooLong = errors.New("line too long")
+
+// session is one SMTP connection.
+type session struct {
+	s   *Server
+	nc  net.Conn
+	r   *bufio.Reader
+	env *Envelope // nil until MAIL FROM
+	tls *tls.ConnectionState
+
+	helo     string
+	extended bool // EHLO rather than HELO
+}
+
+// serveConn runs one SMTP session until QUIT or an I/O error.
+func (s *Server) serveConn(ctx context.Context, nc net.Conn) {
+	defer nc.Close()
+	ss := &session{s: s, nc: nc, r: bufio.NewReader(nc)}
+	if tc, ok := nc.(*tls.Conn); ok {
+		_ = nc.SetDeadline(time.Now().Add(s.cfg.Timeout))
+		if err := tc.HandshakeContext(ctx); err != nil {
+			return
+		}
+		st := tc.ConnectionState()
+		ss.tls = &st
+	}
+	if !ss.reply(220, s.cfg.Hostname+" ESMTP ready") {
+		return
+	}
+	for {
+		_ = nc.SetDeadline(time.Now().Add(s.cfg.Timeout))
+		line, err := ss.readLine(maxCommandLine)
+		if errors.Is(err, errLineTooLong) {
+			if !ss.reply(500, "5.5.2 line too long") {
+				return
+			}
+			continue
+		}
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		if !ss.command(ctx, strings.ToUpper(verb), strings.TrimSpace(arg)) {
+			return
+		}
+	}
+}
+
+// command handles one command and reports whether the session goes on.
+func (ss *session) command(ctx context.Context, verb, arg string) bool {
+	cfg := &ss.s.cfg
+	switch verb {
+	case "HELO", "EHLO":
+		if arg == "" {
+			return ss.reply(501, "5.5.4 "+verb+" requires a domain")
+		}
+		if !validHelo(arg) {
+			return ss.reply(501, "5.5.4 invalid domain or address literal")
+		}
+		ss.helo, ss.extended, ss.env = arg, verb == "EHLO", nil
+		if !ss.extended {
+			return ss.reply(250, cfg.Hostname)
+		}
+		lines := []string{
+			cfg.Hostname, "PIPELINING", "8BITMIME", "SMTPUTF8",
+			"ENHANCEDSTATUSCODES", "SIZE " + strconv.Itoa(cfg.MaxMessageSize),
+		}
+		if cfg.TLSConfig != nil && ss.tls == nil {
+			lines = append(lines, "STARTTLS")
+		}
+		return ss.replyLines(250, lines)
+	case "STARTTLS":
+		if cfg.TLSConfig == nil || ss.tls != nil {
+			return ss.reply(502, "5.5.1 STARTTLS not available")
+		}
+		if !ss.reply(220, "2.0.0 ready to start TLS") {
+			return false
+		}
+		tc := tls.Server(ss.nc, cfg.TLSConfig)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			return false
+		}
+		st := tc.ConnectionState()
+		// RFC 3207 4.2: forget everything learned before TLS.
+		ss.nc, ss.r, ss.tls = tc, bufio.NewReader(tc), &st
+		ss.helo, ss.env = "", nil
+		return true
+	case "MAIL":
+		return ss.mail(arg)
+	case "RCPT":
+		return ss.rcpt(ctx, arg)
+	case "DATA":
+		return ss.data(ctx)
+	case "RSET":
+		ss.env = nil
+		return ss.reply(250, "2.0.0 OK")
+	case "NOOP":
+		return ss.reply(250, "2.0.0 OK")
+	case "VRFY":
+		return ss.reply(252, "2.1.5 cannot verify, send some mail")
+	case "QUIT":
+		ss.reply(221, "2.0.0 bye")
+		return false
+	default:
+		return ss.reply(500, "5.5.2 command not recognized")
+	}
+}
+
+// mail handles MAIL FROM.
+func (ss *session) mail(arg string) bool {
+	cfg := &ss.s.cfg
+	switch {
+	case ss.helo == "":
+		return ss.reply(503, "5.5.1 send HELO or EHLO first")
+	case cfg.RequireTLS && ss.tls == nil:
+		return ss.reply(530, "5.7.0 must issue a STARTTLS command first")
+	case ss.env != nil:
+		return ss.reply(503, "5.5.1 nested MAIL command")
+	}
+	addr, params, ok := parsePath(arg, "FROM:")
+	if !ok {
+		return ss.reply(501, "5.5.4 syntax: MAIL FROM:<address>")
+	}
+	for _, p := range params {
+		k, v, _ := strings.Cut(p, "=")
+		if strings.EqualFold(k, "SIZE") {
+			if n, err := strconv.Atoi(v); err == nil && n > cfg.MaxMessageSize {
+				return ss.reply(552, "5.3.4 message too big")
+			}
+		}
+	}
+	ss.env = &Envelope{
+		RemoteAddr: ss.nc.RemoteAddr(),
+		Helo:       ss.helo,
+		From:       addr,
+		TLS:        ss.tls,
+	}
+	return ss.reply(250, "2.1.0 OK")
+}
+
+// rcpt handles RCPT TO.
+func (ss *session) rcpt(ctx context.Context, arg string) bool {
+	cfg := &ss.s.cfg
+	if ss.env == nil {
+		return ss.reply(503, "5.5.1 send MAIL first")
+	}
+	addr, _, ok := parsePath(arg, "TO:")
+	if !ok || addr == "" || !strings.Contains(addr, "@") && !strings.EqualFold(addr, "postmaster") {
+		return ss.reply(501, "5.5.4 syntax: RCPT TO:<address>")
+	}
+	if len(ss.env.To) >= cfg.MaxRecipients {
+		return ss.reply(452, "4.5.3 too many recipients")
+	}
+	if cfg.CheckRecipient != nil {
+		if err := cfg.CheckRecipient(ctx, addr); err != nil {
+			var se *Error
+			if errors.As(err, &se) {
+				return ss.reply(se.Code, se.Message)
+			}
+			return ss.reply(550, "5.1.1 recipient rejected")
+		}
+	}
+	ss.env.To = append(ss.env.To, addr)
+	return ss.reply(250, "2.1.5 OK")
+}
+
+// data handles DATA: it reads the message and calls the handler.
+func (ss *session) data(ctx context.Context) bool {
+	cfg := &ss.s.cfg
+	if ss.env == nil || len(ss.env.To) == 0 {
+		return ss.reply(503, "5.5.1 send RCPT first")
+	}
+	if !ss.reply(354, "end data with <CR><LF>.<CR><LF>") {
+		return false
+	}
+	env := ss.env
+	ss.env = nil
+	// One deadline covers the whole transfer, so a client trickling
+	// lines cannot hold the session open.
+	_ = ss.nc.SetDeadline(time.Now().Add(cfg.Timeout))
+	var b bytes.Buffer
+	b.WriteString(ss.received(env))
+	header := b.Len()
+	// Only CRLF.CRLF ends the data: a bare LF anywhere would let a
+	// client smuggle commands past relays that end it differently, so
+	// the data is read to its end and rejected.
+	tooBig, bareLF, afterCRLF := false, false, true
+	for {
+		line, err := ss.readLine(cfg.MaxMessageSize)
+		if errors.Is(err, errLineTooLong) {
+			tooBig = true
+			if afterCRLF = line == "\r\n"; !afterCRLF {
+				bareLF = true
+			}
+			continue
+		}
+		if err != nil {
+			return false
+		}
+		if line == ".\r\n" && afterCRLF {
+			break
+		}
+		afterCRLF = strings.HasSuffix(line, "\r\n")
+		if !afterCRLF {
+			bareLF = true
+		}
+		if bareLF {
+			continue
+		}
+		if strings.HasPrefix(line, ".") {
+			line = line[1:]
+		}
+		if tooBig || b.Len()-header+len(line) > cfg.MaxMessageSize {
+			tooBig = true
+			continue
+		}
+		b.WriteString(line)
+	}
+	if bareLF {
+		return ss.reply(554, "5.6.0 bare LF in message data")
+	}
+	if tooBig {
+		return ss.reply(552, "5.3.4 message too big")
+	}
+	if err := cfg.Handler(ctx, env, b.Bytes()); err != nil {
+		var se *Error
+		if errors.As(err, &se) {
+			return ss.reply(se.Code, se.Message)
+		}
+		return ss.reply(451, "4.3.0 temporary failure, try again later")
+	}
+	return ss.reply(250, "2.0.0 OK")
+}
+
+// received returns the Received trace header (RFC 5321 4.4).
+func (ss *session) received(env *Envelope) string {
+	with := "SMTP"
+	if ss.extended {
+		with = "ESMTP"
+	}
+	if env.TLS != nil {
+		with += "S"
+	}
+	remote := ""
+	if addr, ok := env.RemoteAddr.(*net.TCPAddr); ok {
+		remote = " ([" + addr.IP.String() + "])"
+	}
+	return fmt.Sprintf("Received: from %s%s\r\n\tby %s with %s; %s\r\n",
+		env.Helo, remote, ss.s.cfg.Hostname, with,
+		internal.Now().Format(time.RFC1123Z))
+}
+
+// readLine reads one line with its line ending; the caller sets the
+// deadline. Lines longer than limit are consumed and reported as errLineTooLong,
+// with only their line ending returned.
+func (ss *session) readLine(limit int) (string, error) {
+	var b []byte
+	var tail []byte // the last two bytes read
+	for {
+		chunk, err := ss.r.ReadSlice('\n')
+		if len(b) <= limit {
+			b = append(b, chunk...)
+		}
+		tail = append(tail, chunk...)
+		if len(tail) > 2 {
+			tail = append(tail[:0], tail[len(tail)-2:]...)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			if err == io.EOF && len(b) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		if len(b) > limit {
+			if len(tail) == 2 && tail[0] == '\r' {
+				return "\r\n", errLineTooLong
+			}
+			return "\n", errLineTooLong
+		}
+		return string(b), nil
+	}
+}
+
+// reply writes a single-line reply and reports whether it was sent.
+func (ss *session) reply(code int, text string) bool {
+	return ss.replyLines(code, []string{text})
+}
+
+// replyLines writes a multi-line reply.
+func (ss *session) replyLines(code int, lines []string) bool {
+	var b strings.Builder
+	for i, l := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+		fmt.Fprintf(&b, "%d%s%s\r\n", code, sep, l)
+	}
+	_ = ss.nc.SetDeadline(time.Now().Add(ss.s.cfg.Timeout))
+	_, err := io.WriteString(ss.nc, b.String())
+	return err == nil
+}
+
+// validHelo reports whether arg of HELO or EHLO is a domain or an
+// address literal, so that it is safe to quote in the Received header.
+// Bytes above ASCII are allowed for internationalized domains.
+func validHelo(arg string) bool {
+	if lit, ok := strings.CutPrefix(arg, "["); ok {
+		lit, ok = strings.CutSuffix(lit, "]")
+		return ok && lit != "" && !strings.ContainsFunc(lit, func(r rune) bool {
+			return r <= ' ' || r == 0x7f || r == '[' || r == ']' || r == '\\'
+		})
+	}
+	for i := 0; i < len(arg); i++ {
+		c := arg[i]
+		switch {
+		case c >= 0x80, c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z',
+			c >= '0' && c <= '9', c == '-', c == '.', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parsePath parses "FROM:<addr> params" or "TO:<addr> params". Source
+// routes are dropped; the null path "<>" gives an empty address.
+func parsePath(arg, prefix string) (string, []string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", nil, false
+	}
+	rest := strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(rest, "<") {
+		return "", nil, false
+	}
+	end := strings.IndexByte(rest, '>')
+	if end < 0 {
+		return "", nil, false
+	}
+	addr := rest[1:end]
+	if strings.HasPrefix(addr, "@") {
+		_, addr, _ = strings.Cut(addr, ":")
+	}
+	if strings.ContainsAny(addr, " \t<>") {
+		return "", nil, false
+	}
+	return addr, strings.Fields(rest[end+1:]), true
+}
@@ -0,0 +1,155 @@
+package smtpd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dialog sends each command on a new session and returns the first
+// reply line to each, after the greeting.
+func dialog(t *testing.T, addr string, cmds ...string) []string {
+	t.Helper()
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(c)
+	readReply := func() string {
+		var first string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if first == "" {
+				first = strings.TrimRight(line, "\r\n")
+			}
+			if len(line) < 4 || line[3] != '-' {
+				return first
+			}
+		}
+	}
+	readReply()
+	var out []string
+	for _, cmd := range cmds {
+		if _, err := c.Write([]byte(cmd + "\r\n")); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, readReply())
+	}
+	return out
+}
+
+func TestSessionReplies(t *testing.T) {
+	var got received
+	addr := startServer(t, Config{
+		Hostname:       "mx.example.org",
+		MaxMessageSize: 64,
+		MaxRecipients:  2,
+		Handler: func(ctx context.Context, env *Envelope, raw []byte) error {
+			switch env.To[0] {
+			case "full@example.org":
+				return &Error{Code: 552, Message: "5.2.2 mailbox full"}
+			case "flaky@example.org":
+				return errors.New("database down")
+			}
+			return got.handle(ctx, env, raw)
+		},
+		CheckRecipient: func(ctx context.Context, addr string) error {
+			if addr == "nobody@example.org" {
+				return errors.New("unknown")
+			}
+			if addr == "closed@example.org" {
+				return &Error{Code: 550, Message: "5.1.1 mailbox closed"}
+			}
+			return nil
+		},
+	})
+
+	tests := []struct {
+		name string
+		cmds []string
+		want []string
+	}{
+		{"bounce with null sender", []string{
+			"EHLO client.example.com", "MAIL FROM:<>", "RCPT TO:<bounces@example.org>",
+			"DATA", "Subject: bounce\r\n\r\n..dot\r\n.",
+		}, []string{"250", "250", "250", "354", "250 2.0.0"}},
+		{"order and syntax", []string{
+			"MAIL FROM:<a@example.com>", "HELO client", "RCPT TO:<b@example.org>",
+			"MAIL FROM:a@example.com", "MAIL FROM:<a@example.com>",
+			"MAIL FROM:<a@example.com>", "DATA", "RCPT TO:<not an address>", "BOGUS",
+		}, []string{"503", "250", "503", "501", "250", "503", "503", "501", "500"}},
+		{"recipient checks and limits", []string{
+			"HELO client", "MAIL FROM:<a@example.com>", "RCPT TO:<nobody@example.org>",
+			"RCPT TO:<closed@example.org>", "RCPT TO:<Postmaster>",
+			"RCPT TO:<@relay.example:b@example.org>", "RCPT TO:<c@example.org>",
+		}, []string{"250", "250", "550 5.1.1 recipient rejected", "550 5.1.1 mailbox closed",
+			"250", "250", "452"}},
+		{"size limits", []string{
+			"EHLO client", "MAIL FROM:<a@example.com> SIZE=1000", "MAIL FROM:<a@example.com> SIZE=10",
+			"RCPT TO:<b@example.org>", "DATA", strings.Repeat("x", 100) + "\r\n.", "NOOP",
+		}, []string{"250", "552", "250", "250", "354", "552", "250"}},
+		{"handler errors", []string{
+			"HELO client", "MAIL FROM:<a@example.com>", "RCPT TO:<full@example.org>",
+			"DATA", "x\r\n.", "MAIL FROM:<a@example.com>", "RCPT TO:<flaky@example.org>",
+			"DATA", "x\r\n.", "RSET", "VRFY b", "QUIT",
+		}, []string{"250", "250", "250", "354", "552 5.2.2 mailbox full", "250", "250",
+			"354", "451", "250", "252", "221"}},
+		{"long line", []string{
+			"NOOP " + strings.Repeat("x", maxCommandLine), "NOOP",
+		}, []string{"500 5.5.2 line too long", "250"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replies := dialog(t, addr, tt.cmds...)
+			for i, want := range tt.want {
+				if !strings.HasPrefix(replies[i], want) {
+					t.Fatalf("reply to %q = %q, want %q", tt.cmds[i], replies[i], want)
+				}
+			}
+		})
+	}
+	env, raw := got.last()
+	if env == nil || env.From != "" || env.TLS != nil ||
+		!strings.HasSuffix(string(raw), "\r\nSubject: bounce\r\n\r\n.dot\r\n") ||
+		!strings.Contains(string(raw), "with ESMTP;") {
+		t.Fatalf("unexpected bounce %+v %q", env, raw)
+	}
+}
+
+func TestSessionRequireTLS(t *testing.T) {
+	addr := startServer(t, Config{RequireTLS: true, Handler: (&received{}).handle})
+	replies := dialog(t, addr, "EHLO client", "MAIL FROM:<a@example.com>", "STARTTLS")
+	if !strings.HasPrefix(replies[1], "530") || !strings.HasPrefix(replies[2], "502") {
+		t.Fatalf("unexpected replies %q", replies)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	for _, tt := range []struct {
+		arg, prefix, addr string
+		params            int
+		ok                bool
+	}{
+		{"FROM:<a@example.com>", "FROM:", "a@example.com", 0, true},
+		{"from: <a@example.com> SIZE=10 BODY=8BITMIME", "FROM:", "a@example.com", 2, true},
+		{"FROM:<>", "FROM:", "", 0, true},
+		{"TO:<@a.example,@b.example:c@example.org>", "TO:", "c@example.org", 0, true},
+		{"TO:c@example.org", "TO:", "", 0, false},
+		{"TO:<c@example.org", "TO:", "", 0, false},
+		{"FROM:<a@example.com>", "TO:", "", 0, false},
+	} {
+		addr, params, ok := parsePath(tt.arg, tt.prefix)
+		if addr != tt.addr || len(params) != tt.params || ok != tt.ok {
+			t.Fatalf("parsePath(%q) = %q %q %v", tt.arg, addr, params, ok)
+		}
+	}
+}
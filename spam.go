@@ -0,0 +1,133 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aatuh/email/v2/internal"
+)
+
+// SpamChecker scores a built message with a spam filter. The spamcheck
+// package provides clients for SpamAssassin's spamd and for rspamd.
+type SpamChecker interface {
+	// CheckSpam scores raw, the message as it will be sent.
+	CheckSpam(ctx context.Context, raw []byte) (SpamReport, error)
+}
+
+// SpamReport is the verdict of a SpamChecker.
+type SpamReport struct {
+	Score float64
+	Rules []SpamRule // the rules that matched
+}
+
+// SpamRule is a spam filter rule that matched a message.
+type SpamRule struct {
+	Name        string
+	Score       float64
+	Description string
+}
+
+// SpamAction tells what WithSpamCheck does with a message scoring
+// above the threshold.
+type SpamAction int
+
+const (
+	// SpamBlock fails the send with a *SpamError.
+	SpamBlock SpamAction = iota
+	// SpamAnnotate sends the message with X-Spam-Flag and
+	// X-Spam-Status headers prepended, e.g. for a review mailbox to
+	// filter on.
+	SpamAnnotate
+)
+
+// SpamCheck configures WithSpamCheck.
+type SpamCheck struct {
+	Checker   SpamChecker
+	Threshold float64 // scores above it exceed the threshold
+	Action    SpamAction
+}
+
+// SpamError is returned for a message blocked by WithSpamCheck.
+type SpamError struct {
+	Report    SpamReport
+	Threshold float64
+}
+
+// Error implements error.
+//
+// Returns:
+//   - string: The error message.
+func (e *SpamError) Error() string {
+	return fmt.Sprintf("email: spam score %s exceeds threshold %s (%s)",
+		formatScore(e.Report.Score), formatScore(e.Threshold),
+		strings.Join(e.Report.ruleNames(), ", "))
+}
+
+// WithSpamCheck scores the built message with c before it is sent. A
+// score above threshold blocks or annotates the message according to
+// action. The report is available in SendResult.Spam; an error from c
+// fails the send. The SMTP and sendmail adapters support it; others
+// ignore it.
+//
+// Parameters:
+//   - c: The spam checker.
+//   - threshold: The highest acceptable score.
+//   - action: What to do with a message above threshold.
+//
+// Returns:
+//   - Option: The option.
+func WithSpamCheck(c SpamChecker, threshold float64, action SpamAction) Option {
+	return func(cfg *SendConfig) {
+		cfg.SpamCheck = &SpamCheck{Checker: c, Threshold: threshold, Action: action}
+	}
+}
+
+// Apply scores raw and returns the message to send: raw, or raw with
+// X-Spam headers for an annotated message above the threshold.
+//
+// Parameters:
+//   - ctx: The context.
+//   - raw: The built message.
+//
+// Returns:
+//   - []byte: The message to send.
+//   - *SpamReport: The report, nil if the checker failed.
+//   - error: A *SpamError for a blocked message, or the checker error.
+func (s SpamCheck) Apply(ctx context.Context, raw []byte) ([]byte, *SpamReport, error) {
+	rep, err := s.Checker.CheckSpam(ctx, raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("email: spam check: %w", err)
+	}
+	if rep.Score <= s.Threshold {
+		return raw, &rep, nil
+	}
+	if s.Action == SpamBlock {
+		return nil, &rep, &SpamError{Report: rep, Threshold: s.Threshold}
+	}
+	status := "Yes, score=" + formatScore(rep.Score) +
+		" required=" + formatScore(s.Threshold)
+	if names := rep.ruleNames(); len(names) > 0 {
+		status += " tests=" + strings.Join(names, ", ")
+	}
+	prefix := internal.FoldHeader("X-Spam-Flag", "YES") +
+		internal.FoldHeader("X-Spam-Status", status)
+	out := make([]byte, 0, len(prefix)+len(raw))
+	return append(append(out, prefix...), raw...), &rep, nil
+}
+
+// ruleNames returns the names of the matched rules.
+func (r SpamReport) ruleNames() []string {
+	names := make([]string, len(r.Rules))
+	for i, rule := range r.Rules {
+		names[i] = rule.Name
+	}
+	return names
+}
+
+// formatScore formats a spam score with one decimal, as spam filters
+// do.
+func formatScore(f float64) string {
+	return strconv.FormatFloat(f, 'f', 1, 64)
+}
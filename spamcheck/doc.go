@@ -0,0 +1,6 @@
+// Package spamcheck scores outgoing messages with a spam filter, for
+// email.WithSpamCheck. Spamd talks the spamc protocol to
+// SpamAssassin's spamd over TCP; Rspamd posts the message to rspamd's
+// HTTP check endpoint. Both report the score and the rules that
+// matched.
+package spamcheck
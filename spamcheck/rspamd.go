@@ -0,0 +1,112 @@
+package spamcheck
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/aatuh/email/v2"
+)
+
+// RspamdConfig configures an rspamd client.
+type RspamdConfig struct {
+	// URL is the normal worker or controller, defaults to
+	// "http://localhost:11333".
+	URL      string
+	Password string // controller password (optional)
+	Client   *http.Client
+}
+
+// Rspamd scores messages with rspamd's /checkv2 endpoint. It
+// implements email.SpamChecker and is safe for concurrent use.
+type Rspamd struct {
+	cfg RspamdConfig
+}
+
+// NewRspamd creates an rspamd client.
+//
+// Parameters:
+//   - cfg: The rspamd config.
+//
+// Returns:
+//   - *Rspamd: The client.
+func NewRspamd(cfg RspamdConfig) *Rspamd {
+	if cfg.URL == "" {
+		cfg.URL = "http://localhost:11333"
+	}
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &Rspamd{cfg: cfg}
+}
+
+// rspamdReply is the part of a /checkv2 reply the client reads.
+type rspamdReply struct {
+	Score   float64 `json:"score"`
+	Symbols map[string]struct {
+		Name        string  `json:"name"`
+		Score       float64 `json:"score"`
+		Description string  `json:"description"`
+	} `json:"symbols"`
+}
+
+// CheckSpam implements email.SpamChecker. Rules are sorted by
+// descending score.
+//
+// Parameters:
+//   - ctx: The context.
+//   - raw: The message.
+//
+// Returns:
+//   - email.SpamReport: The score and matched rules.
+//   - error: The error if rspamd cannot be reached or fails.
+func (s *Rspamd) CheckSpam(ctx context.Context, raw []byte) (email.SpamReport, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		s.cfg.URL+"/checkv2", bytes.NewReader(raw))
+	if err != nil {
+		return email.SpamReport{}, fmt.Errorf("spamcheck: %w", err)
+	}
+	if s.cfg.Password != "" {
+		req.Header.Set("Password", s.cfg.Password)
+	}
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return email.SpamReport{}, fmt.Errorf("spamcheck: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return email.SpamReport{}, fmt.Errorf("spamcheck: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return email.SpamReport{}, fmt.Errorf("spamcheck: rspamd: %s: %s",
+			resp.Status, strings.TrimSpace(string(body)))
+	}
+	var reply rspamdReply
+	if err := json.Unmarshal(body, &reply); err != nil {
+		return email.SpamReport{}, fmt.Errorf("spamcheck: rspamd reply: %w", err)
+	}
+	rep := email.SpamReport{Score: reply.Score}
+	for key, sym := range reply.Symbols {
+		if sym.Name == "" {
+			sym.Name = key
+		}
+		rep.Rules = append(rep.Rules, email.SpamRule{
+			Name: sym.Name, Score: sym.Score, Description: sym.Description,
+		})
+	}
+	slices.SortFunc(rep.Rules, func(a, b email.SpamRule) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return rep, nil
+}
@@ -0,0 +1,131 @@
+package spamcheck
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const spamdReport = `Spam detection software, running on the system "mx.example.com",
+has identified this incoming email as possible spam.
+
+Content analysis details:   (7.5 points, 5.0 required)
+
+ pts rule name              description
+---- ---------------------- --------------------------------------------------
+ 4.0 FREE_MONEY             BODY: Lots of money is available for
+                            free
+-0.5 DKIM_VALID             Message has at least one valid DKIM or DK
+ 4.0 URGENT                 Urgent request
+`
+
+// serveSpamd answers one spamc request with reply and returns the
+// request it read.
+func serveSpamd(t *testing.T, reply string) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	got := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var head strings.Builder
+		n := 0
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			head.WriteString(line)
+			if v, ok := strings.CutPrefix(line, "Content-length: "); ok {
+				n, _ = strconv.Atoi(strings.TrimSpace(v))
+			}
+			if line == "\r\n" {
+				break
+			}
+		}
+		body := make([]byte, n)
+		_, _ = io.ReadFull(r, body)
+		got <- head.String() + string(body)
+		_, _ = io.WriteString(conn, reply)
+	}()
+	return ln.Addr().String(), got
+}
+
+func TestSpamd(t *testing.T) {
+	addr, got := serveSpamd(t, "SPAMD/1.1 0 EX_OK\r\nContent-length: 999\r\n"+
+		"Spam: True ; 7.5 / 5.0\r\n\r\n"+spamdReport)
+	rep, err := NewSpamd(SpamdConfig{Addr: addr, User: "app"}).
+		CheckSpam(context.Background(), []byte("Subject: hi\r\n\r\nbody"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req := <-got; req != "REPORT SPAMC/1.5\r\nContent-length: 19\r\nUser: app\r\n\r\n"+
+		"Subject: hi\r\n\r\nbody" {
+		t.Fatalf("request: %q", req)
+	}
+	if rep.Score != 7.5 || len(rep.Rules) != 3 {
+		t.Fatalf("report: %+v", rep)
+	}
+	if r := rep.Rules[0]; r.Name != "FREE_MONEY" || r.Score != 4 ||
+		r.Description != "BODY: Lots of money is available for free" {
+		t.Fatalf("rule: %+v", r)
+	}
+	if r := rep.Rules[1]; r.Name != "DKIM_VALID" || r.Score != -0.5 {
+		t.Fatalf("rule: %+v", r)
+	}
+
+	addr, _ = serveSpamd(t, "SPAMD/1.0 76 Bad header line\r\n")
+	if _, err := NewSpamd(SpamdConfig{Addr: addr}).CheckSpam(context.Background(),
+		[]byte("x")); err == nil || !strings.Contains(err.Error(), "76 Bad header line") {
+		t.Fatalf("expected a spamd error, got %v", err)
+	}
+}
+
+func TestRspamd(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path != "/checkv2" || string(body) != "raw" || r.Header.Get("Password") != "pw" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"score": 6.2, "required_score": 15, "action": "add header",
+			"symbols": map[string]any{
+				"R_SPF_FAIL": map[string]any{"name": "R_SPF_FAIL", "score": 1.2,
+					"description": "SPF verification failed"},
+				"BAYES_SPAM": map[string]any{"name": "BAYES_SPAM", "score": 5},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	rep, err := NewRspamd(RspamdConfig{URL: srv.URL + "/", Password: "pw"}).
+		CheckSpam(context.Background(), []byte("raw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Score != 6.2 || len(rep.Rules) != 2 || rep.Rules[0].Name != "BAYES_SPAM" ||
+		rep.Rules[1].Description != "SPF verification failed" {
+		t.Fatalf("report: %+v", rep)
+	}
+
+	_, err = NewRspamd(RspamdConfig{URL: srv.URL}).CheckSpam(context.Background(), []byte("raw"))
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("expected an HTTP error, got %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package spamcheck
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aatuh/email/v2"
+	"github.com/aatuh/email/v2/types"
+)
+
+// SpamdConfig configures a SpamAssassin spamd client.
+type SpamdConfig struct {
+	Addr    string // host:port, defaults to "localhost:783"
+	User    string // user whose preferences spamd applies (optional)
+	Timeout time.Duration
+	// Dialer, if set, opens the connection, e.g. through a proxy.
+	Dialer types.ContextDialer
+}
+
+// Spamd scores messages with spamd's REPORT command. It implements
+// email.SpamChecker and is safe for concurrent use.
+type Spamd struct {
+	cfg SpamdConfig
+}
+
+// NewSpamd creates a spamd client.
+//
+// Parameters:
+//   - cfg: The spamd config.
+//
+// Returns:
+//   - *Spamd: The client.
+func NewSpamd(cfg SpamdConfig) *Spamd {
+	if cfg.Addr == "" {
+		cfg.Addr = "localhost:783"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.Dialer == nil {
+		cfg.Dialer = &net.Dialer{}
+	}
+	return &Spamd{cfg: cfg}
+}
+
+// CheckSpam implements email.SpamChecker.
+//
+// Parameters:
+//   - ctx: The context.
+//   - raw: The message.
+//
+// Returns:
+//   - email.SpamReport: The score and matched rules.
+//   - error: The error if spamd cannot be reached or fails.
+func (s *Spamd) CheckSpam(ctx context.Context, raw []byte) (email.SpamReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	conn, err := s.cfg.Dialer.DialContext(ctx, "tcp", s.cfg.Addr)
+	if err != nil {
+		return email.SpamReport{}, fmt.Errorf("spamcheck: %w", err)
+	}
+	defer conn.Close()
+	if d, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(d)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	req := fmt.Sprintf("REPORT SPAMC/1.5\r\nContent-length: %d\r\n", len(raw))
+	if s.cfg.User != "" {
+		req += "User: " + s.cfg.User + "\r\n"
+	}
+	if _, err := io.WriteString(conn, req+"\r\n"); err != nil {
+		return email.SpamReport{}, fmt.Errorf("spamcheck: %w", err)
+	}
+	if _, err := conn.Write(raw); err != nil {
+		return email.SpamReport{}, fmt.Errorf("spamcheck: %w", err)
+	}
+	rep, err := readSpamd(bufio.NewReader(conn))
+	if err != nil && ctx.Err() != nil {
+		return email.SpamReport{}, ctx.Err()
+	}
+	return rep, err
+}
+
+// readSpamd reads a spamd reply to REPORT.
+func readSpamd(r *bufio.Reader) (email.SpamReport, error) {
+	var rep email.SpamReport
+	status, err := r.ReadString('\n')
+	if err != nil {
+		return rep, fmt.Errorf("spamcheck: read reply: %w", err)
+	}
+	// SPAMD/1.1 0 EX_OK
+	f := strings.Fields(status)
+	if len(f) < 2 || !strings.HasPrefix(f[0], "SPAMD/") {
+		return rep, fmt.Errorf("spamcheck: unexpected reply %q", strings.TrimSpace(status))
+	}
+	if f[1] != "0" {
+		return rep, fmt.Errorf("spamcheck: spamd: %s", strings.Join(f[1:], " "))
+	}
+	scored := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return rep, fmt.Errorf("spamcheck: read reply: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		// Spam: True ; 15.0 / 5.0
+		name, v, _ := strings.Cut(line, ":")
+		if !strings.EqualFold(name, "Spam") {
+			continue
+		}
+		_, v, _ = strings.Cut(v, ";")
+		score, _, _ := strings.Cut(v, "/")
+		if rep.Score, err = strconv.ParseFloat(strings.TrimSpace(score), 64); err != nil {
+			return rep, fmt.Errorf("spamcheck: invalid Spam header %q", line)
+		}
+		scored = true
+	}
+	if !scored {
+		return rep, fmt.Errorf("spamcheck: reply without Spam header")
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return rep, fmt.Errorf("spamcheck: read reply: %w", err)
+	}
+	rep.Rules = parseReport(string(body))
+	return rep, nil
+}
+
+// reportRule matches a rule line of a SpamAssassin report:
+// " 1.2 RULE_NAME              Description".
+var reportRule = regexp.MustCompile(`^\s*(-?\d+(?:\.\d+)?)\s+([A-Z0-9_]+)\s*(.*)$`)
+
+// parseReport extracts the rules from the table of a SpamAssassin
+// report, which follows a "---- ----" separator line. Indented lines
+// continue the description of the rule above.
+func parseReport(report string) []email.SpamRule {
+	var rules []email.SpamRule
+	table := false
+	for _, line := range strings.Split(report, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !table {
+			table = strings.HasPrefix(line, "----")
+			continue
+		}
+		if m := reportRule.FindStringSubmatch(line); m != nil {
+			score, _ := strconv.ParseFloat(m[1], 64)
+			rules = append(rules, email.SpamRule{
+				Name: m[2], Score: score, Description: strings.TrimSpace(m[3]),
+			})
+			continue
+		}
+		if cont := strings.TrimSpace(line); cont != "" && len(rules) > 0 &&
+			strings.HasPrefix(line, " ") {
+			r := &rules[len(rules)-1]
+			r.Description = strings.TrimSpace(r.Description + " " + cont)
+		}
+	}
+	return rules
+}
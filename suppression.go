@@ -0,0 +1,130 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// ErrSuppressed is returned (wrapped) by the checker of
+// SuppressionCheck for a suppressed recipient.
+var ErrSuppressed = errors.New("email: recipient is suppressed")
+
+// Suppression reasons.
+const (
+	SuppressComplaint   = "complaint"   // marked as spam (feedback loop)
+	SuppressBounce      = "bounce"      // hard bounce
+	SuppressUnsubscribe = "unsubscribe" // opted out
+)
+
+// SuppressionList records addresses that must not be mailed again.
+// Addresses compare case-insensitively, with internationalized domains
+// in ASCII form (see types.Address.Normalize). Implementations must be safe
+// for concurrent use.
+type SuppressionList interface {
+	// Suppress adds addr with reason, e.g. SuppressComplaint.
+	Suppress(ctx context.Context, addr, reason string) error
+	// Suppressed reports whether addr is suppressed, and why.
+	Suppressed(ctx context.Context, addr string) (reason string, ok bool, err error)
+}
+
+// SuppressionCheck returns an AddressChecker for WithAddressCheck that
+// fails sends to addresses on l with ErrSuppressed.
+//
+// Parameters:
+//   - l: The suppression list.
+//
+// Returns:
+//   - AddressChecker: The checker.
+func SuppressionCheck(l SuppressionList) AddressChecker {
+	return suppressionCheck{l}
+}
+
+// suppressionCheck adapts a SuppressionList to AddressChecker.
+type suppressionCheck struct {
+	l SuppressionList
+}
+
+// CheckAddress implements AddressChecker.
+func (c suppressionCheck) CheckAddress(ctx context.Context, addr string) error {
+	reason, ok, err := c.l.Suppressed(ctx, addr)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return fmt.Errorf("%s: %w (%s)", addr, ErrSuppressed, reason)
+	}
+	return nil
+}
+
+// MemorySuppressionList is an in-memory SuppressionList.
+type MemorySuppressionList struct {
+	mu      sync.Mutex
+	entries map[string]string // normalized address -> reason
+}
+
+// NewMemorySuppressionList creates an empty suppression list.
+//
+// Returns:
+//   - *MemorySuppressionList: The list.
+func NewMemorySuppressionList() *MemorySuppressionList {
+	return &MemorySuppressionList{entries: map[string]string{}}
+}
+
+// Suppress adds addr with reason, replacing an earlier reason.
+//
+// Parameters:
+//   - ctx: The context.
+//   - addr: The address.
+//   - reason: The reason.
+//
+// Returns:
+//   - error: Always nil.
+func (l *MemorySuppressionList) Suppress(ctx context.Context, addr, reason string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries[normalizeAddr(addr)] = reason
+	return nil
+}
+
+// Suppressed reports whether addr is suppressed, and why.
+//
+// Parameters:
+//   - ctx: The context.
+//   - addr: The address.
+//
+// Returns:
+//   - string: The reason.
+//   - bool: True if addr is suppressed.
+//   - error: Always nil.
+func (l *MemorySuppressionList) Suppressed(ctx context.Context, addr string) (string, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	reason, ok := l.entries[normalizeAddr(addr)]
+	return reason, ok, nil
+}
+
+// Remove takes addr off the list, e.g. after the recipient opted in
+// again.
+//
+// Parameters:
+//   - addr: The address.
+func (l *MemorySuppressionList) Remove(addr string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, normalizeAddr(addr))
+}
+
+// normalizeAddr lowercases addr, converts its domain to ASCII and
+// strips spaces and angle brackets.
+func normalizeAddr(addr string) string {
+	a, err := types.Address{Mail: addr}.Normalize(types.NormalizeOptions{FoldLocal: true})
+	if err != nil {
+		return strings.ToLower(strings.Trim(addr, " \t<>"))
+	}
+	return a.Mail
+}
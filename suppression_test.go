@@ -0,0 +1,32 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMemorySuppressionList(t *testing.T) {
+	ctx := context.Background()
+	l := NewMemorySuppressionList()
+	l.Suppress(ctx, "<Bob@Example.com>", SuppressBounce)
+	if reason, ok, _ := l.Suppressed(ctx, "bob@example.com"); !ok || reason != SuppressBounce {
+		t.Fatalf("suppressed = %q, %v", reason, ok)
+	}
+	check := SuppressionCheck(l)
+	err := check.CheckAddress(ctx, "BOB@example.com")
+	if !errors.Is(err, ErrSuppressed) || err.Error() != "BOB@example.com: email: recipient is suppressed (bounce)" {
+		t.Fatalf("check = %v", err)
+	}
+	if err := check.CheckAddress(ctx, "ada@example.com"); err != nil {
+		t.Fatalf("check ada = %v", err)
+	}
+	l.Suppress(ctx, "ada@Bücher.example", SuppressComplaint)
+	if _, ok, _ := l.Suppressed(ctx, "ADA@xn--bcher-kva.example"); !ok {
+		t.Fatal("IDN forms of one address should match")
+	}
+	l.Remove("bob@example.com")
+	if _, ok, _ := l.Suppressed(ctx, "bob@example.com"); ok {
+		t.Fatal("still suppressed after Remove")
+	}
+}
@@ -0,0 +1,168 @@
+package email
+
+import (
+	"encoding/base32"
+	"errors"
+	"strings"
+)
+
+// maxLocalPart is the longest local part RFC 5321 4.5.3.1.1 allows.
+const maxLocalPart = 64
+
+// tagEncoding encodes tags that are not plain; lowercase so that the
+// result survives servers that fold the case of local parts.
+var tagEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// EncodeTag encodes an arbitrary ID for use in the local part of an
+// address. IDs made of lowercase letters, digits and '-' are kept as
+// they are; others are base32-encoded behind a '_' marker. The result
+// is case-insensitive and contains neither '+' nor '='.
+//
+// Parameters:
+//   - id: The ID.
+//
+// Returns:
+//   - string: The encoded tag.
+func EncodeTag(id string) string {
+	if isPlainTag(id) {
+		return id
+	}
+	return "_" + tagEncoding.EncodeToString([]byte(id))
+}
+
+// DecodeTag reverses EncodeTag, ignoring case.
+//
+// Parameters:
+//   - tag: The encoded tag.
+//
+// Returns:
+//   - string: The ID.
+//   - error: An error if tag is not a valid encoding.
+func DecodeTag(tag string) (string, error) {
+	tag = strings.ToLower(tag)
+	enc, ok := strings.CutPrefix(tag, "_")
+	if !ok {
+		if !isPlainTag(tag) {
+			return "", errors.New("tag: invalid characters")
+		}
+		return tag, nil
+	}
+	id, err := tagEncoding.DecodeString(enc)
+	if err != nil {
+		return "", errors.New("tag: malformed encoding")
+	}
+	return string(id), nil
+}
+
+// TagAddress returns addr with id as its plus tag: for addr
+// "replies@example.com" and id "ticket 42" it returns
+// "replies+_oruwg23foqqdimq@example.com". An existing tag is replaced.
+//
+// Parameters:
+//   - addr: The base address.
+//   - id: The ID to carry, encoded with EncodeTag.
+//
+// Returns:
+//   - string: The tagged address.
+//   - error: An error if addr lacks a domain or the local part gets
+//     longer than 64 octets.
+func TagAddress(addr, id string) (string, error) {
+	local, domain, ok := splitAddr(addr)
+	if !ok {
+		return "", errors.New("tag: invalid address")
+	}
+	local, _, _ = strings.Cut(local, "+")
+	return joinTagged(local+"+"+EncodeTag(id), domain)
+}
+
+// ParseTagAddress splits a tagged address made by TagAddress.
+//
+// Parameters:
+//   - addr: The address a reply was delivered to.
+//
+// Returns:
+//   - string: The base address, without the tag.
+//   - string: The decoded ID.
+//   - error: An error if addr has no valid tag.
+func ParseTagAddress(addr string) (string, string, error) {
+	local, domain, ok := splitAddr(addr)
+	if !ok {
+		return "", "", errors.New("tag: invalid address")
+	}
+	base, tag, ok := strings.Cut(local, "+")
+	if !ok || tag == "" {
+		return "", "", errors.New("tag: no tag")
+	}
+	id, err := DecodeTag(tag)
+	if err != nil {
+		return "", "", err
+	}
+	return base + "@" + domain, id, nil
+}
+
+// BounceAddress returns a VERP-style bounce address carrying id, e.g.
+// "bounce-<tag>@example.com" for bounce "bounce@example.com". Unlike
+// VERP it does not rely on plus addressing; route the "bounce-" prefix
+// to the bounce mailbox instead.
+//
+// Parameters:
+//   - bounce: The bounce mailbox.
+//   - id: The ID to carry, encoded with EncodeTag.
+//
+// Returns:
+//   - string: The envelope sender.
+//   - error: An error if bounce lacks a domain or the local part gets
+//     longer than 64 octets.
+func BounceAddress(bounce, id string) (string, error) {
+	local, domain, ok := splitAddr(bounce)
+	if !ok {
+		return "", errors.New("tag: invalid bounce address")
+	}
+	return joinTagged(local+"-"+EncodeTag(id), domain)
+}
+
+// ParseBounceAddress extracts the ID from an address made by
+// BounceAddress for the same bounce mailbox.
+//
+// Parameters:
+//   - addr: The address the bounce was delivered to.
+//   - bounce: The bounce mailbox passed to BounceAddress.
+//
+// Returns:
+//   - string: The decoded ID.
+//   - error: An error if addr does not belong to bounce.
+func ParseBounceAddress(addr, bounce string) (string, error) {
+	local, domain, ok := splitAddr(addr)
+	bl, bd, bok := splitAddr(bounce)
+	if !ok || !bok {
+		return "", errors.New("tag: invalid address")
+	}
+	tag, ok := strings.CutPrefix(strings.ToLower(local), strings.ToLower(bl)+"-")
+	if !ok || tag == "" || !strings.EqualFold(domain, bd) {
+		return "", errors.New("tag: not a bounce address of " + bounce)
+	}
+	return DecodeTag(tag)
+}
+
+// joinTagged joins a tagged local part and domain, enforcing the
+// local part limit.
+func joinTagged(local, domain string) (string, error) {
+	if len(local) > maxLocalPart {
+		return "", errors.New("tag: local part longer than 64 octets")
+	}
+	return local + "@" + domain, nil
+}
+
+// isPlainTag reports whether id needs no encoding.
+func isPlainTag(id string) bool {
+	if id == "" {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
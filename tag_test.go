@@ -0,0 +1,67 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncodeTagRoundTrip(t *testing.T) {
+	for _, id := range []string{"42", "order-7", "Ticket 42", "a+b=c", "ünïcode", "_x", ""} {
+		tag := EncodeTag(id)
+		if strings.ContainsAny(tag, "+=@ ") || tag != strings.ToLower(tag) {
+			t.Fatalf("EncodeTag(%q) = %q is not local-part safe", id, tag)
+		}
+		got, err := DecodeTag(strings.ToUpper(tag))
+		if err != nil || got != id {
+			t.Fatalf("DecodeTag(%q) = %q, %v; want %q", tag, got, err, id)
+		}
+	}
+	if EncodeTag("order-7") != "order-7" {
+		t.Fatalf("plain IDs should stay readable")
+	}
+	for _, bad := range []string{"a.b", "_1"} {
+		if _, err := DecodeTag(bad); err == nil {
+			t.Fatalf("expected error decoding %q", bad)
+		}
+	}
+}
+
+func TestTagAddress(t *testing.T) {
+	addr, err := TagAddress("replies+old@example.com", "ticket 42")
+	if err != nil || addr != "replies+_oruwg23foqqdimq@example.com" {
+		t.Fatalf("tag: %q %v", addr, err)
+	}
+	base, id, err := ParseTagAddress(strings.ToUpper(addr))
+	if err != nil || base != "REPLIES@EXAMPLE.COM" || id != "ticket 42" {
+		t.Fatalf("parse: %q %q %v", base, id, err)
+	}
+	if _, err := TagAddress("replies", "x"); err == nil {
+		t.Fatalf("expected error for address without domain")
+	}
+	if _, err := TagAddress("replies@example.com", strings.Repeat("x", 60)); err == nil {
+		t.Fatalf("expected local part length error")
+	}
+	if _, _, err := ParseTagAddress("replies@example.com"); err == nil {
+		t.Fatalf("expected error for untagged address")
+	}
+}
+
+func TestBounceAddress(t *testing.T) {
+	addr, err := BounceAddress("bounce@example.com", "msg/7")
+	if err != nil || !strings.HasPrefix(addr, "bounce-_") || !strings.HasSuffix(addr, "@example.com") {
+		t.Fatalf("bounce: %q %v", addr, err)
+	}
+	id, err := ParseBounceAddress(addr, "Bounce@Example.com")
+	if err != nil || id != "msg/7" {
+		t.Fatalf("parse: %q %v", id, err)
+	}
+	plain, _ := BounceAddress("bounce@example.com", "7")
+	if plain != "bounce-7@example.com" {
+		t.Fatalf("unexpected plain bounce address %q", plain)
+	}
+	for _, addr := range []string{"bounce@example.com", "bounce-7@example.org", "other-7@example.com"} {
+		if _, err := ParseBounceAddress(addr, "bounce@example.com"); err == nil {
+			t.Fatalf("expected error for %q", addr)
+		}
+	}
+}
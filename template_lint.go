@@ -0,0 +1,160 @@
+package email
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template/parse"
+)
+
+// Template lint finding codes.
+const (
+	LintTemplateHTMLInText = "template-html-in-text"
+	LintTemplatePrintf     = "template-printf-data"
+)
+
+// TemplateLint selects what LoadTemplatesWithOptions does with template
+// lint findings.
+type TemplateLint int
+
+// Template lint modes.
+const (
+	// TemplateLintWarn records findings in TemplateSet.Findings.
+	TemplateLintWarn TemplateLint = iota
+	// TemplateLintFail fails loading with a *TemplateLintError.
+	TemplateLintFail
+	// TemplateLintOff skips the checks.
+	TemplateLintOff
+)
+
+// LoadOptions configures LoadTemplatesWithOptions.
+type LoadOptions struct {
+	// Lint handles findings of the template checks: HTML tags in a
+	// .txt.tmpl, which text/template does not escape, and printf with
+	// %s or %v of template data in a .html.tmpl, which formats data
+	// into markup instead of letting html/template escape it in place.
+	Lint TemplateLint
+	// Locale is the render locale of Render and RenderMessage, and of
+	// RenderLocale when given an empty or unknown locale; "" means
+	// English.
+	Locale string
+}
+
+// TemplateLintError is returned by LoadTemplatesWithOptions with
+// TemplateLintFail when a template has lint findings.
+type TemplateLintError struct {
+	Findings []Finding
+}
+
+// Error implements error.
+func (e *TemplateLintError) Error() string {
+	msgs := make([]string, len(e.Findings))
+	for i, f := range e.Findings {
+		msgs[i] = f.String()
+	}
+	return "template lint: " + strings.Join(msgs, "; ")
+}
+
+// htmlInTextRe matches common HTML tags in template text.
+var htmlInTextRe = regexp.MustCompile(`(?i)</?(a|b|i|p|br|hr|div|span|img|table|tr|td|th|strong|em|ul|ol|li|h[1-6]|html|head|body|style|script)\b[^<>]*>`)
+
+// lintTextTree reports HTML tags in the text of a text template.
+func lintTextTree(tree *parse.Tree) []Finding {
+	var out []Finding
+	walkTemplate(tree.Root, func(n parse.Node) {
+		text, ok := n.(*parse.TextNode)
+		if !ok {
+			return
+		}
+		if tag := htmlInTextRe.Find(text.Text); tag != nil {
+			loc, _ := tree.ErrorContext(n)
+			out = append(out, Finding{
+				Code:     LintTemplateHTMLInText,
+				Severity: SeverityWarning,
+				Message: fmt.Sprintf("%s: text template contains HTML %q, "+
+					"which is not escaped; use a .html.tmpl", loc, tag),
+			})
+		}
+	})
+	return out
+}
+
+// lintHTMLTree reports printf calls formatting template data with %s or
+// %v in an HTML template.
+func lintHTMLTree(tree *parse.Tree) []Finding {
+	var out []Finding
+	walkTemplate(tree.Root, func(n parse.Node) {
+		cmd, ok := n.(*parse.CommandNode)
+		if !ok || len(cmd.Args) < 3 {
+			return
+		}
+		if id, ok := cmd.Args[0].(*parse.IdentifierNode); !ok || id.Ident != "printf" {
+			return
+		}
+		format, ok := cmd.Args[1].(*parse.StringNode)
+		if !ok || !strings.Contains(format.Text, "%s") && !strings.Contains(format.Text, "%v") {
+			return
+		}
+		for _, arg := range cmd.Args[2:] {
+			switch arg.(type) {
+			case *parse.FieldNode, *parse.VariableNode, *parse.ChainNode,
+				*parse.DotNode, *parse.PipeNode:
+				loc, _ := tree.ErrorContext(n)
+				out = append(out, Finding{
+					Code:     LintTemplatePrintf,
+					Severity: SeverityWarning,
+					Message: fmt.Sprintf("%s: printf %q formats template data "+
+						"into markup; output the value directly", loc, format.Text),
+				})
+				return
+			}
+		}
+	})
+	return out
+}
+
+// walkTemplate calls fn for n and every node below it.
+func walkTemplate(n parse.Node, fn func(parse.Node)) {
+	if n == nil {
+		return
+	}
+	fn(n)
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			walkTemplate(c, fn)
+		}
+	case *parse.ActionNode:
+		walkTemplate(n.Pipe, fn)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			walkTemplate(c, fn)
+		}
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			walkTemplate(a, fn)
+		}
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.TemplateNode:
+		walkTemplate(n.Pipe, fn)
+	}
+}
+
+func walkBranch(b *parse.BranchNode, fn func(parse.Node)) {
+	walkTemplate(b.Pipe, fn)
+	walkTemplate(b.List, fn)
+	if b.ElseList != nil {
+		walkTemplate(b.ElseList, fn)
+	}
+}
@@ -0,0 +1,47 @@
+package email
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTemplateLint(t *testing.T) {
+	mfs := fstest.MapFS{
+		"welcome.txt.tmpl": {Data: []byte("Hi {{.Name}},\n<p>Welcome</p>\nFrom <team@example.com>")},
+		"welcome.html.tmpl": {Data: []byte(`{{if .Name}}<p>{{printf "<b>%s</b>" .Name}}</p>{{end}}` +
+			`<p>{{printf "%d items" .Count}}</p>`)},
+		"clean.txt.tmpl":  {Data: []byte("Hi {{.Name}} <ada@example.com>")},
+		"clean.html.tmpl": {Data: []byte(`<p>{{.Name}}</p>`)},
+	}
+	ts, err := LoadTemplates(mfs)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	fs := ts.Findings()
+	if len(fs) != 2 {
+		t.Fatalf("findings = %v", fs)
+	}
+	codes := map[string]string{}
+	for _, f := range fs {
+		codes[f.Code] = f.Message
+	}
+	if m := codes[LintTemplateHTMLInText]; !strings.HasPrefix(m, "welcome.txt.tmpl:1:") ||
+		!strings.Contains(m, "<p>") {
+		t.Fatalf("html in text finding: %q", m)
+	}
+	if m := codes[LintTemplatePrintf]; !strings.HasPrefix(m, "welcome.html.tmpl:1:") {
+		t.Fatalf("printf finding: %q", m)
+	}
+
+	_, err = LoadTemplatesWithOptions(mfs, LoadOptions{Lint: TemplateLintFail})
+	var lErr *TemplateLintError
+	if !errors.As(err, &lErr) || len(lErr.Findings) != 2 {
+		t.Fatalf("expected *TemplateLintError, got %v", err)
+	}
+	ts, err = LoadTemplatesWithOptions(mfs, LoadOptions{Lint: TemplateLintOff})
+	if err != nil || len(ts.Findings()) != 0 {
+		t.Fatalf("lint off: %v %v", err, ts.Findings())
+	}
+}
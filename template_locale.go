@@ -0,0 +1,519 @@
+package email
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aatuh/email/v2/internal"
+)
+
+// Locale holds the formatting rules the date, number, currency and
+// relativeTime template functions use; see TemplateSet.RenderLocale.
+// Fields left empty fall back to English.
+type Locale struct {
+	// Tag is the BCP 47 tag the locale is selected by, e.g. "de" or
+	// "en-GB".
+	Tag string
+
+	// DecimalSep and GroupSep separate the fraction and the groups of
+	// three digits of numbers, e.g. "," and "." in German.
+	DecimalSep string
+	GroupSep   string
+	// CurrencyPattern places the amount (#) and the currency symbol
+	// (¤), e.g. "¤#" or "#\u00a0¤".
+	CurrencyPattern string
+	// CurrencySymbols maps ISO 4217 codes to symbols, e.g. "USD" to
+	// "US$", overriding the shared symbols. A code without a symbol is
+	// shown as the code.
+	CurrencySymbols map[string]string
+
+	// DateFormats maps date styles to time.Format layouts, in which
+	// January, Jan, Monday and Mon stand for the names below. The date
+	// function accepts the styles "short", "medium", "long", "full" and
+	// "time".
+	DateFormats map[string]string
+	Months      [12]string // January first, in the form used in dates
+	ShortMonths [12]string
+	Days        [7]string // Sunday first
+	ShortDays   [7]string
+
+	// RelativePast and RelativeFuture wrap a duration, e.g. "%s ago"
+	// and "in %s"; RelativeNow is used below a minute.
+	RelativePast   string
+	RelativeFuture string
+	RelativeNow    string
+	// RelativeUnits holds the singular and plural of "minute", "hour",
+	// "day", "week", "month" and "year" with a %d verb, e.g.
+	// {"%d day", "%d days"}. FutureUnits, if set, replaces them in
+	// future times, for languages that inflect them differently.
+	RelativeUnits map[string][2]string
+	FutureUnits   map[string][2]string
+}
+
+// localeEN is the English locale and the fallback of every other.
+var localeEN = Locale{
+	Tag:             "en",
+	DecimalSep:      ".",
+	GroupSep:        ",",
+	CurrencyPattern: "¤#",
+	DateFormats: map[string]string{
+		"short": "1/2/06", "medium": "Jan 2, 2006", "long": "January 2, 2006",
+		"full": "Monday, January 2, 2006", "time": "3:04 PM",
+	},
+	Months: [12]string{"January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December"},
+	ShortMonths: [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun",
+		"Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+	Days: [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday",
+		"Friday", "Saturday"},
+	ShortDays:      [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+	RelativePast:   "%s ago",
+	RelativeFuture: "in %s",
+	RelativeNow:    "just now",
+	RelativeUnits: map[string][2]string{
+		"minute": {"%d minute", "%d minutes"}, "hour": {"%d hour", "%d hours"},
+		"day": {"%d day", "%d days"}, "week": {"%d week", "%d weeks"},
+		"month": {"%d month", "%d months"}, "year": {"%d year", "%d years"},
+	},
+}
+
+// builtinLocales are the locales known without WithLocales.
+var builtinLocales = []Locale{
+	localeEN,
+	{
+		Tag:             "en-GB",
+		CurrencySymbols: map[string]string{"USD": "US$"},
+		DateFormats: map[string]string{
+			"short": "02/01/2006", "medium": "2 Jan 2006", "long": "2 January 2006",
+			"full": "Monday, 2 January 2006", "time": "15:04",
+		},
+	},
+	{
+		Tag:             "de",
+		DecimalSep:      ",",
+		GroupSep:        ".",
+		CurrencyPattern: "#\u00a0¤",
+		DateFormats: map[string]string{
+			"short": "02.01.06", "medium": "02.01.2006", "long": "2. January 2006",
+			"full": "Monday, 2. January 2006", "time": "15:04",
+		},
+		Months: [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni",
+			"Juli", "August", "September", "Oktober", "November", "Dezember"},
+		ShortMonths: [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni",
+			"Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		Days: [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch",
+			"Donnerstag", "Freitag", "Samstag"},
+		ShortDays:      [7]string{"So.", "Mo.", "Di.", "Mi.", "Do.", "Fr.", "Sa."},
+		RelativePast:   "vor %s",
+		RelativeFuture: "in %s",
+		RelativeNow:    "gerade eben",
+		RelativeUnits: map[string][2]string{
+			"minute": {"%d Minute", "%d Minuten"}, "hour": {"%d Stunde", "%d Stunden"},
+			"day": {"%d Tag", "%d Tagen"}, "week": {"%d Woche", "%d Wochen"},
+			"month": {"%d Monat", "%d Monaten"}, "year": {"%d Jahr", "%d Jahren"},
+		},
+	},
+	{
+		Tag:             "fr",
+		DecimalSep:      ",",
+		GroupSep:        "\u202f",
+		CurrencyPattern: "#\u00a0¤",
+		DateFormats: map[string]string{
+			"short": "02/01/2006", "medium": "2 Jan 2006", "long": "2 January 2006",
+			"full": "Monday 2 January 2006", "time": "15:04",
+		},
+		Months: [12]string{"janvier", "février", "mars", "avril", "mai", "juin",
+			"juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		ShortMonths: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin",
+			"juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		Days: [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi",
+			"vendredi", "samedi"},
+		ShortDays:      [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+		RelativePast:   "il y a %s",
+		RelativeFuture: "dans %s",
+		RelativeNow:    "à l’instant",
+		RelativeUnits: map[string][2]string{
+			"minute": {"%d minute", "%d minutes"}, "hour": {"%d heure", "%d heures"},
+			"day": {"%d jour", "%d jours"}, "week": {"%d semaine", "%d semaines"},
+			"month": {"%d mois", "%d mois"}, "year": {"%d an", "%d ans"},
+		},
+	},
+	{
+		Tag:             "es",
+		DecimalSep:      ",",
+		GroupSep:        ".",
+		CurrencyPattern: "#\u00a0¤",
+		DateFormats: map[string]string{
+			"short": "2/1/06", "medium": "2 Jan 2006", "long": "2 de January de 2006",
+			"full": "Monday, 2 de January de 2006", "time": "15:04",
+		},
+		Months: [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio",
+			"julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		ShortMonths: [12]string{"ene", "feb", "mar", "abr", "may", "jun",
+			"jul", "ago", "sept", "oct", "nov", "dic"},
+		Days: [7]string{"domingo", "lunes", "martes", "miércoles", "jueves",
+			"viernes", "sábado"},
+		ShortDays:      [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+		RelativePast:   "hace %s",
+		RelativeFuture: "dentro de %s",
+		RelativeNow:    "ahora mismo",
+		RelativeUnits: map[string][2]string{
+			"minute": {"%d minuto", "%d minutos"}, "hour": {"%d hora", "%d horas"},
+			"day": {"%d día", "%d días"}, "week": {"%d semana", "%d semanas"},
+			"month": {"%d mes", "%d meses"}, "year": {"%d año", "%d años"},
+		},
+	},
+	{
+		Tag:             "fi",
+		DecimalSep:      ",",
+		GroupSep:        "\u00a0",
+		CurrencyPattern: "#\u00a0¤",
+		DateFormats: map[string]string{
+			"short": "2.1.2006", "medium": "2.1.2006", "long": "2. January 2006",
+			"full": "Monday 2. January 2006", "time": "15.04",
+		},
+		Months: [12]string{"tammikuuta", "helmikuuta", "maaliskuuta",
+			"huhtikuuta", "toukokuuta", "kesäkuuta", "heinäkuuta", "elokuuta",
+			"syyskuuta", "lokakuuta", "marraskuuta", "joulukuuta"},
+		ShortMonths: [12]string{"tammik.", "helmik.", "maalisk.", "huhtik.",
+			"toukok.", "kesäk.", "heinäk.", "elok.", "syysk.", "lokak.",
+			"marrask.", "jouluk."},
+		Days: [7]string{"sunnuntai", "maanantai", "tiistai", "keskiviikko",
+			"torstai", "perjantai", "lauantai"},
+		ShortDays:      [7]string{"su", "ma", "ti", "ke", "to", "pe", "la"},
+		RelativePast:   "%s sitten",
+		RelativeFuture: "%s päästä",
+		RelativeNow:    "juuri nyt",
+		RelativeUnits: map[string][2]string{
+			"minute": {"%d minuutti", "%d minuuttia"}, "hour": {"%d tunti", "%d tuntia"},
+			"day": {"%d päivä", "%d päivää"}, "week": {"%d viikko", "%d viikkoa"},
+			"month": {"%d kuukausi", "%d kuukautta"}, "year": {"%d vuosi", "%d vuotta"},
+		},
+		FutureUnits: map[string][2]string{
+			"minute": {"%d minuutin", "%d minuutin"}, "hour": {"%d tunnin", "%d tunnin"},
+			"day": {"%d päivän", "%d päivän"}, "week": {"%d viikon", "%d viikon"},
+			"month": {"%d kuukauden", "%d kuukauden"}, "year": {"%d vuoden", "%d vuoden"},
+		},
+	},
+	{
+		Tag:             "sv",
+		DecimalSep:      ",",
+		GroupSep:        "\u00a0",
+		CurrencyPattern: "#\u00a0¤",
+		DateFormats: map[string]string{
+			"short": "2006-01-02", "medium": "2 Jan 2006", "long": "2 January 2006",
+			"full": "Monday 2 January 2006", "time": "15:04",
+		},
+		Months: [12]string{"januari", "februari", "mars", "april", "maj", "juni",
+			"juli", "augusti", "september", "oktober", "november", "december"},
+		ShortMonths: [12]string{"jan.", "feb.", "mars", "apr.", "maj", "juni",
+			"juli", "aug.", "sep.", "okt.", "nov.", "dec."},
+		Days: [7]string{"söndag", "måndag", "tisdag", "onsdag", "torsdag",
+			"fredag", "lördag"},
+		ShortDays:      [7]string{"sön", "mån", "tis", "ons", "tors", "fre", "lör"},
+		RelativePast:   "för %s sedan",
+		RelativeFuture: "om %s",
+		RelativeNow:    "just nu",
+		RelativeUnits: map[string][2]string{
+			"minute": {"%d minut", "%d minuter"}, "hour": {"%d timme", "%d timmar"},
+			"day": {"%d dag", "%d dagar"}, "week": {"%d vecka", "%d veckor"},
+			"month": {"%d månad", "%d månader"}, "year": {"%d år", "%d år"},
+		},
+	},
+}
+
+// currencySymbols are the symbols shared by all locales.
+var currencySymbols = map[string]string{
+	"EUR": "€", "USD": "$", "GBP": "£", "JPY": "¥", "CNY": "¥", "INR": "₹",
+	"SEK": "kr", "NOK": "kr", "DKK": "kr", "CHF": "CHF",
+}
+
+// currencyDigits lists the currencies without two minor digits.
+var currencyDigits = map[string]int{
+	"JPY": 0, "KRW": 0, "ISK": 0, "CLP": 0, "VND": 0, "HUF": 0,
+	"BHD": 3, "KWD": 3, "OMR": 3, "TND": 3,
+}
+
+// LookupLocale returns the built-in locale for tag: English ("en"),
+// British English ("en-GB"), German, French, Spanish, Finnish or
+// Swedish. A tag with a region falls back to its language, so "de-AT"
+// returns German.
+//
+// Parameters:
+//   - tag: The BCP 47 tag, e.g. "fr-CA".
+//
+// Returns:
+//   - Locale: The locale.
+//   - bool: False if there is none for tag.
+func LookupLocale(tag string) (Locale, bool) {
+	return findLocale(builtinLocales, tag)
+}
+
+// findLocale returns the locale of ls matching tag exactly or by
+// language, case-insensitively and with "_" taken as "-".
+func findLocale(ls []Locale, tag string) (Locale, bool) {
+	tag = normalizeLocaleTag(tag)
+	lang, _, _ := strings.Cut(tag, "-")
+	for _, want := range []string{tag, lang} {
+		for _, l := range ls {
+			if normalizeLocaleTag(l.Tag) == want {
+				return l, true
+			}
+		}
+	}
+	return Locale{}, false
+}
+
+// normalizeLocaleTag lowercases tag and replaces "_" with "-".
+func normalizeLocaleTag(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
+
+// withDefaults returns l with its empty fields taken from English.
+func (l Locale) withDefaults() Locale {
+	def := localeEN
+	pick := func(s *string, d string) {
+		if *s == "" {
+			*s = d
+		}
+	}
+	pick(&l.DecimalSep, def.DecimalSep)
+	pick(&l.GroupSep, def.GroupSep)
+	pick(&l.CurrencyPattern, def.CurrencyPattern)
+	pick(&l.RelativePast, def.RelativePast)
+	pick(&l.RelativeFuture, def.RelativeFuture)
+	pick(&l.RelativeNow, def.RelativeNow)
+	if l.Months == ([12]string{}) {
+		l.Months = def.Months
+	}
+	if l.ShortMonths == ([12]string{}) {
+		l.ShortMonths = def.ShortMonths
+	}
+	if l.Days == ([7]string{}) {
+		l.Days = def.Days
+	}
+	if l.ShortDays == ([7]string{}) {
+		l.ShortDays = def.ShortDays
+	}
+	if l.DateFormats == nil {
+		l.DateFormats = def.DateFormats
+	}
+	if l.RelativeUnits == nil {
+		l.RelativeUnits = def.RelativeUnits
+	}
+	return l
+}
+
+// localeFuncs returns the template functions formatting for l.
+func localeFuncs(l Locale) map[string]any {
+	l = l.withDefaults()
+	return map[string]any{
+		"date":         l.formatDate,
+		"number":       l.formatNumberArgs,
+		"currency":     l.formatCurrency,
+		"relativeTime": l.formatRelative,
+	}
+}
+
+// formatDate implements the date template function: it formats t in
+// the named style, or with style as a layout if it names none.
+func (l Locale) formatDate(style string, t time.Time) string {
+	layout, ok := l.DateFormats[style]
+	if !ok {
+		if layout, ok = localeEN.DateFormats[style]; !ok {
+			layout = style
+		}
+	}
+	var b strings.Builder
+	for layout != "" {
+		i, name := nextNameToken(layout)
+		if i < 0 {
+			b.WriteString(t.Format(layout))
+			break
+		}
+		b.WriteString(t.Format(layout[:i]))
+		switch name {
+		case "January":
+			b.WriteString(l.Months[t.Month()-1])
+		case "Jan":
+			b.WriteString(l.ShortMonths[t.Month()-1])
+		case "Monday":
+			b.WriteString(l.Days[t.Weekday()])
+		case "Mon":
+			b.WriteString(l.ShortDays[t.Weekday()])
+		}
+		layout = layout[i+len(name):]
+	}
+	return b.String()
+}
+
+// nextNameToken returns the position and text of the first month or
+// weekday name token in layout, or -1.
+func nextNameToken(layout string) (int, string) {
+	jan, mon := strings.Index(layout, "Jan"), strings.Index(layout, "Mon")
+	switch {
+	case jan < 0 && mon < 0:
+		return -1, ""
+	case mon < 0 || jan >= 0 && jan < mon:
+		if strings.HasPrefix(layout[jan:], "January") {
+			return jan, "January"
+		}
+		return jan, "Jan"
+	default:
+		if strings.HasPrefix(layout[mon:], "Monday") {
+			return mon, "Monday"
+		}
+		return mon, "Mon"
+	}
+}
+
+// formatNumberArgs implements the number template function:
+// {{number .X}} keeps the digits the value needs and {{number 2 .X}}
+// rounds to two decimals.
+func (l Locale) formatNumberArgs(args ...any) (string, error) {
+	decimals := -1
+	switch len(args) {
+	case 1:
+	case 2:
+		d, ok := args[0].(int)
+		if !ok || d < 0 {
+			return "", fmt.Errorf("number: decimals must be a non-negative int, got %v", args[0])
+		}
+		decimals = d
+	default:
+		return "", fmt.Errorf("number: want 1 or 2 arguments, got %d", len(args))
+	}
+	return l.formatNumber(args[len(args)-1], decimals)
+}
+
+// formatCurrency implements the currency template function: it
+// formats the amount v, in major units, in the currency code.
+func (l Locale) formatCurrency(code string, v any) (string, error) {
+	code = strings.ToUpper(code)
+	digits, ok := currencyDigits[code]
+	if !ok {
+		digits = 2
+	}
+	amount, err := l.formatNumber(v, digits)
+	if err != nil {
+		return "", fmt.Errorf("currency: %w", err)
+	}
+	sign := ""
+	if s, ok := strings.CutPrefix(amount, "-"); ok {
+		sign, amount = "-", s
+	}
+	symbol := l.CurrencySymbols[code]
+	if symbol == "" {
+		if symbol = currencySymbols[code]; symbol == "" {
+			symbol = code
+		}
+	}
+	s := strings.Replace(l.CurrencyPattern, "#", amount, 1)
+	return sign + strings.Replace(s, "¤", symbol, 1), nil
+}
+
+// formatNumber formats v, an integer or floating-point number, with
+// decimals fraction digits, or as many as it needs if decimals is -1.
+func (l Locale) formatNumber(v any, decimals int) (string, error) {
+	var digits string
+	var neg bool
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := rv.Int()
+		neg = n < 0
+		digits = strconv.FormatUint(absInt(n), 10)
+		if decimals > 0 {
+			digits += "." + strings.Repeat("0", decimals)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		digits = strconv.FormatUint(rv.Uint(), 10)
+		if decimals > 0 {
+			digits += "." + strings.Repeat("0", decimals)
+		}
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return strconv.FormatFloat(f, 'f', -1, 64), nil
+		}
+		digits = strconv.FormatFloat(math.Abs(f), 'f', decimals, 64)
+		// Values that round to zero lose their sign.
+		neg = f < 0 && strings.Trim(digits, "0.") != ""
+	default:
+		return "", fmt.Errorf("not a number: %T", v)
+	}
+	whole, frac, _ := strings.Cut(digits, ".")
+	var b strings.Builder
+	if neg {
+		b.WriteByte('-')
+	}
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(l.GroupSep)
+		}
+		b.WriteRune(c)
+	}
+	if frac != "" {
+		b.WriteString(l.DecimalSep)
+		b.WriteString(frac)
+	}
+	return b.String(), nil
+}
+
+// absInt returns the magnitude of n, which fits math.MinInt64.
+func absInt(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
+
+// formatRelative implements the relativeTime template function: it
+// describes t relative to the clock (see SetClock), e.g. "3 days ago"
+// or "in 2 hours", rounding down to whole units.
+func (l Locale) formatRelative(t time.Time) string {
+	d := t.Sub(internal.Now())
+	future := d > 0
+	if d < 0 {
+		d = -d
+	}
+	const day = 24 * time.Hour
+	var unit string
+	var n int64
+	switch {
+	case d < time.Minute:
+		return l.RelativeNow
+	case d < time.Hour:
+		unit, n = "minute", int64(d/time.Minute)
+	case d < day:
+		unit, n = "hour", int64(d/time.Hour)
+	case d < 7*day:
+		unit, n = "day", int64(d/day)
+	case d < 30*day:
+		unit, n = "week", int64(d/(7*day))
+	case d < 365*day:
+		unit, n = "month", int64(d/(30*day))
+	default:
+		unit, n = "year", int64(d/(365*day))
+	}
+	units, wrap := l.RelativeUnits, l.RelativePast
+	if future {
+		wrap = l.RelativeFuture
+		if l.FutureUnits != nil {
+			units = l.FutureUnits
+		}
+	}
+	forms, ok := units[unit]
+	if !ok {
+		forms = localeEN.RelativeUnits[unit]
+	}
+	form := forms[1]
+	if n == 1 {
+		form = forms[0]
+	}
+	return fmt.Sprintf(wrap, fmt.Sprintf(form, n))
+}
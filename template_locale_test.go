@@ -0,0 +1,143 @@
+package email
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestTemplatesLocaleFuncs(t *testing.T) {
+	mfs := fstest.MapFS{
+		"invoice.txt.tmpl": {Data: []byte(
+			`{{.Total | currency "EUR"}}|{{.Count | number}}|{{.Ratio | number 2}}|` +
+				`{{.Due | date "long"}}|{{.Due | relativeTime}}`)},
+		"invoice.html.tmpl": {Data: []byte(`<b>{{.Total | currency "USD"}}</b>`)},
+	}
+	ts, err := LoadTemplates(mfs)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	now := time.Date(2006, 1, 2, 12, 0, 0, 0, time.UTC)
+	defer SetClock(func() time.Time { return now })()
+	data := map[string]any{
+		"Total": 1234.5,
+		"Count": 1234567,
+		"Ratio": 0.126,
+		"Due":   now,
+	}
+
+	p, h, err := ts.Render("invoice", data)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if got, want := string(p), "€1,234.50|1,234,567|0.13|January 2, 2006|just now"; got != want {
+		t.Fatalf("en: got %q, want %q", got, want)
+	}
+	if got, want := string(h), "<b>$1,234.50</b>"; got != want {
+		t.Fatalf("en html: got %q, want %q", got, want)
+	}
+
+	data["Due"] = now.Add(3 * 24 * time.Hour)
+	p, _, err = ts.RenderLocale("de", "invoice", data)
+	if err != nil {
+		t.Fatalf("render de: %v", err)
+	}
+	if got := string(p); !strings.HasPrefix(got, "1.234,50\u00a0€|1.234.567|0,13|5. Januar 2006|") {
+		t.Fatalf("de: got %q", got)
+	}
+	p, _, err = ts.RenderLocale("fi", "invoice", data)
+	if err != nil {
+		t.Fatalf("render fi: %v", err)
+	}
+	if got := string(p); !strings.HasSuffix(got, "|3 päivän päästä") {
+		t.Fatalf("fi: got %q", got)
+	}
+}
+
+func TestTemplatesRenderLocaleFallback(t *testing.T) {
+	mfs := fstest.MapFS{
+		"n.txt.tmpl": {Data: []byte(`{{number 1 .}}`)},
+	}
+	ts, err := LoadTemplatesWithOptions(mfs, LoadOptions{Locale: "fr"})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	ts.WithLocales(Locale{Tag: "en-IN", GroupSep: "_"})
+	cases := []struct{ locale, want string }{
+		{"", "1\u202f234,5"},   // set default
+		{"xx", "1\u202f234,5"}, // unknown: set default
+		{"de-AT", "1.234,5"},   // by language
+		{"DE_at", "1.234,5"},   // normalized
+		{"en-IN", "1_234.5"},   // custom, English defaults
+		{"en-US", "1,234.5"},   // built-in by language
+	}
+	for _, c := range cases {
+		p, _, err := ts.RenderLocale(c.locale, "n", 1234.5)
+		if err != nil {
+			t.Fatalf("%q: %v", c.locale, err)
+		}
+		if string(p) != c.want {
+			t.Errorf("%q: got %q, want %q", c.locale, p, c.want)
+		}
+	}
+	if n := len(ts.localized); n != 4 {
+		t.Fatalf("cached %d locales, want 4", n)
+	}
+}
+
+func TestTemplatesRenderLocaleConcurrent(t *testing.T) {
+	mfs := fstest.MapFS{
+		"n.txt.tmpl":  {Data: []byte(`{{number .}}`)},
+		"n.html.tmpl": {Data: []byte(`<i>{{number .}}</i>`)},
+	}
+	ts, err := LoadTemplates(mfs)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := map[string]string{"en": "1,000", "de": "1.000", "sv": "1\u00a0000"}
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		for loc, w := range want {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p, h, err := ts.RenderLocale(loc, "n", 1000)
+				if err != nil || string(p) != w || string(h) != "<i>"+w+"</i>" {
+					t.Errorf("%s: %q %q %v", loc, p, h, err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+func TestLocaleFormatting(t *testing.T) {
+	en, _ := LookupLocale("en")
+	if got, err := en.formatNumber(-1234567.891, 2); err != nil || got != "-1,234,567.89" {
+		t.Fatalf("number: %q %v", got, err)
+	}
+	if got, err := en.formatCurrency("JPY", 1234.4); err != nil || got != "¥1,234" {
+		t.Fatalf("JPY: %q %v", got, err)
+	}
+	if _, err := en.formatNumber("x", 0); err == nil {
+		t.Fatal("expected error for a non-number")
+	}
+	now := time.Now()
+	defer SetClock(func() time.Time { return now })()
+	for d, want := range map[time.Duration]string{
+		-90 * time.Minute:    "1 hour ago",
+		-2 * 24 * time.Hour:  "2 days ago",
+		14 * 24 * time.Hour:  "in 2 weeks",
+		400 * 24 * time.Hour: "in 1 year",
+		-10 * time.Second:    "just now",
+	} {
+		if got := en.formatRelative(now.Add(d)); got != want {
+			t.Errorf("%v: got %q, want %q", d, got, want)
+		}
+	}
+	if _, ok := LookupLocale("zz"); ok {
+		t.Fatal("unexpected locale zz")
+	}
+}
@@ -0,0 +1,72 @@
+package email
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Personalization holds per-recipient template fields, e.g. from a
+// mailing list row. Templates read them with fallbacks so a missing
+// field does not leave a gap such as "Hi ,":
+//
+//	Hi {{field . "first_name" "there"}},
+//	Hi {{.first_name | default "there"}},
+type Personalization map[string]any
+
+// Get returns the field key formatted with fmt, or fallback if the
+// field is missing, nil or blank. key may be a dotted path into nested
+// maps and structs, e.g. "company.name".
+//
+// Parameters:
+//   - key: The field name or path.
+//   - fallback: The value for a missing field.
+//
+// Returns:
+//   - string: The field value or fallback.
+func (p Personalization) Get(key, fallback string) string {
+	return templateField(p, key, fallback)
+}
+
+// templateDefault implements the default template function: it returns
+// v formatted with fmt, or fallback if v is nil or blank.
+func templateDefault(fallback string, v any) string {
+	s, ok := personalValue(reflect.ValueOf(v))
+	if !ok {
+		return fallback
+	}
+	return s
+}
+
+// templateField implements the field template function: it looks up the
+// dotted path key in data, a map with string keys or a struct, and
+// returns the value formatted with fmt, or fallback if it is missing,
+// nil or blank.
+func templateField(data any, key, fallback string) string {
+	v := reflect.ValueOf(data)
+	for _, name := range strings.Split(key, ".") {
+		var ok bool
+		if v, ok = field(indirect(v), name); !ok {
+			return fallback
+		}
+	}
+	s, ok := personalValue(v)
+	if !ok {
+		return fallback
+	}
+	return s
+}
+
+// personalValue formats v, reporting false if v is missing, nil or a
+// blank string.
+func personalValue(v reflect.Value) (string, bool) {
+	v = indirect(v)
+	if !v.IsValid() {
+		return "", false
+	}
+	s := fmt.Sprint(v.Interface())
+	if v.Kind() == reflect.String && strings.TrimSpace(s) == "" {
+		return "", false
+	}
+	return s, true
+}
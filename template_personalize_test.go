@@ -0,0 +1,65 @@
+package email
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestTemplatePersonalizationFallbacks(t *testing.T) {
+	set, err := LoadTemplates(fstest.MapFS{
+		"hi.txt.tmpl":  {Data: []byte(`Hi {{.first_name | default "there"}}, {{field . "company.name" "your team"}}`)},
+		"hi.html.tmpl": {Data: []byte(`<p>Hi {{field . "first_name" "there"}}</p>`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		data       any
+		text, html string
+	}{
+		{Personalization{}, "Hi there, your team", "<p>Hi there</p>"},
+		{Personalization{"first_name": "  "}, "Hi there, your team", "<p>Hi there</p>"},
+		{
+			Personalization{"first_name": "Ada", "company": map[string]string{"name": "Acme"}},
+			"Hi Ada, Acme", "<p>Hi Ada</p>",
+		},
+		{
+			Personalization{"first_name": "<b>Bob</b>"},
+			"Hi <b>Bob</b>, your team", "<p>Hi &lt;b&gt;Bob&lt;/b&gt;</p>",
+		},
+	}
+	for _, c := range cases {
+		text, html, err := set.Render("hi", c.data)
+		if err != nil {
+			t.Fatalf("render %v: %v", c.data, err)
+		}
+		if string(text) != c.text || string(html) != c.html {
+			t.Errorf("render %v = %q, %q; want %q, %q", c.data, text, html, c.text, c.html)
+		}
+	}
+}
+
+func TestPersonalizationGet(t *testing.T) {
+	type user struct {
+		Name  string
+		Count int
+		Boss  *user
+	}
+	p := Personalization{
+		"user":  user{Name: "Ada", Boss: &user{Name: "Grace"}},
+		"empty": nil,
+	}
+	for key, want := range map[string]string{
+		"user.Name":      "Ada",
+		"user.Count":     "0",
+		"user.Boss.Name": "Grace",
+		"user.Boss.Boss": "-",
+		"user.missing":   "-",
+		"empty":          "-",
+		"nope.Name":      "-",
+	} {
+		if got := p.Get(key, "-"); got != want {
+			t.Errorf("Get(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package email
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// TemplateDataError reports template data that does not match the
+// template's schema. Render returns it before executing the template,
+// so missing fields never reach a message as "<no value>".
+type TemplateDataError struct {
+	Template string
+	Problems []string // e.g. `missing "Order.ID"`
+}
+
+// Error implements error.
+func (e *TemplateDataError) Error() string {
+	return fmt.Sprintf("template %q: invalid data: %s", e.Template,
+		strings.Join(e.Problems, "; "))
+}
+
+// templateSchema is the subset of JSON Schema accepted in a
+// name.schema.json file: "type", "required", "properties" and "items".
+// Fields are looked up the way templates resolve .Field, as map keys or
+// exported struct fields.
+type templateSchema struct {
+	Type       string                     `json:"type"`
+	Required   []string                   `json:"required"`
+	Properties map[string]*templateSchema `json:"properties"`
+	Items      *templateSchema            `json:"items"`
+}
+
+// parseTemplateSchema parses and checks a schema file.
+func parseTemplateSchema(b []byte) (*templateSchema, error) {
+	var s templateSchema
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// validate rejects unknown types anywhere in s.
+func (s *templateSchema) validate() error {
+	switch s.Type {
+	case "", "string", "number", "integer", "boolean", "array", "object":
+	default:
+		return fmt.Errorf("unknown type %q", s.Type)
+	}
+	for name, p := range s.Properties {
+		if p == nil {
+			return fmt.Errorf("property %q: empty schema", name)
+		}
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("property %q: %w", name, err)
+		}
+	}
+	if s.Items != nil {
+		return s.Items.validate()
+	}
+	return nil
+}
+
+// check appends the ways v does not match s to problems. path names v
+// in messages and is empty for the top-level data.
+func (s *templateSchema) check(path string, v reflect.Value, problems *[]string) {
+	v = indirect(v)
+	if !v.IsValid() {
+		if s.Type != "" || len(s.Required) > 0 {
+			*problems = append(*problems, fmt.Sprintf("%s: want %s, got nil",
+				schemaPath(path), s.kind()))
+		}
+		return
+	}
+	if s.Type != "" && !matchesType(s.Type, v) {
+		*problems = append(*problems, fmt.Sprintf("%s: want %s, got %s",
+			schemaPath(path), s.Type, v.Type()))
+		return
+	}
+	for _, name := range s.Required {
+		if _, ok := field(v, name); !ok {
+			*problems = append(*problems, fmt.Sprintf("missing %q",
+				joinPath(path, name)))
+		}
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if fv, ok := field(v, name); ok {
+			s.Properties[name].check(joinPath(path, name), fv, problems)
+		}
+	}
+	if s.Items != nil && (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) {
+		for i := 0; i < v.Len(); i++ {
+			s.Items.check(fmt.Sprintf("%s[%d]", path, i), v.Index(i), problems)
+		}
+	}
+}
+
+// kind describes what s expects, for nil values.
+func (s *templateSchema) kind() string {
+	if s.Type != "" {
+		return s.Type
+	}
+	return "object"
+}
+
+// indirect dereferences pointers and interfaces; nil becomes invalid.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// field returns the map entry or exported struct field name of v.
+func field(v reflect.Value, name string) (reflect.Value, bool) {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}, false
+		}
+		fv := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		return fv, fv.IsValid()
+	case reflect.Struct:
+		sf, ok := v.Type().FieldByName(name)
+		if !ok || !sf.IsExported() {
+			return reflect.Value{}, false
+		}
+		fv, err := v.FieldByIndexErr(sf.Index)
+		return fv, err == nil
+	}
+	return reflect.Value{}, false
+}
+
+// matchesType reports whether v has the JSON Schema type typ.
+func matchesType(typ string, v reflect.Value) bool {
+	switch k := v.Kind(); typ {
+	case "string":
+		return k == reflect.String
+	case "boolean":
+		return k == reflect.Bool
+	case "number":
+		return isInt(k) || k == reflect.Float32 || k == reflect.Float64
+	case "integer":
+		if k == reflect.Float32 || k == reflect.Float64 {
+			f := v.Float()
+			return f == math.Trunc(f) && !math.IsInf(f, 0)
+		}
+		return isInt(k)
+	case "array":
+		return k == reflect.Slice || k == reflect.Array
+	case "object":
+		return k == reflect.Map || k == reflect.Struct
+	}
+	return true
+}
+
+func isInt(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Uintptr
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func schemaPath(path string) string {
+	if path == "" {
+		return "data"
+	}
+	return fmt.Sprintf("%q", path)
+}
@@ -0,0 +1,82 @@
+package email
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTemplateSchemaRender(t *testing.T) {
+	mfs := fstest.MapFS{
+		"order.txt.tmpl": {Data: []byte("Hi {{.Name}}, order {{.Order.ID}}")},
+		"order.schema.json": {Data: []byte(`{
+			"required": ["Name", "Order"],
+			"properties": {
+				"Name": {"type": "string"},
+				"Order": {"type": "object", "required": ["ID"],
+					"properties": {"ID": {"type": "integer"}}},
+				"Items": {"type": "array", "items": {"type": "object", "required": ["SKU"]}}
+			}
+		}`)},
+	}
+	ts, err := LoadTemplates(mfs)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	type order struct{ ID int }
+	type data struct {
+		Name  string
+		Order *order
+	}
+	p, _, err := ts.Render("order", data{Name: "Ada", Order: &order{ID: 7}})
+	if err != nil || string(p) != "Hi Ada, order 7" {
+		t.Fatalf("struct data: %q %v", p, err)
+	}
+	// JSON-decoded numbers are float64.
+	_, _, err = ts.Render("order", map[string]any{
+		"Name": "Ada", "Order": map[string]any{"ID": 7.0},
+	})
+	if err != nil {
+		t.Fatalf("map data: %v", err)
+	}
+
+	_, _, err = ts.Render("order", map[string]any{
+		"Name":  42,
+		"Order": map[string]any{"ID": 7.5},
+		"Items": []any{map[string]any{"SKU": "a"}, map[string]any{}},
+	})
+	var dErr *TemplateDataError
+	if !errors.As(err, &dErr) {
+		t.Fatalf("expected *TemplateDataError, got %v", err)
+	}
+	want := []string{
+		`missing "Items[1].SKU"`,
+		`"Name": want string, got int`,
+		`"Order.ID": want integer, got float64`,
+	}
+	if !reflect.DeepEqual(dErr.Problems, want) {
+		t.Fatalf("problems = %q", dErr.Problems)
+	}
+
+	_, _, err = ts.Render("order", data{Name: "Ada"})
+	if !errors.As(err, &dErr) || !reflect.DeepEqual(dErr.Problems,
+		[]string{`"Order": want object, got nil`}) {
+		t.Fatalf("nil order: %v", err)
+	}
+	_, _, err = ts.Render("order", nil)
+	if !errors.As(err, &dErr) {
+		t.Fatalf("nil data: %v", err)
+	}
+}
+
+func TestTemplateSchemaLoadErrors(t *testing.T) {
+	for _, schema := range []string{`{`, `{"type": "date"}`,
+		`{"properties": {"A": {"type": "strng"}}}`} {
+		mfs := fstest.MapFS{"a.schema.json": {Data: []byte(schema)}}
+		if _, err := LoadTemplates(mfs); err == nil {
+			t.Fatalf("expected error for %s", schema)
+		}
+	}
+}
@@ -1,22 +1,63 @@
 package email
 
 import (
+	"bytes"
 	"fmt"
 	htmltmpl "html/template"
 	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	texttmpl "text/template"
+
+	"github.com/aatuh/email/v2/sanitize"
+	"github.com/aatuh/email/v2/types"
 )
 
 // TemplateSet loads and renders text and HTML templates from an fs.FS.
 //
 // Convention:
 //
-//	name.txt.tmpl  -> plain text body
-//	name.html.tmpl -> HTML body
+//	name.txt.tmpl    -> plain text body
+//	name.html.tmpl   -> HTML body
+//	name.schema.json -> required data fields (optional)
 //
-// Both files are optional; at least one must exist to render a message.
+// Both bodies are optional; at least one must exist to render a message.
+// HTML templates can call sanitizeHTML to embed untrusted HTML cleaned
+// with sanitize.DefaultPolicy: {{ sanitizeHTML .Comment }}. They can
+// reference inline images with cid, e.g. <img src="{{cid "logo"}}">;
+// RenderMessage attaches the images from the set's assets.
+// Both kinds can fill in personalization fields with a fallback for
+// missing or blank values: {{.FirstName | default "there"}} or
+// {{field . "first_name" "there"}}; see Personalization.
+// Both kinds can format dates, numbers, amounts and relative times for
+// the render locale with date, number, currency and relativeTime; see
+// RenderLocale.
 type TemplateSet struct {
+	// texts and htmls are never executed, so that they can be cloned
+	// for each locale.
+	texts  *texttmpl.Template
+	htmls  *htmltmpl.Template
+	assets fs.FS // inline images for cid; see WithAssets
+
+	schemas  map[string]*templateSchema // by template name
+	findings []Finding
+
+	locale  string   // default render locale
+	locales []Locale // added with WithLocales
+
+	mu        sync.Mutex
+	localized map[string]*localizedTemplates // by locale tag
+}
+
+// localizedTemplates are the templates of a TemplateSet with the
+// formatting functions of one locale.
+type localizedTemplates struct {
 	texts *texttmpl.Template
 	htmls *htmltmpl.Template
 }
@@ -36,7 +77,14 @@ func MustLoadTemplates(fsys fs.FS) *TemplateSet {
 	return ts
 }
 
-// LoadTemplates walks fsys and parses *.txt.tmpl and *.html.tmpl.
+// LoadTemplates walks fsys and parses *.txt.tmpl and *.html.tmpl, and
+// the *.schema.json files declaring the data each template needs. A
+// schema is a subset of JSON Schema, with "type" (string, number,
+// integer, boolean, array, object), "required", "properties" and
+// "items", e.g.
+//
+//	{"required": ["Name", "Order"],
+//	 "properties": {"Order": {"type": "object", "required": ["ID"]}}}
 //
 // Parameters:
 //   - fsys: The filesystem.
@@ -45,8 +93,35 @@ func MustLoadTemplates(fsys fs.FS) *TemplateSet {
 //   - *TemplateSet: The template set.
 //   - error: The error if the template set fails to load.
 func LoadTemplates(fsys fs.FS) (*TemplateSet, error) {
-	textRoot := texttmpl.New("text")
-	htmlRoot := htmltmpl.New("html")
+	return LoadTemplatesWithOptions(fsys, LoadOptions{})
+}
+
+// LoadTemplatesWithOptions is LoadTemplates with options. By default
+// the templates are linted for escaping mistakes and the findings are
+// available from Findings; see LoadOptions.
+//
+// Parameters:
+//   - fsys: The filesystem.
+//   - opts: The load options.
+//
+// Returns:
+//   - *TemplateSet: The template set.
+//   - error: The error if the template set fails to load, or a
+//     *TemplateLintError.
+func LoadTemplatesWithOptions(fsys fs.FS, opts LoadOptions) (*TemplateSet, error) {
+	textRoot := texttmpl.New("text").Funcs(texttmpl.FuncMap{
+		"default": templateDefault,
+		"field":   templateField,
+	}).Funcs(localeFuncs(localeEN))
+	schemas := map[string]*templateSchema{}
+	htmlRoot := htmltmpl.New("html").Funcs(htmltmpl.FuncMap{
+		"sanitizeHTML": func(s string) htmltmpl.HTML {
+			return htmltmpl.HTML(sanitize.HTML(s))
+		},
+		"cid":     templateCID,
+		"default": templateDefault,
+		"field":   templateField,
+	}).Funcs(localeFuncs(localeEN))
 	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, e error) error {
 		if e != nil {
 			return e
@@ -70,6 +145,17 @@ func LoadTemplates(fsys fs.FS) (*TemplateSet, error) {
 			}
 			_, perr := htmlRoot.New(path).Parse(string(b))
 			return perr
+		case strings.HasSuffix(lower, ".schema.json"):
+			b, rerr := fs.ReadFile(fsys, path)
+			if rerr != nil {
+				return rerr
+			}
+			schema, perr := parseTemplateSchema(b)
+			if perr != nil {
+				return fmt.Errorf("%s: %w", path, perr)
+			}
+			schemas[path[:len(path)-len(".schema.json")]] = schema
+			return nil
 		default:
 			return nil
 		}
@@ -77,11 +163,65 @@ func LoadTemplates(fsys fs.FS) (*TemplateSet, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &TemplateSet{texts: textRoot, htmls: htmlRoot}, nil
+	ts := &TemplateSet{
+		texts: textRoot, htmls: htmlRoot, schemas: schemas,
+		locale: opts.Locale, localized: map[string]*localizedTemplates{},
+	}
+	if opts.Lint == TemplateLintOff {
+		return ts, nil
+	}
+	for _, tmpl := range textRoot.Templates() {
+		if tmpl.Tree != nil {
+			ts.findings = append(ts.findings, lintTextTree(tmpl.Tree)...)
+		}
+	}
+	for _, tmpl := range htmlRoot.Templates() {
+		if tmpl.Tree != nil {
+			ts.findings = append(ts.findings, lintHTMLTree(tmpl.Tree)...)
+		}
+	}
+	slices.SortFunc(ts.findings, func(a, b Finding) int {
+		return strings.Compare(a.Message, b.Message)
+	})
+	if opts.Lint == TemplateLintFail && len(ts.findings) > 0 {
+		return nil, &TemplateLintError{Findings: ts.findings}
+	}
+	return ts, nil
+}
+
+// Findings returns the template lint findings from loading.
+//
+// Returns:
+//   - []Finding: The findings, empty if none.
+func (t *TemplateSet) Findings() []Finding {
+	return t.findings
+}
+
+// Names returns the names of the templates in the set, as accepted by
+// Render: the file paths without the .txt.tmpl or .html.tmpl suffix.
+//
+// Returns:
+//   - []string: The sorted template names.
+func (t *TemplateSet) Names() []string {
+	var names []string
+	for _, tmpl := range t.texts.Templates() {
+		if n, ok := strings.CutSuffix(tmpl.Name(), ".txt.tmpl"); ok {
+			names = append(names, n)
+		}
+	}
+	for _, tmpl := range t.htmls.Templates() {
+		if n, ok := strings.CutSuffix(tmpl.Name(), ".html.tmpl"); ok {
+			names = append(names, n)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
 }
 
 // Render renders "name" by locating "name.txt.tmpl" and "name.html.tmpl"
 // anywhere in the parsed set. If only one exists, the other return is nil.
+// If "name.schema.json" exists, data is checked against it first and a
+// mismatch fails with a *TemplateDataError listing every problem.
 //
 // Parameters:
 //   - name: The name of the template.
@@ -92,11 +232,48 @@ func LoadTemplates(fsys fs.FS) (*TemplateSet, error) {
 //   - []byte: The HTML body.
 //   - error: The error if the template fails to render.
 func (t *TemplateSet) Render(name string, data any) ([]byte, []byte, error) {
+	return t.RenderLocale("", name, data)
+}
+
+// RenderLocale is Render with the date, number, currency and
+// relativeTime functions formatting for locale, a BCP 47 tag such as
+// "de-AT":
+//
+//	{{.Total | currency "EUR"}}  {{.Due | date "long"}}  {{.Due | relativeTime}}
+//	{{.Count | number}}  {{.Ratio | number 2}}
+//
+// locale is matched against the locales of WithLocales, then the
+// built-in ones (see LookupLocale), first exactly and then by language.
+// An empty or unknown locale uses LoadOptions.Locale, and English if
+// that is unknown too.
+//
+// Parameters:
+//   - locale: The render locale.
+//   - name: The name of the template.
+//   - data: The data to render the template with.
+//
+// Returns:
+//   - []byte: The plain text body.
+//   - []byte: The HTML body.
+//   - error: The error if the template fails to render.
+func (t *TemplateSet) RenderLocale(locale, name string, data any) ([]byte, []byte, error) {
 	var plain, html []byte
 	txtName := name + ".txt.tmpl"
 	htmlName := name + ".html.tmpl"
 
-	if tmpl := t.texts.Lookup(txtName); tmpl != nil {
+	if schema := t.schemas[name]; schema != nil {
+		var problems []string
+		schema.check("", reflect.ValueOf(data), &problems)
+		if len(problems) > 0 {
+			return nil, nil, &TemplateDataError{Template: name, Problems: problems}
+		}
+	}
+
+	lt, err := t.localize(locale)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tmpl := lt.texts.Lookup(txtName); tmpl != nil {
 		var b strings.Builder
 		if err := tmpl.Execute(&b, data); err != nil {
 			return nil, nil, fmt.Errorf("render text: %w", err)
@@ -104,7 +281,7 @@ func (t *TemplateSet) Render(name string, data any) ([]byte, []byte, error) {
 		plain = []byte(b.String())
 	}
 
-	if tmpl := t.htmls.Lookup(htmlName); tmpl != nil {
+	if tmpl := lt.htmls.Lookup(htmlName); tmpl != nil {
 		var b strings.Builder
 		if err := tmpl.Execute(&b, data); err != nil {
 			return nil, nil, fmt.Errorf("render html: %w", err)
@@ -117,3 +294,189 @@ func (t *TemplateSet) Render(name string, data any) ([]byte, []byte, error) {
 	}
 	return plain, html, nil
 }
+
+// WithLocales adds locales for RenderLocale, taking precedence over the
+// built-in ones with the same tag. Call it before the set is shared
+// between goroutines.
+//
+// Parameters:
+//   - locales: The locales.
+//
+// Returns:
+//   - *TemplateSet: The template set, for chaining.
+func (t *TemplateSet) WithLocales(locales ...Locale) *TemplateSet {
+	t.locales = append(t.locales, locales...)
+	clear(t.localized)
+	return t
+}
+
+// findLocale resolves the render locale tag; see RenderLocale.
+func (t *TemplateSet) findLocale(tag string) Locale {
+	for _, tag := range []string{tag, t.locale} {
+		if tag == "" {
+			continue
+		}
+		if l, ok := findLocale(t.locales, tag); ok {
+			return l
+		}
+		if l, ok := LookupLocale(tag); ok {
+			return l
+		}
+	}
+	return localeEN
+}
+
+// localize returns the templates formatting for the locale tag, cloned
+// from the set on first use.
+func (t *TemplateSet) localize(tag string) (*localizedTemplates, error) {
+	l := t.findLocale(tag)
+	key := normalizeLocaleTag(l.Tag)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if lt := t.localized[key]; lt != nil {
+		return lt, nil
+	}
+	texts, err := t.texts.Clone()
+	if err != nil {
+		return nil, err
+	}
+	htmls, err := t.htmls.Clone()
+	if err != nil {
+		return nil, err
+	}
+	funcs := localeFuncs(l)
+	lt := &localizedTemplates{texts: texts.Funcs(funcs), htmls: htmls.Funcs(funcs)}
+	t.localized[key] = lt
+	return lt, nil
+}
+
+// templateCIDSuffix marks the content IDs produced by the cid template
+// function.
+const templateCIDSuffix = "@template"
+
+// validAssetName matches the asset names cid accepts: characters that
+// are valid in a Content-ID without escaping.
+var validAssetName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+
+// templateCIDRef finds cid references written by templateCID.
+var templateCIDRef = regexp.MustCompile(
+	`cid:([A-Za-z0-9][A-Za-z0-9._/-]*)` + regexp.QuoteMeta(templateCIDSuffix))
+
+// templateCID is the cid template function. It returns the cid: URL of
+// the inline image asset name, typed so html/template keeps the scheme.
+func templateCID(name string) (htmltmpl.URL, error) {
+	if !validAssetName.MatchString(name) {
+		return "", fmt.Errorf("cid: invalid asset name %q", name)
+	}
+	return htmltmpl.URL("cid:" + name + templateCIDSuffix), nil
+}
+
+// WithAssets sets the filesystem RenderMessage reads inline images
+// from. Call it before the set is shared between goroutines.
+//
+// Parameters:
+//   - assets: The filesystem holding the inline images.
+//
+// Returns:
+//   - *TemplateSet: The template set, for chaining.
+func (t *TemplateSet) WithAssets(assets fs.FS) *TemplateSet {
+	t.assets = assets
+	return t
+}
+
+// RenderMessage renders "name" like Render into a copy of base,
+// replacing its Plain and HTML bodies, so the template-to-send flow is
+// one call. Every image the HTML template references with cid is
+// appended to the attachments as an inline part: an image "logo" is
+// read from the assets (see WithAssets) as the file "logo", or else the
+// single file matching "logo.*", and its Content-Type comes from the
+// extension or the content.
+//
+// Parameters:
+//   - name: The name of the template.
+//   - data: The data to render the template with.
+//   - base: The message supplying addresses, subject, headers and
+//     attachments.
+//
+// Returns:
+//   - types.Message: The message with bodies and inline images.
+//   - error: The error if rendering fails or an image is missing.
+func (t *TemplateSet) RenderMessage(
+	name string,
+	data any,
+	base types.Message,
+) (types.Message, error) {
+	return t.RenderMessageLocale("", name, data, base)
+}
+
+// RenderMessageLocale is RenderMessage rendering for locale; see
+// RenderLocale.
+//
+// Parameters:
+//   - locale: The render locale.
+//   - name: The name of the template.
+//   - data: The data to render the template with.
+//   - base: The message supplying addresses, subject, headers and
+//     attachments.
+//
+// Returns:
+//   - types.Message: The message with bodies and inline images.
+//   - error: The error if rendering fails or an image is missing.
+func (t *TemplateSet) RenderMessageLocale(
+	locale, name string,
+	data any,
+	base types.Message,
+) (types.Message, error) {
+	plain, html, err := t.RenderLocale(locale, name, data)
+	if err != nil {
+		return types.Message{}, err
+	}
+	msg := base
+	msg.Plain, msg.HTML = plain, html
+	msg.Attach = append([]types.Attachment(nil), base.Attach...)
+	seen := map[string]bool{}
+	for _, m := range templateCIDRef.FindAllSubmatch(html, -1) {
+		asset := string(m[1])
+		if seen[asset] {
+			continue
+		}
+		seen[asset] = true
+		a, err := assetAttachment(t.assets, asset)
+		if err != nil {
+			return types.Message{}, fmt.Errorf("template %q: %w", name, err)
+		}
+		msg.Attach = append(msg.Attach, a)
+	}
+	return msg, nil
+}
+
+// assetAttachment reads the inline image asset name from assets.
+func assetAttachment(assets fs.FS, name string) (types.Attachment, error) {
+	if assets == nil {
+		return types.Attachment{}, fmt.Errorf("inline image %q: no assets", name)
+	}
+	file := name
+	if _, err := fs.Stat(assets, name); err != nil {
+		matches, gerr := fs.Glob(assets, name+".*")
+		if gerr != nil || len(matches) != 1 {
+			return types.Attachment{}, fmt.Errorf(
+				"inline image %q: want one asset named %q or %q, found %d",
+				name, name, name+".*", len(matches))
+		}
+		file = matches[0]
+	}
+	b, err := fs.ReadFile(assets, file)
+	if err != nil {
+		return types.Attachment{}, fmt.Errorf("inline image %q: %w", name, err)
+	}
+	ctype := mime.TypeByExtension(path.Ext(file))
+	if ctype == "" {
+		ctype = http.DetectContentType(b)
+	}
+	return types.Attachment{
+		Filename:    path.Base(file),
+		ContentType: ctype,
+		ContentID:   name + templateCIDSuffix,
+		Reader:      bytes.NewReader(b),
+	}, nil
+}
@@ -1,8 +1,11 @@
 package email
 
 import (
+    "strings"
     "testing"
     "testing/fstest"
+
+    "github.com/aatuh/email/v2/types"
 )
 
 func TestTemplatesRender(t *testing.T) {
@@ -25,3 +28,101 @@ func TestTemplatesRender(t *testing.T) {
         t.Fatalf("expected error for missing template")
     }
 }
+
+func TestTemplatesSanitizeHTML(t *testing.T) {
+	mfs := fstest.MapFS{
+		"comment.html.tmpl": {Data: []byte("<div>{{sanitizeHTML .Body}}</div><p>{{.Body}}</p>")},
+	}
+	ts, err := LoadTemplates(mfs)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	_, h, err := ts.Render("comment", map[string]any{
+		"Body": `<b onclick="x()">hi</b><script>alert(1)</script>`,
+	})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	want := "<div><b>hi</b></div><p>&lt;b onclick=&#34;x()&#34;&gt;hi&lt;/b&gt;&lt;script&gt;alert(1)&lt;/script&gt;</p>"
+	if string(h) != want {
+		t.Fatalf("unexpected output: %q", h)
+	}
+}
+
+func TestTemplatesRenderMessageInlineImages(t *testing.T) {
+	mfs := fstest.MapFS{
+		"receipt.html.tmpl": {Data: []byte(`<img src="{{cid "logo"}}"><img src="{{cid "img/badge.gif"}}"><img src="{{cid "logo"}}">`)},
+		"receipt.txt.tmpl":  {Data: []byte("Receipt")},
+		"bad.html.tmpl":     {Data: []byte(`<img src="{{cid "a b"}}">`)},
+		"missing.html.tmpl": {Data: []byte(`<img src="{{cid "nope"}}">`)},
+	}
+	assets := fstest.MapFS{
+		"logo.png":      {Data: []byte("\x89PNG\r\n\x1a\n")},
+		"img/badge.gif": {Data: []byte("GIF89a")},
+	}
+	ts, err := LoadTemplates(mfs)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	ts.WithAssets(assets)
+	msg, err := ts.RenderMessage("receipt", nil, types.Message{})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	want := `<img src="cid:logo@template"><img src="cid:img/badge.gif@template"><img src="cid:logo@template">`
+	if string(msg.HTML) != want || string(msg.Plain) != "Receipt" {
+		t.Fatalf("unexpected bodies: %q | %q", msg.Plain, msg.HTML)
+	}
+	if len(msg.Attach) != 2 {
+		t.Fatalf("attachments = %+v", msg.Attach)
+	}
+	logo, badge := msg.Attach[0], msg.Attach[1]
+	if logo.Filename != "logo.png" || logo.ContentType != "image/png" ||
+		logo.ContentID != "logo@template" {
+		t.Fatalf("logo = %+v", logo)
+	}
+	if badge.Filename != "badge.gif" || badge.ContentType != "image/gif" ||
+		badge.ContentID != "img/badge.gif@template" {
+		t.Fatalf("badge = %+v", badge)
+	}
+
+	if _, err := ts.RenderMessage("bad", nil, types.Message{}); err == nil {
+		t.Fatal("expected invalid asset name error")
+	}
+	if _, err := ts.RenderMessage("missing", nil, types.Message{}); err == nil ||
+		!strings.Contains(err.Error(), `"nope"`) {
+		t.Fatalf("expected missing asset error, got %v", err)
+	}
+}
+
+func TestTemplatesRenderMessageBase(t *testing.T) {
+	mfs := fstest.MapFS{
+		"welcome.txt.tmpl": {Data: []byte("Hi {{.Name}}")},
+	}
+	ts, err := LoadTemplates(mfs)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	doc := types.Attachment{Filename: "terms.pdf"}
+	base := types.Message{
+		From:    types.Address{Mail: "app@example.com"},
+		To:      []types.Address{{Mail: "ada@example.org"}},
+		Subject: "Welcome",
+		HTML:    []byte("<p>stale</p>"),
+		Attach:  []types.Attachment{doc},
+	}
+	msg, err := ts.RenderMessage("welcome", map[string]any{"Name": "Ada"}, base)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if string(msg.Plain) != "Hi Ada" || msg.HTML != nil || msg.Subject != "Welcome" ||
+		msg.From != base.From || len(msg.Attach) != 1 {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	if err := msg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if _, err := ts.RenderMessage("missing", nil, base); err == nil {
+		t.Fatal("expected error for missing template")
+	}
+}
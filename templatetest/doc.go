@@ -0,0 +1,5 @@
+// Package templatetest provides snapshot ("golden") tests for
+// email.TemplateSet. RenderGolden renders a template and compares the
+// plain text and HTML bodies against files in testdata; run the tests
+// with -update to rewrite the files after an intended change.
+package templatetest
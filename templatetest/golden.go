@@ -0,0 +1,178 @@
+package templatetest
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aatuh/email/v2"
+)
+
+// update is the -update test flag.
+var update = flag.Bool("update", false, "rewrite template golden files in testdata")
+
+// Dir is the directory golden files are read from and written to,
+// relative to the package under test.
+const Dir = "testdata"
+
+// RenderGolden renders the template name with data and compares each
+// body with its golden file, testdata/<name>.txt.golden and
+// testdata/<name>.html.golden. A mismatch fails t with a line diff. A
+// body the template does not produce must not have a golden file.
+//
+// With -update the golden files are written instead, and removed for
+// bodies the template no longer produces.
+//
+// Parameters:
+//   - t: The test.
+//   - ts: The template set.
+//   - name: The template name, as passed to Render.
+//   - data: The data to render the template with.
+func RenderGolden(t testing.TB, ts *email.TemplateSet, name string, data any) {
+	t.Helper()
+	plain, html, err := ts.Render(name, data)
+	if err != nil {
+		t.Fatalf("render %q: %v", name, err)
+		return
+	}
+	for _, body := range []struct {
+		ext  string
+		data []byte
+	}{{".txt.golden", plain}, {".html.golden", html}} {
+		path := filepath.Join(Dir, filepath.FromSlash(name)+body.ext)
+		if *update {
+			if err := writeGolden(path, body.data); err != nil {
+				t.Fatalf("update %s: %v", path, err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && body.data == nil:
+			continue
+		case errors.Is(err, fs.ErrNotExist):
+			t.Errorf("%s: missing golden file; run the test with -update", path)
+			continue
+		case err != nil:
+			t.Fatalf("%s: %v", path, err)
+			return
+		case body.data == nil:
+			t.Errorf("%s: template renders no body; run the test with -update", path)
+			continue
+		}
+		if !bytes.Equal(body.data, want) {
+			t.Errorf("%s: rendered %q differs from golden file "+
+				"(-golden +rendered); run the test with -update if intended:\n%s",
+				path, name, Diff(string(want), string(body.data)))
+		}
+	}
+}
+
+// writeGolden writes data to path, or removes path if data is nil.
+func writeGolden(path string, data []byte) error {
+	if data == nil {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Diff returns a line diff turning a into b. Removed lines are prefixed
+// with "-", added lines with "+" and unchanged lines with a space; runs
+// of unchanged lines far from a change are elided.
+//
+// Parameters:
+//   - a: The old text.
+//   - b: The new text.
+//
+// Returns:
+//   - string: The diff, empty if a equals b.
+func Diff(a, b string) string {
+	if a == b {
+		return ""
+	}
+	x, y := splitLines(a), splitLines(b)
+	// lcs[i][j] is the length of the longest common subsequence of
+	// x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	type line struct {
+		op   byte
+		text string
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, line{' ', x[i]})
+			i, j = i+1, j+1
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', x[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', y[j]})
+			j++
+		}
+	}
+	const contextLines = 3
+	near := make([]bool, len(lines))
+	for k, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+		for n := max(0, k-contextLines); n <= min(len(lines)-1, k+contextLines); n++ {
+			near[n] = true
+		}
+	}
+	var sb strings.Builder
+	skipped := false
+	for k, l := range lines {
+		if !near[k] {
+			if !skipped {
+				sb.WriteString("  ...\n")
+				skipped = true
+			}
+			continue
+		}
+		skipped = false
+		fmt.Fprintf(&sb, "%c %s\n", l.op, l.text)
+	}
+	return sb.String()
+}
+
+// splitLines splits s into lines, marking a missing final newline so
+// that it shows up in diffs.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += " (no newline at end)"
+	return lines
+}
@@ -0,0 +1,80 @@
+package templatetest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/aatuh/email/v2"
+)
+
+// recorder is a testing.TB that records failures instead of failing.
+type recorder struct {
+	testing.TB
+	errs  []string
+	fatal bool
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+	r.fatal = true
+}
+
+func TestRenderGolden(t *testing.T) {
+	t.Chdir(t.TempDir())
+	mfs := fstest.MapFS{
+		"mail/receipt.txt.tmpl":  {Data: []byte("Hi {{.Name}},\nthanks.\n")},
+		"mail/receipt.html.tmpl": {Data: []byte("<p>Hi {{.Name}}</p>\n")},
+	}
+	ts := email.MustLoadTemplates(mfs)
+	data := map[string]any{"Name": "Ada"}
+
+	r := &recorder{TB: t}
+	RenderGolden(r, ts, "mail/receipt", data)
+	if len(r.errs) != 2 || !strings.Contains(r.errs[0], "missing golden file") {
+		t.Fatalf("missing goldens: %q", r.errs)
+	}
+
+	*update = true
+	RenderGolden(t, ts, "mail/receipt", data)
+	*update = false
+	b, err := os.ReadFile(filepath.Join("testdata", "mail", "receipt.txt.golden"))
+	if err != nil || string(b) != "Hi Ada,\nthanks.\n" {
+		t.Fatalf("golden: %q, %v", b, err)
+	}
+	RenderGolden(t, ts, "mail/receipt", data)
+
+	r = &recorder{TB: t}
+	RenderGolden(r, ts, "mail/receipt", map[string]any{"Name": "Bob"})
+	if len(r.errs) != 2 || !strings.Contains(r.errs[0], "- Hi Ada,\n+ Hi Bob,\n  thanks.\n") {
+		t.Fatalf("mismatch: %q", r.errs)
+	}
+
+	r = &recorder{TB: t}
+	RenderGolden(r, ts, "missing", data)
+	if !r.fatal {
+		t.Fatalf("render error not fatal: %q", r.errs)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	b := "1\n2\n3\n4\n5\nsix\n7\n8\n9\n10"
+	want := "  ...\n  3\n  4\n  5\n- 6\n+ six\n  7\n  8\n  9\n" +
+		"- 10\n+ 10 (no newline at end)\n"
+	if got := Diff(a, b); got != want {
+		t.Fatalf("diff:\n%s\nwant:\n%s", got, want)
+	}
+	if Diff(a, a) != "" {
+		t.Fatalf("equal texts differ")
+	}
+}
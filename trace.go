@@ -0,0 +1,31 @@
+package email
+
+import (
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// PrependTrace returns raw with fields inserted before its first
+// header, as a relay or forwarder adds its Received header. fields are
+// written in order, so pass the newest first. Existing headers,
+// including earlier Received fields, are kept byte for byte; raw is not
+// modified.
+//
+// Parameters:
+//   - raw: The message as received.
+//   - fields: The trace fields to add, e.g. a types.Received value.
+//
+// Returns:
+//   - []byte: The message with the fields prepended.
+//   - error: A *types.HeaderError if a field is invalid.
+func PrependTrace(raw []byte, fields ...types.HeaderField) ([]byte, error) {
+	var prefix []byte
+	for _, f := range fields {
+		if err := types.ValidateHeader(f.Name, f.Value); err != nil {
+			return nil, err
+		}
+		prefix = append(prefix, internal.FoldHeader(f.Name, f.Value)...)
+	}
+	out := make([]byte, 0, len(prefix)+len(raw))
+	return append(append(out, prefix...), raw...), nil
+}
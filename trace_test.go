@@ -0,0 +1,46 @@
+package email
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestPrependTrace(t *testing.T) {
+	date := time.Date(2026, 10, 13, 10, 0, 0, 0, time.UTC)
+	recv, err := types.Received{
+		From: "mail.example.org", FromTCP: "mail.example.org [192.0.2.1]",
+		By: "relay.example.com", With: "ESMTPS", ID: "4QxYz1", For: "Ada <ada@example.org>",
+		Date: date,
+	}.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "from mail.example.org (mail.example.org [192.0.2.1]) by relay.example.com " +
+		"with ESMTPS id 4QxYz1 for <ada@example.org>; Tue, 13 Oct 2026 10:00:00 +0000"
+	if recv != want {
+		t.Fatalf("Received = %q", recv)
+	}
+
+	raw := []byte("Received: from a by b; Mon, 12 Oct 2026 09:00:00 +0000\r\n" +
+		"Subject: hi\r\n\r\nbody\r\n")
+	out, err := PrependTrace(raw, types.HeaderField{Name: "Received", Value: recv})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(out), "\r\n")
+	if lines[0] != "Received: from mail.example.org (mail.example.org [192.0.2.1]) by" ||
+		!strings.HasSuffix(string(out), string(raw)) {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	if _, err := PrependTrace(raw, types.HeaderField{Name: "Received", Value: "x\r\nBcc: y"}); err == nil {
+		t.Fatal("expected header injection error")
+	}
+	for _, r := range []types.Received{{From: "a b"}, {By: "x;y"}, {FromTCP: "[192.0.2.1]"}, {For: "not an address"}} {
+		if _, err := r.Value(); err == nil {
+			t.Fatalf("expected error for %+v", r)
+		}
+	}
+}
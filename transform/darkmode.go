@@ -0,0 +1,121 @@
+package transform
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// DarkModeConfig configures DarkMode.
+type DarkModeConfig struct {
+	// CSS holds rules applied when the reader prefers a dark color
+	// scheme, e.g. ".card { background: #1e1e1e !important; }".
+	CSS string
+	// Images maps light image URLs, as written in <img src>, to their
+	// dark variants, e.g. a logo with light text for dark backgrounds.
+	Images map[string]string
+}
+
+// darkModeMeta declares that the message supports both color schemes,
+// so clients do not invert its colors on their own.
+const darkModeMeta = `<meta name="color-scheme" content="light dark">` +
+	`<meta name="supported-color-schemes" content="light dark">`
+
+// DarkMode returns a transform that makes the body dark-mode aware: it
+// adds the color-scheme meta tags to <head>, unless the body declares
+// them, and a style element with cfg.CSS inside a
+// prefers-color-scheme: dark media query. Each <img> whose src is in
+// cfg.Images is followed by its dark variant, and the query shows one
+// or the other; clients without media query support show the light
+// image.
+//
+// Parameters:
+//   - cfg: The dark mode settings.
+//
+// Returns:
+//   - types.BuildTransform: The transform.
+func DarkMode(cfg DarkModeConfig) types.BuildTransform {
+	return types.BuildTransformFunc(func(ctx context.Context, in []byte) ([]byte, error) {
+		if indexFold(cfg.CSS, "</style") >= 0 {
+			return nil, errors.New("dark mode CSS must not close the style element")
+		}
+		out, err := walk(string(in), func(b *strings.Builder, s string) (int, string, error) {
+			return darkTag(b, s, cfg.Images)
+		})
+		if err != nil {
+			return nil, err
+		}
+		var head strings.Builder
+		if indexFold(string(out), `name="color-scheme"`) < 0 {
+			head.WriteString(darkModeMeta)
+		}
+		head.WriteString("<style>:root{color-scheme:light dark;supported-color-schemes:light dark}")
+		head.WriteString("@media (prefers-color-scheme: dark){")
+		if len(cfg.Images) > 0 {
+			head.WriteString(".dm-light{display:none!important}.dm-dark{display:inline!important}")
+		}
+		head.WriteString(cfg.CSS + "}</style>")
+		return []byte(injectHead(string(out), head.String())), nil
+	})
+}
+
+// darkTag copies the start tag at the beginning of s like rewriteTag,
+// followed by a hidden dark copy if it is an <img> listed in images.
+func darkTag(b *strings.Builder, s string, images map[string]string) (int, string, error) {
+	var light strings.Builder
+	dark := ""
+	n, name, err := rewriteTag(&light, s, func(tag, attr string, u *url.URL) (*url.URL, error) {
+		if tag == "img" && attr == "src" {
+			dark = images[u.String()]
+		}
+		return u, nil
+	})
+	if err != nil || dark == "" {
+		b.WriteString(light.String())
+		return n, name, err
+	}
+	var alt strings.Builder
+	_, _, err = rewriteTag(&alt, s, func(tag, attr string, u *url.URL) (*url.URL, error) {
+		if tag == "img" && attr == "src" {
+			return url.Parse(dark)
+		}
+		return u, nil
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	b.WriteString(`<span class="dm-light">` + light.String() + `</span>`)
+	b.WriteString(`<span class="dm-dark" style="display:none;mso-hide:all">` +
+		alt.String() + `</span>`)
+	return n, name, nil
+}
+
+// injectHead inserts extra at the start of the <head> element, adding
+// one if the document has none.
+func injectHead(s, extra string) string {
+	for i := 0; ; {
+		j := indexFold(s[i:], "<head")
+		if j < 0 {
+			break
+		}
+		i += j + len("<head")
+		if i < len(s) && strings.IndexByte(" \t\r\n\f>", s[i]) >= 0 {
+			end := strings.IndexByte(s[i:], '>')
+			if end < 0 {
+				break
+			}
+			i += end + 1
+			return s[:i] + extra + s[i:]
+		}
+	}
+	if i := indexFold(s, "<html"); i >= 0 {
+		if end := strings.IndexByte(s[i:], '>'); end >= 0 {
+			i += end + 1
+			return s[:i] + "<head>" + extra + "</head>" + s[i:]
+		}
+	}
+	return extra + s
+}
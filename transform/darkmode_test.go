@@ -0,0 +1,46 @@
+package transform
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDarkMode(t *testing.T) {
+	tr := DarkMode(DarkModeConfig{
+		CSS:    ".card{background:#1e1e1e!important}",
+		Images: map[string]string{"https://cdn.example.com/logo.png": "https://cdn.example.com/logo-dark.png"},
+	})
+	in := `<html><HEAD><title>x</title></HEAD><body><header>h</header>` +
+		`<img class=logo src="https://cdn.example.com/logo.png" alt="Acme"><img src="/other.png"></body></html>`
+	got := apply(t, tr, in)
+	want := `<html><HEAD>` + darkModeMeta +
+		`<style>:root{color-scheme:light dark;supported-color-schemes:light dark}` +
+		`@media (prefers-color-scheme: dark){.dm-light{display:none!important}` +
+		`.dm-dark{display:inline!important}.card{background:#1e1e1e!important}}</style>` +
+		`<title>x</title></HEAD><body><header>h</header>` +
+		`<span class="dm-light"><img class=logo src="https://cdn.example.com/logo.png" alt="Acme"></span>` +
+		`<span class="dm-dark" style="display:none;mso-hide:all">` +
+		`<img class=logo src="https://cdn.example.com/logo-dark.png" alt="Acme"></span>` +
+		`<img src="/other.png"></body></html>`
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+
+	// Fragments get a head; declared color schemes are kept.
+	got = apply(t, DarkMode(DarkModeConfig{}), `<p>hi</p>`)
+	if !strings.HasPrefix(got, darkModeMeta+"<style>") || !strings.HasSuffix(got, "}</style><p>hi</p>") {
+		t.Fatalf("fragment: %s", got)
+	}
+	in = `<html lang="en"><body><meta name="color-scheme" content="dark"></body></html>`
+	got = apply(t, DarkMode(DarkModeConfig{}), in)
+	if strings.Contains(got, "supported-color-schemes\" content") ||
+		!strings.HasPrefix(got, `<html lang="en"><head><style>`) {
+		t.Fatalf("declared scheme: %s", got)
+	}
+
+	bad := DarkMode(DarkModeConfig{CSS: "</style><script>"})
+	if _, err := bad.TransformHTML(context.Background(), []byte(in)); err == nil {
+		t.Fatalf("expected CSS error")
+	}
+}
@@ -0,0 +1,9 @@
+// Package transform provides HTML body transforms for
+// email.WithTransform. RewriteURLs visits every URL attribute (href,
+// src, background, ...) in start tags and leaves the rest of the
+// document untouched; HTTPS, UTM, ResolveRelative and Fingerprint build
+// on it for the common cases of enforcing https, tagging links with
+// campaign parameters, making relative image URLs absolute, and
+// pointing images at fingerprinted asset URLs. DarkMode adds
+// prefers-color-scheme styles and dark variants of images.
+package transform
@@ -0,0 +1,330 @@
+package transform
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// URLFunc returns the replacement for the URL u found in attribute attr
+// of element tag (both lower case). Returning u unchanged keeps the
+// original attribute text.
+type URLFunc func(tag, attr string, u *url.URL) (*url.URL, error)
+
+// urlAttributes are the attributes holding a URL.
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "background": true, "action": true,
+	"cite": true, "poster": true, "longdesc": true,
+}
+
+// rawElements have content that is not markup.
+var rawElements = []string{"script", "style", "textarea", "title", "xmp"}
+
+var attrEscaper = strings.NewReplacer("&", "&amp;", `"`, "&quot;", "<", "&lt;", ">", "&gt;")
+
+// RewriteURLs returns a transform calling fn for each parseable URL
+// attribute. Unparseable URLs are left as they are. Comments and the
+// content of script and style elements are skipped.
+//
+// Parameters:
+//   - fn: The rewrite function.
+//
+// Returns:
+//   - types.BuildTransform: The transform.
+func RewriteURLs(fn URLFunc) types.BuildTransform {
+	return types.BuildTransformFunc(func(ctx context.Context, in []byte) ([]byte, error) {
+		return rewrite(string(in), fn)
+	})
+}
+
+// HTTPS returns a transform rewriting http:// URLs to https://.
+//
+// Returns:
+//   - types.BuildTransform: The transform.
+func HTTPS() types.BuildTransform {
+	return RewriteURLs(func(tag, attr string, u *url.URL) (*url.URL, error) {
+		if u.Scheme != "http" {
+			return u, nil
+		}
+		v := *u
+		v.Scheme = "https"
+		return &v, nil
+	})
+}
+
+// UTM returns a transform adding params (e.g. utm_source, utm_medium,
+// utm_campaign) to the query of absolute http(s) links in <a> and
+// <area>. Parameters a link already has are kept. If hosts is not
+// empty, only links to those hosts are tagged.
+//
+// Parameters:
+//   - params: The query parameters to add.
+//   - hosts: Hosts to limit tagging to, empty for all.
+//
+// Returns:
+//   - types.BuildTransform: The transform.
+func UTM(params url.Values, hosts ...string) types.BuildTransform {
+	return RewriteURLs(func(tag, attr string, u *url.URL) (*url.URL, error) {
+		if attr != "href" || (tag != "a" && tag != "area") ||
+			(u.Scheme != "http" && u.Scheme != "https") {
+			return u, nil
+		}
+		if len(hosts) > 0 && !containsFold(hosts, u.Hostname()) {
+			return u, nil
+		}
+		q := u.Query()
+		changed := false
+		for k, vs := range params {
+			if _, ok := q[k]; !ok && len(vs) > 0 {
+				q[k] = vs
+				changed = true
+			}
+		}
+		if !changed {
+			return u, nil
+		}
+		v := *u
+		v.RawQuery = q.Encode()
+		return &v, nil
+	})
+}
+
+// ResolveRelative returns a transform resolving relative URLs, such as
+// "/img/logo.png", against base. Fragment-only links ("#top") are kept.
+//
+// Parameters:
+//   - base: The absolute base URL.
+//
+// Returns:
+//   - types.BuildTransform: The transform.
+func ResolveRelative(base *url.URL) types.BuildTransform {
+	return RewriteURLs(func(tag, attr string, u *url.URL) (*url.URL, error) {
+		if u.IsAbs() || *u == (url.URL{Fragment: u.Fragment, RawFragment: u.RawFragment}) {
+			return u, nil
+		}
+		return base.ResolveReference(u), nil
+	})
+}
+
+// imageAttributes are the URL attributes Fingerprint rewrites.
+var imageAttributes = map[string]bool{"src": true, "background": true, "poster": true}
+
+// Fingerprint returns a transform replacing image URLs with the
+// fingerprinted ones in manifest, e.g. "img/logo.png" to
+// "https://cdn.example.com/img/logo.3f9a2c.png", so a rebrand does not
+// leave caches serving the old logo in new mail. Keys are absolute
+// URLs, matched without query and fragment, or paths, matched against
+// relative URLs with or without the leading slash; run it before
+// ResolveRelative. Only src, background and poster are rewritten, not
+// links.
+//
+// Parameters:
+//   - manifest: The logical URL or path to fingerprinted URL map.
+//
+// Returns:
+//   - types.BuildTransform: The transform.
+func Fingerprint(manifest map[string]string) types.BuildTransform {
+	return RewriteURLs(func(tag, attr string, u *url.URL) (*url.URL, error) {
+		if !imageAttributes[attr] {
+			return u, nil
+		}
+		var keys []string
+		if u.IsAbs() {
+			v := *u
+			v.RawQuery, v.Fragment, v.RawFragment = "", "", ""
+			keys = []string{v.String()}
+		} else if u.Path != "" {
+			keys = []string{u.Path, strings.TrimPrefix(u.Path, "/"), "/" + u.Path}
+		}
+		for _, k := range keys {
+			if hashed, ok := manifest[k]; ok {
+				r, err := url.Parse(hashed)
+				if err != nil {
+					return nil, fmt.Errorf("manifest entry %q: %w", k, err)
+				}
+				return r, nil
+			}
+		}
+		return u, nil
+	})
+}
+
+// rewrite copies s, replacing URL attribute values changed by fn.
+func rewrite(s string, fn URLFunc) ([]byte, error) {
+	return walk(s, func(b *strings.Builder, s string) (int, string, error) {
+		return rewriteTag(b, s, fn)
+	})
+}
+
+// walk copies s, letting tag copy each start tag at the beginning of
+// its argument; tag returns the bytes consumed and the lower-cased
+// name, as rewriteTag does.
+func walk(s string, tag func(b *strings.Builder, s string) (int, string, error)) ([]byte, error) {
+	var b strings.Builder
+	b.Grow(len(s))
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			b.WriteString(s)
+			break
+		}
+		b.WriteString(s[:i])
+		s = s[i:]
+		if strings.HasPrefix(s, "<!--") {
+			n := copyPast(&b, s, "-->")
+			s = s[n:]
+			continue
+		}
+		if len(s) < 2 || !isLetter(s[1]) {
+			b.WriteByte('<')
+			s = s[1:]
+			continue
+		}
+		n, name, err := tag(&b, s)
+		if err != nil {
+			return nil, err
+		}
+		s = s[n:]
+		for _, raw := range rawElements {
+			if name == raw {
+				end := indexFold(s, "</"+raw)
+				if end < 0 {
+					end = len(s)
+				}
+				b.WriteString(s[:end])
+				s = s[end:]
+			}
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+// rewriteTag copies the start tag at the beginning of s, rewriting URL
+// attributes. It returns the bytes consumed and the lower-cased name.
+func rewriteTag(b *strings.Builder, s string, fn URLFunc) (int, string, error) {
+	pos := 1
+	for pos < len(s) && strings.IndexByte(" \t\r\n\f/>", s[pos]) < 0 {
+		pos++
+	}
+	name := strings.ToLower(s[1:pos])
+	copied := 0 // s[:copied] has been written to b
+	for pos < len(s) {
+		c := s[pos]
+		if c == '>' {
+			pos++
+			break
+		}
+		if strings.IndexByte(" \t\r\n\f/", c) >= 0 {
+			pos++
+			continue
+		}
+		nend := pos + 1
+		for nend < len(s) && strings.IndexByte(" \t\r\n\f/>=", s[nend]) < 0 {
+			nend++
+		}
+		attr := strings.ToLower(s[pos:nend])
+		pos = skipSpace(s, nend)
+		if pos >= len(s) || s[pos] != '=' {
+			continue
+		}
+		pos = skipSpace(s, pos+1)
+		if pos >= len(s) {
+			break
+		}
+		vq := pos // value start, including any quote
+		vstart, vend, next := pos, pos, pos
+		if q := s[pos]; q == '"' || q == '\'' {
+			vstart++
+			i := strings.IndexByte(s[vstart:], q)
+			if i < 0 {
+				pos = len(s)
+				break
+			}
+			vend, next = vstart+i, vstart+i+1
+		} else {
+			for vend < len(s) && strings.IndexByte(" \t\r\n\f>", s[vend]) < 0 {
+				vend++
+			}
+			next = vend
+		}
+		pos = next
+		if !urlAttributes[attr] {
+			continue
+		}
+		raw := html.UnescapeString(s[vstart:vend])
+		repl, err := rewriteURL(name, attr, raw, fn)
+		if err != nil {
+			return 0, "", err
+		}
+		if repl != raw {
+			b.WriteString(s[copied:vq])
+			b.WriteString(`"` + attrEscaper.Replace(repl) + `"`)
+			copied = next
+		}
+	}
+	b.WriteString(s[copied:pos])
+	return pos, name, nil
+}
+
+// rewriteURL applies fn to raw, returning raw if it does not parse or
+// fn leaves it unchanged.
+func rewriteURL(tag, attr, raw string, fn URLFunc) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return raw, nil
+	}
+	orig := *u
+	v, err := fn(tag, attr, u)
+	if err != nil {
+		return "", fmt.Errorf("rewrite %s %s %q: %w", tag, attr, raw, err)
+	}
+	if v == nil || *v == orig {
+		return raw, nil
+	}
+	return v.String(), nil
+}
+
+// copyPast writes s up to and including sep, or all of s, returning the
+// bytes written.
+func copyPast(b *strings.Builder, s, sep string) int {
+	n := len(s)
+	if i := strings.Index(s, sep); i >= 0 {
+		n = i + len(sep)
+	}
+	b.WriteString(s[:n])
+	return n
+}
+
+// indexFold is strings.Index ignoring case, for an ASCII substr.
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && strings.IndexByte(" \t\r\n\f", s[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,107 @@
+package transform
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+func apply(t *testing.T, tr types.BuildTransform, in string) string {
+	t.Helper()
+	out, err := tr.TransformHTML(context.Background(), []byte(in))
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	return string(out)
+}
+
+func TestHTTPS(t *testing.T) {
+	in := `<a class=x href="http://example.com/a?b=1&amp;c=2">x</a>` +
+		`<img SRC=http://cdn.example.com/i.png alt='y'>` +
+		`<a href="mailto:a@example.com">m</a>` +
+		`<!-- <a href="http://example.com/c"> -->` +
+		`<script>var u = '<a href="http://example.com/s">';</script>` +
+		`<p>http://example.com/text</p>`
+	want := `<a class=x href="https://example.com/a?b=1&amp;c=2">x</a>` +
+		`<img SRC="https://cdn.example.com/i.png" alt='y'>` +
+		`<a href="mailto:a@example.com">m</a>` +
+		`<!-- <a href="http://example.com/c"> -->` +
+		`<script>var u = '<a href="http://example.com/s">';</script>` +
+		`<p>http://example.com/text</p>`
+	if got := apply(t, HTTPS(), in); got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestUTM(t *testing.T) {
+	tr := UTM(url.Values{
+		"utm_source": {"newsletter"},
+		"utm_medium": {"email"},
+	}, "example.com")
+	in := `<a href="https://example.com/p?utm_source=keep">a</a>` +
+		`<a href="https://other.example/">b</a>` +
+		`<img src="https://example.com/i.png">` +
+		`<a href="#top">c</a>`
+	want := `<a href="https://example.com/p?utm_medium=email&amp;utm_source=keep">a</a>` +
+		`<a href="https://other.example/">b</a>` +
+		`<img src="https://example.com/i.png">` +
+		`<a href="#top">c</a>`
+	if got := apply(t, tr, in); got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestResolveRelative(t *testing.T) {
+	base, _ := url.Parse("https://example.com/news/2024/")
+	in := `<img src="/img/logo.png"><img src="pic.jpg"><img src="cid:logo">` +
+		`<a href="#top">t</a><a href="https://x.example/">x</a><td background=bg.png>`
+	want := `<img src="https://example.com/img/logo.png">` +
+		`<img src="https://example.com/news/2024/pic.jpg"><img src="cid:logo">` +
+		`<a href="#top">t</a><a href="https://x.example/">x</a>` +
+		`<td background="https://example.com/news/2024/bg.png">`
+	if got := apply(t, ResolveRelative(base), in); got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	tr := Fingerprint(map[string]string{
+		"img/logo.png":                     "https://cdn.example.com/img/logo.3f9a2c.png",
+		"https://www.example.com/hero.jpg": "https://cdn.example.com/hero.81bc0d.jpg",
+	})
+	in := `<img src="/img/logo.png"><img src="img/logo.png?v=1">` +
+		`<img src="https://www.example.com/hero.jpg?w=600"><td background=img/logo.png>` +
+		`<a href="/img/logo.png">l</a><img src="/img/other.png">`
+	want := `<img src="https://cdn.example.com/img/logo.3f9a2c.png">` +
+		`<img src="https://cdn.example.com/img/logo.3f9a2c.png">` +
+		`<img src="https://cdn.example.com/hero.81bc0d.jpg">` +
+		`<td background="https://cdn.example.com/img/logo.3f9a2c.png">` +
+		`<a href="/img/logo.png">l</a><img src="/img/other.png">`
+	if got := apply(t, tr, in); got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+
+	bad := Fingerprint(map[string]string{"logo.png": "https://cdn.example.com/%zz"})
+	if _, err := bad.TransformHTML(context.Background(), []byte(`<img src="logo.png">`)); err == nil {
+		t.Fatalf("expected manifest error")
+	}
+}
+
+func TestRewriteURLsError(t *testing.T) {
+	boom := errors.New("boom")
+	tr := RewriteURLs(func(tag, attr string, u *url.URL) (*url.URL, error) {
+		return nil, boom
+	})
+	if _, err := tr.TransformHTML(context.Background(), []byte(`<a href="/x">`)); !errors.Is(err, boom) {
+		t.Fatalf("expected wrapped error, got %v", err)
+	}
+	// Malformed markup passes through unchanged.
+	for _, in := range []string{`<a href="x`, `a < b`, `<`, `<a`, `</a>`} {
+		if got := apply(t, HTTPS(), in); got != in {
+			t.Fatalf("got %q, want %q", got, in)
+		}
+	}
+}
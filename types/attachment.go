@@ -0,0 +1,74 @@
+package types
+
+import (
+	"path"
+	"strings"
+)
+
+// DefaultBlockedExtensions lists executable and script extensions that
+// mail clients may run when opened.
+var DefaultBlockedExtensions = []string{
+	".ade", ".adp", ".app", ".bat", ".chm", ".cmd", ".com", ".cpl",
+	".dll", ".exe", ".hta", ".inf", ".ins", ".isp", ".jar", ".js",
+	".jse", ".lib", ".lnk", ".mde", ".msc", ".msi", ".msp", ".mst",
+	".pif", ".ps1", ".reg", ".scr", ".sct", ".shb", ".sys", ".vb",
+	".vbe", ".vbs", ".vxd", ".wsc", ".wsf", ".wsh",
+}
+
+// bidiControls are the Unicode bidirectional formatting characters.
+const bidiControls = "\u200e\u200f\u202a\u202b\u202c\u202d\u202e" +
+	"\u2066\u2067\u2068\u2069"
+
+// AttachmentPolicy decides which attachment filenames may be sent. It
+// is checked before the message is built. Names containing bidi
+// control characters, often used to disguise an extension, are always
+// rejected.
+type AttachmentPolicy struct {
+	// BlockedExtensions are rejected case-insensitively, e.g. ".exe".
+	BlockedExtensions []string
+	// BlockDoubleExtensions rejects names with more than one extension,
+	// such as "invoice.pdf.exe" or "photo.jpg.zip".
+	BlockDoubleExtensions bool
+}
+
+// DefaultAttachmentPolicy returns a policy blocking
+// DefaultBlockedExtensions and double extensions.
+//
+// Returns:
+//   - AttachmentPolicy: The policy.
+func DefaultAttachmentPolicy() AttachmentPolicy {
+	return AttachmentPolicy{
+		BlockedExtensions:     DefaultBlockedExtensions,
+		BlockDoubleExtensions: true,
+	}
+}
+
+// Check validates filename against the policy. Trailing dots and
+// spaces, which Windows ignores, are stripped before the extension is
+// taken.
+//
+// Parameters:
+//   - filename: The attachment filename.
+//
+// Returns:
+//   - error: An *AttachmentError if the name is rejected.
+func (p AttachmentPolicy) Check(filename string) error {
+	reject := func(reason string) error {
+		return &AttachmentError{Filename: filename, Reason: reason}
+	}
+	if strings.ContainsAny(filename, bidiControls) {
+		return reject("bidi control character in name")
+	}
+	name := strings.TrimRight(path.Base(strings.ReplaceAll(filename, `\`, "/")), ". ")
+	ext := strings.ToLower(path.Ext(name))
+	for _, b := range p.BlockedExtensions {
+		if ext != "" && strings.EqualFold(ext, b) {
+			return reject("blocked extension " + ext)
+		}
+	}
+	stem := strings.TrimLeft(strings.TrimSuffix(name, path.Ext(name)), ".")
+	if p.BlockDoubleExtensions && path.Ext(stem) != "" {
+		return reject("double extension")
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package types
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAttachmentPolicyCheck(t *testing.T) {
+	p := DefaultAttachmentPolicy()
+	for _, n := range []string{"report.pdf", "photo.JPG", "README", ".profile", ""} {
+		if err := p.Check(n); err != nil {
+			t.Fatalf("expected %q to pass, got %v", n, err)
+		}
+	}
+	for _, n := range []string{
+		"setup.exe", "SCRIPT.JS", "run.vbs", "invoice.pdf.zip",
+		"evil.exe.", "evil.exe ", `C:\tmp\evil.bat`, "gnp\u202eexe.png",
+	} {
+		err := p.Check(n)
+		var ae *AttachmentError
+		if !errors.As(err, &ae) || ae.Filename != n {
+			t.Fatalf("expected AttachmentError for %q, got %v", n, err)
+		}
+	}
+
+	p.BlockDoubleExtensions = false
+	if err := p.Check("archive.tar.gz"); err != nil {
+		t.Fatalf("expected double extension to pass, got %v", err)
+	}
+	if err := p.Check("invoice.pdf.exe"); err == nil {
+		t.Fatalf("expected blocked final extension to fail")
+	}
+	if err := (AttachmentPolicy{}).Check("setup.exe"); err != nil {
+		t.Fatalf("zero policy should allow everything: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package types
+
+import (
+	"io"
+	"slices"
+)
+
+// ReaderFactory returns a new reader over the content of an attachment
+// each time it is called; see FactoryReader.
+type ReaderFactory func() (io.Reader, error)
+
+// FactoryReader is an Attachment.Reader that calls New on the first read
+// and reads from the result, closing it at EOF if it is an io.Closer.
+// Clone gives each copy of a message its own FactoryReader, so every
+// copy reads the content from the start, e.g. from object storage.
+type FactoryReader struct {
+	New ReaderFactory
+
+	r io.Reader
+}
+
+// NewFactoryReader returns a reader for the content opened by f.
+//
+// Parameters:
+//   - f: The reader factory.
+//
+// Returns:
+//   - *FactoryReader: The reader.
+func NewFactoryReader(f ReaderFactory) *FactoryReader {
+	return &FactoryReader{New: f}
+}
+
+// Read reads from the reader returned by New, calling it on the first
+// call.
+//
+// Parameters:
+//   - p: The buffer to read into.
+//
+// Returns:
+//   - int: The number of bytes read.
+//   - error: io.EOF at the end, or the error from New or the reader.
+func (r *FactoryReader) Read(p []byte) (int, error) {
+	if r.r == nil {
+		rd, err := r.New()
+		if err != nil {
+			return 0, err
+		}
+		r.r = rd
+	}
+	n, err := r.r.Read(p)
+	if err != nil {
+		if c, ok := r.r.(io.Closer); ok {
+			c.Close()
+		}
+	}
+	return n, err
+}
+
+// sizedReaderAt is implemented by in-memory readers such as
+// *bytes.Reader and *strings.Reader.
+type sizedReaderAt interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// Clone returns a deep copy of m: slices, bodies and headers are
+// copied, so the copy can be changed and sent on another goroutine
+// without affecting m. Attachment readers that can start over get a
+// reader of their own in the copy, positioned at the start of the
+// content: *FileReader, *FactoryReader, and readers with ReadAt and
+// Size such as *bytes.Reader. Any other reader is shared, so only one
+// of the messages may be sent.
+//
+// Returns:
+//   - Message: The copy.
+func (m *Message) Clone() Message {
+	c := *m
+	c.To = slices.Clone(m.To)
+	c.Cc = slices.Clone(m.Cc)
+	c.Bcc = slices.Clone(m.Bcc)
+	c.ReplyTo = slices.Clone(m.ReplyTo)
+	c.ExtraFrom = slices.Clone(m.ExtraFrom)
+	c.Plain = slices.Clone(m.Plain)
+	c.HTML = slices.Clone(m.HTML)
+	c.Headers = slices.Clone(m.Headers)
+	c.References = slices.Clone(m.References)
+	c.EnvelopeTo = slices.Clone(m.EnvelopeTo)
+	c.Attach = slices.Clone(m.Attach)
+	for i, a := range c.Attach {
+		switch r := a.Reader.(type) {
+		case *FileReader:
+			c.Attach[i].Reader = NewFileReader(r.Path)
+		case *FactoryReader:
+			c.Attach[i].Reader = NewFactoryReader(r.New)
+		case sizedReaderAt:
+			c.Attach[i].Reader = io.NewSectionReader(r, 0, r.Size())
+		}
+	}
+	return c
+}
+
+// WithBase returns a clone of base with stamp applied, so bulk jobs can
+// fill in per-recipient fields on a shared base message without
+// changing it:
+//
+//	msg := types.WithBase(base, func(m *types.Message) {
+//		m.To = []types.Address{{Mail: u.Email}}
+//		m.Headers.Set("X-Customer-ID", u.ID)
+//	})
+//
+// Parameters:
+//   - base: The base message.
+//   - stamp: The function setting the per-message fields, may be nil.
+//
+// Returns:
+//   - Message: The new message.
+func WithBase(base Message, stamp func(m *Message)) Message {
+	m := base.Clone()
+	if stamp != nil {
+		stamp(&m)
+	}
+	return m
+}
@@ -0,0 +1,84 @@
+package types
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMessageClone(t *testing.T) {
+	opened := 0
+	base := Message{
+		From:    Address{Mail: "app@example.com"},
+		To:      make([]Address, 1, 4),
+		Plain:   []byte("hello"),
+		Headers: make(Headers, 0, 4),
+		Attach: []Attachment{
+			{Filename: "a.txt", Reader: bytes.NewReader([]byte("inline"))},
+			{Filename: "b.txt", Reader: NewFactoryReader(func() (io.Reader, error) {
+				opened++
+				return strings.NewReader("factory"), nil
+			})},
+		},
+	}
+	base.To[0] = Address{Mail: "first@example.org"}
+	base.Headers.Add("X-Campaign", "spring")
+
+	c := base.Clone()
+	c.To[0].Mail = "changed@example.org"
+	c.To = append(c.To, Address{Mail: "extra@example.org"})
+	c.Plain[0] = 'J'
+	c.Headers.Add("X-Customer", "42")
+	c.Attach[0].Filename = "renamed.txt"
+	if base.To[0].Mail != "first@example.org" || string(base.Plain) != "hello" ||
+		len(base.Headers) != 1 || base.Attach[0].Filename != "a.txt" {
+		t.Fatalf("clone changed the base: %+v", base)
+	}
+	if got := base.To[:2][1].Mail; got != "" {
+		t.Fatalf("append to the clone wrote into the base's array: %q", got)
+	}
+
+	for _, m := range []Message{base, c} {
+		for i, want := range []string{"inline", "factory"} {
+			b, err := io.ReadAll(m.Attach[i].Reader)
+			if err != nil || string(b) != want {
+				t.Fatalf("attachment %d: %q %v", i, b, err)
+			}
+		}
+	}
+	if opened != 2 {
+		t.Fatalf("factory called %d times, want 2", opened)
+	}
+}
+
+func TestWithBaseConcurrent(t *testing.T) {
+	base := Message{
+		From:    Address{Mail: "app@example.com"},
+		Plain:   []byte("hello"),
+		Headers: make(Headers, 0, 8),
+	}
+	base.Headers.Add("X-Campaign", "spring")
+	var wg sync.WaitGroup
+	msgs := make([]Message, 8)
+	for i := range msgs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msgs[i] = WithBase(base, func(m *Message) {
+				m.To = []Address{{Mail: string(rune('a'+i)) + "@example.org"}}
+				m.Headers.Add("X-Index", string(rune('0'+i)))
+			})
+		}()
+	}
+	wg.Wait()
+	for i, m := range msgs {
+		if len(m.Headers) != 2 || m.Headers.Get("X-Index") != string(rune('0'+i)) {
+			t.Fatalf("message %d headers: %+v", i, m.Headers)
+		}
+	}
+	if len(base.Headers) != 1 {
+		t.Fatalf("base headers: %+v", base.Headers)
+	}
+}
@@ -0,0 +1,207 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sentinel errors for branching with errors.Is. Message.Validate returns
+// the first four; HeaderError and SizeError match the others.
+var (
+	ErrMissingFrom        = errors.New("missing From")
+	ErrMissingSender      = errors.New("multiple From addresses require Sender")
+	ErrNoRecipients       = errors.New("no recipients")
+	ErrNoBody             = errors.New("no body or attachments")
+	ErrInvalidHeader      = errors.New("invalid header")
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+	ErrMessageTooLarge    = errors.New("message too large")
+)
+
+// SizeError reports that a message or an attachment exceeded its
+// configured size limit. Sizes are in encoded bytes.
+type SizeError struct {
+	Attachment string // attachment filename; empty for the whole message
+	Size       int64  // bytes written when the limit was hit
+	Limit      int64  // configured limit
+}
+
+// Error implements the error interface.
+//
+// Returns:
+//   - string: The error message.
+func (e *SizeError) Error() string {
+	if e.Attachment != "" {
+		return fmt.Sprintf("attachment %q exceeds size limit of %d bytes",
+			e.Attachment, e.Limit)
+	}
+	return fmt.Sprintf("message exceeds size limit of %d bytes", e.Limit)
+}
+
+// Is matches ErrAttachmentTooLarge if an attachment exceeded its limit
+// and ErrMessageTooLarge otherwise.
+//
+// Parameters:
+//   - target: The error to compare with.
+//
+// Returns:
+//   - bool: True if e matches target.
+func (e *SizeError) Is(target error) bool {
+	if e.Attachment != "" {
+		return target == ErrAttachmentTooLarge
+	}
+	return target == ErrMessageTooLarge
+}
+
+// AttachmentError reports an attachment rejected by an AttachmentPolicy.
+type AttachmentError struct {
+	Filename string // attachment filename
+	Reason   string // human readable reason
+}
+
+// Error implements the error interface.
+//
+// Returns:
+//   - string: The error message.
+func (e *AttachmentError) Error() string {
+	return fmt.Sprintf("attachment %q rejected: %s", e.Filename, e.Reason)
+}
+
+// MalwareError reports an attachment in which an AttachmentScanner
+// found a threat.
+type MalwareError struct {
+	Filename string // attachment filename
+	Threat   string // threat name reported by the scanner
+}
+
+// Error implements the error interface.
+//
+// Returns:
+//   - string: The error message.
+func (e *MalwareError) Error() string {
+	return fmt.Sprintf("attachment %q infected: %s", e.Filename, e.Threat)
+}
+
+// APIError is an error response from an HTTP API adapter.
+type APIError struct {
+	Provider   string // adapter name, e.g. "postmark"
+	StatusCode int    // HTTP status code
+	Code       string // provider error code, if any
+	Message    string // provider error message
+}
+
+// Error implements the error interface.
+//
+// Returns:
+//   - string: The error message.
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s: HTTP %d: %s (code %s)", e.Provider,
+			e.StatusCode, e.Message, e.Code)
+	}
+	return fmt.Sprintf("%s: HTTP %d: %s", e.Provider, e.StatusCode,
+		e.Message)
+}
+
+// Temporary reports whether retrying the request may succeed: the
+// provider was rate limiting (429) or failing (5xx).
+//
+// Returns:
+//   - bool: True if the error is temporary.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == 429 || e.StatusCode >= 500
+}
+
+// SMTPError reports a failed SMTP command. Code is 0 if the command
+// failed without a reply, e.g. because the connection broke.
+type SMTPError struct {
+	Command   string // e.g. "MAIL FROM", "RCPT TO", "DATA", "end data"
+	Recipient string // the address, for RCPT TO
+	Code      int    // reply code, e.g. 550
+	Message   string // reply text; lines of a multi-line reply joined by "\n"
+	Err       error  // the underlying error, e.g. *textproto.Error
+}
+
+// Error implements the error interface. With a reply, it gives the
+// code and every line of the reply text, so multi-line replies such as
+// a blocklist notice with a lookup URL are not cut short.
+//
+// Returns:
+//   - string: The error message.
+func (e *SMTPError) Error() string {
+	cmd := e.Command
+	if e.Recipient != "" {
+		cmd += " " + e.Recipient
+	}
+	if e.Code == 0 {
+		return fmt.Sprintf("smtp %s: %v", cmd, e.Err)
+	}
+	return fmt.Sprintf("smtp %s: %d %s", cmd, e.Code, strings.Join(e.Lines(), " "))
+}
+
+// Lines returns the lines of the reply text, without the reply code;
+// a multi-line reply has one entry per line.
+//
+// Returns:
+//   - []string: The reply lines, or nil without a reply.
+func (e *SMTPError) Lines() []string {
+	if e.Message == "" {
+		return nil
+	}
+	return strings.Split(e.Message, "\n")
+}
+
+// Reply returns the reply as the server sent it, with the code on
+// every line and continuation lines marked with "-" (RFC 5321 4.2.1).
+//
+// Returns:
+//   - string: The reply lines joined with CRLF, or "" without a reply.
+func (e *SMTPError) Reply() string {
+	lines := e.Lines()
+	var b strings.Builder
+	for i, l := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		fmt.Fprintf(&b, "%03d%s%s", e.Code, sep, l)
+	}
+	return b.String()
+}
+
+// Unwrap returns the underlying error.
+//
+// Returns:
+//   - error: The underlying error.
+func (e *SMTPError) Unwrap() error { return e.Err }
+
+// Temporary reports whether the server replied with a 4xx code, so a
+// later attempt may succeed.
+//
+// Returns:
+//   - bool: True if the error is temporary.
+func (e *SMTPError) Temporary() bool {
+	return e.Code >= 400 && e.Code < 500
+}
+
+// EnhancedCode returns the RFC 3463 enhanced status code at the start
+// of Message, e.g. "5.1.1", or "" if there is none.
+//
+// Returns:
+//   - string: The enhanced status code.
+func (e *SMTPError) EnhancedCode() string {
+	code, _, _ := strings.Cut(e.Message, " ")
+	parts := strings.Split(code, ".")
+	if len(parts) != 3 || len(parts[0]) != 1 || !strings.ContainsAny(parts[0], "245") {
+		return ""
+	}
+	for _, p := range parts[1:] {
+		if p == "" || len(p) > 3 || strings.Trim(p, "0123456789") != "" {
+			return ""
+		}
+	}
+	return code
+}
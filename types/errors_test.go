@@ -0,0 +1,76 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestValidateSentinels(t *testing.T) {
+	ok := Message{From: Address{Mail: "a@x.com"}, To: []Address{{Mail: "b@x.com"}}, Plain: []byte("hi")}
+	tests := []struct {
+		edit func(*Message)
+		want error
+	}{
+		{func(m *Message) { m.From = Address{} }, ErrMissingFrom},
+		{func(m *Message) { m.ExtraFrom = []Address{{Mail: "c@x.com"}} }, ErrMissingSender},
+		{func(m *Message) { m.To = nil }, ErrNoRecipients},
+		{func(m *Message) { m.Plain = nil }, ErrNoBody},
+		{func(m *Message) { m.Subject = "x"; m.Headers.Add("X-Bad", "a\r\nb") }, ErrInvalidHeader},
+	}
+	for i, tt := range tests {
+		m := ok
+		m.Headers = nil
+		tt.edit(&m)
+		if err := m.Validate(); !errors.Is(err, tt.want) {
+			t.Fatalf("case %d: got %v, want %v", i, err, tt.want)
+		}
+	}
+}
+
+func TestSizeErrorIs(t *testing.T) {
+	att := fmt.Errorf("build: %w", &SizeError{Attachment: "a.pdf", Limit: 10})
+	if !errors.Is(att, ErrAttachmentTooLarge) || errors.Is(att, ErrMessageTooLarge) {
+		t.Fatalf("attachment size error matched wrongly")
+	}
+	if msg := error(&SizeError{Limit: 10}); !errors.Is(msg, ErrMessageTooLarge) || errors.Is(msg, ErrAttachmentTooLarge) {
+		t.Fatalf("message size error matched wrongly")
+	}
+}
+
+func TestSMTPError(t *testing.T) {
+	inner := errors.New("451 4.7.1 try later")
+	e := &SMTPError{Command: "MAIL FROM", Code: 451, Message: "4.7.1 try later", Err: inner}
+	if e.Error() != "smtp MAIL FROM: 451 4.7.1 try later" || !errors.Is(e, inner) ||
+		!e.Temporary() || e.EnhancedCode() != "4.7.1" {
+		t.Fatalf("unexpected %q %v %q", e, e.Temporary(), e.EnhancedCode())
+	}
+	for _, msg := range []string{"", "mailbox busy", "5.1 x", "6.1.1 x", "5.a.1 x"} {
+		if got := (&SMTPError{Message: msg}).EnhancedCode(); got != "" {
+			t.Fatalf("EnhancedCode(%q) = %q", msg, got)
+		}
+	}
+}
+
+func TestSMTPErrorMultiLine(t *testing.T) {
+	e := &SMTPError{
+		Command: "RCPT TO", Recipient: "ada@example.org", Code: 554,
+		Message: "5.7.1 Service unavailable; blocked using zen.spamhaus.org\n" +
+			"5.7.1 see https://check.spamhaus.org/",
+	}
+	if e.Error() != "smtp RCPT TO ada@example.org: 554 5.7.1 Service unavailable; "+
+		"blocked using zen.spamhaus.org 5.7.1 see https://check.spamhaus.org/" {
+		t.Fatalf("unexpected error %q", e)
+	}
+	if len(e.Lines()) != 2 {
+		t.Fatalf("lines = %q", e.Lines())
+	}
+	want := "554-5.7.1 Service unavailable; blocked using zen.spamhaus.org\r\n" +
+		"554 5.7.1 see https://check.spamhaus.org/"
+	if e.Reply() != want {
+		t.Fatalf("reply = %q", e.Reply())
+	}
+	if (&SMTPError{Command: "DATA", Err: errors.New("EOF")}).Reply() != "" {
+		t.Fatalf("expected no reply")
+	}
+}
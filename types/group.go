@@ -0,0 +1,214 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+)
+
+// UndisclosedRecipients is the conventional name of the empty group
+// written as To when a message only has Bcc recipients (RFC 5322 3.6.3).
+const UndisclosedRecipients = "undisclosed-recipients"
+
+// Group returns members listed as the RFC 5322 group name, for To or Cc,
+// e.g. "Team: alice@x, bob@x;". Without members it returns an empty
+// group such as "undisclosed-recipients:;", which names no recipient.
+//
+// Parameters:
+//   - name: The group display name.
+//   - members: The addresses in the group.
+//
+// Returns:
+//   - []Address: The addresses with Group set.
+func Group(name string, members ...Address) []Address {
+	if len(members) == 0 {
+		return []Address{{Group: name}}
+	}
+	out := make([]Address, 0, len(members))
+	for _, a := range members {
+		a.Group = name
+		out = append(out, a)
+	}
+	return out
+}
+
+// FormatAddressList renders xs as an address header value. Consecutive
+// addresses with the same Group are written as one group.
+//
+// Parameters:
+//   - xs: The addresses.
+//
+// Returns:
+//   - string: The comma-separated list.
+func FormatAddressList(xs []Address) string {
+	return strings.Join(addressItems(xs), ", ")
+}
+
+// addressItems renders xs one entry per address or group.
+func addressItems(xs []Address) []string {
+	var out []string
+	for i := 0; i < len(xs); {
+		a := xs[i]
+		if a.Group == "" {
+			out = append(out, a.String())
+			i++
+			continue
+		}
+		var members []string
+		for ; i < len(xs) && xs[i].Group == a.Group; i++ {
+			if xs[i].Mail != "" {
+				members = append(members, xs[i].String())
+			}
+		}
+		if len(members) == 0 {
+			out = append(out, formatPhrase(a.Group)+":;")
+			continue
+		}
+		out = append(out, formatPhrase(a.Group)+": "+strings.Join(members, ", ")+";")
+	}
+	return out
+}
+
+// formatPhrase renders a group name as atoms, a quoted string or an
+// encoded word.
+func formatPhrase(s string) string {
+	atoms, ascii := true, true
+	for _, r := range s {
+		switch {
+		case r >= 0x80:
+			atoms, ascii = false, false
+		case r != ' ' && !isAtext(r):
+			atoms = false
+		}
+	}
+	switch {
+	case atoms && s != "" && strings.TrimSpace(s) == s:
+		return s
+	case ascii:
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+	default:
+		return mime.QEncoding.Encode("utf-8", s)
+	}
+}
+
+// isAtext reports whether r may appear in an RFC 5322 atom.
+func isAtext(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+		r >= '0' && r <= '9' || strings.ContainsRune("!#$%&'*+-/=?^_`{|}~", r)
+}
+
+// addrRun is a run of plain addresses or one group in an address list.
+type addrRun struct {
+	group   string
+	isGroup bool
+	list    string
+}
+
+// splitGroups splits an address list at its groups. It returns nil if
+// s has no group.
+func splitGroups(s string) ([]addrRun, error) {
+	var runs []addrRun
+	var quoted, escaped, literal bool
+	angle, comment := 0, 0
+	start, item, group := 0, 0, -1
+	name := ""
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && (quoted || comment > 0):
+			escaped = true
+		case quoted:
+			quoted = c != '"'
+		case comment > 0:
+			if c == '(' {
+				comment++
+			} else if c == ')' {
+				comment--
+			}
+		case literal:
+			literal = c != ']'
+		case c == '"':
+			quoted = true
+		case c == '(':
+			comment++
+		case c == '[':
+			literal = true
+		case c == '<':
+			angle++
+		case c == '>' && angle > 0:
+			angle--
+		case angle > 0:
+		case c == ',' && group < 0:
+			item = i + 1
+		case c == ':' && group < 0:
+			runs = append(runs, addrRun{list: s[start:item]})
+			name, group = s[item:i], i+1
+		case c == ';' && group >= 0:
+			runs = append(runs, addrRun{group: name, isGroup: true, list: s[group:i]})
+			start, item, group = i+1, i+1, -1
+		}
+	}
+	if group >= 0 {
+		return nil, errors.New("unterminated group")
+	}
+	if len(runs) == 0 {
+		return nil, nil
+	}
+	return append(runs, addrRun{list: s[start:]}), nil
+}
+
+// parseGroupName decodes the display name of a group.
+func parseGroupName(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == `""` {
+		return "", errors.New("group without name")
+	}
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		var b strings.Builder
+		for i := 1; i < len(s)-1; i++ {
+			if s[i] == '\\' && i+1 < len(s)-1 {
+				i++
+			}
+			b.WriteByte(s[i])
+		}
+		return b.String(), nil
+	}
+	dec, err := new(mime.WordDecoder).DecodeHeader(s)
+	if err != nil {
+		return "", fmt.Errorf("group name %q: %w", s, err)
+	}
+	return dec, nil
+}
+
+// parseGroupList parses an address list containing groups.
+func parseGroupList(runs []addrRun) ([]Address, error) {
+	var out []Address
+	for _, r := range runs {
+		list := strings.Trim(r.list, " \t\r\n,")
+		var name string
+		if r.isGroup {
+			var err error
+			if name, err = parseGroupName(r.group); err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(list) == "" {
+				out = append(out, Address{Group: name})
+				continue
+			}
+		} else if list == "" {
+			continue
+		}
+		parsed, err := mail.ParseAddressList(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, ma := range parsed {
+			out = append(out, Address{Name: ma.Name, Mail: ma.Address, Group: name})
+		}
+	}
+	return out, nil
+}
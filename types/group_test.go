@@ -0,0 +1,90 @@
+package types
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestParseAddressListGroups(t *testing.T) {
+	got, err := ParseAddressList([]string{
+		`c@y.com, Team: alice@x.com, "Bob, Jr." <bob@x.com>;, undisclosed-recipients:;`,
+	})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := []Address{
+		{Mail: "c@y.com"},
+		{Mail: "alice@x.com", Group: "Team"},
+		{Name: "Bob, Jr.", Mail: "bob@x.com", Group: "Team"},
+		{Group: UndisclosedRecipients},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	got, err = ParseAddressList([]string{`"Sales: EU":a@x.com;`, "d@y.com (ops: on call)"})
+	if err != nil || len(got) != 2 || got[0].Group != "Sales: EU" || got[1].Mail != "d@y.com" {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+	for _, bad := range []string{"Team: a@x.com", ": a@x.com;", "Team: not an address;"} {
+		if _, err := ParseAddressList([]string{bad}); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestFormatAddressList(t *testing.T) {
+	xs := append([]Address{{Mail: "c@y.com"}},
+		Group("Team", Address{Mail: "alice@x.com"}, Address{Name: "Bob", Mail: "bob@x.com"})...)
+	xs = append(xs, Group(UndisclosedRecipients)...)
+	xs = append(xs, Group("Équipe", Address{Mail: "e@x.com"})...)
+	xs = append(xs, Group("A.B", Address{Mail: "f@x.com"})...)
+	got := FormatAddressList(xs)
+	want := `c@y.com, Team: alice@x.com, "Bob" <bob@x.com>;, undisclosed-recipients:;, ` +
+		`=?utf-8?q?=C3=89quipe?=: e@x.com;, "A.B": f@x.com;`
+	if got != want {
+		t.Fatalf("got %q\nwant %q", got, want)
+	}
+	back, err := ParseAddressList([]string{got})
+	if err != nil || !slices.Equal(back, xs) {
+		t.Fatalf("round trip: %+v, %v", back, err)
+	}
+}
+
+func TestValidateGroups(t *testing.T) {
+	m := Message{
+		From:  Address{Mail: "a@x.com"},
+		To:    Group(UndisclosedRecipients),
+		Plain: []byte("hi"),
+	}
+	if err := m.Validate(); err == nil {
+		t.Fatalf("expected error for empty group only")
+	}
+	m.Bcc = []Address{{Mail: "b@x.com"}}
+	if err := m.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	m.To = Group("x\r\nBcc: evil@x.com")
+	if err := m.Validate(); err == nil {
+		t.Fatalf("expected error for group name injection")
+	}
+}
+
+func TestMessageJSONGroups(t *testing.T) {
+	m := Message{
+		From: Address{Mail: "a@x.com"},
+		To:   append(Group("Team", Address{Mail: "b@x.com"}, Address{Mail: "c@x.com"}), Address{Mail: "d@x.com"}),
+		Cc:   Group(UndisclosedRecipients),
+	}
+	b, err := json.Marshal(&m)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var back Message
+	if err := json.Unmarshal(b, &back); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !slices.Equal(back.To, m.To) || !slices.Equal(back.Cc, m.Cc) {
+		t.Fatalf("got %+v %+v from %s", back.To, back.Cc, b)
+	}
+}
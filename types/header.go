@@ -0,0 +1,107 @@
+package types
+
+import "fmt"
+
+// HeaderError reports an invalid header field name or value.
+type HeaderError struct {
+	Field  string // header field name (or message field, e.g. "To")
+	Reason string // human readable reason
+}
+
+// Error implements the error interface.
+//
+// Returns:
+//   - string: The error message.
+func (e *HeaderError) Error() string {
+	return fmt.Sprintf("invalid header %q: %s", e.Field, e.Reason)
+}
+
+// Is matches ErrInvalidHeader.
+//
+// Parameters:
+//   - target: The error to compare with.
+//
+// Returns:
+//   - bool: True if target is ErrInvalidHeader.
+func (e *HeaderError) Is(target error) bool {
+	return target == ErrInvalidHeader
+}
+
+// ValidHeaderName reports whether name is a valid RFC 5322 field name:
+// one or more printable US-ASCII characters except colon.
+//
+// Parameters:
+//   - name: The header field name.
+//
+// Returns:
+//   - bool: True if the name is valid.
+func ValidHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < 33 || c > 126 || c == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateHeaderValue rejects values containing CR, LF, NUL or other
+// control characters (HTAB is allowed). Such values could be used to
+// inject additional header fields or break the message structure.
+//
+// Parameters:
+//   - field: The header field name, used in the error.
+//   - value: The header value.
+//
+// Returns:
+//   - error: A *HeaderError if the value is invalid.
+func ValidateHeaderValue(field, value string) error {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\r' || c == '\n':
+			return &HeaderError{Field: field, Reason: "contains line break"}
+		case c == '\t':
+		case c < 32 || c == 127:
+			return &HeaderError{
+				Field:  field,
+				Reason: fmt.Sprintf("contains control character 0x%02x", c),
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateHeader checks both the field name and value.
+//
+// Parameters:
+//   - name: The header field name.
+//   - value: The header value.
+//
+// Returns:
+//   - error: A *HeaderError if the header is invalid.
+func ValidateHeader(name, value string) error {
+	if !ValidHeaderName(name) {
+		return &HeaderError{Field: name, Reason: "invalid field name"}
+	}
+	return ValidateHeaderValue(name, value)
+}
+
+// validateAddrs checks display names and mailboxes for control chars.
+func validateAddrs(field string, xs []Address) error {
+	for _, a := range xs {
+		if err := ValidateHeaderValue(field, a.Name); err != nil {
+			return err
+		}
+		if err := ValidateHeaderValue(field, a.Mail); err != nil {
+			return err
+		}
+		if err := ValidateHeaderValue(field, a.Group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package types
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidHeaderName(t *testing.T) {
+	for _, n := range []string{"X-Tag", "Subject", "x_custom.1"} {
+		if !ValidHeaderName(n) {
+			t.Fatalf("expected %q to be valid", n)
+		}
+	}
+	for _, n := range []string{"", "Bad Name", "Bad:Name", "Bad\r\nName", "Ünicode"} {
+		if ValidHeaderName(n) {
+			t.Fatalf("expected %q to be invalid", n)
+		}
+	}
+}
+
+func TestValidateHeaderValue(t *testing.T) {
+	if err := ValidateHeaderValue("X", "ok\tvalue"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, v := range []string{"a\r\nBcc: evil@example.com", "a\nb", "a\x00b", "a\x7fb"} {
+		err := ValidateHeaderValue("X", v)
+		var he *HeaderError
+		if !errors.As(err, &he) || he.Field != "X" {
+			t.Fatalf("expected HeaderError for %q, got %v", v, err)
+		}
+	}
+}
+
+func TestMessageValidateRejectsInjection(t *testing.T) {
+	base := func() Message {
+		return Message{
+			From:  Address{Mail: "from@example.com"},
+			To:    []Address{{Mail: "to@example.com"}},
+			Plain: []byte("hi"),
+		}
+	}
+	m := base()
+	m.Headers = Headers{{Name: "X-Tag", Value: "a\r\nBcc: evil@example.com"}}
+	if err := m.Validate(); err == nil {
+		t.Fatalf("expected error for CRLF in header value")
+	}
+	m = base()
+	m.Headers = Headers{{Name: "X Tag", Value: "a"}}
+	if err := m.Validate(); err == nil {
+		t.Fatalf("expected error for invalid header name")
+	}
+	m = base()
+	m.To[0].Name = "Ada\r\nBcc: evil@example.com"
+	if err := m.Validate(); err == nil {
+		t.Fatalf("expected error for CRLF in display name")
+	}
+}
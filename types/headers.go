@@ -0,0 +1,133 @@
+package types
+
+import "strings"
+
+// HeaderField is a single header field. Name keeps the caller's casing.
+type HeaderField struct {
+	Name  string
+	Value string
+}
+
+// Headers is an ordered, multi-valued header list. Name lookups are
+// case-insensitive (like net/textproto) but names are written with the
+// casing they were added with, in insertion order. The zero value is
+// an empty list ready to use.
+type Headers []HeaderField
+
+// Add appends a field, keeping any existing fields with the same name.
+//
+// Parameters:
+//   - name: The field name.
+//   - value: The field value.
+func (h *Headers) Add(name, value string) {
+	*h = append(*h, HeaderField{Name: name, Value: value})
+}
+
+// Prepend inserts a field before all others, as relays do with trace
+// fields such as Received.
+//
+// Parameters:
+//   - name: The field name.
+//   - value: The field value.
+func (h *Headers) Prepend(name, value string) {
+	*h = append(Headers{{Name: name, Value: value}}, *h...)
+}
+
+// Set replaces the first field named name and removes the others. If
+// no such field exists, it is appended.
+//
+// Parameters:
+//   - name: The field name.
+//   - value: The field value.
+func (h *Headers) Set(name, value string) {
+	out := (*h)[:0]
+	found := false
+	for _, f := range *h {
+		if !strings.EqualFold(f.Name, name) {
+			out = append(out, f)
+			continue
+		}
+		if !found {
+			out = append(out, HeaderField{Name: name, Value: value})
+			found = true
+		}
+	}
+	if !found {
+		out = append(out, HeaderField{Name: name, Value: value})
+	}
+	*h = out
+}
+
+// Get returns the first value for name, or "".
+//
+// Parameters:
+//   - name: The field name.
+//
+// Returns:
+//   - string: The value.
+func (h Headers) Get(name string) string {
+	for _, f := range h {
+		if strings.EqualFold(f.Name, name) {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Values returns all values for name in order.
+//
+// Parameters:
+//   - name: The field name.
+//
+// Returns:
+//   - []string: The values.
+func (h Headers) Values(name string) []string {
+	var out []string
+	for _, f := range h {
+		if strings.EqualFold(f.Name, name) {
+			out = append(out, f.Value)
+		}
+	}
+	return out
+}
+
+// Has reports whether a field named name exists.
+//
+// Parameters:
+//   - name: The field name.
+//
+// Returns:
+//   - bool: True if present.
+func (h Headers) Has(name string) bool {
+	for _, f := range h {
+		if strings.EqualFold(f.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Del removes all fields named name.
+//
+// Parameters:
+//   - name: The field name.
+func (h *Headers) Del(name string) {
+	out := (*h)[:0]
+	for _, f := range *h {
+		if !strings.EqualFold(f.Name, name) {
+			out = append(out, f)
+		}
+	}
+	*h = out
+}
+
+// Clone returns a copy that can be modified independently.
+//
+// Returns:
+//   - Headers: The copy.
+func (h Headers) Clone() Headers {
+	if h == nil {
+		return nil
+	}
+	return append(Headers(nil), h...)
+}
@@ -0,0 +1,42 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHeadersOrderedMultiValue(t *testing.T) {
+	var h Headers
+	h.Add("Received", "from a")
+	h.Add("X-Tag", "one")
+	h.Add("received", "from b")
+	h.Add("X-Tag", "two")
+
+	if got := h.Values("RECEIVED"); !reflect.DeepEqual(got, []string{"from a", "from b"}) {
+		t.Fatalf("unexpected Received values: %v", got)
+	}
+	if h.Get("x-tag") != "one" || !h.Has("X-TAG") || h.Has("Missing") {
+		t.Fatalf("case-insensitive lookup failed: %+v", h)
+	}
+
+	h.Set("x-tag", "only")
+	want := Headers{
+		{"Received", "from a"},
+		{"x-tag", "only"},
+		{"received", "from b"},
+	}
+	if !reflect.DeepEqual(h, want) {
+		t.Fatalf("Set should replace first and drop others:\n got=%v\nwant=%v", h, want)
+	}
+
+	cp := h.Clone()
+	cp.Del("Received")
+	if len(cp) != 1 || len(h) != 3 {
+		t.Fatalf("Clone/Del aliasing: cp=%v h=%v", cp, h)
+	}
+
+	h.Set("Subject", "new")
+	if h[len(h)-1].Name != "Subject" {
+		t.Fatalf("Set should append missing field: %v", h)
+	}
+}
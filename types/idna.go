@@ -0,0 +1,151 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Punycode parameters (RFC 3492 5).
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+// DomainToASCII converts an internationalized domain to its ASCII form
+// of lowercase A-labels, e.g. "Bücher.Example" to
+// "xn--bcher-kva.example". Ideographic full stops separate labels and a
+// trailing dot is dropped. Only lowercasing is applied as mapping, not
+// the full UTS #46 table, which covers the names seen in practice.
+//
+// Parameters:
+//   - domain: The domain.
+//
+// Returns:
+//   - string: The ASCII domain.
+//   - error: An error if a label is empty or longer than 63 octets.
+func DomainToASCII(domain string) (string, error) {
+	domain = strings.Map(func(r rune) rune {
+		switch r {
+		case '。', '．', '｡': // ideographic and full-width stops
+			return '.'
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(domain)))
+	domain = strings.TrimSuffix(domain, ".")
+	if domain == "" {
+		return "", errors.New("idna: empty domain")
+	}
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if label == "" {
+			return "", fmt.Errorf("idna: empty label in %q", domain)
+		}
+		if !isASCII(label) {
+			enc, err := punyEncode(label)
+			if err != nil {
+				return "", fmt.Errorf("idna: %q: %w", label, err)
+			}
+			label = "xn--" + enc
+		}
+		if len(label) > 63 {
+			return "", fmt.Errorf("idna: label %q longer than 63 octets", label)
+		}
+		labels[i] = label
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// isASCII reports whether s has only ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// punyEncode encodes s with Punycode (RFC 3492 6.3).
+func punyEncode(s string) (string, error) {
+	runes := []rune(s)
+	var out []byte
+	for _, r := range runes {
+		if r < 0x80 {
+			out = append(out, byte(r))
+		}
+	}
+	basic := len(out)
+	handled := basic
+	if basic > 0 {
+		out = append(out, '-')
+	}
+	n, delta, bias := rune(punyInitialN), 0, punyInitialBias
+	for handled < len(runes) {
+		m := rune(math.MaxInt32)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		if int(m-n) > (math.MaxInt32-delta)/(handled+1) {
+			return "", errors.New("punycode overflow")
+		}
+		delta += int(m-n) * (handled + 1)
+		n = m
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := min(max(k-bias, punyTMin), punyTMax)
+				if q < t {
+					break
+				}
+				out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punyDigit(q))
+			bias = punyAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return string(out), nil
+}
+
+// punyAdapt is the bias adaptation function (RFC 3492 6.1).
+func punyAdapt(delta, points int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / points
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+// punyDigit returns the basic code point for digit d.
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
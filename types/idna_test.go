@@ -0,0 +1,26 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDomainToASCII(t *testing.T) {
+	for in, want := range map[string]string{
+		"Example.COM.":          "example.com",
+		"Bücher.Example":        "xn--bcher-kva.example",
+		"münchen.de":            "xn--mnchen-3ya.de",
+		"例え。テスト":                "xn--r8jz45g.xn--zckzah",
+		"xn--bcher-kva.example": "xn--bcher-kva.example",
+	} {
+		got, err := DomainToASCII(in)
+		if err != nil || got != want {
+			t.Fatalf("DomainToASCII(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", ".", "a..b", strings.Repeat("y", 64) + ".com"} {
+		if _, err := DomainToASCII(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package types
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// MessageSchemaVersion is the version of the JSON encoding of Message
+// written by MarshalJSON. UnmarshalJSON rejects newer versions.
+const MessageSchemaVersion = 1
+
+// FileReader is an Attachment.Reader that opens Path on the first read
+// and closes it at EOF. A Message encodes it in JSON as a path reference
+// instead of inlining the content, so queued messages stay small; the
+// file must exist where the message is built.
+type FileReader struct {
+	Path string
+
+	f *os.File
+}
+
+// NewFileReader returns a reader for the file at path.
+//
+// Parameters:
+//   - path: The file path.
+//
+// Returns:
+//   - *FileReader: The reader.
+func NewFileReader(path string) *FileReader {
+	return &FileReader{Path: path}
+}
+
+// Read reads from the file, opening it on the first call.
+//
+// Parameters:
+//   - p: The buffer to read into.
+//
+// Returns:
+//   - int: The number of bytes read.
+//   - error: io.EOF at the end, or the error opening or reading the
+//     file.
+func (r *FileReader) Read(p []byte) (int, error) {
+	if r.f == nil {
+		f, err := os.Open(r.Path)
+		if err != nil {
+			return 0, err
+		}
+		r.f = f
+	}
+	n, err := r.f.Read(p)
+	if err != nil {
+		r.f.Close()
+	}
+	return n, err
+}
+
+// jsonMessage is the JSON encoding of Message. Field names are part of
+// the schema; bump MessageSchemaVersion on incompatible changes.
+type jsonMessage struct {
+	Version      int              `json:"version"`
+	From         string           `json:"from,omitempty"`
+	To           []string         `json:"to,omitempty"`
+	Cc           []string         `json:"cc,omitempty"`
+	Bcc          []string         `json:"bcc,omitempty"`
+	ReplyTo      []string         `json:"reply_to,omitempty"`
+	Subject      string           `json:"subject,omitempty"`
+	Text         string           `json:"text,omitempty"`
+	HTML         string           `json:"html,omitempty"`
+	Attachments  []jsonAttachment `json:"attachments,omitempty"`
+	Headers      []jsonHeader     `json:"headers,omitempty"`
+	TrackingID   string           `json:"tracking_id,omitempty"`
+	InReplyTo    string           `json:"in_reply_to,omitempty"`
+	References   []string         `json:"references,omitempty"`
+	EnvelopeFrom string           `json:"envelope_from,omitempty"`
+	EnvelopeTo   []string         `json:"envelope_to,omitempty"`
+	TextEncoding TransferEncoding `json:"text_encoding,omitempty"`
+	Date         *time.Time       `json:"date,omitempty"`
+	ExtraFrom    []string         `json:"extra_from,omitempty"`
+	Sender       string           `json:"sender,omitempty"`
+}
+
+type jsonAttachment struct {
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	ContentID   string `json:"content_id,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Data        string `json:"data,omitempty"` // base64
+}
+
+type jsonHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// MarshalJSON encodes the message with addresses in "Name <mail>" form
+// and text bodies as strings. Attachments read through a FileReader are
+// written as a path; others are read and inlined as base64. Inlining
+// consumes the Reader: it is rewound if it is an io.Seeker, otherwise
+// the message cannot be sent afterwards.
+//
+// Returns:
+//   - []byte: The JSON encoding.
+//   - error: An error if an attachment cannot be read.
+func (m Message) MarshalJSON() ([]byte, error) {
+	jm := jsonMessage{
+		Version:      MessageSchemaVersion,
+		To:           addrStrings(m.To),
+		Cc:           addrStrings(m.Cc),
+		Bcc:          addrStrings(m.Bcc),
+		ReplyTo:      addrStrings(m.ReplyTo),
+		ExtraFrom:    addrStrings(m.ExtraFrom),
+		Subject:      m.Subject,
+		Text:         string(m.Plain),
+		HTML:         string(m.HTML),
+		TrackingID:   m.TrackingID,
+		InReplyTo:    m.InReplyTo,
+		References:   m.References,
+		EnvelopeFrom: m.EnvelopeFrom,
+		EnvelopeTo:   m.EnvelopeTo,
+		TextEncoding: m.TextEncoding,
+	}
+	if !m.Date.IsZero() {
+		jm.Date = &m.Date
+	}
+	if m.From.Mail != "" {
+		jm.From = m.From.String()
+	}
+	if m.Sender.Mail != "" {
+		jm.Sender = m.Sender.String()
+	}
+	for _, f := range m.Headers {
+		jm.Headers = append(jm.Headers, jsonHeader{Name: f.Name, Value: f.Value})
+	}
+	for _, a := range m.Attach {
+		ja := jsonAttachment{
+			Filename: a.Filename, ContentType: a.ContentType,
+			ContentID: a.ContentID,
+		}
+		switch r := a.Reader.(type) {
+		case nil:
+		case *FileReader:
+			ja.Path = r.Path
+		default:
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return nil, fmt.Errorf("message json: attachment %q: %w", a.Filename, err)
+			}
+			if s, ok := r.(io.Seeker); ok {
+				if _, err := s.Seek(0, io.SeekStart); err != nil {
+					return nil, fmt.Errorf("message json: attachment %q: %w", a.Filename, err)
+				}
+			}
+			ja.Data = base64.StdEncoding.EncodeToString(data)
+		}
+		jm.Attachments = append(jm.Attachments, ja)
+	}
+	return json.Marshal(jm)
+}
+
+// UnmarshalJSON decodes a message written by MarshalJSON. A missing
+// version is read as version 1. Path attachments get a FileReader;
+// the file is opened when the message is built.
+//
+// Parameters:
+//   - data: The JSON encoding.
+//
+// Returns:
+//   - error: An error if the JSON, an address or an attachment is
+//     invalid, or the schema version is not supported.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var jm jsonMessage
+	if err := json.Unmarshal(data, &jm); err != nil {
+		return err
+	}
+	if jm.Version > MessageSchemaVersion || jm.Version < 0 {
+		return fmt.Errorf("message json: unsupported version %d", jm.Version)
+	}
+	out := Message{
+		Subject:      jm.Subject,
+		TrackingID:   jm.TrackingID,
+		InReplyTo:    jm.InReplyTo,
+		References:   jm.References,
+		EnvelopeFrom: jm.EnvelopeFrom,
+		EnvelopeTo:   jm.EnvelopeTo,
+		TextEncoding: jm.TextEncoding,
+	}
+	if jm.Date != nil {
+		out.Date = *jm.Date
+	}
+	var err error
+	if jm.From != "" {
+		if out.From, err = ParseAddress(jm.From); err != nil {
+			return fmt.Errorf("message json: from: %w", err)
+		}
+	}
+	if jm.Sender != "" {
+		if out.Sender, err = ParseAddress(jm.Sender); err != nil {
+			return fmt.Errorf("message json: sender: %w", err)
+		}
+	}
+	for _, l := range []struct {
+		name string
+		dst  *[]Address
+		src  []string
+	}{
+		{"to", &out.To, jm.To}, {"cc", &out.Cc, jm.Cc},
+		{"bcc", &out.Bcc, jm.Bcc}, {"reply_to", &out.ReplyTo, jm.ReplyTo},
+		{"extra_from", &out.ExtraFrom, jm.ExtraFrom},
+	} {
+		if *l.dst, err = ParseAddressList(l.src); err != nil {
+			return fmt.Errorf("message json: %s: %w", l.name, err)
+		}
+	}
+	if jm.Text != "" {
+		out.Plain = []byte(jm.Text)
+	}
+	if jm.HTML != "" {
+		out.HTML = []byte(jm.HTML)
+	}
+	for _, h := range jm.Headers {
+		out.Headers.Add(h.Name, h.Value)
+	}
+	for _, ja := range jm.Attachments {
+		a := Attachment{
+			Filename: ja.Filename, ContentType: ja.ContentType,
+			ContentID: ja.ContentID,
+		}
+		switch {
+		case ja.Path != "" && ja.Data != "":
+			return fmt.Errorf("message json: attachment %q: both path and data set", ja.Filename)
+		case ja.Path != "":
+			a.Reader = NewFileReader(ja.Path)
+		case ja.Data != "":
+			b, err := base64.StdEncoding.DecodeString(ja.Data)
+			if err != nil {
+				return fmt.Errorf("message json: attachment %q: %w", ja.Filename, err)
+			}
+			a.Reader = bytes.NewReader(b)
+		}
+		out.Attach = append(out.Attach, a)
+	}
+	*m = out
+	return nil
+}
+
+// addrStrings renders addresses in "Name <mail>" form, one entry per
+// group.
+func addrStrings(xs []Address) []string {
+	return addressItems(xs)
+}
@@ -0,0 +1,103 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMessageJSONRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.pdf")
+	if err := os.WriteFile(path, []byte("%PDF"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	inline := bytes.NewReader([]byte{0x89, 'P', 'N', 'G'})
+	msg := Message{
+		From:       Address{Name: "Ann Example", Mail: "ann@example.com"},
+		ExtraFrom:  []Address{{Mail: "ben@example.com"}},
+		Sender:     Address{Name: "PA", Mail: "pa@example.com"},
+		To:         []Address{{Mail: "bob@example.com"}, {Name: "Cy, Jr.", Mail: "cy@example.com"}},
+		Bcc:        []Address{{Mail: "audit@example.com"}},
+		Subject:    "Report ✓",
+		Plain:      []byte("hello"),
+		HTML:       []byte("<p>hello</p>"),
+		Headers:    Headers{{"X-Tag", "a"}, {"X-Tag", "b"}},
+		TrackingID: "t-1",
+		References: []string{"<a@x>"},
+		Date:       time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Attach: []Attachment{
+			{Filename: "report.pdf", ContentType: "application/pdf", Reader: NewFileReader(path)},
+			{Filename: "logo.png", ContentType: "image/png", ContentID: "logo", Reader: inline},
+		},
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw struct {
+		Version     int
+		From        string
+		Attachments []struct{ Path, Data string }
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if raw.Version != MessageSchemaVersion || raw.From != `"Ann Example" <ann@example.com>` ||
+		raw.Attachments[0].Path != path || raw.Attachments[1].Data != "iVBORw==" {
+		t.Fatalf("unexpected encoding: %s", data)
+	}
+	if b, _ := io.ReadAll(inline); len(b) != 4 {
+		t.Fatalf("inlined reader was not rewound: %q", b)
+	}
+
+	var got Message
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.From, msg.From) || !reflect.DeepEqual(got.To, msg.To) ||
+		!reflect.DeepEqual(got.Bcc, msg.Bcc) || !reflect.DeepEqual(got.Headers, msg.Headers) ||
+		got.Subject != msg.Subject || string(got.Plain) != "hello" ||
+		string(got.HTML) != "<p>hello</p>" || got.TrackingID != "t-1" ||
+		!reflect.DeepEqual(got.References, msg.References) || got.Cc != nil ||
+		!got.Date.Equal(msg.Date) || !reflect.DeepEqual(got.ExtraFrom, msg.ExtraFrom) ||
+		got.Sender != msg.Sender {
+		t.Fatalf("round trip mismatch:\n got=%+v\nwant=%+v", got, msg)
+	}
+	for i, want := range []string{"%PDF", "\x89PNG"} {
+		a := got.Attach[i]
+		if a.Filename != msg.Attach[i].Filename || a.ContentID != msg.Attach[i].ContentID {
+			t.Fatalf("attachment %d: %+v", i, a)
+		}
+		if b, err := io.ReadAll(a.Reader); err != nil || string(b) != want {
+			t.Fatalf("attachment %d content: %q %v", i, b, err)
+		}
+	}
+	if _, ok := got.Attach[0].Reader.(*FileReader); !ok {
+		t.Fatalf("path attachment should stay a reference: %T", got.Attach[0].Reader)
+	}
+}
+
+func TestMessageJSONErrors(t *testing.T) {
+	for _, in := range []string{
+		`{"version":2,"from":"a@example.com"}`,
+		`{"from":"not an address"}`,
+		`{"to":["a@example.com","<bad"]}`,
+		`{"attachments":[{"filename":"x","path":"/x","data":"eA=="}]}`,
+		`{"attachments":[{"filename":"x","data":"!!"}]}`,
+	} {
+		var m Message
+		if err := json.Unmarshal([]byte(in), &m); err == nil {
+			t.Errorf("%s: expected error", in)
+		}
+	}
+	var m Message
+	if err := json.Unmarshal([]byte(`{"from":"a@example.com","to":["b@example.com"],"text":"hi"}`), &m); err != nil ||
+		m.From.Mail != "a@example.com" || m.Validate() != nil {
+		t.Fatalf("unversioned message: %+v %v", m, err)
+	}
+}
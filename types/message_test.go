@@ -24,6 +24,34 @@ func TestMessageValidate(t *testing.T) {
     }
 }
 
+func TestMessageValidateSender(t *testing.T) {
+    m := Message{
+        From:      Address{Mail: "ceo@example.com"},
+        ExtraFrom: []Address{{Mail: "cfo@example.com"}},
+        To:        []Address{{Mail: "board@example.org"}},
+        Plain:     []byte("hi"),
+    }
+    if err := m.Validate(); err == nil {
+        t.Fatalf("expected error for multiple From without Sender")
+    }
+    m.Sender = Address{Mail: "assistant@example.com"}
+    if err := m.Validate(); err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if got := m.FromList(); len(got) != 2 || got[1].Mail != "cfo@example.com" {
+        t.Fatalf("unexpected FromList: %v", got)
+    }
+    m.ExtraFrom[0].Name = "Bad\r\nBcc: x@example.net"
+    if err := m.Validate(); err == nil {
+        t.Fatalf("expected header error for ExtraFrom")
+    }
+    m.ExtraFrom = nil
+    m.Sender.Mail = "pa@example.com\r\nBcc: x@example.net"
+    if err := m.Validate(); err == nil {
+        t.Fatalf("expected header error for Sender")
+    }
+}
+
 func TestRecipientList(t *testing.T) {
     m := Message{
         To:  []Address{{Mail: "a@example.com"}},
@@ -73,3 +101,40 @@ func TestParseAddressList(t *testing.T) {
         t.Fatalf("nil input: %v, %+v", err, xs)
     }
 }
+
+func TestEnvelopeSender(t *testing.T) {
+    m := Message{From: Address{Mail: "from@example.com"}}
+    if got := m.EnvelopeSender(); got != "from@example.com" {
+        t.Fatalf("expected From fallback, got %q", got)
+    }
+    m.EnvelopeFrom = "bounces+x=example.org@example.com"
+    if got := m.EnvelopeSender(); got != m.EnvelopeFrom {
+        t.Fatalf("expected EnvelopeFrom, got %q", got)
+    }
+}
+
+func TestEnvelopeRecipients(t *testing.T) {
+    m := Message{
+        From:  Address{Mail: "from@example.com"},
+        To:    []Address{{Mail: "list@example.com"}},
+        Plain: []byte("hi"),
+    }
+    if got := m.EnvelopeRecipients(); len(got) != 1 || got[0] != "list@example.com" {
+        t.Fatalf("expected header fallback, got %v", got)
+    }
+    m.EnvelopeTo = []string{" ada@example.org ", "", "archive@example.com"}
+    got := m.EnvelopeRecipients()
+    if len(got) != 2 || got[0] != "ada@example.org" || got[1] != "archive@example.com" {
+        t.Fatalf("expected EnvelopeTo, got %v", got)
+    }
+
+    // EnvelopeTo alone is enough recipients, but must not inject.
+    m.To = nil
+    if err := m.Validate(); err != nil {
+        t.Fatalf("validate: %v", err)
+    }
+    m.EnvelopeTo = []string{"ada@example.org>\r\nDATA"}
+    if err := m.Validate(); err == nil {
+        t.Fatalf("expected injection error")
+    }
+}
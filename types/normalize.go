@@ -0,0 +1,83 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NormalizeOptions selects the canonicalizations of Address.Normalize
+// beyond the domain, which is always lowercased and converted to ASCII.
+type NormalizeOptions struct {
+	// FoldLocal lowercases the local part. RFC 5321 lets servers treat
+	// it case-sensitively, but nearly all providers do not.
+	FoldLocal bool
+	// StripTag removes a "+tag" from the local part.
+	StripTag bool
+	// Gmail canonicalizes Gmail addresses: googlemail.com becomes
+	// gmail.com, and dots and any "+tag" are removed from the
+	// lowercased local part, as Gmail ignores them.
+	Gmail bool
+}
+
+// gmailDomains are the domains Gmail canonicalization applies to.
+var gmailDomains = map[string]bool{"gmail.com": true, "googlemail.com": true}
+
+// Normalize returns a with Mail in canonical form for comparison and
+// deduplication: surrounding spaces and angle brackets are removed and
+// the domain is lowercased and converted to ASCII (see DomainToASCII),
+// with further steps chosen by opts. Name is kept.
+//
+// Parameters:
+//   - opts: The optional canonicalizations.
+//
+// Returns:
+//   - Address: The normalized address.
+//   - error: An error if Mail has no local part or domain, or the
+//     domain is invalid.
+func (a Address) Normalize(opts NormalizeOptions) (Address, error) {
+	addr := strings.Trim(a.Mail, " \t<>")
+	i := strings.LastIndex(addr, "@")
+	if i <= 0 || i == len(addr)-1 {
+		return Address{}, fmt.Errorf("normalize %q: missing local part or domain", a.Mail)
+	}
+	local := addr[:i]
+	domain, err := DomainToASCII(addr[i+1:])
+	if err != nil {
+		return Address{}, fmt.Errorf("normalize %q: %w", a.Mail, err)
+	}
+	if opts.FoldLocal {
+		local = strings.ToLower(local)
+	}
+	if opts.StripTag {
+		local, _, _ = strings.Cut(local, "+")
+	}
+	if opts.Gmail && gmailDomains[domain] {
+		local, _, _ = strings.Cut(strings.ToLower(local), "+")
+		local = strings.ReplaceAll(local, ".", "")
+		domain = "gmail.com"
+	}
+	if local == "" {
+		return Address{}, fmt.Errorf("normalize %q: empty local part", a.Mail)
+	}
+	a.Mail = local + "@" + domain
+	return a, nil
+}
+
+// Equal reports whether a and b name the same mailbox after
+// normalization with opts. Display names are ignored. Addresses that
+// cannot be normalized are equal only if Mail is identical.
+//
+// Parameters:
+//   - b: The address to compare with.
+//   - opts: The optional canonicalizations.
+//
+// Returns:
+//   - bool: True if the addresses are equal.
+func (a Address) Equal(b Address, opts NormalizeOptions) bool {
+	na, errA := a.Normalize(opts)
+	nb, errB := b.Normalize(opts)
+	if errA != nil || errB != nil {
+		return strings.TrimSpace(a.Mail) == strings.TrimSpace(b.Mail)
+	}
+	return na.Mail == nb.Mail
+}
@@ -0,0 +1,45 @@
+package types
+
+import "testing"
+
+func TestAddressNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		opts NormalizeOptions
+		want string
+	}{
+		{" <Ada@Example.COM> ", NormalizeOptions{}, "Ada@example.com"},
+		{"Ada@Bücher.example", NormalizeOptions{FoldLocal: true}, "ada@xn--bcher-kva.example"},
+		{"ada+news@example.com", NormalizeOptions{StripTag: true}, "ada@example.com"},
+		{"Ada.Love.Lace+x@GoogleMail.com", NormalizeOptions{Gmail: true}, "adalovelace@gmail.com"},
+		{"ada.lovelace@example.com", NormalizeOptions{Gmail: true}, "ada.lovelace@example.com"},
+	}
+	for _, tt := range tests {
+		got, err := Address{Name: "Ada", Mail: tt.in}.Normalize(tt.opts)
+		if err != nil || got.Mail != tt.want || got.Name != "Ada" {
+			t.Fatalf("Normalize(%q, %+v) = %+v, %v; want %q", tt.in, tt.opts, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"ada", "@example.com", "ada@", "+x@example.com"} {
+		if _, err := (Address{Mail: bad}).Normalize(NormalizeOptions{StripTag: true}); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestAddressEqual(t *testing.T) {
+	a := Address{Name: "Ada", Mail: "a.da+x@gmail.com"}
+	b := Address{Mail: "ADA@googlemail.com"}
+	if a.Equal(b, NormalizeOptions{}) {
+		t.Fatalf("addresses should differ without Gmail canonicalization")
+	}
+	if !a.Equal(b, NormalizeOptions{Gmail: true}) {
+		t.Fatalf("addresses should be equal with Gmail canonicalization")
+	}
+	if !(Address{Mail: "x@MÜNCHEN.de"}).Equal(Address{Mail: "x@xn--mnchen-3ya.de"}, NormalizeOptions{}) {
+		t.Fatalf("IDN forms should be equal")
+	}
+	if (Address{Mail: "bad"}).Equal(Address{Mail: "BAD"}, NormalizeOptions{FoldLocal: true}) {
+		t.Fatalf("invalid addresses compare exactly")
+	}
+}
@@ -0,0 +1,112 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseOptions tightens ParseAddress beyond what net/mail accepts. The
+// zero value is lenient, as suits inbound mail; sign-up forms should use
+// StrictParse.
+type ParseOptions struct {
+	// NoComments rejects RFC 5322 comments such as "a@x.com (Ann)".
+	NoComments bool
+	// RequireTLD requires a dotted domain whose last label is at least
+	// two characters and not numeric, rejecting "a@localhost".
+	RequireTLD bool
+	// MaxLocalLen limits the local part in octets (0 = no limit). RFC
+	// 5321 allows 64.
+	MaxLocalLen int
+	// NoQuotedLocal rejects local parts that need quoting, such as
+	// "john doe"@x.com.
+	NoQuotedLocal bool
+}
+
+// StrictParse rejects addresses that are valid but rarely typed on
+// purpose: comments, quoted local parts, local parts over 64 octets and
+// domains without a TLD.
+var StrictParse = ParseOptions{
+	NoComments:    true,
+	RequireTLD:    true,
+	MaxLocalLen:   64,
+	NoQuotedLocal: true,
+}
+
+// ParseAddress parses a single address string like ParseAddress and
+// applies the checks selected by o.
+//
+// Parameters:
+//   - s: The address string to parse.
+//
+// Returns:
+//   - Address: The parsed address.
+//   - error: An error if the address is invalid or fails a check.
+func (o ParseOptions) ParseAddress(s string) (Address, error) {
+	a, err := ParseAddress(s)
+	if err != nil {
+		return Address{}, err
+	}
+	bad := func(reason string) (Address, error) {
+		return Address{}, fmt.Errorf("parse address %q: %s", strings.TrimSpace(s), reason)
+	}
+	if o.NoComments && hasComment(s) {
+		return bad("comments not allowed")
+	}
+	at := strings.LastIndex(a.Mail, "@")
+	if at < 0 {
+		return bad("missing domain")
+	}
+	local, domain := a.Mail[:at], a.Mail[at+1:]
+	if o.MaxLocalLen > 0 && len(local) > o.MaxLocalLen {
+		return bad(fmt.Sprintf("local part longer than %d octets", o.MaxLocalLen))
+	}
+	if o.NoQuotedLocal && !isDotAtom(local) {
+		return bad("quoted local part not allowed")
+	}
+	if o.RequireTLD && !hasTLD(domain) {
+		return bad("domain has no top-level domain")
+	}
+	return a, nil
+}
+
+// hasComment reports whether s has a parenthesized comment outside
+// quoted strings.
+func hasComment(s string) bool {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == '(':
+			return true
+		}
+	}
+	return false
+}
+
+// isDotAtom reports whether local can be written without quoting.
+func isDotAtom(local string) bool {
+	if local == "" || strings.HasPrefix(local, ".") || strings.HasSuffix(local, ".") ||
+		strings.Contains(local, "..") {
+		return false
+	}
+	for _, r := range local {
+		if r != '.' && r < 0x80 && !isAtext(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasTLD reports whether domain is dotted and ends in a plausible
+// top-level domain.
+func hasTLD(domain string) bool {
+	i := strings.LastIndex(domain, ".")
+	if i <= 0 {
+		return false
+	}
+	tld := domain[i+1:]
+	return len(tld) >= 2 && strings.Trim(tld, "0123456789") != ""
+}
@@ -0,0 +1,34 @@
+package types
+
+import "testing"
+
+func TestParseOptions(t *testing.T) {
+	long := "a234567890123456789012345678901234567890123456789012345678901234x@example.com"
+	tests := []struct {
+		in   string
+		opts ParseOptions
+		ok   bool
+	}{
+		{"Ann <ann@example.com>", StrictParse, true},
+		{"ann.lee+news@example.co", StrictParse, true},
+		{`"Lee, Ann (HR)" <ann@example.com>`, ParseOptions{NoComments: true}, true},
+		{"ann@example.com (Ann)", ParseOptions{}, true},
+		{"ann@example.com (Ann)", ParseOptions{NoComments: true}, false},
+		{"ann@localhost", ParseOptions{}, true},
+		{"ann@localhost", ParseOptions{RequireTLD: true}, false},
+		{"ann@10.0.0.1", ParseOptions{RequireTLD: true}, false},
+		{"ann@example.c", ParseOptions{RequireTLD: true}, false},
+		{long, ParseOptions{}, true},
+		{long, ParseOptions{MaxLocalLen: 64}, false},
+		{`"ann lee"@example.com`, ParseOptions{}, true},
+		{`"ann lee"@example.com`, ParseOptions{NoQuotedLocal: true}, false},
+		{`"ann..lee"@example.com`, ParseOptions{NoQuotedLocal: true}, false},
+		{"not an address", ParseOptions{}, false},
+	}
+	for _, tt := range tests {
+		_, err := tt.opts.ParseAddress(tt.in)
+		if (err == nil) != tt.ok {
+			t.Fatalf("ParseAddress(%q, %+v) error = %v, want ok %v", tt.in, tt.opts, err, tt.ok)
+		}
+	}
+}
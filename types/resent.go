@@ -0,0 +1,53 @@
+package types
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Resent describes the re-sending of a stored message to new
+// recipients (RFC 5322 3.6.6). It becomes a block of Resent-* fields
+// on top of the original header; the content is not regenerated.
+type Resent struct {
+	From        Address   // who resends; also the default MAIL FROM
+	To, Cc, Bcc []Address // Bcc receives it without a header
+	Date        time.Time // zero means now
+	MessageID   string    // "" generates one at the From domain
+}
+
+// Validate checks the resent addresses.
+//
+// Returns:
+//   - error: The error if From or the recipients are missing, or an
+//     address would inject header content.
+func (r *Resent) Validate() error {
+	if strings.TrimSpace(r.From.Mail) == "" {
+		return errors.New("resent: missing from")
+	}
+	if len(r.RecipientList()) == 0 {
+		return errors.New("resent: no recipients")
+	}
+	for _, c := range []struct {
+		field string
+		xs    []Address
+	}{
+		{"Resent-From", []Address{r.From}}, {"Resent-To", r.To},
+		{"Resent-Cc", r.Cc}, {"Resent-Bcc", r.Bcc},
+	} {
+		if err := validateAddrs(c.field, c.xs); err != nil {
+			return err
+		}
+	}
+	return ValidateHeaderValue("Resent-Message-ID", r.MessageID)
+}
+
+// RecipientList returns the resent To, Cc and Bcc addresses for the
+// SMTP envelope.
+//
+// Returns:
+//   - []string: The recipients.
+func (r *Resent) RecipientList() []string {
+	m := Message{To: r.To, Cc: r.Cc, Bcc: r.Bcc}
+	return m.RecipientList()
+}
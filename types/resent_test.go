@@ -0,0 +1,27 @@
+package types
+
+import "testing"
+
+func TestResentValidate(t *testing.T) {
+	ok := Resent{
+		From: Address{Mail: "support@example.com"},
+		To:   []Address{{Mail: "ada@example.org"}},
+		Bcc:  []Address{{Mail: "audit@example.com"}},
+	}
+	if err := ok.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := ok.RecipientList(); len(got) != 2 || got[1] != "audit@example.com" {
+		t.Fatalf("recipients = %v", got)
+	}
+	for _, r := range []Resent{
+		{To: ok.To},
+		{From: ok.From},
+		{From: ok.From, To: []Address{{Name: "x\r\nBcc: y", Mail: "a@example.org"}}},
+		{From: ok.From, To: ok.To, MessageID: "id\n"},
+	} {
+		if err := r.Validate(); err == nil {
+			t.Fatalf("expected error for %+v", r)
+		}
+	}
+}
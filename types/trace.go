@@ -0,0 +1,93 @@
+package types
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// IsTraceHeader reports whether name is a trace field, Received or
+// Return-Path (RFC 5322 3.6.7). Trace fields are kept at the top of a
+// message, newest first, and are never reordered.
+//
+// Parameters:
+//   - name: The header field name.
+//
+// Returns:
+//   - bool: True for a trace field.
+func IsTraceHeader(name string) bool {
+	return strings.EqualFold(name, "Received") ||
+		strings.EqualFold(name, "Return-Path")
+}
+
+// Received describes a Received trace header added by a relay (RFC 5321
+// 4.4):
+//
+//	from <From> (<FromTCP>) by <By> with <With> id <ID> for <For>; <Date>
+//
+// Empty clauses are omitted; a zero Date means now.
+type Received struct {
+	From    string    // HELO/EHLO name of the sending host
+	FromTCP string    // e.g. "mail.example.org [192.0.2.1]"
+	By      string    // host name of the receiving relay
+	With    string    // protocol, e.g. "ESMTPS" or "LMTP"
+	ID      string    // queue ID of the message at the relay
+	For     string    // single envelope recipient
+	Date    time.Time // time of receipt
+}
+
+// Value returns the header value.
+//
+// Returns:
+//   - string: The Received header value.
+//   - error: A *HeaderError if a clause is not a valid token.
+func (r Received) Value() (string, error) {
+	var b strings.Builder
+	clause := func(kw, v string) error {
+		if v == "" {
+			return nil
+		}
+		if strings.ContainsAny(v, " \t;()<>\r\n") {
+			return &HeaderError{Field: "Received",
+				Reason: fmt.Sprintf("invalid %s clause %q", kw, v)}
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(kw + " " + v)
+		return nil
+	}
+	if err := clause("from", r.From); err != nil {
+		return "", err
+	}
+	if r.FromTCP != "" {
+		if r.From == "" || strings.ContainsAny(r.FromTCP, "()\r\n") {
+			return "", &HeaderError{Field: "Received",
+				Reason: fmt.Sprintf("invalid TCP info %q", r.FromTCP)}
+		}
+		b.WriteString(" (" + r.FromTCP + ")")
+	}
+	for _, c := range [][2]string{{"by", r.By}, {"with", r.With}, {"id", r.ID}} {
+		if err := clause(c[0], c[1]); err != nil {
+			return "", err
+		}
+	}
+	if r.For != "" {
+		addr, err := mail.ParseAddress(r.For)
+		if err != nil {
+			return "", &HeaderError{Field: "Received",
+				Reason: fmt.Sprintf("invalid for clause %q", r.For)}
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString("for <" + addr.Address + ">")
+	}
+	date := r.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	b.WriteString("; " + date.Format(time.RFC1123Z))
+	return b.String(), nil
+}
@@ -2,11 +2,13 @@ package types
 
 import (
 	"context"
-	"errors"
+	"crypto"
 	"fmt"
 	"io"
+	"net"
 	"net/mail"
 	"strings"
+	"time"
 )
 
 // Attachment represents a file attachment or inline image.
@@ -23,31 +25,120 @@ type Message struct {
 	To         []Address
 	Cc         []Address
 	Bcc        []Address
+	ReplyTo    []Address
 	Subject    string
 	Plain      []byte // optional
 	HTML       []byte // optional
 	Attach     []Attachment
-	Headers    map[string]string
+	Headers    Headers // custom headers, written in order after core fields
 	TrackingID string
+
+	// InReplyTo and References carry thread identifiers (Message-IDs,
+	// with or without angle brackets) for replies (RFC 5322 3.6.4).
+	InReplyTo  string
+	References []string
+
+	// ExtraFrom lists further authors, written after From in the From
+	// header (RFC 5322 3.6.2). Sender must then be set.
+	ExtraFrom []Address
+	// Sender, if set, is the mailbox that actually sent the message on
+	// behalf of the authors, e.g. an assistant sending for a manager.
+	Sender Address
+
+	// EnvelopeFrom is the SMTP MAIL FROM (Return-Path) address. If empty,
+	// From.Mail is used. Set it for VERP or a dedicated bounce domain.
+	EnvelopeFrom string
+	// EnvelopeTo, if set, replaces To, Cc and Bcc as the SMTP RCPT TO
+	// addresses while the headers stay as they are, e.g. to expand a
+	// mailing list or to send an archive copy to a compliance mailbox.
+	EnvelopeTo []string
+
+	// TextEncoding forces the Content-Transfer-Encoding of the text
+	// parts. Empty picks one per part automatically.
+	TextEncoding TransferEncoding
+
+	// Date, if non-zero, is written as the Date header instead of the
+	// build time, e.g. to backdate messages imported from an archive.
+	Date time.Time
 }
 
 // Validate minimal correctness before send.
 //
 // Returns:
-//   - error: An error if the message is invalid.
+//   - error: ErrMissingFrom, ErrMissingSender, ErrNoRecipients,
+//     ErrNoBody, a *HeaderError or another error if the message is
+//     invalid.
 func (m *Message) Validate() error {
 	if m.From.Mail == "" {
-		return errors.New("missing From")
+		return ErrMissingFrom
 	}
-	if len(m.To) == 0 && len(m.Cc) == 0 && len(m.Bcc) == 0 {
-		return errors.New("no recipients")
+	if len(m.ExtraFrom) > 0 && m.Sender.Mail == "" {
+		return ErrMissingSender
+	}
+	if len(m.EnvelopeRecipients()) == 0 {
+		return ErrNoRecipients
 	}
 	if len(m.Plain) == 0 && len(m.HTML) == 0 && len(m.Attach) == 0 {
-		return errors.New("no body or attachments")
+		return ErrNoBody
+	}
+	return m.validateHeaders()
+}
+
+// validateHeaders rejects header injection via custom headers, display
+// names and addresses. Subject and TrackingID are sanitized at build time.
+func (m *Message) validateHeaders() error {
+	if err := validateAddrs("From", m.FromList()); err != nil {
+		return err
+	}
+	if m.Sender.Mail != "" {
+		if err := validateAddrs("Sender", []Address{m.Sender}); err != nil {
+			return err
+		}
+	}
+	if err := validateAddrs("To", m.To); err != nil {
+		return err
+	}
+	if err := validateAddrs("Cc", m.Cc); err != nil {
+		return err
+	}
+	if err := validateAddrs("Bcc", m.Bcc); err != nil {
+		return err
+	}
+	if err := validateAddrs("Reply-To", m.ReplyTo); err != nil {
+		return err
+	}
+	if err := ValidateHeaderValue("In-Reply-To", m.InReplyTo); err != nil {
+		return err
+	}
+	for _, r := range m.References {
+		if err := ValidateHeaderValue("References", r); err != nil {
+			return err
+		}
+	}
+	if err := ValidateHeaderValue("Return-Path", m.EnvelopeFrom); err != nil {
+		return err
+	}
+	for _, r := range m.EnvelopeTo {
+		if err := ValidateHeaderValue("RCPT TO", r); err != nil {
+			return err
+		}
+	}
+	for _, f := range m.Headers {
+		if err := ValidateHeader(f.Name, f.Value); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// FromList returns From followed by ExtraFrom.
+//
+// Returns:
+//   - []Address: The authors.
+func (m *Message) FromList() []Address {
+	return append([]Address{m.From}, m.ExtraFrom...)
+}
+
 // RecipientList returns combined To+Cc+Bcc for envelope use.
 func (m *Message) RecipientList() []string {
 	var out []string
@@ -64,19 +155,47 @@ func (m *Message) RecipientList() []string {
 	return out
 }
 
-// CloneHeaders returns a shallow copy safe for per-send mutation.
-func (m *Message) CloneHeaders() map[string]string {
-	cp := make(map[string]string, len(m.Headers))
-	for k, v := range m.Headers {
-		cp[k] = v
+// EnvelopeRecipients returns EnvelopeTo, falling back to RecipientList.
+//
+// Returns:
+//   - []string: The RCPT TO addresses.
+func (m *Message) EnvelopeRecipients() []string {
+	var out []string
+	for _, r := range m.EnvelopeTo {
+		if s := strings.TrimSpace(r); s != "" {
+			out = append(out, s)
+		}
+	}
+	if len(out) > 0 {
+		return out
+	}
+	return m.RecipientList()
+}
+
+// EnvelopeSender returns EnvelopeFrom, falling back to From.Mail.
+//
+// Returns:
+//   - string: The MAIL FROM address.
+func (m *Message) EnvelopeSender() string {
+	if s := strings.TrimSpace(m.EnvelopeFrom); s != "" {
+		return s
 	}
-	return cp
+	return m.From.Mail
+}
+
+// CloneHeaders returns a copy safe for per-send mutation.
+func (m *Message) CloneHeaders() Headers {
+	return m.Headers.Clone()
 }
 
 // Address represents a single email address with an optional display name.
 type Address struct {
 	Name string
 	Mail string
+	// Group names the RFC 5322 group the address is listed in, if any;
+	// see Group. An address with Group set and Mail empty stands for an
+	// empty group such as "undisclosed-recipients:;".
+	Group string
 }
 
 // String renders a display-friendly representation for headers.
@@ -94,21 +213,163 @@ func (a Address) String() string {
 // Hooks allows you to integrate tracing/metrics without extra deps.
 // Return a derived context from Start hooks if you want to carry spans.
 type Hooks struct {
+	// OnBuildStart and OnBuildDone receive the builder's private copy
+	// of the message; changing it does not affect the caller's message
+	// or other sends of it. The copy shares the Plain and HTML bodies,
+	// so replace a body rather than change its bytes.
 	OnBuildStart func(ctx context.Context, msg *Message) context.Context
 	OnBuildDone  func(ctx context.Context, msg *Message, size int,
 		err error)
 	OnAttemptStart func(ctx context.Context, attempt int) context.Context
 	OnAttemptDone  func(ctx context.Context, attempt int, err error)
+	// OnFallback reports a feature dropped because the server lacks
+	// an extension, e.g. an 8bit body rebuilt without 8BITMIME.
+	OnFallback func(ctx context.Context, reason string)
+	// OnBatchStart and OnBatchDone wrap a SendBatch call; the context
+	// returned by OnBatchStart is passed to the hooks of every message
+	// in the batch. failed counts the messages that were not sent.
+	OnBatchStart func(ctx context.Context, n int) context.Context
+	OnBatchDone  func(ctx context.Context, n, failed int)
+	// OnBuildWarning reports each problem the builder worked around,
+	// such as a custom header it ignored, before OnBuildDone.
+	OnBuildWarning func(ctx context.Context, w BuildWarning)
+}
+
+// BuildTransform rewrites the HTML body before MIME assembly, e.g. to
+// rewrite links. Transforms run in order, after any HTML sanitizer, on
+// a copy of the body; the message itself is not modified.
+type BuildTransform interface {
+	TransformHTML(ctx context.Context, html []byte) ([]byte, error)
+}
+
+// BuildTransformFunc adapts a function to BuildTransform.
+type BuildTransformFunc func(ctx context.Context, html []byte) ([]byte, error)
+
+// TransformHTML calls f.
+//
+// Parameters:
+//   - ctx: The build context.
+//   - html: The HTML body.
+//
+// Returns:
+//   - []byte: The rewritten body.
+//   - error: An error to fail the build.
+func (f BuildTransformFunc) TransformHTML(ctx context.Context, html []byte) ([]byte, error) {
+	return f(ctx, html)
+}
+
+// MessageIDGenerator returns a Message-ID for msg. domain is the
+// configured Message-ID domain, or the From domain if none is set. The
+// result may omit the angle brackets; they are added when missing.
+type MessageIDGenerator func(msg *Message, domain string) string
+
+// TransferEncoding is a Content-Transfer-Encoding for text parts.
+type TransferEncoding string
+
+// Transfer encodings. Transfer8Bit needs the server to support 8BITMIME
+// and TransferBinary needs BINARYMIME with CHUNKING.
+const (
+	TransferAuto            TransferEncoding = ""
+	Transfer7Bit            TransferEncoding = "7bit"
+	TransferQuotedPrintable TransferEncoding = "quoted-printable"
+	TransferBase64          TransferEncoding = "base64"
+	Transfer8Bit            TransferEncoding = "8bit"
+	TransferBinary          TransferEncoding = "binary"
+)
+
+// CharsetEncoder transcodes UTF-8 text to another charset. Wrap an
+// encoder such as golang.org/x/text/encoding/japanese.ISO2022JP:
+//
+//	func(b []byte) ([]byte, error) {
+//		return japanese.ISO2022JP.NewEncoder().Bytes(b)
+//	}
+type CharsetEncoder func(utf8 []byte) ([]byte, error)
+
+// AttachmentCache stores base64-encoded attachment bodies keyed by the
+// hex SHA-256 of their content, so a bulk send encodes each distinct
+// file once. Implementations must be safe for concurrent use and must
+// not modify stored slices.
+type AttachmentCache interface {
+	Get(key string) ([]byte, bool)
+	Put(key string, encoded []byte)
 }
 
-// DKIMConfig enables DKIM signing (rsa-sha256, relaxed/relaxed).
+// AttachmentScanner scans attachment content for malware before the
+// message is built. Implementations must be safe for concurrent use.
+type AttachmentScanner interface {
+	// ScanAttachment reads r to its end and returns the name of the
+	// threat found, or "" if it is clean. An error means the content
+	// could not be scanned.
+	ScanAttachment(ctx context.Context, r io.Reader) (threat string, err error)
+}
+
+// DKIM canonicalization algorithms (RFC 6376 3.4).
+const (
+	DKIMCanonSimple  = "simple"
+	DKIMCanonRelaxed = "relaxed"
+)
+
+// DKIM header sets for DKIMConfig.HeaderSet.
+const (
+	// DKIMHeadersDefault signs from, to, subject, date, mime-version,
+	// content-type and message-id.
+	DKIMHeadersDefault = ""
+	// DKIMHeadersRecommended adds sender, cc, reply-to, in-reply-to,
+	// references, content-transfer-encoding and the list headers,
+	// including list-unsubscribe, which Gmail expects to be signed.
+	DKIMHeadersRecommended = "recommended"
+	// DKIMHeadersAll signs every header of the message.
+	DKIMHeadersAll = "all"
+)
+
+// DKIM RSA signature paddings for DKIMConfig.RSAPadding.
+const (
+	// DKIMPaddingPKCS1v15 signs with PKCS#1 v1.5, as RFC 6376 requires.
+	DKIMPaddingPKCS1v15 = ""
+	// DKIMPaddingPSS signs with RSASSA-PSS (salt length equal to the
+	// hash) for deployments that only allow PSS. The a= tag is still
+	// rsa-sha256, so only verifiers that accept PSS, such as dkim.Verify,
+	// can check the signature.
+	DKIMPaddingPSS = "pss"
+)
+
+// DKIMConfig enables DKIM signing (relaxed/relaxed by default).
 // Headers lists which header field names to include in "h=" in order.
-// Use lowercase names (e.g. "from", "to", "subject").
+// Use lowercase names (e.g. "from", "to", "subject"). If Headers is
+// empty, HeaderSet selects the list.
+//
+// The private key is taken from Signer if set, otherwise parsed from
+// KeyPEM on every build. Signer lets signing be delegated to an HSM,
+// KMS, PKCS#11 or Vault transit backend; it must be an RSA or Ed25519
+// key and will be asked to sign a SHA-256 digest.
 type DKIMConfig struct {
 	Domain   string
 	Selector string
 	KeyPEM   []byte
+	Signer   crypto.Signer
 	Headers  []string
+
+	// HeaderCanon and BodyCanon select "simple" or "relaxed"
+	// canonicalization. Empty means relaxed.
+	HeaderCanon string
+	BodyCanon   string
+	// BodyLength, if positive, signs only the first BodyLength bytes of
+	// the canonicalized body and emits the l= tag.
+	BodyLength int64
+	// Expiration, if positive, emits x= as signing time + Expiration.
+	Expiration time.Duration
+
+	// HeaderSet selects the signed headers when Headers is empty:
+	// DKIMHeadersDefault, DKIMHeadersRecommended or DKIMHeadersAll.
+	HeaderSet string
+	// Oversign lists every signed header name once more in h= than it
+	// occurs, including names the message lacks, so that a header
+	// added in transit breaks the signature (RFC 6376 8.15).
+	Oversign bool
+	// RSAPadding selects the padding of RSA signatures:
+	// DKIMPaddingPKCS1v15 (empty) or DKIMPaddingPSS. It is ignored for
+	// Ed25519 keys.
+	RSAPadding string
 }
 
 // MustAddr parses an address like "Ada <ada@example.com>" or
@@ -127,7 +388,8 @@ func MustAddr(s string) Address {
 	return addr
 }
 
-// ParseAddress parses a single address string into Address.
+// ParseAddress parses a single address string into Address. It accepts
+// whatever net/mail does; see ParseOptions for stricter parsing.
 //
 // Parameters:
 //   - s: The address string to parse.
@@ -146,7 +408,10 @@ func ParseAddress(s string) (Address, error) {
 	return Address{Name: ma.Name, Mail: strings.TrimSpace(ma.Address)}, nil
 }
 
-// ParseAddressList parses a header-like list into []Address.
+// ParseAddressList parses a header-like list into []Address. Groups
+// such as "Team: a@x, b@x;" set Group on their members, and an empty
+// group such as "undisclosed-recipients:;" yields one Address with only
+// Group set.
 //
 // Parameters:
 //   - list: The list of address strings to parse.
@@ -165,6 +430,17 @@ func ParseAddressList(list []string) ([]Address, error) {
 	} else {
 		joined = strings.Join(list, ",")
 	}
+	runs, err := splitGroups(joined)
+	if err != nil {
+		return nil, fmt.Errorf("parse address list: %w", err)
+	}
+	if runs != nil {
+		out, err := parseGroupList(runs)
+		if err != nil {
+			return nil, fmt.Errorf("parse address list: %w", err)
+		}
+		return out, nil
+	}
 	parsed, err := mail.ParseAddressList(joined)
 	if err != nil {
 		return nil, fmt.Errorf("parse address list: %w", err)
@@ -175,3 +451,9 @@ func ParseAddressList(list []string) ([]Address, error) {
 	}
 	return out, nil
 }
+
+// ContextDialer dials network connections, e.g. through a proxy. It
+// matches golang.org/x/net/proxy.ContextDialer and *net.Dialer.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
@@ -0,0 +1,36 @@
+package types
+
+import "fmt"
+
+// Build warning codes.
+const (
+	// WarnDKIMHeaderMissing: a header named in DKIMConfig.Headers is
+	// not in the message, so it was left out of the signature.
+	WarnDKIMHeaderMissing = "dkim-header-missing"
+	// WarnLongHeaderLine: a header has a token too long to fold, so a
+	// line exceeds 998 octets and may be rejected or truncated.
+	WarnLongHeaderLine = "long-header-line"
+	// WarnTextRewrapped: a text part has lines over 998 octets and was
+	// encoded as quoted-printable or base64, which wraps them.
+	WarnTextRewrapped = "text-rewrapped"
+	// WarnHeaderIgnored: a custom header was dropped because it is
+	// empty or names a field the builder sets.
+	WarnHeaderIgnored = "header-ignored"
+)
+
+// BuildWarning is a problem the builder worked around instead of
+// failing the build. Warnings are reported to Hooks.OnBuildWarning and
+// returned in the send result.
+type BuildWarning struct {
+	Code    string // one of the Warn* constants
+	Field   string // header name or text part content type
+	Message string
+}
+
+// String renders the warning as "code field: message".
+//
+// Returns:
+//   - string: The rendered warning.
+func (w BuildWarning) String() string {
+	return fmt.Sprintf("%s %s: %s", w.Code, w.Field, w.Message)
+}
@@ -0,0 +1,7 @@
+// Package upload stores attachments in object storage and returns
+// expiring download links, for email.WithAttachmentLinks and
+// email.WithOversizeFallback. S3 works with Amazon S3 and S3-compatible
+// stores such as MinIO or Cloudflare R2; GCS works with Google Cloud
+// Storage through its XML API and HMAC keys. Requests are signed with
+// Signature Version 4, without a cloud SDK.
+package upload
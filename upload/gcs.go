@@ -0,0 +1,70 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// GCSConfig configures a Google Cloud Storage uploader. Create an HMAC
+// key for a service account with write access to the bucket.
+type GCSConfig struct {
+	Bucket   string
+	AccessID string // HMAC key access ID
+	Secret   string // HMAC key secret
+	Endpoint string // defaults to "https://storage.googleapis.com"
+	Prefix   string // prepended to object keys, e.g. "attachments/"
+	Expiry   time.Duration
+	Client   *http.Client
+	Now      func() time.Time
+}
+
+// GCS uploads attachments to a Cloud Storage bucket and returns signed
+// download links, like S3. It implements email.Uploader and is safe for
+// concurrent use.
+type GCS struct {
+	s *store
+}
+
+// NewGCS creates a Cloud Storage uploader.
+//
+// Parameters:
+//   - cfg: The Cloud Storage config.
+//
+// Returns:
+//   - *GCS: The uploader.
+//   - error: An error if a required setting is missing or invalid.
+func NewGCS(cfg GCSConfig) (*GCS, error) {
+	if cfg.AccessID == "" || cfg.Secret == "" {
+		return nil, errors.New("upload: HMAC access ID and secret are required")
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://storage.googleapis.com"
+	}
+	s, err := newStore(endpoint, cfg.Bucket, cfg.Prefix, cfg.Expiry, cfg.Client, cfg.Now)
+	if err != nil {
+		return nil, err
+	}
+	s.pathStyle = true
+	s.unsigned = true
+	s.signer = googSigner(cfg.AccessID, cfg.Secret)
+	return &GCS{s: s}, nil
+}
+
+// Upload stores a and returns a signed download link.
+//
+// Parameters:
+//   - ctx: The context.
+//   - a: The attachment.
+//
+// Returns:
+//   - string: The download link.
+//   - time.Time: When the link expires.
+//   - error: The error if the upload fails.
+func (u *GCS) Upload(ctx context.Context, a types.Attachment) (string, time.Time, error) {
+	return u.s.upload(ctx, a)
+}
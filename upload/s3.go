@@ -0,0 +1,83 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// S3Config configures an S3 uploader.
+type S3Config struct {
+	Bucket string
+	Region string // e.g. "eu-north-1"
+	// Endpoint defaults to "https://s3.<Region>.amazonaws.com"; set it
+	// for S3-compatible stores, e.g. "https://<account>.r2.cloudflarestorage.com".
+	Endpoint string
+	// PathStyle puts the bucket in the URL path instead of the host
+	// name, as MinIO and most self-hosted stores need.
+	PathStyle bool
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // for temporary credentials
+
+	// Prefix is prepended to object keys, e.g. "attachments/".
+	Prefix string
+	// Expiry is how long download links work; defaults to and may not
+	// exceed 7 days. Pair it with a bucket lifecycle rule deleting
+	// older objects.
+	Expiry time.Duration
+
+	Client *http.Client     // defaults to a client with a 1 minute timeout
+	Now    func() time.Time // defaults to time.Now
+}
+
+// S3 uploads attachments to an S3 bucket and returns presigned download
+// links. Objects get random keys and keep the filename, content type
+// and an attachment Content-Disposition. It implements email.Uploader
+// and is safe for concurrent use.
+type S3 struct {
+	s *store
+}
+
+// NewS3 creates an S3 uploader.
+//
+// Parameters:
+//   - cfg: The S3 config.
+//
+// Returns:
+//   - *S3: The uploader.
+//   - error: An error if a required setting is missing or invalid.
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Region == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("upload: region and credentials are required")
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	s, err := newStore(endpoint, cfg.Bucket, cfg.Prefix, cfg.Expiry, cfg.Client, cfg.Now)
+	if err != nil {
+		return nil, err
+	}
+	s.pathStyle = cfg.PathStyle
+	s.signer = awsSigner(cfg.Region, cfg.AccessKeyID, cfg.SecretAccessKey, cfg.SessionToken)
+	return &S3{s: s}, nil
+}
+
+// Upload stores a and returns a presigned download link.
+//
+// Parameters:
+//   - ctx: The context.
+//   - a: The attachment.
+//
+// Returns:
+//   - string: The download link.
+//   - time.Time: When the link expires.
+//   - error: The error if the upload fails.
+func (u *S3) Upload(ctx context.Context, a types.Attachment) (string, time.Time, error) {
+	return u.s.upload(ctx, a)
+}
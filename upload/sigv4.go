@@ -0,0 +1,184 @@
+package upload
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unsignedPayload is the payload hash of requests whose body is not
+// signed, such as presigned URLs.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// signer signs requests with Signature Version 4, in the AWS or the
+// Google Cloud Storage flavor.
+type signer struct {
+	algorithm  string // "AWS4-HMAC-SHA256"
+	keyPrefix  string // "AWS4"
+	terminator string // "aws4_request"
+	header     string // prefix of the date and token headers, "X-Amz"
+	region     string
+	service    string
+
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// awsSigner returns a signer for Amazon S3.
+func awsSigner(region, accessKey, secretKey, token string) signer {
+	return signer{
+		algorithm: "AWS4-HMAC-SHA256", keyPrefix: "AWS4", terminator: "aws4_request",
+		header: "X-Amz", region: region, service: "s3",
+		accessKey: accessKey, secretKey: secretKey, sessionToken: token,
+	}
+}
+
+// googSigner returns a signer for Google Cloud Storage HMAC keys.
+func googSigner(accessKey, secretKey string) signer {
+	return signer{
+		algorithm: "GOOG4-HMAC-SHA256", keyPrefix: "GOOG4", terminator: "goog4_request",
+		header: "X-Goog", region: "auto", service: "storage",
+		accessKey: accessKey, secretKey: secretKey,
+	}
+}
+
+// scope returns the credential scope for t.
+func (s signer) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.region + "/" + s.service + "/" + s.terminator
+}
+
+// sign adds the date, token and Authorization headers to req.
+// payloadHash is the hex SHA-256 of the body, or unsignedPayload.
+func (s signer) sign(req *http.Request, payloadHash string, t time.Time) {
+	t = t.UTC()
+	req.Header.Set(s.header+"-Date", t.Format("20060102T150405Z"))
+	if payloadHash != unsignedPayload {
+		req.Header.Set(s.header+"-Content-Sha256", payloadHash)
+	}
+	if s.sessionToken != "" {
+		req.Header.Set(s.header+"-Security-Token", s.sessionToken)
+	}
+	names := []string{"host"}
+	for name := range req.Header {
+		if l := strings.ToLower(name); strings.HasPrefix(l, strings.ToLower(s.header)+"-") {
+			names = append(names, l)
+		}
+	}
+	slices.Sort(names)
+	var canon strings.Builder
+	for _, name := range names {
+		v := req.Host
+		if v == "" {
+			v = req.URL.Host
+		}
+		if name != "host" {
+			v = strings.TrimSpace(req.Header.Get(name))
+		}
+		canon.WriteString(name + ":" + v + "\n")
+	}
+	signed := strings.Join(names, ";")
+	creq := strings.Join([]string{
+		req.Method, escapePath(req.URL.Path), canonicalQuery(req.URL.Query()),
+		canon.String(), signed, payloadHash,
+	}, "\n")
+	req.Header.Set("Authorization", s.algorithm+" Credential="+s.accessKey+"/"+s.scope(t)+
+		", SignedHeaders="+signed+", Signature="+s.signature(creq, t))
+}
+
+// presign returns u with query parameters that authorize a GET until
+// expiry after t.
+func (s signer) presign(u *url.URL, t time.Time, expiry time.Duration) *url.URL {
+	t = t.UTC()
+	out := *u
+	q := out.Query()
+	q.Set(s.header+"-Algorithm", s.algorithm)
+	q.Set(s.header+"-Credential", s.accessKey+"/"+s.scope(t))
+	q.Set(s.header+"-Date", t.Format("20060102T150405Z"))
+	q.Set(s.header+"-Expires", strconv.FormatInt(int64(expiry/time.Second), 10))
+	q.Set(s.header+"-SignedHeaders", "host")
+	if s.sessionToken != "" {
+		q.Set(s.header+"-Security-Token", s.sessionToken)
+	}
+	creq := strings.Join([]string{
+		http.MethodGet, escapePath(out.Path), canonicalQuery(q),
+		"host:" + out.Host + "\n", "host", unsignedPayload,
+	}, "\n")
+	out.RawQuery = canonicalQuery(q) + "&" + s.header + "-Signature=" + s.signature(creq, t)
+	return &out
+}
+
+// signature signs the canonical request creq made at t.
+func (s signer) signature(creq string, t time.Time) string {
+	sts := s.algorithm + "\n" + t.Format("20060102T150405Z") + "\n" +
+		s.scope(t) + "\n" + hexSHA256([]byte(creq))
+	key := []byte(s.keyPrefix + s.secretKey)
+	for _, part := range []string{t.Format("20060102"), s.region, s.service, s.terminator} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, sts))
+}
+
+// canonicalQuery encodes q sorted by key, as SigV4 requires.
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := slices.Clone(q[k])
+		slices.Sort(vs)
+		for _, v := range vs {
+			parts = append(parts, escape(k, true)+"="+escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapePath URI-encodes each segment of an object path.
+func escapePath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	return escape(p, false)
+}
+
+// escape percent-encodes all but the unreserved characters of RFC 3986,
+// and '/' unless slash is set.
+func escape(s string, slash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' || c == '/' && !slash {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&15])
+	}
+	return b.String()
+}
+
+// hexSHA256 returns the hex SHA-256 of b.
+func hexSHA256(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns HMAC-SHA256 of data under key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,157 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aatuh/email/v2/internal"
+	"github.com/aatuh/email/v2/types"
+)
+
+// maxExpiry is the longest validity of a presigned URL.
+const maxExpiry = 7 * 24 * time.Hour
+
+// store uploads objects to an S3-style bucket.
+type store struct {
+	endpoint  *url.URL
+	bucket    string
+	pathStyle bool
+	prefix    string
+	expiry    time.Duration
+	signer    signer
+	unsigned  bool // send UNSIGNED-PAYLOAD instead of the body hash
+	client    *http.Client
+	now       func() time.Time
+}
+
+// newStore checks the common settings and fills in defaults.
+func newStore(
+	endpoint, bucket, prefix string,
+	expiry time.Duration,
+	client *http.Client,
+	now func() time.Time,
+) (*store, error) {
+	if bucket == "" {
+		return nil, errors.New("upload: bucket is required")
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil, fmt.Errorf("upload: invalid endpoint %q", endpoint)
+	}
+	if expiry == 0 {
+		expiry = maxExpiry
+	}
+	if expiry < time.Second || expiry > maxExpiry {
+		return nil, errors.New("upload: expiry must be between 1s and 7 days")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute}
+	}
+	if now == nil {
+		now = time.Now
+	}
+	return &store{
+		endpoint: u, bucket: bucket, prefix: prefix, expiry: expiry,
+		client: client, now: now,
+	}, nil
+}
+
+// upload stores a under a random key and returns a presigned GET URL.
+func (s *store) upload(ctx context.Context, a types.Attachment) (string, time.Time, error) {
+	if a.Reader == nil {
+		return "", time.Time{}, errors.New("upload: attachment has no content")
+	}
+	body, err := io.ReadAll(a.Reader)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	key, err := s.key(a.Filename)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	u := s.objectURL(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	ctype := a.ContentType
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", ctype)
+	if name := baseName(a.Filename); name != "" {
+		req.Header.Set("Content-Disposition",
+			mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	}
+	hash := unsignedPayload
+	if !s.unsigned {
+		hash = hexSHA256(body)
+	}
+	now := s.now()
+	s.signer.sign(req, hash, now)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("upload: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", time.Time{}, fmt.Errorf("upload: PUT %s: %s: %s",
+			key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return s.signer.presign(u, now, s.expiry).String(), now.Add(s.expiry), nil
+}
+
+// key returns a new unguessable object key ending in filename.
+func (s *store) key(filename string) (string, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(internal.Rand(), b[:]); err != nil {
+		return "", err
+	}
+	name := strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`?#%"`, r) {
+			return '_'
+		}
+		return r
+	}, baseName(filename))
+	if name == "" {
+		name = "attachment"
+	}
+	return s.prefix + hex.EncodeToString(b[:]) + "/" + name, nil
+}
+
+// objectURL returns the URL of key, virtual-hosted or path-style.
+func (s *store) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	base := strings.TrimSuffix(u.Path, "/")
+	if s.pathStyle {
+		u.Path = base + "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = base + "/" + key
+	}
+	u.RawPath = escapePath(u.Path)
+	u.RawQuery = ""
+	return &u
+}
+
+// baseName returns the last element of a Unix or Windows path, or ""
+// if there is none.
+func baseName(filename string) string {
+	name := path.Base(strings.ReplaceAll(filename, `\`, "/"))
+	if name == "." || name == ".." || name == "/" {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,132 @@
+package upload
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// bucket is a fake object store recording PUT requests.
+type bucket struct {
+	mu   sync.Mutex
+	reqs []*http.Request
+	body []string
+	fail bool
+}
+
+func (b *bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, _ := io.ReadAll(r.Body)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reqs = append(b.reqs, r)
+	b.body = append(b.body, string(data))
+	if b.fail {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+	}
+}
+
+func TestS3Upload(t *testing.T) {
+	b := &bucket{}
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	up, err := NewS3(S3Config{
+		Bucket: "mail", Region: "eu-north-1", Endpoint: srv.URL, PathStyle: true,
+		AccessKeyID: "AKID", SecretAccessKey: "secret", Prefix: "att/",
+		Expiry: time.Hour, Now: func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, expires, err := up.Upload(context.Background(), types.Attachment{
+		Filename: `C:\reports\Q3 report.pdf`, ContentType: "application/pdf",
+		Reader: strings.NewReader("%PDF-1.7"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expires.Equal(now.Add(time.Hour)) || len(b.reqs) != 1 {
+		t.Fatalf("expires = %v, requests = %d", expires, len(b.reqs))
+	}
+	req := b.reqs[0]
+	if req.Method != http.MethodPut || b.body[0] != "%PDF-1.7" ||
+		!strings.HasPrefix(req.URL.EscapedPath(), "/mail/att/") ||
+		!strings.HasSuffix(req.URL.EscapedPath(), "/Q3%20report.pdf") ||
+		req.Header.Get("Content-Type") != "application/pdf" ||
+		req.Header.Get("Content-Disposition") != `attachment; filename="Q3 report.pdf"` ||
+		req.Header.Get("X-Amz-Content-Sha256") != hexSHA256([]byte("%PDF-1.7")) ||
+		!strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/20261016/eu-north-1/s3/") {
+		t.Fatalf("unexpected request %s %v", req.URL, req.Header)
+	}
+	u, err := url.Parse(link)
+	if err != nil || u.EscapedPath() != req.URL.EscapedPath() ||
+		u.Query().Get("X-Amz-Expires") != "3600" || len(u.Query().Get("X-Amz-Signature")) != 64 {
+		t.Fatalf("link = %s", link)
+	}
+
+	b.fail = true
+	if _, _, err := up.Upload(context.Background(), types.Attachment{
+		Filename: "a.txt", Reader: strings.NewReader("x"),
+	}); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Fatalf("expected upload error, got %v", err)
+	}
+}
+
+func TestGCSUpload(t *testing.T) {
+	b := &bucket{}
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+	up, err := NewGCS(GCSConfig{Bucket: "mail", AccessID: "GOOGID", Secret: "s", Endpoint: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, expires, err := up.Upload(context.Background(), types.Attachment{
+		Filename: "data.csv", Reader: strings.NewReader("a,b"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := b.reqs[0]
+	if !strings.HasPrefix(req.URL.Path, "/mail/") || req.Header.Get("X-Goog-Date") == "" ||
+		req.Header.Get("Content-Type") != "application/octet-stream" ||
+		!strings.HasPrefix(req.Header.Get("Authorization"), "GOOG4-HMAC-SHA256 ") {
+		t.Fatalf("unexpected request %s %v", req.URL, req.Header)
+	}
+	if !strings.Contains(link, "X-Goog-Expires=604800") || time.Until(expires) < 6*24*time.Hour {
+		t.Fatalf("link = %s, expires %v", link, expires)
+	}
+}
+
+func TestNewStoreErrors(t *testing.T) {
+	for _, cfg := range []S3Config{
+		{Region: "r", AccessKeyID: "a", SecretAccessKey: "s"},
+		{Bucket: "b", AccessKeyID: "a", SecretAccessKey: "s"},
+		{Bucket: "b", Region: "r", AccessKeyID: "a", SecretAccessKey: "s", Expiry: 8 * 24 * time.Hour},
+		{Bucket: "b", Region: "r", AccessKeyID: "a", SecretAccessKey: "s", Endpoint: "ftp://x"},
+	} {
+		if _, err := NewS3(cfg); err == nil {
+			t.Fatalf("expected error for %+v", cfg)
+		}
+	}
+	if _, err := NewGCS(GCSConfig{Bucket: "b"}); err == nil {
+		t.Fatal("expected error without HMAC key")
+	}
+}
+
+func TestObjectURL(t *testing.T) {
+	s, _ := newStore("https://s3.eu-north-1.amazonaws.com", "mail", "", 0, nil, nil)
+	if got := s.objectURL("k/a b.txt").String(); got != "https://mail.s3.eu-north-1.amazonaws.com/k/a%20b.txt" {
+		t.Fatalf("virtual-hosted = %s", got)
+	}
+	if k, _ := s.key("../.."); !strings.HasSuffix(k, "/attachment") {
+		t.Fatalf("key = %s", k)
+	}
+}
@@ -0,0 +1,255 @@
+package validate
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"strings"
+	"sync"
+)
+
+//go:embed data/disposable.txt
+var disposableData string
+
+//go:embed data/free.txt
+var freeData string
+
+// DomainSet answers whether a lowercased domain belongs to a set. Use it
+// to plug an external or database-backed list into a Classifier.
+type DomainSet interface {
+	Contains(domain string) bool
+}
+
+// DomainList is an in-memory DomainSet that can be updated at run time.
+// A domain matches if it or one of its parent domains is listed, so
+// listing "mailinator.com" also covers "eu.mailinator.com". Safe for
+// concurrent use.
+type DomainList struct {
+	mu      sync.RWMutex
+	domains map[string]bool
+}
+
+// NewDomainList creates a list of domains.
+//
+// Parameters:
+//   - domains: The domains.
+//
+// Returns:
+//   - *DomainList: The list.
+func NewDomainList(domains ...string) *DomainList {
+	l := &DomainList{domains: map[string]bool{}}
+	l.Add(domains...)
+	return l
+}
+
+// DefaultDisposable returns a copy of the embedded list of disposable
+// mail domains.
+//
+// Returns:
+//   - *DomainList: The list.
+func DefaultDisposable() *DomainList {
+	l := NewDomainList()
+	_ = l.Load(strings.NewReader(disposableData))
+	return l
+}
+
+// DefaultFree returns a copy of the embedded list of free webmail
+// domains.
+//
+// Returns:
+//   - *DomainList: The list.
+func DefaultFree() *DomainList {
+	l := NewDomainList()
+	_ = l.Load(strings.NewReader(freeData))
+	return l
+}
+
+// Add adds domains to the list.
+//
+// Parameters:
+//   - domains: The domains.
+func (l *DomainList) Add(domains ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, d := range domains {
+		if d = normalizeDomain(d); d != "" {
+			l.domains[d] = true
+		}
+	}
+}
+
+// Load replaces the list with the domains read from r, one per line.
+// Blank lines and lines starting with '#' are skipped. On a read error
+// the list is left unchanged.
+//
+// Parameters:
+//   - r: The list source, e.g. a downloaded file.
+//
+// Returns:
+//   - error: The read error, if any.
+func (l *DomainList) Load(r io.Reader) error {
+	domains := map[string]bool{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if d := normalizeDomain(line); d != "" {
+			domains[d] = true
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.domains = domains
+	return nil
+}
+
+// Contains reports whether domain or one of its parent domains is
+// listed.
+//
+// Parameters:
+//   - domain: The domain.
+//
+// Returns:
+//   - bool: True if the domain is listed.
+func (l *DomainList) Contains(domain string) bool {
+	domain = normalizeDomain(domain)
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for domain != "" {
+		if l.domains[domain] {
+			return true
+		}
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok || !strings.Contains(parent, ".") {
+			return false
+		}
+		domain = parent
+	}
+	return false
+}
+
+// Len returns the number of listed domains.
+//
+// Returns:
+//   - int: The number of domains.
+func (l *DomainList) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.domains)
+}
+
+// normalizeDomain lowercases d and drops a trailing dot.
+func normalizeDomain(d string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(d)), ".")
+}
+
+// ClassifierConfig configures a Classifier. Nil sets use the embedded
+// lists; pass an empty DomainList to disable one.
+type ClassifierConfig struct {
+	Disposable DomainSet
+	Free       DomainSet
+	// RoleAccounts overrides DefaultRoleAccounts.
+	RoleAccounts []string
+}
+
+// Classification tags an address. Role combines with the domain tags;
+// an address on neither a disposable nor a free domain is corporate.
+type Classification struct {
+	Address    string
+	Domain     string
+	Disposable bool
+	Free       bool
+	Role       bool
+}
+
+// Corporate reports whether the address is on an organization's own
+// domain rather than a free or disposable provider.
+//
+// Returns:
+//   - bool: True for corporate addresses.
+func (c Classification) Corporate() bool {
+	return !c.Disposable && !c.Free
+}
+
+// Classifier tags addresses as disposable, free, role or corporate
+// without network lookups. Safe for concurrent use.
+type Classifier struct {
+	disposable DomainSet
+	free       DomainSet
+	roles      map[string]bool
+}
+
+// NewClassifier creates a Classifier.
+//
+// Parameters:
+//   - cfg: The classifier config.
+//
+// Returns:
+//   - *Classifier: The classifier.
+func NewClassifier(cfg ClassifierConfig) *Classifier {
+	c := &Classifier{disposable: cfg.Disposable, free: cfg.Free, roles: map[string]bool{}}
+	if c.disposable == nil {
+		c.disposable = DefaultDisposable()
+	}
+	if c.free == nil {
+		c.free = DefaultFree()
+	}
+	roles := cfg.RoleAccounts
+	if roles == nil {
+		roles = DefaultRoleAccounts
+	}
+	for _, r := range roles {
+		c.roles[strings.ToLower(r)] = true
+	}
+	return c
+}
+
+// Classify checks the syntax of addr and tags it.
+//
+// Parameters:
+//   - addr: The address, with or without display name.
+//
+// Returns:
+//   - Classification: The tags.
+//   - error: A *Error wrapping ErrSyntax if addr is invalid.
+func (c *Classifier) Classify(addr string) (Classification, error) {
+	local, domain, err := Syntax(addr)
+	if err != nil {
+		return Classification{}, err
+	}
+	return Classification{
+		Address:    local + "@" + domain,
+		Domain:     domain,
+		Disposable: c.disposable.Contains(domain),
+		Free:       c.free.Contains(domain),
+		Role:       c.roles[strings.ToLower(local)],
+	}, nil
+}
+
+// IsDisposable reports whether domain is disposable. It fits
+// Config.IsDisposable.
+//
+// Parameters:
+//   - domain: The domain.
+//
+// Returns:
+//   - bool: True if the domain is disposable.
+func (c *Classifier) IsDisposable(domain string) bool {
+	return c.disposable.Contains(normalizeDomain(domain))
+}
+
+// IsFree reports whether domain is a free webmail provider.
+//
+// Parameters:
+//   - domain: The domain.
+//
+// Returns:
+//   - bool: True if the domain is free webmail.
+func (c *Classifier) IsFree(domain string) bool {
+	return c.free.Contains(normalizeDomain(domain))
+}
@@ -0,0 +1,77 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	c := NewClassifier(ClassifierConfig{})
+	tests := []struct {
+		addr                   string
+		disposable, free, role bool
+		corporate              bool
+	}{
+		{"Ada <ada@Example.com>", false, false, false, true},
+		{"info@example.com", false, false, true, true},
+		{"ada@gmail.com", false, true, false, false},
+		{"throwaway@eu.mailinator.com", true, false, false, false},
+	}
+	for _, tt := range tests {
+		got, err := c.Classify(tt.addr)
+		if err != nil {
+			t.Fatalf("classify %q: %v", tt.addr, err)
+		}
+		if got.Disposable != tt.disposable || got.Free != tt.free || got.Role != tt.role ||
+			got.Corporate() != tt.corporate {
+			t.Fatalf("classify %q = %+v", tt.addr, got)
+		}
+	}
+	if _, err := c.Classify("not an address"); !errors.Is(err, ErrSyntax) {
+		t.Fatalf("expected syntax error, got %v", err)
+	}
+}
+
+func TestClassifierCustomLists(t *testing.T) {
+	free := NewDomainList()
+	c := NewClassifier(ClassifierConfig{Free: free, RoleAccounts: []string{"team"}})
+	if c.IsFree("gmail.com") {
+		t.Fatal("empty list should classify nothing as free")
+	}
+	free.Add("Partner.Example.")
+	if err := free.Load(strings.NewReader("# updated\n\nmail.example\n")); err != nil {
+		t.Fatal(err)
+	}
+	if free.Len() != 1 || c.IsFree("partner.example") || !c.IsFree("MAIL.example") {
+		t.Fatalf("Load should replace the list")
+	}
+	// A parent match needs a dotted parent: "example" alone is not a
+	// listed domain of "mail.example".
+	if NewDomainList("example").Contains("mail.example") {
+		t.Fatal("top-level names should not match subdomains")
+	}
+	got, _ := c.Classify("team@example.org")
+	if !got.Role {
+		t.Fatal("custom role accounts should apply")
+	}
+	if DefaultDisposable().Len() == 0 || DefaultFree().Len() == 0 {
+		t.Fatal("embedded lists are empty")
+	}
+}
+
+func TestValidateWithClassifier(t *testing.T) {
+	v := New(Config{Classifier: NewClassifier(ClassifierConfig{}), RejectFree: true})
+	res, err := v.Validate(context.Background(), "ada@yopmail.com")
+	if !errors.Is(err, ErrDisposable) || !res.Disposable {
+		t.Fatalf("expected disposable, got %+v %v", res, err)
+	}
+	res, err = v.Validate(context.Background(), "ada@gmail.com")
+	if !errors.Is(err, ErrFreeMail) || !res.Free {
+		t.Fatalf("expected free mail rejection, got %+v %v", res, err)
+	}
+	if _, err := v.Validate(context.Background(), "ada@example.com"); err != nil {
+		t.Fatalf("corporate address rejected: %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+// Package validate checks email address deliverability: syntax beyond
+// net/mail, role accounts, disposable and free-mail domains, MX records
+// and optional SMTP callout probes, with result caching. A Classifier
+// tags addresses offline from embedded, updatable domain lists. A
+// Validator can be used standalone or passed to email.WithAddressCheck
+// as a pre-send check.
+package validate
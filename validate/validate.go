@@ -0,0 +1,410 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sentinel reasons returned (wrapped in *Error) by Validator.Check.
+var (
+	ErrSyntax      = errors.New("invalid address syntax")
+	ErrDisposable  = errors.New("disposable domain")
+	ErrFreeMail    = errors.New("free webmail domain")
+	ErrRoleAccount = errors.New("role account")
+	ErrNoMX        = errors.New("domain does not accept mail")
+	ErrRejected    = errors.New("mailbox rejected by server")
+)
+
+// Error describes why an address failed validation. Use errors.Is with
+// the sentinel values above to branch on the reason.
+type Error struct {
+	Address string
+	Reason  error
+	Detail  string
+}
+
+// Error implements the error interface.
+//
+// Returns:
+//   - string: The error message.
+func (e *Error) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("validate %s: %v: %s", e.Address, e.Reason,
+			e.Detail)
+	}
+	return fmt.Sprintf("validate %s: %v", e.Address, e.Reason)
+}
+
+// Unwrap returns the sentinel reason.
+//
+// Returns:
+//   - error: The reason.
+func (e *Error) Unwrap() error { return e.Reason }
+
+// Resolver is the subset of *net.Resolver used for MX lookups.
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// CalloutConfig enables SMTP callout (RCPT probe) verification.
+type CalloutConfig struct {
+	HeloName string        // EHLO name; defaults to "localhost"
+	MailFrom string        // probe envelope sender; defaults to "<>"
+	Port     int           // defaults to 25
+	Timeout  time.Duration // per probe; defaults to 10s
+	// Dial overrides the network dialer (tests, proxies).
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// Config configures a Validator. The zero value checks syntax only.
+type Config struct {
+	// IsDisposable reports whether domain is a throwaway provider.
+	IsDisposable func(domain string) bool
+	// Classifier, if set, fills Result.Free and, unless IsDisposable
+	// is set, decides Result.Disposable.
+	Classifier *Classifier
+	// RejectFree makes free webmail addresses fail Check, e.g. for
+	// business sign-ups. It needs a Classifier.
+	RejectFree bool
+	// RoleAccounts overrides the default role local parts (admin, info...).
+	RoleAccounts []string
+	// RejectRole makes role accounts fail Check.
+	RejectRole bool
+	// CheckMX requires the domain to have MX (or A/AAAA fallback) records.
+	CheckMX  bool
+	Resolver Resolver // defaults to net.DefaultResolver
+	// Callout enables RCPT probing against the domain's MX hosts.
+	Callout *CalloutConfig
+	// CacheTTL caches results per address; 0 disables caching.
+	CacheTTL time.Duration
+}
+
+// Result is the outcome of validating one address.
+type Result struct {
+	Address    string
+	Domain     string
+	Role       bool
+	Disposable bool
+	Free       bool
+	MX         []string // MX hosts in preference order, if looked up
+	Callout    string   // final server reply to RCPT, if probed
+}
+
+// Validator checks address deliverability. Safe for concurrent use.
+type Validator struct {
+	cfg   Config
+	roles map[string]bool
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// cacheEntry is a cached validation result.
+type cacheEntry struct {
+	res Result
+	err error
+	exp time.Time
+}
+
+// DefaultRoleAccounts lists local parts commonly used by shared
+// mailboxes rather than individuals.
+var DefaultRoleAccounts = []string{
+	"abuse", "admin", "administrator", "billing", "contact", "help",
+	"hostmaster", "info", "marketing", "noc", "no-reply", "noreply",
+	"postmaster", "sales", "security", "support", "webmaster",
+}
+
+// New creates a Validator.
+//
+// Parameters:
+//   - cfg: The validator config.
+//
+// Returns:
+//   - *Validator: The validator.
+func New(cfg Config) *Validator {
+	if cfg.Resolver == nil {
+		cfg.Resolver = net.DefaultResolver
+	}
+	roles := cfg.RoleAccounts
+	if roles == nil {
+		roles = DefaultRoleAccounts
+	}
+	v := &Validator{cfg: cfg, roles: map[string]bool{}}
+	for _, r := range roles {
+		v.roles[strings.ToLower(r)] = true
+	}
+	if cfg.CacheTTL > 0 {
+		v.cache = map[string]cacheEntry{}
+	}
+	return v
+}
+
+// Validate runs all configured checks on addr and returns the details.
+// A non-nil error is a *Error when the address is rejected.
+//
+// Parameters:
+//   - ctx: The context.
+//   - addr: The address, with or without display name.
+//
+// Returns:
+//   - Result: The validation details.
+//   - error: An error if the address fails a check.
+func (v *Validator) Validate(ctx context.Context, addr string) (Result, error) {
+	key := strings.ToLower(strings.TrimSpace(addr))
+	if v.cache != nil {
+		v.mu.Lock()
+		e, ok := v.cache[key]
+		v.mu.Unlock()
+		if ok && time.Now().Before(e.exp) {
+			return e.res, e.err
+		}
+	}
+	res, err := v.validate(ctx, addr)
+	// Do not cache context errors; they say nothing about the address.
+	if v.cache != nil && ctx.Err() == nil {
+		v.mu.Lock()
+		v.cache[key] = cacheEntry{res: res, err: err,
+			exp: time.Now().Add(v.cfg.CacheTTL)}
+		v.mu.Unlock()
+	}
+	return res, err
+}
+
+// CheckAddress validates addr and returns only the error. It satisfies
+// email.AddressChecker so a Validator can be used as a pre-send option.
+//
+// Parameters:
+//   - ctx: The context.
+//   - addr: The address.
+//
+// Returns:
+//   - error: An error if the address fails a check.
+func (v *Validator) CheckAddress(ctx context.Context, addr string) error {
+	_, err := v.Validate(ctx, addr)
+	return err
+}
+
+// validate performs the uncached checks.
+func (v *Validator) validate(ctx context.Context, addr string) (Result, error) {
+	res := Result{Address: strings.TrimSpace(addr)}
+	local, domain, err := Syntax(addr)
+	if err != nil {
+		return res, err
+	}
+	res.Address = local + "@" + domain
+	res.Domain = domain
+	res.Role = v.roles[strings.ToLower(local)]
+	if v.cfg.IsDisposable != nil {
+		res.Disposable = v.cfg.IsDisposable(domain)
+	} else if v.cfg.Classifier != nil {
+		res.Disposable = v.cfg.Classifier.IsDisposable(domain)
+	}
+	if v.cfg.Classifier != nil {
+		res.Free = v.cfg.Classifier.IsFree(domain)
+	}
+	if res.Disposable {
+		return res, &Error{Address: res.Address, Reason: ErrDisposable}
+	}
+	if res.Free && v.cfg.RejectFree {
+		return res, &Error{Address: res.Address, Reason: ErrFreeMail}
+	}
+	if res.Role && v.cfg.RejectRole {
+		return res, &Error{Address: res.Address, Reason: ErrRoleAccount}
+	}
+	if !v.cfg.CheckMX && v.cfg.Callout == nil {
+		return res, nil
+	}
+	hosts, err := lookupMX(ctx, v.cfg.Resolver, domain)
+	if err != nil {
+		return res, &Error{Address: res.Address, Reason: ErrNoMX,
+			Detail: err.Error()}
+	}
+	res.MX = hosts
+	if v.cfg.Callout != nil {
+		reply, err := callout(ctx, *v.cfg.Callout, hosts, res.Address)
+		res.Callout = reply
+		if err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// Syntax checks addr beyond net/mail: length limits (RFC 5321), a dotted
+// domain of valid LDH labels, and no trailing garbage.
+//
+// Parameters:
+//   - addr: The address, with or without display name.
+//
+// Returns:
+//   - string: The local part.
+//   - string: The lowercased domain.
+//   - error: A *Error wrapping ErrSyntax if invalid.
+func Syntax(addr string) (string, string, error) {
+	bad := func(detail string) (string, string, error) {
+		return "", "", &Error{Address: addr, Reason: ErrSyntax,
+			Detail: detail}
+	}
+	ma, err := mail.ParseAddress(strings.TrimSpace(addr))
+	if err != nil {
+		return bad(err.Error())
+	}
+	at := strings.LastIndex(ma.Address, "@")
+	if at <= 0 {
+		return bad("missing local part")
+	}
+	local, domain := ma.Address[:at], strings.ToLower(ma.Address[at+1:])
+	if len(local) > 64 {
+		return bad("local part longer than 64 octets")
+	}
+	if len(domain) > 253 {
+		return bad("domain longer than 253 octets")
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return bad("domain has no dot")
+	}
+	for _, l := range labels {
+		if !validLabel(l) {
+			return bad(fmt.Sprintf("invalid domain label %q", l))
+		}
+	}
+	return local, domain, nil
+}
+
+// validLabel reports whether l is a valid LDH DNS label.
+func validLabel(l string) bool {
+	if l == "" || len(l) > 63 || l[0] == '-' || l[len(l)-1] == '-' {
+		return false
+	}
+	for i := 0; i < len(l); i++ {
+		c := l[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// lookupMX returns the MX hosts for domain, falling back to the domain
+// itself when it has address records (RFC 5321 5.1). A null MX
+// (RFC 7505) means the domain accepts no mail.
+func lookupMX(ctx context.Context, r Resolver, domain string) ([]string, error) {
+	mxs, err := r.LookupMX(ctx, domain)
+	if err == nil && len(mxs) > 0 {
+		if len(mxs) == 1 && (mxs[0].Host == "." || mxs[0].Host == "") {
+			return nil, errors.New("null MX")
+		}
+		out := make([]string, 0, len(mxs))
+		for _, mx := range mxs {
+			out = append(out, strings.TrimSuffix(mx.Host, "."))
+		}
+		return out, nil
+	}
+	if addrs, herr := r.LookupHost(ctx, domain); herr == nil &&
+		len(addrs) > 0 {
+		return []string{domain}, nil
+	}
+	if err == nil {
+		err = errors.New("no MX or address records")
+	}
+	return nil, err
+}
+
+// callout probes the MX hosts in order until one gives a definitive
+// answer to RCPT TO. 4xx replies and connection failures try the next
+// host; if none answers, the address is not rejected.
+func callout(
+	ctx context.Context,
+	cfg CalloutConfig,
+	hosts []string,
+	addr string,
+) (string, error) {
+	if cfg.Port == 0 {
+		cfg.Port = 25
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.HeloName == "" {
+		cfg.HeloName = "localhost"
+	}
+	var lastReply string
+	for _, h := range hosts {
+		reply, code, err := probe(ctx, cfg, h, addr)
+		if ctx.Err() != nil {
+			return reply, ctx.Err()
+		}
+		if err != nil {
+			lastReply = reply
+			continue
+		}
+		if code >= 500 {
+			return reply, &Error{Address: addr, Reason: ErrRejected,
+				Detail: reply}
+		}
+		if code >= 200 && code < 300 {
+			return reply, nil
+		}
+		lastReply = reply
+	}
+	return lastReply, nil
+}
+
+// probe runs EHLO, MAIL FROM and RCPT TO against one host, then QUITs.
+// It returns the RCPT reply and its code; err is non-nil when the probe
+// could not reach the RCPT stage.
+func probe(
+	ctx context.Context,
+	cfg CalloutConfig,
+	host, addr string,
+) (string, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	dial := cfg.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	conn, err := dial(ctx, "tcp",
+		net.JoinHostPort(host, strconv.Itoa(cfg.Port)))
+	if err != nil {
+		return err.Error(), 0, err
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err.Error(), 0, err
+	}
+	defer c.Close()
+	if err := c.Hello(cfg.HeloName); err != nil {
+		return err.Error(), 0, err
+	}
+	from := strings.Trim(cfg.MailFrom, "<>")
+	if err := c.Mail(from); err != nil {
+		return err.Error(), 0, err
+	}
+	id, err := c.Text.Cmd("RCPT TO:<%s>", addr)
+	if err != nil {
+		return err.Error(), 0, err
+	}
+	c.Text.StartResponse(id)
+	code, msg, _ := c.Text.ReadResponse(0)
+	c.Text.EndResponse(id)
+	_ = c.Reset()
+	_ = c.Quit()
+	return fmt.Sprintf("%d %s", code, msg), code, nil
+}
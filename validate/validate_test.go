@@ -0,0 +1,153 @@
+package validate
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeResolver struct {
+	mx    map[string][]*net.MX
+	hosts map[string][]string
+}
+
+func (r fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if mx, ok := r.mx[name]; ok {
+		return mx, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if h, ok := r.hosts[host]; ok {
+		return h, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestSyntax(t *testing.T) {
+	if l, d, err := Syntax("Ada <ada@Example.COM>"); err != nil || l != "ada" || d != "example.com" {
+		t.Fatalf("unexpected: %q %q %v", l, d, err)
+	}
+	for _, a := range []string{
+		"not-an-email",
+		"ada@localhost",
+		"ada@-bad.example.com",
+		"ada@exa_mple.com",
+		strings.Repeat("a", 65) + "@example.com",
+	} {
+		if _, _, err := Syntax(a); !errors.Is(err, ErrSyntax) {
+			t.Fatalf("expected ErrSyntax for %q, got %v", a, err)
+		}
+	}
+}
+
+func TestValidateRoleAndDisposable(t *testing.T) {
+	v := New(Config{
+		IsDisposable: func(d string) bool { return d == "mailinator.com" },
+		RejectRole:   true,
+	})
+	ctx := context.Background()
+	if err := v.CheckAddress(ctx, "x@mailinator.com"); !errors.Is(err, ErrDisposable) {
+		t.Fatalf("expected ErrDisposable, got %v", err)
+	}
+	if err := v.CheckAddress(ctx, "Postmaster@example.com"); !errors.Is(err, ErrRoleAccount) {
+		t.Fatalf("expected ErrRoleAccount, got %v", err)
+	}
+	res, err := New(Config{}).Validate(ctx, "info@example.com")
+	if err != nil || !res.Role {
+		t.Fatalf("expected role flag without rejection: %+v %v", res, err)
+	}
+}
+
+func TestValidateMX(t *testing.T) {
+	r := fakeResolver{
+		mx: map[string][]*net.MX{
+			"example.com": {{Host: "mx1.example.com.", Pref: 10}},
+			"null.test":   {{Host: ".", Pref: 0}},
+		},
+		hosts: map[string][]string{"a-only.test": {"192.0.2.1"}},
+	}
+	v := New(Config{CheckMX: true, Resolver: r})
+	ctx := context.Background()
+	res, err := v.Validate(ctx, "ada@example.com")
+	if err != nil || len(res.MX) != 1 || res.MX[0] != "mx1.example.com" {
+		t.Fatalf("unexpected MX result: %+v %v", res, err)
+	}
+	if res, err := v.Validate(ctx, "ada@a-only.test"); err != nil || res.MX[0] != "a-only.test" {
+		t.Fatalf("expected implicit MX: %+v %v", res, err)
+	}
+	for _, a := range []string{"ada@null.test", "ada@missing.test"} {
+		if err := v.CheckAddress(ctx, a); !errors.Is(err, ErrNoMX) {
+			t.Fatalf("expected ErrNoMX for %s, got %v", a, err)
+		}
+	}
+}
+
+// fakeSMTP answers one session on c, replying rcptReply to RCPT TO.
+func fakeSMTP(c net.Conn, rcptReply string) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	write := func(s string) { _, _ = c.Write([]byte(s + "\r\n")) }
+	write("220 mx.test ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			write("250-mx.test")
+			write("250 8BITMIME")
+		case strings.HasPrefix(cmd, "RCPT"):
+			write(rcptReply)
+		case strings.HasPrefix(cmd, "QUIT"):
+			write("221 bye")
+			return
+		default:
+			write("250 ok")
+		}
+	}
+}
+
+func TestValidateCalloutAndCache(t *testing.T) {
+	dials := 0
+	reply := "550 5.1.1 no such user"
+	r := fakeResolver{mx: map[string][]*net.MX{
+		"example.com": {{Host: "mx1.example.com.", Pref: 10}},
+	}}
+	v := New(Config{
+		Resolver: r,
+		CacheTTL: time.Minute,
+		Callout: &CalloutConfig{
+			Timeout: time.Second,
+			Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				dials++
+				if addr != "mx1.example.com:25" {
+					t.Errorf("unexpected dial addr %s", addr)
+				}
+				client, server := net.Pipe()
+				go fakeSMTP(server, reply)
+				return client, nil
+			},
+		},
+	})
+	ctx := context.Background()
+	err := v.CheckAddress(ctx, "nobody@example.com")
+	if !errors.Is(err, ErrRejected) || !strings.Contains(err.Error(), "no such user") {
+		t.Fatalf("expected ErrRejected, got %v", err)
+	}
+	if err := v.CheckAddress(ctx, "nobody@example.com"); !errors.Is(err, ErrRejected) || dials != 1 {
+		t.Fatalf("expected cached rejection, dials=%d err=%v", dials, err)
+	}
+	reply = "250 2.1.5 ok"
+	res, err := v.Validate(ctx, "ada@example.com")
+	if err != nil || !strings.HasPrefix(res.Callout, "250") {
+		t.Fatalf("expected accepted callout: %+v %v", res, err)
+	}
+}
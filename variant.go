@@ -0,0 +1,110 @@
+package email
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+// Variant is one arm of an A/B test.
+type Variant struct {
+	// Name identifies the variant downstream, e.g. "a" or "short-subject".
+	// It is written to the X-Variant header.
+	Name string
+	// Template is the TemplateSet template to render.
+	Template string
+	// Subject, if set, replaces the subject of the base message.
+	Subject string
+	// Weight is the variant's share of recipients relative to the other
+	// weights; zero pauses it.
+	Weight int
+}
+
+// VariantSet picks a variant per recipient. The choice depends only on
+// the address and Salt, so the same recipient always gets the same
+// variant of a campaign, across processes and re-runs.
+type VariantSet struct {
+	// Salt separates campaigns: use a new salt for a new test so
+	// recipients are assigned afresh.
+	Salt     string
+	Variants []Variant
+}
+
+// Pick returns the variant for recipient, chosen by a hash of the
+// lowercased address and Salt in proportion to the weights.
+//
+// Parameters:
+//   - recipient: The recipient address.
+//
+// Returns:
+//   - Variant: The chosen variant.
+//   - error: The error if the set has no positive weight or a
+//     negative weight.
+func (s VariantSet) Pick(recipient string) (Variant, error) {
+	total := 0
+	for _, v := range s.Variants {
+		if v.Weight < 0 {
+			return Variant{}, fmt.Errorf("variant %q: negative weight", v.Name)
+		}
+		total += v.Weight
+	}
+	if total == 0 {
+		return Variant{}, errors.New("variant: no variant with a positive weight")
+	}
+	sum := sha256.Sum256([]byte(s.Salt + "\x00" + strings.ToLower(strings.TrimSpace(recipient))))
+	n := int(binary.BigEndian.Uint64(sum[:8]) % uint64(total))
+	var picked Variant
+	for _, v := range s.Variants {
+		if n < v.Weight {
+			picked = v
+			break
+		}
+		n -= v.Weight
+	}
+	return picked, nil
+}
+
+// RenderMessage picks the variant for to, renders its template with
+// data into a copy of base addressed to to, and stamps the variant: the
+// X-Variant header is set to its name and, if base has a TrackingID,
+// "+" and the name are appended to it.
+//
+// Parameters:
+//   - t: The templates.
+//   - to: The recipient.
+//   - data: The template data.
+//   - base: The message supplying From, Subject and other fields.
+//
+// Returns:
+//   - types.Message: The message.
+//   - Variant: The chosen variant.
+//   - error: The error if no variant can be picked or rendering fails.
+func (s VariantSet) RenderMessage(
+	t *TemplateSet,
+	to types.Address,
+	data any,
+	base types.Message,
+) (types.Message, Variant, error) {
+	v, err := s.Pick(to.Mail)
+	if err != nil {
+		return types.Message{}, Variant{}, err
+	}
+	base.To = []types.Address{to}
+	if v.Subject != "" {
+		base.Subject = v.Subject
+	}
+	if base.TrackingID != "" {
+		base.TrackingID += "+" + v.Name
+	}
+	base.Headers = base.CloneHeaders()
+	base.Headers.Set("X-Variant", v.Name)
+	msg, err := t.RenderMessage(v.Template, data, base)
+	if err != nil {
+		return types.Message{}, Variant{}, fmt.Errorf("variant %q: %w", v.Name, err)
+	}
+	return msg, v, nil
+}
@@ -0,0 +1,77 @@
+package email
+
+import (
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/aatuh/email/v2/types"
+)
+
+func TestVariantSetPick(t *testing.T) {
+	set := VariantSet{Salt: "spring-sale", Variants: []Variant{
+		{Name: "a", Weight: 3},
+		{Name: "b", Weight: 1},
+		{Name: "paused", Weight: 0},
+	}}
+	counts := map[string]int{}
+	for i := range 4000 {
+		addr := fmt.Sprintf("user%d@example.com", i)
+		v, err := set.Pick(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, _ := set.Pick(fmt.Sprintf(" USER%d@Example.com", i))
+		if again.Name != v.Name {
+			t.Fatalf("%s: %s then %s", addr, v.Name, again.Name)
+		}
+		counts[v.Name]++
+	}
+	if counts["paused"] != 0 || counts["a"] < 2800 || counts["a"] > 3200 {
+		t.Fatalf("counts = %v", counts)
+	}
+
+	if _, err := (VariantSet{Variants: []Variant{{Name: "x"}}}).Pick("a@example.com"); err == nil {
+		t.Fatal("expected error without positive weights")
+	}
+	if _, err := (VariantSet{Variants: []Variant{{Name: "x", Weight: -1}}}).Pick("a@example.com"); err == nil {
+		t.Fatal("expected error for negative weight")
+	}
+}
+
+func TestVariantSetRenderMessage(t *testing.T) {
+	tpl, err := LoadTemplates(fstest.MapFS{
+		"long.txt.tmpl":  {Data: []byte("Long {{.}}")},
+		"short.txt.tmpl": {Data: []byte("Short {{.}}")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := VariantSet{Salt: "s", Variants: []Variant{
+		{Name: "long", Template: "long", Weight: 1},
+		{Name: "short", Template: "short", Subject: "Sale!", Weight: 1},
+	}}
+	base := types.Message{Subject: "Our spring sale", TrackingID: "camp-7"}
+	base.Headers.Set("X-Variant", "stale")
+	seen := map[string]bool{}
+	for i := 0; len(seen) < 2 && i < 100; i++ {
+		to := types.Address{Mail: fmt.Sprintf("u%d@example.com", i)}
+		msg, v, err := set.RenderMessage(tpl, to, "text", base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[v.Name] = true
+		wantSubject := map[string]string{"long": "Our spring sale", "short": "Sale!"}[v.Name]
+		wantBody := map[string]string{"long": "Long text", "short": "Short text"}[v.Name]
+		if msg.To[0] != to || msg.Subject != wantSubject || string(msg.Plain) != wantBody ||
+			msg.TrackingID != "camp-7+"+v.Name || msg.Headers.Get("X-Variant") != v.Name {
+			t.Fatalf("variant %s: %+v", v.Name, msg)
+		}
+	}
+	if len(seen) != 2 {
+		t.Fatalf("variants seen = %v", seen)
+	}
+	if base.Headers.Get("X-Variant") != "stale" {
+		t.Fatal("base headers modified")
+	}
+}
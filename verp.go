@@ -0,0 +1,65 @@
+package email
+
+import (
+	"errors"
+	"strings"
+)
+
+// VERP encodes rcpt into the bounce address so that a bounce identifies
+// the failed recipient without parsing the DSN (Variable Envelope Return
+// Path). For bounce "bounces@example.com" and rcpt "ada@example.org" it
+// returns "bounces+ada=example.org@example.com".
+//
+// Parameters:
+//   - bounce: The bounce mailbox.
+//   - rcpt: The recipient to encode.
+//
+// Returns:
+//   - string: The VERP envelope sender.
+//   - error: An error if either address lacks a domain.
+func VERP(bounce, rcpt string) (string, error) {
+	bl, bd, ok := splitAddr(bounce)
+	if !ok {
+		return "", errors.New("verp: invalid bounce address")
+	}
+	rl, rd, ok := splitAddr(rcpt)
+	if !ok {
+		return "", errors.New("verp: invalid recipient address")
+	}
+	return bl + "+" + rl + "=" + rd + "@" + bd, nil
+}
+
+// ParseVERP decodes a VERP address produced by VERP.
+//
+// Parameters:
+//   - addr: The address the bounce was delivered to.
+//
+// Returns:
+//   - string: The bounce mailbox.
+//   - string: The original recipient.
+//   - error: An error if addr is not a VERP address.
+func ParseVERP(addr string) (string, string, error) {
+	local, domain, ok := splitAddr(addr)
+	if !ok {
+		return "", "", errors.New("verp: invalid address")
+	}
+	base, tag, ok := strings.Cut(local, "+")
+	if !ok {
+		return "", "", errors.New("verp: no recipient tag")
+	}
+	i := strings.LastIndex(tag, "=")
+	if i <= 0 || i == len(tag)-1 {
+		return "", "", errors.New("verp: malformed recipient tag")
+	}
+	return base + "@" + domain, tag[:i] + "@" + tag[i+1:], nil
+}
+
+// splitAddr splits a bare address at its last '@'.
+func splitAddr(addr string) (string, string, bool) {
+	addr = strings.TrimSpace(addr)
+	i := strings.LastIndex(addr, "@")
+	if i <= 0 || i == len(addr)-1 {
+		return "", "", false
+	}
+	return addr[:i], addr[i+1:], true
+}
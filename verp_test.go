@@ -0,0 +1,23 @@
+package email
+
+import "testing"
+
+func TestVERPRoundTrip(t *testing.T) {
+	v, err := VERP("bounces@example.com", "ada+news@example.org")
+	if err != nil {
+		t.Fatalf("verp: %v", err)
+	}
+	if v != "bounces+ada+news=example.org@example.com" {
+		t.Fatalf("unexpected VERP: %q", v)
+	}
+	bounce, rcpt, err := ParseVERP(v)
+	if err != nil || bounce != "bounces@example.com" || rcpt != "ada+news@example.org" {
+		t.Fatalf("parse: %q %q %v", bounce, rcpt, err)
+	}
+	if _, err := VERP("bounces", "ada@example.org"); err == nil {
+		t.Fatalf("expected error for bounce without domain")
+	}
+	if _, _, err := ParseVERP("bounces@example.com"); err == nil {
+		t.Fatalf("expected error for untagged address")
+	}
+}
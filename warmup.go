@@ -0,0 +1,235 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aatuh/email/v2/internal"
+)
+
+// ErrWarmupQuota is returned (wrapped) by WarmupLimiter.Acquire when the
+// day's quota is used up.
+var ErrWarmupQuota = errors.New("email: warm-up quota for today used up")
+
+// WarmupState is the progress of a warm-up, as persisted by a
+// WarmupStore. The zero value means the warm-up has not started.
+type WarmupState struct {
+	Start time.Time `json:"start"` // first day of the schedule
+	Day   time.Time `json:"day"`   // day Sent counts
+	Sent  int       `json:"sent"`  // sends on Day
+}
+
+// WarmupStore persists warm-up progress across restarts.
+type WarmupStore interface {
+	// LoadWarmup returns the saved state, or the zero state if none.
+	LoadWarmup(ctx context.Context) (WarmupState, error)
+	// SaveWarmup replaces the saved state.
+	SaveWarmup(ctx context.Context, s WarmupState) error
+}
+
+// WarmupConfig configures a WarmupLimiter.
+type WarmupConfig struct {
+	// Schedule is the number of sends allowed on each day of the
+	// warm-up, starting with the day of the first send, e.g.
+	// []int{50, 100, 500, 1000}. After the last day sends are no
+	// longer limited.
+	Schedule []int
+
+	// Store keeps the progress; nil keeps it in memory only.
+	Store WarmupStore
+
+	// Location sets the day boundaries; defaults to UTC.
+	Location *time.Location
+
+	// Now returns the current time; defaults to the clock set with
+	// SetClock.
+	Now func() time.Time
+}
+
+// WarmupLimiter enforces a daily send quota that ramps up over the days
+// of a new IP address or domain warm-up. It is safe for concurrent use
+// by one process; processes sharing a Store may overshoot the quota
+// slightly.
+type WarmupLimiter struct {
+	cfg WarmupConfig
+
+	mu    sync.Mutex
+	state WarmupState // used when Store is nil
+}
+
+// NewWarmupLimiter creates a warm-up limiter.
+//
+// Parameters:
+//   - cfg: The warm-up config.
+//
+// Returns:
+//   - *WarmupLimiter: The limiter.
+func NewWarmupLimiter(cfg WarmupConfig) *WarmupLimiter {
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	if cfg.Now == nil {
+		cfg.Now = internal.Now
+	}
+	return &WarmupLimiter{cfg: cfg}
+}
+
+// Acquire counts one send against today's quota. It does not wait for
+// the next day: a send over quota fails with ErrWarmupQuota, so queued
+// mail can be retried later. It implements RateLimiter.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - error: An error wrapping ErrWarmupQuota if today's quota is used
+//     up, or the Store's error.
+func (l *WarmupLimiter) Acquire(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s, err := l.load(ctx)
+	if err != nil {
+		return err
+	}
+	today := l.today()
+	if s.Start.IsZero() {
+		s.Start = today
+	}
+	if !s.Day.Equal(today) {
+		s.Day, s.Sent = today, 0
+	}
+	day := int(today.Sub(s.Start) / (24 * time.Hour))
+	if day >= 0 && day < len(l.cfg.Schedule) && s.Sent >= l.cfg.Schedule[day] {
+		return fmt.Errorf("%w: day %d allows %d", ErrWarmupQuota, day+1,
+			l.cfg.Schedule[day])
+	}
+	s.Sent++
+	return l.save(ctx, s)
+}
+
+// Remaining returns the number of sends left today, or -1 once the
+// schedule has ended.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - int: The sends left today.
+//   - error: The Store's error.
+func (l *WarmupLimiter) Remaining(ctx context.Context) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, err := l.load(ctx)
+	if err != nil {
+		return 0, err
+	}
+	today := l.today()
+	if s.Start.IsZero() {
+		s.Start = today
+	}
+	day := int(today.Sub(s.Start) / (24 * time.Hour))
+	if day < 0 || day >= len(l.cfg.Schedule) {
+		return -1, nil
+	}
+	sent := 0
+	if s.Day.Equal(today) {
+		sent = s.Sent
+	}
+	return max(l.cfg.Schedule[day]-sent, 0), nil
+}
+
+// today returns the current date in Location as midnight UTC, so that
+// days are exactly 24 hours apart regardless of DST.
+func (l *WarmupLimiter) today() time.Time {
+	y, m, d := l.cfg.Now().In(l.cfg.Location).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func (l *WarmupLimiter) load(ctx context.Context) (WarmupState, error) {
+	if l.cfg.Store == nil {
+		return l.state, nil
+	}
+	s, err := l.cfg.Store.LoadWarmup(ctx)
+	if err != nil {
+		return s, fmt.Errorf("email: load warm-up state: %w", err)
+	}
+	return s, nil
+}
+
+func (l *WarmupLimiter) save(ctx context.Context, s WarmupState) error {
+	if l.cfg.Store == nil {
+		l.state = s
+		return nil
+	}
+	if err := l.cfg.Store.SaveWarmup(ctx, s); err != nil {
+		return fmt.Errorf("email: save warm-up state: %w", err)
+	}
+	return nil
+}
+
+// FileWarmupStore keeps warm-up state in a JSON file, replaced
+// atomically on every save.
+type FileWarmupStore struct {
+	Path string
+}
+
+// LoadWarmup reads the state file. A missing file is the zero state.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - WarmupState: The saved state.
+//   - error: An error if the file cannot be read or parsed.
+func (f FileWarmupStore) LoadWarmup(ctx context.Context) (WarmupState, error) {
+	var s WarmupState
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(data, &s)
+	return s, err
+}
+
+// SaveWarmup writes the state file through a temporary file and rename.
+//
+// Parameters:
+//   - ctx: The context.
+//   - s: The state.
+//
+// Returns:
+//   - error: An error if the file cannot be written.
+func (f FileWarmupStore) SaveWarmup(ctx context.Context, s WarmupState) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.Path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWarmupLimiterSchedule(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	store := FileWarmupStore{Path: filepath.Join(t.TempDir(), "warmup.json")}
+	newLimiter := func() *WarmupLimiter {
+		return NewWarmupLimiter(WarmupConfig{
+			Schedule: []int{2, 3},
+			Store:    store,
+			Now:      func() time.Time { return now },
+		})
+	}
+	l := newLimiter()
+	send := func(n int) int {
+		ok := 0
+		for i := 0; i < n; i++ {
+			err := l.Acquire(ctx)
+			if err == nil {
+				ok++
+			} else if !errors.Is(err, ErrWarmupQuota) {
+				t.Fatalf("acquire: %v", err)
+			}
+		}
+		return ok
+	}
+
+	if got := send(5); got != 2 {
+		t.Fatalf("day 1: %d sends allowed, want 2", got)
+	}
+	// Progress survives a restart.
+	l = newLimiter()
+	if got := send(1); got != 0 {
+		t.Fatalf("day 1 after restart: %d sends allowed", got)
+	}
+	now = now.Add(2 * time.Hour)
+	if rem, _ := l.Remaining(ctx); rem != 3 {
+		t.Fatalf("day 2 remaining = %d, want 3", rem)
+	}
+	if got := send(5); got != 3 {
+		t.Fatalf("day 2: %d sends allowed, want 3", got)
+	}
+	now = now.Add(24 * time.Hour)
+	if got := send(10); got != 10 {
+		t.Fatalf("after schedule: %d sends allowed, want 10", got)
+	}
+	if rem, _ := l.Remaining(ctx); rem != -1 {
+		t.Fatalf("remaining after schedule = %d", rem)
+	}
+}
+
+func TestWarmupLimiterLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*3600)
+	now := time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC) // 23:00 local
+	l := NewWarmupLimiter(WarmupConfig{
+		Schedule: []int{1, 1},
+		Location: loc,
+		Now:      func() time.Time { return now },
+	})
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Hour) // next local day, same UTC day
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatalf("new local day should reset the quota: %v", err)
+	}
+}
+
+func TestWarmupLimiterUsesSetClock(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	defer SetClock(func() time.Time { return now })()
+	l := NewWarmupLimiter(WarmupConfig{Schedule: []int{1, 2}})
+	if err := l.Acquire(ctx); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if err := l.Acquire(ctx); !errors.Is(err, ErrWarmupQuota) {
+		t.Fatalf("day 1: got %v, want ErrWarmupQuota", err)
+	}
+	now = now.Add(24 * time.Hour)
+	if rem, _ := l.Remaining(ctx); rem != 2 {
+		t.Fatalf("day 2 remaining = %d, want 2", rem)
+	}
+}